@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// affinityConfigMap is the ConfigMap, in the lease namespace, recording the last address of each slot of the pools
+	affinityConfigMap = "kubeip-affinity"
+	// slotAnnotation is the node annotation, set by the provisioning tooling, naming the slot of the node in its pool
+	slotAnnotation = "kubeip.com/slot"
+	// slotVacancyTimeout is the time the slot of a deleted node keeps its address for a replacement before it is pruned
+	slotVacancyTimeout = 24 * time.Hour
+)
+
+// slotEntry is the address recorded for a slot in the affinity ConfigMap, with the node holding it and the time the node
+// was found deleted
+type slotEntry struct {
+	Address     string     `json:"address"`
+	Node        string     `json:"node,omitempty"`
+	VacantSince *time.Time `json:"vacantSince,omitempty"`
+}
+
+// parseSlotEntry parses an entry of the affinity ConfigMap; the entries recorded before the nodes were tracked hold the
+// address only
+func parseSlotEntry(value string) slotEntry {
+	var entry slotEntry
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		return slotEntry{Address: value}
+	}
+	return entry
+}
+
+func (e slotEntry) String() string {
+	value, _ := json.Marshal(e) //nolint:errchkjson
+	return string(value)
+}
+
+// affinitySlot returns the key of the slot of the node: its pool and the value of the slot annotation or, without it,
+// the instance group and the index ending the node name (e.g. edge-a-3 for the instance 3 of the edge-a group, a name
+// a stateful instance group keeps when recreating the instance); empty without the annotation if the node has no pool
+// or its name no index: the index alone collides across the instance groups of a pool
+func affinitySlot(n *types.Node) string {
+	slot := n.Annotations[slotAnnotation]
+	if slot == "" {
+		i := strings.LastIndexByte(n.Name, '-')
+		if n.Pool == "" || i <= 0 || i == len(n.Name)-1 || strings.Trim(n.Name[i+1:], "0123456789") != "" {
+			return ""
+		}
+		slot = n.Name
+	}
+	// ConfigMap keys hold alphanumeric characters, '-', '_' and '.' only
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, n.Pool+"."+slot)
+}
+
+// reclaimAddress claims the static public IP address the previous node of the slot of the node held, so the addresses
+// used by a pool stay with its slots as the instances are replaced; returns the claimed address, empty without an
+// address recorded for the slot, with assigners not claiming addresses or if the address is held by another node: the
+// address is then assigned from the pool
+func reclaimAddress(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, assigner address.Assigner, n *types.Node, cfg *config.Config) string {
+	// a claimed address is assigned regardless of the slot
+	if !cfg.AddressAffinity || n.ClaimedAddress != "" {
+		return ""
+	}
+	slot := affinitySlot(n)
+	if slot == "" {
+		return ""
+	}
+	logger := log.WithFields(logrus.Fields{"node": n.Name, "slot": slot})
+	claimer, ok := assigner.(address.Claimer)
+	if !ok {
+		logger.Warn("cloud provider does not support claiming a given address, skipping address affinity")
+		return ""
+	}
+	previous, err := slotAddress(ctx, client, cfg.LeaseNamespace, slot)
+	if err != nil {
+		logger.WithError(err).Warn("failed to get the address of the slot, skipping address affinity")
+		return ""
+	}
+	if previous == "" {
+		return ""
+	}
+	logger = logger.WithField("address", previous)
+	claimed, err := claimAddress(ctx, log, client, claimer, n, previous, cfg)
+	if err != nil {
+		logger.WithError(err).Info("static public IP address of the slot not available, assigning an address of the pool")
+		return ""
+	}
+	logger.Info("static public IP address of the slot reclaimed")
+	return claimed
+}
+
+// slotAddress returns the static public IP address recorded for the slot, empty if none
+func slotAddress(ctx context.Context, client kubernetes.Interface, namespace, slot string) (string, error) {
+	entry, _, err := getSlotEntry(ctx, client, namespace, slot)
+	return entry.Address, err
+}
+
+// getSlotEntry returns the entry recorded for the slot; false if none
+func getSlotEntry(ctx context.Context, client kubernetes.Interface, namespace, slot string) (slotEntry, bool, error) {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, affinityConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return slotEntry{}, false, nil
+	}
+	if err != nil {
+		return slotEntry{}, false, errors.Wrapf(err, "failed to get ConfigMap %s/%s", namespace, affinityConfigMap)
+	}
+	value, ok := cm.Data[slot]
+	if !ok {
+		return slotEntry{}, false, nil
+	}
+	return parseSlotEntry(value), true, nil
+}
+
+// recordSlotAddress records the static public IP address assigned to the node for its slot and prunes the slots of the
+// deleted nodes; failures are logged only, the next node of the slot then gets an address of the pool
+func recordSlotAddress(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, n *types.Node, assigned string, cfg *config.Config) {
+	if !cfg.AddressAffinity || assigned == "" {
+		return
+	}
+	slot := affinitySlot(n)
+	if slot == "" {
+		return
+	}
+	if err := upsertSlotAddress(ctx, client, cfg.LeaseNamespace, slot, slotEntry{Address: assigned, Node: n.Name}); err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"slot": slot, "address": assigned}).Warn("failed to record the address of the slot")
+	}
+	if err := pruneSlots(ctx, client, cfg.LeaseNamespace, time.Now()); err != nil {
+		log.WithError(err).Warn("failed to prune the slots of the deleted nodes")
+	}
+}
+
+// upsertSlotAddress writes the entry of the slot in the affinity ConfigMap, created on first use; writes conflicting
+// with the agent of another node are retried from the current ConfigMap
+func upsertSlotAddress(ctx context.Context, client kubernetes.Interface, namespace, slot string, entry slotEntry) error {
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	return retry.OnError(retry.DefaultRetry, isWriteConflict, func() error { //nolint:wrapcheck
+		current, err := configMaps.Get(ctx, affinityConfigMap, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			cm := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: affinityConfigMap, Namespace: namespace},
+				Data:       map[string]string{slot: entry.String()},
+			}
+			if _, err = configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+				return errors.Wrapf(err, "failed to create ConfigMap %s/%s", namespace, affinityConfigMap)
+			}
+		case err != nil:
+			return errors.Wrapf(err, "failed to get ConfigMap %s/%s", namespace, affinityConfigMap)
+		case current.Data[slot] == entry.String():
+			return nil
+		default:
+			if current.Data == nil {
+				current.Data = make(map[string]string)
+			}
+			current.Data[slot] = entry.String()
+			if _, err = configMaps.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+				return errors.Wrapf(err, "failed to update ConfigMap %s/%s", namespace, affinityConfigMap)
+			}
+		}
+		return nil
+	})
+}
+
+// pruneSlots marks vacant the slots whose node is deleted and removes the slots vacant for longer than the vacancy
+// timeout: a node replaced within the timeout still takes the address of its slot back, while the slots of the nodes
+// scaled in do not accumulate; the slots recorded without their node are left for their next assignment to record it
+func pruneSlots(ctx context.Context, client kubernetes.Interface, namespace string, now time.Time) error {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list nodes")
+	}
+	live := make(map[string]bool, len(nodes.Items))
+	for i := range nodes.Items {
+		live[nodes.Items[i].Name] = true
+	}
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error { //nolint:wrapcheck
+		current, err := configMaps.Get(ctx, affinityConfigMap, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get ConfigMap %s/%s", namespace, affinityConfigMap)
+		}
+		changed := false
+		for slot, value := range current.Data {
+			entry := parseSlotEntry(value)
+			switch {
+			case entry.Node == "" || live[entry.Node] && entry.VacantSince == nil:
+				continue
+			case live[entry.Node]:
+				// the node is back, e.g. recreated with the name of its instance
+				entry.VacantSince = nil
+				current.Data[slot] = entry.String()
+			case entry.VacantSince == nil:
+				entry.VacantSince = &now
+				current.Data[slot] = entry.String()
+			case now.Sub(*entry.VacantSince) > slotVacancyTimeout:
+				delete(current.Data, slot)
+			default:
+				continue
+			}
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		if _, err = configMaps.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrapf(err, "failed to update ConfigMap %s/%s", namespace, affinityConfigMap)
+		}
+		return nil
+	})
+}
+
+func isWriteConflict(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_affinitySlot(t *testing.T) {
+	tests := []struct {
+		name string
+		node *types.Node
+		want string
+	}{
+		{"instance group index", &types.Node{Name: "edge-a-3", Pool: "edge"}, "edge.edge-a-3"},
+		{"index of another instance group", &types.Node{Name: "edge-b-3", Pool: "edge"}, "edge.edge-b-3"},
+		{"slot annotation", &types.Node{Name: "gke-edge-5c1a-x7k2", Pool: "edge", Annotations: map[string]string{slotAnnotation: "eu/a"}}, "edge.eu_a"},
+		{"no index", &types.Node{Name: "gke-edge-5c1a-x7k2", Pool: "edge"}, ""},
+		{"no pool", &types.Node{Name: "edge-a-3"}, ""},
+		{"no dash", &types.Node{Name: "42", Pool: "edge"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, affinitySlot(tt.node))
+		})
+	}
+}
+
+func Test_reclaimAddress(t *testing.T) {
+	log := logrus.NewEntry(logrus.New())
+	cfg := &config.Config{AddressAffinity: true, LeaseNamespace: "default", LeaseDuration: 5}
+	client := fake.NewSimpleClientset()
+	assigner := &claimAssigner{held: map[string]string{}}
+
+	old := &types.Node{Name: "edge-pool-3", Instance: "i-old", Pool: "edge"}
+	recordSlotAddress(context.Background(), log, client, old, "3.3.3.3", cfg)
+	recorded, err := slotAddress(context.Background(), client, cfg.LeaseNamespace, "edge.edge-pool-3")
+	require.NoError(t, err)
+	assert.Equal(t, "3.3.3.3", recorded)
+
+	// the replacement of the slot reclaims the recorded address
+	replacement := &types.Node{Name: "edge-pool-3", Instance: "i-new", Pool: "edge"}
+	assert.Equal(t, "3.3.3.3", reclaimAddress(context.Background(), log, client, assigner, replacement, cfg))
+
+	// the address of the slot is held by another node: the pool assigns one
+	assigner.held = map[string]string{"i-other": "3.3.3.3"}
+	assert.Empty(t, reclaimAddress(context.Background(), log, client, assigner, replacement, cfg))
+
+	// no address recorded for the slot
+	other := &types.Node{Name: "edge-pool-4", Instance: "i-4", Pool: "edge"}
+	assert.Empty(t, reclaimAddress(context.Background(), log, client, assigner, other, cfg))
+	assert.Equal(t, 2, assigner.claims)
+}
+
+func Test_pruneSlots(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-a-1"}})
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	expired := now.Add(-slotVacancyTimeout - time.Minute)
+	_, err := client.CoreV1().ConfigMaps("default").Create(context.Background(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: affinityConfigMap, Namespace: "default"},
+		Data: map[string]string{
+			"edge.edge-a-1": slotEntry{Address: "1.1.1.1", Node: "edge-a-1"}.String(),
+			"edge.edge-a-2": slotEntry{Address: "2.2.2.2", Node: "edge-a-2"}.String(),
+			"edge.edge-a-3": slotEntry{Address: "3.3.3.3", Node: "edge-a-3", VacantSince: &expired}.String(),
+			"edge.legacy":   "4.4.4.4",
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, pruneSlots(context.Background(), client, "default", now))
+	cm, err := client.CoreV1().ConfigMaps("default").Get(context.Background(), affinityConfigMap, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"edge.edge-a-1": slotEntry{Address: "1.1.1.1", Node: "edge-a-1"}.String(),
+		// the slot of the deleted node keeps its address for a replacement
+		"edge.edge-a-2": slotEntry{Address: "2.2.2.2", Node: "edge-a-2", VacantSince: &now}.String(),
+		"edge.legacy":   "4.4.4.4",
+	}, cm.Data)
+
+	// the slot still has an address for the replacement until the vacancy timeout
+	address, err := slotAddress(context.Background(), client, "default", "edge.edge-a-2")
+	require.NoError(t, err)
+	assert.Equal(t, "2.2.2.2", address)
+	address, err = slotAddress(context.Background(), client, "default", "edge.legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "4.4.4.4", address)
+}
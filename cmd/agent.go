@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/claim"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/events"
+	"github.com/doitintl/kubeip/internal/karpenter"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/doitintl/kubeip/internal/metrics"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/probe"
+	"github.com/doitintl/kubeip/internal/schedule"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+// agent is the agent of a node: the state its run phases share, set up as they go
+type agent struct {
+	log       *logrus.Entry
+	clientset kubernetes.Interface
+	explorer  nd.Explorer
+	recorder  nd.StatusRecorder
+	// clusterCfg is the configuration of the cluster, cfg the configuration rendered for the node
+	clusterCfg *config.Config
+	cfg        *config.Config
+	n          *types.Node
+	windows    schedule.Windows
+	assigner   address.Assigner
+	syncer     *integrations
+	watcher    events.Watcher
+}
+
+func newAgent(log *logrus.Entry, clientset kubernetes.Interface, cfg *config.Config) *agent {
+	return &agent{
+		log:        log,
+		clientset:  clientset,
+		explorer:   newExplorer(clientset, cfg),
+		recorder:   nd.NewStatusRecorder(clientset),
+		clusterCfg: cfg,
+		cfg:        cfg,
+	}
+}
+
+func run(c context.Context, log *logrus.Entry, cfg *config.Config) error {
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	// add debug mode to context
+	if cfg.DevelopMode {
+		ctx = context.WithValue(ctx, developModeKey, true)
+	}
+	if cfg.ClusterName != "" {
+		log = log.WithField("cluster", cfg.ClusterName)
+	}
+	log.WithFields(buildInfo()).WithField("develop-mode", cfg.DevelopMode).Infof("kubeip agent started")
+
+	clientset, err := newKubernetesClient(log, cfg)
+	if err != nil {
+		return err
+	}
+	a := newAgent(log, clientset, cfg)
+	discoveryStart := time.Now()
+	if assign, err := a.discover(ctx); err != nil || !assign {
+		return err
+	}
+
+	// the agents started at once by a DaemonSet rollout spread their cloud provider calls
+	if err = waitStartupJitter(ctx, log, cfg.StartupJitter); err != nil {
+		log.Infof("shutting down kubeip agent")
+		return nil
+	}
+	if ctx, err = a.setup(ctx, discoveryStart); err != nil {
+		return err
+	}
+	if err = a.waitForNode(ctx); err != nil {
+		return err
+	}
+
+	assignedAddress, err := a.acquire(ctx)
+	if err != nil {
+		if address.IsPermanent(err) {
+			return blockAssignment(ctx, log, a.syncer, a.n, err)
+		}
+		a.syncer.failed(ctx, log, a.n, err)
+		return errors.Wrap(err, "assigning static public IP address")
+	}
+	if err = a.verify(ctx, assignedAddress); err != nil {
+		return err
+	}
+	if err = a.prestage(ctx); err != nil {
+		return err
+	}
+	if err = a.removeTaint(ctx, assignedAddress); err != nil {
+		return err
+	}
+
+	// pause the agent to prevent it from exiting immediately after assigning the static public IP address
+	// wait for the context to be done: SIGTERM, SIGINT; reassign when an external actor changes the address meanwhile
+	assignedAddress = a.watch(ctx, assignedAddress)
+	log.Infof("shutting down kubeip agent")
+	return a.releaseOnExit(ctx, assignedAddress)
+}
+
+// idle waits for shutdown without assigning a static public IP address: exiting would make the DaemonSet restart the agent
+func (a *agent) idle(ctx context.Context) {
+	<-ctx.Done()
+	a.log.Infof("shutting down kubeip agent")
+}
+
+// discover gets the node and renders the configuration of the node; returns false once the agent idled until shutdown
+// on a node not to assign: unsupported cloud provider, not selected, ignored or outside the canary
+func (a *agent) discover(ctx context.Context) (bool, error) {
+	n, err := a.explorer.GetNode(ctx, a.cfg.NodeName)
+	var unsupported *nd.UnsupportedProviderError
+	if errors.As(err, &unsupported) {
+		return false, unsupportedProvider(ctx, a.log, a.recorder, unsupported, a.cfg)
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "getting node")
+	}
+	a.log.WithField("node", n).Debug("node discovery done")
+	a.n = n
+	// the pre-staging renders the filters of the cluster for each NodeClaim rather than for the agent node
+	if a.cfg, err = providerConfig(a.log, n, a.clusterCfg); err != nil {
+		return false, err
+	}
+
+	// idle on nodes not matching the node selector
+	selected, err := nd.MatchesSelector(n, a.cfg.NodeSelector)
+	if err != nil {
+		return false, errors.Wrap(err, "matching node selector")
+	}
+	if !selected {
+		a.log.WithFields(logrus.Fields{
+			"node":          n.Name,
+			"node-selector": a.cfg.NodeSelector,
+		}).Info("node does not match node selector, skipping static public IP address assignment")
+		a.idle(ctx)
+		return false, nil
+	}
+
+	// skip nodes opted out with the ignore annotation before any cloud provider or integration setup, releasing the held
+	// static public IP address if requested
+	if nd.IsIgnored(n) {
+		a.log.WithField("node", n.Name).Infof("node has %s annotation, skipping static public IP address assignment", nd.IgnoreAnnotation)
+		if a.cfg.ReleaseIgnored {
+			releaseIgnored(ctx, a.log, a.clientset, newAssigner, n, a.cfg)
+		}
+		a.idle(ctx)
+		return false, nil
+	}
+
+	// nodes outside the canary run in dry-run: log the assignment that would happen and idle
+	if canary, err := a.inCanary(ctx); err != nil || !canary {
+		return false, err
+	}
+
+	a.windows, err = schedule.ParseWindows(a.cfg.MaintenanceWindows)
+	return err == nil, errors.Wrap(err, "parsing maintenance windows")
+}
+
+// inCanary reports whether the node is in the canary; a node outside logs the candidate address and idles until
+// shutdown
+func (a *agent) inCanary(ctx context.Context) (bool, error) {
+	canary, err := nd.InCanary(a.n, a.cfg.CanarySelector, a.cfg.CanaryPercent)
+	if err != nil {
+		return false, errors.Wrap(err, "matching canary")
+	}
+	if canary {
+		return true, nil
+	}
+	assigner, err := newAssigner(ctx, a.log, a.n, a.cfg)
+	if err != nil {
+		return false, errors.Wrap(err, "initializing assigner")
+	}
+	logCandidate(ctx, a.log.WithFields(logrus.Fields{
+		"canary-selector": a.cfg.CanarySelector,
+		"canary-percent":  a.cfg.CanaryPercent,
+	}), assigner, a.n, a.cfg)
+	a.idle(ctx)
+	return false, nil
+}
+
+// setup initializes the assigner, checking its cloud permissions, the address pool and claim of the node, the
+// integrations and the change detection; returns the context labelling the phases of the operations on the node
+func (a *agent) setup(ctx context.Context, discoveryStart time.Time) (context.Context, error) {
+	n, cfg := a.n, a.cfg
+	var err error
+	if a.assigner, err = newAssigner(ctx, a.log, n, cfg); err != nil {
+		return ctx, errors.Wrap(err, "initializing assigner")
+	}
+	if err = checkPermissions(ctx, a.assigner, n, cfg); err != nil {
+		return ctx, errors.Wrap(err, "checking cloud permissions")
+	}
+	if n.Tenant, err = poolTenant(cfg.PoolTenants, n.Pool); err != nil {
+		return ctx, err
+	}
+	if n.AddressPool, err = renderNodeTemplate(n, cfg.AddressPool); err != nil {
+		return ctx, errors.Wrap(err, "rendering address pool name")
+	}
+	// the phases of the operations on the node are labelled with it from now on
+	ctx = metrics.WithPhaseLabels(ctx, string(n.Cloud), n.AddressPool, n.Pool, n.Name)
+	metrics.ObservePhase(ctx, metrics.PhaseDiscovery, discoveryStart)
+	if cfg.Claims {
+		dynamicClient, err := newDynamicClient(a.log, cfg)
+		if err != nil {
+			return ctx, err
+		}
+		if err = applyClaim(ctx, a.log, claim.NewFinder(dynamicClient), n); err != nil {
+			return ctx, errors.Wrap(err, "finding address claim")
+		}
+	}
+
+	if a.syncer, err = newIntegrations(ctx, a.log, cfg, a.clientset); err != nil {
+		return ctx, err
+	}
+	a.syncer.pending(ctx, a.log, n)
+	checkQuota(ctx, a.log, a.assigner, n, cfg, a.syncer)
+
+	// a single agent consumes the notifications, holding its own lease, and hands the changes over to the agents
+	elector := lease.NewElector(a.clientset, a.log, eventsLeaseName, cfg.LeaseNamespace, n.Name, time.Duration(cfg.LeaseDuration)*time.Second)
+	if a.watcher, err = events.NewWatcher(ctx, a.log, cfg, events.NewNodeRelay(a.clientset, n.Name), elector); err != nil {
+		return ctx, errors.Wrap(err, "initializing change detection")
+	}
+	return ctx, nil
+}
+
+// waitForNode waits for the node to be bootstrapped and, if it already holds a public IP address, for a maintenance
+// window: swapping the address causes a connectivity blip
+func (a *agent) waitForNode(ctx context.Context) error {
+	requirements, err := nd.ParseRequirements(a.cfg.WaitForConditions, a.cfg.WaitForLabels)
+	if err != nil {
+		return errors.Wrap(err, "parsing node bootstrap requirements")
+	}
+	bootstrapWaiter := nd.NewBootstrapWaiter(a.clientset, a.cfg.NodeResyncInterval, func(unmet []nd.Requirement) {
+		a.log.WithField("unmet", unmet).Debug("node not bootstrapped yet")
+	})
+	if err = waitForNodeBootstrap(ctx, a.log, bootstrapWaiter, a.n, requirements, a.cfg.WaitForNodeTimeout); err != nil {
+		return errors.Wrap(err, "waiting for node bootstrap")
+	}
+	if err = waitForSwapWindow(ctx, a.log, a.windows, a.assigner, a.n, heldAddress(a.n), a.cfg); err != nil {
+		return errors.Wrap(err, "waiting for maintenance window")
+	}
+	return nil
+}
+
+// acquire assigns the node the address of the node it replaces, the address of its slot or an address of the pool, and
+// records it; a failed assignment is recorded in the status of the node and returned as is
+func (a *agent) acquire(ctx context.Context) (string, error) {
+	n := a.n
+	// a replacement node takes over the address of the node it replaces, otherwise an address of the pool is assigned
+	assignedAddress := handOff(ctx, a.log, a.clientset, a.assigner, n, a.cfg, handoffPollInterval)
+	if assignedAddress == "" {
+		// a node replacing another of its slot takes the address back if still free
+		assignedAddress = reclaimAddress(ctx, a.log, a.clientset, a.assigner, n, a.cfg)
+	}
+	if assignedAddress == "" {
+		var err error
+		if assignedAddress, err = assignAddress(ctx, a.log, a.clientset, a.assigner, n, a.cfg, a.syncer); err != nil {
+			recordStatus(ctx, a.log, a.recorder, withOperation(a.assigner, n, &types.AssignmentStatus{Node: n.Name, Pool: n.Pool, LastError: err.Error()}))
+			return "", err
+		}
+	}
+	if assignedAddress == "" {
+		// the node already holds a static public IP address the cloud provider does not report: keep the recorded status
+		assignedAddress = recordedAddress(ctx, a.log, a.recorder, n)
+	} else {
+		recordAssignedStatus(ctx, a.log, a.recorder, n, withOperation(a.assigner, n, &types.AssignmentStatus{Node: n.Name, Address: assignedAddress, Pool: n.Pool}))
+	}
+	recordSlotAddress(ctx, a.log, a.clientset, n, assignedAddress, a.cfg)
+	return assignedAddress, nil
+}
+
+// verify verifies the egress of the node through the assigned address if configured, then publishes the assignment and
+// keeps the readiness gates of the pods of the node
+func (a *agent) verify(ctx context.Context, assignedAddress string) error {
+	n := a.n
+	if a.cfg.VerifyURL != "" {
+		prober := probe.NewProber(a.cfg.VerifyURL, verifyRequestTimeout)
+		if err := verifyEgress(ctx, a.log, prober, n, assignedAddress, a.cfg.VerifyTimeout); err != nil {
+			recordStatus(ctx, a.log, a.recorder, &types.AssignmentStatus{Node: n.Name, Address: assignedAddress, Pool: n.Pool, LastError: err.Error()})
+			a.syncer.failed(ctx, a.log, n, err)
+			return errors.Wrap(err, "verifying egress of static public IP address")
+		}
+	}
+	a.syncer.assigned(ctx, a.log, n, assignedAddress)
+	go a.syncer.watchReadinessGates(ctx, a.log, n)
+	return nil
+}
+
+// prestage pre-stages the addresses of the Karpenter NodeClaims of the node pools, if configured
+func (a *agent) prestage(ctx context.Context) error {
+	if len(a.cfg.KarpenterNodePools) == 0 {
+		return nil
+	}
+	dynamicClient, err := newDynamicClient(a.log, a.cfg)
+	if err != nil {
+		return err
+	}
+	p := &prestager{
+		lock:     newPrestageLock(a.clientset, a.n, a.cfg),
+		stager:   karpenter.NewStager(dynamicClient),
+		assigner: a.assigner,
+		cloud:    a.n.Cloud,
+		cfg:      a.clusterCfg,
+	}
+	if a.cfg.Claims {
+		p.finder = claim.NewFinder(dynamicClient)
+	}
+	go prestageNodeClaims(ctx, a.log, a.clientset, p, a.n)
+	return nil
+}
+
+// removeTaint removes the taint key of the node once it reports the assigned address, if configured; the address is
+// released if the taint cannot be removed
+func (a *agent) removeTaint(ctx context.Context, assignedAddress string) error {
+	if a.cfg.TaintKey == "" {
+		return nil
+	}
+	n := a.n
+	if err := waitForAddressToBeReported(ctx, a.log, a.explorer, a.assigner, n, assignedAddress, a.cfg); err != nil {
+		return errors.Wrap(err, "waiting for node to report assigned address")
+	}
+
+	logger := a.log.WithField("taint-key", a.cfg.TaintKey)
+	didRemoveTaint, err := nd.NewTainter(a.clientset).RemoveTaintKey(ctx, n, a.cfg.TaintKey)
+	if err != nil {
+		logger.Error("removing taint key failed, releasing static public IP address")
+		if releaseErr := releaseIP(ctx, a.assigner, n); releaseErr != nil {
+			a.log.WithError(releaseErr).Error("releasing static public IP address after taint key removal failed")
+		} else {
+			recordStatus(ctx, a.log, a.recorder, &types.AssignmentStatus{Node: n.Name, Pool: n.Pool, LastError: err.Error()})
+			a.syncer.released(ctx, a.log, n, assignedAddress)
+			a.syncer.failed(ctx, a.log, n, err)
+		}
+		return errors.Wrap(err, "removing node taint key")
+	}
+
+	if didRemoveTaint {
+		logger.Info("taint key removed successfully")
+	} else {
+		logger.Warning("taint key not present on node, skipped removal")
+	}
+	return nil
+}
+
+// watch reassigns the address when an external actor changes it, and acts on the admin, watchdog and pre-drain
+// requests, until shutdown; returns the address held on shutdown
+func (a *agent) watch(ctx context.Context, assignedAddress string) string {
+	actions := serveAdmin(ctx, a.log, a.cfg, a.clientset, a.n)
+	if a.cfg.WatchdogInterval > 0 && a.cfg.WatchdogURL != "" {
+		prober := probe.NewProber(a.cfg.WatchdogURL, verifyRequestTimeout)
+		actions = watchEgress(ctx, a.log, prober, a.recorder, a.n, actions, a.cfg.WatchdogInterval, a.cfg.WatchdogReconcile)
+	}
+	if a.cfg.ReleaseBeforeDrain {
+		actions = watchPreDrain(ctx, a.log, nd.NewDrainDetector(a.clientset, a.cfg.DrainTaints), a.n, actions, preDrainPollInterval)
+	}
+	return watchAddressChanges(ctx, a.log, a.watcher, actions, a.n, assignedAddress, func(current string) string {
+		return a.reassign(ctx, current)
+	}, func(current string) string {
+		if err := releaseAddress(ctx, a.log, a.assigner, a.recorder, a.syncer, a.n, current); err != nil {
+			a.log.WithError(err).Error("releasing static public IP address failed")
+			return current
+		}
+		return ""
+	})
+}
+
+// reassign assigns the node an address again, in a maintenance window if it still holds one; returns the address held
+// afterwards, the current one if the reassignment failed
+func (a *agent) reassign(ctx context.Context, current string) string {
+	n := a.n
+	held := current
+	if refreshed, err := a.explorer.GetNode(ctx, n.Name); err != nil {
+		a.log.WithError(err).Warn("failed to refresh node, assuming the address is still held")
+	} else {
+		held = heldAddress(refreshed)
+	}
+	if err := waitForSwapWindow(ctx, a.log, a.windows, a.assigner, n, held, a.cfg); err != nil {
+		a.log.WithError(err).Warn("reassigning static public IP address cancelled")
+		return current
+	}
+	reassigned, err := assignAddress(ctx, a.log, a.clientset, a.assigner, n, a.cfg, a.syncer)
+	if err != nil {
+		a.log.WithError(err).Error("reassigning static public IP address failed")
+		recordStatus(ctx, a.log, a.recorder, withOperation(a.assigner, n, &types.AssignmentStatus{Node: n.Name, Pool: n.Pool, LastError: err.Error()}))
+		a.syncer.failed(ctx, a.log, n, err)
+		return current
+	}
+	// an empty address: the node still holds its static public IP address
+	if reassigned == "" || reassigned == current {
+		return current
+	}
+	recordStatus(ctx, a.log, a.recorder, withOperation(a.assigner, n, &types.AssignmentStatus{Node: n.Name, Address: reassigned, Pool: n.Pool}))
+	if current != "" {
+		a.syncer.released(ctx, a.log, n, current)
+	}
+	a.syncer.assigned(ctx, a.log, n, reassigned)
+	return reassigned
+}
+
+// releaseOnExit releases the static public IP address on exit, if configured
+func (a *agent) releaseOnExit(ctx context.Context, assignedAddress string) error {
+	if !a.cfg.ReleaseOnExit {
+		return nil
+	}
+	a.log.Infof("releasing static public IP address")
+	if err := releaseAddress(ctx, a.log, a.assigner, a.recorder, a.syncer, a.n, assignedAddress); err != nil {
+		// released on admin request meanwhile
+		if errors.Is(err, address.ErrNoStaticIPAssigned) {
+			a.log.Infof("no static public IP address assigned, nothing to release")
+			return nil
+		}
+		return err
+	}
+	a.log.Infof("static public IP address released")
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+// exit codes of the one-shot assign command; the run command exits with exitCodeUnsupportedProvider as well
+const (
+	exitCodeSetupFailed         = 1 // kubernetes client, node discovery or cloud provider initialization failed
+	exitCodeAssignFailed        = 2 // static public IP address could not be assigned
+	exitCodeUnsupportedProvider = 3 // the provider ID of the node is not one of a supported cloud provider
+)
+
+// assignerFactory returns the assigner of the node: newAssigner, or a fake in tests
+type assignerFactory func(ctx context.Context, log *logrus.Entry, n *types.Node, cfg *config.Config) (address.Assigner, error)
+
+// assignOnce assigns a static public IP address to the node (with retry) and returns the assigned address; the address is
+// empty if the node already holds a static public IP address the cloud provider does not report
+func assignOnce(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, newAssigner assignerFactory, cfg *config.Config) (string, error) {
+	explorer := newExplorer(client, cfg)
+	n, err := explorer.GetNode(ctx, cfg.NodeName)
+	var unsupported *nd.UnsupportedProviderError
+	if errors.As(err, &unsupported) {
+		return "", cli.Exit(err, exitCodeUnsupportedProvider)
+	}
+	if err != nil {
+		return "", cli.Exit(errors.Wrap(err, "getting node"), exitCodeSetupFailed)
+	}
+	log.WithField("node", n).Debug("node discovery done")
+	if cfg, err = providerConfig(log, n, cfg); err != nil {
+		return "", cli.Exit(err, exitCodeSetupFailed)
+	}
+
+	assigner, err := newAssigner(ctx, log, n, cfg)
+	if err != nil {
+		return "", cli.Exit(errors.Wrap(err, "initializing assigner"), exitCodeSetupFailed)
+	}
+	if err = checkPermissions(ctx, assigner, n, cfg); err != nil {
+		return "", cli.Exit(errors.Wrap(err, "checking cloud permissions"), exitCodeSetupFailed)
+	}
+
+	recorder := nd.NewStatusRecorder(client)
+	assignedAddress, err := assignAddress(ctx, log, client, assigner, n, cfg, nil)
+	if err != nil {
+		recordStatus(ctx, log, recorder, withOperation(assigner, n, &types.AssignmentStatus{Node: n.Name, Pool: n.Pool, LastError: err.Error()}))
+		return "", cli.Exit(errors.Wrap(err, "assigning static public IP address"), exitCodeAssignFailed)
+	}
+	// the node already holds a static public IP address: keep the recorded status
+	if assignedAddress == "" {
+		assignedAddress = recordedAddress(ctx, log, recorder, n)
+		log.WithFields(logrus.Fields{
+			"node":    n.Name,
+			"address": assignedAddress,
+		}).Info("static public IP address already assigned")
+		return assignedAddress, nil
+	}
+	recordStatus(ctx, log, recorder, withOperation(assigner, n, &types.AssignmentStatus{Node: n.Name, Address: assignedAddress, Pool: n.Pool}))
+	log.WithFields(logrus.Fields{
+		"node":    n.Name,
+		"address": assignedAddress,
+	}).Info("static public IP address assigned")
+	return assignedAddress, nil
+}
+
+func assignCmd(c *cli.Context) error {
+	ctx := signals.SetupSignalHandler()
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	if err := config.ApplyProfile(c); err != nil {
+		log.WithError(err).Error("invalid kubeip agent configuration profile")
+		return cli.Exit(err, exitCodeSetupFailed)
+	}
+	cfg := config.NewConfig(c)
+	if err := cfg.Interpolate(); err != nil {
+		log.WithError(err).Error("invalid kubeip agent configuration")
+		return cli.Exit(err, exitCodeSetupFailed)
+	}
+
+	client, err := newKubernetesClient(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing kubernetes client")
+		return cli.Exit(err, exitCodeSetupFailed)
+	}
+
+	assignedAddress, err := assignOnce(ctx, log, client, newAssigner, cfg)
+	if err != nil {
+		log.WithError(err).Error("error assigning static public IP address")
+		return err
+	}
+
+	// print the assigned address to stdout, so it can be consumed by scripts
+	if assignedAddress == "" {
+		fmt.Fprintln(os.Stderr, "static public IP address already assigned")
+		return nil
+	}
+	fmt.Println(assignedAddress)
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	mocks "github.com/doitintl/kubeip/mocks/address"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	tmock "github.com/stretchr/testify/mock"
+	"github.com/urfave/cli/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testGCPNode(annotations map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+			Labels: map[string]string{
+				"topology.kubernetes.io/region": "us-central1",
+				"topology.kubernetes.io/zone":   "us-central1-a",
+				"cloud.google.com/gke-nodepool": "test-pool",
+			},
+			Annotations: annotations,
+		},
+		Spec: v1.NodeSpec{ProviderID: "gce://test-project/us-central1-a/test-instance"},
+	}
+}
+
+func Test_assignOnce(t *testing.T) {
+	cfg := &config.Config{
+		NodeName:      "test-node",
+		RetryAttempts: 0,
+		RetryInterval: time.Millisecond,
+		LeaseDuration: 1,
+	}
+	tests := []struct {
+		name         string
+		nodes        []*v1.Node
+		cfg          *config.Config
+		assignerFn   func(t *testing.T) address.Assigner
+		assignerErr  error
+		want         string
+		wantStatus   string
+		wantExitCode int
+	}{
+		{
+			name:         "node not found",
+			cfg:          &config.Config{NodeName: "missing-node"},
+			wantExitCode: exitCodeSetupFailed,
+		},
+		{
+			name: "unsupported cloud provider",
+			nodes: []*v1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+					Spec:       v1.NodeSpec{ProviderID: "kind://docker/kind/test-node"},
+				},
+			},
+			cfg:          &config.Config{NodeName: "test-node"},
+			wantExitCode: exitCodeUnsupportedProvider,
+		},
+		{
+			name:         "assigner initialization failed",
+			nodes:        []*v1.Node{testGCPNode(nil)},
+			cfg:          cfg,
+			assignerErr:  errors.New("missing credentials"),
+			wantExitCode: exitCodeSetupFailed,
+		},
+		{
+			name:  "no static public IP address available",
+			nodes: []*v1.Node{testGCPNode(nil)},
+			cfg:   cfg,
+			assignerFn: func(t *testing.T) address.Assigner {
+				mock := mocks.NewAssigner(t)
+				mock.EXPECT().Assign(tmock.Anything, "test-instance", "us-central1-a", []string(nil), "").Return("", errors.New("no available addresses"))
+				return mock
+			},
+			wantExitCode: exitCodeAssignFailed,
+		},
+		{
+			name:  "static public IP address assigned",
+			nodes: []*v1.Node{testGCPNode(nil)},
+			cfg:   cfg,
+			assignerFn: func(t *testing.T) address.Assigner {
+				mock := mocks.NewAssigner(t)
+				mock.EXPECT().Assign(tmock.Anything, "test-instance", "us-central1-a", []string(nil), "").Return("1.1.1.1", nil)
+				return mock
+			},
+			want:       "1.1.1.1",
+			wantStatus: "1.1.1.1",
+		},
+		{
+			name: "static public IP address already assigned",
+			nodes: []*v1.Node{testGCPNode(map[string]string{
+				node.AddressAnnotation:            "2.2.2.2",
+				node.LastTransitionTimeAnnotation: "2024-03-16T02:00:00Z",
+			})},
+			cfg: cfg,
+			assignerFn: func(t *testing.T) address.Assigner {
+				mock := mocks.NewAssigner(t)
+				mock.EXPECT().Assign(tmock.Anything, "test-instance", "us-central1-a", []string(nil), "").Return("", nil)
+				return mock
+			},
+			want:       "2.2.2.2",
+			wantStatus: "2.2.2.2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := prepareLogger("debug", false)
+			client := fake.NewSimpleClientset()
+			for _, n := range tt.nodes {
+				if _, err := client.CoreV1().Nodes().Create(context.Background(), n, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			factory := func(_ context.Context, _ *logrus.Entry, _ *types.Node, _ *config.Config) (address.Assigner, error) {
+				if tt.assignerErr != nil {
+					return nil, tt.assignerErr
+				}
+				return tt.assignerFn(t), nil
+			}
+			got, err := assignOnce(context.Background(), log, client, factory, tt.cfg)
+			if tt.wantExitCode != 0 {
+				var exitErr cli.ExitCoder
+				if !errors.As(err, &exitErr) {
+					t.Fatalf("assignOnce() error = %v, want cli.ExitCoder", err)
+				}
+				if exitErr.ExitCode() != tt.wantExitCode {
+					t.Errorf("assignOnce() exit code = %d, want %d", exitErr.ExitCode(), tt.wantExitCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("assignOnce() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("assignOnce() = %v, want %v", got, tt.want)
+			}
+			status, err := node.NewStatusRecorder(client).GetStatus(context.Background(), "test-node")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Address != tt.wantStatus {
+				t.Errorf("assignOnce() recorded address = %v, want %v", status.Address, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+const pluginName = "kubectl-kubeip"
+
+// isKubectlPlugin checks if the binary is invoked as kubectl plugin (kubectl-kubeip or kubectl-kubeip.exe)
+func isKubectlPlugin(arg0 string) bool {
+	return strings.TrimSuffix(filepath.Base(arg0), filepath.Ext(arg0)) == pluginName
+}
+
+// agentCommands returns commands of the kubeip-agent binary
+func agentCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:   "run",
+			Usage:  "run agent",
+			Flags:  append(runFlags(), commonFlags()...),
+			Action: runCmd,
+		},
+		{
+			Name:   "controller",
+			Usage:  "run controller assigning static public IP addresses to the nodes of several clusters sharing the addresses of a project or account",
+			Flags:  concatFlags(controllerFlags(), assignmentFlags(), metricsFlags(), healthFlags(), commonFlags()),
+			Action: controllerCmd,
+		},
+		{
+			Name:   "assign",
+			Usage:  "assign a static public IP address to a node once and exit",
+			Flags:  append(assignFlags(), commonFlags()...),
+			Action: assignCmd,
+		},
+		{
+			Name:   "status",
+			Usage:  "show the static public IP address assignment status",
+			Flags:  append(statusFlags(), commonFlags()...),
+			Action: statusCmd,
+		},
+		{
+			Name:   "top",
+			Usage:  "show a live overview of nodes, assigned addresses, pool utilization and recent errors",
+			Flags:  append(topFlags(), commonFlags()...),
+			Action: topCmd,
+		},
+		{
+			Name:   "release",
+			Usage:  "release the static public IP address from a node and exit",
+			Flags:  append(releaseFlags(), commonFlags()...),
+			Action: releaseCmd,
+		},
+		{
+			Name:   "e2e",
+			Usage:  "run the static public IP address lifecycle (assign, drift repair, release, GC) on a node against a cloud provider or its emulator and exit",
+			Flags:  append(e2eFlags(), commonFlags()...),
+			Action: e2eCmd,
+		},
+		{
+			Name:   "loadtest",
+			Usage:  "simulate virtual nodes joining and leaving against the develop mode pool and report the assignment throughput and latencies",
+			Flags:  append(loadTestFlags(), commonFlags()...),
+			Action: loadTestCmd,
+		},
+		{
+			Name:   "grafana-dashboard",
+			Usage:  "print the Grafana dashboard (JSON) of the agent metrics and exit",
+			Flags:  commonFlags(),
+			Action: dashboardCmd,
+		},
+	}
+}
+
+// pluginCommands returns commands of the kubectl-kubeip plugin
+func pluginCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:      "status",
+			Usage:     "show the static public IP address assignment status of a node",
+			ArgsUsage: "[node]",
+			Flags:     append(statusFlags(), commonFlags()...),
+			Action:    withNodeArg(statusCmd),
+		},
+		{
+			Name:   "list",
+			Usage:  "list the static public IP address assignment status of all nodes",
+			Flags:  append(listFlags(), commonFlags()...),
+			Action: listCmd,
+		},
+		{
+			Name:   "top",
+			Usage:  "show a live overview of nodes, assigned addresses, pool utilization and recent errors",
+			Flags:  append(topFlags(), commonFlags()...),
+			Action: topCmd,
+		},
+		{
+			Name:      "release",
+			Usage:     "release the static public IP address from a node",
+			ArgsUsage: "[node]",
+			Flags:     append(releaseFlags(), commonFlags()...),
+			Action:    withNodeArg(releaseCmd),
+		},
+		{
+			Name:      "assign",
+			Usage:     "assign a static public IP address to a node",
+			ArgsUsage: "[node]",
+			Flags:     append(assignFlags(), commonFlags()...),
+			Action:    withNodeArg(assignCmd),
+		},
+	}
+}
+
+// withNodeArg allows passing the node name as positional argument: kubectl kubeip status <node>;
+// aliases are only synced when parsing, so the name read by the config (node-name) is set
+func withNodeArg(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if c.Args().Present() && !c.IsSet("node-name") {
+			if err := c.Set("node-name", c.Args().First()); err != nil {
+				return err //nolint:wrapcheck
+			}
+		}
+		return action(c)
+	}
+}
+
+func listCmd(c *cli.Context) error {
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	cfg := config.NewConfig(c)
+
+	client, err := newKubernetesClient(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing kubernetes client")
+		return err
+	}
+
+	if err = printStatus(c.Context, os.Stdout, client, "", true, false, c.String("output")); err != nil {
+		log.WithError(err).Error("error listing assignment status")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+func Test_isKubectlPlugin(t *testing.T) {
+	tests := []struct {
+		arg0 string
+		want bool
+	}{
+		{arg0: "/usr/local/bin/kubectl-kubeip", want: true},
+		{arg0: "kubectl-kubeip.exe", want: true},
+		{arg0: "/kubeip-agent", want: false},
+		{arg0: "kubectl", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg0, func(t *testing.T) {
+			if got := isKubectlPlugin(tt.arg0); got != tt.want {
+				t.Errorf("isKubectlPlugin(%s) = %v, want %v", tt.arg0, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_withNodeArg(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "positional node argument", args: []string{"plugin", "status", "node-1"}, want: "node-1"},
+		{name: "node flag wins over positional argument", args: []string{"plugin", "status", "--node", "node-2", "node-1"}, want: "node-2"},
+		{name: "node-name flag wins over positional argument", args: []string{"plugin", "status", "--node-name", "node-2", "node-1"}, want: "node-2"},
+		{name: "no node", args: []string{"plugin", "status"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			app := &cli.App{
+				Commands: []*cli.Command{
+					{
+						Name:  "status",
+						Flags: statusFlags(),
+						Action: withNodeArg(func(c *cli.Context) error {
+							got = config.NewConfig(c).NodeName
+							return nil
+						}),
+					},
+				},
+			}
+			if err := app.Run(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("withNodeArg() node = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+// controllerLeaseName is the lease electing the replica of the controller managing the clusters
+const controllerLeaseName = "kubeip-controller"
+
+// managedCluster is a cluster whose nodes the controller assigns the static public IP addresses of
+type managedCluster struct {
+	name   string
+	client kubernetes.Interface
+	// cfg is the configuration of the controller for the cluster: its kubeconfig, its name and its scoping filters
+	cfg *config.Config
+}
+
+// clusterConfig returns the configuration of the controller for the cluster: the scoping filters of the cluster are
+// added to the filters of the controller, so clusters sharing the addresses of a project or account never pick the same
+func clusterConfig(cfg *config.Config, cluster config.Cluster) *config.Config {
+	clusterCfg := *cfg
+	clusterCfg.KubeConfigPath, clusterCfg.KubeContext = cluster.KubeConfig, cluster.Context
+	// the API server and the token of the cluster of the controller do not reach the managed cluster
+	clusterCfg.KubeAPIServer, clusterCfg.KubeTokenFile = "", ""
+	clusterCfg.ClusterName = cluster.Name
+	clusterCfg.Filter = append(append([]string{}, cfg.Filter...), cluster.Filters...)
+	return &clusterCfg
+}
+
+// newManagedClusters returns the clusters managed by the controller with their Kubernetes clients
+func newManagedClusters(log *logrus.Entry, cfg *config.Config) ([]*managedCluster, error) {
+	clusters, err := cfg.ManagedClusters()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if len(clusters) == 0 {
+		return nil, errors.New("the controller requires at least one --cluster")
+	}
+	managed := make([]*managedCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		clusterCfg := clusterConfig(cfg, cluster)
+		client, err := newKubernetesClient(log.WithField("cluster", cluster.Name), clusterCfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cluster %s", cluster.Name)
+		}
+		managed = append(managed, &managedCluster{name: cluster.Name, client: client, cfg: clusterCfg})
+	}
+	return managed, nil
+}
+
+// runController assigns the static public IP addresses of the nodes of the managed clusters until the context is done;
+// run by the replica of the controller holding the controller lease in its own cluster
+func runController(ctx context.Context, log *logrus.Entry, clusters []*managedCluster, newAssigner assignerFactory) {
+	var wg sync.WaitGroup
+	for _, cluster := range clusters {
+		wg.Add(1)
+		go func(cluster *managedCluster) {
+			defer wg.Done()
+			logger := log.WithField("cluster", cluster.name)
+			if err := cluster.manage(ctx, logger, newAssigner); err != nil {
+				logger.WithError(err).Error("managing cluster failed")
+			}
+		}(cluster)
+	}
+	wg.Wait()
+}
+
+// manage assigns a static public IP address to the nodes of the cluster matching the node selector and holding none, as
+// they join or change, until the context is done; each node is assigned on its own, as its agent would, holding the
+// lock of the cluster, and a failed node waits for the retry interval before it is assigned again
+func (m *managedCluster) manage(ctx context.Context, log *logrus.Entry, newAssigner assignerFactory) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(m.client, m.cfg.NodeResyncInterval, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+		o.LabelSelector = m.cfg.NodeSelector
+	}))
+	informer := factory.Core().V1().Nodes()
+	// the changes are coalesced: the handler only signals, the nodes are read from the cache
+	changed := make(chan struct{}, 1)
+	signal := func(interface{}) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	_, err := informer.Informer().AddEventHandler(nodeEventHandler(signal))
+	if err != nil {
+		return errors.Wrap(err, "failed to watch nodes")
+	}
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	var (
+		mutex     sync.Mutex
+		wg        sync.WaitGroup
+		assigning = map[string]bool{}
+	)
+	defer wg.Wait()
+	for {
+		select {
+		case <-changed:
+			nodes, err := informer.Lister().List(labels.Everything())
+			if err != nil {
+				return errors.Wrap(err, "failed to list nodes")
+			}
+			for _, name := range pendingNodes(nodes) {
+				mutex.Lock()
+				busy := assigning[name]
+				assigning[name] = true
+				mutex.Unlock()
+				if busy {
+					continue
+				}
+				wg.Add(1)
+				go func(name string) {
+					defer wg.Done()
+					start := time.Now()
+					assigned := m.assign(ctx, log.WithField("node", name), newAssigner, name)
+					if !assigned {
+						pace(ctx, m.cfg.RetryInterval, start)
+					}
+					mutex.Lock()
+					delete(assigning, name)
+					mutex.Unlock()
+					// the failed node is assigned again
+					if !assigned {
+						signal(nil)
+					}
+				}(name)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// nodeEventHandler signals the additions of assignable nodes and the updates that may make a node pending
+func nodeEventHandler(signal func(interface{})) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if n, ok := obj.(*v1.Node); ok && assignableNode(n) {
+				signal(obj)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, oldOK := oldObj.(*v1.Node)
+			newNode, newOK := newObj.(*v1.Node)
+			if oldOK && newOK && nodeChanged(oldNode, newNode) {
+				signal(newObj)
+			}
+		},
+	}
+}
+
+// assignableNode reports whether the node can be assigned: Ready and registered with its cloud provider instance
+func assignableNode(n *v1.Node) bool {
+	if n.Spec.ProviderID == "" {
+		return false
+	}
+	for _, condition := range n.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeChanged reports whether the update may make the node pending: it turns Ready with a provider ID, or the address
+// or opt-out annotations change; the updates of the status and the heartbeats are skipped, the resyncs (same resource
+// version) kept as the periodic check of the nodes
+func nodeChanged(oldNode, newNode *v1.Node) bool {
+	if !assignableNode(newNode) {
+		return false
+	}
+	return oldNode.ResourceVersion == newNode.ResourceVersion || !assignableNode(oldNode) ||
+		oldNode.Annotations[nd.AddressAnnotation] != newNode.Annotations[nd.AddressAnnotation] ||
+		oldNode.Annotations[nd.IgnoreAnnotation] != newNode.Annotations[nd.IgnoreAnnotation]
+}
+
+// pendingNodes returns the names of the assignable nodes without a recorded static public IP address, the opted out
+// nodes excepted
+func pendingNodes(nodes []*v1.Node) []string {
+	var pending []string
+	for _, n := range nodes {
+		if ignore, _ := strconv.ParseBool(n.Annotations[nd.IgnoreAnnotation]); ignore || !assignableNode(n) {
+			continue
+		}
+		if n.Annotations[nd.AddressAnnotation] == "" {
+			pending = append(pending, n.Name)
+		}
+	}
+	return pending
+}
+
+// assign assigns a static public IP address to the node of the cluster and records the status in the node; returns
+// whether the node holds a static public IP address
+func (m *managedCluster) assign(ctx context.Context, log *logrus.Entry, newAssigner assignerFactory, name string) bool {
+	n, err := newExplorer(m.client, m.cfg).GetNode(ctx, name)
+	if err != nil {
+		log.WithError(err).Warn("failed to get node")
+		return false
+	}
+	cfg, err := providerConfig(log, n, m.cfg)
+	if err != nil {
+		log.WithError(err).Error("invalid filters of the node")
+		return false
+	}
+	assigner, err := newAssigner(ctx, log, n, cfg)
+	if err != nil {
+		log.WithError(err).Error("failed to initialize the assigner of the node")
+		return false
+	}
+	recorder := nd.NewStatusRecorder(m.client)
+	assigned, err := assignAddress(ctx, log, m.client, assigner, n, cfg, nil)
+	if err != nil {
+		log.WithError(err).Error("failed to assign static public IP address")
+		recordStatus(ctx, log, recorder, withOperation(assigner, n, &types.AssignmentStatus{Node: n.Name, Pool: n.Pool, LastError: err.Error()}))
+		return false
+	}
+	// the node already holds a static public IP address the cloud provider does not report: record the held one
+	if assigned == "" {
+		assigned = heldAddress(n)
+	}
+	recordStatus(ctx, log, recorder, withOperation(assigner, n, &types.AssignmentStatus{Node: n.Name, Address: assigned, Pool: n.Pool}))
+	log.WithField("address", assigned).Info("static public IP address assigned")
+	return true
+}
+
+func controllerCmd(c *cli.Context) error {
+	ctx := signals.SetupSignalHandler()
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	if err := config.ApplyProfile(c); err != nil {
+		log.WithError(err).Error("invalid kubeip controller configuration profile")
+		return err //nolint:wrapcheck
+	}
+	cfg := config.NewConfig(c)
+	if err := cfg.Interpolate(); err != nil {
+		log.WithError(err).Error("invalid kubeip controller configuration")
+		return err //nolint:wrapcheck
+	}
+	if err := cfg.Validate(); err != nil {
+		log.WithError(err).Error("invalid kubeip controller configuration")
+		return err //nolint:wrapcheck
+	}
+
+	clusters, err := newManagedClusters(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing the managed clusters")
+		return err
+	}
+	restconfig, err := retrieveKubeConfig(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error retrieving kube config")
+		return err
+	}
+	// the replicas of the controller compete for the controller lease, the replica holding it manages all the clusters
+	mgr, err := newManager(log, restconfig, cfg, controllerLeaseName)
+	if err != nil {
+		log.WithError(err).Error("error initializing kubeip controller manager")
+		return err
+	}
+	log.WithFields(buildInfo()).WithField("clusters", len(clusters)).Info("kubeip controller started")
+	if err = runManager(ctx, mgr, true, func(ctx context.Context) error {
+		runController(ctx, log, clusters, newAssigner)
+		return nil
+	}); err != nil {
+		log.WithError(err).Error("error running kubeip controller")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// filterAssigner is an assigner recording the filters of the assigned instances
+type filterAssigner struct {
+	address.Assigner
+	mu      sync.Mutex
+	filters map[string][]string
+}
+
+func (a *filterAssigner) Assign(_ context.Context, instanceID, _ string, filter []string, _ string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.filters[instanceID] = filter
+	return "1.1.1.1", nil
+}
+
+func (a *filterAssigner) assigned() map[string][]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	assigned := make(map[string][]string, len(a.filters))
+	for instance, filter := range a.filters {
+		assigned[instance] = filter
+	}
+	return assigned
+}
+
+func clusterNode(name string, annotations map[string]string) *v1.Node {
+	n := testGCPNode(annotations)
+	n.Name = name
+	n.Spec.ProviderID = "gce://test-project/us-central1-a/" + name
+	n.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+	return n
+}
+
+func Test_clusterConfig(t *testing.T) {
+	cfg := &config.Config{KubeAPIServer: "https://10.0.0.1", KubeTokenFile: "/var/run/token", Filter: []string{"labels.env=prod"}}
+	got := clusterConfig(cfg, config.Cluster{Name: "gcp-eu", KubeConfig: "/etc/kubeip/clusters", Context: "gke-eu", Filters: []string{"labels.cluster=gcp-eu"}})
+	assert.Equal(t, "/etc/kubeip/clusters", got.KubeConfigPath)
+	assert.Equal(t, "gke-eu", got.KubeContext)
+	assert.Equal(t, "gcp-eu", got.ClusterName)
+	assert.Empty(t, got.KubeAPIServer)
+	assert.Empty(t, got.KubeTokenFile)
+	assert.Equal(t, []string{"labels.env=prod", "labels.cluster=gcp-eu"}, got.Filter)
+	assert.Equal(t, []string{"labels.env=prod"}, cfg.Filter, "the filters of the controller are kept")
+}
+
+func Test_managedCluster_manage(t *testing.T) {
+	notReady := clusterNode("not-ready", nil)
+	notReady.Status.Conditions[0].Status = v1.ConditionFalse
+	unregistered := clusterNode("unregistered", nil)
+	unregistered.Spec.ProviderID = ""
+	client := fake.NewSimpleClientset(
+		clusterNode("pending", nil),
+		clusterNode("assigned", map[string]string{node.AddressAnnotation: "2.2.2.2"}),
+		clusterNode("ignored", map[string]string{node.IgnoreAnnotation: "true"}),
+		notReady,
+		unregistered,
+	)
+	cfg := &config.Config{
+		Filter:             []string{"labels.env=prod"},
+		RetryInterval:      time.Millisecond,
+		LeaseDuration:      1,
+		NodeResyncInterval: time.Minute,
+	}
+	m := &managedCluster{
+		name:   "gcp-eu",
+		client: client,
+		cfg:    clusterConfig(cfg, config.Cluster{Name: "gcp-eu", Filters: []string{"labels.cluster=gcp-eu"}}),
+	}
+	assigner := &filterAssigner{filters: map[string][]string{}}
+	factory := func(context.Context, *logrus.Entry, *types.Node, *config.Config) (address.Assigner, error) {
+		return assigner, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.manage(ctx, prepareLogger("debug", false), factory)
+	}()
+
+	recorder := node.NewStatusRecorder(client)
+	require.Eventually(t, func() bool {
+		status, err := recorder.GetStatus(context.Background(), "pending")
+		return err == nil && status.Address == "1.1.1.1"
+	}, 5*time.Second, 10*time.Millisecond, "the pending node gets an address of the pool of its cluster")
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, map[string][]string{"pending": {"labels.env=prod", "labels.cluster=gcp-eu"}}, assigner.assigned(),
+		"the nodes holding an address, the ignored nodes and the nodes not Ready or without provider ID are not assigned")
+}
+
+func Test_nodeChanged(t *testing.T) {
+	ready := clusterNode("node-1", nil)
+	ready.ResourceVersion = "1"
+	notReady := ready.DeepCopy()
+	notReady.Status.Conditions[0].Status = v1.ConditionFalse
+	unregistered := ready.DeepCopy()
+	unregistered.Spec.ProviderID = ""
+	with := func(n *v1.Node, change func(*v1.Node)) *v1.Node {
+		n = n.DeepCopy()
+		n.ResourceVersion = "2"
+		change(n)
+		return n
+	}
+
+	tests := []struct {
+		name     string
+		old, new *v1.Node
+		want     bool
+	}{
+		{name: "turned Ready", old: notReady, new: with(ready, func(*v1.Node) {}), want: true},
+		{name: "registered", old: unregistered, new: with(ready, func(*v1.Node) {}), want: true},
+		{name: "address released", old: clusterNode("node-1", map[string]string{node.AddressAnnotation: "2.2.2.2"}), new: with(ready, func(*v1.Node) {}), want: true},
+		{name: "resync", old: ready, new: ready, want: true},
+		{name: "heartbeat", old: ready, new: with(ready, func(n *v1.Node) { n.Status.Conditions[0].LastHeartbeatTime.Time = time.Now() })},
+		{name: "status", old: ready, new: with(ready, func(n *v1.Node) { n.Status.Images = []v1.ContainerImage{{Names: []string{"app"}}} })},
+		{name: "not Ready", old: ready, new: with(notReady, func(*v1.Node) {})},
+		{name: "no provider ID", old: notReady, new: with(unregistered, func(*v1.Node) {})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nodeChanged(tt.old, tt.new))
+		})
+	}
+}
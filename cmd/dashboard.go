@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/urfave/cli/v2"
+)
+
+// printDashboard prints the Grafana dashboard of the agent metrics
+func printDashboard(w io.Writer) error {
+	data, err := metrics.Dashboard(metrics.Default)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err //nolint:wrapcheck
+}
+
+func dashboardCmd(c *cli.Context) error {
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	if err := printDashboard(os.Stdout); err != nil {
+		log.WithError(err).Error("error generating Grafana dashboard")
+		return err
+	}
+	return nil
+}
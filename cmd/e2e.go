@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/events"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+// exit code of the e2e command when a lifecycle step fails
+const exitCodeE2EFailed = 3
+
+// e2eResult is the outcome of a step of the end-to-end lifecycle
+type e2eResult struct {
+	step     string
+	address  string
+	duration time.Duration
+	err      error
+}
+
+// e2eTarget is the node of the end-to-end lifecycle; the provider and the instance override those of the node, e.g. an
+// instance of LocalStack on a kind node
+type e2eTarget struct {
+	provider types.CloudProvider
+	instance string
+	zone     string
+}
+
+// e2eExplorer returns the explorer of the node of the lifecycle; with a target provider, any node is taken as simulated
+// (kind nodes have no supported provider ID)
+func e2eExplorer(client kubernetes.Interface, cfg *config.Config, target e2eTarget) nd.Explorer {
+	if target.provider != "" {
+		return nd.NewExplorer(client, types.CloudProviderFake, "")
+	}
+	return newExplorer(client, cfg)
+}
+
+// e2eNode returns the node of the lifecycle, the node name being the instance of a simulated node unless the target
+// instance is set
+func e2eNode(ctx context.Context, explorer nd.Explorer, cfg *config.Config, target e2eTarget) (*types.Node, error) {
+	n, err := explorer.GetNode(ctx, cfg.NodeName)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting node")
+	}
+	if target.provider != "" {
+		n.Cloud = target.provider
+	}
+	if target.instance != "" {
+		n.Instance = target.instance
+	}
+	if target.zone != "" {
+		n.Zone = target.zone
+	}
+	return n, nil
+}
+
+// e2eWatcher delivers the changes the steps of the lifecycle cause, standing in for the cloud change notifications the
+// emulators do not send
+type e2eWatcher struct {
+	changes <-chan events.Change
+}
+
+func (w *e2eWatcher) Watch(ctx context.Context, _ string, changes chan<- events.Change) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change := <-w.changes:
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// e2eRun is the end-to-end lifecycle run by the agent of the node; assigned is the address the node holds
+type e2eRun struct {
+	*agent
+	assigned string
+}
+
+// e2e runs the lifecycle of a static public IP address on the node: assign, assign again (idempotence), repair a drift
+// (the reconcile loop of the agent assigns again the address detached out of band), release and sweep the orphan slot of
+// a deleted node (GC); the steps stop at the first failure and the address is released
+func e2e(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, newAssigner assignerFactory, cfg *config.Config, target e2eTarget) ([]e2eResult, error) {
+	a := newAgent(log, client, cfg)
+	a.explorer = e2eExplorer(client, cfg, target)
+	var err error
+	if a.n, err = e2eNode(ctx, a.explorer, cfg, target); err != nil {
+		return nil, err
+	}
+	if a.assigner, err = newAssigner(ctx, log, a.n, cfg); err != nil {
+		return nil, errors.Wrap(err, "initializing assigner")
+	}
+	if err = checkPermissions(ctx, a.assigner, a.n, cfg); err != nil {
+		return nil, errors.Wrap(err, "checking cloud permissions")
+	}
+	if a.syncer, err = newIntegrations(ctx, log, cfg, client); err != nil {
+		return nil, err
+	}
+
+	r := &e2eRun{agent: a}
+	steps := []struct {
+		name string
+		run  func(ctx context.Context) (string, error)
+	}{
+		{"assign", r.assign},
+		{"assign again", r.assignAgain},
+		{"drift repair", r.repairDrift},
+		{"release", r.release},
+		{"gc", r.sweepOrphan},
+	}
+	var results []e2eResult
+	for _, step := range steps {
+		start := time.Now()
+		stepAddress, err := step.run(ctx)
+		results = append(results, e2eResult{step: step.name, address: stepAddress, duration: time.Since(start), err: err})
+		if err != nil {
+			// leave the node as found: release the address of the failed lifecycle
+			r.cleanup(ctx)
+			return results, errors.Wrapf(err, "step %s", step.name)
+		}
+	}
+	return results, nil
+}
+
+// assign assigns the node an address and records it
+func (r *e2eRun) assign(ctx context.Context) (string, error) {
+	assigned, err := assignAddress(ctx, r.log, r.clientset, r.assigner, r.n, r.cfg, r.syncer)
+	if err != nil {
+		return "", err
+	}
+	if assigned == "" {
+		return "", errors.Errorf("node %s already holds a static public IP address, release it first", r.n.Name)
+	}
+	r.assigned = assigned
+	recordStatus(ctx, r.log, r.recorder, &types.AssignmentStatus{Node: r.n.Name, Address: assigned, Pool: r.n.Pool})
+	r.syncer.assigned(ctx, r.log, r.n, assigned)
+	return assigned, verifyRecorded(ctx, r.recorder, r.n, assigned)
+}
+
+// assignAgain checks the cloud provider reports the node holding its address: assigning again keeps it
+func (r *e2eRun) assignAgain(ctx context.Context) (string, error) {
+	held, err := r.assigner.Assign(ctx, r.n.Instance, r.n.Zone, r.cfg.Filter, r.cfg.OrderBy)
+	if err == nil {
+		want := r.assigned
+		// the address assigned anew is released with the failed lifecycle
+		r.assigned = held
+		return held, errors.Errorf("instance %s holds no address, assigned %s anew, want the held address %s", r.n.Instance, held, want)
+	}
+	if !errors.Is(err, address.ErrStaticIPAlreadyAssigned) {
+		return "", errors.Wrap(err, "failed to assign again")
+	}
+	if held != r.assigned {
+		return held, errors.Errorf("assigned again %s, want the held address %s", held, r.assigned)
+	}
+	return held, nil
+}
+
+// repairDrift detaches the address through the cloud API, as an external actor would, and waits for the reconcile loop
+// of the agent, notified of the change, to assign the node an address again
+func (r *e2eRun) repairDrift(ctx context.Context) (string, error) {
+	loopCtx, stop := context.WithCancel(ctx)
+	changes := make(chan events.Change)
+	reconciled := make(chan string, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchAddressChanges(loopCtx, r.log, &e2eWatcher{changes: changes}, nil, r.n, r.assigned, func(current string) string {
+			held := r.reassign(loopCtx, current)
+			reconciled <- held
+			return held
+		}, func(current string) string { return current })
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	if err := releaseIP(ctx, r.assigner, r.n); err != nil {
+		return "", errors.Wrap(err, "failed to detach the address out of band")
+	}
+	detached := r.assigned
+	select {
+	case changes <- events.Change{Instance: r.n.Instance, Address: detached, Action: "e2e-detach"}:
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "notifying the drift")
+	}
+	select {
+	case r.assigned = <-reconciled:
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "waiting for the drift repair")
+	}
+	// the reconcile loop keeps the detached address when the reassignment fails: the instance then holds none
+	if held, err := r.assignAgain(ctx); err != nil {
+		return held, errors.Wrap(err, "drift not repaired")
+	}
+	return r.assigned, verifyRecorded(ctx, r.recorder, r.n, r.assigned)
+}
+
+// release releases the address and checks the cloud provider and the status of the node report none
+func (r *e2eRun) release(ctx context.Context) (string, error) {
+	released := r.assigned
+	if err := releaseAddress(ctx, r.log, r.assigner, r.recorder, r.syncer, r.n, released); err != nil {
+		return released, err
+	}
+	r.assigned = ""
+	err := r.assigner.Unassign(ctx, r.n.Instance, r.n.Zone)
+	if !errors.Is(err, address.ErrNoStaticIPAssigned) && !errors.Is(err, address.ErrNoPublicIPAssigned) {
+		return released, errors.Errorf("released address is still assigned (unassign: %v)", err)
+	}
+	return released, verifyRecorded(ctx, r.recorder, r.n, "")
+}
+
+const (
+	// e2eOrphanNode is the deleted node whose affinity slot the GC step sweeps
+	e2eOrphanNode = "kubeip-e2e-orphan"
+	// e2eOrphanAddress is the address recorded for the orphan slot, from the documentation range: it is never claimed
+	e2eOrphanAddress = "192.0.2.1"
+)
+
+// sweepOrphan records the affinity slot of a deleted node and checks the sweep of the orphan slots: the slot is kept,
+// marked vacant, for a replacement node, then removed once vacant for longer than the vacancy timeout; the elapsed
+// timeout is simulated by backdating the vacancy, the slots of the other nodes are left as they are
+func (r *e2eRun) sweepOrphan(ctx context.Context) (string, error) {
+	namespace, slot := r.cfg.LeaseNamespace, "e2e."+e2eOrphanNode
+	if err := upsertSlotAddress(ctx, r.clientset, namespace, slot, slotEntry{Address: e2eOrphanAddress, Node: e2eOrphanNode}); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := pruneSlots(ctx, r.clientset, namespace, now); err != nil {
+		return "", err
+	}
+	entry, ok, err := getSlotEntry(ctx, r.clientset, namespace, slot)
+	if err != nil {
+		return "", err
+	}
+	if !ok || entry.VacantSince == nil {
+		return "", errors.Errorf("orphan slot %s not kept vacant for a replacement node", slot)
+	}
+	expired := now.Add(-slotVacancyTimeout - time.Second)
+	entry.VacantSince = &expired
+	if err = upsertSlotAddress(ctx, r.clientset, namespace, slot, entry); err != nil {
+		return "", err
+	}
+	if err = pruneSlots(ctx, r.clientset, namespace, now); err != nil {
+		return "", err
+	}
+	if _, ok, err = getSlotEntry(ctx, r.clientset, namespace, slot); err != nil || ok {
+		return "", errors.Errorf("orphan slot %s not swept after the vacancy timeout (%v)", slot, err)
+	}
+	return "", nil
+}
+
+// cleanup releases the address held by the node after a failed step
+func (r *e2eRun) cleanup(ctx context.Context) {
+	if r.assigned == "" {
+		return
+	}
+	if err := releaseAddress(ctx, r.log, r.assigner, r.recorder, r.syncer, r.n, r.assigned); err != nil {
+		r.log.WithError(err).WithField("address", r.assigned).Warn("failed to release the address after the failed step")
+	}
+}
+
+// verifyRecorded checks the assignment status of the node records the address
+func verifyRecorded(ctx context.Context, recorder nd.StatusRecorder, n *types.Node, want string) error {
+	status, err := recorder.GetStatus(ctx, n.Name)
+	if err != nil {
+		return errors.Wrap(err, "failed to get assignment status")
+	}
+	if status.Address != want {
+		return errors.Errorf("recorded address %q, want %q", status.Address, want)
+	}
+	return nil
+}
+
+// printE2EResults prints the steps of the lifecycle as a table
+func printE2EResults(w io.Writer, results []e2eResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:gomnd
+	fmt.Fprintln(tw, "STEP\tRESULT\tADDRESS\tDURATION\tERROR")
+	for _, r := range results {
+		result, message, stepAddress := "PASS", "-", r.address
+		if r.err != nil {
+			result, message = "FAIL", r.err.Error()
+		}
+		if stepAddress == "" {
+			stepAddress = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.step, result, stepAddress, r.duration.Round(time.Millisecond), message)
+	}
+	tw.Flush()
+}
+
+func e2eCmd(c *cli.Context) error {
+	ctx := signals.SetupSignalHandler()
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	cfg := config.NewConfig(c)
+
+	client, err := newKubernetesClient(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing kubernetes client")
+		return cli.Exit(err, exitCodeSetupFailed)
+	}
+
+	target := e2eTarget{
+		provider: types.CloudProvider(c.String("e2e-provider")),
+		instance: c.String("e2e-instance"),
+		zone:     c.String("e2e-zone"),
+	}
+	results, err := e2e(ctx, log, client, newAssigner, cfg, target)
+	printE2EResults(os.Stdout, results)
+	if err != nil {
+		log.WithError(err).Error("end-to-end lifecycle failed")
+		if len(results) == 0 {
+			return cli.Exit(err, exitCodeSetupFailed)
+		}
+		return cli.Exit(err, exitCodeE2EFailed)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	fakeaddress "github.com/doitintl/kubeip/pkg/address/fake"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_e2e(t *testing.T) {
+	kindNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "kind-worker"},
+		Spec:       v1.NodeSpec{ProviderID: "kind://docker/kind/kind-worker"},
+	}
+	cfg := &config.Config{NodeName: "kind-worker", RetryInterval: time.Millisecond, LeaseDuration: 1, LeaseNamespace: "default"}
+	tests := []struct {
+		name      string
+		target    e2eTarget
+		failNext  int
+		wantSteps []string
+		wantErr   bool
+	}{
+		{
+			name:      "full lifecycle",
+			target:    e2eTarget{provider: types.CloudProviderAWS, instance: "i-0123456789"},
+			wantSteps: []string{"assign", "assign again", "drift repair", "release", "gc"},
+		},
+		{
+			name:    "unsupported node without target provider",
+			wantErr: true,
+		},
+		{
+			name:      "assign failed",
+			target:    e2eTarget{provider: types.CloudProviderGCP},
+			failNext:  1,
+			wantSteps: []string{"assign"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(kindNode.DeepCopy())
+			provider, err := fakeaddress.New([]string{"203.0.113.1", "203.0.113.2"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.failNext; i++ {
+				provider.FailNext(fakeaddress.ErrSimulatedFailure)
+			}
+			var instance string
+			factory := func(_ context.Context, _ *logrus.Entry, n *types.Node, _ *config.Config) (address.Assigner, error) {
+				instance = n.Instance
+				return provider, nil
+			}
+
+			results, err := e2e(context.Background(), prepareLogger("debug", false), client, factory, cfg, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("e2e() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var steps []string
+			for _, r := range results {
+				steps = append(steps, r.step)
+			}
+			if strings.Join(steps, ",") != strings.Join(tt.wantSteps, ",") {
+				t.Errorf("e2e() steps = %v, want %v", steps, tt.wantSteps)
+			}
+			if tt.target.instance != "" && instance != tt.target.instance {
+				t.Errorf("e2e() instance = %v, want %v", instance, tt.target.instance)
+			}
+			// the lifecycle leaves no address assigned and no address recorded
+			if assignments := provider.Assignments(); len(assignments) != 0 {
+				t.Errorf("e2e() left assignments %v", assignments)
+			}
+			status, err := node.NewStatusRecorder(client).GetStatus(context.Background(), "kind-worker")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Address != "" {
+				t.Errorf("e2e() left recorded address %v", status.Address)
+			}
+			// the orphan slot is swept
+			if _, ok, err := getSlotEntry(context.Background(), client, "default", "e2e."+e2eOrphanNode); err != nil || ok {
+				t.Errorf("e2e() left orphan slot (err %v)", err)
+			}
+		})
+	}
+}
+
+func Test_printE2EResults(t *testing.T) {
+	var buf bytes.Buffer
+	printE2EResults(&buf, []e2eResult{
+		{step: "assign", address: "203.0.113.1", duration: time.Second},
+		{step: "assign again", err: errors.New("no available address")},
+	})
+	want := `STEP           RESULT   ADDRESS       DURATION   ERROR
+assign         PASS     203.0.113.1   1s         -
+assign again   FAIL     -             0s         no available address
+`
+	if buf.String() != want {
+		t.Errorf("printE2EResults() = %q, want %q", buf.String(), want)
+	}
+}
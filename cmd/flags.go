@@ -0,0 +1,1280 @@
+package main
+
+import (
+	"time"
+
+	"github.com/doitintl/kubeip/internal/status"
+	"github.com/urfave/cli/v2"
+)
+
+// commonFlags returns flags shared by all kubeip-agent commands
+func commonFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "project",
+			Usage:    "name of the GCP project or the AWS account ID (not needed if running in node) or OCI compartment OCID (required for OCI)",
+			EnvVars:  []string{"PROJECT"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "profile",
+			Usage:    "built-in configuration profile pre-setting the filter, order, timeouts and release policies of a managed Kubernetes service (gke-default, eks-default, aks-default); flags and environment variables set explicitly take precedence",
+			EnvVars:  []string{"KUBEIP_PROFILE"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "region",
+			Usage:    "name of the GCP region or the AWS region or the OCI region (not needed if running in node)",
+			EnvVars:  []string{"REGION"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "ipv6",
+			Usage:    "enable IPv6 support",
+			EnvVars:  []string{"IPV6"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "cluster-name",
+			Usage:    "Kubernetes cluster name, used to identify the cluster in logs",
+			EnvVars:  []string{"CLUSTER_NAME"},
+			Category: "Configuration",
+		},
+		&cli.PathFlag{
+			Name:     "kubeconfig",
+			Usage:    "path to Kubernetes configuration file (not needed if running in node)",
+			EnvVars:  []string{"KUBECONFIG"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "kube-context",
+			Usage:    "kubeconfig context to use, from ~/.kube/config without --kubeconfig (default: current context)",
+			EnvVars:  []string{"KUBE_CONTEXT"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "kube-api-server",
+			Usage:    "override the Kubernetes API server address",
+			EnvVars:  []string{"KUBE_API_SERVER"},
+			Category: "Configuration",
+		},
+		&cli.PathFlag{
+			Name:     "kube-token-file",
+			Usage:    "path to a bearer token file used to authenticate to the Kubernetes API server",
+			EnvVars:  []string{"KUBE_TOKEN_FILE"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "as",
+			Usage:    "user to impersonate for the Kubernetes API calls, e.g. system:serviceaccount:kube-system:kubeip",
+			EnvVars:  []string{"KUBE_AS"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "as-group",
+			Usage:    "group to impersonate for the Kubernetes API calls, repeat for multiple groups (requires --as)",
+			EnvVars:  []string{"KUBE_AS_GROUP"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "proxy-url",
+			Usage:    "proxy of the cloud API and Kubernetes API requests, NO_PROXY hosts excepted (default: HTTP_PROXY and HTTPS_PROXY)",
+			EnvVars:  []string{"PROXY_URL"},
+			Category: "Network",
+		},
+		&cli.PathFlag{
+			Name:     "ca-bundle-file",
+			Usage:    "PEM CA bundle trusted by the cloud API clients in addition to the system roots, e.g. of a TLS-inspecting proxy",
+			EnvVars:  []string{"CA_BUNDLE_FILE"},
+			Category: "Network",
+		},
+		&cli.StringFlag{
+			Name:     "log-level",
+			Usage:    "set log level (debug, info(*), warning, error, fatal, panic)",
+			Value:    "info",
+			EnvVars:  []string{"LOG_LEVEL"},
+			Category: "Logging",
+		},
+		&cli.BoolFlag{
+			Name:     "json",
+			Usage:    "produce log in JSON format: Logstash and Splunk friendly",
+			EnvVars:  []string{"LOG_JSON"},
+			Category: "Logging",
+		},
+		&cli.BoolFlag{
+			Name:     "develop-mode",
+			Usage:    "enable develop mode: all nodes get simulated static public IP addresses, without cloud provider calls",
+			EnvVars:  []string{"DEV_MODE"},
+			Category: "Development",
+		},
+		&cli.StringSliceFlag{
+			Name:     "develop-addresses",
+			Usage:    "simulated static public IP addresses (IPs or CIDRs) of the develop mode (default: 203.0.113.0/28)",
+			EnvVars:  []string{"DEV_ADDRESSES"},
+			Category: "Development",
+		},
+		&cli.DurationFlag{
+			Name:     "develop-latency",
+			Usage:    "simulated latency of the cloud provider calls in develop mode",
+			Value:    defaultDevelopLatency,
+			EnvVars:  []string{"DEV_LATENCY"},
+			Category: "Development",
+		},
+		&cli.Float64Flag{
+			Name:     "develop-failure-rate",
+			Usage:    "simulated failure rate (0 to 1) of the cloud provider calls in develop mode",
+			EnvVars:  []string{"DEV_FAILURE_RATE"},
+			Category: "Development",
+		},
+	}
+}
+
+// awsFlags returns flags of the AWS credentials: a dedicated minimal role instead of the ambient node credentials; only
+// the commands calling the cloud APIs (run, assign, release) take them
+func awsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "aws-region",
+			Usage:    "AWS region, overrides --region for AWS",
+			EnvVars:  []string{"KUBEIP_AWS_REGION"},
+			Category: "AWS",
+		},
+		&cli.StringFlag{
+			Name:     "aws-endpoint",
+			Usage:    "EC2 API endpoint override, e.g. a VPC endpoint or LocalStack (http://localhost:4566)",
+			EnvVars:  []string{"AWS_EC2_ENDPOINT"},
+			Category: "AWS",
+		},
+		&cli.PathFlag{
+			Name:     "aws-credentials-file",
+			Usage:    "AWS shared credentials file (default profile), e.g. a mounted Secret, re-read every minute to pick up rotated keys",
+			EnvVars:  []string{"KUBEIP_AWS_CREDENTIALS_FILE"},
+			Category: "AWS",
+		},
+		&cli.StringFlag{
+			Name:     "aws-role-arn",
+			Usage:    "ARN of the IAM role assumed by the AWS clients (with the ambient credentials or the web identity token)",
+			EnvVars:  []string{"AWS_ASSUME_ROLE_ARN"},
+			Category: "AWS",
+		},
+		&cli.StringFlag{
+			Name:     "aws-external-id",
+			Usage:    "external ID required by the trust policy of the assumed role",
+			EnvVars:  []string{"AWS_EXTERNAL_ID"},
+			Category: "AWS",
+		},
+		&cli.PathFlag{
+			Name:     "aws-web-identity-token-file",
+			Usage:    "web identity token file (IRSA projected service account token) used to assume the role",
+			EnvVars:  []string{"AWS_ASSUME_ROLE_WEB_IDENTITY_TOKEN_FILE"},
+			Category: "AWS",
+		},
+		&cli.IntFlag{
+			Name:     "aws-imds-hop-limit",
+			Usage:    "instance metadata response hop limit expected on the node instances, lower limits are reported (1 with hostNetwork)",
+			Value:    defaultIMDSHopLimit,
+			EnvVars:  []string{"AWS_IMDS_HOP_LIMIT"},
+			Category: "AWS",
+		},
+		&cli.StringFlag{
+			Name:     "aws-lifecycle-hook",
+			Usage:    "launch lifecycle hook of the EC2 Auto Scaling groups, completed with CONTINUE once the elastic IP is attached to the instance",
+			EnvVars:  []string{"AWS_LIFECYCLE_HOOK"},
+			Category: "AWS",
+		},
+		&cli.StringSliceFlag{
+			Name:     "aws-pool-role-arn",
+			Usage:    "ARN of the role of an account of the address pool; its available elastic IPs are transferred to the account of the instances once it has none left (repeatable, tried in order)",
+			EnvVars:  []string{"AWS_POOL_ROLE_ARNS"},
+			Category: "AWS",
+		},
+		&cli.StringSliceFlag{
+			Name:     "aws-ipv6-prefix",
+			Usage:    "/80 IPv6 prefix of the subnets delegated to the primary network interface of a node along with its elastic IP, one per node (repeatable, tried in order)",
+			EnvVars:  []string{"AWS_IPV6_PREFIXES"},
+			Category: "AWS",
+		},
+		&cli.StringSliceFlag{
+			Name:     "aws-secondary-private-ip",
+			Usage:    "secondary private IPv4 address of the subnets assigned to the primary network interface of a node along with its elastic IP, one per node (repeatable, tried in order)",
+			EnvVars:  []string{"AWS_SECONDARY_PRIVATE_IPS"},
+			Category: "AWS",
+		},
+		&cli.StringFlag{
+			Name:     "aws-autoscaling-endpoint",
+			Usage:    "EC2 Auto Scaling API endpoint override of the lifecycle hook, e.g. a VPC endpoint or LocalStack (http://localhost:4566)",
+			EnvVars:  []string{"AWS_AUTOSCALING_ENDPOINT"},
+			Category: "AWS",
+		},
+	}
+}
+
+// azureFlags returns flags of the cloud and the authentication of the Azure clients
+func azureFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "azure-cloud",
+			Usage:    "Azure cloud of the Microsoft Entra ID authority and the Azure Resource Manager endpoint: AzurePublicCloud, AzureUSGovernment or AzureChinaCloud",
+			Value:    "AzurePublicCloud",
+			EnvVars:  []string{"AZURE_CLOUD"},
+			Category: "Azure",
+		},
+		&cli.StringFlag{
+			Name:     "azure-endpoint",
+			Usage:    "Azure Resource Manager endpoint override of the cloud, e.g. a private endpoint or a sovereign cloud (https://management.<cloud>)",
+			EnvVars:  []string{"AZURE_ENDPOINT"},
+			Category: "Azure",
+		},
+		&cli.StringFlag{
+			Name:     "azure-auth",
+			Usage:    "authentication of the Azure clients: system or user-assigned managed identity (managed-identity), federated workload identity (workload-identity) or service principal client secret (client-secret) (default: the environment, workload identity and managed identity credentials in turn)",
+			EnvVars:  []string{"AZURE_AUTH"},
+			Category: "Azure",
+		},
+		&cli.StringFlag{
+			Name:     "azure-tenant-id",
+			Usage:    "Microsoft Entra tenant ID of the workload identity or the service principal",
+			EnvVars:  []string{"AZURE_TENANT_ID"},
+			Category: "Azure",
+		},
+		&cli.StringFlag{
+			Name:     "azure-client-id",
+			Usage:    "application (client) ID of the workload identity or the service principal, or client ID of the user-assigned managed identity (default: the system-assigned managed identity)",
+			EnvVars:  []string{"AZURE_CLIENT_ID"},
+			Category: "Azure",
+		},
+		&cli.PathFlag{
+			Name:     "azure-client-secret-file",
+			Usage:    "client secret file of the service principal, e.g. a mounted Secret",
+			EnvVars:  []string{"AZURE_CLIENT_SECRET_FILE"},
+			Category: "Azure",
+		},
+		&cli.PathFlag{
+			Name:     "azure-federated-token-file",
+			Usage:    "projected service account token file exchanged by the workload identity (set by the Azure workload identity webhook)",
+			EnvVars:  []string{"AZURE_FEDERATED_TOKEN_FILE"},
+			Category: "Azure",
+		},
+	}
+}
+
+// gcpFlags returns flags of the Google Cloud credentials: a dedicated service account instead of the Application Default
+// Credentials; only the commands calling the cloud APIs (run, assign, release) take them
+func gcpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "gcp-alias-ip-range",
+			Usage:    "alias IP range attached to the network interface of a node along with its static public IP, one per node: a CIDR of the subnet or <secondary-range>:<CIDR> (repeatable, tried in order)",
+			EnvVars:  []string{"GCP_ALIAS_IP_RANGES"},
+			Category: "Google Cloud",
+		},
+		&cli.PathFlag{
+			Name:     "gcp-credentials-file",
+			Usage:    "Google Cloud credentials file: service account key or workload identity federation configuration (default: Application Default Credentials)",
+			EnvVars:  []string{"GCP_CREDENTIALS_FILE"},
+			Category: "Google Cloud",
+		},
+		&cli.StringFlag{
+			Name:     "gcp-endpoint",
+			Usage:    "Compute Engine API endpoint override, e.g. a Private Service Connect endpoint (https://compute-<endpoint>.p.googleapis.com/compute/v1/)",
+			EnvVars:  []string{"GCP_COMPUTE_ENDPOINT"},
+			Category: "Google Cloud",
+		},
+		&cli.StringFlag{
+			Name:     "gcp-private-nodes",
+			Usage:    "policy of the nodes without external access config (private GKE clusters behind Cloud NAT): add an access config with the static public IP address, released without restoring an ephemeral address (add), or refuse the assignment (refuse)",
+			Value:    "add",
+			EnvVars:  []string{"GCP_PRIVATE_NODES"},
+			Category: "Google Cloud",
+		},
+		&cli.BoolFlag{
+			Name:     "gcp-address-labels",
+			Usage:    "label the static addresses with the node holding them (kubeip-node) and the time of the assignment (kubeip-assigned-at, Unix seconds), removed on release; one write per address guarded by the label fingerprint",
+			EnvVars:  []string{"GCP_ADDRESS_LABELS"},
+			Category: "Google Cloud",
+		},
+		&cli.StringFlag{
+			Name:     "gcp-impersonate-service-account",
+			Usage:    "email of the service account impersonated by the Google Cloud clients (requires roles/iam.serviceAccountTokenCreator)",
+			EnvVars:  []string{"GCP_IMPERSONATE_SERVICE_ACCOUNT"},
+			Category: "Google Cloud",
+		},
+	}
+}
+
+// chaosFlags returns flags of the chaos mode, injecting faults into the cloud provider calls of the commands assigning
+// and releasing addresses; any fault enables it
+func chaosFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.Float64Flag{
+			Name:     "chaos-error-rate",
+			Usage:    "rate (0 to 1) of the cloud provider calls failing with an injected error",
+			EnvVars:  []string{"CHAOS_ERROR_RATE"},
+			Category: "Chaos",
+		},
+		&cli.DurationFlag{
+			Name:     "chaos-max-delay",
+			Usage:    "maximum random delay injected into the cloud provider calls",
+			EnvVars:  []string{"CHAOS_MAX_DELAY"},
+			Category: "Chaos",
+		},
+		&cli.Float64Flag{
+			Name:     "chaos-stale-rate",
+			Usage:    "rate (0 to 1) of the candidate address reads returning the previous, possibly stale, candidate",
+			EnvVars:  []string{"CHAOS_STALE_RATE"},
+			Category: "Chaos",
+		},
+	}
+}
+
+// assignmentFlags returns flags controlling how the static public IP address is selected and assigned
+func assignmentFlags() []cli.Flag {
+	return concatFlags([]cli.Flag{
+		&cli.DurationFlag{
+			Name:     "retry-interval",
+			Usage:    "when the agent fails to assign the static public IP address, it will retry after this interval",
+			Value:    defaultRetryInterval,
+			EnvVars:  []string{"RETRY_INTERVAL"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "filter",
+			Usage:    "filter for the IP addresses, may reference the node, e.g. labels.pool={{.Node.Labels.nodepool}}",
+			EnvVars:  []string{"FILTER"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "filter-logic",
+			Usage:    "combination of the repeated --filter: and (addresses matching all the filters) or or (addresses matching any filter; not supported on OCI)",
+			EnvVars:  []string{"FILTER_LOGIC"},
+			Value:    "and",
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "name-regex",
+			Usage:    "regular expression the names of the candidate addresses match, in addition to the filters, e.g. ^egress-prod-\\d+$ (GCP address name, AWS Name tag, OCI display name)",
+			EnvVars:  []string{"NAME_REGEX"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "provider-filter",
+			Usage:    "filter for the IP addresses of the nodes of a cloud provider, <provider>=<filter> (aws, gcp, oci, azure, metallb, bgp), used instead of --filter in clusters mixing cloud providers (repeatable)",
+			EnvVars:  []string{"PROVIDER_FILTERS"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "order-by",
+			Usage:    "order by for the IP addresses",
+			EnvVars:  []string{"ORDER_BY"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "non-pool-address",
+			Usage:    "policy of a static public IP address held by the node outside the pool (not matching the filter): keep, replace (release it and assign an address of the pool) or fail",
+			Value:    "keep",
+			EnvVars:  []string{"NON_POOL_ADDRESS"},
+			Category: "Configuration",
+		},
+		&cli.IntFlag{
+			Name:     "retry-attempts",
+			Usage:    "number of attempts to assign the static public IP address",
+			Value:    defaultRetryAttempts,
+			EnvVars:  []string{"RETRY_ATTEMPTS"},
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "drain-timeout",
+			Usage:    "on shutdown, time an in-flight static public IP address assignment may take to complete and record its result; keep it below the pod termination grace period",
+			Value:    defaultDrainTimeout,
+			EnvVars:  []string{"DRAIN_TIMEOUT"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "permission-check",
+			Usage:    "check the cloud permissions of the credentials at startup (GCP testIamPermissions, AWS dry-run calls) and fail with the missing permissions",
+			Value:    true,
+			EnvVars:  []string{"PERMISSION_CHECK"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "quota-check",
+			Usage:    "check the static public IP address quota of the region at startup (AWS vpc-max-elastic-ips, GCP STATIC_ADDRESSES), exposed as a metric and published as a quota_exhausted event once exhausted",
+			EnvVars:  []string{"QUOTA_CHECK"},
+			Category: "Configuration",
+		},
+	}, leaseFlags(), metalLBFlags(), bgpFlags(), awsFlags(), azureFlags(), gcpFlags(), chaosFlags())
+}
+
+// leaseFlags returns flags of the kubernetes leases serializing the assignments and firewall updates of the agents
+func leaseFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:     "lease-duration",
+			Usage:    "duration of the kubernetes lease",
+			Value:    defaultLeaseDuration,
+			EnvVars:  []string{"LEASE_DURATION"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "lease-namespace",
+			Usage:    "namespace of the kubernetes lease",
+			EnvVars:  []string{"LEASE_NAMESPACE"},
+			Value:    "default", // default namespace
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "rollout-pacing",
+			Usage:    "minimum time an agent assigning a new address holds the kubeip-lock lease, so a DaemonSet rollout mutates at most one address per pacing interval cluster wide; disabled if 0",
+			EnvVars:  []string{"ROLLOUT_PACING"},
+			Category: "Configuration",
+		},
+	}
+}
+
+// bgpFlags returns flags of the BGP mode for bare metal nodes
+func bgpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "bgp-addresses",
+			Usage:    "addresses (IPs or CIDRs) claimed for bare metal nodes and announced over BGP by the gobgpd daemon of the node; enables the BGP mode instead of cloud provider calls",
+			EnvVars:  []string{"BGP_ADDRESSES"},
+			Category: "BGP",
+		},
+		&cli.StringFlag{
+			Name:     "bgp-api-address",
+			Usage:    "gRPC API address (host:port) of the gobgpd daemon of the node",
+			EnvVars:  []string{"BGP_API_ADDRESS"},
+			Value:    "127.0.0.1:50051",
+			Category: "BGP",
+		},
+		&cli.StringFlag{
+			Name:     "bgp-next-hop",
+			Usage:    "next hop of the announced routes (default: the gobgpd default)",
+			EnvVars:  []string{"BGP_NEXT_HOP"},
+			Category: "BGP",
+		},
+	}
+}
+
+// metalLBFlags returns flags of the MetalLB mode for bare metal nodes
+func metalLBFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "metallb-addresses",
+			Usage:    "addresses (IPs or CIDRs) claimed for bare metal nodes and announced with MetalLB; enables the MetalLB mode instead of cloud provider calls",
+			EnvVars:  []string{"METALLB_ADDRESSES"},
+			Category: "MetalLB",
+		},
+		&cli.StringFlag{
+			Name:     "metallb-namespace",
+			Usage:    "namespace of the MetalLB IPAddressPool and L2Advertisement resources",
+			EnvVars:  []string{"METALLB_NAMESPACE"},
+			Value:    "metallb-system",
+			Category: "MetalLB",
+		},
+	}
+}
+
+// runFlags returns flags specific to the run command
+func runFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "node-name",
+			Usage:    "Kubernetes node name; if not set, read from the downward API file /etc/podinfo/nodeName",
+			EnvVars:  []string{"NODE_NAME"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "instance-id",
+			Usage:    "cloud instance of a node without provider ID (custom kubelet bootstrap): provider ID (e.g. aws:///us-east-1a/i-0123456789abcdef0, gce://<project>/<zone>/<instance>) or AWS instance ID; the kubeip.com/instance-id node annotation takes precedence",
+			EnvVars:  []string{"INSTANCE_ID"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "node-selector",
+			Usage:    "label selector the node must match (e.g. kubeip=enabled); on other nodes the agent idles without assigning an address",
+			EnvVars:  []string{"NODE_SELECTOR"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "release-on-exit",
+			Usage:    "release the static public IP address on exit",
+			EnvVars:  []string{"RELEASE_ON_EXIT"},
+			Category: "Configuration",
+			Value:    true,
+		},
+		&cli.StringFlag{
+			Name:     "verify-url",
+			Usage:    "URL of an echo endpoint answering the caller address (\"what's my IP\"): the node is published as ready once its traffic egresses from the assigned address (verification disabled if empty)",
+			EnvVars:  []string{"VERIFY_URL"},
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "verify-timeout",
+			Usage:    "time the egress verification waits for the traffic to egress from the assigned address before failing the assignment",
+			Value:    defaultVerifyTimeout,
+			EnvVars:  []string{"VERIFY_TIMEOUT"},
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "watchdog-interval",
+			Usage:    "interval of the checks that the traffic of the node still egresses from its static public IP address (watchdog disabled if 0)",
+			EnvVars:  []string{"WATCHDOG_INTERVAL"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "watchdog-url",
+			Usage:    "URL of the echo endpoint of the connectivity watchdog (default: --verify-url)",
+			EnvVars:  []string{"WATCHDOG_URL"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "watchdog-reconcile",
+			Usage:    "reconcile the assignment once the connectivity watchdog detects the traffic egressing from another address",
+			EnvVars:  []string{"WATCHDOG_RECONCILE"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "release-before-drain",
+			Usage:    "release the static public IP address as soon as a termination handler taints the node before draining it (AWS Node Termination Handler, Karpenter, cluster-autoscaler, GKE)",
+			EnvVars:  []string{"RELEASE_BEFORE_DRAIN"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "drain-taint",
+			Usage:    "additional taint key announcing the drain of the node with --release-before-drain",
+			EnvVars:  []string{"DRAIN_TAINTS"},
+			Category: "Configuration",
+		},
+		&cli.IntFlag{
+			Name:     "canary-percent",
+			Usage:    "percentage of nodes (stable per node name) acting on assignments; other nodes run in dry-run",
+			EnvVars:  []string{"CANARY_PERCENT"},
+			Category: "Canary",
+			Value:    100, //nolint:gomnd
+		},
+		&cli.StringFlag{
+			Name:     "canary-selector",
+			Usage:    "label selector of canary nodes acting on assignments; other nodes run in dry-run",
+			EnvVars:  []string{"CANARY_SELECTOR"},
+			Category: "Canary",
+		},
+		&cli.BoolFlag{
+			Name:     "release-ignored",
+			Usage:    "release the static public IP address held by a node with the kubeip.com/ignore=true annotation",
+			EnvVars:  []string{"RELEASE_IGNORED"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "maintenance-window",
+			Usage:    "cron-like UTC window for reassignments, e.g. \"0 2 * * 6 4h\" (Saturday 02:00 for 4 hours); initial assignments are not restricted",
+			EnvVars:  []string{"MAINTENANCE_WINDOW"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "pool-tenant",
+			Usage:    "tenant of a node pool, <pool>=<tenant>, attributing the addresses of its nodes in the metrics and events",
+			EnvVars:  []string{"POOL_TENANTS"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "address-pool",
+			Usage:    "name of the address pool of the filters, labeling the metrics; may reference the node, e.g. {{.Node.Pool}}-egress",
+			EnvVars:  []string{"ADDRESS_POOL"},
+			Value:    defaultAddressPool,
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "claims",
+			Usage:    "honor the KubeIPClaim resources: a node matching the node selector of a claim gets its address instead of an address of the pool",
+			EnvVars:  []string{"CLAIMS"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "handoff-label",
+			Usage:    "label of the node role (e.g. node.kubernetes.io/role): a replacement node claims the static public IP address of the cordoned or deleted node of the same role once released",
+			EnvVars:  []string{"HANDOFF_LABEL"},
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "handoff-timeout",
+			Usage:    "time a replacement node waits for the replaced node to release its static public IP address before assigning an address of the pool",
+			Value:    defaultHandoffTimeout,
+			EnvVars:  []string{"HANDOFF_TIMEOUT"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "address-affinity",
+			Usage:    "give a node the static public IP address last assigned to its slot (node pool and kubeip.com/slot annotation or instance group and index of the node name) if free, keeping the addresses of each pool stable as the instances are replaced",
+			EnvVars:  []string{"ADDRESS_AFFINITY"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "karpenter-nodepool",
+			Usage:    "Karpenter NodePool whose launched instances get a static public IP address before their node registers (pre-staging disabled if empty)",
+			EnvVars:  []string{"KARPENTER_NODEPOOLS"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "taint-key",
+			Usage:    "specify a taint key to remove from the node once the static public IP address is assigned",
+			EnvVars:  []string{"TAINT_KEY"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "unsupported-provider",
+			Usage:    "policy of the nodes whose provider ID is not one of a supported cloud provider (e.g. kind, k3s): exit with code 3 (fail) or idle without assigning an address (ignore)",
+			Value:    unsupportedProviderFail,
+			EnvVars:  []string{"UNSUPPORTED_PROVIDER"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "wait-for-condition",
+			Usage:    "node condition, <type>[=<status>] (default status True), the node reports before the static public IP address is assigned, e.g. Ready or NetworkUnavailable=False (repeatable)",
+			EnvVars:  []string{"WAIT_FOR_CONDITIONS"},
+			Category: "Configuration",
+		},
+		&cli.StringSliceFlag{
+			Name:     "wait-for-label",
+			Usage:    "node label, <key>[=<value>], the node carries before the static public IP address is assigned, e.g. set by the CNI once ready (repeatable)",
+			EnvVars:  []string{"WAIT_FOR_LABELS"},
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "wait-for-node-timeout",
+			Usage:    "time the assignment waits for the node conditions and labels before going ahead anyway",
+			Value:    defaultWaitForNodeTimeout,
+			EnvVars:  []string{"WAIT_FOR_NODE_TIMEOUT"},
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "node-resync-interval",
+			Usage:    "interval of the checks of the node conditions and labels besides the node changes they are watched for, a safety net for missed changes",
+			Value:    defaultNodeResyncInterval,
+			EnvVars:  []string{"NODE_RESYNC_INTERVAL"},
+			Category: "Configuration",
+		},
+		&cli.DurationFlag{
+			Name:     "startup-jitter",
+			Usage:    "random delay, up to the jitter, before the agent starts calling the cloud provider, spreading the agents of a DaemonSet rollout; disabled if 0",
+			EnvVars:  []string{"STARTUP_JITTER"},
+			Category: "Configuration",
+		},
+	}, concatFlags(assignmentFlags(), integrationFlags(), eventsFlags(), metricsFlags(), healthFlags(), adminFlags())...)
+}
+
+// integrationFlags returns flags of the external systems kept in sync with the assigned address
+func integrationFlags() []cli.Flag {
+	return concatFlags([]cli.Flag{
+		&cli.BoolFlag{
+			Name:     "egress-gateway-labels",
+			Usage:    "label the node holding the static public IP address with kubeip.com/egress-gateway=true and kubeip.com/egress-ip=<address> for Cilium or Calico egress gateways",
+			EnvVars:  []string{"EGRESS_GATEWAY_LABELS"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "readiness-gate",
+			Usage:    "set the kubeip.com/static-ip readiness gate condition of the pods of the node declaring it: true once the node holds its static public IP address",
+			EnvVars:  []string{"READINESS_GATE"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "node-condition",
+			Usage:    "set the kubeip.com/StaticIPAssigned condition of the node: false until the node holds its static public IP address",
+			EnvVars:  []string{"NODE_CONDITION"},
+			Category: "Configuration",
+		},
+	}, dnsFlags(), ipamFlags(), firewallFlags(), snatFlags(), garpFlags(), routeFlags(), sinkFlags())
+}
+
+// routeFlags returns flags of the policy routing entries of the address assigned to the node
+func routeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "policy-route",
+			Usage:    "policy routing entry added with the assigned address and deleted on release: route or rule followed by the ip arguments, e.g. \"rule from {{.Address}} lookup 100\" (fields: Node, Address; repeatable, in order)",
+			EnvVars:  []string{"POLICY_ROUTES"},
+			Category: "Routing",
+		},
+	}
+}
+
+// snatFlags returns flags of the SNAT rules translating the pod egress traffic to the address assigned to the node
+func snatFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "snat-backend",
+			Usage:    "program SNAT rules translating the pod egress traffic to the address assigned to the node (iptables, nftables); requires hostNetwork and NET_ADMIN; disabled if empty",
+			EnvVars:  []string{"SNAT_BACKEND"},
+			Category: "SNAT",
+		},
+		&cli.StringSliceFlag{
+			Name:     "snat-source-cidr",
+			Usage:    "CIDR of the pod egress traffic translated to the address, e.g. the pod CIDR of the node (repeatable)",
+			EnvVars:  []string{"SNAT_SOURCE_CIDRS"},
+			Category: "SNAT",
+		},
+		&cli.StringSliceFlag{
+			Name:     "snat-exclude-cidr",
+			Usage:    "destination CIDR not translated, e.g. the cluster or VPC CIDR (repeatable)",
+			EnvVars:  []string{"SNAT_EXCLUDE_CIDRS"},
+			Category: "SNAT",
+		},
+		&cli.StringFlag{
+			Name:     "snat-interface",
+			Usage:    "output interface of the translated traffic, e.g. eth0; any if empty",
+			EnvVars:  []string{"SNAT_INTERFACE"},
+			Category: "SNAT",
+		},
+	}
+}
+
+// garpFlags returns flags of the gratuitous announcements of the address bound to the node on bare metal and L2 networks
+func garpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "garp-interface",
+			Usage:    "send gratuitous ARP (IPv4) or unsolicited neighbor advertisements (IPv6) of the address bound to the node from the interface, so switches and neighbors update immediately; requires hostNetwork, NET_RAW, arping and ndsend; disabled if empty",
+			EnvVars:  []string{"GARP_INTERFACE"},
+			Category: "Gratuitous ARP",
+		},
+		&cli.IntFlag{
+			Name:     "garp-count",
+			Usage:    "number of gratuitous announcements sent after the assignment",
+			EnvVars:  []string{"GARP_COUNT"},
+			Value:    defaultGARPCount,
+			Category: "Gratuitous ARP",
+		},
+	}
+}
+
+// sinkFlags returns flags of the event sink streaming assignment lifecycle events
+func sinkFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "sink-provider",
+			Usage:    "event sink streaming assignment lifecycle events (kafka-rest-proxy, pubsub, webhook); disabled if empty",
+			EnvVars:  []string{"SINK_PROVIDER"},
+			Category: "Event sink",
+		},
+		&cli.StringFlag{
+			Name:     "sink-url",
+			Usage:    "URL of the Kafka REST Proxy (e.g. http://kafka-rest:8082) or the webhook",
+			EnvVars:  []string{"SINK_URL"},
+			Category: "Event sink",
+		},
+		&cli.StringFlag{
+			Name:     "sink-topic",
+			Usage:    "topic of the events: Kafka topic or Pub/Sub topic (projects/<project>/topics/<name>)",
+			EnvVars:  []string{"SINK_TOPIC"},
+			Category: "Event sink",
+		},
+		&cli.StringFlag{
+			Name:     "sink-template",
+			Usage:    "Go template of the webhook payload over the event, e.g. {\"u_node\":{{json .Node}}} (default: JSON event)",
+			EnvVars:  []string{"SINK_TEMPLATE"},
+			Category: "Event sink",
+		},
+		&cli.PathFlag{
+			Name:     "sink-template-file",
+			Usage:    "file of the Go template of the webhook payload",
+			EnvVars:  []string{"SINK_TEMPLATE_FILE"},
+			Category: "Event sink",
+		},
+		&cli.StringSliceFlag{
+			Name:     "sink-header",
+			Usage:    "header of the webhook requests, e.g. \"Authorization: Bearer <token>\"",
+			EnvVars:  []string{"SINK_HEADERS"},
+			Category: "Event sink",
+		},
+	}
+}
+
+// concatFlags returns the flags of all groups
+func concatFlags(groups ...[]cli.Flag) []cli.Flag {
+	var flags []cli.Flag
+	for _, group := range groups {
+		flags = append(flags, group...)
+	}
+	return flags
+}
+
+// eventsFlags returns flags of the cloud change notifications detecting address changes by external actors
+func eventsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "events-provider",
+			Usage:    "cloud change notifications triggering an immediate reassignment when an external actor changes the address (aws-eventbridge, gcp-pubsub); disabled if empty",
+			EnvVars:  []string{"EVENTS_PROVIDER"},
+			Category: "Events",
+		},
+		&cli.StringFlag{
+			Name:     "events-source",
+			Usage:    "SQS queue URL targeted by the EventBridge rule or Pub/Sub subscription of the audit log sink topic (projects/<project>/subscriptions/<name>)",
+			EnvVars:  []string{"EVENTS_SOURCE"},
+			Category: "Events",
+		},
+	}
+}
+
+// metricsFlags returns flags of the Prometheus metrics endpoint
+func metricsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "metrics-address",
+			Usage:    "listen address of the Prometheus metrics endpoint /metrics, e.g. :9100; disabled if empty",
+			EnvVars:  []string{"METRICS_ADDRESS"},
+			Category: "Metrics",
+		},
+		&cli.StringFlag{
+			Name:     "metrics-tls-cert-file",
+			Usage:    "certificate file serving the metrics over TLS",
+			EnvVars:  []string{"METRICS_TLS_CERT_FILE"},
+			Category: "Metrics",
+		},
+		&cli.StringFlag{
+			Name:     "metrics-tls-key-file",
+			Usage:    "key file of the metrics certificate",
+			EnvVars:  []string{"METRICS_TLS_KEY_FILE"},
+			Category: "Metrics",
+		},
+		&cli.StringFlag{
+			Name:     "metrics-tls-client-ca-file",
+			Usage:    "CA file verifying the client certificates required to scrape the metrics (mutual TLS); requires --metrics-tls-cert-file",
+			EnvVars:  []string{"METRICS_TLS_CLIENT_CA_FILE"},
+			Category: "Metrics",
+		},
+	}
+}
+
+// healthFlags returns flags of the health probes of the agent
+func healthFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "health-address",
+			Usage:    "listen address of the liveness (/healthz) and readiness (/readyz) probes, e.g. :8081; the readiness fails with the provider-unavailable reason during a cloud provider outage; disabled if empty",
+			EnvVars:  []string{"HEALTH_ADDRESS"},
+			Category: "Health",
+		},
+		&cli.StringFlag{
+			Name:     "health-tls-cert-file",
+			Usage:    "certificate file serving the health probes over TLS (the kubelet HTTPS probes skip the verification)",
+			EnvVars:  []string{"HEALTH_TLS_CERT_FILE"},
+			Category: "Health",
+		},
+		&cli.StringFlag{
+			Name:     "health-tls-key-file",
+			Usage:    "key file of the health probes certificate",
+			EnvVars:  []string{"HEALTH_TLS_KEY_FILE"},
+			Category: "Health",
+		},
+		&cli.StringFlag{
+			Name:     "health-tls-client-ca-file",
+			Usage:    "CA file verifying the client certificates required to query the health probes (mutual TLS); the kubelet presents none, probe the agent with an exec probe then; requires --health-tls-cert-file",
+			EnvVars:  []string{"HEALTH_TLS_CLIENT_CA_FILE"},
+			Category: "Health",
+		},
+	}
+}
+
+// adminFlags returns flags of the admin API listing the assignments and handing reconciles and releases over to the agents
+func adminFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "admin-address",
+			Usage:    "listen address of the REST admin API, e.g. :8443; disabled if empty",
+			EnvVars:  []string{"ADMIN_ADDRESS"},
+			Category: "Admin API",
+		},
+		&cli.StringFlag{
+			Name:     "admin-token-file",
+			Usage:    "file of the bearer token authenticating the admin API requests (required with --admin-address)",
+			EnvVars:  []string{"ADMIN_TOKEN_FILE"},
+			Category: "Admin API",
+		},
+		&cli.BoolFlag{
+			Name:     "admin-dashboard",
+			Usage:    "serve the web dashboard of the nodes, assigned addresses, pool utilization and recent errors at /ui/ of the admin API",
+			EnvVars:  []string{"ADMIN_DASHBOARD"},
+			Category: "Admin API",
+		},
+		&cli.StringFlag{
+			Name:     "admin-tls-cert-file",
+			Usage:    "certificate file serving the admin API over TLS",
+			EnvVars:  []string{"ADMIN_TLS_CERT_FILE"},
+			Category: "Admin API",
+		},
+		&cli.StringFlag{
+			Name:     "admin-tls-key-file",
+			Usage:    "key file of the admin API certificate",
+			EnvVars:  []string{"ADMIN_TLS_KEY_FILE"},
+			Category: "Admin API",
+		},
+		&cli.StringFlag{
+			Name:     "admin-tls-client-ca-file",
+			Usage:    "CA file verifying the client certificates required by the admin API on top of the bearer token (mutual TLS); requires --admin-tls-cert-file",
+			EnvVars:  []string{"ADMIN_TLS_CLIENT_CA_FILE"},
+			Category: "Admin API",
+		},
+	}
+}
+
+// firewallFlags returns flags of the cloud firewall resource trusting assigned addresses
+func firewallFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "firewall-provider",
+			Usage:    "cloud firewall resource trusting assigned addresses (gcp-firewall, aws-security-group, aws-prefix-list); disabled if empty",
+			EnvVars:  []string{"FIREWALL_PROVIDER"},
+			Category: "Firewall",
+		},
+		&cli.StringFlag{
+			Name:     "firewall-name",
+			Usage:    "GCP firewall rule name, AWS security group ID or AWS managed prefix list ID",
+			EnvVars:  []string{"FIREWALL_NAME"},
+			Category: "Firewall",
+		},
+	}
+}
+
+// ipamFlags returns flags of the IPAM system recording assigned addresses
+func ipamFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "ipam-provider",
+			Usage:    "IPAM system recording assigned addresses (netbox, infoblox, phpipam); disabled if empty",
+			EnvVars:  []string{"IPAM_PROVIDER"},
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-url",
+			Usage:    "base URL of the IPAM API, e.g. https://netbox.example.com",
+			EnvVars:  []string{"IPAM_URL"},
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-token",
+			Usage:    "API token of the IPAM system",
+			EnvVars:  []string{"IPAM_TOKEN"},
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-username",
+			Usage:    "username of the IPAM API (Infoblox)",
+			EnvVars:  []string{"IPAM_USERNAME"},
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-password",
+			Usage:    "password of the IPAM API (Infoblox)",
+			EnvVars:  []string{"IPAM_PASSWORD"},
+			Category: "IPAM",
+		},
+		&cli.PathFlag{
+			Name:     "ipam-ca-file",
+			Usage:    "CA certificate file verifying the IPAM API server certificate",
+			EnvVars:  []string{"IPAM_CA_FILE"},
+			Category: "IPAM",
+		},
+		&cli.BoolFlag{
+			Name:     "ipam-insecure-skip-verify",
+			Usage:    "skip the IPAM API server certificate verification (testing only)",
+			EnvVars:  []string{"IPAM_INSECURE_SKIP_VERIFY"},
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-infoblox-record",
+			Usage:    "Infoblox object recording the address: fixedaddress (IPv4, reserved) or host (requires --ipam-domain)",
+			EnvVars:  []string{"IPAM_INFOBLOX_RECORD"},
+			Value:    "fixedaddress",
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-domain",
+			Usage:    "domain of the node host records in the IPAM system: <node>.<domain>",
+			EnvVars:  []string{"IPAM_DOMAIN"},
+			Category: "IPAM",
+		},
+		&cli.StringSliceFlag{
+			Name:     "ipam-extattr",
+			Usage:    "Infoblox extensible attribute name=template, e.g. \"Cluster={{.Cluster}}\" (fields: Node, Cluster, Address)",
+			EnvVars:  []string{"IPAM_EXTATTRS"},
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-subnet-id",
+			Usage:    "phpIPAM subnet ID where addresses missing in phpIPAM are created",
+			EnvVars:  []string{"IPAM_SUBNET_ID"},
+			Category: "IPAM",
+		},
+		&cli.StringFlag{
+			Name:     "ipam-interface",
+			Usage:    "name of the node interface the address is assigned to in the IPAM system, e.g. eth0",
+			EnvVars:  []string{"IPAM_INTERFACE"},
+			Category: "IPAM",
+		},
+	}
+}
+
+// dnsFlags returns flags of the DNS records kept in sync with assigned addresses
+func dnsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "dns-provider",
+			Usage:    "DNS provider keeping a <node>.<domain> record in sync with the assigned address (clouddns, cloudflare, external-dns); disabled if empty",
+			EnvVars:  []string{"DNS_PROVIDER"},
+			Category: "DNS",
+		},
+		&cli.StringFlag{
+			Name:     "dns-zone",
+			Usage:    "DNS zone of the node records (Cloud DNS managed zone name or Cloudflare zone ID)",
+			EnvVars:  []string{"DNS_ZONE"},
+			Category: "DNS",
+		},
+		&cli.StringFlag{
+			Name:     "dns-domain",
+			Usage:    "domain of the node records, e.g. egress.example.com",
+			EnvVars:  []string{"DNS_DOMAIN"},
+			Category: "DNS",
+		},
+		&cli.StringFlag{
+			Name:     "dns-namespace",
+			Usage:    "namespace of the external-dns DNSEndpoint resources",
+			EnvVars:  []string{"DNS_NAMESPACE"},
+			Value:    "default",
+			Category: "DNS",
+		},
+		&cli.StringFlag{
+			Name:     "dns-api-token",
+			Usage:    "API token of the DNS provider (Cloudflare token with Zone.DNS edit permission)",
+			EnvVars:  []string{"DNS_API_TOKEN", "CLOUDFLARE_API_TOKEN"},
+			Category: "DNS",
+		},
+		&cli.IntFlag{
+			Name:     "dns-ttl",
+			Usage:    "TTL of the node records in seconds",
+			EnvVars:  []string{"DNS_TTL"},
+			Value:    300, //nolint:gomnd
+			Category: "DNS",
+		},
+	}
+}
+
+// controllerFlags returns flags of the controller managing the nodes of several clusters
+func controllerFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "cluster",
+			Usage:    "cluster managed by the controller, <name>=<kubeconfig>[#<context>] (default context: the current context of the kubeconfig) (repeatable)",
+			EnvVars:  []string{"CLUSTERS"},
+			Category: "Controller",
+		},
+		&cli.StringSliceFlag{
+			Name:     "cluster-filter",
+			Usage:    "filter scoping the addresses of a managed cluster, <name>=<filter>, added to --filter, e.g. gcp-eu=labels.cluster=gcp-eu; required for each cluster once several share the addresses (repeatable)",
+			EnvVars:  []string{"CLUSTER_FILTERS"},
+			Category: "Controller",
+		},
+		&cli.StringFlag{
+			Name:     "node-selector",
+			Usage:    "label selector of the nodes of the managed clusters getting a static public IP address (e.g. kubeip=enabled); all nodes if empty",
+			EnvVars:  []string{"NODE_SELECTOR"},
+			Category: "Controller",
+		},
+		&cli.DurationFlag{
+			Name:     "node-resync-interval",
+			Usage:    "interval of the checks of the nodes of the managed clusters besides the node changes they are watched for, a safety net for missed changes",
+			Value:    defaultNodeResyncInterval,
+			EnvVars:  []string{"NODE_RESYNC_INTERVAL"},
+			Category: "Controller",
+		},
+	}
+}
+
+// assignFlags returns flags specific to the one-shot assign command
+func assignFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "node",
+			Aliases:  []string{"node-name"},
+			Usage:    "Kubernetes node name to assign the static public IP address to (not needed if running in node)",
+			EnvVars:  []string{"NODE_NAME"},
+			Category: "Configuration",
+		},
+	}, assignmentFlags()...)
+}
+
+// loadTestFlags returns flags specific to the loadtest command
+func loadTestFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:     "loadtest-nodes",
+			Usage:    "number of virtual nodes joining and leaving the cluster",
+			Value:    5000, //nolint:gomnd
+			EnvVars:  []string{"LOADTEST_NODES"},
+			Category: "Load test",
+		},
+		&cli.Float64Flag{
+			Name:     "loadtest-join-rate",
+			Usage:    "number of virtual nodes joining per second",
+			Value:    100, //nolint:gomnd
+			EnvVars:  []string{"LOADTEST_JOIN_RATE"},
+			Category: "Load test",
+		},
+		&cli.Float64Flag{
+			Name:     "loadtest-leave-rate",
+			Usage:    "number of virtual nodes leaving per second, releasing their address",
+			EnvVars:  []string{"LOADTEST_LEAVE_RATE"},
+			Category: "Load test",
+		},
+		&cli.IntFlag{
+			Name:     "loadtest-workers",
+			Usage:    "number of concurrent assignments and releases",
+			Value:    10, //nolint:gomnd
+			EnvVars:  []string{"LOADTEST_WORKERS"},
+			Category: "Load test",
+		},
+		&cli.IntFlag{
+			Name:     "loadtest-retry-attempts",
+			Usage:    "number of attempts to assign the address of a joining node",
+			Value:    3, //nolint:gomnd
+			EnvVars:  []string{"LOADTEST_RETRY_ATTEMPTS"},
+			Category: "Load test",
+		},
+		&cli.DurationFlag{
+			Name:     "loadtest-retry-interval",
+			Usage:    "interval between the assignment attempts of a joining node",
+			Value:    time.Second,
+			EnvVars:  []string{"LOADTEST_RETRY_INTERVAL"},
+			Category: "Load test",
+		},
+		&cli.DurationFlag{
+			Name:     "loadtest-duration",
+			Usage:    "duration of the joins and leaves; the queued assignments and releases complete after it",
+			Value:    time.Minute,
+			EnvVars:  []string{"LOADTEST_DURATION"},
+			Category: "Load test",
+		},
+	}
+}
+
+// e2eFlags returns flags specific to the e2e command
+func e2eFlags() []cli.Flag {
+	return concatFlags([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "node",
+			Aliases:  []string{"node-name"},
+			Usage:    "Kubernetes node name to run the static public IP address lifecycle on",
+			EnvVars:  []string{"NODE_NAME"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "e2e-provider",
+			Usage:    "cloud provider (aws, gcp) of the emulator (LocalStack, GCE emulator) to run the lifecycle against, for nodes without a supported provider ID, e.g. kind nodes",
+			EnvVars:  []string{"E2E_PROVIDER"},
+			Category: "E2E",
+		},
+		&cli.StringFlag{
+			Name:     "e2e-instance",
+			Usage:    "emulator instance to run the lifecycle on (default: the node name with --e2e-provider, the node instance otherwise)",
+			EnvVars:  []string{"E2E_INSTANCE"},
+			Category: "E2E",
+		},
+		&cli.StringFlag{
+			Name:     "e2e-zone",
+			Usage:    "zone of the emulator instance (default: the node zone label)",
+			EnvVars:  []string{"E2E_ZONE"},
+			Category: "E2E",
+		},
+	}, assignmentFlags(), integrationFlags())
+}
+
+// releaseFlags returns flags specific to the release command
+func releaseFlags() []cli.Flag {
+	return concatFlags([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "node",
+			Aliases:  []string{"node-name"},
+			Usage:    "Kubernetes node name to release the static public IP address from",
+			EnvVars:  []string{"NODE_NAME"},
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "ip",
+			Usage:    "static public IP address to release; used to find the node when --node is not set, or to verify the node holds it",
+			EnvVars:  []string{"RELEASE_IP"},
+			Category: "Configuration",
+		},
+	}, leaseFlags(), metalLBFlags(), bgpFlags(), awsFlags(), azureFlags(), gcpFlags(), chaosFlags(), integrationFlags())
+}
+
+// statusFlags returns flags specific to the status command
+func statusFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "node",
+			Aliases:  []string{"node-name"},
+			Usage:    "Kubernetes node name to show the assignment status for",
+			EnvVars:  []string{"NODE_NAME"},
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "all",
+			Usage:    "show the assignment status of all nodes",
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "history",
+			Usage:    "show the recorded assignment transitions (last 50 per node) instead of the current status",
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "address",
+			Usage:    "show the nodes holding the static public IP address at --at, according to their recorded history",
+			Category: "Configuration",
+		},
+		&cli.TimestampFlag{
+			Name:     "at",
+			Usage:    "time (RFC3339) of the --address query (default: now)",
+			Layout:   time.RFC3339,
+			Category: "Configuration",
+		},
+		&cli.StringFlag{
+			Name:     "output",
+			Aliases:  []string{"o"},
+			Usage:    "output format (table, json, yaml)",
+			Value:    status.FormatTable,
+			Category: "Configuration",
+		},
+	}
+}
+
+// listFlags returns flags specific to the kubectl plugin list command
+func listFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "output",
+			Aliases:  []string{"o"},
+			Usage:    "output format (table, json, yaml)",
+			Value:    status.FormatTable,
+			Category: "Configuration",
+		},
+	}
+}
+
+// topFlags returns flags specific to the top command
+func topFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.DurationFlag{
+			Name:     "interval",
+			Usage:    "refresh interval, must be positive",
+			Value:    2 * time.Second, //nolint:gomnd
+			Category: "Configuration",
+		},
+		&cli.BoolFlag{
+			Name:     "once",
+			Usage:    "print the overview once and exit",
+			Category: "Configuration",
+		},
+	}
+}
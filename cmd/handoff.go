@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/doitintl/kubeip/internal/metrics"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// handoffPollInterval is the interval of the checks of the node replaced during a hand-off
+const handoffPollInterval = 5 * time.Second
+
+// handOff claims the static public IP address of the node the node replaces: a node of the same role (value of the
+// hand-off label), cordoned or being deleted and holding a recorded address; the address is claimed as soon as the
+// replaced node releases it; returns the claimed address, empty without a node to take over from, with assigners not
+// claiming addresses or once the hand-off timeout expires: the address is then assigned from the pool
+func handOff(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, assigner address.Assigner, n *types.Node, cfg *config.Config, interval time.Duration) string {
+	// a claimed address is assigned regardless of the replaced node
+	if cfg.HandoffLabel == "" || n.ClaimedAddress != "" {
+		return ""
+	}
+	logger := log.WithFields(logrus.Fields{"node": n.Name, "handoff-label": cfg.HandoffLabel})
+	claimer, ok := assigner.(address.Claimer)
+	if !ok {
+		logger.Warn("cloud provider does not support claiming a given address, skipping hand-off")
+		return ""
+	}
+	replaced, replacedAddress, err := findReplacedNode(ctx, client, n.Name, cfg.HandoffLabel)
+	if err != nil {
+		logger.WithError(err).Warn("failed to find the node replaced, skipping hand-off")
+		return ""
+	}
+	if replaced == "" {
+		return ""
+	}
+	logger = logger.WithFields(logrus.Fields{"replaced-node": replaced, "address": replacedAddress, "handoff-timeout": cfg.HandoffTimeout})
+	logger.Info("waiting for the replaced node to release its static public IP address")
+
+	handoffCtx, cancel := context.WithTimeout(ctx, cfg.HandoffTimeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		released, err := addressReleased(handoffCtx, client, replaced, replacedAddress)
+		if err != nil {
+			logger.WithError(err).Warn("failed to check the replaced node")
+		}
+		if released {
+			claimed, err := claimAddress(handoffCtx, log, client, claimer, n, replacedAddress, cfg)
+			if err == nil {
+				logger.Info("static public IP address handed off from the replaced node")
+				return claimed
+			}
+			// the cloud provider may still report the address attached to the terminated instance
+			logger.WithError(err).Debug("failed to claim the released static public IP address")
+		}
+		select {
+		case <-ticker.C:
+		case <-handoffCtx.Done():
+			logger.Warn("static public IP address not handed off before the timeout, assigning an address of the pool")
+			return ""
+		}
+	}
+}
+
+// findReplacedNode returns the node of the same role (value of the label) as the node, cordoned or being deleted, and
+// the static public IP address recorded for it; empty if there is none
+func findReplacedNode(ctx context.Context, client kubernetes.Interface, nodeName, label string) (string, string, error) {
+	self, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to get kubernetes node")
+	}
+	role, ok := self.Labels[label]
+	if !ok {
+		return "", "", nil
+	}
+	list, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: labels.Set{label: role}.String()})
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to list kubernetes nodes")
+	}
+	nodes := list.Items
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Name == nodeName || !terminating(node) {
+			continue
+		}
+		if recorded := node.Annotations[nd.AddressAnnotation]; recorded != "" {
+			return node.Name, recorded, nil
+		}
+	}
+	return "", "", nil
+}
+
+// terminating checks if the node is on its way out: deleted or cordoned before the drain
+func terminating(node *v1.Node) bool {
+	return node.DeletionTimestamp != nil || node.Spec.Unschedulable
+}
+
+// addressReleased checks if the replaced node released the address: gone or recording another address
+func addressReleased(ctx context.Context, client kubernetes.Interface, nodeName, replacedAddress string) (bool, error) {
+	node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get kubernetes node")
+	}
+	return node.Annotations[nd.AddressAnnotation] != replacedAddress, nil
+}
+
+// claimAddress claims the address for the node holding the cluster wide lock, like any assignment
+func claimAddress(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, claimer address.Claimer, n *types.Node, claimed string, cfg *config.Config) (string, error) {
+	ctx = metrics.WithTraceID(ctx, metrics.NewTraceID())
+	log = log.WithField("trace_id", metrics.TraceID(ctx))
+	start := time.Now()
+	lock := lease.NewKubeLeaseLock(client, kubeipLockName, cfg.LeaseNamespace, n.Instance, cfg.LeaseDuration)
+	if err := lock.Lock(ctx); err != nil {
+		return "", errors.Wrap(err, "failed to acquire lock")
+	}
+	drainCtx, drainCancel := drainContext(ctx, cfg.DrainTimeout)
+	defer drainCancel()
+	defer func() {
+		lock.Unlock(drainCtx) //nolint:errcheck
+		log.Debug("lock released")
+	}()
+	assigned, err := claimer.Claim(drainCtx, n.Instance, n.Zone, claimed, cfg.Filter)
+	if err != nil {
+		return "", err //nolint:wrapcheck
+	}
+	metrics.ObserveAssignment(ctx, string(n.Cloud), n.AddressPool, n.Pool, n.Name, metrics.ResultSuccess, time.Since(start))
+	return assigned, nil
+}
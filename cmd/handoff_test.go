@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const roleLabel = "node.kubernetes.io/role"
+
+// claimAssigner is an assigner claiming the addresses not held by other instances
+type claimAssigner struct {
+	address.Assigner
+	mu     sync.Mutex
+	held   map[string]string
+	claims int
+}
+
+func (a *claimAssigner) Claim(_ context.Context, instanceID, _, claimed string, _ []string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.claims++
+	for instance, held := range a.held {
+		if held == claimed && instance != instanceID {
+			return "", address.ErrNoAvailableAddress
+		}
+	}
+	a.held[instanceID] = claimed
+	return claimed, nil
+}
+
+func roleNode(name, role string, cordoned bool, recorded string) *v1.Node {
+	n := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{roleLabel: role}, Annotations: map[string]string{}}}
+	n.Spec.Unschedulable = cordoned
+	if recorded != "" {
+		n.Annotations[node.AddressAnnotation] = recorded
+	}
+	return n
+}
+
+func Test_findReplacedNode(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		roleNode("new", "web", false, ""),
+		roleNode("web-1", "web", false, "1.1.1.1"),
+		roleNode("web-2", "web", true, "2.2.2.2"),
+		roleNode("db-1", "db", true, "3.3.3.3"),
+	)
+	replaced, recorded, err := findReplacedNode(context.Background(), client, "new", roleLabel)
+	require.NoError(t, err)
+	assert.Equal(t, "web-2", replaced)
+	assert.Equal(t, "2.2.2.2", recorded)
+
+	// no role label: no hand-off
+	replaced, _, err = findReplacedNode(context.Background(), client, "new", "kubeip.com/role")
+	require.NoError(t, err)
+	assert.Empty(t, replaced)
+}
+
+func Test_handOff(t *testing.T) {
+	log := logrus.NewEntry(logrus.New())
+	n := &types.Node{Name: "new", Instance: "i-new", Zone: "zone", Pool: "web"}
+	cfg := &config.Config{HandoffLabel: roleLabel, HandoffTimeout: time.Second, LeaseNamespace: "default", LeaseDuration: 5}
+
+	t.Run("claimed once released", func(t *testing.T) {
+		client := fake.NewSimpleClientset(roleNode("new", "web", false, ""), roleNode("old", "web", true, "2.2.2.2"))
+		assigner := &claimAssigner{held: map[string]string{"i-old": "2.2.2.2"}}
+		go func() {
+			// the agent of the replaced node releases the address on exit
+			time.Sleep(50 * time.Millisecond)
+			assigner.mu.Lock()
+			delete(assigner.held, "i-old")
+			assigner.mu.Unlock()
+			_ = client.CoreV1().Nodes().Delete(context.Background(), "old", metav1.DeleteOptions{})
+		}()
+		assert.Equal(t, "2.2.2.2", handOff(context.Background(), log, client, assigner, n, cfg, 10*time.Millisecond))
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		client := fake.NewSimpleClientset(roleNode("new", "web", false, ""), roleNode("old", "web", true, "2.2.2.2"))
+		assigner := &claimAssigner{held: map[string]string{"i-old": "2.2.2.2"}}
+		timeout := *cfg
+		timeout.HandoffTimeout = 50 * time.Millisecond
+		assert.Empty(t, handOff(context.Background(), log, client, assigner, n, &timeout, 10*time.Millisecond))
+		assert.Zero(t, assigner.claims)
+	})
+
+	t.Run("no replaced node", func(t *testing.T) {
+		client := fake.NewSimpleClientset(roleNode("new", "web", false, ""), roleNode("other", "web", false, "2.2.2.2"))
+		assigner := &claimAssigner{held: map[string]string{}}
+		assert.Empty(t, handOff(context.Background(), log, client, assigner, n, cfg, 10*time.Millisecond))
+	})
+
+	t.Run("hand-off disabled", func(t *testing.T) {
+		client := fake.NewSimpleClientset(roleNode("new", "web", false, ""), roleNode("old", "web", true, "2.2.2.2"))
+		assert.Empty(t, handOff(context.Background(), log, client, &claimAssigner{}, n, &config.Config{}, 10*time.Millisecond))
+	})
+}
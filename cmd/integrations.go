@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/dns"
+	"github.com/doitintl/kubeip/internal/firewall"
+	"github.com/doitintl/kubeip/internal/garp"
+	"github.com/doitintl/kubeip/internal/ipam"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/doitintl/kubeip/internal/metrics"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/route"
+	"github.com/doitintl/kubeip/internal/sink"
+	"github.com/doitintl/kubeip/internal/snat"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// firewallLockName is the cluster wide lock serializing the firewall rule updates of the agents
+	firewallLockName = "kubeip-firewall-lock"
+)
+
+// integrations keep external systems (DNS, IPAM, firewall, egress gateway, policy routes, SNAT, gratuitous ARP, pod readiness gates, node condition, event sink) in sync with the static public IP address assigned to the node;
+// failures are logged and do not interrupt the agent; syncs complete on shutdown, up to the record status timeout
+type integrations struct {
+	dns      dns.Updater
+	ipam     ipam.Registrar
+	firewall firewall.Syncer
+	egress   nd.EgressLabeler
+	routes   route.Hook
+	snat     snat.Programmer
+	garp     garp.Sender
+	sink     sink.Sink
+	cluster  string
+	// readiness sets the readiness gate of the pods of the node with the held address
+	readiness        nd.ReadinessGate
+	readinessMutex   sync.Mutex
+	readinessAddress string
+	// readinessWatching is set once the pods of the node are watched
+	readinessWatching atomic.Bool
+	// condition sets the kubeip.com/StaticIPAssigned condition of the node
+	condition nd.NodeCondition
+}
+
+func newIntegrations(ctx context.Context, log *logrus.Entry, cfg *config.Config, client kubernetes.Interface) (*integrations, error) {
+	var dynamicClient dynamic.Interface
+	if cfg.DNSProvider == dns.ProviderExternalDNS {
+		var err error
+		if dynamicClient, err = newDynamicClient(log, cfg); err != nil {
+			return nil, err
+		}
+	}
+	dnsUpdater, err := dns.NewUpdater(ctx, log, cfg, dynamicClient)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing DNS updater")
+	}
+	registrar, err := ipam.NewRegistrar(log, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing IPAM registrar")
+	}
+	holder := cfg.NodeName
+	if holder == "" {
+		holder = "kubeip-cli"
+	}
+	firewallLock := lease.NewKubeLeaseLock(client, firewallLockName, cfg.LeaseNamespace, holder, cfg.LeaseDuration)
+	syncer, err := firewall.NewSyncer(ctx, log, cfg, firewallLock)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing firewall syncer")
+	}
+	var egress nd.EgressLabeler
+	if cfg.EgressGatewayLabels {
+		egress = nd.NewEgressLabeler(client)
+	}
+	var readiness nd.ReadinessGate
+	if cfg.ReadinessGate {
+		readiness = nd.NewReadinessGate(client)
+	}
+	var condition nd.NodeCondition
+	if cfg.NodeCondition {
+		condition = nd.NewNodeCondition(client)
+	}
+	routes, err := route.NewHook(log, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing policy routes")
+	}
+	programmer, err := snat.NewProgrammer(log, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing SNAT programmer")
+	}
+	announcer, err := garp.NewSender(log, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing gratuitous ARP sender")
+	}
+	eventSink, err := sink.NewSink(ctx, log, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing event sink")
+	}
+	return &integrations{
+		dns:       dnsUpdater,
+		ipam:      registrar,
+		firewall:  syncer,
+		egress:    egress,
+		routes:    routes,
+		snat:      programmer,
+		garp:      announcer,
+		sink:      eventSink,
+		cluster:   cfg.ClusterName,
+		readiness: readiness,
+		condition: condition,
+	}, nil
+}
+
+// assigned publishes the address assigned to the node
+func (i *integrations) assigned(ctx context.Context, log *logrus.Entry, n *types.Node, assignedAddress string) {
+	i.sync(ctx, log, n, assignedAddress, false)
+}
+
+// released withdraws the address released from the node
+func (i *integrations) released(ctx context.Context, log *logrus.Entry, n *types.Node, assignedAddress string) {
+	i.sync(ctx, log, n, assignedAddress, true)
+}
+
+// pending sets the node condition false until the address is assigned: a node without the condition looks usable
+func (i *integrations) pending(ctx context.Context, log *logrus.Entry, n *types.Node) {
+	if i.condition == nil {
+		return
+	}
+	syncCtx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
+	if err := i.condition.Sync(syncCtx, n.Name, ""); err != nil {
+		log.WithError(err).WithField("node", n.Name).Warn("failed to update node condition")
+	}
+}
+
+// failed publishes the failed assignment of the node
+func (i *integrations) failed(ctx context.Context, log *logrus.Entry, n *types.Node, err error) {
+	syncCtx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
+	i.publish(syncCtx, log, n, &sink.Event{Type: sink.EventFailed, Error: err.Error()})
+}
+
+// blocked publishes the assignment of the node blocked by a permanent error and keeps the readiness gate of its pods
+// and the node condition false
+func (i *integrations) blocked(ctx context.Context, log *logrus.Entry, n *types.Node, err error) {
+	syncCtx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
+	if i.readiness != nil {
+		i.readinessMutex.Lock()
+		i.readinessAddress = ""
+		i.readinessMutex.Unlock()
+		i.syncReadinessGates(syncCtx, log.WithField("node", n.Name), n)
+	}
+	if i.condition != nil {
+		if condErr := i.condition.Block(syncCtx, n.Name, err); condErr != nil {
+			log.WithError(condErr).WithField("node", n.Name).Warn("failed to update node condition")
+		}
+	}
+	i.publish(syncCtx, log, n, &sink.Event{Type: sink.EventBlocked, Error: err.Error()})
+}
+
+// nonPool publishes the static public IP address held by the node outside the pool with the result of the policy
+func (i *integrations) nonPool(ctx context.Context, log *logrus.Entry, n *types.Node, heldAddress, result string) {
+	if i == nil {
+		return
+	}
+	syncCtx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
+	i.publish(syncCtx, log, n, &sink.Event{Type: sink.EventNonPool, Address: heldAddress, Result: result})
+}
+
+// publish streams the assignment lifecycle event of the node to the event sink
+func (i *integrations) publish(ctx context.Context, log *logrus.Entry, n *types.Node, event *sink.Event) {
+	if i.sink == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	event.Cluster = i.cluster
+	event.Node = n.Name
+	event.Instance = n.Instance
+	event.Cloud = string(n.Cloud)
+	event.Pool = n.Pool
+	event.Tenant = n.Tenant
+	if err := i.sink.Publish(ctx, event); err != nil {
+		log.WithError(err).WithField("node", n.Name).Warn("failed to publish assignment event")
+	}
+}
+
+func (i *integrations) sync(ctx context.Context, log *logrus.Entry, n *types.Node, assignedAddress string, release bool) {
+	logger := log.WithFields(logrus.Fields{
+		"node":    n.Name,
+		"address": assignedAddress,
+	})
+	// nothing to publish or withdraw for an unknown address, e.g. released before any assignment was recorded
+	if assignedAddress == "" {
+		logger.Warn("static public IP address unknown, skipping integrations sync")
+		return
+	}
+
+	ctx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
+
+	if i.dns != nil {
+		var err error
+		if release {
+			err = i.dns.Delete(ctx, n.Name, assignedAddress)
+		} else {
+			err = i.dns.Upsert(ctx, n.Name, assignedAddress)
+		}
+		if err != nil {
+			logger.WithError(err).Warn("failed to update DNS record")
+		}
+	}
+	if i.ipam != nil {
+		var err error
+		if release {
+			err = i.ipam.Unregister(ctx, n.Name, assignedAddress)
+		} else {
+			err = i.ipam.Register(ctx, n.Name, assignedAddress)
+		}
+		if err != nil {
+			logger.WithError(err).Warn("failed to sync IP address with IPAM")
+		}
+	}
+	if i.firewall != nil {
+		var err error
+		if release {
+			err = i.firewall.Revoke(ctx, n.Name, assignedAddress)
+		} else {
+			err = i.firewall.Allow(ctx, n.Name, assignedAddress)
+		}
+		if err != nil {
+			logger.WithError(err).Warn("failed to sync IP address with firewall")
+		}
+	}
+	if i.egress != nil {
+		var err error
+		if release {
+			err = i.egress.Unlabel(ctx, n.Name)
+		} else {
+			err = i.egress.Label(ctx, n.Name, assignedAddress)
+		}
+		if err != nil {
+			logger.WithError(err).Warn("failed to update egress gateway labels")
+		}
+	}
+	if i.routes != nil {
+		var err error
+		if release {
+			err = i.routes.Delete(ctx, n.Name, assignedAddress)
+		} else {
+			err = i.routes.Add(ctx, n.Name, assignedAddress)
+		}
+		if err != nil {
+			logger.WithError(err).Warn("failed to update policy routes")
+		}
+	}
+	if i.snat != nil {
+		var err error
+		if release {
+			err = i.snat.Remove(ctx, assignedAddress)
+		} else {
+			err = i.snat.Apply(ctx, assignedAddress)
+		}
+		if err != nil {
+			logger.WithError(err).Warn("failed to program SNAT rules")
+		}
+	}
+	// announced last: the address is bound and routed by now; nothing to announce on release
+	if i.garp != nil && !release {
+		if err := i.garp.Announce(ctx, assignedAddress); err != nil {
+			logger.WithError(err).Warn("failed to send gratuitous announcements")
+		}
+	}
+	if i.readiness != nil {
+		held := assignedAddress
+		if release {
+			held = ""
+		}
+		i.readinessMutex.Lock()
+		i.readinessAddress = held
+		i.readinessMutex.Unlock()
+		i.syncReadinessGates(ctx, logger, n)
+	}
+	if i.condition != nil {
+		held := assignedAddress
+		if release {
+			held = ""
+		}
+		if err := i.condition.Sync(ctx, n.Name, held); err != nil {
+			logger.WithError(err).Warn("failed to update node condition")
+		}
+	}
+	if release {
+		metrics.ObserveReleasedAddress(string(n.Cloud), n.AddressPool, n.Pool, n.Name, n.Tenant, assignedAddress)
+		i.publish(ctx, log, n, &sink.Event{Type: sink.EventReleased, Address: assignedAddress})
+	} else {
+		metrics.ObserveAssignedAddress(string(n.Cloud), n.AddressPool, n.Pool, n.Name, n.Tenant, assignedAddress)
+		i.publish(ctx, log, n, &sink.Event{Type: sink.EventAssigned, Address: assignedAddress})
+	}
+}
+
+func newDynamicClient(log logrus.FieldLogger, cfg *config.Config) (dynamic.Interface, error) {
+	restconfig, err := retrieveKubeConfig(log, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving kube config")
+	}
+
+	client, err := dynamic.NewForConfig(restconfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing kubernetes dynamic client")
+	}
+	return client, nil
+}
+
+// syncReadinessGates sets the readiness gate of the pods of the node with the held address
+func (i *integrations) syncReadinessGates(ctx context.Context, log *logrus.Entry, n *types.Node) {
+	if err := i.readiness.Sync(ctx, n.Name, i.heldReadinessAddress()); err != nil {
+		log.WithError(err).Warn("failed to update pod readiness gates")
+	}
+}
+
+func (i *integrations) heldReadinessAddress() string {
+	i.readinessMutex.Lock()
+	defer i.readinessMutex.Unlock()
+	return i.readinessAddress
+}
+
+// watchReadinessGates sets the readiness gate of the pods scheduled on the node until the context is done; the pods are
+// watched once, whatever the assignments of the node
+func (i *integrations) watchReadinessGates(ctx context.Context, log *logrus.Entry, n *types.Node) {
+	if i.readiness == nil || !i.readinessWatching.CompareAndSwap(false, true) {
+		return
+	}
+	logger := log.WithField("node", n.Name)
+	err := i.readiness.Watch(ctx, n.Name, i.heldReadinessAddress, func(err error) {
+		logger.WithError(err).Warn("failed to update pod readiness gates")
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to watch the pods of the node, readiness gates of the pods scheduled later are not set")
+	}
+}
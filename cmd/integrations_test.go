@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/metrics"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_integrations_sync(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node"}
+	updater := &fakeUpdater{records: map[string]string{}}
+	syncer := &integrations{dns: updater}
+	ctx := context.Background()
+
+	// unknown address: integrations untouched
+	syncer.assigned(ctx, log, n, "")
+	syncer.released(ctx, log, n, "")
+	if updater.calls != 0 {
+		t.Errorf("sync() called the DNS updater %d times without an address, want 0", updater.calls)
+	}
+
+	syncer.assigned(ctx, log, n, "1.1.1.1")
+	if updater.records["test-node"] != "1.1.1.1" {
+		t.Errorf("assigned() DNS record = %q, want 1.1.1.1", updater.records["test-node"])
+	}
+	syncer.released(ctx, log, n, "1.1.1.1")
+	if _, ok := updater.records["test-node"]; ok || updater.calls != 2 {
+		t.Errorf("released() DNS records = %v after %d calls, want none after 2", updater.records, updater.calls)
+	}
+}
+
+func Test_integrations_tenant(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "tenant-node", Cloud: types.CloudProviderAWS, Pool: "payments-pool", AddressPool: "egress", Tenant: "payments"}
+	events := &recordingSink{}
+	syncer := &integrations{sink: events}
+	ctx := context.Background()
+	labels := []string{"aws", "egress", "payments-pool", "tenant-node", "payments", "1.1.1.1"}
+
+	syncer.assigned(ctx, log, n, "1.1.1.1")
+	if got := metrics.AssignedAddresses.Value(labels...); got != 1 {
+		t.Errorf("assigned() kubeip_assigned_address_info = %v, want 1", got)
+	}
+	syncer.released(ctx, log, n, "1.1.1.1")
+	if got := metrics.AssignedAddresses.Value(labels...); got != 0 {
+		t.Errorf("released() kubeip_assigned_address_info = %v, want 0", got)
+	}
+	if len(events.events) != 2 || events.events[0].Tenant != "payments" || events.events[1].Tenant != "payments" {
+		t.Errorf("sync() events = %+v, want two events of tenant payments", events.events)
+	}
+}
+
+func Test_integrations_readinessGates(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "node-1"}
+	client := fake.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "gated", Namespace: "default"},
+		Spec: v1.PodSpec{
+			NodeName:       "node-1",
+			ReadinessGates: []v1.PodReadinessGate{{ConditionType: nd.ReadinessGateConditionType}},
+		},
+	})
+	syncer := &integrations{readiness: nd.NewReadinessGate(client)}
+	status := func() v1.ConditionStatus {
+		pod, err := client.CoreV1().Pods("default").Get(context.Background(), "gated", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == nd.ReadinessGateConditionType {
+				return condition.Status
+			}
+		}
+		return v1.ConditionUnknown
+	}
+
+	syncer.assigned(context.Background(), log, n, "1.1.1.1")
+	if got := status(); got != v1.ConditionTrue {
+		t.Errorf("assigned() readiness gate = %v, want %v", got, v1.ConditionTrue)
+	}
+	syncer.released(context.Background(), log, n, "1.1.1.1")
+	if got := status(); got != v1.ConditionFalse {
+		t.Errorf("released() readiness gate = %v, want %v", got, v1.ConditionFalse)
+	}
+
+	// the pods of the node are watched once: a second watch returns at once
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		syncer.watchReadinessGates(ctx, log, n)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); !syncer.readinessWatching.Load(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("watchReadinessGates() did not start watching the pods")
+		}
+	}
+	syncer.watchReadinessGates(ctx, log, n)
+	cancel()
+	<-done
+}
+
+func Test_integrations_nodeCondition(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "node-1"}
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	syncer := &integrations{condition: nd.NewNodeCondition(client)}
+	status := func() v1.ConditionStatus {
+		node, err := client.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == nd.NodeConditionType {
+				return condition.Status
+			}
+		}
+		return v1.ConditionUnknown
+	}
+
+	syncer.pending(context.Background(), log, n)
+	if got := status(); got != v1.ConditionFalse {
+		t.Errorf("pending() node condition = %v, want %v", got, v1.ConditionFalse)
+	}
+	syncer.assigned(context.Background(), log, n, "1.1.1.1")
+	if got := status(); got != v1.ConditionTrue {
+		t.Errorf("assigned() node condition = %v, want %v", got, v1.ConditionTrue)
+	}
+	syncer.blocked(context.Background(), log, n, errors.New("permission denied"))
+	if got := status(); got != v1.ConditionFalse {
+		t.Errorf("blocked() node condition = %v, want %v", got, v1.ConditionFalse)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/pkg/address/fake"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+// newLoadTest returns the load test of the command flags against the simulated pool of the develop mode; the pool
+// defaults to the benchmark range
+func newLoadTest(c *cli.Context, cfg *config.Config) (*fake.LoadTest, error) {
+	if len(cfg.DevelopAddresses) == 0 {
+		cfg.DevelopAddresses = []string{defaultLoadTestAddresses}
+	}
+	assigner, err := newFakeAssigner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &fake.LoadTest{
+		Assigner:      assigner,
+		Nodes:         c.Int("loadtest-nodes"),
+		JoinRate:      c.Float64("loadtest-join-rate"),
+		LeaveRate:     c.Float64("loadtest-leave-rate"),
+		Workers:       c.Int("loadtest-workers"),
+		Attempts:      c.Int("loadtest-retry-attempts"),
+		RetryInterval: c.Duration("loadtest-retry-interval"),
+		Duration:      c.Duration("loadtest-duration"),
+	}, nil
+}
+
+// printLoadReport prints the report of the load test
+func printLoadReport(w io.Writer, report *fake.LoadReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:gomnd
+	fmt.Fprintf(tw, "elapsed\t%v\n", report.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "joined\t%d\n", report.Joined)
+	fmt.Fprintf(tw, "left\t%d\n", report.Left)
+	fmt.Fprintf(tw, "failed\t%d\n", report.Failed)
+	fmt.Fprintf(tw, "throughput\t%.1f assignments/s\n", report.Throughput)
+	fmt.Fprintf(tw, "queue latency\t%v\n", report.QueueLatency)
+	fmt.Fprintf(tw, "assign latency\t%v\n", report.AssignLatency)
+	tw.Flush()
+}
+
+func loadTestCmd(c *cli.Context) error {
+	ctx := signals.SetupSignalHandler()
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	cfg := config.NewConfig(c)
+
+	loadTest, err := newLoadTest(c, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing load test")
+		return err
+	}
+	log.WithFields(logrus.Fields{
+		"nodes":      loadTest.Nodes,
+		"join-rate":  loadTest.JoinRate,
+		"leave-rate": loadTest.LeaveRate,
+		"workers":    loadTest.Workers,
+		"duration":   loadTest.Duration,
+	}).Info("starting load test")
+	report, err := loadTest.Run(ctx)
+	if err != nil {
+		log.WithError(err).Error("error running load test")
+		return errors.Wrap(err, "running load test")
+	}
+	printLoadReport(os.Stdout, report)
+	return nil
+}
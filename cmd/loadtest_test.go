@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/pkg/address/fake"
+)
+
+func Test_printLoadReport(t *testing.T) {
+	var buf bytes.Buffer
+	printLoadReport(&buf, &fake.LoadReport{
+		Elapsed:       time.Minute,
+		Joined:        120,
+		Left:          20,
+		Failed:        1,
+		Throughput:    2,
+		QueueLatency:  fake.Percentiles{P50: time.Millisecond, P90: 2 * time.Millisecond, P99: 3 * time.Millisecond, Max: 4 * time.Millisecond},
+		AssignLatency: fake.Percentiles{P50: time.Second, P90: time.Second, P99: time.Second, Max: time.Second},
+	})
+	want := `elapsed          1m0s
+joined           120
+left             20
+failed           1
+throughput       2.0 assignments/s
+queue latency    p50=1ms p90=2ms p99=3ms max=4ms
+assign latency   p50=1s p90=1s p99=1s max=1s
+`
+	if buf.String() != want {
+		t.Errorf("printLoadReport() = %q, want %q", buf.String(), want)
+	}
+}
@@ -3,15 +3,29 @@ package main
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"os"
 	"runtime"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/admin"
+	"github.com/doitintl/kubeip/internal/claim"
+	"github.com/doitintl/kubeip/internal/cloud"
 	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/events"
+	"github.com/doitintl/kubeip/internal/health"
 	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/doitintl/kubeip/internal/metrics"
 	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/probe"
+	"github.com/doitintl/kubeip/internal/schedule"
+	"github.com/doitintl/kubeip/internal/sink"
 	"github.com/doitintl/kubeip/internal/types"
+	"github.com/doitintl/kubeip/pkg/address/fake"
+	"github.com/doitintl/kubeip/pkg/ipam"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -24,10 +38,14 @@ import (
 type contextKey string
 
 const (
-	developModeKey       contextKey = "develop-mode"
-	unassignTimeout                 = 5 * time.Minute
-	kubeipLockName                  = "kubeip-lock"
-	defaultLeaseDuration            = 5
+	developModeKey                contextKey = "develop-mode"
+	unassignTimeout                          = 5 * time.Minute
+	recordStatusTimeout                      = 30 * time.Second
+	maintenanceWindowPollInterval            = time.Minute
+	adminRequestInterval                     = 5 * time.Second
+	kubeipLockName                           = "kubeip-lock"
+	eventsLeaseName                          = "kubeip-events"
+	defaultLeaseDuration                     = 5
 )
 
 var (
@@ -36,12 +54,49 @@ var (
 	gitCommit    string
 	gitBranch    string
 	errEmptyPath = errors.New("empty path")
+	// errClaimUnsupported is returned when an address is claimed for a node of a cloud provider not assigning given addresses
+	errClaimUnsupported = errors.New("cloud provider does not support KubeIPClaim")
 )
 
 const (
 	// DefaultRetryInterval is the default retry interval
 	defaultRetryInterval = time.Minute
 	defaultRetryAttempts = 60
+	// defaultDrainTimeout leaves room for the release on exit within the default termination grace period (30s)
+	defaultDrainTimeout = 20 * time.Second
+	// defaultHandoffTimeout is the default time a replacement node waits for the replaced node to release its address
+	defaultHandoffTimeout = 5 * time.Minute
+	// defaultWaitForNodeTimeout is the default time the assignment waits for the node conditions and labels
+	defaultWaitForNodeTimeout = 10 * time.Minute
+	// policies of the nodes of an unsupported cloud provider
+	unsupportedProviderFail   = "fail"
+	unsupportedProviderIgnore = "ignore"
+	// defaultNodeResyncInterval is the default interval of the checks of the node conditions and labels besides the
+	// watched node changes
+	defaultNodeResyncInterval = 30 * time.Second
+	// defaultVerifyTimeout is the default time the egress verification waits for the traffic to egress from the address
+	defaultVerifyTimeout = 2 * time.Minute
+	// verifyPollInterval is the interval of the egress verification probes, each bounded by the request timeout
+	verifyPollInterval   = 5 * time.Second
+	verifyRequestTimeout = 10 * time.Second
+	// egressDriftAction reconciles the assignment once the traffic of the node drifts to another address
+	egressDriftAction = "egress-drift"
+	// preDrainPollInterval is the interval of the checks of the drain taints of the node
+	preDrainPollInterval = 5 * time.Second
+	// preDrainAction releases the static public IP address before the drain of the node
+	preDrainAction = "pre-drain"
+	// pods outside the host network are one hop away from the instance metadata
+	defaultIMDSHopLimit = 2
+	// defaultGARPCount is the default number of gratuitous announcements: switches may miss the first one
+	defaultGARPCount = 3
+	// defaultAddressPool is the address pool name of the metrics of a configuration with a single pool
+	defaultAddressPool = "default"
+	// defaultDevelopLatency is the simulated latency of the cloud provider calls in develop mode
+	defaultDevelopLatency = 500 * time.Millisecond
+	// defaultDevelopAddresses are the simulated addresses of the develop mode (TEST-NET-3 documentation range)
+	defaultDevelopAddresses = "203.0.113.0/28"
+	// defaultLoadTestAddresses are the simulated addresses of the load test (benchmark range), for 16k nodes
+	defaultLoadTestAddresses = "198.18.0.0/18"
 )
 
 func prepareLogger(level string, json bool) *logrus.Entry {
@@ -82,18 +137,56 @@ func prepareLogger(level string, json bool) *logrus.Entry {
 	return log
 }
 
-func assignAddress(c context.Context, log *logrus.Entry, client kubernetes.Interface, assigner address.Assigner, node *types.Node, cfg *config.Config) (string, error) {
+// buildInfo identifies the running binary, e.g. on clusters mixing node architectures
+func buildInfo() logrus.Fields {
+	return logrus.Fields{
+		"platform": runtime.GOOS + "/" + runtime.GOARCH,
+		"go":       runtime.Version(),
+		"commit":   gitCommit,
+		"built":    buildDate,
+	}
+}
+
+func assignAddress(c context.Context, log *logrus.Entry, client kubernetes.Interface, assigner address.Assigner, node *types.Node, cfg *config.Config, syncer *integrations) (string, error) {
+	// the trace ID of the assignment, logged and recorded as exemplar of its metrics, links both
+	c = metrics.WithTraceID(c, metrics.NewTraceID())
+	log = log.WithField("trace_id", metrics.TraceID(c))
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	start := time.Now()
+	result := metrics.ResultFailure
+	defer func() {
+		metrics.ObserveAssignment(ctx, string(node.Cloud), node.AddressPool, node.Pool, node.Name, result, time.Since(start))
+	}()
+
 	// ticker for retry interval
 	ticker := time.NewTicker(cfg.RetryInterval)
 	defer ticker.Stop()
 
+	assign, err := assignFunc(log, assigner, node, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	if err = checkRegion(node, cfg); err != nil {
+		result = metrics.ResultBlocked
+		return "", err
+	}
+
 	// create new cluster wide lock
 	lock := lease.NewKubeLeaseLock(client, kubeipLockName, cfg.LeaseNamespace, node.Instance, cfg.LeaseDuration)
 
-	for retryCounter := 0; retryCounter <= cfg.RetryAttempts; retryCounter++ {
+	// a restarted agent resumes the retries recorded by the previous run
+	recorder := nd.NewStatusRecorder(client)
+	retryCounter, err := resumeRetries(ctx, log, recorder, node, cfg)
+	if err != nil {
+		return "", err
+	}
+	// a reconcile request lists the addresses again without waiting for the retry, e.g. once new addresses are reserved
+	refresh := watchReconcile(ctx, client, node, cfg)
+
+	for ; retryCounter <= cfg.RetryAttempts; retryCounter++ {
 		log.WithFields(logrus.Fields{
 			"node":           node.Name,
 			"instance":       node.Instance,
@@ -101,46 +194,237 @@ func assignAddress(c context.Context, log *logrus.Entry, client kubernetes.Inter
 			"retry-counter":  retryCounter,
 			"retry-attempts": cfg.RetryAttempts,
 		}).Debug("assigning static public IP address to node")
-		assignedAddress, err := func(ctx context.Context) (string, error) {
-			if err := lock.Lock(ctx); err != nil {
-				return "", errors.Wrap(err, "failed to acquire lock")
+		// the failures of the cloud provider calls only, made once locked, mark the provider unavailable
+		assignedAddress, locked, err := lockedAssign(c, log, lock, assign, cfg)
+		if err == nil || errors.Is(err, address.ErrStaticIPAlreadyAssigned) {
+			health.Default.ProviderAvailable(string(node.Cloud))
+			if err != nil {
+				replaced, policyErr := applyNonPoolPolicy(ctx, log, assigner, node, assignedAddress, cfg, syncer)
+				if policyErr != nil {
+					return "", policyErr
+				}
+				// assign an address of the pool right away
+				if replaced {
+					continue
+				}
 			}
-			log.Debug("lock acquired")
-			defer func() {
-				lock.Unlock(ctx) //nolint:errcheck
-				log.Debug("lock released")
-			}()
-			assignedAddress, err := assigner.Assign(ctx, node.Instance, node.Zone, cfg.Filter, cfg.OrderBy)
+			result = metrics.ResultSuccess
 			if err != nil {
-				return "", err //nolint:wrapcheck
+				result = metrics.ResultAlreadyAssigned
 			}
 			return assignedAddress, nil
-		}(c)
-		if err == nil || errors.Is(err, address.ErrStaticIPAlreadyAssigned) {
-			return assignedAddress, nil
 		}
 
-		log.WithError(err).WithFields(logrus.Fields{
-			"node":     node.Name,
-			"instance": node.Instance,
-		}).Error("failed to assign static public IP address to node")
+		if err = failedAttempt(ctx, log, recorder, node, retryCounter+1, locked, err); err != nil {
+			result = metrics.ResultBlocked
+			return "", err
+		}
 		log.Infof("retrying after %v", cfg.RetryInterval)
-
-		select {
-		case <-ticker.C:
-			continue
-		case <-ctx.Done():
-			// If the context is done, return an error indicating that the operation was cancelled
-			return "", errors.Wrap(ctx.Err(), "context cancelled while assigning addresses")
+		if err = waitRetry(ctx, log, ticker, refresh, node); err != nil {
+			return "", err
 		}
 	}
 	return "", errors.New("reached maximum number of retries")
 }
 
-func waitForAddressToBeReported(c context.Context, log *logrus.Entry, explorer nd.Explorer, node *types.Node, assignedAddress string, cfg *config.Config) error {
+// watchReconcile watches the reconcile requests of the admin API for the node until the context is done; nil with the
+// admin API disabled, so the node is not watched at all
+func watchReconcile(ctx context.Context, client kubernetes.Interface, node *types.Node, cfg *config.Config) <-chan struct{} {
+	if cfg.AdminAddress == "" {
+		return nil
+	}
+	return admin.WatchReconcile(ctx, client, node.Name)
+}
+
+// lockedAssign assigns the address holding the cluster wide lock; returns whether the lock was acquired: the assignment
+// then reached the cloud provider
+func lockedAssign(ctx context.Context, log *logrus.Entry, lock lease.KubeLock, assign func(ctx context.Context) (string, error), cfg *config.Config) (string, bool, error) {
+	lockStart := time.Now()
+	err := lock.Lock(ctx)
+	metrics.ObservePhase(ctx, metrics.PhaseLock, lockStart)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to acquire lock")
+	}
+	lockedAt := time.Now()
+	log.Debug("lock acquired")
+	// a started assignment is drained on shutdown rather than cancelled mid-association
+	drainCtx, drainCancel := drainContext(ctx, cfg.DrainTimeout)
+	defer drainCancel()
+	defer func() {
+		lock.Unlock(drainCtx) //nolint:errcheck
+		log.Debug("lock released")
+	}()
+	// the address already held by the node comes with ErrStaticIPAlreadyAssigned
+	assigned, err := assign(drainCtx)
+	if err == nil {
+		// a new address holds the lock for the rollout pacing: the agents waiting for it mutate one by one
+		pace(ctx, cfg.RolloutPacing, lockedAt)
+	}
+	return assigned, true, err
+}
+
+// failedAttempt logs the failed assignment attempt of the node and records its retry; returns the error if permanent:
+// retrying cannot fix an invalid filter, denied permissions or a node outside the region of the addresses
+func failedAttempt(ctx context.Context, log *logrus.Entry, recorder nd.StatusRecorder, node *types.Node, attempts int, locked bool, err error) error {
+	log.WithError(err).WithFields(logrus.Fields{
+		"node":     node.Name,
+		"instance": node.Instance,
+	}).Error("failed to assign static public IP address to node")
+	if address.IsPermanent(err) {
+		return err
+	}
+	if locked && address.IsUnavailable(err) {
+		health.Default.ProviderUnavailable(string(node.Cloud), time.Now())
+	}
+	recordRetry(ctx, log, recorder, node, attempts, err)
+	return nil
+}
+
+// waitRetry waits for the retry interval or a reconcile request, failing once the context is done
+func waitRetry(ctx context.Context, log *logrus.Entry, ticker *time.Ticker, refresh <-chan struct{}, node *types.Node) error {
+	select {
+	case <-ticker.C:
+	case <-refresh:
+		log.WithField("node", node.Name).Info("reconcile requested, listing the static public IP addresses again")
+	case <-ctx.Done():
+		// If the context is done, return an error indicating that the operation was cancelled
+		return errors.Wrap(ctx.Err(), "context cancelled while assigning addresses")
+	}
+	return nil
+}
+
+// checkRegion fails with ErrRegionMismatch for a cloud node outside the configured region of the addresses
+func checkRegion(node *types.Node, cfg *config.Config) error {
+	region := cfg.Region
+	switch node.Cloud {
+	case types.CloudProviderAWS:
+		if cfg.AWSRegion != "" {
+			region = cfg.AWSRegion
+		}
+	case types.CloudProviderGCP, types.CloudProviderOCI:
+	default:
+		return nil
+	}
+	if region == "" || node.Region == "" || node.Region == region {
+		return nil
+	}
+	return errors.Wrapf(address.ErrRegionMismatch, "node %s in region %s, addresses in region %s", node.Name, node.Region, region)
+}
+
+// resumeRetries returns the failed attempts recorded for the pending assignment of the node and waits for the rest of
+// the retry interval since the last one: a crash-looping agent keeps its pace instead of retrying from the first attempt
+func resumeRetries(ctx context.Context, log *logrus.Entry, recorder nd.StatusRecorder, node *types.Node, cfg *config.Config) (int, error) {
+	status, err := recorder.GetStatus(ctx, node.Name)
+	if err != nil || status.RetryAttempts == 0 {
+		return 0, nil
+	}
+	attempts := status.RetryAttempts
+	// the previous runs used up the retries: start over, still paced by the retry interval
+	if attempts > cfg.RetryAttempts {
+		attempts = 0
+	}
+	logger := log.WithFields(logrus.Fields{
+		"node":           node.Name,
+		"retry-counter":  attempts,
+		"retry-attempts": cfg.RetryAttempts,
+		"last-error":     status.LastError,
+	})
+	wait := cfg.RetryInterval - time.Since(status.LastRetryTime)
+	if wait <= 0 {
+		logger.Info("resuming static public IP address assignment retries")
+		return attempts, nil
+	}
+	logger.Infof("resuming static public IP address assignment retries after %v", wait.Round(time.Second))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return attempts, nil
+	case <-ctx.Done():
+		return 0, errors.Wrap(ctx.Err(), "context cancelled while resuming retries")
+	}
+}
+
+// recordRetry records the failed attempts of the pending assignment of the node; failures are logged and do not
+// interrupt the retries
+func recordRetry(ctx context.Context, log *logrus.Entry, recorder nd.StatusRecorder, node *types.Node, attempts int, err error) {
+	recordCtx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
+
+	if recordErr := recorder.SetRetry(recordCtx, node.Name, attempts, err.Error()); recordErr != nil {
+		log.WithError(recordErr).WithField("node", node.Name).Warn("failed to record assignment retry")
+	}
+}
+
+// poolTenant returns the tenant of the node pool from the <pool>=<tenant> entries, empty if the pool has no tenant
+func poolTenant(entries []string, pool string) (string, error) {
+	tenant := ""
+	for _, entry := range entries {
+		p, t, ok := strings.Cut(entry, "=")
+		if !ok || p == "" || t == "" {
+			return "", errors.Errorf("invalid pool tenant %q, want <pool>=<tenant>", entry)
+		}
+		if p == pool && tenant == "" {
+			tenant = t
+		}
+	}
+	return tenant, nil
+}
+
+// applyClaim sets the address claimed for the node by the KubeIPClaim matching its labels, if any
+func applyClaim(ctx context.Context, log *logrus.Entry, finder claim.Finder, n *types.Node) error {
+	c, err := finder.Find(ctx, n.Labels)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if c == nil {
+		return nil
+	}
+	log.WithFields(logrus.Fields{
+		"node":    n.Name,
+		"claim":   c.Name,
+		"address": c.Address,
+	}).Info("static public IP address claimed for node")
+	n.ClaimedAddress = c.Address
+	return nil
+}
+
+// assignFunc returns the assignment of the address claimed for the node, or of an address of the pool without claim
+func assignFunc(log *logrus.Entry, assigner address.Assigner, node *types.Node, cfg *config.Config) (func(ctx context.Context) (string, error), error) {
+	// the held address is only known from the assignment (ErrStaticIPAlreadyAssigned)
+	decision, err := ipam.Decide(ipam.Request{Claimed: node.ClaimedAddress, Filter: cfg.Filter, OrderBy: cfg.OrderBy})
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if decision.Action == ipam.ActionAssign {
+		return func(ctx context.Context) (string, error) {
+			return assigner.Assign(ctx, node.Instance, node.Zone, decision.Filter, decision.OrderBy) //nolint:wrapcheck
+		}, nil
+	}
+	claimer, ok := assigner.(address.Claimer)
+	if !ok {
+		return nil, errors.Wrapf(errClaimUnsupported, "address %s claimed for node %s", node.ClaimedAddress, node.Name)
+	}
+	return func(ctx context.Context) (string, error) {
+		held, err := claimer.Claim(ctx, node.Instance, node.Zone, decision.Address, decision.Filter)
+		if errors.Is(err, address.ErrStaticIPAlreadyAssigned) && held != node.ClaimedAddress {
+			log.WithFields(logrus.Fields{
+				"node":            node.Name,
+				"address":         held,
+				"claimed-address": node.ClaimedAddress,
+			}).Warn("node holds another static public IP address than claimed, release it to assign the claimed address")
+		}
+		return held, err //nolint:wrapcheck
+	}, nil
+}
+
+func waitForAddressToBeReported(c context.Context, log *logrus.Entry, explorer nd.Explorer, assigner address.Assigner, node *types.Node, assignedAddress string, cfg *config.Config) error {
 	ctx, cancel := context.WithCancel(c)
 	defer cancel()
 
+	// the wait is a phase of its own: the assignment is already recorded
+	defer metrics.ObservePhase(ctx, metrics.PhaseReport, time.Now())
+
 	// ticker for retry interval
 	ticker := time.NewTicker(cfg.RetryInterval)
 	defer ticker.Stop()
@@ -154,31 +438,29 @@ func waitForAddressToBeReported(c context.Context, log *logrus.Entry, explorer n
 			"retry-attempts": cfg.RetryAttempts,
 		}).Debug("Waiting for node to report assigned address")
 
-		nodeInfo, err := explorer.GetNode(ctx, node.Name)
-		if err == nil {
-			for _, ip := range nodeInfo.ExternalIPs {
-				if ip.String() == assignedAddress {
-					log.WithFields(logrus.Fields{
-						"node":           node.Name,
-						"instance":       node.Instance,
-						"address":        assignedAddress,
-						"retry-counter":  retryCounter,
-						"retry-attempts": cfg.RetryAttempts,
-					}).Info("Node is reporting assigned address")
-					return nil
-				}
-			}
+		reported, err := addressReported(ctx, explorer, assigner, node, assignedAddress)
+		switch {
+		case err != nil:
 			log.WithError(err).WithFields(logrus.Fields{
 				"node":     node.Name,
 				"instance": node.Instance,
 				"address":  assignedAddress,
-			}).Warn("Node is not yet reporting the assigned address")
-		} else {
-			log.WithError(err).WithFields(logrus.Fields{
+			}).Error("failed to check if node is reporting the assigned address")
+		case reported:
+			log.WithFields(logrus.Fields{
+				"node":           node.Name,
+				"instance":       node.Instance,
+				"address":        assignedAddress,
+				"retry-counter":  retryCounter,
+				"retry-attempts": cfg.RetryAttempts,
+			}).Info("Node is reporting assigned address")
+			return nil
+		default:
+			log.WithFields(logrus.Fields{
 				"node":     node.Name,
 				"instance": node.Instance,
 				"address":  assignedAddress,
-			}).Error("failed to check if node is reporting the assigned address")
+			}).Warn("Node is not yet reporting the assigned address")
 		}
 
 		log.Infof("retrying after %v", cfg.RetryInterval)
@@ -194,102 +476,512 @@ func waitForAddressToBeReported(c context.Context, log *logrus.Entry, explorer n
 	return errors.New("reached maximum number of retries")
 }
 
-func run(c context.Context, log *logrus.Entry, cfg *config.Config) error {
-	ctx, cancel := context.WithCancel(c)
+// addressReported checks if the node reports the assigned address as external IP; addresses announced by the assigner
+// itself (MetalLB) are not reported by the node, the assignment is confirmed from the announcement
+func addressReported(ctx context.Context, explorer nd.Explorer, assigner address.Assigner, node *types.Node, assignedAddress string) (bool, error) {
+	if announcer, ok := assigner.(address.Announcer); ok {
+		return announcer.Announced(ctx, node.Instance, assignedAddress) //nolint:wrapcheck
+	}
+	nodeInfo, err := explorer.GetNode(ctx, node.Name)
+	if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+	return nodeHasExternalIP(nodeInfo, assignedAddress), nil
+}
+
+// serveAdmin serves the admin API if configured and returns the admin actions handed over to the agent of the node;
+// nil if the admin API is disabled; a failure to serve is logged and does not interrupt the agent
+func serveAdmin(ctx context.Context, log *logrus.Entry, cfg *config.Config, client kubernetes.Interface, n *types.Node) <-chan string {
+	if cfg.AdminAddress == "" {
+		return nil
+	}
+	requests := admin.NewNodeRequests(client, n.Name)
+	go func() {
+		if err := admin.Serve(ctx, log, cfg, client, requests); err != nil {
+			log.WithError(err).Error("serving admin API failed")
+		}
+	}()
+	actions := make(chan string)
+	go admin.Watch(ctx, log, requests, adminRequestInterval, actions)
+	return actions
+}
+
+// verifyEgress checks end to end that the traffic of the node egresses from the assigned address before the node is
+// published as ready: the cloud call may succeed while the routing still uses the previous address
+func verifyEgress(ctx context.Context, log *logrus.Entry, prober probe.Prober, n *types.Node, assignedAddress string, timeout time.Duration) error {
+	if assignedAddress == "" {
+		return nil
+	}
+	logger := log.WithFields(logrus.Fields{
+		"node":    n.Name,
+		"address": assignedAddress,
+	})
+	logger.Info("verifying the egress of the static public IP address")
+	defer metrics.ObservePhase(ctx, metrics.PhaseVerify, time.Now())
+	verifyCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
+	if err := probe.Verify(verifyCtx, prober, assignedAddress, verifyPollInterval); err != nil {
+		return errors.Wrapf(err, "traffic does not egress from %s after %v", assignedAddress, timeout)
+	}
+	logger.Info("traffic egresses from the static public IP address")
+	return nil
+}
 
-	// add debug mode to context
-	if cfg.DevelopMode {
-		ctx = context.WithValue(ctx, developModeKey, true)
+// watchEgress forwards the admin actions and probes every interval that the traffic of the node still egresses from the
+// address recorded for it; a drift sets the egress drift metric and, with reconcile, hands over the reconciliation of
+// the assignment once per drift; probe failures leave the metric as is
+func watchEgress(ctx context.Context, log *logrus.Entry, prober probe.Prober, recorder nd.StatusRecorder, n *types.Node, actions <-chan string, interval time.Duration, reconcile bool) <-chan string {
+	forwarded := make(chan string)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		drifted := false
+		for {
+			var action string
+			select {
+			case <-ctx.Done():
+				return
+			case action = <-actions:
+			case <-ticker.C:
+				// the recorded address follows the reassignments and releases of the node
+				expected := recordedAddress(ctx, log, recorder, n)
+				if expected == "" {
+					continue
+				}
+				egress, err := prober.EgressIP(ctx)
+				if err != nil {
+					log.WithError(err).WithField("node", n.Name).Warn("failed to probe the egress of the node")
+					continue
+				}
+				drift := egress != expected
+				metrics.ObserveEgressDrift(string(n.Cloud), n.AddressPool, n.Pool, n.Name, drift)
+				if !drift {
+					drifted = false
+					continue
+				}
+				log.WithFields(logrus.Fields{"node": n.Name, "address": expected, "egress": egress}).Warn("traffic egresses from another address than the static public IP address")
+				if drifted || !reconcile {
+					drifted = true
+					continue
+				}
+				drifted = true
+				action = egressDriftAction
+			}
+			select {
+			case forwarded <- action:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return forwarded
+}
+
+// watchPreDrain forwards the admin actions and hands over the release of the static public IP address once a termination
+// handler announces the drain of the node, so that the address is back in the pool before the replacement node needs it
+func watchPreDrain(ctx context.Context, log *logrus.Entry, detector nd.DrainDetector, n *types.Node, actions <-chan string, interval time.Duration) <-chan string {
+	forwarded := make(chan string)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		checks := ticker.C
+		for {
+			var action string
+			select {
+			case <-ctx.Done():
+				return
+			case action = <-actions:
+			case <-checks:
+				taint, err := detector.Draining(ctx, n.Name)
+				if err != nil {
+					log.WithError(err).WithField("node", n.Name).Warn("failed to check node drain")
+					continue
+				}
+				if taint == "" {
+					continue
+				}
+				log.WithFields(logrus.Fields{"node": n.Name, "taint": taint}).Info("node about to be drained")
+				// the address is released once: the node does not come back from the drain
+				checks = nil
+				action = preDrainAction
+			}
+			select {
+			case forwarded <- action:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return forwarded
+}
+
+// releaseIP releases the static public IP address of the node; it completes on shutdown, up to the unassign timeout
+func releaseIP(ctx context.Context, assigner address.Assigner, n *types.Node) error {
+	releaseCtx, releaseCancel := detachedContext(ctx, unassignTimeout)
+	defer releaseCancel()
+
+	if err := assigner.Unassign(releaseCtx, n.Instance, n.Zone); err != nil {
+		metrics.ObserveRelease(string(n.Cloud), n.AddressPool, n.Pool, n.Name, metrics.ResultFailure)
+		return errors.Wrap(err, "failed to release static public IP address")
 	}
-	log.WithField("develop-mode", cfg.DevelopMode).Infof("kubeip agent started")
 
-	restconfig, err := retrieveKubeConfig(log, cfg)
+	metrics.ObserveRelease(string(n.Cloud), n.AddressPool, n.Pool, n.Name, metrics.ResultSuccess)
+	return nil
+}
+
+// logCandidate logs the static public IP address the assigner would pick for the node, without changing anything (dry-run)
+func logCandidate(ctx context.Context, log *logrus.Entry, assigner address.Assigner, n *types.Node, cfg *config.Config) {
+	logger := log.WithFields(logrus.Fields{
+		"node":     n.Name,
+		"pool":     n.Pool,
+		"filter":   cfg.Filter,
+		"order-by": cfg.OrderBy,
+	})
+	candidate, err := assigner.Candidate(ctx, n.Instance, n.Zone, cfg.Filter, cfg.OrderBy)
 	if err != nil {
-		return errors.Wrap(err, "retrieving kube config")
+		logger.WithError(err).Warn("dry-run: node is not in the canary, no static public IP address would be assigned")
+		return
 	}
+	logger.WithField("address", candidate).Info("dry-run: node is not in the canary, would assign static public IP address")
+}
 
-	clientset, err := kubernetes.NewForConfig(restconfig)
-	if err != nil {
-		return errors.Wrap(err, "initializing kubernetes client")
+// releaseAddress releases the static public IP address of the node, records the status and withdraws the released
+// address from the integrations; shared by the agent and the release command
+func releaseAddress(ctx context.Context, log *logrus.Entry, assigner address.Assigner, recorder nd.StatusRecorder, syncer *integrations, n *types.Node, releasedAddress string) error {
+	if err := releaseIP(ctx, assigner, n); err != nil {
+		return err
 	}
+	recordStatus(ctx, log, recorder, withOperation(assigner, n, &types.AssignmentStatus{Node: n.Name, Pool: n.Pool}))
+	syncer.released(ctx, log, n, releasedAddress)
+	return nil
+}
 
-	explorer := nd.NewExplorer(clientset)
-	n, err := explorer.GetNode(ctx, cfg.NodeName)
+// recordedAddress returns the address recorded in the node assignment status; empty if unknown
+func recordedAddress(ctx context.Context, log *logrus.Entry, recorder nd.StatusRecorder, n *types.Node) string {
+	status, err := recorder.GetStatus(ctx, n.Name)
 	if err != nil {
-		return errors.Wrap(err, "getting node")
+		log.WithError(err).WithField("node", n.Name).Warn("failed to get assignment status")
+		return ""
 	}
-	log.WithField("node", n).Debug("node discovery done")
+	return status.Address
+}
 
-	// assign static public IP address with retry (interval and attempts)
-	assigner, err := address.NewAssigner(ctx, log, n.Cloud, cfg)
-	if err != nil {
-		return errors.Wrap(err, "initializing assigner")
+// watchAddressChanges blocks until the context is done; when the watcher reports a change of the node address by an
+// external actor, the assignment is reconciled immediately; admin actions reconcile or release the assignment on
+// request; returns the address finally assigned to the node
+func watchAddressChanges(ctx context.Context, log *logrus.Entry, watcher events.Watcher, actions <-chan string, n *types.Node, assignedAddress string, reconcile, release func(current string) string) string {
+	// a nil channel never delivers: no change detection
+	var changes chan events.Change
+	if watcher != nil {
+		changes = make(chan events.Change)
+		go func() {
+			if err := watcher.Watch(ctx, n.Instance, changes); err != nil {
+				log.WithError(err).Error("watching cloud change notifications failed")
+			}
+		}()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return assignedAddress
+		case action := <-actions:
+			logger := log.WithFields(logrus.Fields{"node": n.Name, "address": assignedAddress, "action": action})
+			switch action {
+			case admin.ActionReconcile:
+				logger.Info("reconciling static public IP address on admin request")
+				assignedAddress = reconcile(assignedAddress)
+			case admin.ActionRelease:
+				logger.Info("releasing static public IP address on admin request")
+				assignedAddress = release(assignedAddress)
+			case egressDriftAction:
+				logger.Info("reconciling static public IP address on egress drift")
+				assignedAddress = reconcile(assignedAddress)
+			case preDrainAction:
+				logger.Info("releasing static public IP address before node drain")
+				assignedAddress = release(assignedAddress)
+			default:
+				logger.Warn("ignoring unknown admin request")
+			}
+		case change := <-changes:
+			if !change.Affects(n.Instance, assignedAddress) {
+				continue
+			}
+			log.WithFields(logrus.Fields{
+				"node":    n.Name,
+				"address": assignedAddress,
+				"action":  change.Action,
+			}).Info("static public IP address changed by external actor, reconciling")
+			assignedAddress = reconcile(assignedAddress)
+		}
 	}
+}
+
+// heldAddress returns the public IP address reported by the node (static or ephemeral); empty if none
+func heldAddress(n *types.Node) string {
+	if len(n.ExternalIPs) == 0 {
+		return ""
+	}
+	return n.ExternalIPs[0].String()
+}
 
-	assignedAddress, err := assignAddress(ctx, log, clientset, assigner, n, cfg)
+// isReassignment checks if assigning the node swaps the held public IP address for a different one; nodes without a
+// public IP address or already holding the candidate address are not swapped; if the candidate cannot be determined,
+// the node is handled as not swapped (the assignment reports the failure)
+func isReassignment(ctx context.Context, log *logrus.Entry, assigner address.Assigner, n *types.Node, held string, cfg *config.Config) bool {
+	if held == "" {
+		return false
+	}
+	candidate, err := assigner.Candidate(ctx, n.Instance, n.Zone, cfg.Filter, cfg.OrderBy)
 	if err != nil {
-		return errors.Wrap(err, "assigning static public IP address")
+		log.WithError(err).WithField("node", n.Name).Warn("failed to get candidate static public IP address, assuming no swap")
+		return false
 	}
+	return candidate != "" && candidate != held
+}
 
-	if cfg.TaintKey != "" {
-		if err := waitForAddressToBeReported(ctx, log, explorer, n, assignedAddress, cfg); err != nil {
-			return errors.Wrap(err, "waiting for node to report assigned address")
-		}
+// waitForSwapWindow waits for a maintenance window before swapping the public IP address held by the node; other
+// assignments go ahead immediately
+func waitForSwapWindow(ctx context.Context, log *logrus.Entry, windows schedule.Windows, assigner address.Assigner, n *types.Node, held string, cfg *config.Config) error {
+	if len(windows) == 0 || !isReassignment(ctx, log, assigner, n, held, cfg) {
+		return nil
+	}
+	return waitForMaintenanceWindow(ctx, log, windows, maintenanceWindowPollInterval)
+}
+
+// waitForNodeBootstrap blocks until the node meets the requirements (e.g. Ready, labeled by the CNI) or the timeout
+// expires: the assignment then goes ahead anyway rather than leaving the node without its address
+func waitForNodeBootstrap(ctx context.Context, log *logrus.Entry, waiter nd.BootstrapWaiter, n *types.Node, requirements []nd.Requirement, timeout time.Duration) error {
+	if len(requirements) == 0 {
+		return nil
+	}
+	logger := log.WithFields(logrus.Fields{
+		"node":         n.Name,
+		"requirements": requirements,
+	})
+	logger.Info("waiting for node bootstrap before assigning static public IP address")
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := waiter.Wait(waitCtx, n.Name, requirements)
+	switch {
+	case err == nil:
+		logger.Info("node bootstrapped, assigning static public IP address")
+		return nil
+	case ctx.Err() != nil:
+		return errors.Wrap(ctx.Err(), "context cancelled while waiting for node bootstrap")
+	case waitCtx.Err() != nil:
+		logger.WithField("timeout", timeout).Warn("node not bootstrapped in time, assigning static public IP address anyway")
+		return nil
+	default:
+		return err //nolint:wrapcheck
+	}
+}
 
-		logger := log.WithField("taint-key", cfg.TaintKey)
-		tainter := nd.NewTainter(clientset)
+// waitForMaintenanceWindow blocks until one of the maintenance windows is open (UTC)
+func waitForMaintenanceWindow(ctx context.Context, log *logrus.Entry, windows schedule.Windows, interval time.Duration) error {
+	if windows.Contains(time.Now().UTC()) {
+		return nil
+	}
+	log.WithField("maintenance-windows", windows).Info("static public IP address reassignment queued until the next maintenance window")
 
-		didRemoveTaint, err := tainter.RemoveTaintKey(ctx, n, cfg.TaintKey)
-		if err != nil {
-			logger.Error("removing taint key failed, releasing static public IP address")
-			if releaseErr := releaseIP(assigner, n); releaseErr != nil { //nolint:contextcheck
-				log.WithError(releaseErr).Error("releasing static public IP address after taint key removal failed")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if windows.Contains(time.Now().UTC()) {
+				log.Info("maintenance window open, reassigning static public IP address")
+				return nil
 			}
-			return errors.Wrap(err, "removing node taint key")
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "context cancelled while waiting for maintenance window")
 		}
+	}
+}
 
-		if didRemoveTaint {
-			logger.Info("taint key removed successfully")
-		} else {
-			logger.Warning("taint key not present on node, skipped removal")
+// releaseIgnored releases the static public IP address held by an ignored node and withdraws it from the integrations;
+// failures are logged
+func releaseIgnored(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, newAssigner assignerFactory, n *types.Node, cfg *config.Config) {
+	logger := log.WithFields(logrus.Fields{
+		"node":     n.Name,
+		"instance": n.Instance,
+	})
+	assigner, err := newAssigner(ctx, log, n, cfg)
+	if err != nil {
+		logger.WithError(err).Error("failed to initialize assigner, static public IP address of ignored node not released")
+		return
+	}
+	syncer, err := newIntegrations(ctx, log, cfg, client)
+	if err != nil {
+		logger.WithError(err).Warn("failed to initialize integrations, releasing static public IP address of ignored node without sync")
+		syncer = &integrations{}
+	}
+	recorder := nd.NewStatusRecorder(client)
+	if err = releaseAddress(ctx, log, assigner, recorder, syncer, n, recordedAddress(ctx, log, recorder, n)); err != nil {
+		if errors.Is(err, address.ErrNoStaticIPAssigned) || errors.Is(err, address.ErrNoPublicIPAssigned) {
+			logger.Debug("no static public IP address assigned to ignored node, nothing to release")
+			return
 		}
+		logger.WithError(err).Error("failed to release static public IP address of ignored node")
+		return
 	}
+	logger.Info("static public IP address of ignored node released")
+}
 
-	// pause the agent to prevent it from exiting immediately after assigning the static public IP address
-	// wait for the context to be done: SIGTERM, SIGINT
+// unsupportedProvider records the unsupported provider of the node and, by policy, idles until shutdown (mixed fleets
+// running the DaemonSet on every node) or exits with exitCodeUnsupportedProvider
+func unsupportedProvider(ctx context.Context, log *logrus.Entry, recorder nd.StatusRecorder, unsupported *nd.UnsupportedProviderError, cfg *config.Config) error {
+	logger := log.WithFields(logrus.Fields{
+		"node":        unsupported.Node,
+		"provider-id": unsupported.ProviderID,
+		"policy":      cfg.UnsupportedProvider,
+	})
+	recordStatus(ctx, log, recorder, &types.AssignmentStatus{Node: unsupported.Node, LastError: unsupported.Error()})
+	if cfg.UnsupportedProvider != unsupportedProviderIgnore {
+		logger.Error("unsupported cloud provider: keep the DaemonSet off the node (node affinity, --node-selector), use the MetalLB mode (--metallb-addresses) for bare metal nodes or set --unsupported-provider=ignore")
+		return cli.Exit(unsupported, exitCodeUnsupportedProvider)
+	}
+	logger.Warn("unsupported cloud provider, skipping static public IP address assignment")
 	<-ctx.Done()
 	log.Infof("shutting down kubeip agent")
+	return nil
+}
 
-	// release the static public IP address on exit
-	if cfg.ReleaseOnExit {
-		log.Infof("releasing static public IP address")
-		if releaseErr := releaseIP(assigner, n); releaseErr != nil { //nolint:contextcheck
-			return releaseErr
-		}
-		log.Infof("static public IP address released")
-	}
+// blockAssignment leaves the assignment of the node blocked by a permanent error until shutdown instead of exiting: a
+// restarted agent would fail the same way; the agent and the pods of the node stay unready until the cause is fixed and
+// the agent restarted
+func blockAssignment(ctx context.Context, log *logrus.Entry, syncer *integrations, n *types.Node, err error) error {
+	log.WithError(err).WithField("node", n.Name).Error("permanent error, static public IP address assignment blocked until the agent restarts")
+	health.Default.Blocked(err, time.Now())
+	syncer.blocked(ctx, log, n, err)
+	go syncer.watchReadinessGates(ctx, log, n)
+	<-ctx.Done()
 	return nil
 }
 
-func releaseIP(assigner address.Assigner, n *types.Node) error {
-	releaseCtx, releaseCancel := context.WithTimeout(context.Background(), unassignTimeout)
-	defer releaseCancel()
+// recordStatus records the assignment status of the node; it completes on shutdown, up to the record status timeout
+func recordStatus(ctx context.Context, log *logrus.Entry, recorder nd.StatusRecorder, status *types.AssignmentStatus) {
+	recordCtx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
 
-	if err := assigner.Unassign(releaseCtx, n.Instance, n.Zone); err != nil {
-		return errors.Wrap(err, "failed to release static public IP address")
+	if err := recorder.SetStatus(recordCtx, status); err != nil {
+		log.WithError(err).WithField("node", status.Node).Warn("failed to record assignment status")
 	}
+}
 
-	return nil
+// recordAssignedStatus records the status of the address assigned to the node unless the status already records it,
+// e.g. after a restart of the agent: the history of the node only holds actual transitions
+func recordAssignedStatus(ctx context.Context, log *logrus.Entry, recorder nd.StatusRecorder, n *types.Node, assigned *types.AssignmentStatus) {
+	if status, err := recorder.GetStatus(ctx, n.Name); err == nil && status.Address == assigned.Address && status.Pool == n.Pool && status.LastError == "" {
+		log.WithFields(logrus.Fields{
+			"node":    n.Name,
+			"address": assigned.Address,
+		}).Info("static public IP address already assigned and recorded, keeping assignment status")
+		return
+	}
+	recordStatus(ctx, log, recorder, assigned)
+}
+
+// withOperation sets the most recent cloud operation on the instance of the node, if the assigner reports it, on the
+// status
+func withOperation(assigner address.Assigner, n *types.Node, status *types.AssignmentStatus) *types.AssignmentStatus {
+	if reporter, ok := assigner.(address.OperationReporter); ok {
+		status.Operation = reporter.LastOperation(n.Instance)
+	}
+	return status
+}
+
+// waitStartupJitter waits a random delay up to the jitter, returning the context error if done first
+func waitStartupJitter(ctx context.Context, log *logrus.Entry, jitter time.Duration) error {
+	if jitter <= 0 {
+		return nil
+	}
+	delay := time.Duration(rand.Int63n(int64(jitter))) //nolint:gosec
+	log.WithField("delay", delay).Info("delaying the start to spread the rollout")
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck
+	}
+}
+
+// pace waits until the interval has elapsed since the start, or the context is done
+func pace(ctx context.Context, interval time.Duration, start time.Time) {
+	remaining := interval - time.Since(start)
+	if remaining <= 0 {
+		return
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// drainContext returns a context keeping the values of ctx that outlives its cancellation by the drain timeout: a cloud
+// mutation in flight on shutdown (SIGTERM, SIGINT) completes and its result is recorded instead of leaving the address
+// in an unknown state; a zero timeout cancels it with ctx
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-drainCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-drainCtx.Done():
+		case <-timer.C:
+			cancel()
+		}
+	}()
+	return drainCtx, cancel
+}
+
+// detachedContext returns a context keeping the values of ctx but not its cancellation, with a timeout: the release and
+// bookkeeping done on shutdown (SIGTERM, SIGINT) must not be cancelled with the agent context
+func detachedContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
 }
 
 func runCmd(c *cli.Context) error {
 	// setup signal handler for graceful shutdown: SIGTERM, SIGINT
 	ctx := signals.SetupSignalHandler()
 	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	if err := config.ApplyProfile(c); err != nil {
+		log.WithError(err).Error("invalid kubeip agent configuration profile")
+		return err //nolint:wrapcheck
+	}
 	cfg := config.NewConfig(c)
+	// report all the configuration problems at once, before any cloud or Kubernetes call
+	if err := cfg.Interpolate(); err != nil {
+		log.WithError(err).Error("invalid kubeip agent configuration")
+		return err //nolint:wrapcheck
+	}
+	if err := cfg.Validate(); err != nil {
+		log.WithError(err).Error("invalid kubeip agent configuration")
+		return err //nolint:wrapcheck
+	}
 
-	if err := run(ctx, log, cfg); err != nil {
+	restconfig, err := retrieveKubeConfig(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error retrieving kube config")
+		return err
+	}
+	// the agent of each node runs on its own: no leader election
+	mgr, err := newManager(log, restconfig, cfg, "")
+	if err != nil {
+		log.WithError(err).Error("error initializing kubeip agent manager")
+		return err
+	}
+	if err = runManager(ctx, mgr, false, func(ctx context.Context) error {
+		return run(ctx, log, cfg)
+	}); err != nil {
 		log.WithError(err).Error("error running kubeip agent")
 		return err
 	}
@@ -297,133 +989,29 @@ func runCmd(c *cli.Context) error {
 	return nil
 }
 
-//nolint:funlen
 func main() {
 	app := &cli.App{
 		// use ";" instead of "," for slice flag separator
 		// AWS filter values can contain "," and shorthand filter format uses "," to separate Names and Values
 		SliceFlagSeparator: ";",
-		Commands: []*cli.Command{
-			{
-				Name:  "run",
-				Usage: "run agent",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "node-name",
-						Usage:    "Kubernetes node name (not needed if running in node)",
-						EnvVars:  []string{"NODE_NAME"},
-						Category: "Configuration",
-					},
-					&cli.StringFlag{
-						Name:     "project",
-						Usage:    "name of the GCP project or the AWS account ID (not needed if running in node) or OCI compartment OCID (required for OCI)",
-						EnvVars:  []string{"PROJECT"},
-						Category: "Configuration",
-					},
-					&cli.StringFlag{
-						Name:     "region",
-						Usage:    "name of the GCP region or the AWS region or the OCI region (not needed if running in node)",
-						EnvVars:  []string{"REGION"},
-						Category: "Configuration",
-					},
-					&cli.BoolFlag{
-						Name:     "ipv6",
-						Usage:    "enable IPv6 support",
-						EnvVars:  []string{"IPV6"},
-						Category: "Configuration",
-					},
-					&cli.PathFlag{
-						Name:     "kubeconfig",
-						Usage:    "path to Kubernetes configuration file (not needed if running in node)",
-						EnvVars:  []string{"KUBECONFIG"},
-						Category: "Configuration",
-					},
-					&cli.DurationFlag{
-						Name:     "retry-interval",
-						Usage:    "when the agent fails to assign the static public IP address, it will retry after this interval",
-						Value:    defaultRetryInterval,
-						EnvVars:  []string{"RETRY_INTERVAL"},
-						Category: "Configuration",
-					},
-					&cli.StringSliceFlag{
-						Name:     "filter",
-						Usage:    "filter for the IP addresses",
-						EnvVars:  []string{"FILTER"},
-						Category: "Configuration",
-					},
-					&cli.StringFlag{
-						Name:     "order-by",
-						Usage:    "order by for the IP addresses",
-						EnvVars:  []string{"ORDER_BY"},
-						Category: "Configuration",
-					},
-					&cli.IntFlag{
-						Name:     "retry-attempts",
-						Usage:    "number of attempts to assign the static public IP address",
-						Value:    defaultRetryAttempts,
-						EnvVars:  []string{"RETRY_ATTEMPTS"},
-						Category: "Configuration",
-					},
-					&cli.IntFlag{
-						Name:     "lease-duration",
-						Usage:    "duration of the kubernetes lease",
-						Value:    defaultLeaseDuration,
-						EnvVars:  []string{"LEASE_DURATION"},
-						Category: "Configuration",
-					},
-					&cli.StringFlag{
-						Name:     "lease-namespace",
-						Usage:    "namespace of the kubernetes lease",
-						EnvVars:  []string{"LEASE_NAMESPACE"},
-						Value:    "default", // default namespace
-						Category: "Configuration",
-					},
-					&cli.BoolFlag{
-						Name:     "release-on-exit",
-						Usage:    "release the static public IP address on exit",
-						EnvVars:  []string{"RELEASE_ON_EXIT"},
-						Category: "Configuration",
-						Value:    true,
-					},
-					&cli.StringFlag{
-						Name:     "taint-key",
-						Usage:    "specify a taint key to remove from the node once the static public IP address is assigned",
-						EnvVars:  []string{"TAINT_KEY"},
-						Category: "Configuration",
-					},
-					&cli.StringFlag{
-						Name:     "log-level",
-						Usage:    "set log level (debug, info(*), warning, error, fatal, panic)",
-						Value:    "info",
-						EnvVars:  []string{"LOG_LEVEL"},
-						Category: "Logging",
-					},
-					&cli.BoolFlag{
-						Name:     "json",
-						Usage:    "produce log in JSON format: Logstash and Splunk friendly",
-						EnvVars:  []string{"LOG_JSON"},
-						Category: "Logging",
-					},
-					&cli.BoolFlag{
-						Name:     "develop-mode",
-						Usage:    "enable develop mode",
-						EnvVars:  []string{"DEV_MODE"},
-						Category: "Development",
-					},
-				},
-				Action: runCmd,
-			},
-		},
-		Name:    "kubeip-agent",
-		Usage:   "replaces the node's public IP address with a static public IP (IPv4/IPv6) address",
-		Version: version,
-	}
-	cli.VersionPrinter = func(_ *cli.Context) {
-		fmt.Printf("kubeip-agent %s\n", version)
+		Commands:           agentCommands(),
+		Name:               "kubeip-agent",
+		Usage:              "replaces the node's public IP address with a static public IP (IPv4/IPv6) address",
+		Version:            version,
+	}
+	// the same binary is installed as kubectl plugin: kubectl kubeip status|list|release|assign
+	if isKubectlPlugin(os.Args[0]) {
+		app.Name = pluginName
+		app.Usage = "manage kubeip static public IP address assignments"
+		app.Commands = pluginCommands()
+	}
+	cli.VersionPrinter = func(c *cli.Context) {
+		fmt.Printf("%s %s\n", c.App.Name, version)
 		fmt.Printf("  Build date: %s\n", buildDate)
 		fmt.Printf("  Git commit: %s\n", gitCommit)
 		fmt.Printf("  Git branch: %s\n", gitBranch)
 		fmt.Printf("  Built with: %s\n", runtime.Version())
+		fmt.Printf("  Platform:   %s/%s\n", runtime.GOOS, runtime.GOARCH)
 	}
 
 	err := app.Run(os.Args)
@@ -432,17 +1020,16 @@ func main() {
 	}
 }
 
-func kubeConfigFromPath(kubepath string) (*rest.Config, error) {
+func kubeConfigFromPath(kubepath, kubecontext, apiServer string) (*rest.Config, error) {
 	if kubepath == "" {
 		return nil, errEmptyPath
 	}
 
-	data, err := os.ReadFile(kubepath)
-	if err != nil {
+	if _, err := os.Stat(kubepath); err != nil {
 		return nil, errors.Wrapf(err, "reading kubeconfig at %s", kubepath)
 	}
 
-	cfg, err := clientcmd.RESTConfigFromKubeConfig(data)
+	cfg, err := kubeConfigFromRules(&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubepath}, kubecontext, apiServer)
 	if err != nil {
 		return nil, errors.Wrapf(err, "building rest config from kubeconfig at %s", kubepath)
 	}
@@ -450,21 +1037,279 @@ func kubeConfigFromPath(kubepath string) (*rest.Config, error) {
 	return cfg, nil
 }
 
+// kubeConfigFromRules uses the given context (or current context) and API server override from the kubeconfig files of the loading rules
+func kubeConfigFromRules(loadingRules *clientcmd.ClientConfigLoadingRules, kubecontext, apiServer string) (*rest.Config, error) {
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubecontext}
+	overrides.ClusterInfo.Server = apiServer
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig() //nolint:wrapcheck
+}
+
 func retrieveKubeConfig(log logrus.FieldLogger, cfg *config.Config) (*rest.Config, error) {
-	kubeconfig, err := kubeConfigFromPath(cfg.KubeConfigPath)
+	kubeconfig, err := kubeConfigFromPath(cfg.KubeConfigPath, cfg.KubeContext, cfg.KubeAPIServer)
 	if err != nil && !errors.Is(err, errEmptyPath) {
 		return nil, errors.Wrap(err, "retrieving kube config from path")
 	}
 
-	if kubeconfig != nil {
-		log.Debug("using kube config from env variables")
-		return kubeconfig, nil
+	switch {
+	case kubeconfig != nil:
+		log.WithField("context", cfg.KubeContext).Debug("using kube config from path")
+	case cfg.KubeContext != "":
+		// a context is never part of the in node kube config: use the default kubeconfig files ($KUBECONFIG, ~/.kube/config)
+		kubeconfig, err = kubeConfigFromRules(clientcmd.NewDefaultClientConfigLoadingRules(), cfg.KubeContext, cfg.KubeAPIServer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "building rest config for context %s from default kubeconfig", cfg.KubeContext)
+		}
+		log.WithField("context", cfg.KubeContext).Debug("using kube config from default kubeconfig")
+	case cfg.KubeAPIServer != "" && cfg.KubeTokenFile != "":
+		// remote cluster without kubeconfig: API server and bearer token only
+		kubeconfig = &rest.Config{Host: cfg.KubeAPIServer}
+		log.WithField("api-server", cfg.KubeAPIServer).Debug("using kube API server and token file")
+	default:
+		kubeconfig, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "retrieving in node kube config")
+		}
+		if cfg.KubeAPIServer != "" {
+			kubeconfig.Host = cfg.KubeAPIServer
+		}
+		log.Debug("using in node kube config")
+	}
+
+	// override the bearer token with the token file (re-read by the client when the token is rotated)
+	if cfg.KubeTokenFile != "" {
+		kubeconfig.BearerToken = ""
+		kubeconfig.BearerTokenFile = cfg.KubeTokenFile
+	}
+	// route the API calls through the impersonated identity (the authenticated identity needs the impersonate verb)
+	if len(cfg.KubeAsGroups) > 0 && cfg.KubeAs == "" {
+		return nil, errors.New("impersonating groups requires an impersonated user (--as)")
+	}
+	if cfg.KubeAs != "" {
+		kubeconfig.Impersonate = rest.ImpersonationConfig{UserName: cfg.KubeAs, Groups: cfg.KubeAsGroups}
+	}
+	// the API server keeps its own CA: the CA bundle only applies to the cloud API clients
+	if cfg.ProxyURL != "" {
+		kubeconfig.Proxy = cloud.ProxyFunc(cfg)
+	}
+	return kubeconfig, nil
+}
+
+// newExplorer returns the node explorer: in MetalLB or BGP mode, nodes without a cloud provider ID are bare metal nodes; in
+// develop mode, all nodes are simulated
+func newExplorer(client kubernetes.Interface, cfg *config.Config) nd.Explorer {
+	var bareMetal types.CloudProvider
+	switch {
+	case cfg.DevelopMode:
+		bareMetal = types.CloudProviderFake
+	case len(cfg.MetalLBAddresses) > 0:
+		bareMetal = types.CloudProviderMetalLB
+	case len(cfg.BGPAddresses) > 0:
+		bareMetal = types.CloudProviderBGP
+	}
+	return nd.NewExplorer(client, bareMetal, cfg.InstanceID)
+}
+
+// newAssigner returns the assigner of the node cloud provider, the MetalLB or BGP assigner of bare metal nodes or the simulated
+// assigner of the develop mode, injecting faults in chaos mode
+func newAssigner(ctx context.Context, log *logrus.Entry, n *types.Node, cfg *config.Config) (address.Assigner, error) {
+	assigner, err := newNodeAssigner(ctx, log, n, cfg)
+	if err != nil || !address.ChaosEnabled(cfg) {
+		return assigner, err
+	}
+	log.WithFields(logrus.Fields{
+		"chaos-error-rate": cfg.ChaosErrorRate,
+		"chaos-max-delay":  cfg.ChaosMaxDelay,
+		"chaos-stale-rate": cfg.ChaosStaleRate,
+	}).Warn("chaos mode enabled, injecting faults into the cloud provider calls")
+	return address.NewChaosAssigner(log, assigner, cfg) //nolint:wrapcheck
+}
+
+// newNodeAssigner returns the assigner of the node cloud provider
+func newNodeAssigner(ctx context.Context, log *logrus.Entry, n *types.Node, cfg *config.Config) (address.Assigner, error) {
+	if n.Cloud == types.CloudProviderFake {
+		return newFakeAssigner(cfg)
+	}
+	if n.Cloud == types.CloudProviderMetalLB {
+		client, err := newDynamicClient(log, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return address.NewMetalLBAssigner(log, client, cfg) //nolint:wrapcheck
+	}
+	if n.Cloud == types.CloudProviderBGP {
+		client, err := newDynamicClient(log, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return address.NewBGPAssigner(log, client, cfg) //nolint:wrapcheck
+	}
+	return address.NewAssigner(ctx, log, n.Cloud, gcpProjectConfig(log, n, cfg)) //nolint:wrapcheck
+}
+
+// providerConfig returns the configuration with the filters of the cloud provider of the node, <provider>=<filter>
+// entries, instead of the common filters: one deployment serves clusters mixing cloud providers (EKS Anywhere, Anthos
+// attached nodes), each with its own filter syntax; the configuration is kept without filters for the provider. The
+// filters are then rendered with the metadata of the node
+func providerConfig(log *logrus.Entry, n *types.Node, cfg *config.Config) (*config.Config, error) {
+	var filters []string
+	for _, entry := range cfg.ProviderFilters {
+		provider, filter, ok := strings.Cut(entry, "=")
+		switch types.CloudProvider(provider) {
+		case types.CloudProviderAWS, types.CloudProviderGCP, types.CloudProviderOCI, types.CloudProviderAzure, types.CloudProviderMetalLB,
+			types.CloudProviderBGP:
+		default:
+			ok = false
+		}
+		if !ok || filter == "" {
+			return nil, errors.Errorf("invalid provider filter %q, want <provider>=<filter> with provider aws, gcp, oci, azure, metallb or bgp", entry)
+		}
+		if types.CloudProvider(provider) == n.Cloud {
+			filters = append(filters, filter)
+		}
 	}
+	selected := len(filters) > 0
+	if selected {
+		log.WithFields(logrus.Fields{
+			"node":     n.Name,
+			"provider": n.Cloud,
+			"filter":   filters,
+		}).Info("using the filters of the cloud provider of the node")
+	} else {
+		filters = cfg.Filter
+	}
+	rendered, templated, err := renderFilters(n, filters)
+	if err != nil {
+		return nil, err
+	}
+	if templated {
+		log.WithFields(logrus.Fields{
+			"node":   n.Name,
+			"filter": rendered,
+		}).Info("rendered the filters with the node metadata")
+	} else if !selected {
+		return cfg, nil
+	}
+	providerCfg := *cfg
+	providerCfg.Filter = rendered
+	return &providerCfg, nil
+}
+
+// filterData are the fields of the filter and address pool templates
+type filterData struct {
+	Node *types.Node
+}
 
-	inClusterConfig, err := rest.InClusterConfig()
+// renderFilters renders the filters holding a template with the metadata of the node, e.g.
+// labels.pool={{ .Node.Labels.nodepool }}, so one configuration maps each node pool to its own addresses; a missing label
+// or annotation is an error rather than an empty value matching another pool. It reports whether a filter was templated
+func renderFilters(n *types.Node, filters []string) ([]string, bool, error) {
+	rendered := make([]string, 0, len(filters))
+	templated := false
+	for _, filter := range filters {
+		value, err := renderNodeTemplate(n, filter)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "rendering filter")
+		}
+		rendered = append(rendered, value)
+		templated = templated || value != filter
+	}
+	return rendered, templated, nil
+}
+
+// renderNodeTemplate renders a text holding a template with the metadata of the node; a text without template is kept
+func renderNodeTemplate(n *types.Node, text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("node").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid template %q", text)
+	}
+	var b strings.Builder
+	if err = tmpl.Execute(&b, filterData{Node: n}); err != nil {
+		return "", errors.Wrapf(err, "failed to render %q for node %s", text, n.Name)
+	}
+	return b.String(), nil
+}
+
+// gcpProjectConfig returns the configuration with the project of the GCP node instance, from its provider ID, when no
+// project is configured; a configured project is kept
+func gcpProjectConfig(log *logrus.Entry, n *types.Node, cfg *config.Config) *config.Config {
+	if n.Cloud != types.CloudProviderGCP || n.Project == "" || n.Project == cfg.Project {
+		return cfg
+	}
+	if cfg.Project != "" {
+		log.WithFields(logrus.Fields{
+			"node":             n.Name,
+			"project":          cfg.Project,
+			"instance-project": n.Project,
+		}).Warn("node instance is not in the configured project")
+		return cfg
+	}
+	projectCfg := *cfg
+	projectCfg.Project = n.Project
+	return &projectCfg
+}
+
+// newFakeAssigner returns the simulated assigner of the develop mode
+func newFakeAssigner(cfg *config.Config) (address.Assigner, error) {
+	addresses := cfg.DevelopAddresses
+	if len(addresses) == 0 {
+		addresses = []string{defaultDevelopAddresses}
+	}
+	provider, err := fake.New(addresses, fake.WithLatency(cfg.DevelopLatency), fake.WithFailureRate(cfg.DevelopFailureRate))
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing develop mode assigner")
+	}
+	return provider, nil
+}
+
+// checkPermissions checks the cloud permissions of the assigner credentials, if enabled and supported by the assigner
+func checkPermissions(ctx context.Context, assigner address.Assigner, n *types.Node, cfg *config.Config) error {
+	checker, ok := assigner.(address.PermissionChecker)
+	if !ok || !cfg.PermissionCheck {
+		return nil
+	}
+	return checker.CheckPermissions(ctx, n.Instance) //nolint:wrapcheck
+}
+
+// checkQuota reports the static public IP addresses left in the cloud provider quota, if enabled and supported by the
+// assigner; an exhausted quota is published to the event sink, failures are logged
+func checkQuota(ctx context.Context, log *logrus.Entry, assigner address.Assigner, n *types.Node, cfg *config.Config, syncer *integrations) {
+	checker, ok := assigner.(address.QuotaChecker)
+	if !ok || !cfg.QuotaCheck {
+		return
+	}
+	quota, err := checker.Quota(ctx)
+	if err != nil {
+		log.WithError(err).Warn("failed to check the static public IP address quota")
+		return
+	}
+	metrics.ObserveQuota(string(n.Cloud), quota.Name, quota.Remaining())
+	logger := log.WithFields(logrus.Fields{
+		"quota": quota.Name,
+		"limit": quota.Limit,
+		"usage": quota.Usage,
+	})
+	if quota.Remaining() > 0 {
+		logger.Info("static public IP address quota checked")
+		return
+	}
+	logger.Warn("static public IP address quota exhausted, no more addresses can be reserved in the region")
+	syncCtx, cancel := detachedContext(ctx, recordStatusTimeout)
+	defer cancel()
+	syncer.publish(syncCtx, log, n, &sink.Event{Type: sink.EventQuotaExhausted, Quota: quota.Name, QuotaLimit: quota.Limit})
+}
+
+func newKubernetesClient(log logrus.FieldLogger, cfg *config.Config) (kubernetes.Interface, error) {
+	restconfig, err := retrieveKubeConfig(log, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving kube config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(restconfig)
 	if err != nil {
-		return nil, errors.Wrap(err, "retrieving in node kube config")
+		return nil, errors.Wrap(err, "initializing kubernetes client")
 	}
-	log.Debug("using in node kube config")
-	return inClusterConfig, nil
+	return clientset, nil
 }
@@ -3,18 +3,36 @@ package main
 import (
 	"context"
 	"net"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/admin"
+	"github.com/doitintl/kubeip/internal/claim"
 	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/events"
+	"github.com/doitintl/kubeip/internal/metrics"
 	"github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/probe"
+	"github.com/doitintl/kubeip/internal/schedule"
 	"github.com/doitintl/kubeip/internal/types"
 	mocks "github.com/doitintl/kubeip/mocks/address"
 	nodeMocks "github.com/doitintl/kubeip/mocks/node"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	tmock "github.com/stretchr/testify/mock"
+	"github.com/urfave/cli/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 )
 
 func Test_assignAddress(t *testing.T) {
@@ -55,6 +73,31 @@ func Test_assignAddress(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "static public IP address already assigned",
+			address: "1.1.1.1",
+			args: args{
+				c: context.Background(),
+				assignerFn: func(t *testing.T) address.Assigner {
+					mock := mocks.NewAssigner(t)
+					mock.EXPECT().Assign(tmock.Anything, "test-instance", "test-zone", []string{"test-filter"}, "test-order-by").Return("1.1.1.1", errors.Wrap(address.ErrStaticIPAlreadyAssigned, "check")).Once()
+					return mock
+				},
+				node: &types.Node{
+					Name:     "test-node",
+					Instance: "test-instance",
+					Region:   "test-region",
+					Zone:     "test-zone",
+				},
+				cfg: &config.Config{
+					Filter:        []string{"test-filter"},
+					OrderBy:       "test-order-by",
+					RetryAttempts: 3,
+					RetryInterval: time.Millisecond,
+					LeaseDuration: 1,
+				},
+			},
+		},
 		{
 			name:    "assign address after a few retries",
 			address: "1.1.1.1",
@@ -168,18 +211,73 @@ func Test_assignAddress(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "permanent error not retried",
+			args: args{
+				c: context.Background(),
+				assignerFn: func(t *testing.T) address.Assigner {
+					mock := mocks.NewAssigner(t)
+					mock.EXPECT().Assign(tmock.Anything, "test-instance", "test-zone", []string{"test-filter"}, "test-order-by").Return("", errors.Wrap(address.ErrInvalidFilter, "name of \"test-filter\"")).Once()
+					return mock
+				},
+				node: &types.Node{
+					Name:     "test-node",
+					Instance: "test-instance",
+					Region:   "test-region",
+					Zone:     "test-zone",
+				},
+				cfg: &config.Config{
+					Filter:        []string{"test-filter"},
+					OrderBy:       "test-order-by",
+					RetryAttempts: 3,
+					RetryInterval: time.Millisecond,
+					LeaseDuration: 1,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "node outside the region of the addresses",
+			args: args{
+				c: context.Background(),
+				assignerFn: func(t *testing.T) address.Assigner {
+					return mocks.NewAssigner(t)
+				},
+				node: &types.Node{
+					Name:     "test-node",
+					Instance: "test-instance",
+					Cloud:    types.CloudProviderGCP,
+					Region:   "test-region",
+					Zone:     "test-zone",
+				},
+				cfg: &config.Config{
+					Region:        "other-region",
+					Filter:        []string{"test-filter"},
+					RetryAttempts: 3,
+					RetryInterval: time.Millisecond,
+					LeaseDuration: 1,
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			log := prepareLogger("debug", false)
 			assigner := tt.args.assignerFn(t)
 			client := fake.NewSimpleClientset()
-			assignedAddress, err := assignAddress(tt.args.c, log, client, assigner, tt.args.node, tt.args.cfg)
+			assignedAddress, err := assignAddress(tt.args.c, log, client, assigner, tt.args.node, tt.args.cfg, nil)
 			if err != nil != tt.wantErr {
 				t.Errorf("assignAddress() error = %v, wantErr %v", err, tt.wantErr)
 			} else if assignedAddress != tt.address {
 				t.Fatalf("assignAddress() = %v, want %v", assignedAddress, tt.address)
 			}
+			// no reconcile request is watched with the admin API disabled
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "watch" {
+					t.Errorf("assignAddress() watched %v with the admin API disabled", action.GetResource().Resource)
+				}
+			}
 		})
 	}
 }
@@ -373,10 +471,965 @@ func Test_waitForAddressToBeReported(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			log := prepareLogger("debug", false)
 			explorer := tt.args.explorerFn(t)
-			err := waitForAddressToBeReported(tt.args.c, log, explorer, tt.args.node, tt.args.address, tt.args.cfg)
+			err := waitForAddressToBeReported(tt.args.c, log, explorer, nil, tt.args.node, tt.args.address, tt.args.cfg)
 			if err != nil != tt.wantErr {
 				t.Errorf("waitForAddressToBeReported() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func Test_waitForAddressToBeReported_metalLB(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		address.IPAddressPoolResource:   "IPAddressPoolList",
+		address.L2AdvertisementResource: "L2AdvertisementList",
+	})
+	log := prepareLogger("debug", false)
+	assigner, err := address.NewMetalLBAssigner(log, client, &config.Config{MetalLBAddresses: []string{"192.0.2.10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &types.Node{Name: "metal-1", Instance: "metal-1", Cloud: types.CloudProviderMetalLB}
+	cfg := &config.Config{RetryAttempts: 1, RetryInterval: time.Millisecond}
+	// the node does not report the MetalLB address: the explorer is not used
+	explorer := nodeMocks.NewExplorer(t)
+
+	if err = waitForAddressToBeReported(context.Background(), log, explorer, assigner, n, "192.0.2.10", cfg); err == nil {
+		t.Errorf("waitForAddressToBeReported() of address not announced, want error")
+	}
+	if _, err = assigner.Assign(context.Background(), n.Instance, "", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = waitForAddressToBeReported(context.Background(), log, explorer, assigner, n, "192.0.2.10", cfg); err != nil {
+		t.Errorf("waitForAddressToBeReported() error = %v", err)
+	}
+	// the wait is not an assignment of its own
+	for _, result := range []string{metrics.ResultSuccess, metrics.ResultFailure} {
+		if got := metrics.Assignments.Value(string(n.Cloud), n.AddressPool, n.Pool, n.Name, result); got != 0 {
+			t.Errorf("waitForAddressToBeReported() recorded %v assignments with result %s, want none", got, result)
+		}
+	}
+}
+
+// permissionCheckingAssigner is an assigner checking its cloud permissions
+type permissionCheckingAssigner struct {
+	*mocks.Assigner
+	err     error
+	checked string
+}
+
+func (a *permissionCheckingAssigner) CheckPermissions(_ context.Context, instanceID string) error {
+	a.checked = instanceID
+	return a.err
+}
+
+func Test_checkPermissions(t *testing.T) {
+	n := &types.Node{Name: "node-1", Instance: "i-1"}
+	enabled := &config.Config{PermissionCheck: true}
+
+	// assigners without permission check are not checked
+	if err := checkPermissions(context.Background(), mocks.NewAssigner(t), n, enabled); err != nil {
+		t.Errorf("checkPermissions() error = %v", err)
+	}
+
+	assigner := &permissionCheckingAssigner{err: errors.New("missing AWS permissions: ec2:AssociateAddress")}
+	if err := checkPermissions(context.Background(), assigner, n, &config.Config{}); err != nil || assigner.checked != "" {
+		t.Errorf("checkPermissions() with check disabled error = %v, checked %q", err, assigner.checked)
+	}
+	if err := checkPermissions(context.Background(), assigner, n, enabled); err == nil || assigner.checked != "i-1" {
+		t.Errorf("checkPermissions() error = %v, checked %q, want missing permissions of i-1", err, assigner.checked)
+	}
+}
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: first
+  cluster:
+    server: https://first.example.com
+- name: second
+  cluster:
+    server: https://second.example.com
+users:
+- name: user
+  user:
+    token: static-token
+contexts:
+- name: first
+  context:
+    cluster: first
+    user: user
+- name: second
+  context:
+    cluster: second
+    user: user
+current-context: first
+`
+
+func Test_retrieveKubeConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		wantHost  string
+		wantToken string
+		wantFile  string
+		wantAs    rest.ImpersonationConfig
+		wantErr   bool
+	}{
+		{
+			name:      "current context",
+			cfg:       &config.Config{KubeConfigPath: kubeconfig},
+			wantHost:  "https://first.example.com",
+			wantToken: "static-token",
+		},
+		{
+			name:      "explicit context",
+			cfg:       &config.Config{KubeConfigPath: kubeconfig, KubeContext: "second"},
+			wantHost:  "https://second.example.com",
+			wantToken: "static-token",
+		},
+		{
+			name:      "api server override",
+			cfg:       &config.Config{KubeConfigPath: kubeconfig, KubeAPIServer: "https://override.example.com"},
+			wantHost:  "https://override.example.com",
+			wantToken: "static-token",
+		},
+		{
+			name:     "token file override",
+			cfg:      &config.Config{KubeConfigPath: kubeconfig, KubeTokenFile: "/var/run/token"},
+			wantHost: "https://first.example.com",
+			wantFile: "/var/run/token",
+		},
+		{
+			name:     "api server and token file without kubeconfig",
+			cfg:      &config.Config{KubeAPIServer: "https://remote.example.com", KubeTokenFile: "/var/run/token"},
+			wantHost: "https://remote.example.com",
+			wantFile: "/var/run/token",
+		},
+		{
+			name:      "context from default kubeconfig",
+			cfg:       &config.Config{KubeContext: "second"},
+			wantHost:  "https://second.example.com",
+			wantToken: "static-token",
+		},
+		{
+			name:      "impersonation",
+			cfg:       &config.Config{KubeConfigPath: kubeconfig, KubeAs: "system:serviceaccount:kube-system:kubeip", KubeAsGroups: []string{"kubeip"}},
+			wantHost:  "https://first.example.com",
+			wantToken: "static-token",
+			wantAs:    rest.ImpersonationConfig{UserName: "system:serviceaccount:kube-system:kubeip", Groups: []string{"kubeip"}},
+		},
+		{
+			name:    "impersonated groups without user",
+			cfg:     &config.Config{KubeConfigPath: kubeconfig, KubeAsGroups: []string{"kubeip"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown context from default kubeconfig",
+			cfg:     &config.Config{KubeContext: "unknown"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown context",
+			cfg:     &config.Config{KubeConfigPath: kubeconfig, KubeContext: "unknown"},
+			wantErr: true,
+		},
+		{
+			name:    "missing kubeconfig",
+			cfg:     &config.Config{KubeConfigPath: filepath.Join(t.TempDir(), "missing")},
+			wantErr: true,
+		},
+	}
+	// default kubeconfig loading rules read $KUBECONFIG
+	t.Setenv("KUBECONFIG", kubeconfig)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := retrieveKubeConfig(logrus.New(), tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retrieveKubeConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Host != tt.wantHost {
+				t.Errorf("retrieveKubeConfig() host = %v, want %v", got.Host, tt.wantHost)
+			}
+			if got.BearerToken != tt.wantToken {
+				t.Errorf("retrieveKubeConfig() token = %v, want %v", got.BearerToken, tt.wantToken)
+			}
+			if got.BearerTokenFile != tt.wantFile {
+				t.Errorf("retrieveKubeConfig() token file = %v, want %v", got.BearerTokenFile, tt.wantFile)
+			}
+			if !reflect.DeepEqual(got.Impersonate, tt.wantAs) {
+				t.Errorf("retrieveKubeConfig() impersonate = %v, want %v", got.Impersonate, tt.wantAs)
+			}
+		})
+	}
+}
+
+func Test_isReassignment(t *testing.T) {
+	tests := []struct {
+		name         string
+		held         string
+		candidate    string
+		candidateErr error
+		want         bool
+	}{
+		{
+			name: "no public IP address held",
+			want: false,
+		},
+		{
+			name:      "candidate already held",
+			held:      "1.1.1.1",
+			candidate: "1.1.1.1",
+			want:      false,
+		},
+		{
+			name:      "ephemeral address swapped",
+			held:      "3.3.3.3",
+			candidate: "1.1.1.1",
+			want:      true,
+		},
+		{
+			name:         "candidate unknown",
+			held:         "3.3.3.3",
+			candidateErr: address.ErrNoAvailableAddress,
+			want:         false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assigner := mocks.NewAssigner(t)
+			if tt.held != "" {
+				assigner.EXPECT().Candidate(tmock.Anything, "i-1", "zone-a", []string(nil), "").Return(tt.candidate, tt.candidateErr)
+			}
+			n := &types.Node{Name: "test-node", Instance: "i-1", Zone: "zone-a"}
+			got := isReassignment(context.Background(), prepareLogger("debug", false), assigner, n, tt.held, &config.Config{})
+			if got != tt.want {
+				t.Errorf("isReassignment() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_heldAddress(t *testing.T) {
+	if got := heldAddress(&types.Node{}); got != "" {
+		t.Errorf("heldAddress() = %v, want empty", got)
+	}
+	if got := heldAddress(&types.Node{ExternalIPs: []net.IP{net.ParseIP("1.1.1.1")}}); got != "1.1.1.1" {
+		t.Errorf("heldAddress() = %v, want 1.1.1.1", got)
+	}
+}
+
+func Test_waitForMaintenanceWindow(t *testing.T) {
+	log := prepareLogger("debug", false)
+
+	// no windows: always open
+	if err := waitForMaintenanceWindow(context.Background(), log, nil, time.Millisecond); err != nil {
+		t.Errorf("waitForMaintenanceWindow() error = %v, want nil", err)
+	}
+
+	// window opening on a day-of-month/month combination that never occurs: cancelled while waiting
+	windows, err := schedule.ParseWindows([]string{"0 0 31 2 * 1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = waitForMaintenanceWindow(ctx, log, windows, time.Millisecond); err == nil {
+		t.Error("waitForMaintenanceWindow() error = nil, want context error")
+	}
+}
+
+type fakeWatcher struct {
+	changes []events.Change
+}
+
+func (f *fakeWatcher) Watch(ctx context.Context, _ string, changes chan<- events.Change) error {
+	for _, change := range f.changes {
+		select {
+		case changes <- change:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func Test_watchAddressChanges(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node", Instance: "i-1"}
+
+	// no watcher: wait for the context to be done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if got := watchAddressChanges(ctx, log, nil, nil, n, "1.1.1.1", nil, nil); got != "1.1.1.1" {
+		t.Errorf("watchAddressChanges() = %v, want 1.1.1.1", got)
+	}
+
+	// reconcile on changes affecting the node only: the changes of another instance or naming neither the instance nor
+	// the address are skipped
+	watcher := &fakeWatcher{changes: []events.Change{
+		{Instance: "i-2", Action: "AssociateAddress"},
+		{Action: "DisassociateAddress"},
+		{Address: "4.4.4.4", Action: "ReleaseAddress"},
+		{Instance: "i-1", Action: "DisassociateAddress"},
+	}}
+	reconciled := make(chan string, 1)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	result := make(chan string)
+	go func() {
+		result <- watchAddressChanges(ctx, log, watcher, nil, n, "1.1.1.1", func(current string) string {
+			reconciled <- current
+			return "2.2.2.2"
+		}, nil)
+	}()
+	if got := <-reconciled; got != "1.1.1.1" {
+		t.Errorf("reconcile() current = %v, want 1.1.1.1", got)
+	}
+	cancel()
+	if got := <-result; got != "2.2.2.2" {
+		t.Errorf("watchAddressChanges() = %v, want 2.2.2.2", got)
+	}
+	if len(reconciled) != 0 {
+		t.Error("reconcile() called for a change not affecting the node")
+	}
+
+	// no reconcile on changes not affecting the node
+	watcher = &fakeWatcher{changes: []events.Change{
+		{Instance: "i-2", Action: "AssociateAddress"},
+		{Address: "4.4.4.4", Action: "ReleaseAddress"},
+	}}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	got := watchAddressChanges(ctx, log, watcher, nil, n, "1.1.1.1", func(current string) string {
+		t.Errorf("reconcile() called for a change not affecting the node, current = %v", current)
+		return current
+	}, nil)
+	if got != "1.1.1.1" {
+		t.Errorf("watchAddressChanges() = %v, want 1.1.1.1", got)
+	}
+
+	// admin actions: release, then reconcile
+	actions := make(chan string)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		result <- watchAddressChanges(ctx, log, nil, actions, n, "1.1.1.1", func(current string) string {
+			reconciled <- current
+			return "3.3.3.3"
+		}, func(string) string {
+			return ""
+		})
+	}()
+	actions <- admin.ActionRelease
+	actions <- admin.ActionReconcile
+	if got := <-reconciled; got != "" {
+		t.Errorf("reconcile() after release current = %v, want empty", got)
+	}
+	cancel()
+	if got := <-result; got != "3.3.3.3" {
+		t.Errorf("watchAddressChanges() = %v, want 3.3.3.3", got)
+	}
+}
+
+// fakeProber reports the egress address
+type fakeProber struct {
+	address string
+}
+
+func (f *fakeProber) EgressIP(context.Context) (string, error) {
+	return f.address, nil
+}
+
+func Test_verifyEgress(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node"}
+	if err := verifyEgress(context.Background(), log, &fakeProber{address: "1.1.1.1"}, n, "1.1.1.1", time.Second); err != nil {
+		t.Errorf("verifyEgress() error = %v", err)
+	}
+	// the cloud call succeeded but the traffic still egresses from the previous address
+	err := verifyEgress(context.Background(), log, &fakeProber{address: "2.2.2.2"}, n, "1.1.1.1", 10*time.Millisecond)
+	if !errors.Is(err, probe.ErrUnexpectedEgress) {
+		t.Errorf("verifyEgress() error = %v, want %v", err, probe.ErrUnexpectedEgress)
+	}
+	// nothing to verify for an unknown address
+	if err = verifyEgress(context.Background(), log, &fakeProber{address: "2.2.2.2"}, n, "", time.Second); err != nil {
+		t.Errorf("verifyEgress() error = %v", err)
+	}
+}
+
+func Test_watchEgress(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node", Cloud: types.CloudProviderGCP, Pool: "test-pool"}
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+	recorder := node.NewStatusRecorder(client)
+	if err := recorder.SetStatus(context.Background(), &types.AssignmentStatus{Node: "test-node", Address: "1.1.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the drift is reconciled once
+	forwarded := watchEgress(ctx, log, &fakeProber{address: "2.2.2.2"}, recorder, n, nil, time.Millisecond, true)
+	if got := <-forwarded; got != egressDriftAction {
+		t.Errorf("watchEgress() action = %v, want %v", got, egressDriftAction)
+	}
+	if got := metrics.EgressDrift.Value(string(n.Cloud), n.AddressPool, n.Pool, n.Name); got != 1 {
+		t.Errorf("egress drift metric = %v, want 1", got)
+	}
+	select {
+	case got := <-forwarded:
+		t.Errorf("watchEgress() action = %v, want a single reconciliation per drift", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// fakeDrainDetector reports the drain taint from the given check on
+type fakeDrainDetector struct {
+	checks   int
+	draining int
+}
+
+func (f *fakeDrainDetector) Draining(context.Context, string) (string, error) {
+	f.checks++
+	if f.checks >= f.draining {
+		return "karpenter.sh/disrupted", nil
+	}
+	return "", nil
+}
+
+func Test_watchPreDrain(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	actions := make(chan string)
+	detector := &fakeDrainDetector{draining: 2}
+	forwarded := watchPreDrain(ctx, log, detector, n, actions, time.Millisecond)
+
+	// admin actions are forwarded, the pre-drain release is handed over once
+	actions <- admin.ActionReconcile
+	if got := <-forwarded; got != admin.ActionReconcile {
+		t.Errorf("watchPreDrain() action = %v, want %v", got, admin.ActionReconcile)
+	}
+	if got := <-forwarded; got != preDrainAction {
+		t.Errorf("watchPreDrain() action = %v, want %v", got, preDrainAction)
+	}
+	checks := detector.checks
+	actions <- admin.ActionRelease
+	if got := <-forwarded; got != admin.ActionRelease {
+		t.Errorf("watchPreDrain() action = %v, want %v", got, admin.ActionRelease)
+	}
+	if detector.checks != checks {
+		t.Errorf("watchPreDrain() checked the node %d times after the drain, want none", detector.checks-checks)
+	}
+}
+
+// fakeUpdater records the DNS records of the nodes and the number of calls
+type fakeUpdater struct {
+	records map[string]string
+	calls   int
+}
+
+func (f *fakeUpdater) Upsert(_ context.Context, nodeName, address string) error {
+	f.calls++
+	f.records[nodeName] = address
+	return nil
+}
+
+func (f *fakeUpdater) Delete(_ context.Context, nodeName, address string) error {
+	f.calls++
+	if f.records[nodeName] == address {
+		delete(f.records, nodeName)
+	}
+	return nil
+}
+
+func Test_waitForNodeBootstrap(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node"}
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Labels: map[string]string{"cni": "ready"}}})
+	waiter := node.NewBootstrapWaiter(client, time.Millisecond, nil)
+
+	if err := waitForNodeBootstrap(context.Background(), log, waiter, n, []node.Requirement{{Label: "cni", Value: "ready"}}, time.Second); err != nil {
+		t.Errorf("waitForNodeBootstrap() error = %v for a bootstrapped node", err)
+	}
+	// the assignment goes ahead once the timeout expires
+	if err := waitForNodeBootstrap(context.Background(), log, waiter, n, []node.Requirement{{Condition: v1.NodeReady, Status: v1.ConditionTrue}}, 10*time.Millisecond); err != nil {
+		t.Errorf("waitForNodeBootstrap() error = %v after the timeout, want nil", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitForNodeBootstrap(ctx, log, waiter, n, []node.Requirement{{Condition: v1.NodeReady, Status: v1.ConditionTrue}}, time.Second); err == nil {
+		t.Error("waitForNodeBootstrap() expected error once the agent shuts down")
+	}
+}
+
+func Test_unsupportedProvider(t *testing.T) {
+	log := prepareLogger("debug", false)
+	unsupported := &node.UnsupportedProviderError{Node: "test-node", ProviderID: "k3s://test-node"}
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+	recorder := node.NewStatusRecorder(client)
+
+	err := unsupportedProvider(context.Background(), log, recorder, unsupported, &config.Config{UnsupportedProvider: unsupportedProviderFail})
+	var exitErr cli.ExitCoder
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitCodeUnsupportedProvider {
+		t.Errorf("unsupportedProvider() error = %v, want exit code %d", err, exitCodeUnsupportedProvider)
+	}
+
+	// ignored: the agent idles until shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = unsupportedProvider(ctx, log, recorder, unsupported, &config.Config{UnsupportedProvider: unsupportedProviderIgnore}); err != nil {
+		t.Errorf("unsupportedProvider() error = %v, want nil when ignored", err)
+	}
+	status, err := recorder.GetStatus(context.Background(), "test-node")
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.LastError != unsupported.Error() {
+		t.Errorf("unsupportedProvider() recorded error = %q, want %q", status.LastError, unsupported.Error())
+	}
+}
+
+func Test_detachedContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), developModeKey, true))
+	cancel()
+	detached, detachedCancel := detachedContext(ctx, time.Minute)
+	defer detachedCancel()
+	if err := detached.Err(); err != nil {
+		t.Errorf("detachedContext() error = %v, want nil after the parent is cancelled", err)
+	}
+	if _, ok := detached.Deadline(); !ok {
+		t.Error("detachedContext() has no deadline")
+	}
+	if detached.Value(developModeKey) != true {
+		t.Error("detachedContext() lost the parent values")
+	}
+}
+
+func Test_drainContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), developModeKey, true))
+	drained, drainCancel := drainContext(ctx, 50*time.Millisecond)
+	defer drainCancel()
+	cancel()
+	if err := drained.Err(); err != nil {
+		t.Errorf("drainContext() error = %v, want nil right after the parent is cancelled", err)
+	}
+	if drained.Value(developModeKey) != true {
+		t.Error("drainContext() lost the parent values")
+	}
+	select {
+	case <-drained.Done():
+	case <-time.After(time.Second):
+		t.Error("drainContext() not cancelled after the drain timeout")
+	}
+}
+
+func Test_waitStartupJitter(t *testing.T) {
+	log := prepareLogger("debug", false)
+	if err := waitStartupJitter(context.Background(), log, 0); err != nil {
+		t.Errorf("waitStartupJitter() error = %v, want nil without jitter", err)
+	}
+	start := time.Now()
+	if err := waitStartupJitter(context.Background(), log, 20*time.Millisecond); err != nil || time.Since(start) > time.Second {
+		t.Errorf("waitStartupJitter() error = %v after %v, want nil within the jitter", err, time.Since(start))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitStartupJitter(ctx, log, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("waitStartupJitter() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_pace(t *testing.T) {
+	start := time.Now()
+	pace(context.Background(), 50*time.Millisecond, start)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("pace() returned after %v, want at least the pacing interval", elapsed)
+	}
+	// the pacing interval elapsed during the assignment: no wait
+	start = time.Now()
+	pace(context.Background(), 50*time.Millisecond, start.Add(-time.Second))
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("pace() waited %v, want no wait", elapsed)
+	}
+}
+
+func Test_releaseAddress(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node", Instance: "test-instance", Zone: "test-zone", Pool: "test-pool"}
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Annotations: map[string]string{
+		node.AddressAnnotation:            "1.1.1.1",
+		node.PoolAnnotation:               "test-pool",
+		node.LastTransitionTimeAnnotation: "2024-03-16T02:00:00Z",
+	}}})
+	recorder := node.NewStatusRecorder(client)
+	updater := &fakeUpdater{records: map[string]string{"test-node": "1.1.1.1"}}
+	syncer := &integrations{dns: updater}
+	ctx := context.Background()
+
+	// release failed: status and integrations untouched
+	assigner := mocks.NewAssigner(t)
+	assigner.EXPECT().Unassign(tmock.Anything, "test-instance", "test-zone").Return(address.ErrNoStaticIPAssigned).Once()
+	if err := releaseAddress(ctx, log, assigner, recorder, syncer, n, "1.1.1.1"); !errors.Is(err, address.ErrNoStaticIPAssigned) {
+		t.Fatalf("releaseAddress() error = %v, want %v", err, address.ErrNoStaticIPAssigned)
+	}
+	if got := recordedAddress(context.Background(), log, recorder, n); got != "1.1.1.1" {
+		t.Errorf("recordedAddress() = %v, want 1.1.1.1", got)
+	}
+
+	// released: status cleared and DNS record deleted
+	assigner.EXPECT().Unassign(tmock.Anything, "test-instance", "test-zone").Return(nil).Once()
+	if err := releaseAddress(ctx, log, assigner, recorder, syncer, n, "1.1.1.1"); err != nil {
+		t.Fatalf("releaseAddress() error = %v", err)
+	}
+	if got := recordedAddress(context.Background(), log, recorder, n); got != "" {
+		t.Errorf("recordedAddress() = %v, want empty", got)
+	}
+	if _, ok := updater.records["test-node"]; ok {
+		t.Error("releaseAddress() did not delete the DNS record")
+	}
+}
+
+func Test_recordAssignedStatus(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node", Pool: "test-pool"}
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Annotations: map[string]string{
+		node.AddressAnnotation:            "1.1.1.1",
+		node.PoolAnnotation:               "test-pool",
+		node.LastTransitionTimeAnnotation: "2024-03-16T02:00:00Z",
+	}}})
+	recorder := node.NewStatusRecorder(client)
+	ctx := context.Background()
+
+	// restart on a node holding the recorded address: status untouched
+	recordAssignedStatus(ctx, log, recorder, n, &types.AssignmentStatus{Node: n.Name, Address: "1.1.1.1", Pool: n.Pool})
+	status, err := recorder.GetStatus(ctx, n.Name)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if !status.LastTransitionTime.Equal(time.Date(2024, 3, 16, 2, 0, 0, 0, time.UTC)) || len(status.History) != 0 {
+		t.Errorf("recordAssignedStatus() recorded a transition for the recorded address: %+v", status)
+	}
+
+	// new address: transition recorded
+	recordAssignedStatus(ctx, log, recorder, n, &types.AssignmentStatus{Node: n.Name, Address: "2.2.2.2", Pool: n.Pool})
+	status, err = recorder.GetStatus(ctx, n.Name)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Address != "2.2.2.2" || len(status.History) != 1 {
+		t.Errorf("recordAssignedStatus() status = %+v, want address 2.2.2.2 with one transition", status)
+	}
+}
+
+func Test_resumeRetries(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node"}
+	cfg := &config.Config{RetryAttempts: 3, RetryInterval: time.Hour}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        int
+	}{
+		{name: "no recorded retries", want: 0},
+		{name: "recorded retries", annotations: map[string]string{
+			node.RetryAttemptsAnnotation: "2",
+			node.LastRetryTimeAnnotation: "2024-03-16T02:00:00Z",
+		}, want: 2},
+		{name: "retries used up by previous runs", annotations: map[string]string{
+			node.RetryAttemptsAnnotation: "4",
+			node.LastRetryTimeAnnotation: "2024-03-16T02:00:00Z",
+		}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Annotations: tt.annotations}})
+			got, err := resumeRetries(context.Background(), log, node.NewStatusRecorder(client), n, cfg)
+			if err != nil {
+				t.Fatalf("resumeRetries() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resumeRetries() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// a recent failure delays the retries by the rest of the retry interval
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+	recorder := node.NewStatusRecorder(client)
+	recordRetry(context.Background(), log, recorder, n, 1, errors.New("no available addresses"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := resumeRetries(ctx, log, recorder, n, cfg); err == nil {
+		t.Error("resumeRetries() expected to wait for the retry interval")
+	}
+}
+
+// staticFinder finds the claim whatever the labels
+type staticFinder struct {
+	claim *claim.Claim
+	err   error
+}
+
+func (f *staticFinder) Find(context.Context, map[string]string) (*claim.Claim, error) {
+	return f.claim, f.err
+}
+
+func Test_poolTenant(t *testing.T) {
+	entries := []string{"payments-pool=payments", "reporting-pool=reporting", "payments-pool=other"}
+	if got, err := poolTenant(entries, "payments-pool"); err != nil || got != "payments" {
+		t.Errorf("poolTenant() = %q, %v, want payments (first entry of the pool)", got, err)
+	}
+	if got, err := poolTenant(entries, "web-pool"); err != nil || got != "" {
+		t.Errorf("poolTenant() = %q, %v, want no tenant", got, err)
+	}
+	if _, err := poolTenant([]string{"payments-pool"}, "payments-pool"); err == nil {
+		t.Error("poolTenant() error = nil, want an invalid entry error")
+	}
+}
+
+func Test_applyClaim(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "test-node"}
+	if err := applyClaim(context.Background(), log, &staticFinder{}, n); err != nil || n.ClaimedAddress != "" {
+		t.Errorf("applyClaim() without claim: error = %v, claimed address = %q", err, n.ClaimedAddress)
+	}
+	if err := applyClaim(context.Background(), log, &staticFinder{err: errors.New("forbidden")}, n); err == nil {
+		t.Error("applyClaim() error = nil, want the lookup error")
+	}
+	c := &claim.Claim{Name: "payments", NodeSelector: "app=payments", Address: "1.1.1.1"}
+	if err := applyClaim(context.Background(), log, &staticFinder{claim: c}, n); err != nil || n.ClaimedAddress != "1.1.1.1" {
+		t.Errorf("applyClaim() error = %v, claimed address = %q, want 1.1.1.1", err, n.ClaimedAddress)
+	}
+}
+
+func Test_assignFunc(t *testing.T) {
+	log := prepareLogger("debug", false)
+	cfg := &config.Config{Filter: []string{"test-filter"}, OrderBy: "test-order-by"}
+	ctx := context.Background()
+
+	// no claim: an address of the pool
+	n := &types.Node{Name: "test-node", Instance: "test-instance", Zone: "test-zone"}
+	pool := mocks.NewAssigner(t)
+	pool.EXPECT().Assign(tmock.Anything, "test-instance", "test-zone", cfg.Filter, cfg.OrderBy).Return("2.2.2.2", nil).Once()
+	assign, err := assignFunc(log, pool, n, cfg)
+	if err != nil {
+		t.Fatalf("assignFunc() error = %v", err)
+	}
+	if got, err := assign(ctx); err != nil || got != "2.2.2.2" {
+		t.Errorf("assign() = %v, %v, want 2.2.2.2", got, err)
+	}
+
+	// claimed address
+	n.ClaimedAddress = "1.1.1.1"
+	claimer := &claimAssigner{held: map[string]string{}}
+	if assign, err = assignFunc(log, claimer, n, cfg); err != nil {
+		t.Fatalf("assignFunc() error = %v", err)
+	}
+	if got, err := assign(ctx); err != nil || got != "1.1.1.1" {
+		t.Errorf("assign() = %v, %v, want 1.1.1.1", got, err)
+	}
+
+	// claimed address with an assigner not claiming addresses
+	if _, err = assignFunc(log, mocks.NewAssigner(t), n, cfg); !errors.Is(err, errClaimUnsupported) {
+		t.Errorf("assignFunc() error = %v, want %v", err, errClaimUnsupported)
+	}
+}
+
+func Test_releaseIgnored(t *testing.T) {
+	tests := []struct {
+		name        string
+		unassignErr error
+		factoryErr  error
+		wantAddress string
+	}{
+		{
+			name: "static public IP address released",
+		},
+		{
+			name:        "no static public IP address assigned",
+			unassignErr: address.ErrNoStaticIPAssigned,
+			wantAddress: "1.1.1.1",
+		},
+		{
+			name:        "assigner initialization failed",
+			factoryErr:  errors.New("missing credentials"),
+			wantAddress: "1.1.1.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := prepareLogger("debug", false)
+			n := &types.Node{Name: "test-node", Instance: "test-instance", Zone: "test-zone"}
+			client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", Annotations: map[string]string{
+				node.IgnoreAnnotation:             "true",
+				node.AddressAnnotation:            "1.1.1.1",
+				node.LastTransitionTimeAnnotation: "2024-03-16T02:00:00Z",
+			}}})
+			factory := func(_ context.Context, _ *logrus.Entry, _ *types.Node, _ *config.Config) (address.Assigner, error) {
+				if tt.factoryErr != nil {
+					return nil, tt.factoryErr
+				}
+				mock := mocks.NewAssigner(t)
+				mock.EXPECT().Unassign(tmock.Anything, "test-instance", "test-zone").Return(tt.unassignErr)
+				return mock, nil
+			}
+			releaseIgnored(context.Background(), log, client, factory, n, &config.Config{})
+			if got := recordedAddress(context.Background(), log, node.NewStatusRecorder(client), n); got != tt.wantAddress {
+				t.Errorf("releaseIgnored() recorded address = %v, want %v", got, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func Test_logCandidate(t *testing.T) {
+	n := &types.Node{Name: "test-node", Instance: "test-instance", Zone: "test-zone"}
+	cfg := &config.Config{Filter: []string{"test-filter"}, OrderBy: "test-order-by"}
+	tests := []struct {
+		name        string
+		candidate   string
+		err         error
+		wantLevel   logrus.Level
+		wantAddress interface{}
+	}{
+		{
+			name:        "candidate address",
+			candidate:   "1.1.1.1",
+			wantLevel:   logrus.InfoLevel,
+			wantAddress: "1.1.1.1",
+		},
+		{
+			name:      "no available address",
+			err:       address.ErrNoAvailableAddress,
+			wantLevel: logrus.WarnLevel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			assigner := mocks.NewAssigner(t)
+			assigner.EXPECT().Candidate(tmock.Anything, "test-instance", "test-zone", []string{"test-filter"}, "test-order-by").Return(tt.candidate, tt.err)
+			logCandidate(context.Background(), logrus.NewEntry(logger), assigner, n, cfg)
+			entry := hook.LastEntry()
+			if entry == nil || entry.Level != tt.wantLevel {
+				t.Fatalf("logCandidate() entry = %v, want level %v", entry, tt.wantLevel)
+			}
+			if entry.Data["address"] != tt.wantAddress {
+				t.Errorf("logCandidate() address = %v, want %v", entry.Data["address"], tt.wantAddress)
+			}
+		})
+	}
+}
+
+func Test_gcpProjectConfig(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "node-1", Cloud: types.CloudProviderGCP, Project: "node-project"}
+
+	cfg := &config.Config{Region: "us-central1"}
+	got := gcpProjectConfig(log, n, cfg)
+	if got.Project != "node-project" || got.Region != "us-central1" {
+		t.Errorf("gcpProjectConfig() = %+v, want the project of the node", got)
+	}
+	if cfg.Project != "" {
+		t.Errorf("gcpProjectConfig() changed the configuration")
+	}
+
+	cfg = &config.Config{Project: "configured-project"}
+	if got = gcpProjectConfig(log, n, cfg); got != cfg {
+		t.Errorf("gcpProjectConfig() = %+v, want the configured project", got)
+	}
+
+	cfg = &config.Config{}
+	if got = gcpProjectConfig(log, &types.Node{Cloud: types.CloudProviderAWS}, cfg); got != cfg {
+		t.Errorf("gcpProjectConfig() = %+v, want the configuration of the AWS node", got)
+	}
+}
+
+func Test_providerConfig(t *testing.T) {
+	log := prepareLogger("debug", false)
+	cfg := &config.Config{
+		Filter:          []string{"labels.env=dev"},
+		ProviderFilters: []string{"aws=Name=tag:env,Values=dev", "gcp=labels.app=streamer", "aws=Name=tag:app,Values=streamer"},
+	}
+
+	got, err := providerConfig(log, &types.Node{Name: "node-1", Cloud: types.CloudProviderAWS}, cfg)
+	if err != nil {
+		t.Fatalf("providerConfig() error = %v", err)
+	}
+	if want := []string{"Name=tag:env,Values=dev", "Name=tag:app,Values=streamer"}; !reflect.DeepEqual(got.Filter, want) {
+		t.Errorf("providerConfig() filter = %v, want %v", got.Filter, want)
+	}
+	if len(cfg.Filter) != 1 {
+		t.Errorf("providerConfig() changed the configuration")
+	}
+
+	// no filter for the provider: the common filters apply
+	if got, err = providerConfig(log, &types.Node{Name: "node-2", Cloud: types.CloudProviderOCI}, cfg); err != nil || got != cfg {
+		t.Errorf("providerConfig() = %+v, %v, want the configuration", got, err)
+	}
+
+	if _, err = providerConfig(log, &types.Node{Cloud: types.CloudProviderGCP}, &config.Config{ProviderFilters: []string{"labels.env=dev"}}); err == nil {
+		t.Error("providerConfig() expected error for a filter without provider")
+	}
+}
+
+func Test_providerConfig_template(t *testing.T) {
+	log := prepareLogger("debug", false)
+	cfg := &config.Config{
+		Filter:          []string{`labels.pool={{ index .Node.Labels "cloud.google.com/gke-nodepool" }}`, "labels.env=prod"},
+		ProviderFilters: []string{"aws=Name=tag:pool,Values={{ .Node.Labels.nodepool }}"},
+	}
+	n := &types.Node{
+		Name:   "node-1",
+		Cloud:  types.CloudProviderGCP,
+		Labels: map[string]string{"cloud.google.com/gke-nodepool": "egress", "nodepool": "batch"},
+	}
+
+	got, err := providerConfig(log, n, cfg)
+	if err != nil {
+		t.Fatalf("providerConfig() error = %v", err)
+	}
+	if want := []string{"labels.pool=egress", "labels.env=prod"}; !reflect.DeepEqual(got.Filter, want) {
+		t.Errorf("providerConfig() filter = %v, want %v", got.Filter, want)
+	}
+	if cfg.Filter[0] == got.Filter[0] {
+		t.Errorf("providerConfig() changed the configuration")
+	}
+
+	n.Cloud = types.CloudProviderAWS
+	if got, err = providerConfig(log, n, cfg); err != nil {
+		t.Fatalf("providerConfig() error = %v", err)
+	}
+	if want := []string{"Name=tag:pool,Values=batch"}; !reflect.DeepEqual(got.Filter, want) {
+		t.Errorf("providerConfig() filter = %v, want %v", got.Filter, want)
+	}
+
+	// a missing label fails instead of matching the addresses of another pool
+	n.Labels = nil
+	if _, err = providerConfig(log, n, cfg); err == nil {
+		t.Error("providerConfig() expected error for a missing label")
+	}
+}
+
+func Test_renderNodeTemplate(t *testing.T) {
+	n := &types.Node{Name: "node-1", Pool: "batch"}
+	if got, err := renderNodeTemplate(n, "{{ .Node.Pool }}-egress"); err != nil || got != "batch-egress" {
+		t.Errorf("renderNodeTemplate() = %q, %v, want batch-egress", got, err)
+	}
+	if got, err := renderNodeTemplate(n, defaultAddressPool); err != nil || got != defaultAddressPool {
+		t.Errorf("renderNodeTemplate() = %q, %v, want %s", got, err, defaultAddressPool)
+	}
+	if _, err := renderNodeTemplate(n, "{{ .Node.Pool"); err == nil {
+		t.Error("renderNodeTemplate() expected error for an invalid template")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/health"
+	"github.com/doitintl/kubeip/internal/httpserver"
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// managerDisabled disables a built-in server of the manager
+const managerDisabled = "0"
+
+// runnable is a task run by the manager; the tasks not needing the leader election run on every replica
+type runnable struct {
+	start          func(ctx context.Context) error
+	leaderElection bool
+}
+
+func (r *runnable) Start(ctx context.Context) error {
+	return r.start(ctx)
+}
+
+func (r *runnable) NeedLeaderElection() bool {
+	return r.leaderElection
+}
+
+// newLogger returns the logger of the manager, logging through the agent logger at debug level
+func newLogger(log *logrus.Entry) logr.Logger {
+	return funcr.New(func(prefix, args string) {
+		log.WithField("logger", prefix).Debug(args)
+	}, funcr.Options{})
+}
+
+// shutdownTimeout is the time the manager waits for the tasks to return once stopped: the in-flight cloud mutation
+// drains, the static public IP address is released and the status recorded
+func shutdownTimeout(cfg *config.Config) time.Duration {
+	return cfg.DrainTimeout + unassignTimeout + recordStatusTimeout
+}
+
+// newManager returns the manager of the agent or the controller, electing a single replica with the named lease if set;
+// the metrics and the health probes are served with the kubeip registry and readiness over TLS if configured, the
+// built-in servers of the manager being disabled
+func newManager(log *logrus.Entry, restconfig *rest.Config, cfg *config.Config, leaderElectionID string) (manager.Manager, error) {
+	logger := newLogger(log)
+	ctrllog.SetLogger(logger)
+	leaseDuration := time.Duration(cfg.LeaseDuration) * time.Second
+	renewDeadline, retryPeriod := leaseDuration*2/3, leaseDuration/5 //nolint:gomnd
+	timeout := shutdownTimeout(cfg)
+	mgr, err := manager.New(restconfig, manager.Options{
+		Logger:                        logger,
+		Metrics:                       metricsserver.Options{BindAddress: managerDisabled},
+		HealthProbeBindAddress:        managerDisabled,
+		LeaderElection:                leaderElectionID != "",
+		LeaderElectionID:              leaderElectionID,
+		LeaderElectionNamespace:       cfg.LeaseNamespace,
+		LeaderElectionReleaseOnCancel: true,
+		LeaseDuration:                 &leaseDuration,
+		RenewDeadline:                 &renewDeadline,
+		RetryPeriod:                   &retryPeriod,
+		GracefulShutdownTimeout:       &timeout,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing manager")
+	}
+	if cfg.MetricsAddress != "" {
+		t := httpserver.TLS{CertFile: cfg.MetricsTLSCertFile, KeyFile: cfg.MetricsTLSKeyFile, ClientCAFile: cfg.MetricsTLSClientCAFile}
+		if err = mgr.Add(&runnable{start: func(ctx context.Context) error {
+			return errors.Wrap(metrics.Serve(ctx, log, cfg.MetricsAddress, metrics.Default, t), "serving metrics")
+		}}); err != nil {
+			return nil, errors.Wrap(err, "adding metrics server")
+		}
+	}
+	if cfg.HealthAddress != "" {
+		t := httpserver.TLS{CertFile: cfg.HealthTLSCertFile, KeyFile: cfg.HealthTLSKeyFile, ClientCAFile: cfg.HealthTLSClientCAFile}
+		if err = mgr.Add(&runnable{start: func(ctx context.Context) error {
+			return errors.Wrap(health.Serve(ctx, log, cfg.HealthAddress, health.Default, t), "serving health probes")
+		}}); err != nil {
+			return nil, errors.Wrap(err, "adding health probes server")
+		}
+	}
+	return mgr, nil
+}
+
+// runManager runs the task with the manager until the context is done (SIGTERM, SIGINT) or the task returns, stopping
+// the manager; returns the error of the task, of a server or of the leader election
+func runManager(ctx context.Context, mgr manager.Manager, leaderElection bool, task func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := mgr.Add(&runnable{start: func(ctx context.Context) error {
+		// a failed task stops the manager with its error
+		err := task(ctx)
+		if err == nil {
+			cancel()
+		}
+		return err
+	}, leaderElection: leaderElection})
+	if err != nil {
+		return errors.Wrap(err, "adding task")
+	}
+	return errors.Wrap(mgr.Start(ctx), "running manager")
+}
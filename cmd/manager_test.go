@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func Test_runManager(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	// the agent manager does not call the API server: no leader election and no cache
+	restconfig := &rest.Config{Host: "http://127.0.0.1:1"}
+	cfg := &config.Config{MetricsAddress: "127.0.0.1:0", HealthAddress: "127.0.0.1:0", LeaseDuration: 5}
+
+	t.Run("task returns", func(t *testing.T) {
+		mgr, err := newManager(logger, restconfig, cfg, "")
+		require.NoError(t, err)
+		assert.NoError(t, runManager(context.Background(), mgr, false, func(context.Context) error { return nil }))
+	})
+	t.Run("task fails", func(t *testing.T) {
+		mgr, err := newManager(logger, restconfig, cfg, "")
+		require.NoError(t, err)
+		err = runManager(context.Background(), mgr, false, func(context.Context) error { return errors.New("node not found") })
+		assert.ErrorContains(t, err, "node not found")
+	})
+	t.Run("signal stops the task", func(t *testing.T) {
+		mgr, err := newManager(logger, restconfig, cfg, "")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		started, stopped := make(chan struct{}), make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- runManager(ctx, mgr, false, func(ctx context.Context) error {
+				close(started)
+				<-ctx.Done()
+				close(stopped)
+				return nil
+			})
+		}()
+		<-started
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("runManager() did not return once the context was done")
+		}
+		// the manager waits for the task to return
+		select {
+		case <-stopped:
+		default:
+			t.Fatal("runManager() returned before the task")
+		}
+	})
+}
+
+func Test_shutdownTimeout(t *testing.T) {
+	assert.Equal(t, 20*time.Second+unassignTimeout+recordStatusTimeout, shutdownTimeout(&config.Config{DrainTimeout: 20 * time.Second}))
+}
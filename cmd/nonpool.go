@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/doitintl/kubeip/pkg/ipam"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// policies of a static public IP address held by the node outside the pool
+const (
+	nonPoolKeep    = string(ipam.NonPoolKeep)
+	nonPoolReplace = string(ipam.NonPoolReplace)
+	nonPoolFail    = string(ipam.NonPoolFail)
+)
+
+// errNonPoolAddress refuses the static public IP address held by the node outside the pool (fail policy)
+var errNonPoolAddress = errors.New("node holds a static public IP address outside the pool")
+
+// applyNonPoolPolicy applies the non-pool address policy to the static public IP address held by the node and returns
+// whether it was released to assign an address of the pool instead; the address is kept with assigners not telling the
+// addresses of the pool apart, or if the check fails
+func applyNonPoolPolicy(ctx context.Context, log *logrus.Entry, assigner address.Assigner, n *types.Node, heldAddress string, cfg *config.Config, syncer *integrations) (bool, error) {
+	checker, ok := assigner.(address.PoolChecker)
+	if !ok || heldAddress == "" {
+		return false, nil
+	}
+	logger := log.WithFields(logrus.Fields{"node": n.Name, "address": heldAddress, "filter": cfg.Filter, "policy": cfg.NonPoolAddress})
+	inPool, err := checker.InPool(ctx, heldAddress, cfg.Filter)
+	if err != nil {
+		logger.WithError(err).Warn("failed to check if the held static public IP address is in the pool, keeping it")
+		return false, nil
+	}
+	if inPool {
+		return false, nil
+	}
+
+	result := metrics.ResultKept
+	defer func() {
+		metrics.ObserveNonPoolAddress(string(n.Cloud), n.AddressPool, n.Pool, n.Name, result)
+		syncer.nonPool(ctx, log, n, heldAddress, result)
+	}()
+	decision, err := ipam.Decide(ipam.Request{Held: heldAddress, NonPoolPolicy: ipam.NonPoolPolicy(cfg.NonPoolAddress)})
+	if err != nil {
+		result = metrics.ResultRefused
+		return false, err //nolint:wrapcheck
+	}
+	switch decision.Action {
+	case ipam.ActionKeep:
+		logger.Warn("node holds a static public IP address outside the pool, keeping it")
+		return false, nil
+	case ipam.ActionReplace:
+		if err = releaseIP(ctx, assigner, n); err != nil {
+			result = metrics.ResultFailure
+			return false, errors.Wrap(err, "releasing static public IP address outside the pool")
+		}
+		result = metrics.ResultReplaced
+		logger.Info("static public IP address outside the pool released, assigning an address of the pool")
+		return true, nil
+	default:
+		result = metrics.ResultRefused
+		logger.Error("node holds a static public IP address outside the pool, refusing it")
+		return false, errors.Wrap(errNonPoolAddress, heldAddress)
+	}
+}
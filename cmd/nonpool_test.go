@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/doitintl/kubeip/internal/sink"
+	"github.com/doitintl/kubeip/internal/types"
+	mocks "github.com/doitintl/kubeip/mocks/address"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	tmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// poolAssigner is an assigner telling the addresses of the pool apart
+type poolAssigner struct {
+	*mocks.Assigner
+	pool []string
+	err  error
+}
+
+func (a *poolAssigner) InPool(_ context.Context, address string, _ []string) (bool, error) {
+	for _, pooled := range a.pool {
+		if pooled == address {
+			return true, a.err
+		}
+	}
+	return false, a.err
+}
+
+// recordingSink records the published events
+type recordingSink struct {
+	events []*sink.Event
+}
+
+func (s *recordingSink) Publish(_ context.Context, event *sink.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+// quotaAssigner is an assigner reporting its quota
+type quotaAssigner struct {
+	*mocks.Assigner
+	quota *address.Quota
+}
+
+func (a *quotaAssigner) Quota(context.Context) (*address.Quota, error) {
+	return a.quota, nil
+}
+
+func Test_checkQuota(t *testing.T) {
+	n := &types.Node{Name: "node-1", Cloud: types.CloudProviderGCP}
+	log := logrus.NewEntry(logrus.New())
+	events := &recordingSink{}
+	syncer := &integrations{sink: events}
+	cfg := &config.Config{QuotaCheck: true}
+
+	checkQuota(context.Background(), log, &quotaAssigner{quota: &address.Quota{Name: "STATIC_ADDRESSES", Limit: 8, Usage: 5}}, n, cfg, syncer)
+	assert.Equal(t, float64(3), metrics.QuotaRemaining.Value("gcp", "STATIC_ADDRESSES"))
+	assert.Empty(t, events.events)
+
+	checkQuota(context.Background(), log, &quotaAssigner{quota: &address.Quota{Name: "STATIC_ADDRESSES", Limit: 8, Usage: 8}}, n, cfg, syncer)
+	assert.Equal(t, float64(0), metrics.QuotaRemaining.Value("gcp", "STATIC_ADDRESSES"))
+	require.Len(t, events.events, 1)
+	assert.Equal(t, sink.EventQuotaExhausted, events.events[0].Type)
+	assert.Equal(t, "STATIC_ADDRESSES", events.events[0].Quota)
+
+	// disabled
+	checkQuota(context.Background(), log, &quotaAssigner{quota: &address.Quota{Name: "STATIC_ADDRESSES", Limit: 8, Usage: 8}}, n, &config.Config{}, syncer)
+	assert.Len(t, events.events, 1)
+}
+
+func Test_applyNonPoolPolicy(t *testing.T) {
+	n := &types.Node{Name: "node-1", Instance: "i-1", Zone: "zone", Cloud: types.CloudProviderAWS}
+	log := logrus.NewEntry(logrus.New())
+	tests := []struct {
+		name     string
+		policy   string
+		held     string
+		checkErr error
+		release  bool
+		replaced bool
+		wantErr  bool
+		result   string
+	}{
+		{name: "address of the pool", policy: nonPoolFail, held: "100.0.0.1"},
+		{name: "check failed, address kept", policy: nonPoolFail, held: "200.0.0.1", checkErr: errors.New("throttled")},
+		{name: "keep by default", held: "200.0.0.1", result: "kept"},
+		{name: "keep", policy: nonPoolKeep, held: "200.0.0.1", result: "kept"},
+		{name: "replace", policy: nonPoolReplace, held: "200.0.0.1", release: true, replaced: true, result: "replaced"},
+		{name: "fail", policy: nonPoolFail, held: "200.0.0.1", wantErr: true, result: "refused"},
+		{name: "unknown policy", policy: "swap", held: "200.0.0.1", wantErr: true, result: "refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assigner := &poolAssigner{Assigner: mocks.NewAssigner(t), pool: []string{"100.0.0.1"}, err: tt.checkErr}
+			if tt.release {
+				assigner.EXPECT().Unassign(tmock.Anything, "i-1", "zone").Return(nil).Once()
+			}
+			events := &recordingSink{}
+			syncer := &integrations{sink: events}
+			replaced, err := applyNonPoolPolicy(context.Background(), log, assigner, n, tt.held, &config.Config{NonPoolAddress: tt.policy}, syncer)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.replaced, replaced)
+			if tt.result == "" {
+				assert.Empty(t, events.events)
+				return
+			}
+			require.Len(t, events.events, 1)
+			assert.Equal(t, sink.EventNonPool, events.events[0].Type)
+			assert.Equal(t, tt.held, events.events[0].Address)
+			assert.Equal(t, tt.result, events.events[0].Result)
+		})
+	}
+}
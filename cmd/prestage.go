@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/claim"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/karpenter"
+	"github.com/doitintl/kubeip/internal/lease"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// nodeClaimResyncInterval is the interval of the checks of the instances Karpenter launches besides their changes
+	nodeClaimResyncInterval = time.Minute
+	// prestageLeaseName is the lease electing the agent staging the addresses
+	prestageLeaseName = "kubeip-prestage"
+)
+
+// prestager stages the addresses of the instances Karpenter launches
+type prestager struct {
+	// lock is the cluster wide lock of the assignments, held with the identity of the stager
+	lock     lease.KubeLock
+	stager   karpenter.Stager
+	assigner address.Assigner
+	// finder finds the KubeIPClaims of the NodeClaims; nil without claims
+	finder claim.Finder
+	// cloud is the cloud provider of the agent node, the only one the assigner reaches
+	cloud types.CloudProvider
+	// cfg is the configuration of the cluster, not rendered for the agent node
+	cfg *config.Config
+}
+
+// newPrestageLock returns the cluster wide lock of the assignments for the stager of the agent node: the lock is
+// re-entrant per holder identity, so the stager holds it with its own identity apart from the assignments of the node
+func newPrestageLock(client kubernetes.Interface, n *types.Node, cfg *config.Config) lease.KubeLock {
+	return lease.NewKubeLeaseLock(client, kubeipLockName, cfg.LeaseNamespace, prestageLeaseName+"/"+n.Name, cfg.LeaseDuration)
+}
+
+// prestageNodeClaims assigns a static public IP address to the instances Karpenter launches for the node pools as soon
+// as their provider ID is known, before their node registers and their agent starts: the agent of the new node then
+// finds the address already held; a single agent, holding the prestage lease, watches the NodeClaims and stages them;
+// runs until the context is done
+func prestageNodeClaims(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, p *prestager, n *types.Node) {
+	if len(p.cfg.KarpenterNodePools) == 0 {
+		return
+	}
+	logger := log.WithField("karpenter-nodepools", p.cfg.KarpenterNodePools)
+	elector := lease.NewElector(client, logger, prestageLeaseName, p.cfg.LeaseNamespace, n.Name, time.Duration(p.cfg.LeaseDuration)*time.Second)
+	elector.Run(ctx, func(ctx context.Context) {
+		err := p.stager.Watch(ctx, p.cfg.KarpenterNodePools, nodeClaimResyncInterval, func(ctx context.Context, claims []karpenter.NodeClaim) {
+			if err := p.prestage(ctx, logger, claims); err != nil {
+				logger.WithError(err).Warn("failed to pre-stage static public IP addresses of Karpenter NodeClaims")
+			}
+		})
+		if err != nil {
+			logger.WithError(err).Error("watching Karpenter NodeClaims failed")
+		}
+	})
+}
+
+// prestage assigns an address to the launched NodeClaims of the cloud provider of the agent node, holding the cluster
+// wide lock of the assignments
+func (p *prestager) prestage(ctx context.Context, log *logrus.Entry, claims []karpenter.NodeClaim) error {
+	if err := p.lock.Lock(ctx); err != nil {
+		return errors.Wrap(err, "failed to acquire lock")
+	}
+	drainCtx, drainCancel := drainContext(ctx, p.cfg.DrainTimeout)
+	defer drainCancel()
+	defer p.lock.Unlock(drainCtx) //nolint:errcheck
+	for _, nodeClaim := range claims {
+		logger := log.WithFields(logrus.Fields{"nodeclaim": nodeClaim.Name, "provider-id": nodeClaim.ProviderID})
+		node, err := p.claimNode(nodeClaim)
+		if err != nil {
+			logger.WithError(err).Warn("invalid provider ID of Karpenter NodeClaim, skipping pre-staging")
+			continue
+		}
+		// the assigner only reaches the addresses of the cloud provider of the agent node
+		if node.Cloud != p.cloud {
+			continue
+		}
+		assigned, err := p.assign(drainCtx, log, node)
+		if err != nil && !errors.Is(err, address.ErrStaticIPAlreadyAssigned) {
+			logger.WithError(err).Warn("failed to pre-stage static public IP address, the agent of the node assigns it")
+			continue
+		}
+		if err = p.stager.Stage(drainCtx, nodeClaim.Name, assigned); err != nil {
+			logger.WithError(err).Warn("failed to record pre-staged static public IP address")
+			continue
+		}
+		logger.WithField("address", assigned).Info("static public IP address pre-staged for Karpenter NodeClaim")
+	}
+	return nil
+}
+
+// claimNode returns the future node of the NodeClaim: its instance, the NodePool as node pool and its labels
+func (p *prestager) claimNode(nodeClaim karpenter.NodeClaim) (*types.Node, error) {
+	cloud, instance, zone, err := nd.ParseProviderID(nodeClaim.ProviderID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if zone == "" {
+		zone = nodeClaim.Zone
+	}
+	return &types.Node{
+		Name:     nodeClaim.Name,
+		Instance: instance,
+		Cloud:    cloud,
+		Pool:     nodeClaim.NodePool,
+		Zone:     zone,
+		Labels:   nodeClaim.Labels,
+	}, nil
+}
+
+// assign assigns the address the agent of the node would: the address of the KubeIPClaim matching the labels of the
+// NodeClaim, or an address of the pool with the filters of the cluster rendered for the NodeClaim
+func (p *prestager) assign(ctx context.Context, log *logrus.Entry, node *types.Node) (string, error) {
+	cfg, err := providerConfig(log, node, p.cfg)
+	if err != nil {
+		return "", err
+	}
+	if p.finder != nil {
+		if err = applyClaim(ctx, log, p.finder, node); err != nil {
+			return "", errors.Wrap(err, "finding address claim")
+		}
+	}
+	assign, err := assignFunc(log, p.assigner, node, cfg)
+	if err != nil {
+		return "", err
+	}
+	return assign(ctx)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/claim"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/karpenter"
+	"github.com/doitintl/kubeip/internal/types"
+	mocks "github.com/doitintl/kubeip/mocks/address"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	tmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeStager is a stager of NodeClaims in memory
+type fakeStager struct {
+	mu     sync.Mutex
+	claims []karpenter.NodeClaim
+	staged map[string]string
+}
+
+func (s *fakeStager) Watch(ctx context.Context, _ []string, _ time.Duration, launched func(ctx context.Context, claims []karpenter.NodeClaim)) error {
+	launched(ctx, s.claims)
+	<-ctx.Done()
+	return nil
+}
+
+func (s *fakeStager) Stage(_ context.Context, name, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staged[name] = address
+	return nil
+}
+
+func (s *fakeStager) stagedAddresses() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	staged := make(map[string]string, len(s.staged))
+	for name, address := range s.staged {
+		staged[name] = address
+	}
+	return staged
+}
+
+// labelFinder finds the claim of the nodes with the label
+type labelFinder struct {
+	label string
+	claim *claim.Claim
+}
+
+func (f *labelFinder) Find(_ context.Context, nodeLabels map[string]string) (*claim.Claim, error) {
+	if _, ok := nodeLabels[f.label]; ok {
+		return f.claim, nil
+	}
+	return nil, nil //nolint:nilnil
+}
+
+func Test_prestager_prestage(t *testing.T) {
+	log := prepareLogger("debug", false)
+	n := &types.Node{Name: "node-1", Instance: "i-node-1", Cloud: types.CloudProviderAWS}
+	// the filters of the cluster are rendered for each NodeClaim, not for the agent node
+	cfg := &config.Config{KarpenterNodePools: []string{"public"}, Filter: []string{"labels.team={{ .Node.Labels.team }}"}, OrderBy: "test-order-by",
+		LeaseDuration: 5, Claims: true}
+	labels := func(team string, extra ...string) map[string]string {
+		l := map[string]string{karpenter.NodePoolLabel: "public", "team": team}
+		for _, key := range extra {
+			l[key] = "true"
+		}
+		return l
+	}
+	stager := &fakeStager{
+		claims: []karpenter.NodeClaim{
+			{Name: "public-a", NodePool: "public", ProviderID: "aws:///us-west-2a/i-0a", Zone: "us-west-2a", Labels: labels("web")},
+			{Name: "public-b", NodePool: "public", ProviderID: "aws:///us-west-2a/i-0b", Zone: "us-west-2a", Labels: labels("api")},
+			{Name: "public-c", NodePool: "public", ProviderID: "aws:///us-west-2a/i-0c", Zone: "us-west-2a", Labels: labels("db", "dedicated")},
+			{Name: "public-d", NodePool: "public", ProviderID: "aws:///us-west-2a/i-0d", Zone: "us-west-2a", Labels: map[string]string{}},
+			{Name: "public-gcp", NodePool: "public", ProviderID: "gce://test-project/us-central1-a/test-instance"},
+		},
+		staged: map[string]string{},
+	}
+	assigner := mocks.NewAssigner(t)
+	assigner.EXPECT().Assign(tmock.Anything, "i-0a", "us-west-2a", []string{"labels.team=web"}, cfg.OrderBy).Return("203.0.113.1", nil).Once()
+	assigner.EXPECT().Assign(tmock.Anything, "i-0b", "us-west-2a", []string{"labels.team=api"}, cfg.OrderBy).Return("", errors.New("no address")).Once()
+	client := fake.NewSimpleClientset()
+	p := &prestager{
+		lock:     newPrestageLock(client, n, cfg),
+		stager:   stager,
+		assigner: &claimAssigner{Assigner: assigner, held: map[string]string{}},
+		finder:   &labelFinder{label: "dedicated", claim: &claim.Claim{Name: "db", Address: "198.51.100.7"}},
+		cloud:    n.Cloud,
+		cfg:      cfg,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		prestageNodeClaims(ctx, log, client, p, n)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(stager.stagedAddresses()) == 2 }, 10*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	// the KubeIPClaim of the NodeClaim is claimed, the failed pre-staging is left to the agent of the node, the NodeClaim
+	// missing the label of the filter and the NodeClaim of another cloud provider are skipped
+	assert.Equal(t, map[string]string{"public-a": "203.0.113.1", "public-c": "198.51.100.7"}, stager.stagedAddresses())
+}
+
+func Test_newPrestageLock(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	n := &types.Node{Name: "node-1", Instance: "i-node-1"}
+	cfg := &config.Config{LeaseNamespace: "default", LeaseDuration: 5}
+	lock := newPrestageLock(client, n, cfg)
+	require.NoError(t, lock.Lock(ctx))
+	defer lock.Unlock(ctx) //nolint:errcheck
+
+	// the lock is re-entrant per identity: the stager must not hold it as the agent of its node
+	l, err := client.CoordinationV1().Leases(cfg.LeaseNamespace).Get(ctx, kubeipLockName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "kubeip-prestage/node-1", *l.Spec.HolderIdentity)
+}
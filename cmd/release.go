@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/internal/config"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+var (
+	errNodeOrAddressRequired = errors.New("either node name or IP address must be specified")
+	errNodeNotFound          = errors.New("no node reports the given IP address")
+)
+
+// findNodeByAddress returns the name of the node reporting the given external IP address
+func findNodeByAddress(ctx context.Context, client kubernetes.Interface, ip string) (string, error) {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list kubernetes nodes")
+	}
+	for i := range nodes.Items {
+		for _, a := range nodes.Items[i].Status.Addresses {
+			if a.Type == v1.NodeExternalIP && a.Address == ip {
+				return nodes.Items[i].Name, nil
+			}
+		}
+	}
+	return "", errors.Wrapf(errNodeNotFound, "address %s", ip)
+}
+
+// nodeHasExternalIP checks if the node reports the given external IP address
+func nodeHasExternalIP(n *types.Node, ip string) bool {
+	for _, externalIP := range n.ExternalIPs {
+		if externalIP.String() == ip {
+			return true
+		}
+	}
+	return false
+}
+
+func release(ctx context.Context, log *logrus.Entry, client kubernetes.Interface, cfg *config.Config, ip string) error {
+	nodeName := cfg.NodeName
+	if nodeName == "" {
+		if ip == "" {
+			return errNodeOrAddressRequired
+		}
+		var err error
+		nodeName, err = findNodeByAddress(ctx, client, ip)
+		if err != nil {
+			return errors.Wrap(err, "finding node by address")
+		}
+	}
+
+	explorer := newExplorer(client, cfg)
+	n, err := explorer.GetNode(ctx, nodeName)
+	if err != nil {
+		return errors.Wrap(err, "getting node")
+	}
+	log.WithField("node", n).Debug("node discovery done")
+
+	// refuse to release if the node does not hold the requested address
+	if ip != "" && !nodeHasExternalIP(n, ip) {
+		return errors.Errorf("node %s does not report address %s", n.Name, ip)
+	}
+
+	assigner, err := newAssigner(ctx, log, n, cfg)
+	if err != nil {
+		return errors.Wrap(err, "initializing assigner")
+	}
+
+	syncer, err := newIntegrations(ctx, log, cfg, client)
+	if err != nil {
+		return err
+	}
+
+	// withdraw the released address from the integrations: the requested address or the recorded one
+	recorder := nd.NewStatusRecorder(client)
+	releasedAddress := ip
+	if releasedAddress == "" {
+		releasedAddress = recordedAddress(ctx, log, recorder, n)
+	}
+
+	logger := log.WithFields(logrus.Fields{
+		"node":     n.Name,
+		"instance": n.Instance,
+		"address":  releasedAddress,
+	})
+	if err = releaseAddress(ctx, log, assigner, recorder, syncer, n, releasedAddress); err != nil {
+		// nothing to release is not a failure for a manual release
+		if errors.Is(err, address.ErrNoStaticIPAssigned) || errors.Is(err, address.ErrNoPublicIPAssigned) {
+			logger.Warn("no static public IP address assigned to node, nothing to release")
+			return nil
+		}
+		return err
+	}
+	logger.Info("static public IP address released")
+	return nil
+}
+
+func releaseCmd(c *cli.Context) error {
+	ctx := signals.SetupSignalHandler()
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	cfg := config.NewConfig(c)
+
+	client, err := newKubernetesClient(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing kubernetes client")
+		return err
+	}
+
+	if err = release(ctx, log, client, cfg, c.String("ip")); err != nil {
+		log.WithError(err).Error("error releasing static public IP address")
+		return err
+	}
+
+	return nil
+}
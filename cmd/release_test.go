@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_findNodeByAddress(t *testing.T) {
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: v1.NodeExternalIP, Address: "1.1.1.1"},
+			}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.2"},
+				{Type: v1.NodeExternalIP, Address: "2.2.2.2"},
+			}},
+		},
+	}
+	tests := []struct {
+		name    string
+		ip      string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "find node by external IP",
+			ip:   "2.2.2.2",
+			want: "node-2",
+		},
+		{
+			name:    "internal IP does not match",
+			ip:      "10.0.0.1",
+			wantErr: true,
+		},
+		{
+			name:    "unknown IP",
+			ip:      "3.3.3.3",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(nodes[0], nodes[1])
+			got, err := findNodeByAddress(context.Background(), client, tt.ip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findNodeByAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("findNodeByAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nodeHasExternalIP(t *testing.T) {
+	n := &types.Node{
+		Name:        "test-node",
+		ExternalIPs: []net.IP{net.IPv4(1, 1, 1, 1)},
+		InternalIPs: []net.IP{net.IPv4(10, 0, 0, 1)},
+	}
+	if !nodeHasExternalIP(n, "1.1.1.1") {
+		t.Errorf("nodeHasExternalIP() = false, want true")
+	}
+	if nodeHasExternalIP(n, "10.0.0.1") {
+		t.Errorf("nodeHasExternalIP() = true for internal IP, want false")
+	}
+}
+
+func Test_release(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		ip      string
+		wantErr error
+	}{
+		{
+			name:    "node name and IP address missing",
+			cfg:     &config.Config{},
+			wantErr: errNodeOrAddressRequired,
+		},
+		{
+			name:    "no node reports IP address",
+			cfg:     &config.Config{},
+			ip:      "1.1.1.1",
+			wantErr: errNodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := prepareLogger("debug", false)
+			client := fake.NewSimpleClientset()
+			err := release(context.Background(), log, client, tt.cfg, tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("release() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
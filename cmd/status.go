@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/status"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/kubernetes"
+)
+
+var errNodeOrAllRequired = errors.New("either node name or --all must be specified")
+
+// printStatus prints the assignment status of the node or of all nodes; with history, their recorded transitions
+func printStatus(ctx context.Context, w io.Writer, client kubernetes.Interface, nodeName string, all, history bool, format string) error {
+	recorder := nd.NewStatusRecorder(client)
+
+	var statuses []types.AssignmentStatus
+	switch {
+	case all:
+		list, err := recorder.ListStatus(ctx)
+		if err != nil {
+			return errors.Wrap(err, "listing assignment status")
+		}
+		statuses = list
+	case nodeName != "":
+		s, err := recorder.GetStatus(ctx, nodeName)
+		if err != nil {
+			return errors.Wrap(err, "getting assignment status")
+		}
+		statuses = []types.AssignmentStatus{*s}
+	default:
+		return errNodeOrAllRequired
+	}
+
+	if history {
+		return status.PrintHistory(w, statuses, format) //nolint:wrapcheck
+	}
+	return status.Print(w, statuses, format) //nolint:wrapcheck
+}
+
+// printHolders prints the nodes holding the address at the given time, according to their recorded history
+func printHolders(ctx context.Context, w io.Writer, client kubernetes.Interface, address string, at time.Time, format string) error {
+	statuses, err := nd.NewStatusRecorder(client).ListStatus(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing assignment status")
+	}
+	return status.PrintHolders(w, status.HoldersAt(statuses, address, at), format) //nolint:wrapcheck
+}
+
+func statusCmd(c *cli.Context) error {
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	cfg := config.NewConfig(c)
+
+	client, err := newKubernetesClient(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing kubernetes client")
+		return err
+	}
+
+	if address := c.String("address"); address != "" {
+		at := time.Now()
+		if c.IsSet("at") {
+			at = *c.Timestamp("at")
+		}
+		if err = printHolders(c.Context, os.Stdout, client, address, at, c.String("output")); err != nil {
+			log.WithError(err).Error("error showing address holders")
+			return err
+		}
+		return nil
+	}
+	if err = printStatus(c.Context, os.Stdout, client, cfg.NodeName, c.Bool("all"), c.Bool("history"), c.String("output")); err != nil {
+		log.WithError(err).Error("error showing assignment status")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/status"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+)
+
+const (
+	// clearScreen moves the cursor home and clears the terminal (ANSI)
+	clearScreen = "\033[H\033[2J"
+	// topHelp lists the keys of the interactive top command
+	topHelp = "keys: s sort, / filter (enter to apply, esc to clear), q quit"
+
+	keyCtrlC     = 0x03
+	keyBackspace = 0x08
+	keyEscape    = 0x1b
+	keyDelete    = 0x7f
+)
+
+var errInvalidInterval = errors.New("--interval must be positive")
+
+// topScreen is the state of the top command screen: the last listed statuses, the view and the filter being typed
+type topScreen struct {
+	statuses  []types.AssignmentStatus
+	listErr   error
+	view      status.View
+	filtering bool
+}
+
+// press handles a key press; returns false on quit
+func (s *topScreen) press(key byte) bool {
+	if s.filtering {
+		switch {
+		case key == '\r' || key == '\n':
+			s.filtering = false
+		case key == keyEscape:
+			s.filtering = false
+			s.view.Filter = ""
+		case key == keyBackspace || key == keyDelete:
+			if s.view.Filter != "" {
+				s.view.Filter = s.view.Filter[:len(s.view.Filter)-1]
+			}
+		case key == keyCtrlC:
+			return false
+		case key >= ' ' && key < keyDelete:
+			s.view.Filter += string(key)
+		}
+		return true
+	}
+	switch key {
+	case 'q', keyCtrlC:
+		return false
+	case 's':
+		s.view.SortBy = s.view.NextSort()
+	case '/':
+		s.filtering = true
+	case keyEscape:
+		s.view.Filter = ""
+	}
+	return true
+}
+
+// render writes the screen: the overview, the last listing error and, when interactive, the keys or the filter prompt
+func (s *topScreen) render(w io.Writer, interactive bool) error {
+	if err := status.PrintTop(w, s.statuses, time.Now(), s.view); err != nil {
+		return errors.Wrap(err, "printing assignment overview")
+	}
+	if s.listErr != nil {
+		fmt.Fprintf(w, "\nrefresh failed, showing the last overview: %v\n", s.listErr)
+	}
+	if !interactive {
+		return nil
+	}
+	if s.filtering {
+		fmt.Fprintf(w, "\nfilter: %s", s.view.Filter)
+		return nil
+	}
+	fmt.Fprintf(w, "\n%s\n", topHelp)
+	return nil
+}
+
+// top refreshes the assignment overview until the context is done or quit is pressed; keys, when not nil, are the key
+// presses of an interactive terminal; listing failures are logged and shown, the next refresh retries; with once, it
+// prints the overview a single time
+func top(ctx context.Context, log *logrus.Entry, w io.Writer, keys <-chan byte, client kubernetes.Interface, interval time.Duration, once bool) error {
+	if !once && interval <= 0 {
+		return errInvalidInterval
+	}
+	recorder := nd.NewStatusRecorder(client)
+	screen := &topScreen{view: status.View{SortBy: status.SortByNode}}
+	interactive := keys != nil
+
+	var ticker *time.Ticker
+	if !once {
+		ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+	}
+	refresh := true
+	for {
+		if refresh {
+			statuses, err := recorder.ListStatus(ctx)
+			if err != nil {
+				if once {
+					return errors.Wrap(err, "listing assignment status")
+				}
+				log.WithError(err).Warn("listing assignment status failed, retrying at the next refresh")
+				screen.listErr = err
+			} else {
+				screen.statuses, screen.listErr = statuses, nil
+			}
+		}
+		if !once {
+			io.WriteString(w, clearScreen) //nolint:errcheck
+		}
+		if err := screen.render(w, interactive); err != nil {
+			return err
+		}
+		if once {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+			refresh = true
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+			} else if !screen.press(key) {
+				return nil
+			}
+			refresh = false
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// readKeys sends the bytes read from r, one key press each, until reading fails
+func readKeys(r io.Reader) <-chan byte {
+	keys := make(chan byte)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 1)
+		for {
+			if _, err := r.Read(buf); err != nil {
+				return
+			}
+			keys <- buf[0]
+		}
+	}()
+	return keys
+}
+
+// crlfWriter translates line feeds to carriage return and line feed, as a terminal in raw mode does not
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, errors.Wrap(err, "writing to terminal")
+	}
+	return len(p), nil
+}
+
+func topCmd(c *cli.Context) error {
+	ctx := signals.SetupSignalHandler()
+	log := prepareLogger(c.String("log-level"), c.Bool("json"))
+	cfg := config.NewConfig(c)
+
+	interval, once := c.Duration("interval"), c.Bool("once")
+	client, err := newKubernetesClient(log, cfg)
+	if err != nil {
+		log.WithError(err).Error("error initializing kubernetes client")
+		return err
+	}
+
+	// on a terminal, read the key presses in raw mode (no echo, no line buffering)
+	var keys <-chan byte
+	var w io.Writer = os.Stdout
+	if fd := int(os.Stdin.Fd()); !once && term.IsTerminal(fd) {
+		state, rawErr := term.MakeRaw(fd)
+		if rawErr != nil {
+			log.WithError(rawErr).Warn("failed to read key presses, showing the overview only")
+		} else {
+			defer term.Restore(fd, state) //nolint:errcheck
+			keys = readKeys(os.Stdin)
+			w = crlfWriter{w: os.Stdout}
+		}
+	}
+
+	if err = top(ctx, log, w, keys, client, interval, once); err != nil {
+		log.WithError(err).Error("error showing assignment overview")
+		return err
+	}
+	return nil
+}
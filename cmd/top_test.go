@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/status"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testTopClient() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-node",
+				Annotations: map[string]string{
+					node.AddressAnnotation:            "1.1.1.1",
+					node.PoolAnnotation:               "test-pool",
+					node.LastTransitionTimeAnnotation: "2024-03-01T10:00:00Z",
+				},
+			},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "other-node",
+				Annotations: map[string]string{
+					node.AddressAnnotation:            "2.2.2.2",
+					node.PoolAnnotation:               "other-pool",
+					node.LastTransitionTimeAnnotation: "2024-03-01T11:00:00Z",
+				},
+			},
+		},
+	)
+}
+
+func Test_top(t *testing.T) {
+	client := testTopClient()
+	log := prepareLogger("debug", false)
+
+	var once bytes.Buffer
+	if err := top(context.Background(), log, &once, nil, client, time.Second, true); err != nil {
+		t.Fatalf("top() error = %v", err)
+	}
+	if strings.Contains(once.String(), clearScreen) || strings.Contains(once.String(), topHelp) || !strings.Contains(once.String(), "test-pool") {
+		t.Errorf("top() once output unexpected:\n%s", once.String())
+	}
+
+	// refresh until cancelled
+	var live bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := top(ctx, log, &live, nil, client, 10*time.Millisecond, false); err != nil {
+		t.Fatalf("top() error = %v", err)
+	}
+	if strings.Count(live.String(), clearScreen) < 2 {
+		t.Errorf("top() did not refresh:\n%s", live.String())
+	}
+
+	// invalid interval
+	if err := top(context.Background(), log, &live, nil, client, 0, false); !errors.Is(err, errInvalidInterval) {
+		t.Errorf("top() error = %v, want %v", err, errInvalidInterval)
+	}
+}
+
+func Test_top_listFailure(t *testing.T) {
+	client := testTopClient()
+	client.PrependReactor("list", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	log := prepareLogger("debug", false)
+
+	var once bytes.Buffer
+	if err := top(context.Background(), log, &once, nil, client, time.Second, true); err == nil {
+		t.Error("top() error = nil, want listing error")
+	}
+
+	// keeps refreshing
+	var live bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := top(ctx, log, &live, nil, client, 10*time.Millisecond, false); err != nil {
+		t.Fatalf("top() error = %v", err)
+	}
+	if strings.Count(live.String(), clearScreen) < 2 || !strings.Contains(live.String(), "connection refused") {
+		t.Errorf("top() did not keep refreshing:\n%s", live.String())
+	}
+}
+
+func Test_top_keys(t *testing.T) {
+	keys := make(chan byte)
+	var live bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- top(context.Background(), prepareLogger("debug", false), &live, keys, testTopClient(), time.Hour, false)
+	}()
+	for _, key := range []byte("s/other\r") {
+		keys <- key
+	}
+	keys <- 'q'
+	if err := <-done; err != nil {
+		t.Fatalf("top() error = %v", err)
+	}
+	screens := strings.Split(live.String(), clearScreen)
+	last := screens[len(screens)-1]
+	if !strings.Contains(last, "NODES (by pool)") || !strings.Contains(last, `1 matching "other"`) || !strings.Contains(last, topHelp) {
+		t.Errorf("top() last screen unexpected:\n%s", last)
+	}
+}
+
+func Test_topScreen_press(t *testing.T) {
+	screen := &topScreen{view: status.View{SortBy: status.SortByNode}}
+	for _, key := range []byte("/ab\x7fc") {
+		screen.press(key)
+	}
+	if !screen.filtering || screen.view.Filter != "ac" {
+		t.Errorf("press() filter = %q (filtering %v), want \"ac\" while filtering", screen.view.Filter, screen.filtering)
+	}
+	screen.press(keyEscape)
+	if screen.filtering || screen.view.Filter != "" {
+		t.Errorf("press() filter = %q (filtering %v), want cleared", screen.view.Filter, screen.filtering)
+	}
+	if screen.press(keyCtrlC) {
+		t.Error("press() ctrl-c did not quit")
+	}
+}
@@ -3,6 +3,9 @@ package address
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
+	"sync"
 
 	"github.com/doitintl/kubeip/internal/config"
 	"github.com/doitintl/kubeip/internal/types"
@@ -15,18 +18,148 @@ var (
 	ErrNoStaticIPAssigned      = errors.New("no static public IP assigned")
 )
 
+var ErrNoAvailableAddress = errors.New("no available static public IP address")
+
+var (
+	// ErrInvalidFilter is returned for a filter of the addresses the cloud provider cannot apply
+	ErrInvalidFilter = errors.New("invalid filter")
+	// ErrRegionMismatch is returned for a node outside the region of the static public IP addresses
+	ErrRegionMismatch = errors.New("node outside the region of the static public IP addresses")
+)
+
 type Assigner interface {
 	Assign(ctx context.Context, instanceID, zone string, filter []string, orderBy string) (string, error)
+	// Candidate returns the address Assign would pick without changing anything (dry-run): the static public IP address
+	// already held by the instance or the first available address matching the filter in the order
+	Candidate(ctx context.Context, instanceID, zone string, filter []string, orderBy string) (string, error)
 	Unassign(ctx context.Context, instanceID, zone string) error
 }
 
+// Announcer is implemented by assigners announcing the address themselves (MetalLB): the node does not report the
+// address as external IP, the assignment is confirmed from the announcement
+type Announcer interface {
+	Announced(ctx context.Context, instanceID, address string) (bool, error)
+}
+
+// PoolChecker is implemented by assigners telling the addresses of the pool from the other static public IP addresses:
+// the instance may hold a static public IP address reserved outside the pool (not matching the filter)
+type PoolChecker interface {
+	InPool(ctx context.Context, address string, filter []string) (bool, error)
+}
+
+// Claimer is implemented by assigners assigning a given address of the pool: the replacement of a node claims the address
+// released by the node it replaces
+type Claimer interface {
+	Claim(ctx context.Context, instanceID, zone, address string, filter []string) (string, error)
+}
+
+// Quota is a cloud provider quota of the static public IP addresses
+type Quota struct {
+	Name  string
+	Limit float64
+	Usage float64
+}
+
+// Remaining returns the addresses left before the quota is exceeded
+func (q *Quota) Remaining() float64 {
+	return q.Limit - q.Usage
+}
+
+// QuotaChecker is implemented by assigners reporting the quota of the static public IP addresses of the region
+type QuotaChecker interface {
+	Quota(ctx context.Context) (*Quota, error)
+}
+
+// PermissionChecker is implemented by assigners checking the cloud permissions of their credentials at startup: missing
+// permissions are reported by name instead of failing the first assignment with an opaque authorization error
+type PermissionChecker interface {
+	CheckPermissions(ctx context.Context, instanceID string) error
+}
+
+// labels of the Kubernetes objects claiming an address for a node: the MetalLB address pools and the BGP leases
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "kubeip"
+	nodeLabel      = "kubeip.com/node"
+	addressLabel   = "kubeip.com/address"
+)
+
+// combinations of the filters of the addresses
+const (
+	// FilterLogicAnd selects the addresses matching all the filters
+	FilterLogicAnd = "and"
+	// FilterLogicOr selects the addresses matching any filter
+	FilterLogicOr = "or"
+)
+
+// filterGroups returns the groups of filters listed one after the other: all the filters at once (and), or each filter
+// on its own (or), the addresses of the groups being merged in order without duplicates
+func filterGroups(filter []string, logic string) [][]string {
+	if logic != FilterLogicOr || len(filter) <= 1 {
+		return [][]string{filter}
+	}
+	groups := make([][]string, 0, len(filter))
+	for _, f := range filter {
+		groups = append(groups, []string{f})
+	}
+	return groups
+}
+
+// compileNameRegex compiles the pattern of the candidate address names, nil without pattern
+func compileNameRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil //nolint:nilnil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: name regex %q: %v", ErrInvalidFilter, expr, err) //nolint:errorlint
+	}
+	return re, nil
+}
+
+// nameMatches reports whether the name of a candidate address matches the pattern, any name without pattern
+func nameMatches(re *regexp.Regexp, name string) bool {
+	return re == nil || re.MatchString(name)
+}
+
+// OperationReporter is implemented by assigners identifying their cloud mutations: the most recent operation of the
+// instance, nil if none, is recorded in the assignment status to find its entry in the cloud audit log
+type OperationReporter interface {
+	LastOperation(instanceID string) *types.CloudOperation
+}
+
+// operations holds the most recent cloud operation by instance; embedded in the assigners reporting their operations
+type operations struct {
+	mutex sync.Mutex
+	last  map[string]*types.CloudOperation
+}
+
+// record keeps the operation as the most recent one of the instance; a nil operation is ignored
+func (o *operations) record(instanceID string, operation *types.CloudOperation) {
+	if operation == nil {
+		return
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.last == nil {
+		o.last = make(map[string]*types.CloudOperation)
+	}
+	o.last[instanceID] = operation
+}
+
+func (o *operations) LastOperation(instanceID string) *types.CloudOperation {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.last[instanceID]
+}
+
 func NewAssigner(ctx context.Context, logger *logrus.Entry, provider types.CloudProvider, cfg *config.Config) (Assigner, error) {
 	if provider == types.CloudProviderAWS {
-		return NewAwsAssigner(ctx, logger, cfg.Region)
+		return NewAwsAssigner(ctx, logger, cfg)
 	} else if provider == types.CloudProviderAzure {
-		return &azureAssigner{}, nil
+		return NewAzureAssigner(ctx, logger, cfg)
 	} else if provider == types.CloudProviderGCP {
-		return NewGCPAssigner(ctx, logger, cfg.Project, cfg.Region, cfg.IPv6)
+		return NewGCPAssigner(ctx, logger, cfg)
 	} else if provider == types.CloudProviderOCI {
 		return NewOCIAssigner(ctx, logger, cfg)
 	}
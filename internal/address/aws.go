@@ -2,38 +2,93 @@ package address
 
 import (
 	"context"
+	"net"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/metrics"
+	kubeiptypes "github.com/doitintl/kubeip/internal/types"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	shorthandFilterTokens = 2
+	// awsIPv6PrefixLength is the length of the IPv6 prefixes EC2 delegates to network interfaces
+	awsIPv6PrefixLength = 80
+	// poolListParallelism bounds the concurrent listings of the pool accounts
+	poolListParallelism = 4
 )
 
+var errNoElasticIPs = errors.New("no available elastic IPs")
+
+// awsPool is an account of an address pool spanning accounts: its available elastic IPs are transferred to the account
+// of the instances once the account has none left
+type awsPool struct {
+	roleARN     string
+	eipLister   cloud.EipLister
+	transferrer cloud.EipTransferrer
+}
+
 type awsAssigner struct {
 	region         string
 	logger         *logrus.Entry
 	instanceGetter cloud.Ec2InstanceGetter
 	eipLister      cloud.EipLister
 	eipAssigner    cloud.EipAssigner
+	dryRunner      cloud.Ec2DryRunner
+	imdsHopLimit   int
+	// lifecycleHook is the launch lifecycle hook completed once the elastic IP is attached; disabled if empty
+	lifecycleHook      string
+	lifecycleCompleter cloud.LifecycleActionCompleter
+	// accountID is the account of the instances, receiving the elastic IPs transferred from the pool accounts
+	accountID   string
+	pools       []awsPool
+	transferrer cloud.EipTransferrer
+	// ipv6Prefixes are the IPv6 prefixes delegated to the primary network interface, one per instance; disabled if empty
+	ipv6Prefixes   []string
+	prefixAssigner cloud.Ipv6PrefixAssigner
+	// privateIPs are the secondary private IPs assigned to the primary network interface, one per instance; disabled if empty
+	privateIPs        []string
+	privateIPAssigner cloud.PrivateIPAssigner
+	quotaGetter       cloud.EipQuotaGetter
+	// filterLogic combines the filters of the elastic IPs: and, or
+	filterLogic string
+	// nameRegex selects the available elastic IPs by Name tag, nil for all
+	nameRegex *regexp.Regexp
+	operations
 }
 
-func NewAwsAssigner(ctx context.Context, logger *logrus.Entry, region string) (Assigner, error) {
-	// initialize AWS client
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+func NewAwsAssigner(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Assigner, error) {
+	ipv6Prefixes, err := parseIPv6Prefixes(cfg.AWSIPv6Prefixes)
+	if err != nil {
+		return nil, err
+	}
+	nameRegex, err := compileNameRegex(cfg.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range cfg.AWSSecondaryPrivateIPs {
+		if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+			return nil, errors.Errorf("invalid secondary private IP %s: expected an IPv4 address", ip)
+		}
+	}
+
+	// initialize AWS client, assuming the configured role if any
+	awsCfg, err := cloud.LoadAWSConfig(ctx, cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load AWS config")
 	}
 
 	// create AWS client for EC2 service in the given region with default config and credentials
-	client := ec2.NewFromConfig(cfg)
+	client := cloud.NewEC2Client(awsCfg, cfg)
 
 	// initialize AWS instance getter
 	instanceGetter := cloud.NewEc2InstanceGetter(client)
@@ -44,13 +99,70 @@ func NewAwsAssigner(ctx context.Context, logger *logrus.Entry, region string) (A
 	// initialize AWS elastic IP internalAssigner
 	eipAssigner := cloud.NewEipAssigner(client)
 
-	return &awsAssigner{
-		region:         region,
-		logger:         logger,
-		instanceGetter: instanceGetter,
-		eipLister:      eipLister,
-		eipAssigner:    eipAssigner,
-	}, nil
+	assigner := &awsAssigner{
+		region:             awsCfg.Region,
+		logger:             logger,
+		instanceGetter:     instanceGetter,
+		eipLister:          eipLister,
+		eipAssigner:        eipAssigner,
+		dryRunner:          cloud.NewEc2DryRunner(client),
+		imdsHopLimit:       cfg.AWSIMDSHopLimit,
+		lifecycleHook:      cfg.AWSLifecycleHook,
+		lifecycleCompleter: cloud.NewLifecycleActionCompleter(awsCfg, cfg),
+		transferrer:        cloud.NewEipTransferrer(client),
+		ipv6Prefixes:       ipv6Prefixes,
+		prefixAssigner:     cloud.NewIpv6PrefixAssigner(client),
+		privateIPs:         cfg.AWSSecondaryPrivateIPs,
+		privateIPAssigner:  cloud.NewPrivateIPAssigner(client),
+		quotaGetter:        cloud.NewEipQuotaGetter(client),
+		filterLogic:        cfg.FilterLogic,
+		nameRegex:          nameRegex,
+	}
+	if len(cfg.AWSPoolRoleARNs) == 0 {
+		return assigner, nil
+	}
+
+	// initialize the pool accounts, assuming their role with the credentials of the account of the instances
+	if assigner.accountID, err = cloud.AWSAccountID(ctx, awsCfg); err != nil {
+		return nil, errors.Wrap(err, "failed to get AWS account ID")
+	}
+	for _, roleARN := range cfg.AWSPoolRoleARNs {
+		poolCfg, err := cloud.AssumeRoleConfig(awsCfg, roleARN)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to configure pool account role %s", roleARN)
+		}
+		poolClient := cloud.NewEC2Client(poolCfg, cfg)
+		assigner.pools = append(assigner.pools, awsPool{
+			roleARN:     roleARN,
+			eipLister:   cloud.NewEipLister(poolClient),
+			transferrer: cloud.NewEipTransferrer(poolClient),
+		})
+	}
+	return assigner, nil
+}
+
+// parseIPv6Prefixes parses the IPv6 prefixes delegated to the instances, EC2 delegates /80 prefixes only
+func parseIPv6Prefixes(prefixes []string) ([]string, error) {
+	parsed := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		ip, ipNet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse IPv6 prefix %s", prefix)
+		}
+		if ones, _ := ipNet.Mask.Size(); ip.To4() != nil || ones != awsIPv6PrefixLength {
+			return nil, errors.Errorf("invalid IPv6 prefix %s: EC2 delegates /%d IPv6 prefixes only", prefix, awsIPv6PrefixLength)
+		}
+		// the host bits would be masked silently, possibly into another configured prefix
+		if !ip.Equal(ipNet.IP) {
+			return nil, errors.Errorf("invalid IPv6 prefix %s: host bits set, did you mean %s?", prefix, ipNet)
+		}
+		parsed = append(parsed, ipNet.String())
+	}
+	return parsed, nil
+}
+
+func (a *awsAssigner) CheckPermissions(ctx context.Context, instanceID string) error {
+	return cloud.CheckAWSPermissions(ctx, a.logger, a.dryRunner, instanceID, cloud.AWSAssignerPermissions) //nolint:wrapcheck
 }
 
 // parseShorthandFilter parses shorthand filter string into filter name and values
@@ -60,22 +172,32 @@ func parseShorthandFilter(filter string) (string, []string, error) {
 	// split filter by the first ","
 	exp := strings.SplitN(filter, ",", shorthandFilterTokens)
 	if len(exp) != shorthandFilterTokens {
-		return "", nil, errors.New("invalid filter format; supported format Name=string,Values=string,string,")
+		return "", nil, errors.Wrapf(ErrInvalidFilter, "%q, supported format Name=string,Values=string,string", filter)
 	}
 	// get filter name
 	name := strings.Split(exp[0], "=")
 	if len(name) != 2 || name[0] != "Name" {
-		return "", nil, errors.New("invalid filter Name")
+		return "", nil, errors.Wrapf(ErrInvalidFilter, "name of %q", filter)
 	}
 	// get filter values
 	values := strings.Split(exp[1], "=")
 	if len(values) != 2 || values[0] != "Values" {
-		return "", nil, errors.New("invalid filter Values list")
+		return "", nil, errors.Wrapf(ErrInvalidFilter, "values list of %q", filter)
 	}
 	listValues := strings.Split(values[1], ",")
 	return name[1], listValues, nil
 }
 
+// addressTag returns the value of the tag of the elastic IP, empty if not tagged
+func addressTag(address types.Address, key string) string {
+	for _, tag := range address.Tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
 func sortAddressesByTag(addresses []types.Address, key string) {
 	sort.Slice(addresses, func(i, j int) bool {
 		if addresses[i].Tags == nil {
@@ -166,23 +288,51 @@ func (a *awsAssigner) forceCheckAddressAssigned(ctx context.Context, allocationI
 }
 
 func (a *awsAssigner) Assign(ctx context.Context, instanceID, _ string, filter []string, orderBy string) (string, error) {
+	d := newDecision(instanceID, filter, orderBy)
 	// get elastic IP attached to the instance
-	err := a.checkElasticIPAssigned(ctx, instanceID)
+	assignedAddress, err := a.checkElasticIPAssigned(ctx, instanceID)
+	if errors.Is(err, ErrStaticIPAlreadyAssigned) {
+		d.choose(assignedAddress, ReasonAlreadyAssigned)
+		d.log(a.logger)
+	}
 	if err != nil {
-		return "", errors.Wrapf(err, "check if elastic IP is already assigned to instance %s", instanceID)
+		// the agent may have restarted before assigning the pool addresses of the network interface or completing the
+		// lifecycle action
+		if errors.Is(err, ErrStaticIPAlreadyAssigned) && (a.lifecycleHook != "" || len(a.ipv6Prefixes) > 0 || len(a.privateIPs) > 0) {
+			if instance, getErr := a.instanceGetter.Get(ctx, instanceID, a.region); getErr == nil {
+				if eniErr := a.assignENIAddresses(ctx, instance); eniErr != nil {
+					return "", errors.Wrapf(eniErr, "failed to assign network interface addresses to instance %s", instanceID)
+				}
+				a.completeLifecycleAction(ctx, instanceID, instance)
+			}
+		}
+		return assignedAddress, errors.Wrapf(err, "check if elastic IP is already assigned to instance %s", instanceID)
 	}
 
-	// get available elastic IPs based on filter and orderBy
+	// get available elastic IPs based on filter and orderBy; from the pool accounts once the account has none left
+	listStart := time.Now()
 	addresses, err := a.getAvailableElasticIPs(ctx, filter, orderBy)
+	if errors.Is(err, errNoElasticIPs) && len(a.pools) > 0 {
+		addresses, err = a.transferPoolElasticIP(ctx, filter, orderBy)
+	}
+	metrics.ObservePhase(ctx, metrics.PhaseList, listStart)
+	if errors.Is(err, errNoElasticIPs) {
+		d.choose("", ReasonNoCandidates)
+		d.log(a.logger)
+	}
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get available elastic IPs")
 	}
+	for i := range addresses {
+		d.candidates = append(d.candidates, aws.ToString(addresses[i].PublicIp))
+	}
 
 	// get EC2 instance
 	instance, err := a.instanceGetter.Get(ctx, instanceID, a.region)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to get instance %s", instanceID)
 	}
+	cloud.CheckInstanceMetadataOptions(a.logger, instance, a.imdsHopLimit)
 	// get primary network interface ID with public IP address (DeviceIndex == 0)
 	networkInterfaceID, err := a.getNetworkInterfaceID(instance)
 	if err != nil {
@@ -191,7 +341,6 @@ func (a *awsAssigner) Assign(ctx context.Context, instanceID, _ string, filter [
 
 	// try to assign available addresses until succeeds
 	// due to concurrency, it is possible that another kubeip instance will assign the same address
-	var assignedAddress string
 	for i := range addresses {
 		a.logger.WithFields(logrus.Fields{
 			"instance":           instanceID,
@@ -199,8 +348,11 @@ func (a *awsAssigner) Assign(ctx context.Context, instanceID, _ string, filter [
 			"allocation_id":      *addresses[i].AllocationId,
 			"networkInterfaceID": networkInterfaceID,
 		}).Debug("assigning elastic IP to the instance")
+		attachStart := time.Now()
 		err = a.tryAssignAddress(ctx, &addresses[i], networkInterfaceID, instanceID)
+		metrics.ObservePhase(ctx, metrics.PhaseAttach, attachStart)
 		if err != nil {
+			d.exclude(*addresses[i].PublicIp, err)
 			a.logger.WithError(err).Warn("failed to assign elastic IP address")
 			a.logger.Debug("retrying with another address")
 		} else {
@@ -214,11 +366,179 @@ func (a *awsAssigner) Assign(ctx context.Context, instanceID, _ string, filter [
 		}
 	}
 	if err != nil {
+		d.choose("", ReasonAllExcluded)
+		d.log(a.logger)
 		return "", errors.Wrap(err, "failed to assign elastic IP address")
 	}
+	d.choose(assignedAddress, ReasonFirstAvailable)
+	d.log(a.logger)
+	if err = a.assignENIAddresses(ctx, instance); err != nil {
+		return "", errors.Wrapf(err, "failed to assign network interface addresses to instance %s", instanceID)
+	}
+	a.completeLifecycleAction(ctx, instanceID, instance)
 	return assignedAddress, nil
 }
 
+// eniAddressAssigner assigns addresses of a pool to network interfaces: IPv6 prefixes or secondary private IPs
+type eniAddressAssigner interface {
+	Assign(ctx context.Context, networkInterfaceID, address string) error
+	Unassign(ctx context.Context, networkInterfaceID, address string) error
+}
+
+// eniPool is a pool of addresses assigned to the primary network interface of the instances along with their elastic
+// IP, one per instance
+type eniPool struct {
+	kind      string
+	addresses []string
+	assigner  eniAddressAssigner
+	// assigned returns the addresses of the kind assigned to the network interface
+	assigned func(ni *types.InstanceNetworkInterface) []string
+}
+
+func (a *awsAssigner) eniPools() []eniPool {
+	return []eniPool{
+		{kind: "IPv6 prefix", addresses: a.ipv6Prefixes, assigner: a.prefixAssigner, assigned: func(ni *types.InstanceNetworkInterface) []string {
+			prefixes := make([]string, 0, len(ni.Ipv6Prefixes))
+			for _, p := range ni.Ipv6Prefixes {
+				prefixes = append(prefixes, aws.ToString(p.Ipv6Prefix))
+			}
+			return prefixes
+		}},
+		{kind: "secondary private IP", addresses: a.privateIPs, assigner: a.privateIPAssigner, assigned: func(ni *types.InstanceNetworkInterface) []string {
+			ips := make([]string, 0, len(ni.PrivateIpAddresses))
+			for _, ip := range ni.PrivateIpAddresses {
+				if !aws.ToBool(ip.Primary) {
+					ips = append(ips, aws.ToString(ip.PrivateIpAddress))
+				}
+			}
+			return ips
+		}},
+	}
+}
+
+// held returns the addresses of the pool assigned to the network interface of the instance
+func (p *eniPool) held(instance *types.Instance, networkInterfaceID string) []string {
+	var held []string
+	for i := range instance.NetworkInterfaces {
+		if aws.ToString(instance.NetworkInterfaces[i].NetworkInterfaceId) != networkInterfaceID {
+			continue
+		}
+		for _, assigned := range p.assigned(&instance.NetworkInterfaces[i]) {
+			for _, address := range p.addresses {
+				if assigned == address {
+					held = append(held, address)
+				}
+			}
+		}
+	}
+	return held
+}
+
+// assignENIAddresses assigns an address of each pool (IPv6 prefix, secondary private IP) to the primary network
+// interface of the instance, unless it already holds one: the address stays with the instance until released
+func (a *awsAssigner) assignENIAddresses(ctx context.Context, instance *types.Instance) error {
+	for _, pool := range a.eniPools() {
+		if len(pool.addresses) == 0 {
+			continue
+		}
+		networkInterfaceID, err := a.getNetworkInterfaceID(instance)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get network interface ID for instance %s", aws.ToString(instance.InstanceId))
+		}
+		logger := a.logger.WithFields(logrus.Fields{"instance": aws.ToString(instance.InstanceId), "networkInterfaceID": networkInterfaceID})
+		if held := pool.held(instance, networkInterfaceID); len(held) > 0 {
+			logger.WithField("address", held[0]).Debugf("%s already assigned to the instance", pool.kind)
+			continue
+		}
+		if err = a.assignENIAddress(ctx, logger, pool, networkInterfaceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignENIAddress tries the addresses of the pool in order: EC2 rejects the addresses assigned to other network interfaces
+func (a *awsAssigner) assignENIAddress(ctx context.Context, logger *logrus.Entry, pool eniPool, networkInterfaceID string) error {
+	var err error
+	for _, address := range pool.addresses {
+		if err = pool.assigner.Assign(ctx, networkInterfaceID, address); err != nil {
+			logger.WithError(err).WithField("address", address).Debugf("failed to assign %s, retrying with another one", pool.kind)
+			continue
+		}
+		logger.WithField("address", address).Infof("%s assigned to the instance", pool.kind)
+		return nil
+	}
+	return errors.Wrapf(err, "no %s available", pool.kind)
+}
+
+// unassignENIAddresses removes the addresses of the pools assigned to the primary network interface of the instance
+func (a *awsAssigner) unassignENIAddresses(ctx context.Context, instanceID string) error {
+	if len(a.ipv6Prefixes) == 0 && len(a.privateIPs) == 0 {
+		return nil
+	}
+	instance, err := a.instanceGetter.Get(ctx, instanceID, a.region)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get instance %s", instanceID)
+	}
+	// without a public IP address, the instance has no elastic IP, hence no address assigned by KubeIP
+	networkInterfaceID, err := a.getNetworkInterfaceID(instance)
+	if err != nil {
+		return nil //nolint:nilerr
+	}
+	for _, pool := range a.eniPools() {
+		for _, address := range pool.held(instance, networkInterfaceID) {
+			if err = pool.assigner.Unassign(ctx, networkInterfaceID, address); err != nil {
+				return errors.Wrapf(err, "failed to unassign %s %s", pool.kind, address)
+			}
+			a.logger.WithFields(logrus.Fields{"instance": instanceID, "address": address}).Infof("%s unassigned from the instance", pool.kind)
+		}
+	}
+	return nil
+}
+
+// completeLifecycleAction completes the pending launch lifecycle action of the instance with CONTINUE, letting it enter
+// service with its elastic IP; failures are logged: the action times out with the default result of the hook
+func (a *awsAssigner) completeLifecycleAction(ctx context.Context, instanceID string, instance *types.Instance) {
+	if a.lifecycleHook == "" {
+		return
+	}
+	var group string
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == cloud.AutoScalingGroupTag {
+			group = aws.ToString(tag.Value)
+		}
+	}
+	logger := a.logger.WithFields(logrus.Fields{"instance": instanceID, "group": group, "hook": a.lifecycleHook})
+	if group == "" {
+		logger.Debug("instance is not in an Auto Scaling group, no lifecycle action to complete")
+		return
+	}
+	err := a.lifecycleCompleter.Complete(ctx, group, a.lifecycleHook, instanceID)
+	switch {
+	case errors.Is(err, cloud.ErrNoLifecycleAction):
+		logger.Debug("no pending lifecycle action")
+	case err != nil:
+		logger.WithError(err).Warn("failed to complete lifecycle action")
+	default:
+		logger.Info("lifecycle action completed, instance enters service")
+	}
+}
+
+func (a *awsAssigner) Candidate(ctx context.Context, instanceID, _ string, filter []string, orderBy string) (string, error) {
+	assignedAddress, err := a.checkElasticIPAssigned(ctx, instanceID)
+	if errors.Is(err, ErrStaticIPAlreadyAssigned) {
+		return assignedAddress, nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "check if elastic IP is already assigned to instance %s", instanceID)
+	}
+	addresses, err := a.getAvailableElasticIPs(ctx, filter, orderBy)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get available elastic IPs")
+	}
+	return aws.ToString(addresses[0].PublicIp), nil
+}
+
 func (a *awsAssigner) tryAssignAddress(ctx context.Context, address *types.Address, networkInterfaceID, instanceID string) error {
 	// force check if address is already assigned (reduce the chance of assigning the same address by multiple kubeip instances)
 	addressAssigned, err := a.forceCheckAddressAssigned(ctx, *address.AllocationId)
@@ -228,9 +548,11 @@ func (a *awsAssigner) tryAssignAddress(ctx context.Context, address *types.Addre
 	if addressAssigned {
 		return errors.Errorf("address %s is already assigned", *address.PublicIp)
 	}
-	if err = a.eipAssigner.Assign(ctx, networkInterfaceID, *address.AllocationId); err != nil {
+	operation, err := a.eipAssigner.Assign(ctx, networkInterfaceID, *address.AllocationId)
+	if err != nil {
 		return errors.Wrapf(err, "failed to assign elastic IP %s to the instance %s", *address.PublicIp, instanceID)
 	}
+	a.record(instanceID, operation)
 	return nil
 }
 
@@ -254,17 +576,40 @@ func (a *awsAssigner) getNetworkInterfaceID(instance *types.Instance) (string, e
 	return networkInterfaceID, nil
 }
 
-func (a *awsAssigner) checkElasticIPAssigned(ctx context.Context, instanceID string) error {
+// checkElasticIPAssigned returns the elastic IP already attached to the instance with ErrStaticIPAlreadyAssigned
+func (a *awsAssigner) checkElasticIPAssigned(ctx context.Context, instanceID string) (string, error) {
 	filters := make(map[string][]string)
 	filters["instance-id"] = []string{instanceID}
 	addresses, err := a.eipLister.List(ctx, filters, true)
 	if err != nil {
-		return errors.Wrapf(err, "failed to list elastic IPs attached to instance %s", instanceID)
+		return "", errors.Wrapf(err, "failed to list elastic IPs attached to instance %s", instanceID)
 	}
 	if len(addresses) > 0 {
-		return ErrStaticIPAlreadyAssigned
+		return aws.ToString(addresses[0].PublicIp), ErrStaticIPAlreadyAssigned
 	}
-	return nil
+	return "", nil
+}
+
+func (a *awsAssigner) InPool(ctx context.Context, address string, filter []string) (bool, error) {
+	addresses, err := a.listElasticIPs(ctx, a.eipLister, filter, map[string][]string{"public-ip": {address}}, true)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to list elastic IP %s", address)
+	}
+	return len(addresses) > 0, nil
+}
+
+// Quota returns the elastic IP quota of the region (vpc-max-elastic-ips) and the elastic IPs allocated in the region
+func (a *awsAssigner) Quota(ctx context.Context) (*Quota, error) {
+	limit, usage, err := a.quotaGetter.Quota(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return &Quota{Name: "vpc-max-elastic-ips", Limit: float64(limit), Usage: float64(usage)}, nil
+}
+
+// Claim assigns the elastic IP of the pool with the public IP: the pool filter narrowed to the address
+func (a *awsAssigner) Claim(ctx context.Context, instanceID, zone, address string, filter []string) (string, error) {
+	return a.Assign(ctx, instanceID, zone, append(append([]string{}, filter...), "Name=public-ip,Values="+address), "")
 }
 
 func (a *awsAssigner) getAssignedElasticIP(ctx context.Context, instanceID string) (*types.Address, error) {
@@ -281,7 +626,39 @@ func (a *awsAssigner) getAssignedElasticIP(ctx context.Context, instanceID strin
 	return &addresses[0], nil
 }
 
-func (a *awsAssigner) getAvailableElasticIPs(ctx context.Context, filter []string, orderBy string) ([]types.Address, error) {
+// listElasticIPs lists the elastic IPs matching the filter, combined with the filter logic, and the fixed filters; the
+// elastic IPs of the filters listed one after the other are merged in order without duplicates
+func (a *awsAssigner) listElasticIPs(ctx context.Context, lister cloud.EipLister, filter []string, fixed map[string][]string, inUse bool) ([]types.Address, error) {
+	var addresses []types.Address
+	seen := make(map[string]bool)
+	for _, group := range filterGroups(filter, a.filterLogic) {
+		filters, err := parseFilters(group)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range fixed {
+			filters[name] = values
+		}
+		listed, err := lister.List(ctx, filters, inUse)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		for i := range listed {
+			// the available elastic IPs are the candidates: those not matching the name pattern are skipped
+			if !inUse && !nameMatches(a.nameRegex, addressTag(listed[i], "Name")) {
+				continue
+			}
+			if id := aws.ToString(listed[i].AllocationId); !seen[id] {
+				seen[id] = true
+				addresses = append(addresses, listed[i])
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// parseFilters parses the shorthand filters into the filters of the elastic IPs listing
+func parseFilters(filter []string) (map[string][]string, error) {
 	filters := make(map[string][]string)
 	for _, f := range filter {
 		name, values, err := parseShorthandFilter(f)
@@ -290,12 +667,19 @@ func (a *awsAssigner) getAvailableElasticIPs(ctx context.Context, filter []strin
 		}
 		filters[name] = values
 	}
-	addresses, err := a.eipLister.List(ctx, filters, false)
+	return filters, nil
+}
+
+func (a *awsAssigner) getAvailableElasticIPs(ctx context.Context, filter []string, orderBy string) ([]types.Address, error) {
+	addresses, err := a.listElasticIPs(ctx, a.eipLister, filter, nil, false)
 	if err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			return nil, err
+		}
 		return nil, errors.Wrap(err, "failed to list available elastic IPs")
 	}
 	if len(addresses) == 0 {
-		return nil, errors.Errorf("no available elastic IPs")
+		return nil, errNoElasticIPs
 	}
 	// sort addresses by orderBy field
 	sortAddressesByField(addresses, orderBy)
@@ -309,7 +693,64 @@ func (a *awsAssigner) getAvailableElasticIPs(ctx context.Context, filter []strin
 	return addresses, nil
 }
 
+// listPoolElasticIPs lists the available elastic IPs of the pool accounts concurrently, at most poolListParallelism at
+// a time, and returns them in the order of the pool accounts; the pool accounts failing to list have no address
+func (a *awsAssigner) listPoolElasticIPs(ctx context.Context, filter []string) [][]types.Address {
+	listed := make([][]types.Address, len(a.pools))
+	slots := make(chan struct{}, poolListParallelism)
+	var wg sync.WaitGroup
+	for i := range a.pools {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			addresses, err := a.listElasticIPs(ctx, a.pools[i].eipLister, filter, nil, false)
+			if err != nil {
+				a.logger.WithField("pool-role", a.pools[i].roleARN).WithError(err).Warn("failed to list available elastic IPs of the pool account")
+				return
+			}
+			listed[i] = addresses
+		}(i)
+	}
+	wg.Wait()
+	return listed
+}
+
+// transferPoolElasticIP transfers an available elastic IP of the pool accounts (in order) to the account of the instances
+// and returns it; the transferred elastic IP keeps its tags and stays in the account once released
+func (a *awsAssigner) transferPoolElasticIP(ctx context.Context, filter []string, orderBy string) ([]types.Address, error) {
+	// report the invalid filters: the pool accounts failing to list are skipped
+	if _, err := parseFilters(filter); err != nil {
+		return nil, err
+	}
+	for i, addresses := range a.listPoolElasticIPs(ctx, filter) {
+		pool := a.pools[i]
+		logger := a.logger.WithField("pool-role", pool.roleARN)
+		sortAddressesByField(addresses, orderBy)
+		for i := range addresses {
+			publicIP := aws.ToString(addresses[i].PublicIp)
+			if err := pool.transferrer.Offer(ctx, aws.ToString(addresses[i].AllocationId), a.accountID); err != nil {
+				logger.WithError(err).WithField("address", publicIP).Warn("failed to offer elastic IP of the pool account")
+				continue
+			}
+			allocationID, err := a.transferrer.Accept(ctx, publicIP, addresses[i].Tags)
+			if err != nil {
+				logger.WithError(err).WithField("address", publicIP).Warn("failed to accept elastic IP of the pool account")
+				continue
+			}
+			logger.WithFields(logrus.Fields{"address": publicIP, "allocation_id": allocationID}).Info("elastic IP transferred from the pool account")
+			return []types.Address{{AllocationId: aws.String(allocationID), PublicIp: aws.String(publicIP), Tags: addresses[i].Tags}}, nil
+		}
+	}
+	return nil, errors.Wrap(errNoElasticIPs, "in the account and the pool accounts")
+}
+
 func (a *awsAssigner) Unassign(ctx context.Context, instanceID, _ string) error {
+	// release the network interface addresses first: the primary network interface is looked up by its elastic IP
+	if err := a.unassignENIAddresses(ctx, instanceID); err != nil {
+		return err
+	}
 	// get elastic IP attached to the instance
 	address, err := a.getAssignedElasticIP(ctx, instanceID)
 	if err != nil {
@@ -319,6 +760,7 @@ func (a *awsAssigner) Unassign(ctx context.Context, instanceID, _ string) error
 	if err = a.eipAssigner.Unassign(ctx, *address.AssociationId); err != nil {
 		return errors.Wrap(err, "failed to unassign elastic IP")
 	}
+	a.record(instanceID, &kubeiptypes.CloudOperation{AllocationID: *address.AllocationId, AssociationID: *address.AssociationId})
 	a.logger.WithFields(logrus.Fields{
 		"instance":      instanceID,
 		"address":       *address.PublicIp,
@@ -2,12 +2,16 @@ package address
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/doitintl/kubeip/internal/cloud"
+	kubeiptypes "github.com/doitintl/kubeip/internal/types"
 	mocks "github.com/doitintl/kubeip/mocks/cloud"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -526,7 +530,7 @@ func Test_awsAssigner_Assign(t *testing.T) {
 				},
 				eipAssignerFn: func(t *testing.T, args *args) cloud.EipAssigner {
 					mock := mocks.NewEipAssigner(t)
-					mock.EXPECT().Assign(args.ctx, "eni-0abcd1234efgh5678", "eipalloc-0abcd1234efgh5678").Return(nil)
+					mock.EXPECT().Assign(args.ctx, "eni-0abcd1234efgh5678", "eipalloc-0abcd1234efgh5678").Return(&kubeiptypes.CloudOperation{OperationID: "req-1", AllocationID: "eipalloc-0abcd1234efgh5678", AssociationID: "eipassoc-1"}, nil)
 					return mock
 				},
 			},
@@ -543,8 +547,9 @@ func Test_awsAssigner_Assign(t *testing.T) {
 		{
 			name: "instance already has EIP assigned",
 			fields: fields{
-				region: "us-east-1",
-				logger: logrus.NewEntry(logrus.New()),
+				region:  "us-east-1",
+				logger:  logrus.NewEntry(logrus.New()),
+				address: "100.0.0.1",
 				instanceGetterFn: func(t *testing.T, args *args) cloud.Ec2InstanceGetter {
 					return nil
 				},
@@ -589,7 +594,8 @@ func Test_awsAssigner_Assign(t *testing.T) {
 			address, err := a.Assign(tt.args.ctx, tt.args.instanceID, "", tt.args.filter, tt.args.orderBy)
 			if err != nil != tt.wantErr {
 				t.Errorf("Assign() error = %v, wantErr %v", err, tt.wantErr)
-			} else if address != tt.fields.address {
+			}
+			if address != tt.fields.address {
 				t.Fatalf("Assign() = %v, want %v", address, tt.fields.address)
 			}
 		})
@@ -699,7 +705,7 @@ func Test_awsAssigner_tryAssignAddress(t *testing.T) {
 				},
 				eipAssignerFn: func(t *testing.T, args *args) cloud.EipAssigner {
 					mock := mocks.NewEipAssigner(t)
-					mock.EXPECT().Assign(context.TODO(), args.networkInterfaceID, *args.address.AllocationId).Return(nil)
+					mock.EXPECT().Assign(context.TODO(), args.networkInterfaceID, *args.address.AllocationId).Return(&kubeiptypes.CloudOperation{AssociationID: "eipassoc-1"}, nil)
 					return mock
 				},
 			},
@@ -776,7 +782,7 @@ func Test_awsAssigner_tryAssignAddress(t *testing.T) {
 				},
 				eipAssignerFn: func(t *testing.T, args *args) cloud.EipAssigner {
 					mock := mocks.NewEipAssigner(t)
-					mock.EXPECT().Assign(context.TODO(), args.networkInterfaceID, *args.address.AllocationId).Return(nil)
+					mock.EXPECT().Assign(context.TODO(), args.networkInterfaceID, *args.address.AllocationId).Return(&kubeiptypes.CloudOperation{AssociationID: "eipassoc-1"}, nil)
 					return mock
 				},
 			},
@@ -802,7 +808,7 @@ func Test_awsAssigner_tryAssignAddress(t *testing.T) {
 				},
 				eipAssignerFn: func(t *testing.T, args *args) cloud.EipAssigner {
 					mock := mocks.NewEipAssigner(t)
-					mock.EXPECT().Assign(context.TODO(), args.networkInterfaceID, *args.address.AllocationId).Return(errors.New("test-error"))
+					mock.EXPECT().Assign(context.TODO(), args.networkInterfaceID, *args.address.AllocationId).Return(nil, errors.New("test-error"))
 					return mock
 				},
 			},
@@ -816,9 +822,13 @@ func Test_awsAssigner_tryAssignAddress(t *testing.T) {
 				eipLister:   tt.fields.eipListerFn(t, &tt.args),
 				eipAssigner: tt.fields.eipAssignerFn(t, &tt.args),
 			}
-			if err := a.tryAssignAddress(context.TODO(), tt.args.address, tt.args.networkInterfaceID, tt.args.instanceID); (err != nil) != tt.wantErr {
+			err := a.tryAssignAddress(context.TODO(), tt.args.address, tt.args.networkInterfaceID, tt.args.instanceID)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("tryAssignAddress() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if operation := a.LastOperation(tt.args.instanceID); (operation != nil) == tt.wantErr {
+				t.Errorf("LastOperation() = %+v, want an operation after an assignment only", operation)
+			}
 		})
 	}
 }
@@ -1058,3 +1068,222 @@ func Test_awsAssigner_Unassign(t *testing.T) {
 		})
 	}
 }
+
+func Test_awsAssigner_completeLifecycleAction(t *testing.T) {
+	instance := &types.Instance{
+		InstanceId: aws.String("i-1"),
+		Tags:       []types.Tag{{Key: aws.String("aws:autoscaling:groupName"), Value: aws.String("workers")}},
+	}
+	tests := []struct {
+		name     string
+		hook     string
+		instance *types.Instance
+		err      error
+		called   bool
+	}{
+		{name: "hook disabled", instance: instance},
+		{name: "instance outside an Auto Scaling group", hook: "kubeip", instance: &types.Instance{InstanceId: aws.String("i-1")}},
+		{name: "lifecycle action completed", hook: "kubeip", instance: instance, called: true},
+		{name: "no pending lifecycle action", hook: "kubeip", instance: instance, err: cloud.ErrNoLifecycleAction, called: true},
+		{name: "failed to complete", hook: "kubeip", instance: instance, err: errors.New("access denied"), called: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completer := mocks.NewLifecycleActionCompleter(t)
+			if tt.called {
+				completer.EXPECT().Complete(context.Background(), "workers", "kubeip", "i-1").Return(tt.err)
+			}
+			a := &awsAssigner{
+				logger:             logrus.NewEntry(logrus.New()),
+				lifecycleHook:      tt.hook,
+				lifecycleCompleter: completer,
+			}
+			a.completeLifecycleAction(context.Background(), "i-1", tt.instance)
+		})
+	}
+}
+
+func Test_awsAssigner_transferPoolElasticIP(t *testing.T) {
+	ctx := context.Background()
+	filters := map[string][]string{"tag:kubeip": {"reserved"}}
+	tags := []types.Tag{{Key: aws.String("kubeip"), Value: aws.String("reserved")}}
+
+	// the first pool account has no available elastic IP, the offer of the first address of the second one fails
+	emptyLister := mocks.NewEipLister(t)
+	emptyLister.EXPECT().List(ctx, filters, false).Return([]types.Address{}, nil).Once()
+	poolLister := mocks.NewEipLister(t)
+	poolLister.EXPECT().List(ctx, filters, false).Return([]types.Address{
+		{AllocationId: aws.String("eipalloc-pool-2"), PublicIp: aws.String("100.0.0.2"), Tags: tags},
+		{AllocationId: aws.String("eipalloc-pool-1"), PublicIp: aws.String("100.0.0.1"), Tags: tags},
+	}, nil).Once()
+	poolTransferrer := mocks.NewEipTransferrer(t)
+	poolTransferrer.EXPECT().Offer(ctx, "eipalloc-pool-1", "111111111111").Return(errors.New("transfer already pending")).Once()
+	poolTransferrer.EXPECT().Offer(ctx, "eipalloc-pool-2", "111111111111").Return(nil).Once()
+	transferrer := mocks.NewEipTransferrer(t)
+	transferrer.EXPECT().Accept(ctx, "100.0.0.2", tags).Return("eipalloc-local-2", nil).Once()
+
+	a := &awsAssigner{
+		logger:      logrus.NewEntry(logrus.New()),
+		accountID:   "111111111111",
+		transferrer: transferrer,
+		pools: []awsPool{
+			{roleARN: "arn:aws:iam::222222222222:role/pool", eipLister: emptyLister, transferrer: mocks.NewEipTransferrer(t)},
+			{roleARN: "arn:aws:iam::333333333333:role/pool", eipLister: poolLister, transferrer: poolTransferrer},
+		},
+	}
+	addresses, err := a.transferPoolElasticIP(ctx, []string{"Name=tag:kubeip,Values=reserved"}, "PublicIp")
+	if err != nil {
+		t.Fatalf("transferPoolElasticIP() error = %v", err)
+	}
+	if len(addresses) != 1 || aws.ToString(addresses[0].AllocationId) != "eipalloc-local-2" || aws.ToString(addresses[0].PublicIp) != "100.0.0.2" {
+		t.Errorf("transferPoolElasticIP() = %+v", addresses)
+	}
+
+	// no pool account left with an available elastic IP
+	emptyLister.EXPECT().List(ctx, filters, false).Return([]types.Address{}, nil).Once()
+	a.pools = a.pools[:1]
+	if _, err = a.transferPoolElasticIP(ctx, []string{"Name=tag:kubeip,Values=reserved"}, ""); !errors.Is(err, errNoElasticIPs) {
+		t.Errorf("transferPoolElasticIP() error = %v, want %v", err, errNoElasticIPs)
+	}
+}
+
+// concurrentLister lists a single elastic IP after a delay and records the maximum number of concurrent listings
+type concurrentLister struct {
+	address string
+	err     error
+	active  *int32
+	max     *int32
+}
+
+func (l *concurrentLister) List(context.Context, map[string][]string, bool) ([]types.Address, error) {
+	active := atomic.AddInt32(l.active, 1)
+	defer atomic.AddInt32(l.active, -1)
+	for {
+		current := atomic.LoadInt32(l.max)
+		if active <= current || atomic.CompareAndSwapInt32(l.max, current, active) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if l.err != nil {
+		return nil, l.err
+	}
+	return []types.Address{{PublicIp: aws.String(l.address)}}, nil
+}
+
+func Test_awsAssigner_listPoolElasticIPs(t *testing.T) {
+	var active, maxActive int32
+	a := &awsAssigner{logger: logrus.NewEntry(logrus.New())}
+	for i := 0; i < 2*poolListParallelism; i++ {
+		lister := &concurrentLister{address: fmt.Sprintf("100.0.0.%d", i), active: &active, max: &maxActive}
+		if i == 1 {
+			lister.err = errors.New("access denied")
+		}
+		a.pools = append(a.pools, awsPool{roleARN: fmt.Sprintf("arn:aws:iam::%012d:role/pool", i), eipLister: lister})
+	}
+
+	listed := a.listPoolElasticIPs(context.Background(), nil)
+	if len(listed) != len(a.pools) {
+		t.Fatalf("listPoolElasticIPs() = %d pool accounts, want %d", len(listed), len(a.pools))
+	}
+	for i, addresses := range listed {
+		switch {
+		case i == 1 && addresses != nil:
+			t.Errorf("listPoolElasticIPs() pool account %d failing to list = %+v, want none", i, addresses)
+		case i != 1 && (len(addresses) != 1 || aws.ToString(addresses[0].PublicIp) != fmt.Sprintf("100.0.0.%d", i)):
+			t.Errorf("listPoolElasticIPs() pool account %d = %+v, out of order", i, addresses)
+		}
+	}
+	if maxActive < 2 || maxActive > poolListParallelism {
+		t.Errorf("listPoolElasticIPs() concurrent listings = %d, want between 2 and %d", maxActive, poolListParallelism)
+	}
+}
+
+func Test_parseIPv6Prefixes(t *testing.T) {
+	prefixes, err := parseIPv6Prefixes([]string{"2600:1f18:0:1::/80", "2600:1f18:0:1:1::/80"})
+	if err != nil {
+		t.Fatalf("parseIPv6Prefixes() error = %v", err)
+	}
+	if want := []string{"2600:1f18:0:1::/80", "2600:1f18:0:1:1::/80"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("parseIPv6Prefixes() = %v, want %v", prefixes, want)
+	}
+	for _, prefix := range []string{"2600:1f18:0:1::/64", "10.0.0.0/8", "2600:1f18:0:1::", "2600:1f18:0:1:0:1:0:0/80"} {
+		if _, err = parseIPv6Prefixes([]string{prefix}); err == nil {
+			t.Errorf("parseIPv6Prefixes(%s) expected error", prefix)
+		}
+	}
+}
+
+func Test_awsAssigner_assignENIAddresses(t *testing.T) {
+	ctx := context.Background()
+	instance := func(prefixes []string, privateIPs ...string) *types.Instance {
+		ni := types.InstanceNetworkInterface{
+			NetworkInterfaceId: aws.String("eni-1"),
+			Association:        &types.InstanceNetworkInterfaceAssociation{PublicIp: aws.String("100.0.0.1")},
+			Attachment:         &types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(0)},
+			PrivateIpAddresses: []types.InstancePrivateIpAddress{{PrivateIpAddress: aws.String("10.0.0.10"), Primary: aws.Bool(true)}},
+		}
+		for _, prefix := range prefixes {
+			ni.Ipv6Prefixes = append(ni.Ipv6Prefixes, types.InstanceIpv6Prefix{Ipv6Prefix: aws.String(prefix)})
+		}
+		for _, ip := range privateIPs {
+			ni.PrivateIpAddresses = append(ni.PrivateIpAddresses, types.InstancePrivateIpAddress{PrivateIpAddress: aws.String(ip), Primary: aws.Bool(false)})
+		}
+		return &types.Instance{InstanceId: aws.String("i-1"), NetworkInterfaces: []types.InstanceNetworkInterface{ni}}
+	}
+	prefixes := []string{"2600:1f18::/80", "2600:1f18:0:0:1::/80"}
+	privateIPs := []string{"10.0.0.100", "10.0.0.101"}
+
+	// the first prefix is delegated to another network interface, the secondary private IP is assigned
+	prefixAssigner := mocks.NewIpv6PrefixAssigner(t)
+	prefixAssigner.EXPECT().Assign(ctx, "eni-1", "2600:1f18::/80").Return(errors.New("prefix in use")).Once()
+	prefixAssigner.EXPECT().Assign(ctx, "eni-1", "2600:1f18:0:0:1::/80").Return(nil).Once()
+	privateIPAssigner := mocks.NewPrivateIPAssigner(t)
+	privateIPAssigner.EXPECT().Assign(ctx, "eni-1", "10.0.0.100").Return(nil).Once()
+	a := &awsAssigner{
+		logger:            logrus.NewEntry(logrus.New()),
+		ipv6Prefixes:      prefixes,
+		prefixAssigner:    prefixAssigner,
+		privateIPs:        privateIPs,
+		privateIPAssigner: privateIPAssigner,
+	}
+	if err := a.assignENIAddresses(ctx, instance(nil)); err != nil {
+		t.Errorf("assignENIAddresses() error = %v", err)
+	}
+
+	// the instance keeps the addresses it holds, other addresses of the interface are not of the pools
+	if err := a.assignENIAddresses(ctx, instance([]string{"2600:1f18:ffff::/80", "2600:1f18::/80"}, "10.0.0.50", "10.0.0.101")); err != nil {
+		t.Errorf("assignENIAddresses() error = %v", err)
+	}
+
+	// every secondary private IP is in use
+	privateIPAssigner.EXPECT().Assign(ctx, "eni-1", "10.0.0.100").Return(errors.New("address in use")).Once()
+	privateIPAssigner.EXPECT().Assign(ctx, "eni-1", "10.0.0.101").Return(errors.New("address in use")).Once()
+	if err := a.assignENIAddresses(ctx, instance([]string{"2600:1f18::/80"})); err == nil {
+		t.Error("assignENIAddresses() expected error")
+	}
+
+	// released with the elastic IP
+	instanceGetter := mocks.NewEc2InstanceGetter(t)
+	instanceGetter.EXPECT().Get(ctx, "i-1", "").Return(instance([]string{"2600:1f18:0:0:1::/80"}, "10.0.0.101"), nil).Once()
+	prefixAssigner.EXPECT().Unassign(ctx, "eni-1", "2600:1f18:0:0:1::/80").Return(nil).Once()
+	privateIPAssigner.EXPECT().Unassign(ctx, "eni-1", "10.0.0.101").Return(nil).Once()
+	a.instanceGetter = instanceGetter
+	if err := a.unassignENIAddresses(ctx, "i-1"); err != nil {
+		t.Errorf("unassignENIAddresses() error = %v", err)
+	}
+}
+
+func Test_awsAssigner_Quota(t *testing.T) {
+	ctx := context.Background()
+	getter := mocks.NewEipQuotaGetter(t)
+	getter.EXPECT().Quota(ctx).Return(5, 5, nil).Once()
+	a := &awsAssigner{quotaGetter: getter}
+	quota, err := a.Quota(ctx)
+	if err != nil {
+		t.Fatalf("Quota() error = %v", err)
+	}
+	if quota.Name != "vpc-max-elastic-ips" || quota.Limit != 5 || quota.Remaining() != 0 {
+		t.Errorf("Quota() = %+v, want vpc-max-elastic-ips exhausted at 5", quota)
+	}
+}
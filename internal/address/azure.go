@@ -1,14 +1,304 @@
 package address
 
-import "context"
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// azureTagFilterPrefix prefixes the filters of the public IP addresses by tag: tags.<key>=<value>
+	azureTagFilterPrefix = "tags."
+	// azureOrderByName orders the public IP addresses by name, else they are listed in the order of the API
+	azureOrderByName = "name"
+)
+
+// ErrAzureScaleSetInstance is returned for the assignment to an instance of a uniform orchestration scale set: its
+// network interfaces belong to the scale set model, Azure does not attach an existing public IP address to one instance
+var ErrAzureScaleSetInstance = errors.New("the public IP address of a uniform orchestration scale set instance cannot be changed, use a flexible orchestration scale set")
+
+// azureAssigner assigns the static public IP addresses of the resource group of the virtual machine to the primary IP
+// configuration of its primary network interface
 type azureAssigner struct {
+	lister      cloud.AzurePermissionLister
+	networkSvc  cloud.AzureNetworkService
+	logger      *logrus.Entry
+	filterLogic string
+	// nameRegex selects the candidate addresses by name, nil for all
+	nameRegex *regexp.Regexp
+	operations
 }
 
-func (a *azureAssigner) Assign(_ context.Context, _, _ string, _ []string, _ string) (string, error) {
-	return "", nil
+// NewAzureAssigner returns the Azure assigner, failing at startup on credentials Microsoft Entra ID rejects
+func NewAzureAssigner(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Assigner, error) {
+	if _, err := parseAzureFilters(cfg.Filter); err != nil {
+		return nil, err
+	}
+	nameRegex, err := compileNameRegex(cfg.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+	configuration, err := cloud.AzureCloudConfiguration(cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	credential, err := cloud.NewAzureCredential(cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if err = cloud.CheckAzureCredential(ctx, credential, configuration); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	options, err := cloud.AzureARMClientOptions(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Azure transport")
+	}
+	transport, err := cloud.NewHTTPTransport(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Azure transport")
+	}
+	return &azureAssigner{
+		lister:      cloud.NewAzurePermissionLister(credential, &http.Client{Transport: transport}, configuration),
+		networkSvc:  cloud.NewAzureNetworkService(credential, options),
+		logger:      logger,
+		filterLogic: cfg.FilterLogic,
+		nameRegex:   nameRegex,
+	}, nil
 }
 
-func (a *azureAssigner) Unassign(_ context.Context, _, _ string) error {
+// parseAzureFilters parses the tag filters of the public IP addresses, tags.<key>=<value>, into the tags to match
+func parseAzureFilters(filter []string) (map[string]string, error) {
+	tags := make(map[string]string, len(filter))
+	for _, f := range filter {
+		key, value, ok := strings.Cut(strings.TrimPrefix(f, azureTagFilterPrefix), "=")
+		if !strings.HasPrefix(f, azureTagFilterPrefix) || !ok || key == "" {
+			return nil, errors.Wrapf(ErrInvalidFilter, "Azure filter %q: expected tags.<key>=<value>", f)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// Assign assigns the first available public IP address of the pool to the virtual machine, replacing the public IP
+// address of its primary IP configuration; a public IP address of the pool already assigned is kept
+func (a *azureAssigner) Assign(ctx context.Context, instanceID, _ string, filter []string, orderBy string) (string, error) {
+	d := newDecision(instanceID, filter, orderBy)
+	resource, nic, ipConfig, err := a.primaryIPConfig(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	listStart := time.Now()
+	addresses, err := a.listAddresses(ctx, resource, filter, orderBy)
+	metrics.ObservePhase(ctx, metrics.PhaseList, listStart)
+	if err != nil {
+		return "", err
+	}
+	if held := heldAzureAddress(ipConfig, addresses); held != nil {
+		d.choose(*held.Properties.IPAddress, ReasonAlreadyAssigned)
+		d.log(a.logger)
+		return *held.Properties.IPAddress, ErrStaticIPAlreadyAssigned
+	}
+	if cloud.IsAzureScaleSetVM(resource) {
+		return "", errors.Wrapf(ErrAzureScaleSetInstance, "instance %s", instanceID)
+	}
+
+	available := freeAzureAddresses(addresses)
+	if len(available) == 0 {
+		d.choose("", ReasonNoCandidates)
+		d.log(a.logger)
+		return "", ErrNoAvailableAddress
+	}
+	for _, address := range available {
+		d.candidates = append(d.candidates, *address.Properties.IPAddress)
+	}
+	for _, address := range available {
+		ipConfig.Properties.PublicIPAddress = &armnetwork.PublicIPAddress{ID: address.ID}
+		attachStart := time.Now()
+		err = a.networkSvc.UpdateInterface(ctx, nic)
+		metrics.ObservePhase(ctx, metrics.PhaseAttach, attachStart)
+		if err == nil {
+			// the public IP address is the allocation, the network interface its association
+			a.record(instanceID, &types.CloudOperation{AllocationID: *address.ID, AssociationID: *nic.ID})
+			d.choose(*address.Properties.IPAddress, ReasonFirstAvailable)
+			d.log(a.logger)
+			return *address.Properties.IPAddress, nil
+		}
+		a.logger.WithError(err).Warnf("failed to assign public IP address %s to instance %s", *address.Properties.IPAddress, instanceID)
+		d.exclude(*address.Properties.IPAddress, err)
+	}
+	d.choose("", ReasonAllExcluded)
+	d.log(a.logger)
+	return "", errors.New("failed to assign any public IP address")
+}
+
+// Candidate returns the public IP address of the pool assigned to the virtual machine or the first available one
+func (a *azureAssigner) Candidate(ctx context.Context, instanceID, _ string, filter []string, orderBy string) (string, error) {
+	resource, _, ipConfig, err := a.primaryIPConfig(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	addresses, err := a.listAddresses(ctx, resource, filter, orderBy)
+	if err != nil {
+		return "", err
+	}
+	if held := heldAzureAddress(ipConfig, addresses); held != nil {
+		return *held.Properties.IPAddress, nil
+	}
+	available := freeAzureAddresses(addresses)
+	if len(available) == 0 {
+		return "", ErrNoAvailableAddress
+	}
+	return *available[0].Properties.IPAddress, nil
+}
+
+// Unassign removes the public IP address from the primary IP configuration of the virtual machine
+func (a *azureAssigner) Unassign(ctx context.Context, instanceID, _ string) error {
+	resource, nic, ipConfig, err := a.primaryIPConfig(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	held := ipConfig.Properties.PublicIPAddress
+	if held == nil || held.ID == nil {
+		return ErrNoPublicIPAssigned
+	}
+	if cloud.IsAzureScaleSetVM(resource) {
+		return errors.Wrapf(ErrAzureScaleSetInstance, "instance %s", instanceID)
+	}
+	ipConfig.Properties.PublicIPAddress = nil
+	start := time.Now()
+	err = a.networkSvc.UpdateInterface(ctx, nic)
+	metrics.ObservePhase(ctx, metrics.PhaseDetach, start)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unassign public IP address from instance %s", instanceID)
+	}
+	a.record(instanceID, &types.CloudOperation{AllocationID: *held.ID, AssociationID: *nic.ID})
 	return nil
 }
+
+func (a *azureAssigner) CheckPermissions(ctx context.Context, instanceID string) error {
+	return cloud.CheckAzurePermissions(ctx, a.logger, a.lister, instanceID, cloud.AzureAssignerPermissions) //nolint:wrapcheck
+}
+
+// primaryIPConfig returns the primary IP configuration of the primary network interface of the instance, with the parsed
+// instance ID and the network interface to update
+func (a *azureAssigner) primaryIPConfig(ctx context.Context, instanceID string) (*arm.ResourceID, *armnetwork.Interface, *armnetwork.InterfaceIPConfiguration, error) {
+	resource, err := arm.ParseResourceID(instanceID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to parse Azure instance ID %s", instanceID)
+	}
+	nics, err := a.networkSvc.ListInterfaces(ctx, instanceID)
+	if err != nil {
+		return nil, nil, nil, err //nolint:wrapcheck
+	}
+	var nic *armnetwork.Interface
+	for _, n := range nics {
+		// a single network interface is the primary one, the primary flag is set once there are more
+		if n.Properties != nil && (len(nics) == 1 || (n.Properties.Primary != nil && *n.Properties.Primary)) {
+			nic = n
+			break
+		}
+	}
+	if nic == nil || nic.ID == nil {
+		return nil, nil, nil, errors.Errorf("no primary network interface found for instance %s", instanceID)
+	}
+	configs := nic.Properties.IPConfigurations
+	for _, ipConfig := range configs {
+		if ipConfig.Properties != nil && (len(configs) == 1 || (ipConfig.Properties.Primary != nil && *ipConfig.Properties.Primary)) {
+			return resource, nic, ipConfig, nil
+		}
+	}
+	return nil, nil, nil, errors.Errorf("no primary IP configuration found on network interface %s", *nic.ID)
+}
+
+// listAddresses lists the static IPv4 public IP addresses of the pool in the resource group of the instance: matching the
+// tag filters, all of them (and) or any of them (or), and the name pattern, in the order
+func (a *azureAssigner) listAddresses(ctx context.Context, resource *arm.ResourceID, filter []string, orderBy string) ([]*armnetwork.PublicIPAddress, error) {
+	if orderBy != "" && orderBy != azureOrderByName {
+		return nil, errors.Wrapf(ErrInvalidFilter, "Azure supports the %s order only, got %s", azureOrderByName, orderBy)
+	}
+	groups := filterGroups(filter, a.filterLogic)
+	tagGroups := make([]map[string]string, 0, len(groups))
+	for _, group := range groups {
+		tags, err := parseAzureFilters(group)
+		if err != nil {
+			return nil, err
+		}
+		tagGroups = append(tagGroups, tags)
+	}
+	list, err := a.networkSvc.ListPublicIPAddresses(ctx, resource.SubscriptionID, resource.ResourceGroupName)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	var addresses []*armnetwork.PublicIPAddress
+	for _, address := range list {
+		if isAzurePoolAddress(address) && nameMatches(a.nameRegex, *address.Name) && azureTagsMatch(address.Tags, tagGroups) {
+			addresses = append(addresses, address)
+		}
+	}
+	if orderBy == azureOrderByName {
+		sort.SliceStable(addresses, func(i, j int) bool { return *addresses[i].Name < *addresses[j].Name })
+	}
+	return addresses, nil
+}
+
+// isAzurePoolAddress reports whether the public IP address can be assigned: static, IPv4 and allocated
+func isAzurePoolAddress(address *armnetwork.PublicIPAddress) bool {
+	p := address.Properties
+	return address.ID != nil && address.Name != nil && p != nil && p.IPAddress != nil &&
+		p.PublicIPAllocationMethod != nil && *p.PublicIPAllocationMethod == armnetwork.IPAllocationMethodStatic &&
+		(p.PublicIPAddressVersion == nil || *p.PublicIPAddressVersion == armnetwork.IPVersionIPv4)
+}
+
+// azureTagsMatch reports whether the tags match all the tags of any group
+func azureTagsMatch(tags map[string]*string, groups []map[string]string) bool {
+	for _, group := range groups {
+		matched := true
+		for key, value := range group {
+			if tag, ok := tags[key]; !ok || tag == nil || *tag != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// heldAzureAddress returns the public IP address of the pool assigned to the IP configuration, nil if none
+func heldAzureAddress(ipConfig *armnetwork.InterfaceIPConfiguration, addresses []*armnetwork.PublicIPAddress) *armnetwork.PublicIPAddress {
+	held := ipConfig.Properties.PublicIPAddress
+	if held == nil || held.ID == nil {
+		return nil
+	}
+	for _, address := range addresses {
+		if strings.EqualFold(*address.ID, *held.ID) {
+			return address
+		}
+	}
+	return nil
+}
+
+// freeAzureAddresses returns the public IP addresses associated with no IP configuration nor NAT gateway
+func freeAzureAddresses(addresses []*armnetwork.PublicIPAddress) []*armnetwork.PublicIPAddress {
+	var free []*armnetwork.PublicIPAddress
+	for _, address := range addresses {
+		if address.Properties.IPConfiguration == nil && address.Properties.NatGateway == nil {
+			free = append(free, address)
+		}
+	}
+	return free
+}
@@ -0,0 +1,232 @@
+package address
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/doitintl/kubeip/internal/types"
+	cmocks "github.com/doitintl/kubeip/mocks/cloud"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	azureTestGroup = "/subscriptions/sub/resourceGroups/rg"
+	azureTestVM    = azureTestGroup + "/providers/Microsoft.Compute/virtualMachines/vmss-flex_0"
+	azureTestNIC   = azureTestGroup + "/providers/Microsoft.Network/networkInterfaces/vmss-flex_0-nic"
+	// azureTestScaleSetVM is an instance of a uniform orchestration scale set
+	azureTestScaleSetVM = azureTestGroup + "/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/3"
+)
+
+func azureTestAddress(name, ip string, tags map[string]*string, assigned bool) *armnetwork.PublicIPAddress {
+	address := &armnetwork.PublicIPAddress{
+		ID:   to.Ptr(azureTestGroup + "/providers/Microsoft.Network/publicIPAddresses/" + name),
+		Name: to.Ptr(name),
+		Tags: tags,
+		Properties: &armnetwork.PublicIPAddressPropertiesFormat{
+			IPAddress:                to.Ptr(ip),
+			PublicIPAllocationMethod: to.Ptr(armnetwork.IPAllocationMethodStatic),
+			PublicIPAddressVersion:   to.Ptr(armnetwork.IPVersionIPv4),
+		},
+	}
+	if assigned {
+		address.Properties.IPConfiguration = &armnetwork.IPConfiguration{ID: to.Ptr("other")}
+	}
+	return address
+}
+
+// azureTestInterfaces returns a secondary and the primary network interface, holding the public IP address if any
+func azureTestInterfaces(publicIPAddressID string) []*armnetwork.Interface {
+	ipConfig := &armnetwork.InterfaceIPConfiguration{Properties: &armnetwork.InterfaceIPConfigurationPropertiesFormat{Primary: to.Ptr(true)}}
+	if publicIPAddressID != "" {
+		ipConfig.Properties.PublicIPAddress = &armnetwork.PublicIPAddress{ID: to.Ptr(publicIPAddressID)}
+	}
+	return []*armnetwork.Interface{
+		{ID: to.Ptr(azureTestNIC + "-2"), Properties: &armnetwork.InterfacePropertiesFormat{Primary: to.Ptr(false)}},
+		{ID: to.Ptr(azureTestNIC), Properties: &armnetwork.InterfacePropertiesFormat{
+			Primary:          to.Ptr(true),
+			IPConfigurations: []*armnetwork.InterfaceIPConfiguration{ipConfig},
+		}},
+	}
+}
+
+// associated returns the public IP address ID set on the primary IP configuration of the updated network interface
+func associated(nic *armnetwork.Interface) string {
+	publicIPAddress := nic.Properties.IPConfigurations[0].Properties.PublicIPAddress
+	if publicIPAddress == nil {
+		return ""
+	}
+	return *publicIPAddress.ID
+}
+
+func Test_azureAssigner_Assign(t *testing.T) {
+	reserved := map[string]*string{"kubeip": to.Ptr("reserved")}
+	addresses := []*armnetwork.PublicIPAddress{
+		azureTestAddress("ip-c", "20.0.0.3", reserved, false),
+		azureTestAddress("ip-a", "20.0.0.1", reserved, true),
+		azureTestAddress("ip-b", "20.0.0.2", reserved, false),
+		azureTestAddress("other", "20.0.0.4", nil, false),
+	}
+	tests := []struct {
+		name       string
+		instanceID string
+		held       string
+		filter     []string
+		orderBy    string
+		updateErrs []error
+		want       string
+		wantErr    error
+		wantIDs    []string
+	}{
+		{
+			name:       "first available address in the order",
+			instanceID: azureTestVM,
+			filter:     []string{"tags.kubeip=reserved"},
+			orderBy:    "name",
+			updateErrs: []error{nil},
+			want:       "20.0.0.2",
+			wantIDs:    []string{*addresses[2].ID},
+		},
+		{
+			name:       "next address after a failed association",
+			instanceID: azureTestVM,
+			filter:     []string{"tags.kubeip=reserved"},
+			orderBy:    "name",
+			updateErrs: []error{errors.New("conflict"), nil},
+			want:       "20.0.0.3",
+			wantIDs:    []string{*addresses[2].ID, *addresses[0].ID},
+		},
+		{
+			name:       "pool address already assigned",
+			instanceID: azureTestVM,
+			held:       *addresses[1].ID,
+			filter:     []string{"tags.kubeip=reserved"},
+			want:       "20.0.0.1",
+			wantErr:    ErrStaticIPAlreadyAssigned,
+		},
+		{
+			name:       "non-pool address replaced",
+			instanceID: azureTestVM,
+			held:       *addresses[3].ID,
+			filter:     []string{"tags.kubeip=reserved"},
+			updateErrs: []error{nil},
+			want:       "20.0.0.3",
+			wantIDs:    []string{*addresses[0].ID},
+		},
+		{
+			name:       "no address matching the filter",
+			instanceID: azureTestVM,
+			filter:     []string{"tags.kubeip=other"},
+			wantErr:    ErrNoAvailableAddress,
+		},
+		{
+			name:       "uniform scale set instance",
+			instanceID: azureTestScaleSetVM,
+			filter:     []string{"tags.kubeip=reserved"},
+			wantErr:    ErrAzureScaleSetInstance,
+		},
+		{
+			name:       "uniform scale set instance holding a pool address",
+			instanceID: azureTestScaleSetVM,
+			held:       *addresses[1].ID,
+			filter:     []string{"tags.kubeip=reserved"},
+			want:       "20.0.0.1",
+			wantErr:    ErrStaticIPAlreadyAssigned,
+		},
+		{
+			name:       "unsupported order",
+			instanceID: azureTestVM,
+			orderBy:    "ipAddress",
+			wantErr:    ErrInvalidFilter,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networkSvc := cmocks.NewAzureNetworkService(t)
+			networkSvc.EXPECT().ListInterfaces(mock.Anything, tt.instanceID).Return(azureTestInterfaces(tt.held), nil)
+			if tt.orderBy == "" || tt.orderBy == "name" {
+				networkSvc.EXPECT().ListPublicIPAddresses(mock.Anything, "sub", "rg").Return(addresses, nil)
+			}
+			var gotIDs []string
+			for _, err := range tt.updateErrs {
+				networkSvc.EXPECT().UpdateInterface(mock.Anything, mock.Anything).Run(func(_ context.Context, nic *armnetwork.Interface) {
+					assert.Equal(t, azureTestNIC, *nic.ID)
+					gotIDs = append(gotIDs, associated(nic))
+				}).Return(err).Once()
+			}
+			a := &azureAssigner{networkSvc: networkSvc, logger: logrus.NewEntry(logrus.New())}
+			got, err := a.Assign(context.Background(), tt.instanceID, "", tt.filter, tt.orderBy)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, &types.CloudOperation{AllocationID: gotIDs[len(gotIDs)-1], AssociationID: azureTestNIC}, a.LastOperation(tt.instanceID))
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantIDs, gotIDs)
+		})
+	}
+}
+
+func Test_azureAssigner_Candidate(t *testing.T) {
+	networkSvc := cmocks.NewAzureNetworkService(t)
+	networkSvc.EXPECT().ListInterfaces(mock.Anything, azureTestScaleSetVM).Return(azureTestInterfaces(""), nil)
+	networkSvc.EXPECT().ListPublicIPAddresses(mock.Anything, "sub", "rg").Return([]*armnetwork.PublicIPAddress{
+		azureTestAddress("ip-a", "20.0.0.1", map[string]*string{"env": to.Ptr("dev")}, false),
+		azureTestAddress("ip-b", "20.0.0.2", map[string]*string{"env": to.Ptr("prod")}, false),
+	}, nil)
+	a := &azureAssigner{networkSvc: networkSvc, logger: logrus.NewEntry(logrus.New()), filterLogic: FilterLogicOr}
+
+	got, err := a.Candidate(context.Background(), azureTestScaleSetVM, "", []string{"tags.env=prod", "tags.env=test"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "20.0.0.2", got)
+}
+
+func Test_azureAssigner_Unassign(t *testing.T) {
+	held := azureTestGroup + "/providers/Microsoft.Network/publicIPAddresses/ip-a"
+	tests := []struct {
+		name       string
+		instanceID string
+		held       string
+		wantErr    error
+	}{
+		{name: "address removed", instanceID: azureTestVM, held: held},
+		{name: "no address", instanceID: azureTestVM, wantErr: ErrNoPublicIPAssigned},
+		{name: "uniform scale set instance", instanceID: azureTestScaleSetVM, held: held, wantErr: ErrAzureScaleSetInstance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networkSvc := cmocks.NewAzureNetworkService(t)
+			networkSvc.EXPECT().ListInterfaces(mock.Anything, tt.instanceID).Return(azureTestInterfaces(tt.held), nil)
+			if tt.wantErr == nil {
+				networkSvc.EXPECT().UpdateInterface(mock.Anything, mock.Anything).Run(func(_ context.Context, nic *armnetwork.Interface) {
+					assert.Empty(t, associated(nic))
+				}).Return(nil).Once()
+			}
+			a := &azureAssigner{networkSvc: networkSvc, logger: logrus.NewEntry(logrus.New())}
+			err := a.Unassign(context.Background(), tt.instanceID, "")
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &types.CloudOperation{AllocationID: held, AssociationID: azureTestNIC}, a.LastOperation(tt.instanceID))
+		})
+	}
+}
+
+func Test_parseAzureFilters(t *testing.T) {
+	tags, err := parseAzureFilters([]string{"tags.kubeip=reserved", "tags.env="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"kubeip": "reserved", "env": ""}, tags)
+
+	for _, filter := range []string{"labels.kubeip=reserved", "tags.kubeip", "tags.=reserved"} {
+		_, err = parseAzureFilters([]string{filter})
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
@@ -0,0 +1,300 @@
+package address
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/netlink"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	bgpPrefix = "kubeip-bgp-"
+	// bgpModeLabel tells the leases claiming the BGP addresses from the other leases of the namespace
+	bgpModeLabel = "kubeip.com/mode"
+	bgpModeValue = "bgp"
+	// defaultBGPAPIAddress is the gRPC API address of the gobgpd daemon of the node
+	defaultBGPAPIAddress = "127.0.0.1:50051"
+	// bgpInterface is the interface the announced address is bound to on the node
+	bgpInterface = "lo"
+)
+
+// LeaseResource is the Kubernetes Lease resource claiming a BGP address for a node
+var LeaseResource = schema.GroupVersionResource{Group: "coordination.k8s.io", Version: "v1", Resource: "leases"}
+
+// NodeResource is the Kubernetes Node resource owning the Leases claiming its BGP addresses
+var NodeResource = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+
+type bgpAssigner struct {
+	client    dynamic.Interface
+	namespace string
+	addresses []net.IP
+	// host and port of the gobgpd gRPC API
+	apiHost string
+	apiPort string
+	nextHop string
+	run     netlink.Runner
+	// link binds the announced address to the loopback interface
+	link   netlink.Link
+	logger *logrus.Entry
+}
+
+// NewBGPAssigner returns an assigner for bare metal clusters announcing the address from the node over BGP: an address
+// is claimed from the configured addresses by creating a Lease named after it, owned by the node, bound to the loopback
+// interface of the node and announced to the upstream routers by the gobgpd daemon of the node (gobgp CLI); the address
+// is withdrawn and unbound on release, and freed when the node is deleted
+func NewBGPAssigner(logger *logrus.Entry, client dynamic.Interface, cfg *config.Config) (Assigner, error) {
+	if client == nil {
+		return nil, errors.New("kubernetes dynamic client is required for BGP")
+	}
+	addresses, err := ExpandAddresses(cfg.BGPAddresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse BGP addresses")
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("BGP addresses are required")
+	}
+	apiAddress := cfg.BGPAPIAddress
+	if apiAddress == "" {
+		apiAddress = defaultBGPAPIAddress
+	}
+	host, port, err := net.SplitHostPort(apiAddress)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid gobgp API address %s", apiAddress)
+	}
+	if cfg.BGPNextHop != "" && net.ParseIP(cfg.BGPNextHop) == nil {
+		return nil, errors.Errorf("invalid BGP next hop %s", cfg.BGPNextHop)
+	}
+	return &bgpAssigner{
+		client:    client,
+		namespace: cfg.LeaseNamespace,
+		addresses: addresses,
+		apiHost:   host,
+		apiPort:   port,
+		nextHop:   cfg.BGPNextHop,
+		run:       netlink.Run,
+		link:      netlink.NewLink(bgpInterface, netlink.Run),
+		logger:    logger,
+	}, nil
+}
+
+// bgpName returns the name of the Lease claiming the address; naming by address makes the creation the claim: concurrent
+// agents fail with already exists
+func bgpName(address string) string {
+	return bgpPrefix + strings.NewReplacer(".", "-", ":", "-").Replace(address)
+}
+
+func addressFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// claimed returns the addresses claimed by all nodes and the address claimed by the node
+func (a *bgpAssigner) claimed(ctx context.Context, nodeName string) (map[string]bool, string, error) {
+	leases, err := a.client.Resource(LeaseResource).Namespace(a.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: managedByLabel + "=" + managedByValue + "," + bgpModeLabel + "=" + bgpModeValue,
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to list BGP address leases")
+	}
+	claimed := make(map[string]bool)
+	var nodeAddress string
+	for _, lease := range leases.Items {
+		ip := net.ParseIP(lease.GetAnnotations()[addressLabel])
+		if ip == nil {
+			continue
+		}
+		claimed[ip.String()] = true
+		if holder, _, _ := unstructured.NestedString(lease.Object, "spec", "holderIdentity"); holder == nodeName {
+			nodeAddress = ip.String()
+		}
+	}
+	return claimed, nodeAddress, nil
+}
+
+// nodeOwner returns the owner reference of the node: the Lease claiming its address is garbage collected with the node,
+// freeing the address of a node deleted without releasing it
+func (a *bgpAssigner) nodeOwner(ctx context.Context, nodeName string) (map[string]interface{}, error) {
+	node, err := a.client.Resource(NodeResource).Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get node %s", nodeName)
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Node",
+		"name":       nodeName,
+		"uid":        string(node.GetUID()),
+	}, nil
+}
+
+func (a *bgpAssigner) lease(name, nodeName, address string, owner map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": LeaseResource.GroupVersion().String(),
+		"kind":       "Lease",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": a.namespace,
+			"labels": map[string]interface{}{
+				managedByLabel: managedByValue,
+				bgpModeLabel:   bgpModeValue,
+				nodeLabel:      nodeName,
+			},
+			// label values cannot hold IPv6 addresses
+			"annotations":     map[string]interface{}{addressLabel: address},
+			"ownerReferences": []interface{}{owner},
+		},
+		"spec": map[string]interface{}{"holderIdentity": nodeName},
+	}}
+}
+
+// gobgp runs the gobgp CLI against the gobgpd API of the node
+func (a *bgpAssigner) gobgp(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := a.run(ctx, "gobgp", append([]string{"-u", a.apiHost, "-p", a.apiPort}, args...)...)
+	if err != nil {
+		return out, errors.Wrapf(err, "gobgp %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// announce binds the address to the node and announces it to the BGP peers
+func (a *bgpAssigner) announce(ctx context.Context, ip net.IP) error {
+	if err := a.link.Add(ctx, ip.String()); err != nil {
+		return err //nolint:wrapcheck
+	}
+	args := []string{"global", "rib", "add", hostCIDR(ip), "-a", addressFamily(ip)}
+	if a.nextHop != "" {
+		args = append(args, "nexthop", a.nextHop)
+	}
+	if _, err := a.gobgp(ctx, args...); err != nil {
+		// unbind: the traffic to the address does not reach the node
+		_ = a.link.Remove(ctx, ip.String())
+		return errors.Wrapf(err, "failed to announce %s", ip)
+	}
+	return nil
+}
+
+// withdraw withdraws the address from the BGP peers and unbinds it from the node
+func (a *bgpAssigner) withdraw(ctx context.Context, ip net.IP) error {
+	if _, err := a.gobgp(ctx, "global", "rib", "del", hostCIDR(ip), "-a", addressFamily(ip)); err != nil {
+		return errors.Wrapf(err, "failed to withdraw %s", ip)
+	}
+	return a.link.Remove(ctx, ip.String()) //nolint:wrapcheck
+}
+
+func (a *bgpAssigner) Assign(ctx context.Context, instanceID, _ string, _ []string, _ string) (string, error) {
+	claimed, nodeAddress, err := a.claimed(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if nodeAddress != "" {
+		// announce again: the route is lost when gobgpd restarts
+		if err = a.announce(ctx, net.ParseIP(nodeAddress)); err != nil {
+			return "", err
+		}
+		a.logger.WithFields(logrus.Fields{
+			"node":    instanceID,
+			"address": nodeAddress,
+		}).Info("BGP address already claimed by the node")
+		return nodeAddress, nil
+	}
+
+	owner, err := a.nodeOwner(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range a.addresses {
+		address := ip.String()
+		if claimed[address] {
+			continue
+		}
+		name := bgpName(address)
+		_, err = a.client.Resource(LeaseResource).Namespace(a.namespace).Create(ctx, a.lease(name, instanceID, address, owner), metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// claimed concurrently by another agent
+			a.logger.WithField("address", address).Debug("BGP address claimed by another node, retrying with another address")
+			continue
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create BGP address lease %s", name)
+		}
+		if err = a.announce(ctx, ip); err != nil {
+			// release the claim: the address is not announced
+			_ = a.client.Resource(LeaseResource).Namespace(a.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+			return "", err
+		}
+		a.logger.WithFields(logrus.Fields{
+			"node":    instanceID,
+			"address": address,
+		}).Info("BGP address claimed and announced by the node")
+		return address, nil
+	}
+	return "", ErrNoAvailableAddress
+}
+
+func (a *bgpAssigner) Candidate(ctx context.Context, instanceID, _ string, _ []string, _ string) (string, error) {
+	claimed, nodeAddress, err := a.claimed(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if nodeAddress != "" {
+		return nodeAddress, nil
+	}
+	for _, ip := range a.addresses {
+		if !claimed[ip.String()] {
+			return ip.String(), nil
+		}
+	}
+	return "", ErrNoAvailableAddress
+}
+
+// Announced checks if the address is announced from the node: the address is claimed by the node and in the global RIB
+// of its gobgpd daemon
+func (a *bgpAssigner) Announced(ctx context.Context, instanceID, address string) (bool, error) {
+	_, nodeAddress, err := a.claimed(ctx, instanceID)
+	if err != nil {
+		return false, err
+	}
+	ip := net.ParseIP(address)
+	if nodeAddress != address || ip == nil {
+		return false, nil
+	}
+	out, err := a.gobgp(ctx, "global", "rib", hostCIDR(ip), "-a", addressFamily(ip))
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(out), hostCIDR(ip)), nil
+}
+
+func (a *bgpAssigner) Unassign(ctx context.Context, instanceID, _ string) error {
+	_, nodeAddress, err := a.claimed(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if nodeAddress == "" {
+		return ErrNoStaticIPAssigned
+	}
+	// withdraw before releasing the claim: another node must not announce the address meanwhile
+	if err = a.withdraw(ctx, net.ParseIP(nodeAddress)); err != nil {
+		return err
+	}
+	name := bgpName(nodeAddress)
+	err = a.client.Resource(LeaseResource).Namespace(a.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete BGP address lease %s", name)
+	}
+	a.logger.WithFields(logrus.Fields{
+		"node":    instanceID,
+		"address": nodeAddress,
+	}).Info("BGP address withdrawn and released by the node")
+	return nil
+}
@@ -0,0 +1,150 @@
+package address
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/netlink"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// fakeBGPDaemon records the commands and keeps the global RIB of the gobgpd daemon
+type fakeBGPDaemon struct {
+	commands []string
+	rib      map[string]bool
+	fail     string
+}
+
+func (f *fakeBGPDaemon) run(_ context.Context, name string, args ...string) ([]byte, error) {
+	command := name + " " + strings.Join(args, " ")
+	f.commands = append(f.commands, command)
+	if f.fail != "" && strings.Contains(command, f.fail) {
+		return []byte("failed"), errors.New("exit status 1")
+	}
+	if name == "gobgp" && len(args) > 6 {
+		// gobgp -u <host> -p <port> global rib [add|del] <prefix> -a <family>
+		switch args[6] {
+		case "add":
+			f.rib[args[7]] = true
+		case "del":
+			delete(f.rib, args[7])
+		default:
+			if f.rib[args[6]] {
+				return []byte("   Network              Next Hop             AS_PATH              Age        Attrs\n*> " + args[6] + "        0.0.0.0                                   00:00:01   [{Origin: ?}]\n"), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func newTestBGP(t *testing.T, addresses ...string) (*bgpAssigner, *fakeBGPDaemon, *dynamicfake.FakeDynamicClient) {
+	var nodes []runtime.Object
+	for _, name := range []string{"node-1", "node-2", "node-3"} {
+		nodes = append(nodes, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Node",
+			"metadata":   map[string]interface{}{"name": name, "uid": "uid-" + name},
+		}})
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		LeaseResource: "LeaseList",
+		NodeResource:  "NodeList",
+	}, nodes...)
+	a, err := NewBGPAssigner(logrus.NewEntry(logrus.New()), client, &config.Config{BGPAddresses: addresses, LeaseNamespace: "default"})
+	require.NoError(t, err)
+	daemon := &fakeBGPDaemon{rib: map[string]bool{}}
+	assigner := a.(*bgpAssigner) //nolint:forcetypeassert
+	assigner.run = daemon.run
+	assigner.link = netlink.NewLink(bgpInterface, daemon.run)
+	return assigner, daemon, client
+}
+
+func TestBGPAssigner(t *testing.T) {
+	ctx := context.Background()
+	a, daemon, client := newTestBGP(t, "192.0.2.10", "192.0.2.11")
+
+	got, err := a.Assign(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", got)
+	assert.Equal(t, []string{
+		"ip addr replace 192.0.2.10/32 dev lo",
+		"gobgp -u 127.0.0.1 -p 50051 global rib add 192.0.2.10/32 -a ipv4",
+	}, daemon.commands)
+	lease, err := client.Resource(LeaseResource).Namespace("default").Get(ctx, "kubeip-bgp-192-0-2-10", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "node-1", lease.GetLabels()[nodeLabel])
+	// the claim is garbage collected with the node
+	require.Len(t, lease.GetOwnerReferences(), 1)
+	assert.Equal(t, metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: "node-1", UID: "uid-node-1"}, lease.GetOwnerReferences()[0])
+
+	// another node gets another address, the node keeps its address
+	got, err = a.Assign(ctx, "node-2", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.11", got)
+	got, err = a.Candidate(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", got)
+	_, err = a.Assign(ctx, "node-3", "", nil, "")
+	assert.ErrorIs(t, err, ErrNoAvailableAddress)
+
+	announced, err := a.Announced(ctx, "node-1", "192.0.2.10")
+	require.NoError(t, err)
+	assert.True(t, announced)
+	announced, err = a.Announced(ctx, "node-2", "192.0.2.10")
+	require.NoError(t, err)
+	assert.False(t, announced)
+
+	// the release withdraws the route and frees the address
+	require.NoError(t, a.Unassign(ctx, "node-1", ""))
+	assert.False(t, daemon.rib["192.0.2.10/32"])
+	assert.ErrorIs(t, a.Unassign(ctx, "node-1", ""), ErrNoStaticIPAssigned)
+	got, err = a.Candidate(ctx, "node-3", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", got)
+}
+
+func TestBGPAssigner_unknownNode(t *testing.T) {
+	a, daemon, _ := newTestBGP(t, "192.0.2.10")
+
+	// a claim without its owner would never be freed
+	_, err := a.Assign(context.Background(), "node-4", "", nil, "")
+	require.Error(t, err)
+	assert.Empty(t, daemon.commands, "nothing is announced")
+}
+
+func TestBGPAssigner_announceFailure(t *testing.T) {
+	ctx := context.Background()
+	a, daemon, _ := newTestBGP(t, "2001:db8::10")
+	daemon.fail = "rib add"
+
+	_, err := a.Assign(ctx, "node-1", "", nil, "")
+	require.Error(t, err)
+	assert.Equal(t, "ip addr del 2001:db8::10/128 dev lo", daemon.commands[len(daemon.commands)-1], "the address is unbound")
+	// the claim is released: the address is not announced
+	got, err := a.Candidate(ctx, "node-2", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::10", got)
+}
+
+func TestNewBGPAssigner(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	log := logrus.NewEntry(logrus.New())
+	_, err := NewBGPAssigner(log, client, &config.Config{})
+	assert.Error(t, err, "no addresses")
+	_, err = NewBGPAssigner(log, client, &config.Config{BGPAddresses: []string{"192.0.2.10"}, BGPAPIAddress: "localhost"})
+	assert.Error(t, err, "API address without port")
+	_, err = NewBGPAssigner(log, client, &config.Config{BGPAddresses: []string{"192.0.2.10"}, BGPNextHop: "router"})
+	assert.Error(t, err, "invalid next hop")
+	_, err = NewBGPAssigner(log, nil, &config.Config{BGPAddresses: []string{"192.0.2.10"}})
+	assert.Error(t, err, "no client")
+}
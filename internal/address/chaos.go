@@ -0,0 +1,142 @@
+package address
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrChaosFault is the cloud provider error injected in chaos mode
+var ErrChaosFault = errors.New("chaos: injected cloud provider error")
+
+// chaosAssigner injects faults into the cloud provider calls of the assigner (chaos mode): errors, delays and stale
+// candidates, to verify the retry, lease and reconciliation logic under failures
+type chaosAssigner struct {
+	assigner  Assigner
+	logger    *logrus.Entry
+	errorRate float64
+	maxDelay  time.Duration
+	staleRate float64
+	random    func() float64
+	mutex     sync.Mutex
+	// candidates are the last candidates by instance, returned again as stale reads
+	candidates map[string]string
+}
+
+// chaosAnnouncer is the chaos assigner of an assigner announcing the address itself
+type chaosAnnouncer struct {
+	*chaosAssigner
+	announcer Announcer
+}
+
+// ChaosEnabled reports if the chaos mode is enabled: any fault configured
+func ChaosEnabled(cfg *config.Config) bool {
+	return cfg.ChaosErrorRate > 0 || cfg.ChaosMaxDelay > 0 || cfg.ChaosStaleRate > 0
+}
+
+// NewChaosAssigner returns the assigner injecting the configured faults into the calls of the assigner
+func NewChaosAssigner(logger *logrus.Entry, assigner Assigner, cfg *config.Config) (Assigner, error) {
+	for name, rate := range map[string]float64{"error": cfg.ChaosErrorRate, "stale read": cfg.ChaosStaleRate} {
+		if rate < 0 || rate > 1 {
+			return nil, errors.Errorf("chaos %s rate %v is not between 0 and 1", name, rate)
+		}
+	}
+	chaos := &chaosAssigner{
+		assigner:   assigner,
+		logger:     logger.WithField("chaos", true),
+		errorRate:  cfg.ChaosErrorRate,
+		maxDelay:   cfg.ChaosMaxDelay,
+		staleRate:  cfg.ChaosStaleRate,
+		random:     rand.Float64, //nolint:gosec
+		candidates: make(map[string]string),
+	}
+	if announcer, ok := assigner.(Announcer); ok {
+		return &chaosAnnouncer{chaosAssigner: chaos, announcer: announcer}, nil
+	}
+	return chaos, nil
+}
+
+// inject delays the call up to the maximum delay and fails it at the error rate
+func (c *chaosAssigner) inject(ctx context.Context, operation, instanceID string) error {
+	c.mutex.Lock()
+	delay := time.Duration(c.random() * float64(c.maxDelay))
+	fail := c.random() < c.errorRate
+	c.mutex.Unlock()
+	if delay > 0 {
+		c.logger.WithFields(logrus.Fields{"operation": operation, "instance": instanceID, "delay": delay}).Debug("injecting delay")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "chaos: %s cancelled", operation)
+		}
+	}
+	if fail {
+		c.logger.WithFields(logrus.Fields{"operation": operation, "instance": instanceID}).Info("injecting cloud provider error")
+		return errors.Wrap(ErrChaosFault, operation)
+	}
+	return nil
+}
+
+func (c *chaosAssigner) Assign(ctx context.Context, instanceID, zone string, filter []string, orderBy string) (string, error) {
+	if err := c.inject(ctx, "assign", instanceID); err != nil {
+		return "", err
+	}
+	return c.assigner.Assign(ctx, instanceID, zone, filter, orderBy) //nolint:wrapcheck
+}
+
+func (c *chaosAssigner) Candidate(ctx context.Context, instanceID, zone string, filter []string, orderBy string) (string, error) {
+	if err := c.inject(ctx, "candidate", instanceID); err != nil {
+		return "", err
+	}
+	c.mutex.Lock()
+	stale, ok := c.candidates[instanceID]
+	staleRead := ok && c.random() < c.staleRate
+	c.mutex.Unlock()
+	if staleRead {
+		c.logger.WithFields(logrus.Fields{"instance": instanceID, "address": stale}).Info("injecting stale candidate")
+		return stale, nil
+	}
+	candidate, err := c.assigner.Candidate(ctx, instanceID, zone, filter, orderBy)
+	if err == nil {
+		c.mutex.Lock()
+		c.candidates[instanceID] = candidate
+		c.mutex.Unlock()
+	}
+	return candidate, err //nolint:wrapcheck
+}
+
+func (c *chaosAssigner) Unassign(ctx context.Context, instanceID, zone string) error {
+	if err := c.inject(ctx, "unassign", instanceID); err != nil {
+		return err
+	}
+	return c.assigner.Unassign(ctx, instanceID, zone) //nolint:wrapcheck
+}
+
+// CheckPermissions checks the permissions of the assigner without faults: the chaos mode tests the runtime behavior
+func (c *chaosAssigner) CheckPermissions(ctx context.Context, instanceID string) error {
+	if checker, ok := c.assigner.(PermissionChecker); ok {
+		return checker.CheckPermissions(ctx, instanceID) //nolint:wrapcheck
+	}
+	return nil
+}
+
+// LastOperation reports the operations of the assigner: the chaos mode fails calls before they reach the cloud provider
+func (c *chaosAssigner) LastOperation(instanceID string) *types.CloudOperation {
+	if reporter, ok := c.assigner.(OperationReporter); ok {
+		return reporter.LastOperation(instanceID)
+	}
+	return nil
+}
+
+func (c *chaosAnnouncer) Announced(ctx context.Context, instanceID, address string) (bool, error) {
+	if err := c.inject(ctx, "announced", instanceID); err != nil {
+		return false, err
+	}
+	return c.announcer.Announced(ctx, instanceID, address) //nolint:wrapcheck
+}
@@ -0,0 +1,92 @@
+package address
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAssigner returns the next candidate of the list on every call
+type stubAssigner struct {
+	candidates []string
+	calls      int
+}
+
+func (s *stubAssigner) Assign(context.Context, string, string, []string, string) (string, error) {
+	s.calls++
+	return "203.0.113.1", nil
+}
+
+func (s *stubAssigner) Candidate(context.Context, string, string, []string, string) (string, error) {
+	s.calls++
+	candidate := s.candidates[0]
+	s.candidates = s.candidates[1:]
+	return candidate, nil
+}
+
+func (s *stubAssigner) Unassign(context.Context, string, string) error {
+	s.calls++
+	return nil
+}
+
+func newTestChaos(t *testing.T, assigner Assigner, cfg *config.Config, random float64) *chaosAssigner {
+	a, err := NewChaosAssigner(logrus.NewEntry(logrus.New()), assigner, cfg)
+	require.NoError(t, err)
+	chaos := a.(*chaosAssigner)
+	chaos.random = func() float64 { return random }
+	return chaos
+}
+
+func TestChaosAssigner_Errors(t *testing.T) {
+	stub := &stubAssigner{}
+	chaos := newTestChaos(t, stub, &config.Config{ChaosErrorRate: 0.5}, 0.4)
+	_, err := chaos.Assign(context.Background(), "i-1", "", nil, "")
+	assert.ErrorIs(t, err, ErrChaosFault)
+	assert.ErrorIs(t, chaos.Unassign(context.Background(), "i-1", ""), ErrChaosFault)
+	assert.Equal(t, 0, stub.calls)
+
+	chaos.random = func() float64 { return 0.6 }
+	assigned, err := chaos.Assign(context.Background(), "i-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.1", assigned)
+	assert.Equal(t, 1, stub.calls)
+}
+
+func TestChaosAssigner_StaleReads(t *testing.T) {
+	stub := &stubAssigner{candidates: []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"}}
+	chaos := newTestChaos(t, stub, &config.Config{ChaosStaleRate: 0.5}, 0.4)
+
+	// the first read is fresh, the next ones return it again
+	for i := 0; i < 2; i++ {
+		candidate, err := chaos.Candidate(context.Background(), "i-1", "", nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "203.0.113.1", candidate)
+	}
+
+	chaos.random = func() float64 { return 0.6 }
+	candidate, err := chaos.Candidate(context.Background(), "i-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.2", candidate)
+}
+
+func TestNewChaosAssigner(t *testing.T) {
+	_, err := NewChaosAssigner(logrus.NewEntry(logrus.New()), &stubAssigner{}, &config.Config{ChaosErrorRate: 1.5})
+	assert.Error(t, err)
+
+	a, err := NewChaosAssigner(logrus.NewEntry(logrus.New()), &metalLBAssigner{}, &config.Config{ChaosStaleRate: 0.1})
+	require.NoError(t, err)
+	_, ok := a.(Announcer)
+	assert.True(t, ok, "chaos assigner of an announcer announces")
+
+	a, err = NewChaosAssigner(logrus.NewEntry(logrus.New()), &stubAssigner{}, &config.Config{ChaosStaleRate: 0.1})
+	require.NoError(t, err)
+	_, ok = a.(Announcer)
+	assert.False(t, ok, "chaos assigner of a cloud assigner does not announce")
+
+	assert.False(t, ChaosEnabled(&config.Config{}))
+	assert.True(t, ChaosEnabled(&config.Config{ChaosMaxDelay: 1}))
+}
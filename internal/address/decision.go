@@ -0,0 +1,60 @@
+package address
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// reasons of the assignment decisions
+const (
+	// ReasonAlreadyAssigned is the decision to keep the static public IP address the instance holds
+	ReasonAlreadyAssigned = "already-assigned"
+	// ReasonNoCandidates is the decision to assign nothing: no available address matches the filter
+	ReasonNoCandidates = "no-candidates"
+	// ReasonFirstAvailable is the decision to assign the first candidate in the order the assignment succeeded for
+	ReasonFirstAvailable = "first-available"
+	// ReasonAllExcluded is the decision to assign nothing: the assignment failed for every candidate
+	ReasonAllExcluded = "all-excluded"
+)
+
+// decision is the record of an assignment decision, logged once decided: the candidates the filter and order selected,
+// the candidates excluded and why, and the chosen address with the reason, so why an address was picked (or none) is
+// answered from the logs
+type decision struct {
+	instance   string
+	filter     []string
+	orderBy    string
+	candidates []string
+	// excluded are the reasons of the candidates the assignment failed for, by address
+	excluded map[string]string
+	chosen   string
+	reason   string
+}
+
+func newDecision(instance string, filter []string, orderBy string) *decision {
+	return &decision{instance: instance, filter: filter, orderBy: orderBy, excluded: make(map[string]string)}
+}
+
+// exclude records the candidate the assignment failed for
+func (d *decision) exclude(address string, err error) {
+	d.excluded[address] = err.Error()
+}
+
+// choose records the chosen address, empty if none, and the reason of the decision
+func (d *decision) choose(address, reason string) {
+	d.chosen = address
+	d.reason = reason
+}
+
+// log writes the decision record as one structured entry
+func (d *decision) log(logger *logrus.Entry) {
+	logger.WithFields(logrus.Fields{
+		"instance":   d.instance,
+		"filter":     d.filter,
+		"order-by":   d.orderBy,
+		"candidates": len(d.candidates),
+		"considered": d.candidates,
+		"excluded":   d.excluded,
+		"chosen":     d.chosen,
+		"reason":     d.reason,
+	}).Info("assignment decision")
+}
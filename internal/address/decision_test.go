@@ -0,0 +1,37 @@
+package address
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestDecision_log(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	d := newDecision("i-1", []string{"labels.env=prod"}, "name")
+	d.candidates = []string{"1.1.1.1", "2.2.2.2"}
+	d.exclude("1.1.1.1", errors.New("address is already assigned"))
+	d.choose("2.2.2.2", ReasonFirstAvailable)
+	d.log(logrus.NewEntry(logger))
+
+	entry := hook.LastEntry()
+	if entry == nil || entry.Message != "assignment decision" {
+		t.Fatalf("log() entry = %+v, want the assignment decision", entry)
+	}
+	want := logrus.Fields{
+		"instance":   "i-1",
+		"filter":     []string{"labels.env=prod"},
+		"order-by":   "name",
+		"candidates": 2,
+		"considered": []string{"1.1.1.1", "2.2.2.2"},
+		"excluded":   map[string]string{"1.1.1.1": "address is already assigned"},
+		"chosen":     "2.2.2.2",
+		"reason":     ReasonFirstAvailable,
+	}
+	if !reflect.DeepEqual(entry.Data, want) {
+		t.Errorf("log() fields = %v, want %v", entry.Data, want)
+	}
+}
@@ -3,11 +3,16 @@ package address
 import (
 	"context"
 	"fmt"
+	"net"
+	"regexp"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
 	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/doitintl/kubeip/internal/types"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/compute/v1"
@@ -28,8 +33,22 @@ const (
 	maxRetries                  = 10 // number of retries for assigning ephemeral public IP address
 )
 
+// the access configs added to private nodes are told apart on release: the node never had an ephemeral address
+const (
+	privateNetworkName     = "kubeip-private-node"
+	privateNetworkNameIPv6 = "kubeip-private-node-ipv6"
+)
+
 var (
 	ErrNoPublicIPAssigned = errors.New("no public IP address assigned to the instance")
+	// ErrPrivateNode is returned for a node without external access config when the private nodes are refused
+	ErrPrivateNode = errors.New("private node without external access config")
+)
+
+// policies of the private nodes, instances without external access config (private GKE clusters behind Cloud NAT)
+const (
+	PrivateNodesAdd    = "add"
+	PrivateNodesRefuse = "refuse"
 )
 
 type internalAssigner interface {
@@ -43,10 +62,24 @@ type gcpAssigner struct {
 	waiter         cloud.ZoneWaiter
 	addressManager cloud.AddressManager
 	instanceGetter cloud.InstanceGetter
+	permissions    cloud.GCPPermissionTester
 	project        string
 	region         string
 	ipv6           bool
-	logger         *logrus.Entry
+	// aliasIPRanges are the alias IP ranges attached to the network interface, one per instance; disabled if empty
+	aliasIPRanges []*compute.AliasIpRange
+	aliasUpdater  cloud.AliasIPRangeUpdater
+	quotaGetter   cloud.RegionQuotaGetter
+	// privateNodes is the policy of the instances without external access config
+	privateNodes string
+	// filterLogic combines the filters of the addresses: and, or
+	filterLogic string
+	// nameRegex selects the candidate addresses by name, nil for all
+	nameRegex *regexp.Regexp
+	// addressLabels marks the static addresses with the instance holding them
+	addressLabels bool
+	logger        *logrus.Entry
+	operations
 }
 
 type operationError struct {
@@ -81,14 +114,38 @@ func (e *operationError) Error() string {
 	return fmt.Sprintf("operation %s failed with error %v", e.name, joinErrorMessages(e.err))
 }
 
-func NewGCPAssigner(ctx context.Context, logger *logrus.Entry, project, region string, ipv6 bool) (Assigner, error) {
+func NewGCPAssigner(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Assigner, error) {
+	aliasIPRanges, err := parseAliasIPRanges(cfg.GCPAliasIPRanges)
+	if err != nil {
+		return nil, err
+	}
+	privateNodes := cfg.GCPPrivateNodes
+	switch privateNodes {
+	case "":
+		privateNodes = PrivateNodesAdd
+	case PrivateNodesAdd, PrivateNodesRefuse:
+	default:
+		return nil, errors.Errorf("unknown private nodes policy %q, want %s or %s", privateNodes, PrivateNodesAdd, PrivateNodesRefuse)
+	}
+
+	nameRegex, err := compileNameRegex(cfg.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := cloud.GCPClientOptions(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
 	// initialize Google Cloud client
-	client, err := compute.NewService(ctx)
+	client, err := compute.NewService(ctx, cloud.GCPComputeOptions(opts, cfg)...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize Google Cloud client")
 	}
 
 	// get project ID from metadata server
+	project, region := cfg.Project, cfg.Region
 	if project == "" {
 		project, err = metadata.ProjectID()
 		if err != nil {
@@ -108,18 +165,57 @@ func NewGCPAssigner(ctx context.Context, logger *logrus.Entry, project, region s
 		}
 	}
 
+	permissionTester, err := cloud.NewGCPPermissionTester(ctx, opts...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
 	return &gcpAssigner{
 		lister:         cloud.NewLister(client),
 		waiter:         cloud.NewZoneWaiter(client),
-		addressManager: cloud.NewAddressManager(client, ipv6),
+		addressManager: cloud.NewAddressManager(client, cfg.IPv6),
 		instanceGetter: cloud.NewInstanceGetter(client),
+		permissions:    permissionTester,
 		project:        project,
 		region:         region,
-		ipv6:           ipv6,
+		ipv6:           cfg.IPv6,
+		aliasIPRanges:  aliasIPRanges,
+		filterLogic:    cfg.FilterLogic,
+		nameRegex:      nameRegex,
+		addressLabels:  cfg.GCPAddressLabels,
+		aliasUpdater:   cloud.NewAliasIPRangeUpdater(client),
+		quotaGetter:    cloud.NewRegionQuotaGetter(client),
+		privateNodes:   privateNodes,
 		logger:         logger,
 	}, nil
 }
 
+// parseAliasIPRanges parses the alias IP ranges: a CIDR of the primary range of the subnet, or <secondary-range>:<CIDR>
+// for a CIDR of a secondary range
+func parseAliasIPRanges(entries []string) ([]*compute.AliasIpRange, error) {
+	ranges := make([]*compute.AliasIpRange, 0, len(entries))
+	for _, entry := range entries {
+		name, cidr, found := strings.Cut(entry, ":")
+		if !found {
+			name, cidr = "", entry
+		}
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil || ip.To4() == nil {
+			return nil, errors.Errorf("invalid alias IP range %s: expected an IPv4 CIDR, optionally prefixed with <secondary-range>:", entry)
+		}
+		ranges = append(ranges, &compute.AliasIpRange{IpCidrRange: ipNet.String(), SubnetworkRangeName: name})
+	}
+	return ranges, nil
+}
+
+func (a *gcpAssigner) CheckPermissions(ctx context.Context, _ string) error {
+	permissions := cloud.GCPAssignerPermissions
+	if a.addressLabels {
+		permissions = append(append([]string{}, permissions...), "compute.addresses.setLabels")
+	}
+	return cloud.CheckGCPPermissions(ctx, a.logger, a.permissions, a.project, permissions) //nolint:wrapcheck
+}
+
 func (a *gcpAssigner) waitForOperation(c context.Context, op *compute.Operation, zone string, timeout time.Duration) error {
 	if op == nil {
 		a.logger.Warn("operation is nil")
@@ -149,6 +245,13 @@ func (a *gcpAssigner) waitForOperation(c context.Context, op *compute.Operation,
 	return nil
 }
 
+// recordOperation records the zone operation of the instance, failed operations included: their audit log entry tells why
+func (a *gcpAssigner) recordOperation(instance *compute.Instance, op *compute.Operation) {
+	if op != nil {
+		a.record(instance.Name, &types.CloudOperation{OperationID: op.Name})
+	}
+}
+
 func (a *gcpAssigner) DeleteInstanceAddress(ctx context.Context, instance *compute.Instance, zone string) error {
 	// get instance network interface
 	networkInterface, err := getNetworkInterface(instance)
@@ -168,6 +271,7 @@ func (a *gcpAssigner) DeleteInstanceAddress(ctx context.Context, instance *compu
 	if err != nil {
 		return errors.Wrapf(err, "failed to delete access config %s from instance %s", accessConfig.Name, instance.Name)
 	}
+	a.recordOperation(instance, op)
 	// wait for operation to complete
 	if err = a.waitForOperation(ctx, op, zone, defaultTimeout); err != nil {
 		// return error if operation failed
@@ -186,14 +290,19 @@ func (a *gcpAssigner) AddInstanceAddress(ctx context.Context, instance *compute.
 	if err != nil {
 		return errors.Wrap(err, "failed to get instance network interface")
 	}
-	// create access config for the address
-	accessConfig := createAccessConfig(address, a.ipv6)
+	// add the access config of the address
+	return a.addAccessConfig(ctx, instance, networkInterface, zone, createAccessConfig(address, a.ipv6))
+}
+
+// addAccessConfig adds the access config to the network interface of the instance and waits for the operation
+func (a *gcpAssigner) addAccessConfig(ctx context.Context, instance *compute.Instance, networkInterface *compute.NetworkInterface, zone string, accessConfig *compute.AccessConfig) error {
 	// add instance network interface access config
 	a.logger.WithField("accessConfig", accessConfig).Info("adding public IP address to instance")
 	op, err := a.addressManager.AddAccessConfig(a.project, zone, instance.Name, networkInterface.Name, networkInterface.Fingerprint, accessConfig)
 	if err != nil {
 		return errors.Wrapf(err, "failed to add access config to instance %s", instance.Name)
 	}
+	a.recordOperation(instance, op)
 	// wait for operation to complete
 	if err = a.waitForOperation(ctx, op, zone, defaultTimeout); err != nil {
 		// return error if operation failed
@@ -215,32 +324,53 @@ func (a *gcpAssigner) CheckAddressAssigned(region, addressName string) (bool, er
 }
 
 func (a *gcpAssigner) Assign(ctx context.Context, instanceID, zone string, filter []string, orderBy string) (string, error) {
+	d := newDecision(instanceID, filter, orderBy)
 	// check if instance already has a public static IP address assigned
 	instance, address, err := a.checkStaticIPAssigned(zone, instanceID)
 	if err != nil {
 		if errors.Is(err, ErrStaticIPAlreadyAssigned) {
+			d.choose(address, ReasonAlreadyAssigned)
+			d.log(a.logger)
+			// the agent may have restarted before attaching the alias IP range
+			if err = a.assignAliasIPRange(ctx, instanceID, zone); err != nil {
+				return "", errors.Wrapf(err, "failed to assign alias IP range to instance %s", instanceID)
+			}
 			return address, nil
 		}
 		return "", errors.Wrapf(err, "check if static public IP is already assigned to instance %s", instanceID)
 	}
 
+	// a private node has no external access config: add one or refuse the node
+	var as internalAssigner = a
+	if a.isPrivate(instance) {
+		if a.privateNodes == PrivateNodesRefuse {
+			return "", errors.Wrapf(ErrPrivateNode, "instance %s", instanceID)
+		}
+		a.logger.WithField("instance", instanceID).Info("private node without external access config, adding one with the static public IP address")
+		as = &privateNodeAssigner{gcpAssigner: a}
+	}
+
 	// get available reserved public IP addresses
+	listStart := time.Now()
 	addresses, err := a.listAddresses(filter, orderBy, reservedStatus)
+	metrics.ObservePhase(ctx, metrics.PhaseList, listStart)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to list available addresses")
 	}
 	if len(addresses) == 0 {
+		d.choose("", ReasonNoCandidates)
+		d.log(a.logger)
 		return "", errors.Errorf("no available addresses")
 	}
-	// log available addresses IPs
-	ips := make([]string, 0, len(addresses))
 	for _, address := range addresses {
-		ips = append(ips, address.Address)
+		d.candidates = append(d.candidates, address.Address)
 	}
-	a.logger.WithField("addresses", ips).Debugf("found %d available addresses", len(addresses))
 
 	// delete current ephemeral public IP address
-	if err = a.DeleteInstanceAddress(ctx, instance, zone); err != nil && !errors.Is(err, ErrNoPublicIPAssigned) {
+	detachStart := time.Now()
+	err = a.DeleteInstanceAddress(ctx, instance, zone)
+	metrics.ObservePhase(ctx, metrics.PhaseDetach, detachStart)
+	if err != nil && !errors.Is(err, ErrNoPublicIPAssigned) {
 		return "", errors.Wrap(err, "failed to delete current public IP address")
 	}
 
@@ -252,24 +382,155 @@ func (a *gcpAssigner) Assign(ctx context.Context, instanceID, zone string, filte
 
 	// try to assign all available addresses until one succeeds
 	// due to concurrency, it is possible that another kubeip instance will assign the same address
-	var assignedAddress string
+	var assignedAddress *compute.Address
 	for _, address := range addresses {
 		// check if context is done before trying to assign an address
 		if ctx.Err() != nil {
 			return "", errors.Wrap(ctx.Err(), "context cancelled while assigning addresses")
 		}
-		if err = tryAssignAddress(ctx, a, instance, a.region, zone, address); err != nil {
+		attachStart := time.Now()
+		err = tryAssignAddress(ctx, as, instance, a.region, zone, address)
+		metrics.ObservePhase(ctx, metrics.PhaseAttach, attachStart)
+		if err != nil {
 			a.logger.WithError(err).WithField("address", address.Address).Error("failed to assign static public IP address")
+			d.exclude(address.Address, err)
 			continue
 		}
-		assignedAddress = address.Address
+		assignedAddress = address
 		// break the loop after successfully assigning an address
 		break
 	}
 	if err != nil {
+		d.choose("", ReasonAllExcluded)
+		d.log(a.logger)
 		return "", errors.Wrap(err, "failed to assign static public IP address")
 	}
-	return assignedAddress, nil
+	d.choose(assignedAddress.Address, ReasonFirstAvailable)
+	d.log(a.logger)
+	a.labelOwner(assignedAddress, instance.Name)
+	if err = a.assignAliasIPRange(ctx, instanceID, zone); err != nil {
+		return "", errors.Wrapf(err, "failed to assign alias IP range to instance %s", instanceID)
+	}
+	return assignedAddress.Address, nil
+}
+
+// heldAliasIPRanges returns the alias IP ranges of the pool attached to the network interface
+func (a *gcpAssigner) heldAliasIPRanges(networkInterface *compute.NetworkInterface) []*compute.AliasIpRange {
+	var held []*compute.AliasIpRange
+	for _, r := range networkInterface.AliasIpRanges {
+		for _, aliasIPRange := range a.aliasIPRanges {
+			if r.IpCidrRange == aliasIPRange.IpCidrRange && r.SubnetworkRangeName == aliasIPRange.SubnetworkRangeName {
+				held = append(held, aliasIPRange)
+			}
+		}
+	}
+	return held
+}
+
+// updateAliasIPRanges replaces the alias IP ranges of the network interface and waits for the operation
+func (a *gcpAssigner) updateAliasIPRanges(ctx context.Context, instance *compute.Instance, zone string, networkInterface *compute.NetworkInterface, ranges []*compute.AliasIpRange) error {
+	op, err := a.aliasUpdater.UpdateAliasIPRanges(a.project, zone, instance.Name, networkInterface.Name, networkInterface.Fingerprint, ranges)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update alias IP ranges of instance %s", instance.Name)
+	}
+	return a.waitForOperation(ctx, op, zone, defaultTimeout)
+}
+
+// assignAliasIPRange attaches an alias IP range of the pool to the network interface of the instance, along with the
+// alias IP ranges it already has (e.g. the GKE pod range), unless it already holds one
+func (a *gcpAssigner) assignAliasIPRange(ctx context.Context, instanceID, zone string) error {
+	if len(a.aliasIPRanges) == 0 {
+		return nil
+	}
+	// get the instance details for the current network interface fingerprint
+	instance, err := a.instanceGetter.Get(a.project, zone, instanceID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get instance %s", instanceID)
+	}
+	networkInterface, err := getNetworkInterface(instance)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance network interface")
+	}
+	logger := a.logger.WithField("instance", instance.Name)
+	if held := a.heldAliasIPRanges(networkInterface); len(held) > 0 {
+		logger.WithField("range", held[0].IpCidrRange).Debug("alias IP range already assigned to the instance")
+		return nil
+	}
+	// try the ranges in order: Compute Engine rejects the ranges attached to other instances
+	for _, aliasIPRange := range a.aliasIPRanges {
+		ranges := append(append([]*compute.AliasIpRange{}, networkInterface.AliasIpRanges...), aliasIPRange)
+		if err = a.updateAliasIPRanges(ctx, instance, zone, networkInterface, ranges); err != nil {
+			logger.WithError(err).WithField("range", aliasIPRange.IpCidrRange).Debug("failed to assign alias IP range, retrying with another range")
+			// the failed update may have changed the fingerprint
+			if instance, err = a.instanceGetter.Get(a.project, zone, instanceID); err != nil {
+				return errors.Wrapf(err, "failed to get instance %s", instanceID)
+			}
+			if networkInterface, err = getNetworkInterface(instance); err != nil {
+				return errors.Wrap(err, "failed to get instance network interface")
+			}
+			continue
+		}
+		logger.WithField("range", aliasIPRange.IpCidrRange).Info("alias IP range assigned to the instance")
+		return nil
+	}
+	return errors.New("no alias IP range available")
+}
+
+// unassignAliasIPRange detaches the alias IP ranges of the pool from the network interface of the instance, keeping the
+// other alias IP ranges
+func (a *gcpAssigner) unassignAliasIPRange(ctx context.Context, instanceID, zone string) error {
+	if len(a.aliasIPRanges) == 0 {
+		return nil
+	}
+	instance, err := a.instanceGetter.Get(a.project, zone, instanceID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get instance %s", instanceID)
+	}
+	networkInterface, err := getNetworkInterface(instance)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance network interface")
+	}
+	if len(a.heldAliasIPRanges(networkInterface)) == 0 {
+		return nil
+	}
+	ranges := make([]*compute.AliasIpRange, 0, len(networkInterface.AliasIpRanges))
+	for _, r := range networkInterface.AliasIpRanges {
+		pooled := false
+		for _, aliasIPRange := range a.aliasIPRanges {
+			pooled = pooled || (r.IpCidrRange == aliasIPRange.IpCidrRange && r.SubnetworkRangeName == aliasIPRange.SubnetworkRangeName)
+		}
+		if !pooled {
+			ranges = append(ranges, r)
+		}
+	}
+	if err = a.updateAliasIPRanges(ctx, instance, zone, networkInterface, ranges); err != nil {
+		return errors.Wrap(err, "failed to unassign alias IP range")
+	}
+	a.logger.WithField("instance", instance.Name).Info("alias IP range unassigned from the instance")
+	return nil
+}
+
+func (a *gcpAssigner) Candidate(_ context.Context, instanceID, zone string, filter []string, orderBy string) (string, error) {
+	_, address, err := a.checkStaticIPAssigned(zone, instanceID)
+	if errors.Is(err, ErrStaticIPAlreadyAssigned) {
+		return address, nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "check if static public IP is already assigned to instance %s", instanceID)
+	}
+	// the first address is the candidate: the rest of the pages is not fetched
+	var candidate string
+	err = a.walkAddresses(filter, orderBy, reservedStatus, func(address *compute.Address) bool {
+		candidate = address.Address
+		return false
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list available addresses")
+	}
+	if candidate == "" {
+		return "", ErrNoAvailableAddress
+	}
+	return candidate, nil
 }
 
 func (a *gcpAssigner) checkStaticIPAssigned(zone, instanceID string) (*compute.Instance, string, error) {
@@ -277,20 +538,89 @@ func (a *gcpAssigner) checkStaticIPAssigned(zone, instanceID string) (*compute.I
 	if err != nil {
 		return nil, "", errors.Wrapf(err, "failed to get instance %s", instanceID)
 	}
-	assigned, err := a.listAddresses(nil, "", inUseStatus)
+	// look for the instance's self link in the users of the assigned addresses, page by page
+	var address string
+	err = a.walkAddresses(nil, "", inUseStatus, func(assigned *compute.Address) bool {
+		for _, user := range assigned.Users {
+			if user == instance.SelfLink {
+				address = assigned.Address
+				return false
+			}
+		}
+		return true
+	})
 	if err != nil {
 		return nil, "", errors.Wrap(err, "failed to list assigned addresses")
 	}
-	// create a map of users for quick lookup
-	users := a.createUserMap(assigned)
-	// check if the instance's self link is in the list of users
-	if address, ok := users[instance.SelfLink]; ok {
+	if address != "" {
 		return nil, address, ErrStaticIPAlreadyAssigned
 	}
 	return instance, "", nil
 }
 
+func (a *gcpAssigner) InPool(_ context.Context, address string, filter []string) (bool, error) {
+	var found bool
+	err := a.walkAddresses(filter, "", inUseStatus, func(assigned *compute.Address) bool {
+		found = assigned.Address == address
+		return !found
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list assigned addresses")
+	}
+	return found, nil
+}
+
+// Quota returns the static external addresses quota of the region
+func (a *gcpAssigner) Quota(ctx context.Context) (*Quota, error) {
+	limit, usage, err := a.quotaGetter.Quota(ctx, a.project, a.region, cloud.StaticAddressesQuota)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return &Quota{Name: cloud.StaticAddressesQuota, Limit: limit, Usage: usage}, nil
+}
+
+// Claim assigns the static address of the pool with the IP address: the pool filter narrowed to the address
+func (a *gcpAssigner) Claim(ctx context.Context, instanceID, zone, address string, filter []string) (string, error) {
+	return a.Assign(ctx, instanceID, zone, append(append([]string{}, filter...), fmt.Sprintf("address = %q", address)), "")
+}
+
 func (a *gcpAssigner) listAddresses(filter []string, orderBy, status string) ([]*compute.Address, error) {
+	var addresses []*compute.Address
+	err := a.walkAddresses(filter, orderBy, status, func(address *compute.Address) bool {
+		addresses = append(addresses, address)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// walkAddresses calls fn with the addresses matching the filter, combined with the filter logic, each address once;
+// stops listing once fn returns false
+func (a *gcpAssigner) walkAddresses(filter []string, orderBy, status string, fn func(*compute.Address) bool) error {
+	seen := make(map[string]bool)
+	for _, group := range filterGroups(filter, a.filterLogic) {
+		stopped := false
+		err := a.walkFilter(group, orderBy, status, func(address *compute.Address) bool {
+			// the reserved addresses are the candidates: those not matching the name pattern are skipped
+			if seen[address.Name] || (status == reservedStatus && !nameMatches(a.nameRegex, address.Name)) {
+				return true
+			}
+			seen[address.Name] = true
+			stopped = !fn(address)
+			return !stopped
+		})
+		if err != nil || stopped {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkFilter calls fn with the addresses matching all the filters page by page, following the page tokens up to
+// cloud.MaxListPages; stops fetching pages once fn returns false
+func (a *gcpAssigner) walkFilter(filter []string, orderBy, status string, fn func(*compute.Address) bool) error {
 	call := a.lister.List(a.project, a.region)
 	// Initialize filters with known filters
 	filters := []string{
@@ -313,22 +643,32 @@ func (a *gcpAssigner) listAddresses(filter []string, orderBy, status string) ([]
 	if orderBy != "" {
 		call = call.OrderBy(orderBy)
 	}
-	// get all addresses
-	var addresses []*compute.Address
-	for {
+	for page := 1; ; page++ {
 		list, err := call.Do()
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to list available addresses")
+			return errors.Wrap(err, "failed to list available addresses")
+		}
+		metrics.ObserveListPage(string(types.CloudProviderGCP))
+		for _, address := range list.Items {
+			if !fn(address) {
+				return nil
+			}
 		}
-		addresses = append(addresses, list.Items...)
 		if list.NextPageToken == "" {
-			return addresses, nil
+			return nil
+		}
+		if page == cloud.MaxListPages {
+			return errors.Errorf("more than %d pages of addresses", cloud.MaxListPages)
 		}
 		call = call.PageToken(list.NextPageToken)
 	}
 }
 
 func (a *gcpAssigner) Unassign(ctx context.Context, instanceID, zone string) error {
+	// detach the alias IP range first: the instance keeps it with or without a static public IP address
+	if err := a.unassignAliasIPRange(ctx, instanceID, zone); err != nil {
+		return err
+	}
 	// get the instance details
 	instance, err := a.instanceGetter.Get(a.project, zone, instanceID)
 	if err != nil {
@@ -348,10 +688,16 @@ func (a *gcpAssigner) Unassign(ctx context.Context, instanceID, zone string) err
 
 	// check if the instance's self link is in the list of users
 	if _, ok := users[instance.SelfLink]; ok {
+		private := a.isPrivate(instance)
 		// release/remove current static public IP address
 		if err = a.DeleteInstanceAddress(ctx, instance, zone); err != nil {
 			return errors.Wrap(err, "failed to delete current public IP address")
 		}
+		a.labelOwner(heldAddress(assigned, instance.SelfLink), "")
+		// a private node goes back to no external access config
+		if private {
+			return nil
+		}
 		// get instance details again to refresh the network interface fingerprint (required for adding a new ipv6 address)
 		instance, err = a.instanceGetter.Get(a.project, zone, instanceID)
 		if err != nil {
@@ -365,6 +711,38 @@ func (a *gcpAssigner) Unassign(ctx context.Context, instanceID, zone string) err
 	return nil
 }
 
+// isPrivate reports whether the instance is a private node: no external access config, or the one added by kubeip to a
+// private node
+func (a *gcpAssigner) isPrivate(instance *compute.Instance) bool {
+	networkInterface, err := getNetworkInterface(instance)
+	if err != nil {
+		return false
+	}
+	accessConfig, err := getAccessConfig(networkInterface, a.ipv6)
+	if errors.Is(err, ErrNoPublicIPAssigned) {
+		return true
+	}
+	return err == nil && (accessConfig.Name == privateNetworkName || accessConfig.Name == privateNetworkNameIPv6)
+}
+
+// privateNodeAssigner adds the access config of a private node under the private node name, telling it apart on release
+type privateNodeAssigner struct {
+	*gcpAssigner
+}
+
+func (p *privateNodeAssigner) AddInstanceAddress(ctx context.Context, instance *compute.Instance, zone string, address *compute.Address) error {
+	networkInterface, err := getNetworkInterface(instance)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instance network interface")
+	}
+	accessConfig := createAccessConfig(address, p.ipv6)
+	accessConfig.Name = privateNetworkName
+	if p.ipv6 {
+		accessConfig.Name = privateNetworkNameIPv6
+	}
+	return p.addAccessConfig(ctx, instance, networkInterface, zone, accessConfig)
+}
+
 func getAccessConfig(networkInterface *compute.NetworkInterface, ipv6 bool) (*compute.AccessConfig, error) {
 	if ipv6 {
 		if len(networkInterface.Ipv6AccessConfigs) == 0 {
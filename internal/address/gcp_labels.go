@@ -0,0 +1,96 @@
+package address
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// labels of the static addresses marking the instance holding them, written with --gcp-address-labels
+const (
+	ownerNodeLabel  = "kubeip-node"
+	assignedAtLabel = "kubeip-assigned-at"
+	// labelAttempts is the number of writes of the labels of an address conflicting with concurrent writes
+	labelAttempts = 3
+)
+
+// ownerLabels returns the label changes marking the address held by the instance since the time, or released (empty
+// values) without instance
+func ownerLabels(instance string, since time.Time) map[string]string {
+	if instance == "" {
+		return map[string]string{ownerNodeLabel: "", assignedAtLabel: ""}
+	}
+	return map[string]string{ownerNodeLabel: instance, assignedAtLabel: strconv.FormatInt(since.Unix(), 10)}
+}
+
+// mergeLabels returns the labels with the changes applied, an empty value removing the label, and whether they changed
+func mergeLabels(labels, changes map[string]string) (map[string]string, bool) {
+	merged := make(map[string]string, len(labels)+len(changes))
+	for key, value := range labels {
+		merged[key] = value
+	}
+	changed := false
+	for key, value := range changes {
+		current, ok := merged[key]
+		switch {
+		case value == "" && ok:
+			delete(merged, key)
+			changed = true
+		case value != "" && current != value:
+			merged[key] = value
+			changed = true
+		}
+	}
+	return merged, changed
+}
+
+// updateAddressLabels writes all the label changes of the address in one request, skipped if the labels hold them
+// already, keeping the labels set by others; the write is guarded by the label fingerprint: on a conflict with another
+// agent (412 Precondition Failed), the address is read again and the changes applied to its current labels, up to
+// labelAttempts times. The region operation of the write is not waited for: the labels do not gate the assignment
+func (a *gcpAssigner) updateAddressLabels(address *compute.Address, changes map[string]string) error {
+	name := address.Name
+	for attempt := 1; ; attempt++ {
+		labels, changed := mergeLabels(address.Labels, changes)
+		if !changed {
+			return nil
+		}
+		_, err := a.addressManager.SetLabels(a.project, a.region, name, address.LabelFingerprint, labels)
+		if err == nil {
+			return nil
+		}
+		var apiErr *googleapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusPreconditionFailed || attempt == labelAttempts {
+			return errors.Wrapf(err, "failed to update labels of address %s", name)
+		}
+		if address, err = a.addressManager.GetAddress(a.project, a.region, name); err != nil {
+			return errors.Wrapf(err, "failed to get address %s", name)
+		}
+	}
+}
+
+// heldAddress returns the address of the addresses used by the instance, nil if none
+func heldAddress(addresses []*compute.Address, selfLink string) *compute.Address {
+	for _, address := range addresses {
+		for _, user := range address.Users {
+			if user == selfLink {
+				return address
+			}
+		}
+	}
+	return nil
+}
+
+// labelOwner marks the address held by the instance, or released without instance; failures are logged only
+func (a *gcpAssigner) labelOwner(address *compute.Address, instance string) {
+	if !a.addressLabels || address == nil {
+		return
+	}
+	if err := a.updateAddressLabels(address, ownerLabels(instance, time.Now())); err != nil {
+		a.logger.WithError(err).WithField("address", address.Address).Warn("failed to update the owner labels of the static public IP address")
+	}
+}
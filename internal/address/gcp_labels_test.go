@@ -0,0 +1,48 @@
+package address
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	mocks "github.com/doitintl/kubeip/mocks/cloud"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func Test_gcpAssigner_updateAddressLabels(t *testing.T) {
+	manager := mocks.NewAddressManager(t)
+	a := &gcpAssigner{addressManager: manager, project: "project", region: "region", logger: logrus.NewEntry(logrus.New())}
+	changes := ownerLabels("node-1", time.Unix(1700000000, 0))
+	address := &compute.Address{Name: "static-1", LabelFingerprint: "f1", Labels: map[string]string{"env": "prod"}}
+
+	// another agent wrote the labels first: the address is read again and the changes applied to its labels
+	conflict := &googleapi.Error{Code: http.StatusPreconditionFailed}
+	manager.EXPECT().SetLabels("project", "region", "static-1", "f1",
+		map[string]string{"env": "prod", ownerNodeLabel: "node-1", assignedAtLabel: "1700000000"}).Return(nil, conflict).Once()
+	manager.EXPECT().GetAddress("project", "region", "static-1").
+		Return(&compute.Address{Name: "static-1", LabelFingerprint: "f2", Labels: map[string]string{"env": "prod", "team": "net"}}, nil).Once()
+	manager.EXPECT().SetLabels("project", "region", "static-1", "f2",
+		map[string]string{"env": "prod", "team": "net", ownerNodeLabel: "node-1", assignedAtLabel: "1700000000"}).Return(&compute.Operation{}, nil).Once()
+	if err := a.updateAddressLabels(address, changes); err != nil {
+		t.Fatalf("updateAddressLabels() error = %v", err)
+	}
+
+	// the labels hold the changes already: nothing is written
+	address.Labels = map[string]string{"env": "prod", ownerNodeLabel: "node-1", assignedAtLabel: "1700000000"}
+	if err := a.updateAddressLabels(address, changes); err != nil {
+		t.Fatalf("updateAddressLabels() error = %v", err)
+	}
+
+	// the conflicts go on: the write gives up after labelAttempts writes
+	manager.EXPECT().SetLabels("project", "region", "static-1", "f1", map[string]string{"env": "prod"}).Return(nil, conflict).Times(labelAttempts)
+	manager.EXPECT().GetAddress("project", "region", "static-1").Return(address, nil).Times(labelAttempts - 1)
+	address.LabelFingerprint = "f1"
+	err := a.updateAddressLabels(address, ownerLabels("", time.Time{}))
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusPreconditionFailed {
+		t.Errorf("updateAddressLabels() error = %v, want the conflict", err)
+	}
+}
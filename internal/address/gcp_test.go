@@ -103,6 +103,31 @@ func Test_gcpAssigner_listAddresses(t *testing.T) {
 				{Name: "test-address-4", Status: "RESERVED", Address: "10.10.0.4", NetworkTier: "PREMIUM", AddressType: "EXTERNAL"},
 			},
 		},
+		{
+			name: "list addresses exceeding the page cap",
+			fields: fields{
+				project: "test-project",
+				region:  "test-region",
+				listerFn: func(t *testing.T) cloud.Lister {
+					mock := mocks.NewLister(t)
+					mockCall := mocks.NewListCall(t)
+					mock.EXPECT().List("test-project", "test-region").Return(mockCall)
+					mockCall.EXPECT().Filter("(status=RESERVED) (addressType=EXTERNAL) (ipVersion!=IPV6)").Return(mockCall)
+					mockCall.EXPECT().Do().Return(&compute.AddressList{
+						Items: []*compute.Address{
+							{Name: "test-address-1", Status: "RESERVED", Address: "10.10.0.1", NetworkTier: "PREMIUM", AddressType: "EXTERNAL"},
+						},
+						NextPageToken: "test-next-page-token",
+					}, nil).Times(cloud.MaxListPages)
+					mockCall.EXPECT().PageToken("test-next-page-token").Return(mockCall).Times(cloud.MaxListPages - 1)
+					return mock
+				},
+			},
+			args: args{
+				status: "RESERVED",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		logger := logrus.NewEntry(logrus.New())
@@ -125,6 +150,87 @@ func Test_gcpAssigner_listAddresses(t *testing.T) {
 	}
 }
 
+func Test_gcpAssigner_InPool(t *testing.T) {
+	mock := mocks.NewLister(t)
+	mockCall := mocks.NewListCall(t)
+	mock.EXPECT().List("test-project", "test-region").Return(mockCall)
+	mockCall.EXPECT().Filter("(status=IN_USE) (addressType=EXTERNAL) (ipVersion!=IPV6) (labels.kubeip=reserved)").Return(mockCall)
+	// the address is on the first page: the next page is not fetched
+	mockCall.EXPECT().Do().Return(&compute.AddressList{
+		Items: []*compute.Address{
+			{Name: "test-address-1", Status: "IN_USE", Address: "10.10.0.1"},
+			{Name: "test-address-2", Status: "IN_USE", Address: "10.10.0.2"},
+		},
+		NextPageToken: "test-next-page-token",
+	}, nil).Once()
+	a := &gcpAssigner{lister: mock, project: "test-project", region: "test-region", logger: logrus.NewEntry(logrus.New())}
+	found, err := a.InPool(context.Background(), "10.10.0.1", []string{"labels.kubeip=reserved"})
+	if err != nil {
+		t.Fatalf("InPool() error = %v", err)
+	}
+	if !found {
+		t.Errorf("InPool() = false, want true")
+	}
+}
+
+func Test_gcpAssigner_listAddresses_or(t *testing.T) {
+	mock := mocks.NewLister(t)
+	projectCall := mocks.NewListCall(t)
+	envCall := mocks.NewListCall(t)
+	// the filters list one after the other, the address matching both once
+	mock.EXPECT().List("test-project", "test-region").Return(projectCall).Once()
+	mock.EXPECT().List("test-project", "test-region").Return(envCall).Once()
+	projectCall.EXPECT().Filter("(status=RESERVED) (addressType=EXTERNAL) (ipVersion!=IPV6) (labels.project=shop)").Return(projectCall)
+	projectCall.EXPECT().OrderBy("name").Return(projectCall)
+	projectCall.EXPECT().Do().Return(&compute.AddressList{
+		Items: []*compute.Address{{Name: "test-address-2", Address: "10.10.0.2"}},
+	}, nil)
+	envCall.EXPECT().Filter("(status=RESERVED) (addressType=EXTERNAL) (ipVersion!=IPV6) (labels.env=prod)").Return(envCall)
+	envCall.EXPECT().OrderBy("name").Return(envCall)
+	envCall.EXPECT().Do().Return(&compute.AddressList{
+		Items: []*compute.Address{{Name: "test-address-1", Address: "10.10.0.1"}, {Name: "test-address-2", Address: "10.10.0.2"}},
+	}, nil)
+	a := &gcpAssigner{lister: mock, project: "test-project", region: "test-region", filterLogic: FilterLogicOr, logger: logrus.NewEntry(logrus.New())}
+	got, err := a.listAddresses([]string{"labels.project=shop", "labels.env=prod"}, "name", reservedStatus)
+	if err != nil {
+		t.Fatalf("listAddresses() error = %v", err)
+	}
+	want := []*compute.Address{{Name: "test-address-2", Address: "10.10.0.2"}, {Name: "test-address-1", Address: "10.10.0.1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listAddresses() = %v, want %v", got, want)
+	}
+}
+
+func Test_gcpAssigner_listAddresses_nameRegex(t *testing.T) {
+	mock := mocks.NewLister(t)
+	mockCall := mocks.NewListCall(t)
+	mock.EXPECT().List("test-project", "test-region").Return(mockCall)
+	mockCall.EXPECT().Filter("(status=RESERVED) (addressType=EXTERNAL) (ipVersion!=IPV6) (labels.env=prod)").Return(mockCall)
+	mockCall.EXPECT().Do().Return(&compute.AddressList{
+		Items: []*compute.Address{
+			{Name: "egress-prod-1", Address: "10.10.0.1"},
+			{Name: "egress-prod-test", Address: "10.10.0.2"},
+			{Name: "egress-prod-2", Address: "10.10.0.3"},
+		},
+	}, nil)
+	nameRegex, err := compileNameRegex(`^egress-prod-\d+$`)
+	if err != nil {
+		t.Fatalf("compileNameRegex() error = %v", err)
+	}
+	a := &gcpAssigner{lister: mock, project: "test-project", region: "test-region", nameRegex: nameRegex, logger: logrus.NewEntry(logrus.New())}
+	got, err := a.listAddresses([]string{"labels.env=prod"}, "", reservedStatus)
+	if err != nil {
+		t.Fatalf("listAddresses() error = %v", err)
+	}
+	want := []*compute.Address{{Name: "egress-prod-1", Address: "10.10.0.1"}, {Name: "egress-prod-2", Address: "10.10.0.3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listAddresses() = %v, want %v", got, want)
+	}
+	if _, err = compileNameRegex("egress-("); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("compileNameRegex() error = %v, want %v", err, ErrInvalidFilter)
+	}
+}
+
 func Test_gcpAssigner_waitForOperation(t *testing.T) {
 	type fields struct {
 		waiterFn func(t *testing.T) cloud.ZoneWaiter
@@ -330,6 +436,7 @@ func Test_gcpAssigner_Assign(t *testing.T) {
 		project          string
 		region           string
 		address          string
+		privateNodes     string
 	}
 	type args struct {
 		ctx        context.Context
@@ -458,6 +565,97 @@ func Test_gcpAssigner_Assign(t *testing.T) {
 				orderBy:    "test-order-by",
 			},
 		},
+		{
+			name: "add access config to private node",
+			fields: fields{
+				project:      "test-project",
+				region:       "test-region",
+				address:      "100.0.0.3",
+				privateNodes: PrivateNodesAdd,
+				listerFn: func(t *testing.T) cloud.Lister {
+					mock := mocks.NewLister(t)
+					mockCall := mocks.NewListCall(t)
+					mock.EXPECT().List("test-project", "test-region").Return(mockCall)
+					mockCall.EXPECT().Filter("(status=IN_USE) (addressType=EXTERNAL) (ipVersion!=IPV6)").Return(mockCall).Once()
+					mockCall.EXPECT().Do().Return(&compute.AddressList{}, nil).Once()
+					mockCall.EXPECT().Filter("(status=RESERVED) (addressType=EXTERNAL) (ipVersion!=IPV6) (test-filter-1)").Return(mockCall).Once()
+					mockCall.EXPECT().OrderBy("test-order-by").Return(mockCall).Once()
+					mockCall.EXPECT().Do().Return(&compute.AddressList{
+						Items: []*compute.Address{
+							{Name: "test-address-3", Status: reservedStatus, Address: "100.0.0.3", NetworkTier: defaultNetworkTier, AddressType: "EXTERNAL"},
+						},
+					}, nil).Once()
+					return mock
+				},
+				instanceGetterFn: func(t *testing.T) cloud.InstanceGetter {
+					mock := mocks.NewInstanceGetter(t)
+					mock.EXPECT().Get("test-project", "test-zone", "test-instance-0").Return(&compute.Instance{
+						Name: "test-instance-0",
+						Zone: "test-zone",
+						NetworkInterfaces: []*compute.NetworkInterface{
+							{Name: "test-network-interface", Fingerprint: "test-fingerprint"},
+						},
+					}, nil)
+					return mock
+				},
+				addressManagerFn: func(t *testing.T) cloud.AddressManager {
+					mock := mocks.NewAddressManager(t)
+					mock.EXPECT().AddAccessConfig("test-project", "test-zone", "test-instance-0", "test-network-interface", "test-fingerprint", &compute.AccessConfig{
+						Name:  privateNetworkName,
+						Type:  defaultAccessConfigType,
+						Kind:  accessConfigKind,
+						NatIP: "100.0.0.3",
+					}).Return(&compute.Operation{Name: "test-operation", Status: "DONE"}, nil)
+					mock.EXPECT().GetAddress("test-project", "test-region", "test-address-3").Return(&compute.Address{Name: "test-address-3", Status: reservedStatus}, nil)
+					return mock
+				},
+			},
+			args: args{
+				ctx:        context.TODO(),
+				instanceID: "test-instance-0",
+				zone:       "test-zone",
+				filter:     []string{"test-filter-1"},
+				orderBy:    "test-order-by",
+			},
+		},
+		{
+			name: "refuse private node",
+			fields: fields{
+				project:      "test-project",
+				region:       "test-region",
+				privateNodes: PrivateNodesRefuse,
+				listerFn: func(t *testing.T) cloud.Lister {
+					mock := mocks.NewLister(t)
+					mockCall := mocks.NewListCall(t)
+					mock.EXPECT().List("test-project", "test-region").Return(mockCall)
+					mockCall.EXPECT().Filter("(status=IN_USE) (addressType=EXTERNAL) (ipVersion!=IPV6)").Return(mockCall).Once()
+					mockCall.EXPECT().Do().Return(&compute.AddressList{}, nil).Once()
+					return mock
+				},
+				instanceGetterFn: func(t *testing.T) cloud.InstanceGetter {
+					mock := mocks.NewInstanceGetter(t)
+					mock.EXPECT().Get("test-project", "test-zone", "test-instance-0").Return(&compute.Instance{
+						Name: "test-instance-0",
+						Zone: "test-zone",
+						NetworkInterfaces: []*compute.NetworkInterface{
+							{Name: "test-network-interface", Fingerprint: "test-fingerprint"},
+						},
+					}, nil)
+					return mock
+				},
+				addressManagerFn: func(t *testing.T) cloud.AddressManager {
+					return mocks.NewAddressManager(t)
+				},
+			},
+			args: args{
+				ctx:        context.TODO(),
+				instanceID: "test-instance-0",
+				zone:       "test-zone",
+				filter:     []string{"test-filter-1"},
+				orderBy:    "test-order-by",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -468,6 +666,7 @@ func Test_gcpAssigner_Assign(t *testing.T) {
 				instanceGetter: tt.fields.instanceGetterFn(t),
 				project:        tt.fields.project,
 				region:         tt.fields.region,
+				privateNodes:   tt.fields.privateNodes,
 				logger:         logger,
 			}
 			address, err := a.Assign(tt.args.ctx, tt.args.instanceID, tt.args.zone, tt.args.filter, tt.args.orderBy)
@@ -814,3 +1013,87 @@ func Test_tryAssignAddress(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseAliasIPRanges(t *testing.T) {
+	ranges, err := parseAliasIPRanges([]string{"10.0.1.5/32", "services:10.4.0.1/28"})
+	if err != nil {
+		t.Fatalf("parseAliasIPRanges() error = %v", err)
+	}
+	want := []*compute.AliasIpRange{
+		{IpCidrRange: "10.0.1.5/32"},
+		{IpCidrRange: "10.4.0.0/28", SubnetworkRangeName: "services"},
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("parseAliasIPRanges() = %v, want %v", ranges, want)
+	}
+	for _, entry := range []string{"10.0.1.5", "services:2600:1900::/96", "services:"} {
+		if _, err = parseAliasIPRanges([]string{entry}); err == nil {
+			t.Errorf("parseAliasIPRanges(%s) expected error", entry)
+		}
+	}
+}
+
+func Test_gcpAssigner_assignAliasIPRange(t *testing.T) {
+	ctx := context.Background()
+	podRange := &compute.AliasIpRange{IpCidrRange: "10.8.0.0/24", SubnetworkRangeName: "pods"}
+	pool := []*compute.AliasIpRange{{IpCidrRange: "10.0.1.5/32"}, {IpCidrRange: "10.0.1.6/32"}}
+	instance := func(fingerprint string, ranges ...*compute.AliasIpRange) *compute.Instance {
+		return &compute.Instance{Name: "node-1", NetworkInterfaces: []*compute.NetworkInterface{
+			{Name: "nic0", Fingerprint: fingerprint, AliasIpRanges: ranges},
+		}}
+	}
+	done := &compute.Operation{Name: "op", Status: operationDone}
+
+	// the first range is attached to another instance, the GKE pod range is kept
+	instanceGetter := mocks.NewInstanceGetter(t)
+	instanceGetter.EXPECT().Get("project", "zone", "node-1").Return(instance("f1", podRange), nil).Once()
+	instanceGetter.EXPECT().Get("project", "zone", "node-1").Return(instance("f2", podRange), nil).Once()
+	updater := mocks.NewAliasIPRangeUpdater(t)
+	updater.EXPECT().UpdateAliasIPRanges("project", "zone", "node-1", "nic0", "f1", []*compute.AliasIpRange{podRange, pool[0]}).
+		Return(nil, errors.New("range in use")).Once()
+	updater.EXPECT().UpdateAliasIPRanges("project", "zone", "node-1", "nic0", "f2", []*compute.AliasIpRange{podRange, pool[1]}).
+		Return(done, nil).Once()
+	a := &gcpAssigner{
+		instanceGetter: instanceGetter,
+		aliasUpdater:   updater,
+		aliasIPRanges:  pool,
+		project:        "project",
+		logger:         logrus.NewEntry(logrus.New()),
+	}
+	if err := a.assignAliasIPRange(ctx, "node-1", "zone"); err != nil {
+		t.Errorf("assignAliasIPRange() error = %v", err)
+	}
+
+	// the instance keeps the range it holds
+	instanceGetter.EXPECT().Get("project", "zone", "node-1").Return(instance("f3", podRange, pool[1]), nil).Once()
+	if err := a.assignAliasIPRange(ctx, "node-1", "zone"); err != nil {
+		t.Errorf("assignAliasIPRange() error = %v", err)
+	}
+
+	// released with the static public IP, the GKE pod range is kept
+	instanceGetter.EXPECT().Get("project", "zone", "node-1").Return(instance("f3", podRange, pool[1]), nil).Once()
+	updater.EXPECT().UpdateAliasIPRanges("project", "zone", "node-1", "nic0", "f3", []*compute.AliasIpRange{podRange}).
+		Return(done, nil).Once()
+	if err := a.unassignAliasIPRange(ctx, "node-1", "zone"); err != nil {
+		t.Errorf("unassignAliasIPRange() error = %v", err)
+	}
+}
+
+func Test_gcpAssigner_Quota(t *testing.T) {
+	ctx := context.Background()
+	getter := mocks.NewRegionQuotaGetter(t)
+	getter.EXPECT().Quota(ctx, "project", "region", cloud.StaticAddressesQuota).Return(8, 6, nil).Once()
+	a := &gcpAssigner{quotaGetter: getter, project: "project", region: "region"}
+	quota, err := a.Quota(ctx)
+	if err != nil {
+		t.Fatalf("Quota() error = %v", err)
+	}
+	if quota.Name != cloud.StaticAddressesQuota || quota.Remaining() != 2 {
+		t.Errorf("Quota() = %+v, want 2 %s left", quota, cloud.StaticAddressesQuota)
+	}
+
+	getter.EXPECT().Quota(ctx, "project", "region", cloud.StaticAddressesQuota).Return(0, 0, errors.New("forbidden")).Once()
+	if _, err = a.Quota(ctx); err == nil {
+		t.Error("Quota() error = nil, want the quota getter error")
+	}
+}
@@ -0,0 +1,290 @@
+package address
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	metalLBHostnameLabel    = "kubernetes.io/hostname"
+	metalLBPrefix           = "kubeip-"
+	defaultMetalLBNamespace = "metallb-system"
+	// maxMetalLBAddresses limits the expansion of the configured CIDRs
+	maxMetalLBAddresses = 4096
+)
+
+var (
+	// IPAddressPoolResource is the MetalLB IPAddressPool custom resource
+	IPAddressPoolResource = schema.GroupVersionResource{Group: "metallb.io", Version: "v1beta1", Resource: "ipaddresspools"}
+	// L2AdvertisementResource is the MetalLB L2Advertisement custom resource
+	L2AdvertisementResource = schema.GroupVersionResource{Group: "metallb.io", Version: "v1beta1", Resource: "l2advertisements"}
+)
+
+type metalLBAssigner struct {
+	client    dynamic.Interface
+	namespace string
+	addresses []net.IP
+	logger    *logrus.Entry
+}
+
+// NewMetalLBAssigner returns an assigner for bare metal clusters: an address is claimed from the configured addresses by
+// creating a single address MetalLB IPAddressPool and announced from the node with a L2Advertisement scoped to the node
+func NewMetalLBAssigner(logger *logrus.Entry, client dynamic.Interface, cfg *config.Config) (Assigner, error) {
+	if client == nil {
+		return nil, errors.New("kubernetes dynamic client is required for MetalLB")
+	}
+	addresses, err := ExpandAddresses(cfg.MetalLBAddresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse MetalLB addresses")
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("MetalLB addresses are required")
+	}
+	namespace := cfg.MetalLBNamespace
+	if namespace == "" {
+		namespace = defaultMetalLBNamespace
+	}
+	return &metalLBAssigner{
+		client:    client,
+		namespace: namespace,
+		addresses: addresses,
+		logger:    logger,
+	}, nil
+}
+
+// ExpandAddresses returns the addresses of the entries: single IP addresses or CIDRs
+func ExpandAddresses(entries []string) ([]net.IP, error) {
+	var addresses []net.IP
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP address: %s", entry)
+			}
+			addresses = append(addresses, ip)
+			continue
+		}
+		ip, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid CIDR: %s", entry)
+		}
+		for ip = ip.Mask(ipNet.Mask); ipNet.Contains(ip); ip = nextIP(ip) {
+			if len(addresses) == maxMetalLBAddresses {
+				return nil, errors.Errorf("more than %d addresses", maxMetalLBAddresses)
+			}
+			addresses = append(addresses, ip)
+		}
+	}
+	return addresses, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// metalLBName returns the name of the IPAddressPool and L2Advertisement of the address;
+// naming by address makes the pool creation the claim: concurrent agents fail with already exists
+func metalLBName(address string) string {
+	return metalLBPrefix + strings.NewReplacer(".", "-", ":", "-").Replace(address)
+}
+
+func hostCIDR(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+func (a *metalLBAssigner) object(kind, name, nodeName, address string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": IPAddressPoolResource.GroupVersion().String(),
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": a.namespace,
+			"labels": map[string]interface{}{
+				managedByLabel: managedByValue,
+				nodeLabel:      nodeName,
+				addressLabel:   strings.ReplaceAll(address, ":", "-"),
+			},
+		},
+		"spec": spec,
+	}}
+}
+
+// claimed returns the addresses claimed by all nodes and the address claimed by the node
+func (a *metalLBAssigner) claimed(ctx context.Context, nodeName string) (map[string]bool, string, error) {
+	pools, err := a.client.Resource(IPAddressPoolResource).Namespace(a.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: managedByLabel + "=" + managedByValue,
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to list MetalLB IP address pools")
+	}
+	claimed := make(map[string]bool)
+	var nodeAddress string
+	for _, pool := range pools.Items {
+		cidrs, _, _ := unstructured.NestedStringSlice(pool.Object, "spec", "addresses")
+		for _, cidr := range cidrs {
+			ip, _, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			claimed[ip.String()] = true
+			if pool.GetLabels()[nodeLabel] == nodeName {
+				nodeAddress = ip.String()
+			}
+		}
+	}
+	return claimed, nodeAddress, nil
+}
+
+func (a *metalLBAssigner) Assign(ctx context.Context, instanceID, _ string, _ []string, _ string) (string, error) {
+	claimed, nodeAddress, err := a.claimed(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if nodeAddress != "" {
+		a.logger.WithFields(logrus.Fields{
+			"node":    instanceID,
+			"address": nodeAddress,
+		}).Info("MetalLB address already claimed by the node")
+		return nodeAddress, nil
+	}
+
+	for _, ip := range a.addresses {
+		address := ip.String()
+		if claimed[address] {
+			continue
+		}
+		name := metalLBName(address)
+		pool := a.object("IPAddressPool", name, instanceID, address, map[string]interface{}{
+			"addresses":  []interface{}{hostCIDR(ip)},
+			"autoAssign": false,
+		})
+		_, err = a.client.Resource(IPAddressPoolResource).Namespace(a.namespace).Create(ctx, pool, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// claimed concurrently by another agent
+			a.logger.WithField("address", address).Debug("MetalLB address claimed by another node, retrying with another address")
+			continue
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create MetalLB IP address pool %s", name)
+		}
+
+		advertisement := a.object("L2Advertisement", name, instanceID, address, map[string]interface{}{
+			"ipAddressPools": []interface{}{name},
+			"nodeSelectors": []interface{}{
+				map[string]interface{}{
+					"matchLabels": map[string]interface{}{metalLBHostnameLabel: instanceID},
+				},
+			},
+		})
+		_, err = a.client.Resource(L2AdvertisementResource).Namespace(a.namespace).Create(ctx, advertisement, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			// release the claim: the address is not announced
+			_ = a.client.Resource(IPAddressPoolResource).Namespace(a.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+			return "", errors.Wrapf(err, "failed to create MetalLB L2 advertisement %s", name)
+		}
+		a.logger.WithFields(logrus.Fields{
+			"node":    instanceID,
+			"address": address,
+			"pool":    name,
+		}).Info("MetalLB address claimed by the node")
+		return address, nil
+	}
+	return "", errors.New("no available MetalLB address")
+}
+
+func (a *metalLBAssigner) Candidate(ctx context.Context, instanceID, _ string, _ []string, _ string) (string, error) {
+	claimed, nodeAddress, err := a.claimed(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	if nodeAddress != "" {
+		return nodeAddress, nil
+	}
+	for _, ip := range a.addresses {
+		if !claimed[ip.String()] {
+			return ip.String(), nil
+		}
+	}
+	return "", ErrNoAvailableAddress
+}
+
+// Announced checks if the address is announced from the node: the IP address pool of the address is claimed by the node
+// and the L2 advertisement of the pool selects the node
+func (a *metalLBAssigner) Announced(ctx context.Context, instanceID, address string) (bool, error) {
+	name := metalLBName(address)
+	pool, err := a.client.Resource(IPAddressPoolResource).Namespace(a.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get MetalLB IP address pool %s", name)
+	}
+	if pool.GetLabels()[nodeLabel] != instanceID {
+		return false, nil
+	}
+	advertisement, err := a.client.Resource(L2AdvertisementResource).Namespace(a.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get MetalLB L2 advertisement %s", name)
+	}
+	selectors, _, _ := unstructured.NestedSlice(advertisement.Object, "spec", "nodeSelectors") //nolint:errcheck
+	for _, selector := range selectors {
+		s, ok := selector.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		labels, _, _ := unstructured.NestedStringMap(s, "matchLabels") //nolint:errcheck
+		if labels[metalLBHostnameLabel] == instanceID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a *metalLBAssigner) Unassign(ctx context.Context, instanceID, _ string) error {
+	_, nodeAddress, err := a.claimed(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	if nodeAddress == "" {
+		return ErrNoStaticIPAssigned
+	}
+	name := metalLBName(nodeAddress)
+	err = a.client.Resource(L2AdvertisementResource).Namespace(a.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete MetalLB L2 advertisement %s", name)
+	}
+	err = a.client.Resource(IPAddressPoolResource).Namespace(a.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete MetalLB IP address pool %s", name)
+	}
+	a.logger.WithFields(logrus.Fields{
+		"node":    instanceID,
+		"address": nodeAddress,
+	}).Info("MetalLB address released by the node")
+	return nil
+}
@@ -0,0 +1,192 @@
+package address
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestMetalLB(t *testing.T, addresses ...string) (Assigner, *dynamicfake.FakeDynamicClient) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		IPAddressPoolResource:   "IPAddressPoolList",
+		L2AdvertisementResource: "L2AdvertisementList",
+	})
+	a, err := NewMetalLBAssigner(logrus.NewEntry(logrus.New()), client, &config.Config{MetalLBAddresses: addresses})
+	require.NoError(t, err)
+	return a, client
+}
+
+func TestExpandAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+		wantErr bool
+	}{
+		{"single addresses", []string{"192.0.2.10", "2001:db8::1"}, []string{"192.0.2.10", "2001:db8::1"}, false},
+		{"CIDR", []string{"192.0.2.254/31", "192.0.2.1"}, []string{"192.0.2.254", "192.0.2.255", "192.0.2.1"}, false},
+		{"invalid address", []string{"192.0.2"}, nil, true},
+		{"invalid CIDR", []string{"192.0.2.0/33"}, nil, true},
+		{"too many addresses", []string{"10.0.0.0/8"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandAddresses(tt.entries)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var addresses []string
+			for _, ip := range got {
+				addresses = append(addresses, ip.String())
+			}
+			assert.Equal(t, tt.want, addresses)
+		})
+	}
+}
+
+func Test_nextIP(t *testing.T) {
+	assert.Equal(t, "192.0.3.0", nextIP(net.ParseIP("192.0.2.255").To4()).String())
+}
+
+func TestMetalLBAssigner(t *testing.T) {
+	a, client := newTestMetalLB(t, "192.0.2.10", "192.0.2.11")
+	ctx := context.Background()
+
+	// the candidate is not claimed
+	address, err := a.Candidate(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", address)
+	_, err = client.Resource(IPAddressPoolResource).Namespace(defaultMetalLBNamespace).Get(ctx, "kubeip-192-0-2-10", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// claim the first address
+	address, err = a.Assign(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", address)
+	pool, err := client.Resource(IPAddressPoolResource).Namespace(defaultMetalLBNamespace).Get(ctx, "kubeip-192-0-2-10", metav1.GetOptions{})
+	require.NoError(t, err)
+	addresses, _, _ := unstructured.NestedStringSlice(pool.Object, "spec", "addresses")
+	assert.Equal(t, []string{"192.0.2.10/32"}, addresses)
+	advertisement, err := client.Resource(L2AdvertisementResource).Namespace(defaultMetalLBNamespace).Get(ctx, "kubeip-192-0-2-10", metav1.GetOptions{})
+	require.NoError(t, err)
+	selectors, _, _ := unstructured.NestedSlice(advertisement.Object, "spec", "nodeSelectors")
+	assert.Equal(t, []interface{}{map[string]interface{}{"matchLabels": map[string]interface{}{metalLBHostnameLabel: "node-1"}}}, selectors)
+
+	// the address is announced from the node only
+	announcer, ok := a.(Announcer)
+	require.True(t, ok)
+	announced, err := announcer.Announced(ctx, "node-1", "192.0.2.10")
+	require.NoError(t, err)
+	assert.True(t, announced)
+	announced, err = announcer.Announced(ctx, "node-2", "192.0.2.10")
+	require.NoError(t, err)
+	assert.False(t, announced)
+	announced, err = announcer.Announced(ctx, "node-1", "192.0.2.11")
+	require.NoError(t, err)
+	assert.False(t, announced)
+
+	// the node keeps its address
+	address, err = a.Assign(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", address)
+
+	// the claimed address is the node candidate, others get the next one
+	address, err = a.Candidate(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", address)
+	address, err = a.Candidate(ctx, "node-2", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.11", address)
+
+	// other nodes claim the remaining addresses
+	address, err = a.Assign(ctx, "node-2", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.11", address)
+	_, err = a.Assign(ctx, "node-3", "", nil, "")
+	assert.Error(t, err)
+
+	// release
+	require.NoError(t, a.Unassign(ctx, "node-1", ""))
+	_, err = client.Resource(IPAddressPoolResource).Namespace(defaultMetalLBNamespace).Get(ctx, "kubeip-192-0-2-10", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = client.Resource(L2AdvertisementResource).Namespace(defaultMetalLBNamespace).Get(ctx, "kubeip-192-0-2-10", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.ErrorIs(t, a.Unassign(ctx, "node-1", ""), ErrNoStaticIPAssigned)
+
+	// the released address is claimed again
+	address, err = a.Assign(ctx, "node-3", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", address)
+}
+
+func TestMetalLBAssigner_concurrent(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("address claimed by another node", func(t *testing.T) {
+		a, client := newTestMetalLB(t, "192.0.2.10", "192.0.2.11")
+		other, ok := a.(*metalLBAssigner)
+		require.True(t, ok)
+		claimed := false
+		client.PrependReactor("create", "ipaddresspools", func(k8stesting.Action) (bool, runtime.Object, error) {
+			if !claimed {
+				// another agent claims the first address since listed, the create fails with already exists
+				claimed = true
+				pool := other.object("IPAddressPool", metalLBName("192.0.2.10"), "node-2", "192.0.2.10", map[string]interface{}{
+					"addresses": []interface{}{"192.0.2.10/32"},
+				})
+				require.NoError(t, client.Tracker().Create(IPAddressPoolResource, pool, defaultMetalLBNamespace))
+			}
+			return false, nil, nil
+		})
+		address, err := a.Assign(ctx, "node-1", "", nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, "192.0.2.11", address)
+		pool, err := client.Resource(IPAddressPoolResource).Namespace(defaultMetalLBNamespace).Get(ctx, "kubeip-192-0-2-10", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "node-2", pool.GetLabels()[nodeLabel])
+	})
+	t.Run("advertisement forbidden", func(t *testing.T) {
+		a, client := newTestMetalLB(t, "192.0.2.10")
+		client.PrependReactor("create", "l2advertisements", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(L2AdvertisementResource.GroupResource(), "kubeip-192-0-2-10", errors.New("RBAC: access denied"))
+		})
+		_, err := a.Assign(ctx, "node-1", "", nil, "")
+		assert.True(t, apierrors.IsForbidden(err))
+		// the claim is released as the address is not announced
+		_, err = client.Resource(IPAddressPoolResource).Namespace(defaultMetalLBNamespace).Get(ctx, "kubeip-192-0-2-10", metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+	t.Run("list forbidden", func(t *testing.T) {
+		a, client := newTestMetalLB(t, "192.0.2.10")
+		client.PrependReactor("list", "ipaddresspools", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(IPAddressPoolResource.GroupResource(), "", errors.New("RBAC: access denied"))
+		})
+		_, err := a.Assign(ctx, "node-1", "", nil, "")
+		assert.True(t, apierrors.IsForbidden(err))
+		_, err = a.Candidate(ctx, "node-1", "", nil, "")
+		assert.True(t, apierrors.IsForbidden(err))
+		assert.True(t, apierrors.IsForbidden(a.Unassign(ctx, "node-1", "")))
+	})
+}
+
+func TestNewMetalLBAssigner(t *testing.T) {
+	_, err := NewMetalLBAssigner(logrus.NewEntry(logrus.New()), nil, &config.Config{MetalLBAddresses: []string{"192.0.2.10"}})
+	assert.Error(t, err)
+	_, err = NewMetalLBAssigner(logrus.NewEntry(logrus.New()), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), &config.Config{})
+	assert.Error(t, err)
+}
@@ -2,10 +2,13 @@ package address
 
 import (
 	"context"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/doitintl/kubeip/internal/cloud"
 	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/metrics"
 	"github.com/doitintl/kubeip/internal/types"
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/core"
@@ -20,6 +23,9 @@ type ociAssigner struct {
 	compartmentOCID string
 	instanceSvc     cloud.OCIInstanceService
 	networkSvc      cloud.OCINetworkService
+	// nameRegex selects the available public IPs by display name, nil for all
+	nameRegex *regexp.Regexp
+	operations
 }
 
 // NewOCIAssigner creates a new Assigner for Oracle Cloud Infrastructure.
@@ -31,6 +37,16 @@ func NewOCIAssigner(_ context.Context, logger *logrus.Entry, cfg *config.Config)
 		},
 	).Info("creating new OCI assigner with given config")
 
+	// the freeform tags of the filters all have to match: OCI cannot list the public IPs matching any filter
+	if cfg.FilterLogic == FilterLogicOr && len(cfg.Filter) > 1 {
+		return nil, errors.Wrap(ErrInvalidFilter, "OCI supports the and filter logic only")
+	}
+
+	nameRegex, err := compileNameRegex(cfg.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse the filters
 	filters, err := parseOCIFilters(cfg)
 	if err != nil {
@@ -58,13 +74,15 @@ func NewOCIAssigner(_ context.Context, logger *logrus.Entry, cfg *config.Config)
 		instanceSvc:     computeSvc,
 		networkSvc:      networkSvc,
 		compartmentOCID: cfg.Project,
+		nameRegex:       nameRegex,
 	}, nil
 }
 
 // Assign assigns reserved Public IP to the instance.
 // If the instance already has a public IP assigned, and it is from the reserved list, it returns the same IP.
 // Else it assigns a new public IP from the reserved list.
-func (a *ociAssigner) Assign(ctx context.Context, instanceOCID, _ string, _ []string, _ string) (string, error) {
+func (a *ociAssigner) Assign(ctx context.Context, instanceOCID, _ string, filter []string, orderBy string) (string, error) {
+	d := newDecision(instanceOCID, filter, orderBy)
 	a.logger.WithField("instanceOCID", instanceOCID).Debug("starting process to assign reserved public IP to instance")
 
 	// Get the primary VNIC
@@ -80,7 +98,8 @@ func (a *ociAssigner) Assign(ctx context.Context, instanceOCID, _ string, _ []st
 		return "", errors.Wrap(err, "failed to check if public ip is already assigned or not")
 	}
 	if alreadyAssigned {
-		a.logger.WithField("alreadyAssignedIP", *vnic.PublicIp).Infof("reserved public IP already assigned on instance %s", instanceOCID)
+		d.choose(*vnic.PublicIp, ReasonAlreadyAssigned)
+		d.log(a.logger)
 		return *vnic.PublicIp, ErrStaticIPAlreadyAssigned
 	}
 
@@ -92,27 +111,70 @@ func (a *ociAssigner) Assign(ctx context.Context, instanceOCID, _ string, _ []st
 	a.logger.WithField("privateIPOCID", *privateIP.Id).Debugf("got primary VNIC private IP of the instance %s", instanceOCID)
 
 	// Fetch all available reserved Public IPs that will be used for assignment
+	listStart := time.Now()
 	reservedPublicIPList, err := a.fetchPublicIps(ctx, true, false)
+	metrics.ObservePhase(ctx, metrics.PhaseList, listStart)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get list of reserved public IPs")
 	}
 	if len(reservedPublicIPList) == 0 {
+		d.choose("", ReasonNoCandidates)
+		d.log(a.logger)
 		return "", errors.New("no reserved public IPs available")
 	}
-	a.logger.WithField("reservedPublicIpList", reservedPublicIPList).Debug("got list of available reserved public IPs")
+	for _, publicIP := range reservedPublicIPList {
+		d.candidates = append(d.candidates, *publicIP.IpAddress)
+	}
 
 	// Try to assign an IP from the reserved public IP list
 	for _, publicIP := range reservedPublicIPList {
-		if err = a.tryAssignAddress(ctx, *privateIP.Id, *publicIP.Id); err == nil {
-			a.logger.WithField("assignedIP", *publicIP.IpAddress).Infof("assigned IP %s to instance %s", *publicIP.IpAddress, instanceOCID)
+		attachStart := time.Now()
+		err = a.tryAssignAddress(ctx, *privateIP.Id, *publicIP.Id)
+		metrics.ObservePhase(ctx, metrics.PhaseAttach, attachStart)
+		if err == nil {
+			// the public IP is the allocation, the private IP of the VNIC its association
+			a.record(instanceOCID, &types.CloudOperation{AllocationID: *publicIP.Id, AssociationID: *privateIP.Id})
+			d.choose(*publicIP.IpAddress, ReasonFirstAvailable)
+			d.log(a.logger)
 			return *publicIP.IpAddress, nil
 		}
 		a.logger.Warnf("Failed to assign IP %s to instance %s: %v", *publicIP.IpAddress, instanceOCID, err)
+		d.exclude(*publicIP.IpAddress, err)
 	}
 
+	d.choose("", ReasonAllExcluded)
+	d.log(a.logger)
 	return "", errors.New("failed to assign any IP")
 }
 
+// Candidate returns the reserved public IP already assigned to the instance or the first available reserved public IP,
+// without unassigning or deleting the public IP currently assigned to the instance.
+func (a *ociAssigner) Candidate(ctx context.Context, instanceOCID, _ string, _ []string, _ string) (string, error) {
+	vnic, err := a.getPrimaryVnicOfInstance(ctx, instanceOCID)
+	if err != nil {
+		return "", err
+	}
+	if vnic.PublicIp != nil {
+		assigned, err := a.fetchPublicIps(ctx, true, true)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to list reserved public IPs assigned to private IP")
+		}
+		for _, ip := range assigned {
+			if *ip.IpAddress == *vnic.PublicIp {
+				return *vnic.PublicIp, nil
+			}
+		}
+	}
+	available, err := a.fetchPublicIps(ctx, true, false)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get list of reserved public IPs")
+	}
+	if len(available) == 0 {
+		return "", ErrNoAvailableAddress
+	}
+	return *available[0].IpAddress, nil
+}
+
 // Unassign unassigns the public IP from the instance.
 // If assigned public IP is from the reserved public IP list, it unassigns the public IP.
 // Else it does nothing.
@@ -146,6 +208,7 @@ func (a *ociAssigner) Unassign(ctx context.Context, instanceOCID, _ string) erro
 			if err := a.networkSvc.UpdatePublicIP(ctx, *ip.Id, ""); err != nil {
 				return errors.Wrap(err, "failed to unassign public IP assigned to private IP")
 			}
+			a.record(instanceOCID, &types.CloudOperation{AllocationID: *ip.Id})
 			return nil
 		}
 	}
@@ -207,7 +270,10 @@ func (a *ociAssigner) handlePublicIPAlreadyAssignedCase(ctx context.Context, vni
 		for _, ip := range list {
 			if *ip.IpAddress == *publicIP {
 				// Unassign the public IP
-				if err = a.networkSvc.UpdatePublicIP(ctx, *ip.Id, ""); err != nil {
+				start := time.Now()
+				err = a.networkSvc.UpdatePublicIP(ctx, *ip.Id, "")
+				metrics.ObservePhase(ctx, metrics.PhaseDetach, start)
+				if err != nil {
 					return false, errors.Wrap(err, "failed to unassign public IP assigned to private IP")
 				}
 				return false, nil
@@ -226,7 +292,10 @@ func (a *ociAssigner) handlePublicIPAlreadyAssignedCase(ctx context.Context, vni
 		for _, ip := range list {
 			if *ip.IpAddress == *publicIP {
 				// Delete the ephemeral public IP
-				if err := a.networkSvc.DeletePublicIP(ctx, *ip.Id); err != nil {
+				start := time.Now()
+				err = a.networkSvc.DeletePublicIP(ctx, *ip.Id)
+				metrics.ObservePhase(ctx, metrics.PhaseDetach, start)
+				if err != nil {
 					return false, errors.Wrap(err, "failed to delete ephemeral public IP assigned to private IP")
 				}
 				return false, nil
@@ -268,6 +337,10 @@ func (a *ociAssigner) fetchPublicIps(ctx context.Context, useFilter, inUse bool)
 	// Return IPs that match the given lifecycleState.
 	var updatedList []core.PublicIp
 	for _, ip := range list {
+		// the available public IPs of the filters are the candidates: those not matching the name pattern are skipped
+		if useFilter && !inUse && a.nameRegex != nil && (ip.DisplayName == nil || !a.nameRegex.MatchString(*ip.DisplayName)) {
+			continue
+		}
 		if ip.LifecycleState == lifecycleState {
 			updatedList = append(updatedList, ip)
 		}
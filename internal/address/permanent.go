@@ -0,0 +1,112 @@
+package address
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"google.golang.org/api/googleapi"
+)
+
+// AWS error codes of the requests retrying cannot fix
+var awsPermanentCodes = map[string]bool{
+	"UnauthorizedOperation": true,
+	"AuthFailure":           true,
+	"AccessDenied":          true,
+	"OptInRequired":         true,
+	"InvalidParameterValue": true,
+	"InvalidFilter":         true,
+}
+
+// Google Cloud error reasons of the requests retrying cannot fix; other reasons of a 400 or 403 (e.g. resourceNotReady,
+// rateLimitExceeded) are transient
+var gcpPermanentReasons = map[string]bool{
+	"invalid":                 true,
+	"invalidParameter":        true,
+	"required":                true,
+	"forbidden":               true,
+	"insufficientPermissions": true,
+	"accessNotConfigured":     true,
+	"authError":               true,
+	"quotaExceeded":           true,
+}
+
+// OCI error codes of the requests retrying cannot fix
+var ociPermanentCodes = map[string]bool{
+	"NotAuthenticated":        true,
+	"NotAuthorizedOrNotFound": true,
+}
+
+// AWS error codes of the cloud provider failing or throttling the requests
+var awsUnavailableCodes = map[string]bool{
+	"InternalError":        true,
+	"InternalFailure":      true,
+	"ServiceUnavailable":   true,
+	"Unavailable":          true,
+	"RequestLimitExceeded": true,
+	"Throttling":           true,
+}
+
+// IsPermanent reports whether retrying the assignment cannot fix the error: an invalid filter or request, denied
+// credentials or permissions, an exhausted Google Cloud quota, a node outside the region of the addresses or a refused
+// private node; other errors (throttling, resource not ready, unavailable API, no available address) are transient
+func IsPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrInvalidFilter) || errors.Is(err, ErrRegionMismatch) || errors.Is(err, ErrPrivateNode) ||
+		errors.Is(err, ErrUnknownCloudProvider) {
+		return true
+	}
+	var gcpErr *googleapi.Error
+	if errors.As(err, &gcpErr) {
+		return isGCPPermanent(gcpErr)
+	}
+	var awsErr smithy.APIError
+	if errors.As(err, &awsErr) {
+		return awsPermanentCodes[awsErr.ErrorCode()]
+	}
+	var ociErr common.ServiceError
+	if errors.As(err, &ociErr) {
+		return ociPermanentCodes[ociErr.GetCode()]
+	}
+	return false
+}
+
+// isGCPPermanent reports whether the reason of the Google Cloud error is permanent; without a reason, denied credentials
+// (401, 403) are
+func isGCPPermanent(err *googleapi.Error) bool {
+	if len(err.Errors) == 0 {
+		return err.Code == http.StatusUnauthorized || err.Code == http.StatusForbidden
+	}
+	for _, item := range err.Errors {
+		if gcpPermanentReasons[item.Reason] {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnavailable reports whether the error is the cloud provider being unavailable: a server error, throttling or an
+// unreachable API; the agent backs off through such an outage rather than being restarted
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var gcpErr *googleapi.Error
+	if errors.As(err, &gcpErr) {
+		return gcpErr.Code >= http.StatusInternalServerError || gcpErr.Code == http.StatusTooManyRequests
+	}
+	var awsErr smithy.APIError
+	if errors.As(err, &awsErr) {
+		return awsUnavailableCodes[awsErr.ErrorCode()]
+	}
+	var ociErr common.ServiceError
+	if errors.As(err, &ociErr) {
+		return ociErr.GetHTTPStatusCode() >= http.StatusInternalServerError || ociErr.GetHTTPStatusCode() == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,81 @@
+package address
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+)
+
+// ociServiceError is an OCI service error of the status and code
+type ociServiceError struct {
+	status int
+	code   string
+}
+
+func (e ociServiceError) Error() string           { return e.code }
+func (e ociServiceError) GetHTTPStatusCode() int  { return e.status }
+func (e ociServiceError) GetMessage() string      { return e.code }
+func (e ociServiceError) GetCode() string         { return e.code }
+func (e ociServiceError) GetOpcRequestID() string { return "" }
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "invalid filter", err: errors.Wrap(ErrInvalidFilter, "name of \"Name\""), want: true},
+		{name: "region mismatch", err: errors.Wrap(ErrRegionMismatch, "node in us-east1"), want: true},
+		{name: "gcp permission denied", err: errors.Wrap(&googleapi.Error{Code: http.StatusForbidden}, "failed to list addresses"), want: true},
+		{name: "gcp bad filter", err: &googleapi.Error{Code: http.StatusBadRequest, Errors: []googleapi.ErrorItem{{Reason: "invalid"}}}, want: true},
+		{name: "gcp resource not ready", err: &googleapi.Error{Code: http.StatusBadRequest, Errors: []googleapi.ErrorItem{{Reason: "resourceNotReady"}}}, want: false},
+		{name: "gcp bad request without reason", err: &googleapi.Error{Code: http.StatusBadRequest}, want: false},
+		{name: "gcp quota exceeded", err: &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}, want: true},
+		{name: "gcp rate limit exceeded", err: &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, want: false},
+		{name: "gcp rate limit", err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: false},
+		{name: "gcp unavailable", err: &googleapi.Error{Code: http.StatusServiceUnavailable}, want: false},
+		{name: "aws unauthorized", err: errors.Wrap(&smithy.GenericAPIError{Code: "UnauthorizedOperation"}, "failed to associate"), want: true},
+		{name: "aws throttling", err: &smithy.GenericAPIError{Code: "RequestLimitExceeded"}, want: false},
+		{name: "oci not authenticated", err: errors.Wrap(ociServiceError{status: http.StatusUnauthorized, code: "NotAuthenticated"}, "failed to list"), want: true},
+		{name: "oci not authorized or not found", err: ociServiceError{status: http.StatusNotFound, code: "NotAuthorizedOrNotFound"}, want: true},
+		{name: "oci conflict", err: ociServiceError{status: http.StatusConflict, code: "Conflict"}, want: false},
+		{name: "no available address", err: ErrNoAvailableAddress, want: false},
+		{name: "other", err: errors.New("connection reset"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPermanent(tt.err); got != tt.want {
+				t.Errorf("IsPermanent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "gcp unavailable", err: errors.Wrap(&googleapi.Error{Code: http.StatusServiceUnavailable}, "failed to list addresses"), want: true},
+		{name: "gcp rate limit", err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: true},
+		{name: "gcp permission denied", err: &googleapi.Error{Code: http.StatusForbidden}, want: false},
+		{name: "aws internal error", err: errors.Wrap(&smithy.GenericAPIError{Code: "InternalError"}, "failed to associate"), want: true},
+		{name: "aws unauthorized", err: &smithy.GenericAPIError{Code: "UnauthorizedOperation"}, want: false},
+		{name: "unreachable API", err: errors.Wrap(&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "failed to list"), want: true},
+		{name: "no available address", err: ErrNoAvailableAddress, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUnavailable(tt.err); got != tt.want {
+				t.Errorf("IsUnavailable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
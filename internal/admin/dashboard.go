@@ -0,0 +1,59 @@
+package admin
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/status"
+	"github.com/doitintl/kubeip/internal/types"
+)
+
+const (
+	// dashboardRefresh is the reload interval of the dashboard page, in seconds
+	dashboardRefresh = 10
+	// dashboardErrors is the number of recent errors shown on the dashboard
+	dashboardErrors = 10
+)
+
+//go:embed dashboard.html
+var dashboardPage string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardPage))
+
+// dashboardData is the model of the dashboard page
+type dashboardData struct {
+	Now      time.Time
+	Refresh  int
+	Pools    []status.PoolUtilization
+	Statuses []types.AssignmentStatus
+	Errors   []types.AssignmentStatus
+}
+
+// GET /ui/: the nodes, their assigned addresses, the pool utilization and the recent errors as a self-contained page
+// (relative links only, no external assets) to be embedded behind an authenticating proxy
+func (h *handler) dashboard(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	statuses, err := h.recorder.ListStatus(r.Context())
+	if err != nil {
+		h.log.WithError(err).Warn("admin API: failed to list assignment status")
+		http.Error(w, "failed to list assignment status", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Node < statuses[j].Node })
+	data := dashboardData{
+		Now:      time.Now().UTC(),
+		Refresh:  dashboardRefresh,
+		Pools:    status.Utilization(statuses),
+		Statuses: statuses,
+		Errors:   status.RecentErrors(statuses, dashboardErrors),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err = dashboardTemplate.Execute(w, data); err != nil {
+		h.log.WithError(err).Debug("admin API: failed to render dashboard")
+	}
+}
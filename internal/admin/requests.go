@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	typesv1 "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RequestAnnotation is the node annotation handing an admin request received by one agent over to the agent of the node
+	RequestAnnotation = "kubeip.com/admin-request"
+	// ActionReconcile assigns the static public IP address again unless the node still holds it
+	ActionReconcile = "reconcile"
+	// ActionRelease releases the static public IP address of the node
+	ActionRelease = "release"
+)
+
+var ErrUnknownAction = errors.New("unknown admin action")
+
+// Requests hands the admin requests over to the agent of the node: the API is served by any agent of the cluster, the
+// request is carried out by the agent of the requested node
+type Requests interface {
+	// Submit hands the action over to the agent of the node, replacing a pending request
+	Submit(ctx context.Context, nodeName, action string) error
+	// Receive returns the action handed over to the agent and clears it; false if there is none
+	Receive(ctx context.Context) (string, bool, error)
+}
+
+type nodeRequests struct {
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewNodeRequests returns the admin requests annotating the requested node; the agent runs on the named node
+func NewNodeRequests(client kubernetes.Interface, nodeName string) Requests {
+	return &nodeRequests{client: client, nodeName: nodeName}
+}
+
+func (r *nodeRequests) patch(ctx context.Context, nodeName string, patch map[string]interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"metadata": patch})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal admin request patch")
+	}
+	_, err = r.client.CoreV1().Nodes().Patch(ctx, nodeName, typesv1.MergePatchType, data, metav1.PatchOptions{})
+	return errors.Wrapf(err, "failed to patch node %s", nodeName)
+}
+
+func (r *nodeRequests) Submit(ctx context.Context, nodeName, action string) error {
+	if action != ActionReconcile && action != ActionRelease {
+		return errors.Wrapf(ErrUnknownAction, "%s, supported actions: %s, %s", action, ActionReconcile, ActionRelease)
+	}
+	return r.patch(ctx, nodeName, map[string]interface{}{
+		"annotations": map[string]string{RequestAnnotation: action},
+	})
+}
+
+func (r *nodeRequests) Receive(ctx context.Context) (string, bool, error) {
+	n, err := r.client.CoreV1().Nodes().Get(ctx, r.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to get kubernetes node")
+	}
+	action, ok := n.Annotations[RequestAnnotation]
+	if !ok {
+		return "", false, nil
+	}
+	// the resource version fails the patch when a request is submitted meanwhile, received on the next poll
+	err = r.patch(ctx, r.nodeName, map[string]interface{}{
+		"resourceVersion": n.ResourceVersion,
+		"annotations":     map[string]interface{}{RequestAnnotation: nil},
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return action, true, nil
+}
+
+// Watch delivers the actions handed over to the agent to the channel, polling at the interval until the context is done
+func Watch(ctx context.Context, log *logrus.Entry, requests Requests, interval time.Duration, actions chan<- string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		action, ok, err := requests.Receive(ctx)
+		if err != nil {
+			log.WithError(err).Warn("failed to receive admin requests")
+			continue
+		}
+		if !ok {
+			continue
+		}
+		select {
+		case actions <- action:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchRetryDelay is the delay before watching the node again once the API server refuses the watch
+var watchRetryDelay = 5 * time.Second
+
+// WatchReconcile signals the reconcile requests handed over to the agent of the node while it waits to assign an
+// address (e.g. once new addresses are reserved), clearing them; it watches the node rather than polling it, until the
+// context is done. The other actions are left to Watch, once the address is assigned
+func WatchReconcile(ctx context.Context, client kubernetes.Interface, nodeName string) <-chan struct{} {
+	r := &nodeRequests{client: client, nodeName: nodeName}
+	requests := make(chan struct{}, 1)
+	receive := func(n *v1.Node) {
+		if n.Name != nodeName || n.Annotations[RequestAnnotation] != ActionReconcile {
+			return
+		}
+		// the resource version fails the patch when a request is submitted meanwhile, received on its event
+		err := r.patch(ctx, nodeName, map[string]interface{}{
+			"resourceVersion": n.ResourceVersion,
+			"annotations":     map[string]interface{}{RequestAnnotation: nil},
+		})
+		if err != nil {
+			return
+		}
+		// a pending request covers the new one
+		select {
+		case requests <- struct{}{}:
+		default:
+		}
+	}
+	go func() {
+		for ctx.Err() == nil {
+			// the watch is opened before the node is checked, so no request is missed in between
+			changes, err := client.CoreV1().Nodes().Watch(ctx, metav1.ListOptions{
+				FieldSelector: fields.OneTermEqualSelector("metadata.name", nodeName).String(),
+			})
+			if err != nil {
+				select {
+				case <-time.After(watchRetryDelay):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			if n, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{}); err == nil {
+				receive(n)
+			}
+			watchNode(ctx, changes, receive)
+			changes.Stop()
+		}
+	}()
+	return requests
+}
+
+// watchNode calls receive on the changes of the node until the API server closes the watch or the context is done
+func watchNode(ctx context.Context, changes watch.Interface, receive func(n *v1.Node)) {
+	for {
+		select {
+		case event, ok := <-changes.ResultChan():
+			if !ok {
+				return
+			}
+			if n, isNode := event.Object.(*v1.Node); isNode && event.Type != watch.Deleted {
+				receive(n)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeRequests(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+	)
+	sender := NewNodeRequests(client, "node-1")
+	requests := NewNodeRequests(client, "node-2")
+
+	_, ok, err := requests.Receive(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.True(t, errors.Is(sender.Submit(ctx, "node-2", "restart"), ErrUnknownAction))
+	assert.Error(t, sender.Submit(ctx, "missing", ActionReconcile))
+	// the last request replaces a pending one
+	require.NoError(t, sender.Submit(ctx, "node-2", ActionReconcile))
+	require.NoError(t, sender.Submit(ctx, "node-2", ActionRelease))
+
+	action, ok, err := requests.Receive(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ActionRelease, action)
+
+	// the handed over request is cleared
+	n, err := client.CoreV1().Nodes().Get(ctx, "node-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, n.Annotations, RequestAnnotation)
+	_, ok, err = requests.Receive(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestWatchReconcile(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: map[string]string{RequestAnnotation: ActionReconcile}}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+	)
+	sender := NewNodeRequests(client, "node-2")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	requests := WatchReconcile(ctx, client, "node-1")
+
+	// the request pending on start is received and cleared
+	<-requests
+	require.Eventually(t, func() bool {
+		n, err := client.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+		return err == nil && n.Annotations[RequestAnnotation] == ""
+	}, time.Second, time.Millisecond)
+
+	// a release request and the requests of another node are left to Watch
+	require.NoError(t, sender.Submit(ctx, "node-2", ActionReconcile))
+	require.NoError(t, sender.Submit(ctx, "node-1", ActionRelease))
+	select {
+	case <-requests:
+		t.Fatal("WatchReconcile() signalled a request other than reconcile")
+	case <-time.After(20 * time.Millisecond):
+	}
+	action, ok, err := NewNodeRequests(client, "node-1").Receive(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ActionRelease, action)
+
+	// a new reconcile request is signalled
+	require.NoError(t, sender.Submit(ctx, "node-1", ActionReconcile))
+	<-requests
+}
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/httpserver"
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/status"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+var ErrMissingToken = errors.New("the admin API requires a bearer token file")
+
+// handler serves the admin API: list the assignments and the pool state, request the reconcile or the release of the
+// static public IP address of a node; every request is authenticated with the bearer token
+type handler struct {
+	log      *logrus.Entry
+	recorder nd.StatusRecorder
+	requests Requests
+	token    []byte
+}
+
+// NewHandler returns the admin API handler authenticating the requests with the bearer token; the dashboard is served
+// at /ui/ if enabled
+func NewHandler(log *logrus.Entry, client kubernetes.Interface, requests Requests, token string, dashboard bool) http.Handler {
+	h := &handler{log: log, recorder: nd.NewStatusRecorder(client), requests: requests, token: []byte(token)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/assignments", h.assignments)
+	mux.HandleFunc("/v1/pools", h.pools)
+	mux.HandleFunc("/v1/nodes/", h.nodeAction)
+	if dashboard {
+		mux.HandleFunc("/ui/", h.dashboard)
+	}
+	return h.authenticate(mux)
+}
+
+func (h *handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), h.token) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kubeip"`)
+			h.error(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET /v1/assignments: the assignment status of all nodes
+func (h *handler) assignments(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	statuses, err := h.recorder.ListStatus(r.Context())
+	if err != nil {
+		h.log.WithError(err).Warn("admin API: failed to list assignment status")
+		h.error(w, http.StatusInternalServerError, "failed to list assignment status")
+		return
+	}
+	h.write(w, http.StatusOK, statuses)
+}
+
+// GET /v1/pools: the nodes with an assigned address per node pool
+func (h *handler) pools(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodGet) {
+		return
+	}
+	statuses, err := h.recorder.ListStatus(r.Context())
+	if err != nil {
+		h.log.WithError(err).Warn("admin API: failed to list assignment status")
+		h.error(w, http.StatusInternalServerError, "failed to list assignment status")
+		return
+	}
+	h.write(w, http.StatusOK, status.Utilization(statuses))
+}
+
+// POST /v1/nodes/<node>/reconcile, POST /v1/nodes/<node>/release: hand the action over to the agent of the node
+func (h *handler) nodeAction(w http.ResponseWriter, r *http.Request) {
+	if !h.allow(w, r, http.MethodPost) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/nodes/"), "/")
+	if len(parts) != 2 || parts[0] == "" || (parts[1] != ActionReconcile && parts[1] != ActionRelease) { //nolint:gomnd
+		h.error(w, http.StatusNotFound, "not found")
+		return
+	}
+	nodeName, action := parts[0], parts[1]
+	logger := h.log.WithFields(logrus.Fields{"node": nodeName, "action": action})
+	if err := h.requests.Submit(r.Context(), nodeName, action); err != nil {
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			h.error(w, http.StatusNotFound, "node not found")
+			return
+		}
+		logger.WithError(err).Warn("admin API: failed to submit request")
+		h.error(w, http.StatusInternalServerError, "failed to submit request")
+		return
+	}
+	logger.Info("admin API: request handed over to the agent of the node")
+	h.write(w, http.StatusAccepted, map[string]string{"node": nodeName, "action": action})
+}
+
+// allow checks the request method, answering 405 otherwise
+func (h *handler) allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	h.error(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func (h *handler) error(w http.ResponseWriter, code int, message string) {
+	h.write(w, code, map[string]string{"error": message})
+}
+
+func (h *handler) write(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log.WithError(err).Debug("admin API: failed to write response")
+	}
+}
+
+// Serve serves the admin API on the configured address until the context is done; TLS if a certificate is configured,
+// mutual TLS if a client CA is configured
+func Serve(ctx context.Context, log *logrus.Entry, cfg *config.Config, client kubernetes.Interface, requests Requests) error {
+	if cfg.AdminTokenFile == "" {
+		return ErrMissingToken
+	}
+	token, err := os.ReadFile(cfg.AdminTokenFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read admin API token file")
+	}
+	if strings.TrimSpace(string(token)) == "" {
+		return errors.Wrapf(ErrMissingToken, "empty token file %s", cfg.AdminTokenFile)
+	}
+	handler := NewHandler(log, client, requests, strings.TrimSpace(string(token)), cfg.AdminDashboard)
+	t := httpserver.TLS{CertFile: cfg.AdminTLSCertFile, KeyFile: cfg.AdminTLSKeyFile, ClientCAFile: cfg.AdminTLSClientCAFile}
+	return httpserver.Serve(ctx, log, "admin API", cfg.AdminAddress, handler, t) //nolint:wrapcheck
+}
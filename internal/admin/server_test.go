@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/status"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHandler(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: map[string]string{
+			nd.AddressAnnotation:            "1.1.1.1",
+			nd.PoolAnnotation:               "pool-1",
+			nd.LastTransitionTimeAnnotation: "2024-03-01T10:00:00Z",
+		}}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+	)
+	requests := NewNodeRequests(client, "node-1")
+	server := httptest.NewServer(NewHandler(logrus.NewEntry(logrus.New()), client, requests, "secret", true))
+	defer server.Close()
+
+	do := func(method, path, token string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, http.NoBody)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{name: "missing token", method: http.MethodGet, path: "/v1/assignments", wantCode: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, path: "/v1/assignments", token: "guess", wantCode: http.StatusUnauthorized},
+		{name: "wrong method", method: http.MethodPost, path: "/v1/assignments", token: "secret", wantCode: http.StatusMethodNotAllowed},
+		{name: "unknown action", method: http.MethodPost, path: "/v1/nodes/node-2/restart", token: "secret", wantCode: http.StatusNotFound},
+		{name: "unknown node", method: http.MethodPost, path: "/v1/nodes/missing/release", token: "secret", wantCode: http.StatusNotFound},
+		{name: "release", method: http.MethodPost, path: "/v1/nodes/node-2/release", token: "secret", wantCode: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, do(tt.method, tt.path, tt.token).StatusCode)
+		})
+	}
+
+	// the release is handed over to the agent of node-2
+	action, ok, err := NewNodeRequests(client, "node-2").Receive(context.Background())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ActionRelease, action)
+
+	var statuses []types.AssignmentStatus
+	resp := do(http.MethodGet, "/v1/assignments", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "1.1.1.1", statuses[0].Address)
+
+	var pools []status.PoolUtilization
+	resp = do(http.MethodGet, "/v1/pools", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&pools))
+	require.Len(t, pools, 1)
+
+	resp = do(http.MethodGet, "/ui/", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	page, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "<td>node-1</td><td>1.1.1.1</td><td>pool-1</td>")
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/ui/", "").StatusCode)
+}
@@ -0,0 +1,78 @@
+package claim
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Resource is the cluster scoped KubeIPClaim custom resource reserving a static public IP address for the nodes matching
+// its node selector
+var Resource = schema.GroupVersionResource{Group: "kubeip.com", Version: "v1alpha1", Resource: "kubeipclaims"}
+
+// Claim is the static public IP address reserved for the nodes matching the node selector
+type Claim struct {
+	Name         string
+	NodeSelector string
+	Address      string
+}
+
+// Finder finds the claim of a node
+type Finder interface {
+	// Find returns the claim matching the labels of the node, the first by name if several match; nil if none matches
+	Find(ctx context.Context, nodeLabels map[string]string) (*Claim, error)
+}
+
+type finder struct {
+	client dynamic.Interface
+}
+
+func NewFinder(client dynamic.Interface) Finder {
+	return &finder{client: client}
+}
+
+func (f *finder) Find(ctx context.Context, nodeLabels map[string]string) (*Claim, error) {
+	list, err := f.client.Resource(Resource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list KubeIPClaim resources")
+	}
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool { return items[i].GetName() < items[j].GetName() })
+	for i := range items {
+		c, err := parse(&items[i])
+		if err != nil {
+			return nil, err
+		}
+		selector, err := labels.Parse(c.NodeSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid node selector of KubeIPClaim %s", c.Name)
+		}
+		if selector.Matches(labels.Set(nodeLabels)) {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+// parse returns the claim of the resource: an empty node selector matches no node, unlike an empty label selector
+func parse(item *unstructured.Unstructured) (*Claim, error) {
+	nodeSelector, _, err := unstructured.NestedString(item.Object, "spec", "nodeSelector")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid node selector of KubeIPClaim %s", item.GetName())
+	}
+	if nodeSelector == "" {
+		return nil, errors.Errorf("KubeIPClaim %s without node selector", item.GetName())
+	}
+	address, _, err := unstructured.NestedString(item.Object, "spec", "address")
+	if err != nil || net.ParseIP(address) == nil {
+		return nil, errors.Errorf("invalid address %q of KubeIPClaim %s", address, item.GetName())
+	}
+	return &Claim{Name: item.GetName(), NodeSelector: nodeSelector, Address: address}, nil
+}
@@ -0,0 +1,59 @@
+package claim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newClaim(name, nodeSelector, address string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kubeip.com/v1alpha1",
+		"kind":       "KubeIPClaim",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"nodeSelector": nodeSelector, "address": address},
+	}}
+}
+
+func newFinder(objects ...runtime.Object) Finder {
+	return NewFinder(dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{Resource: "KubeIPClaimList"}, objects...))
+}
+
+func TestFinder_Find(t *testing.T) {
+	ctx := context.Background()
+	f := newFinder(
+		newClaim("payments-b", "app=payments", "203.0.113.11"),
+		newClaim("payments-a", "app=payments,zone in (a,b)", "203.0.113.10"),
+		newClaim("reporting", "app=reporting", "203.0.113.20"),
+	)
+
+	// the first matching claim by name
+	c, err := f.Find(ctx, map[string]string{"app": "payments", "zone": "a"})
+	require.NoError(t, err)
+	assert.Equal(t, &Claim{Name: "payments-a", NodeSelector: "app=payments,zone in (a,b)", Address: "203.0.113.10"}, c)
+
+	c, err = f.Find(ctx, map[string]string{"app": "payments", "zone": "c"})
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.11", c.Address)
+
+	c, err = f.Find(ctx, map[string]string{"app": "web"})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	// invalid claims fail the lookup rather than falling back to the pool
+	for _, invalid := range []*unstructured.Unstructured{
+		newClaim("no-selector", "", "203.0.113.10"),
+		newClaim("bad-selector", "app in payments", "203.0.113.10"),
+		newClaim("bad-address", "app=payments", "203.0.113"),
+	} {
+		_, err = newFinder(invalid).Find(ctx, map[string]string{"app": "payments"})
+		assert.Error(t, err, invalid.GetName())
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	kubeiptypes "github.com/doitintl/kubeip/internal/types"
 	"github.com/pkg/errors"
 )
 
 type EipAssigner interface {
-	Assign(ctx context.Context, networkInterfaceID, allocationID string) error
+	// Assign associates the elastic IP with the network interface and returns the request and association IDs
+	Assign(ctx context.Context, networkInterfaceID, allocationID string) (*kubeiptypes.CloudOperation, error)
 	Unassign(ctx context.Context, associationID string) error
 }
 
@@ -21,7 +24,7 @@ func NewEipAssigner(client *ec2.Client) EipAssigner {
 	return &eipAssigner{client: client}
 }
 
-func (a *eipAssigner) Assign(ctx context.Context, networkInterfaceID, allocationID string) error {
+func (a *eipAssigner) Assign(ctx context.Context, networkInterfaceID, allocationID string) (*kubeiptypes.CloudOperation, error) {
 	// associate elastic IP with the instance
 	input := &ec2.AssociateAddressInput{
 		AllocationId:       &allocationID,
@@ -29,12 +32,17 @@ func (a *eipAssigner) Assign(ctx context.Context, networkInterfaceID, allocation
 		AllowReassociation: aws.Bool(false), // do not allow reassociation of the elastic IP
 	}
 
-	_, err := a.client.AssociateAddress(ctx, input)
+	output, err := a.client.AssociateAddress(ctx, input)
 	if err != nil {
-		return errors.Wrap(err, "failed to associate elastic IP with the instance")
+		return nil, errors.Wrap(err, "failed to associate elastic IP with the instance")
 	}
 
-	return nil
+	requestID, _ := awsmiddleware.GetRequestIDMetadata(output.ResultMetadata)
+	return &kubeiptypes.CloudOperation{
+		OperationID:   requestID,
+		AllocationID:  allocationID,
+		AssociationID: aws.ToString(output.AssociationId),
+	}, nil
 }
 
 func (a *eipAssigner) Unassign(ctx context.Context, associationID string) error {
@@ -50,3 +58,77 @@ func (a *eipAssigner) Unassign(ctx context.Context, associationID string) error
 
 	return nil
 }
+
+// Ipv6PrefixAssigner delegates IPv6 prefixes to network interfaces (ENI prefix delegation)
+type Ipv6PrefixAssigner interface {
+	Assign(ctx context.Context, networkInterfaceID, prefix string) error
+	Unassign(ctx context.Context, networkInterfaceID, prefix string) error
+}
+
+type ipv6PrefixAssigner struct {
+	client *ec2.Client
+}
+
+func NewIpv6PrefixAssigner(client *ec2.Client) Ipv6PrefixAssigner {
+	return &ipv6PrefixAssigner{client: client}
+}
+
+func (a *ipv6PrefixAssigner) Assign(ctx context.Context, networkInterfaceID, prefix string) error {
+	// fails if the prefix is delegated to another network interface or outside the subnet
+	_, err := a.client.AssignIpv6Addresses(ctx, &ec2.AssignIpv6AddressesInput{
+		NetworkInterfaceId: &networkInterfaceID,
+		Ipv6Prefixes:       []string{prefix},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to assign IPv6 prefix to the network interface")
+	}
+	return nil
+}
+
+func (a *ipv6PrefixAssigner) Unassign(ctx context.Context, networkInterfaceID, prefix string) error {
+	_, err := a.client.UnassignIpv6Addresses(ctx, &ec2.UnassignIpv6AddressesInput{
+		NetworkInterfaceId: &networkInterfaceID,
+		Ipv6Prefixes:       []string{prefix},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to unassign IPv6 prefix from the network interface")
+	}
+	return nil
+}
+
+// PrivateIPAssigner assigns secondary private IPv4 addresses to network interfaces
+type PrivateIPAssigner interface {
+	Assign(ctx context.Context, networkInterfaceID, privateIP string) error
+	Unassign(ctx context.Context, networkInterfaceID, privateIP string) error
+}
+
+type privateIPAssigner struct {
+	client *ec2.Client
+}
+
+func NewPrivateIPAssigner(client *ec2.Client) PrivateIPAssigner {
+	return &privateIPAssigner{client: client}
+}
+
+func (a *privateIPAssigner) Assign(ctx context.Context, networkInterfaceID, privateIP string) error {
+	_, err := a.client.AssignPrivateIpAddresses(ctx, &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: &networkInterfaceID,
+		PrivateIpAddresses: []string{privateIP},
+		AllowReassignment:  aws.Bool(false), // do not take the private IP from another network interface
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to assign secondary private IP to the network interface")
+	}
+	return nil
+}
+
+func (a *privateIPAssigner) Unassign(ctx context.Context, networkInterfaceID, privateIP string) error {
+	_, err := a.client.UnassignPrivateIpAddresses(ctx, &ec2.UnassignPrivateIpAddressesInput{
+		NetworkInterfaceId: &networkInterfaceID,
+		PrivateIpAddresses: []string{privateIP},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to unassign secondary private IP from the network interface")
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package cloud
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+)
+
+const awsRoleSessionName = "kubeip-agent"
+
+// awsPartitions are the AWS partitions by region prefix; other regions are in the aws partition
+var awsPartitions = []struct {
+	prefix    string
+	partition string
+}{
+	{"us-gov-", "aws-us-gov"},
+	{"cn-", "aws-cn"},
+	{"us-isob-", "aws-iso-b"},
+	{"us-iso-", "aws-iso"},
+}
+
+// AWSPartition returns the partition of the region: aws, aws-us-gov (GovCloud), aws-cn (China), aws-iso or aws-iso-b
+func AWSPartition(region string) string {
+	for _, p := range awsPartitions {
+		if strings.HasPrefix(region, p.prefix) {
+			return p.partition
+		}
+	}
+	return "aws"
+}
+
+// validateRoleARN checks the role ARN is an IAM role ARN of the partition of the region: a role of another partition
+// cannot be assumed and would fail on the first call with an opaque STS error
+func validateRoleARN(roleARN, region string) error {
+	parsed, err := arn.Parse(roleARN)
+	if err != nil || parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+		return errors.Errorf("invalid AWS role ARN %s, expected arn:<partition>:iam::<account>:role/<name>", roleARN)
+	}
+	if region != "" && parsed.Partition != AWSPartition(region) {
+		return errors.Errorf("AWS role ARN %s is in partition %s, region %s is in partition %s", roleARN, parsed.Partition, region, AWSPartition(region))
+	}
+	return nil
+}
+
+// LoadAWSConfig returns the AWS config of the region (--aws-region, else --region, else ambient); the SDK resolves the
+// endpoints of the partition of the region (GovCloud, China); the credentials are those of the credentials file (re-read
+// on rotation) else the ambient ones; with a role ARN the credentials assume the role, with the web identity token (IRSA)
+// or with these credentials and an optional external ID
+func LoadAWSConfig(ctx context.Context, cfg *config.Config) (aws.Config, error) {
+	region := cfg.AWSRegion
+	if region == "" {
+		region = cfg.Region
+	}
+	options, err := TransportOptions(cfg)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	loadOptions := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(region)}
+	if options != nil {
+		loadOptions = append(loadOptions, awsconfig.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(options)))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return aws.Config{}, errors.Wrap(err, "failed to load AWS config")
+	}
+	switch {
+	case cfg.AWSCredentialsFile != "":
+		if awsCfg.Credentials, err = newFileCredentials(ctx, cfg.AWSCredentialsFile); err != nil {
+			return aws.Config{}, err
+		}
+	case awsCfg.Credentials != nil:
+		awsCfg.Credentials = aws.NewCredentialsCache(&metadataCredentialsProvider{provider: awsCfg.Credentials})
+	}
+
+	if cfg.AWSRoleARN == "" {
+		if cfg.AWSWebIdentityTokenFile != "" || cfg.AWSExternalID != "" {
+			return aws.Config{}, errors.New("AWS role ARN is required with web identity token file or external ID")
+		}
+		return awsCfg, nil
+	}
+
+	if err = validateRoleARN(cfg.AWSRoleARN, awsCfg.Region); err != nil {
+		return aws.Config{}, err
+	}
+
+	stsClient := sts.NewFromConfig(awsCfg)
+	if cfg.AWSWebIdentityTokenFile != "" {
+		if cfg.AWSExternalID != "" {
+			return aws.Config{}, errors.New("AWS external ID is not supported with web identity token file")
+		}
+		awsCfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(stsClient, cfg.AWSRoleARN,
+			stscreds.IdentityTokenFile(cfg.AWSWebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = awsRoleSessionName
+			}))
+		return awsCfg, nil
+	}
+	awsCfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, cfg.AWSRoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = awsRoleSessionName
+		if cfg.AWSExternalID != "" {
+			o.ExternalID = aws.String(cfg.AWSExternalID)
+		}
+	}))
+	return awsCfg, nil
+}
+
+// NewEC2Client returns the EC2 client of the AWS config, calling the EC2 endpoint override (VPC endpoint, LocalStack) if set
+func NewEC2Client(awsCfg aws.Config, cfg *config.Config) *ec2.Client {
+	return ec2.NewFromConfig(awsCfg, func(o *ec2.Options) {
+		if cfg.AWSEndpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.AWSEndpoint)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package cloud
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAWSConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *config.Config
+		wantRegion string
+		wantCache  bool
+		wantErr    bool
+	}{
+		{
+			name:       "ambient credentials",
+			cfg:        &config.Config{Region: "us-east-1"},
+			wantRegion: "us-east-1",
+		},
+		{
+			name:       "AWS region overrides region",
+			cfg:        &config.Config{Region: "us-east-1", AWSRegion: "eu-west-1"},
+			wantRegion: "eu-west-1",
+		},
+		{
+			name:       "assume role with external ID",
+			cfg:        &config.Config{Region: "us-east-1", AWSRoleARN: "arn:aws:iam::123456789012:role/kubeip", AWSExternalID: "kubeip"},
+			wantRegion: "us-east-1",
+			wantCache:  true,
+		},
+		{
+			name:       "assume role with web identity",
+			cfg:        &config.Config{Region: "us-east-1", AWSRoleARN: "arn:aws:iam::123456789012:role/kubeip", AWSWebIdentityTokenFile: "/var/run/secrets/token"},
+			wantRegion: "us-east-1",
+			wantCache:  true,
+		},
+		{
+			name:       "assume role in China region",
+			cfg:        &config.Config{Region: "cn-north-1", AWSRoleARN: "arn:aws-cn:iam::123456789012:role/kubeip"},
+			wantRegion: "cn-north-1",
+			wantCache:  true,
+		},
+		{
+			name:    "role of another partition",
+			cfg:     &config.Config{Region: "us-gov-west-1", AWSRoleARN: "arn:aws:iam::123456789012:role/kubeip"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid role ARN",
+			cfg:     &config.Config{Region: "us-east-1", AWSRoleARN: "arn:aws:s3:::kubeip"},
+			wantErr: true,
+		},
+		{
+			name:    "external ID without role",
+			cfg:     &config.Config{Region: "us-east-1", AWSExternalID: "kubeip"},
+			wantErr: true,
+		},
+		{
+			name:    "web identity with external ID",
+			cfg:     &config.Config{Region: "us-east-1", AWSRoleARN: "arn:aws:iam::123456789012:role/kubeip", AWSWebIdentityTokenFile: "/var/run/secrets/token", AWSExternalID: "kubeip"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", "")
+			awsCfg, err := LoadAWSConfig(context.Background(), tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRegion, awsCfg.Region)
+			_, isCache := awsCfg.Credentials.(*aws.CredentialsCache)
+			if tt.wantCache {
+				assert.True(t, isCache)
+			}
+		})
+	}
+}
+
+func TestNewEC2Client(t *testing.T) {
+	awsCfg := aws.Config{Region: "us-east-1"}
+	assert.Nil(t, NewEC2Client(awsCfg, &config.Config{}).Options().BaseEndpoint)
+
+	client := NewEC2Client(awsCfg, &config.Config{AWSEndpoint: "http://localhost:4566"})
+	assert.Equal(t, "http://localhost:4566", aws.ToString(client.Options().BaseEndpoint))
+}
+
+func TestAWSPartition(t *testing.T) {
+	tests := []struct {
+		region string
+		want   string
+	}{
+		{region: "us-east-1", want: "aws"},
+		{region: "eu-west-1", want: "aws"},
+		{region: "us-gov-west-1", want: "aws-us-gov"},
+		{region: "cn-northwest-1", want: "aws-cn"},
+		{region: "us-iso-east-1", want: "aws-iso"},
+		{region: "us-isob-east-1", want: "aws-iso-b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			assert.Equal(t, tt.want, AWSPartition(tt.region))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package cloud
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	awsCredentialsProfile = "default"
+	awsCredentialsSource  = "KubeIPCredentialsFile"
+)
+
+// awsCredentialsRefresh is the interval the credentials file is re-read at (the credentials cache expiry)
+var awsCredentialsRefresh = time.Minute
+
+// fileCredentialsProvider reads the credentials of the default profile of a shared credentials file, e.g. a mounted
+// Secret; the credentials expire after the refresh interval, so a rotated Secret is picked up without a restart
+type fileCredentialsProvider struct {
+	path string
+}
+
+func (p *fileCredentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	shared, err := awsconfig.LoadSharedConfigProfile(ctx, awsCredentialsProfile, func(o *awsconfig.LoadSharedConfigOptions) {
+		o.CredentialsFiles = []string{p.path}
+		o.ConfigFiles = []string{}
+	})
+	if err != nil {
+		return aws.Credentials{}, errors.Wrapf(err, "failed to read AWS credentials file %s", p.path)
+	}
+	creds := shared.Credentials
+	if !creds.HasKeys() {
+		return aws.Credentials{}, errors.Errorf("no access key in the %s profile of AWS credentials file %s", awsCredentialsProfile, p.path)
+	}
+	creds.Source = awsCredentialsSource
+	creds.CanExpire = true
+	creds.Expires = time.Now().Add(awsCredentialsRefresh)
+	return creds, nil
+}
+
+// newFileCredentials returns the cached credentials of the credentials file, failing at startup on an invalid file
+func newFileCredentials(ctx context.Context, path string) (aws.CredentialsProvider, error) {
+	provider := &fileCredentialsProvider{path: path}
+	if _, err := provider.Retrieve(ctx); err != nil {
+		return nil, err
+	}
+	return aws.NewCredentialsCache(provider), nil
+}
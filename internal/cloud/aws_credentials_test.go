@@ -0,0 +1,54 @@
+package cloud
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAWSCredentials(t *testing.T, path, accessKey string) {
+	data := "[default]\naws_access_key_id = " + accessKey + "\naws_secret_access_key = secret\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+}
+
+func TestNewFileCredentials(t *testing.T) {
+	refresh := awsCredentialsRefresh
+	awsCredentialsRefresh = 0
+	defer func() { awsCredentialsRefresh = refresh }()
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	writeAWSCredentials(t, path, "AKIAFIRST")
+	provider, err := newFileCredentials(context.Background(), path)
+	require.NoError(t, err)
+
+	creds, err := provider.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAFIRST", creds.AccessKeyID)
+	assert.Equal(t, awsCredentialsSource, creds.Source)
+
+	// the rotated Secret is re-read once the credentials expire
+	writeAWSCredentials(t, path, "AKIAROTATED")
+	creds, err = provider.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIAROTATED", creds.AccessKeyID)
+}
+
+func TestNewFileCredentials_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	noKeys := filepath.Join(dir, "no-keys")
+	require.NoError(t, os.WriteFile(noKeys, []byte("[other]\naws_access_key_id = AKIAOTHER\naws_secret_access_key = secret\n"), 0o600))
+
+	for name, path := range map[string]string{
+		"missing file":       filepath.Join(dir, "missing"),
+		"no default profile": noKeys,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newFileCredentials(context.Background(), path)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package cloud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AutoScalingGroupTag is the tag of the EC2 instances naming their Auto Scaling group
+	AutoScalingGroupTag   = "aws:autoscaling:groupName"
+	autoScalingAPIVersion = "2011-01-01"
+	autoScalingService    = "autoscaling"
+	// lifecycleActionContinue lets the instance enter service
+	lifecycleActionContinue = "CONTINUE"
+)
+
+// ErrNoLifecycleAction is returned when the instance has no pending lifecycle action of the hook, e.g. completed already
+var ErrNoLifecycleAction = errors.New("no active lifecycle action")
+
+// LifecycleActionCompleter completes the pending lifecycle action of an instance of an EC2 Auto Scaling group
+type LifecycleActionCompleter interface {
+	Complete(ctx context.Context, group, hook, instanceID string) error
+}
+
+// autoScalingClient calls the CompleteLifecycleAction API of EC2 Auto Scaling (Query protocol, SigV4 signed)
+type autoScalingClient struct {
+	awsCfg   aws.Config
+	endpoint string
+	signer   *v4.Signer
+}
+
+// autoScalingError is the error response of the EC2 Auto Scaling Query API
+type autoScalingError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// NewLifecycleActionCompleter returns the lifecycle action completer of the AWS config, calling the Auto Scaling endpoint
+// override (VPC endpoint, LocalStack) if set
+func NewLifecycleActionCompleter(awsCfg aws.Config, cfg *config.Config) LifecycleActionCompleter {
+	endpoint := strings.TrimSuffix(cfg.AWSAutoScalingEndpoint, "/")
+	if endpoint == "" {
+		domain := "amazonaws.com"
+		if AWSPartition(awsCfg.Region) == "aws-cn" {
+			domain = "amazonaws.com.cn"
+		}
+		endpoint = fmt.Sprintf("https://%s.%s.%s", autoScalingService, awsCfg.Region, domain)
+	}
+	return &autoScalingClient{awsCfg: awsCfg, endpoint: endpoint, signer: v4.NewSigner()}
+}
+
+func (c *autoScalingClient) Complete(ctx context.Context, group, hook, instanceID string) error {
+	form := url.Values{
+		"Action":                {"CompleteLifecycleAction"},
+		"Version":               {autoScalingAPIVersion},
+		"AutoScalingGroupName":  {group},
+		"LifecycleHookName":     {hook},
+		"InstanceId":            {instanceID},
+		"LifecycleActionResult": {lifecycleActionContinue},
+	}
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", strings.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create CompleteLifecycleAction request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	credentials, err := c.awsCfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to retrieve AWS credentials")
+	}
+	hash := sha256.Sum256([]byte(body))
+	if err = c.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), autoScalingService, c.awsCfg.Region, time.Now()); err != nil {
+		return errors.Wrap(err, "failed to sign CompleteLifecycleAction request")
+	}
+
+	client := c.awsCfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to call CompleteLifecycleAction")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	payload, _ := io.ReadAll(resp.Body)
+	var apiErr autoScalingError
+	if xml.Unmarshal(payload, &apiErr) != nil || apiErr.Code == "" {
+		return errors.Errorf("CompleteLifecycleAction failed with status %d", resp.StatusCode)
+	}
+	if apiErr.Code == "ValidationError" && strings.Contains(apiErr.Message, "No active Lifecycle Action") {
+		return errors.Wrap(ErrNoLifecycleAction, apiErr.Message)
+	}
+	return errors.Errorf("CompleteLifecycleAction failed: %s: %s", apiErr.Code, apiErr.Message)
+}
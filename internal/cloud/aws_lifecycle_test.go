@@ -0,0 +1,80 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleActionCompleter_Complete(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+		anyErr  bool
+	}{
+		{
+			name:   "completed",
+			status: http.StatusOK,
+			body:   `<CompleteLifecycleActionResponse><CompleteLifecycleActionResult/></CompleteLifecycleActionResponse>`,
+		},
+		{
+			name:    "no active lifecycle action",
+			status:  http.StatusBadRequest,
+			body:    `<ErrorResponse><Error><Type>Sender</Type><Code>ValidationError</Code><Message>No active Lifecycle Action found with instance ID i-1</Message></Error></ErrorResponse>`,
+			wantErr: ErrNoLifecycleAction,
+			anyErr:  true,
+		},
+		{
+			name:   "access denied",
+			status: http.StatusForbidden,
+			body:   `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`,
+			anyErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, r.ParseForm())
+				assert.Equal(t, "CompleteLifecycleAction", r.PostForm.Get("Action"))
+				assert.Equal(t, "workers", r.PostForm.Get("AutoScalingGroupName"))
+				assert.Equal(t, "kubeip", r.PostForm.Get("LifecycleHookName"))
+				assert.Equal(t, "i-1", r.PostForm.Get("InstanceId"))
+				assert.Equal(t, "CONTINUE", r.PostForm.Get("LifecycleActionResult"))
+				assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"), "request is signed")
+				assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/autoscaling/aws4_request")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			awsCfg := aws.Config{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")}
+			completer := NewLifecycleActionCompleter(awsCfg, &config.Config{AWSAutoScalingEndpoint: server.URL})
+			err := completer.Complete(context.Background(), "workers", "kubeip", "i-1")
+			if !tt.anyErr {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewLifecycleActionCompleter_Endpoint(t *testing.T) {
+	completer := NewLifecycleActionCompleter(aws.Config{Region: "cn-north-1"}, &config.Config{})
+	assert.Equal(t, "https://autoscaling.cn-north-1.amazonaws.com.cn", completer.(*autoScalingClient).endpoint)
+	completer = NewLifecycleActionCompleter(aws.Config{Region: "eu-west-1"}, &config.Config{})
+	assert.Equal(t, "https://autoscaling.eu-west-1.amazonaws.com", completer.(*autoScalingClient).endpoint)
+}
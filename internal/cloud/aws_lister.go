@@ -5,6 +5,8 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/doitintl/kubeip/internal/metrics"
+	kubeiptypes "github.com/doitintl/kubeip/internal/types"
 	"github.com/pkg/errors"
 )
 
@@ -35,10 +37,12 @@ func (l *eipLister) List(ctx context.Context, filter map[string][]string, inUse
 	input := &ec2.DescribeAddressesInput{
 		Filters: filters,
 	}
+	// DescribeAddresses is not paginated: the elastic IPs of the region come in a single page, bounded by the quota
 	list, err := l.client.DescribeAddresses(ctx, input)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list elastic IPs")
 	}
+	metrics.ObserveListPage(string(kubeiptypes.CloudProviderAWS))
 
 	filtered := make([]types.Address, 0, len(list.Addresses))
 	// API does not support filtering by association ID equal to nil
@@ -0,0 +1,80 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	imdsTokenPath      = "/latest/api/token"
+	imdsTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	imdsTokenTTL       = "60"
+	imdsProbeTimeout   = 2 * time.Second
+)
+
+// imdsEndpoint is the instance metadata endpoint (overridden in tests)
+var imdsEndpoint = "http://169.254.169.254"
+
+// metadataCredentialsProvider explains the credentials failures caused by the instance metadata: the SDK fetches
+// the instance profile credentials with an IMDSv2 session token, whose response is dropped beyond the hop limit of
+// the instance (1 by default), i.e. in the pod network namespace
+type metadataCredentialsProvider struct {
+	provider aws.CredentialsProvider
+}
+
+func (p *metadataCredentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := p.provider.Retrieve(ctx)
+	if err == nil {
+		return creds, nil
+	}
+	if probeErr := probeIMDSv2(ctx); probeErr != nil {
+		return creds, errors.Wrapf(err, "instance metadata IMDSv2 token request failed (%v): the metadata response hop limit of the "+
+			"instance must be at least 2 outside the host network", probeErr)
+	}
+	return creds, err //nolint:wrapcheck
+}
+
+// probeIMDSv2 requests an IMDSv2 session token, bypassing the proxy
+func probeIMDSv2(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, imdsProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, imdsEndpoint+imdsTokenPath, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to create IMDSv2 token request")
+	}
+	req.Header.Set(imdsTokenTTLHeader, imdsTokenTTL)
+	resp, err := (&http.Client{Transport: &http.Transport{}}).Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to request IMDSv2 token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("IMDSv2 token request returned %s", resp.Status)
+	}
+	return nil
+}
+
+// CheckInstanceMetadataOptions warns when the metadata options of the instance do not meet the expectations: IMDSv1
+// still enabled, or a response hop limit below the expected one (2 for pods outside the host network)
+func CheckInstanceMetadataOptions(logger *logrus.Entry, instance *types.Instance, minHopLimit int) {
+	options := instance.MetadataOptions
+	if options == nil {
+		return
+	}
+	if options.HttpTokens != types.HttpTokensStateRequired {
+		logger.WithField("instance", aws.ToString(instance.InstanceId)).Info("instance metadata allows IMDSv1, require IMDSv2 (http-tokens required) to harden it")
+	}
+	if hopLimit := int(aws.ToInt32(options.HttpPutResponseHopLimit)); hopLimit > 0 && hopLimit < minHopLimit {
+		logger.WithFields(logrus.Fields{
+			"instance":         aws.ToString(instance.InstanceId),
+			"hop-limit":        hopLimit,
+			"expect-hop-limit": minHopLimit,
+		}).Warn("instance metadata response hop limit is below the expected one, pods outside the host network cannot get IMDSv2 tokens")
+	}
+}
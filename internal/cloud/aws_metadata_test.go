@@ -0,0 +1,110 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingCredentialsProvider struct{}
+
+func (failingCredentialsProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{}, errors.New("no EC2 IMDS role found")
+}
+
+func TestMetadataCredentialsProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantHint bool
+	}{
+		{
+			name: "IMDSv2 token available",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPut, r.Method)
+				assert.Equal(t, imdsTokenTTL, r.Header.Get(imdsTokenTTLHeader))
+				_, _ = w.Write([]byte("token"))
+			},
+		},
+		{
+			name: "IMDSv2 token unavailable",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantHint: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			endpoint := imdsEndpoint
+			imdsEndpoint = server.URL
+			defer func() { imdsEndpoint = endpoint }()
+
+			provider := &metadataCredentialsProvider{provider: failingCredentialsProvider{}}
+			_, err := provider.Retrieve(context.Background())
+			assert.ErrorContains(t, err, "no EC2 IMDS role found")
+			if tt.wantHint {
+				assert.ErrorContains(t, err, "hop limit")
+			} else {
+				assert.NotContains(t, err.Error(), "hop limit")
+			}
+		})
+	}
+}
+
+func TestCheckInstanceMetadataOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   *types.InstanceMetadataOptionsResponse
+		hopLimit  int
+		wantLevel []logrus.Level
+	}{
+		{
+			name:    "no metadata options",
+			options: nil,
+		},
+		{
+			name:     "IMDSv2 required and hop limit 2",
+			options:  &types.InstanceMetadataOptionsResponse{HttpTokens: types.HttpTokensStateRequired, HttpPutResponseHopLimit: aws.Int32(2)},
+			hopLimit: 2,
+		},
+		{
+			name:      "IMDSv1 enabled",
+			options:   &types.InstanceMetadataOptionsResponse{HttpTokens: types.HttpTokensStateOptional, HttpPutResponseHopLimit: aws.Int32(2)},
+			hopLimit:  2,
+			wantLevel: []logrus.Level{logrus.InfoLevel},
+		},
+		{
+			name:      "hop limit below the expected one",
+			options:   &types.InstanceMetadataOptionsResponse{HttpTokens: types.HttpTokensStateRequired, HttpPutResponseHopLimit: aws.Int32(1)},
+			hopLimit:  2,
+			wantLevel: []logrus.Level{logrus.WarnLevel},
+		},
+		{
+			name:     "hop limit 1 on the host network",
+			options:  &types.InstanceMetadataOptionsResponse{HttpTokens: types.HttpTokensStateRequired, HttpPutResponseHopLimit: aws.Int32(1)},
+			hopLimit: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			CheckInstanceMetadataOptions(logrus.NewEntry(logger), &types.Instance{InstanceId: aws.String("i-1"), MetadataOptions: tt.options}, tt.hopLimit)
+			var levels []logrus.Level
+			for _, entry := range hook.AllEntries() {
+				levels = append(levels, entry.Level)
+			}
+			assert.Equal(t, tt.wantLevel, levels)
+		})
+	}
+}
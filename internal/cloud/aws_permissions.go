@@ -0,0 +1,93 @@
+package cloud
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	errCodeDryRunOperation = "DryRunOperation"
+	errCodeUnauthorized    = "UnauthorizedOperation"
+	// placeholder IDs of the dry-run calls: the permissions are checked before the resources
+	dryRunAllocationID  = "eipalloc-00000000000000000"
+	dryRunAssociationID = "eipassoc-00000000000000000"
+)
+
+const (
+	ec2DescribeAddresses   = "ec2:DescribeAddresses"
+	ec2DescribeInstances   = "ec2:DescribeInstances"
+	ec2AssociateAddress    = "ec2:AssociateAddress"
+	ec2DisassociateAddress = "ec2:DisassociateAddress"
+)
+
+// AWSAssignerPermissions are the permissions of the AWS assigner
+var AWSAssignerPermissions = []string{
+	ec2DescribeAddresses,
+	ec2DescribeInstances,
+	ec2AssociateAddress,
+	ec2DisassociateAddress,
+}
+
+// Ec2DryRunner runs EC2 actions in dry-run mode: EC2 checks the permissions without making the request
+type Ec2DryRunner interface {
+	// DryRun runs the action (ec2:<Action>) on the instance, the error reports if the action is permitted
+	DryRun(ctx context.Context, action, instanceID string) error
+}
+
+type ec2DryRunner struct {
+	client *ec2.Client
+}
+
+func NewEc2DryRunner(client *ec2.Client) Ec2DryRunner {
+	return &ec2DryRunner{client: client}
+}
+
+func (r *ec2DryRunner) DryRun(ctx context.Context, action, instanceID string) error {
+	var err error
+	switch action {
+	case ec2DescribeAddresses:
+		_, err = r.client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{DryRun: aws.Bool(true)})
+	case ec2DescribeInstances:
+		_, err = r.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{DryRun: aws.Bool(true), InstanceIds: []string{instanceID}})
+	case ec2AssociateAddress:
+		_, err = r.client.AssociateAddress(ctx, &ec2.AssociateAddressInput{
+			DryRun:       aws.Bool(true),
+			AllocationId: aws.String(dryRunAllocationID),
+			InstanceId:   aws.String(instanceID),
+		})
+	case ec2DisassociateAddress:
+		_, err = r.client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{DryRun: aws.Bool(true), AssociationId: aws.String(dryRunAssociationID)})
+	default:
+		return errors.Errorf("unsupported dry-run action %s", action)
+	}
+	return err //nolint:wrapcheck
+}
+
+// CheckAWSPermissions checks the credentials are granted the permissions on the instance with EC2 dry-run calls; missing
+// permissions are reported by name instead of failing later with opaque UnauthorizedOperation errors; permissions
+// the dry-run cannot verify (e.g. a resource-level condition on the placeholder address) are logged and skipped
+func CheckAWSPermissions(ctx context.Context, logger *logrus.Entry, runner Ec2DryRunner, instanceID string, permissions []string) error {
+	var missing []string
+	for _, permission := range permissions {
+		err := runner.DryRun(ctx, permission, instanceID)
+		var apiErr smithy.APIError
+		switch {
+		case err == nil || errors.As(err, &apiErr) && apiErr.ErrorCode() == errCodeDryRunOperation:
+			continue
+		case errors.As(err, &apiErr) && apiErr.ErrorCode() == errCodeUnauthorized:
+			missing = append(missing, permission)
+		default:
+			logger.WithError(err).WithField("permission", permission).Warn("failed to verify AWS permission, skipping")
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("missing AWS permissions: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package cloud
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDryRunner answers the dry-run calls with the error code of the action (DryRunOperation if not set)
+type fakeDryRunner struct {
+	codes map[string]string
+	calls []string
+}
+
+func (f *fakeDryRunner) DryRun(_ context.Context, action, instanceID string) error {
+	f.calls = append(f.calls, action+" "+instanceID)
+	code, ok := f.codes[action]
+	if !ok {
+		code = errCodeDryRunOperation
+	}
+	if code == "" {
+		return errors.New("connection reset")
+	}
+	return &smithy.GenericAPIError{Code: code}
+}
+
+func TestCheckAWSPermissions(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	runner := &fakeDryRunner{}
+	require.NoError(t, CheckAWSPermissions(context.Background(), logger, runner, "i-1", AWSAssignerPermissions))
+	assert.Equal(t, []string{
+		"ec2:DescribeAddresses i-1",
+		"ec2:DescribeInstances i-1",
+		"ec2:AssociateAddress i-1",
+		"ec2:DisassociateAddress i-1",
+	}, runner.calls)
+
+	runner = &fakeDryRunner{codes: map[string]string{
+		ec2AssociateAddress:    errCodeUnauthorized,
+		ec2DisassociateAddress: errCodeUnauthorized,
+	}}
+	err := CheckAWSPermissions(context.Background(), logger, runner, "i-1", AWSAssignerPermissions)
+	require.Error(t, err)
+	assert.Equal(t, "missing AWS permissions: ec2:AssociateAddress, ec2:DisassociateAddress", err.Error())
+
+	// permissions the dry-run cannot verify are skipped
+	runner = &fakeDryRunner{codes: map[string]string{
+		ec2AssociateAddress:  "InvalidAllocationID.NotFound",
+		ec2DescribeAddresses: "",
+	}}
+	assert.NoError(t, CheckAWSPermissions(context.Background(), logger, runner, "i-1", AWSAssignerPermissions))
+}
@@ -0,0 +1,54 @@
+package cloud
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pkg/errors"
+)
+
+// vpcMaxElasticIPs is the account attribute of the elastic IP quota of the region
+const vpcMaxElasticIPs = "vpc-max-elastic-ips"
+
+// EipQuotaGetter gets the elastic IP quota of the region and the elastic IPs allocated in the region
+type EipQuotaGetter interface {
+	Quota(ctx context.Context) (limit, usage int, err error)
+}
+
+type eipQuotaGetter struct {
+	client *ec2.Client
+}
+
+func NewEipQuotaGetter(client *ec2.Client) EipQuotaGetter {
+	return &eipQuotaGetter{client: client}
+}
+
+func (g *eipQuotaGetter) Quota(ctx context.Context) (int, int, error) {
+	attributes, err := g.client.DescribeAccountAttributes(ctx, &ec2.DescribeAccountAttributesInput{
+		AttributeNames: []types.AccountAttributeName{vpcMaxElasticIPs},
+	})
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to describe elastic IP quota")
+	}
+	limit := -1
+	for _, attribute := range attributes.AccountAttributes {
+		if aws.ToString(attribute.AttributeName) != vpcMaxElasticIPs || len(attribute.AttributeValues) == 0 {
+			continue
+		}
+		if limit, err = strconv.Atoi(aws.ToString(attribute.AttributeValues[0].AttributeValue)); err != nil {
+			return 0, 0, errors.Wrap(err, "invalid elastic IP quota")
+		}
+	}
+	if limit < 0 {
+		return 0, 0, errors.New("elastic IP quota not reported")
+	}
+	// all the elastic IPs of the region count, in use or not, in the pool or not
+	addresses, err := g.client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to list elastic IPs")
+	}
+	return limit, len(addresses.Addresses), nil
+}
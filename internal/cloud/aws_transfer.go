@@ -0,0 +1,82 @@
+package cloud
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pkg/errors"
+)
+
+// EipTransferrer transfers elastic IPs between accounts: the account owning the address offers it, the receiving account
+// accepts it (EC2 Elastic IP address transfer)
+type EipTransferrer interface {
+	// Offer enables the transfer of the elastic IP of the client account to the account
+	Offer(ctx context.Context, allocationID, accountID string) error
+	// Accept accepts the transfer of the elastic IP offered to the client account, tags it and returns its allocation ID
+	// in the client account
+	Accept(ctx context.Context, publicIP string, tags []types.Tag) (string, error)
+}
+
+type eipTransferrer struct {
+	client *ec2.Client
+}
+
+func NewEipTransferrer(client *ec2.Client) EipTransferrer {
+	return &eipTransferrer{client: client}
+}
+
+func (t *eipTransferrer) Offer(ctx context.Context, allocationID, accountID string) error {
+	_, err := t.client.EnableAddressTransfer(ctx, &ec2.EnableAddressTransferInput{
+		AllocationId:      aws.String(allocationID),
+		TransferAccountId: aws.String(accountID),
+	})
+	return errors.Wrap(err, "failed to enable elastic IP transfer")
+}
+
+func (t *eipTransferrer) Accept(ctx context.Context, publicIP string, tags []types.Tag) (string, error) {
+	if _, err := t.client.AcceptAddressTransfer(ctx, &ec2.AcceptAddressTransferInput{Address: aws.String(publicIP)}); err != nil {
+		return "", errors.Wrap(err, "failed to accept elastic IP transfer")
+	}
+	// the transferred elastic IP is allocated anew in the account
+	list, err := t.client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{PublicIps: []string{publicIP}})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to describe transferred elastic IP")
+	}
+	if len(list.Addresses) == 0 {
+		return "", errors.Errorf("transferred elastic IP %s not found", publicIP)
+	}
+	allocationID := aws.ToString(list.Addresses[0].AllocationId)
+	// the tags stay in the owning account: copy them so the address matches the tag filters of the pool once released
+	if len(tags) > 0 {
+		if _, err = t.client.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{allocationID}, Tags: tags}); err != nil {
+			return "", errors.Wrap(err, "failed to tag transferred elastic IP")
+		}
+	}
+	return allocationID, nil
+}
+
+// AssumeRoleConfig returns a copy of the AWS config whose credentials assume the role (a pool account role) with the
+// credentials of the config
+func AssumeRoleConfig(awsCfg aws.Config, roleARN string) (aws.Config, error) {
+	if err := validateRoleARN(roleARN, awsCfg.Region); err != nil {
+		return aws.Config{}, err
+	}
+	assumed := awsCfg.Copy()
+	assumed.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = awsRoleSessionName
+	}))
+	return assumed, nil
+}
+
+// AWSAccountID returns the account ID of the credentials of the AWS config
+func AWSAccountID(ctx context.Context, awsCfg aws.Config) (string, error) {
+	identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get AWS caller identity")
+	}
+	return aws.ToString(identity.Account), nil
+}
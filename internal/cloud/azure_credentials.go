@@ -0,0 +1,156 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azcloud "github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	AzureAuthManagedIdentity  = "managed-identity"
+	AzureAuthWorkloadIdentity = "workload-identity"
+	AzureAuthClientSecret     = "client-secret"
+)
+
+// Azure clouds of --azure-cloud
+const (
+	AzurePublicCloud  = "AzurePublicCloud"
+	AzureUSGovernment = "AzureUSGovernment"
+	AzureChinaCloud   = "AzureChinaCloud"
+)
+
+// azureClouds are the configurations of the Azure clouds: the Microsoft Entra ID authority and the Azure Resource Manager
+// endpoint and audience
+var azureClouds = map[string]azcloud.Configuration{
+	"":                azcloud.AzurePublic,
+	AzurePublicCloud:  azcloud.AzurePublic,
+	AzureUSGovernment: azcloud.AzureGovernment,
+	AzureChinaCloud:   azcloud.AzureChina,
+}
+
+// AzureCloudConfiguration returns the configuration of the Azure cloud of --azure-cloud, the public cloud by default, with
+// the Azure Resource Manager endpoint overridden by --azure-endpoint (private endpoint, sovereign cloud); the token
+// audience stays the one of the cloud
+func AzureCloudConfiguration(cfg *config.Config) (azcloud.Configuration, error) {
+	configuration, ok := azureClouds[cfg.AzureCloud]
+	if !ok {
+		return azcloud.Configuration{}, errors.Errorf("unsupported Azure cloud %s", cfg.AzureCloud)
+	}
+	if cfg.AzureEndpoint == "" {
+		return configuration, nil
+	}
+	// the configurations of the SDK are shared: the services are copied before the override
+	services := make(map[azcloud.ServiceName]azcloud.ServiceConfiguration, len(configuration.Services))
+	for name, service := range configuration.Services {
+		services[name] = service
+	}
+	resourceManager := services[azcloud.ResourceManager]
+	resourceManager.Endpoint = strings.TrimSuffix(cfg.AzureEndpoint, "/")
+	services[azcloud.ResourceManager] = resourceManager
+	configuration.Services = services
+	return configuration, nil
+}
+
+// azureResourceManager returns the Azure Resource Manager endpoint and audience of the cloud
+func azureResourceManager(configuration azcloud.Configuration) azcloud.ServiceConfiguration {
+	return configuration.Services[azcloud.ResourceManager]
+}
+
+// azureClientOptions returns the client options of the Azure credentials and clients: the cloud of --azure-cloud and the
+// transport of the cloud API clients when a proxy or a CA bundle is set, else the default one of the SDK
+func azureClientOptions(cfg *config.Config) (azcore.ClientOptions, error) {
+	var options azcore.ClientOptions
+	configuration, err := AzureCloudConfiguration(cfg)
+	if err != nil {
+		return options, err
+	}
+	options.Cloud = configuration
+	transportOptions, err := TransportOptions(cfg)
+	if err != nil || transportOptions == nil {
+		return options, err
+	}
+	transport, err := NewHTTPTransport(cfg)
+	if err != nil {
+		return options, err
+	}
+	options.Transport = &http.Client{Transport: transport}
+	return options, nil
+}
+
+// AzureARMClientOptions returns the client options of the Azure Resource Manager clients, sharing the transport of the
+// Azure credentials
+func AzureARMClientOptions(cfg *config.Config) (*arm.ClientOptions, error) {
+	options, err := azureClientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &arm.ClientOptions{ClientOptions: options}, nil
+}
+
+// NewAzureCredential returns the credential of the Azure clients configured by --azure-auth: the system or user-assigned
+// managed identity, the federated workload identity or the client secret of a service principal, read from a file (e.g.
+// a mounted Secret); without --azure-auth, the environment, workload identity and managed identity credentials are
+// tried in turn
+func NewAzureCredential(cfg *config.Config) (azcore.TokenCredential, error) {
+	options, err := azureClientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var credential azcore.TokenCredential
+	switch cfg.AzureAuth {
+	case AzureAuthManagedIdentity:
+		managedOptions := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: options}
+		// a user-assigned managed identity is selected by its client ID, the system-assigned one otherwise
+		if cfg.AzureClientID != "" {
+			managedOptions.ID = azidentity.ClientID(cfg.AzureClientID)
+		}
+		credential, err = azidentity.NewManagedIdentityCredential(managedOptions)
+	case AzureAuthWorkloadIdentity:
+		credential, err = azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions: options,
+			ClientID:      cfg.AzureClientID,
+			TenantID:      cfg.AzureTenantID,
+			TokenFilePath: cfg.AzureFederatedTokenFile,
+		})
+	case AzureAuthClientSecret:
+		secret, readErr := os.ReadFile(cfg.AzureClientSecretFile)
+		if readErr != nil {
+			return nil, errors.Wrapf(readErr, "failed to read Azure client secret file %s", cfg.AzureClientSecretFile)
+		}
+		credential, err = azidentity.NewClientSecretCredential(cfg.AzureTenantID, cfg.AzureClientID, strings.TrimSpace(string(secret)),
+			&azidentity.ClientSecretCredentialOptions{ClientOptions: options})
+	case "":
+		credential, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: options, TenantID: cfg.AzureTenantID})
+	default:
+		return nil, errors.Errorf("unsupported Azure authentication %s", cfg.AzureAuth)
+	}
+	return credential, errors.Wrapf(err, "failed to create Azure %s credential", azureAuthName(cfg.AzureAuth))
+}
+
+func azureAuthName(auth string) string {
+	if auth == "" {
+		return "default"
+	}
+	return auth
+}
+
+// azureTokenRequest is the token request of the Azure Resource Manager API of the cloud, with the scope of the ARM clients
+func azureTokenRequest(configuration azcloud.Configuration) policy.TokenRequestOptions {
+	return policy.TokenRequestOptions{Scopes: []string{azureResourceManager(configuration).Audience + "/.default"}}
+}
+
+// CheckAzureCredential requests a token of the Azure Resource Manager API of the cloud, failing at startup on credentials
+// Microsoft Entra ID rejects instead of on the first assignment
+func CheckAzureCredential(ctx context.Context, credential azcore.TokenCredential, configuration azcloud.Configuration) error {
+	_, err := credential.GetToken(ctx, azureTokenRequest(configuration))
+	return errors.Wrap(err, "failed to get an Azure Resource Manager token")
+}
@@ -0,0 +1,99 @@
+package cloud
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	azcloud "github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAzureCredential(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("secret\n"), 0o600))
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token"), 0o600))
+	const tenant, client = "00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-000000000002"
+
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "system-assigned managed identity", cfg: &config.Config{AzureAuth: AzureAuthManagedIdentity}, want: &azidentity.ManagedIdentityCredential{}},
+		{name: "user-assigned managed identity", cfg: &config.Config{AzureAuth: AzureAuthManagedIdentity, AzureClientID: client}, want: &azidentity.ManagedIdentityCredential{}},
+		{
+			name: "workload identity",
+			cfg:  &config.Config{AzureAuth: AzureAuthWorkloadIdentity, AzureTenantID: tenant, AzureClientID: client, AzureFederatedTokenFile: tokenFile},
+			want: &azidentity.WorkloadIdentityCredential{},
+		},
+		{
+			name: "client secret",
+			cfg:  &config.Config{AzureAuth: AzureAuthClientSecret, AzureTenantID: tenant, AzureClientID: client, AzureClientSecretFile: secretFile},
+			want: &azidentity.ClientSecretCredential{},
+		},
+		{
+			name:    "missing client secret file",
+			cfg:     &config.Config{AzureAuth: AzureAuthClientSecret, AzureTenantID: tenant, AzureClientID: client, AzureClientSecretFile: filepath.Join(dir, "missing")},
+			wantErr: true,
+		},
+		{name: "default", cfg: &config.Config{}, want: &azidentity.DefaultAzureCredential{}},
+		{name: "unsupported", cfg: &config.Config{AzureAuth: "certificate"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewAzureCredential(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, got)
+		})
+	}
+}
+
+func TestAzureCloudConfiguration(t *testing.T) {
+	tests := []struct {
+		cloud     string
+		endpoint  string
+		scope     string
+		authority string
+	}{
+		{cloud: "", endpoint: "https://management.azure.com", scope: "https://management.core.windows.net//.default", authority: "https://login.microsoftonline.com/"},
+		{cloud: AzureUSGovernment, endpoint: "https://management.usgovcloudapi.net", scope: "https://management.core.usgovcloudapi.net/.default", authority: "https://login.microsoftonline.us/"},
+		{cloud: AzureChinaCloud, endpoint: "https://management.chinacloudapi.cn", scope: "https://management.core.chinacloudapi.cn/.default", authority: "https://login.chinacloudapi.cn/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cloud, func(t *testing.T) {
+			cfg := &config.Config{AzureCloud: tt.cloud}
+			configuration, err := AzureCloudConfiguration(cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.endpoint, azureResourceManager(configuration).Endpoint)
+			assert.Equal(t, []string{tt.scope}, azureTokenRequest(configuration).Scopes)
+			// the credentials and the ARM clients share the cloud
+			options, err := AzureARMClientOptions(cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.authority, options.Cloud.ActiveDirectoryAuthorityHost)
+			assert.Equal(t, tt.endpoint, azureResourceManager(options.Cloud).Endpoint)
+		})
+	}
+
+	_, err := AzureCloudConfiguration(&config.Config{AzureCloud: "AzureGermanCloud"})
+	assert.EqualError(t, err, "unsupported Azure cloud AzureGermanCloud")
+}
+
+func TestAzureCloudConfiguration_endpoint(t *testing.T) {
+	configuration, err := AzureCloudConfiguration(&config.Config{AzureCloud: AzureUSGovernment, AzureEndpoint: "https://arm.example.com/"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://arm.example.com", azureResourceManager(configuration).Endpoint)
+	assert.Equal(t, []string{"https://management.core.usgovcloudapi.net/.default"}, azureTokenRequest(configuration).Scopes)
+	// the configuration of the SDK is left untouched
+	assert.Equal(t, "https://management.usgovcloudapi.net", azureResourceManager(azcloud.AzureGovernment).Endpoint)
+}
@@ -0,0 +1,153 @@
+package cloud
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+)
+
+// azureScaleSetVMType is the resource type of the instances of a uniform orchestration scale set; the instances of a
+// flexible orchestration scale set are virtual machines
+const azureScaleSetVMType = "Microsoft.Compute/virtualMachineScaleSets/virtualMachines"
+
+// AzureNetworkService is the interface of the network operations of the Azure assigner
+type AzureNetworkService interface {
+	// ListInterfaces lists the network interfaces of the virtual machine or the uniform scale set instance
+	ListInterfaces(ctx context.Context, instanceID string) ([]*armnetwork.Interface, error)
+	// ListPublicIPAddresses lists the public IP addresses of the resource group
+	ListPublicIPAddresses(ctx context.Context, subscriptionID, resourceGroup string) ([]*armnetwork.PublicIPAddress, error)
+	// UpdateInterface writes the network interface and waits for the update to complete
+	UpdateInterface(ctx context.Context, nic *armnetwork.Interface) error
+}
+
+// IsAzureScaleSetVM reports whether the resource is an instance of a uniform orchestration scale set
+func IsAzureScaleSetVM(resource *arm.ResourceID) bool {
+	return strings.EqualFold(resource.ResourceType.String(), azureScaleSetVMType)
+}
+
+type azureNetworkService struct {
+	credential azcore.TokenCredential
+	options    *arm.ClientOptions
+	mutex      sync.Mutex
+	// factories are the network clients by subscription
+	factories map[string]*armnetwork.ClientFactory
+}
+
+// NewAzureNetworkService returns the network service of the credential, creating the clients of a subscription on its
+// first use
+func NewAzureNetworkService(credential azcore.TokenCredential, options *arm.ClientOptions) AzureNetworkService {
+	return &azureNetworkService{credential: credential, options: options, factories: make(map[string]*armnetwork.ClientFactory)}
+}
+
+func (s *azureNetworkService) factory(subscriptionID string) (*armnetwork.ClientFactory, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if factory, ok := s.factories[subscriptionID]; ok {
+		return factory, nil
+	}
+	factory, err := armnetwork.NewClientFactory(subscriptionID, s.credential, s.options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Azure network clients of subscription %s", subscriptionID)
+	}
+	s.factories[subscriptionID] = factory
+	return factory, nil
+}
+
+// ListInterfaces lists the network interfaces of the instance: the instance-level network interfaces of a uniform scale
+// set instance, else the network interfaces of the resource group attached to the virtual machine, standalone or of a
+// flexible scale set
+func (s *azureNetworkService) ListInterfaces(ctx context.Context, instanceID string) ([]*armnetwork.Interface, error) {
+	resource, err := arm.ParseResourceID(instanceID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse Azure instance ID %s", instanceID)
+	}
+	factory, err := s.factory(resource.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	client := factory.NewInterfacesClient()
+	if IsAzureScaleSetVM(resource) {
+		pager := client.NewListVirtualMachineScaleSetVMNetworkInterfacesPager(resource.ResourceGroupName, resource.Parent.Name, resource.Name, nil)
+		var nics []*armnetwork.Interface
+		for page := 0; pager.More(); page++ {
+			if page == MaxListPages {
+				return nil, errors.Errorf("network interfaces of %s exceed %d pages", instanceID, MaxListPages)
+			}
+			response, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list network interfaces of scale set instance %s", instanceID)
+			}
+			metrics.ObserveListPage(string(types.CloudProviderAzure))
+			nics = append(nics, response.Value...)
+		}
+		return nics, nil
+	}
+	pager := client.NewListPager(resource.ResourceGroupName, nil)
+	var nics []*armnetwork.Interface
+	for page := 0; pager.More(); page++ {
+		if page == MaxListPages {
+			return nil, errors.Errorf("network interfaces of resource group %s exceed %d pages", resource.ResourceGroupName, MaxListPages)
+		}
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list network interfaces of resource group %s", resource.ResourceGroupName)
+		}
+		metrics.ObserveListPage(string(types.CloudProviderAzure))
+		for _, nic := range response.Value {
+			// resource IDs are case-insensitive: the provider ID of the node may not match the case of the API
+			if nic.Properties != nil && nic.Properties.VirtualMachine != nil && nic.Properties.VirtualMachine.ID != nil &&
+				strings.EqualFold(*nic.Properties.VirtualMachine.ID, instanceID) {
+				nics = append(nics, nic)
+			}
+		}
+	}
+	return nics, nil
+}
+
+func (s *azureNetworkService) ListPublicIPAddresses(ctx context.Context, subscriptionID, resourceGroup string) ([]*armnetwork.PublicIPAddress, error) {
+	factory, err := s.factory(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	pager := factory.NewPublicIPAddressesClient().NewListPager(resourceGroup, nil)
+	var addresses []*armnetwork.PublicIPAddress
+	for page := 0; pager.More(); page++ {
+		if page == MaxListPages {
+			return nil, errors.Errorf("public IP addresses of resource group %s exceed %d pages", resourceGroup, MaxListPages)
+		}
+		response, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list public IP addresses of resource group %s", resourceGroup)
+		}
+		metrics.ObserveListPage(string(types.CloudProviderAzure))
+		addresses = append(addresses, response.Value...)
+	}
+	return addresses, nil
+}
+
+func (s *azureNetworkService) UpdateInterface(ctx context.Context, nic *armnetwork.Interface) error {
+	if nic.ID == nil {
+		return errors.New("network interface without ID")
+	}
+	resource, err := arm.ParseResourceID(*nic.ID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse Azure network interface ID %s", *nic.ID)
+	}
+	factory, err := s.factory(resource.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	poller, err := factory.NewInterfacesClient().BeginCreateOrUpdate(ctx, resource.ResourceGroupName, resource.Name, *nic, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update network interface %s", resource.Name)
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return errors.Wrapf(err, "failed to wait for the update of network interface %s", resource.Name)
+}
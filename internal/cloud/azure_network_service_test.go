@@ -0,0 +1,110 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azcloud "github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAzureNetworkService returns the network service calling the Azure Resource Manager API served by the handler
+func newTestAzureNetworkService(t *testing.T, handler http.HandlerFunc) AzureNetworkService {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	return NewAzureNetworkService(fakeAzureCredential{}, &arm.ClientOptions{ClientOptions: azcore.ClientOptions{
+		Cloud: azcloud.Configuration{Services: map[azcloud.ServiceName]azcloud.ServiceConfiguration{
+			azcloud.ResourceManager: {Endpoint: server.URL, Audience: server.URL},
+		}},
+		Transport: server.Client(),
+	}})
+}
+
+func writeAzureJSON(t *testing.T, w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(t, json.NewEncoder(w).Encode(body))
+}
+
+func TestAzureNetworkService_ListInterfaces(t *testing.T) {
+	const group = "/subscriptions/sub/resourceGroups/rg"
+	nic := func(name, vm string) *armnetwork.Interface {
+		return &armnetwork.Interface{
+			ID:         to.Ptr(group + "/providers/Microsoft.Network/networkInterfaces/" + name),
+			Properties: &armnetwork.InterfacePropertiesFormat{VirtualMachine: &armnetwork.SubResource{ID: to.Ptr(vm)}},
+		}
+	}
+	flexible := group + "/providers/Microsoft.Compute/virtualMachines/vmss-flex_0"
+	tests := []struct {
+		name       string
+		instanceID string
+		path       string
+		nics       []*armnetwork.Interface
+		want       []string
+	}{
+		{
+			name:       "uniform scale set instance",
+			instanceID: group + "/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/3",
+			path:       group + "/providers/Microsoft.Compute/virtualMachineScaleSets/vmss/virtualMachines/3/networkInterfaces",
+			nics:       []*armnetwork.Interface{nic("vmss-nic", "")},
+			want:       []string{"vmss-nic"},
+		},
+		{
+			name:       "flexible scale set instance",
+			instanceID: flexible,
+			path:       group + "/providers/Microsoft.Network/networkInterfaces",
+			nics: []*armnetwork.Interface{
+				nic("vmss-flex_0-nic", "/subscriptions/sub/resourcegroups/RG/providers/Microsoft.Compute/virtualMachines/vmss-flex_0"),
+				nic("vmss-flex_1-nic", group+"/providers/Microsoft.Compute/virtualMachines/vmss-flex_1"),
+			},
+			want: []string{"vmss-flex_0-nic"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestAzureNetworkService(t, func(w http.ResponseWriter, r *http.Request) {
+				// the API paths are case-insensitive, the SDK lowercases the uniform scale set provider
+				assert.True(t, strings.EqualFold(tt.path, r.URL.Path), r.URL.Path)
+				assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+				writeAzureJSON(t, w, armnetwork.InterfaceListResult{Value: tt.nics})
+			})
+			nics, err := svc.ListInterfaces(context.Background(), tt.instanceID)
+			require.NoError(t, err)
+			var names []string
+			for _, n := range nics {
+				resource, err := arm.ParseResourceID(*n.ID)
+				require.NoError(t, err)
+				names = append(names, resource.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestAzureNetworkService_UpdateInterface(t *testing.T) {
+	id := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic-1"
+	publicIPAddress := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/publicIPAddresses/ip-a"
+	svc := newTestAzureNetworkService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, id, r.URL.Path)
+		var body armnetwork.Interface
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, publicIPAddress, *body.Properties.IPConfigurations[0].Properties.PublicIPAddress.ID)
+		writeAzureJSON(t, w, body)
+	})
+	err := svc.UpdateInterface(context.Background(), &armnetwork.Interface{
+		ID: to.Ptr(id),
+		Properties: &armnetwork.InterfacePropertiesFormat{IPConfigurations: []*armnetwork.InterfaceIPConfiguration{{
+			Properties: &armnetwork.InterfaceIPConfigurationPropertiesFormat{PublicIPAddress: &armnetwork.PublicIPAddress{ID: to.Ptr(publicIPAddress)}},
+		}}},
+	})
+	require.NoError(t, err)
+}
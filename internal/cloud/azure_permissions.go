@@ -0,0 +1,163 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	azcloud "github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const azurePermissionsAPIVersion = "2022-04-01"
+
+// AzureAssignerPermissions are the actions the role assignments of the Azure assigner grant on the resource group of the
+// virtual machines: reading the public IP addresses and the virtual machines, and joining the addresses to their network
+// interfaces
+var AzureAssignerPermissions = []string{
+	"Microsoft.Network/publicIPAddresses/read",
+	"Microsoft.Network/publicIPAddresses/join/action",
+	"Microsoft.Network/networkInterfaces/read",
+	"Microsoft.Network/networkInterfaces/write",
+	"Microsoft.Compute/virtualMachines/read",
+}
+
+// AzurePermission is an entry of the permissions granted on a scope by the role assignments of the caller
+type AzurePermission struct {
+	Actions    []string `json:"actions"`
+	NotActions []string `json:"notActions"`
+}
+
+// AzurePermissionLister lists the permissions granted on a scope by the role assignments of the caller
+type AzurePermissionLister interface {
+	ListPermissions(ctx context.Context, scope string) ([]AzurePermission, error)
+}
+
+type azurePermissionLister struct {
+	credential    azcore.TokenCredential
+	client        *http.Client
+	configuration azcloud.Configuration
+}
+
+// NewAzurePermissionLister returns a lister of the permissions of the credential, calling the Azure Resource Manager
+// permissions API of the cloud with the client
+func NewAzurePermissionLister(credential azcore.TokenCredential, client *http.Client, configuration azcloud.Configuration) AzurePermissionLister {
+	return &azurePermissionLister{credential: credential, client: client, configuration: configuration}
+}
+
+type azurePermissionPage struct {
+	Value    []AzurePermission `json:"value"`
+	NextLink string            `json:"nextLink"`
+}
+
+func (l *azurePermissionLister) ListPermissions(ctx context.Context, scope string) ([]AzurePermission, error) {
+	token, err := l.credential.GetToken(ctx, azureTokenRequest(l.configuration))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get an Azure Resource Manager token")
+	}
+	var permissions []AzurePermission
+	link := strings.TrimSuffix(azureResourceManager(l.configuration).Endpoint, "/") + scope + "/providers/Microsoft.Authorization/permissions?api-version=" + azurePermissionsAPIVersion
+	for page := 0; link != "" && page < MaxListPages; page++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, http.NoBody)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create Azure permissions request")
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		resp, err := l.client.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list Azure permissions")
+		}
+		var body azurePermissionPage
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("failed to list Azure permissions on %s: %s", scope, resp.Status)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode Azure permissions")
+		}
+		permissions = append(permissions, body.Value...)
+		link = body.NextLink
+	}
+	return permissions, nil
+}
+
+// azureActionMatches reports whether the action pattern of a role definition (e.g. Microsoft.Network/*/read) matches
+// the action; actions are case-insensitive
+func azureActionMatches(pattern, action string) bool {
+	pattern, action = strings.ToLower(pattern), strings.ToLower(action)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == action
+	}
+	if !strings.HasPrefix(action, parts[0]) {
+		return false
+	}
+	rest := action[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return strings.HasSuffix(rest, parts[len(parts)-1])
+}
+
+// azureActionGranted reports whether a permission entry grants the action: matched by its actions and not excluded by
+// its not-actions
+func azureActionGranted(permissions []AzurePermission, action string) bool {
+	for _, permission := range permissions {
+		granted := false
+		for _, pattern := range permission.Actions {
+			granted = granted || azureActionMatches(pattern, action)
+		}
+		for _, pattern := range permission.NotActions {
+			granted = granted && !azureActionMatches(pattern, action)
+		}
+		if granted {
+			return true
+		}
+	}
+	return false
+}
+
+// azureResourceGroupScope returns the resource group scope (/subscriptions/<id>/resourceGroups/<name>) of the resource
+func azureResourceGroupScope(resourceID string) (string, error) {
+	parts := strings.Split(strings.Trim(resourceID, "/"), "/")
+	if len(parts) < 4 || !strings.EqualFold(parts[0], "subscriptions") || !strings.EqualFold(parts[2], "resourceGroups") {
+		return "", errors.Errorf("invalid Azure resource ID %s, expected /subscriptions/<id>/resourceGroups/<name>/...", resourceID)
+	}
+	return "/" + strings.Join(parts[:4], "/"), nil
+}
+
+// CheckAzurePermissions checks the role assignments of the credentials grant the permissions on the resource group of
+// the virtual machine; missing permissions are reported by name instead of failing later with opaque
+// AuthorizationFailed errors; when the permissions cannot be listed (e.g. the permissions API is not reachable), the
+// check is logged and skipped
+func CheckAzurePermissions(ctx context.Context, logger *logrus.Entry, lister AzurePermissionLister, instanceID string, permissions []string) error {
+	scope, err := azureResourceGroupScope(instanceID)
+	if err != nil {
+		return err
+	}
+	granted, err := lister.ListPermissions(ctx, scope)
+	if err != nil {
+		logger.WithError(err).WithField("scope", scope).Warn("failed to verify Azure permissions, skipping")
+		return nil
+	}
+	var missing []string
+	for _, permission := range permissions {
+		if !azureActionGranted(granted, permission) {
+			missing = append(missing, permission)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("missing Azure permissions on %s: %s", scope, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	azcloud "github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const azureTestInstance = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm-1"
+
+// fakeAzureCredential returns a static token
+type fakeAzureCredential struct{}
+
+func (fakeAzureCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// fakePermissionLister returns the permissions or the error
+type fakePermissionLister struct {
+	permissions []AzurePermission
+	err         error
+	scopes      []string
+}
+
+func (f *fakePermissionLister) ListPermissions(_ context.Context, scope string) ([]AzurePermission, error) {
+	f.scopes = append(f.scopes, scope)
+	return f.permissions, f.err
+}
+
+func TestCheckAzurePermissions(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	// the Network Contributor role and the Virtual Machine reader
+	lister := &fakePermissionLister{permissions: []AzurePermission{
+		{Actions: []string{"Microsoft.Network/*"}, NotActions: []string{"Microsoft.Network/*/delete"}},
+		{Actions: []string{"microsoft.compute/virtualMachines/read"}},
+	}}
+	require.NoError(t, CheckAzurePermissions(context.Background(), logger, lister, azureTestInstance, AzureAssignerPermissions))
+	assert.Equal(t, []string{"/subscriptions/sub/resourceGroups/rg"}, lister.scopes)
+
+	// not-actions exclude the actions of their own entry only
+	lister = &fakePermissionLister{permissions: []AzurePermission{
+		{Actions: []string{"*/read"}},
+		{Actions: []string{"Microsoft.Network/networkInterfaces/*"}, NotActions: []string{"Microsoft.Network/networkInterfaces/write"}},
+	}}
+	err := CheckAzurePermissions(context.Background(), logger, lister, azureTestInstance, AzureAssignerPermissions)
+	require.Error(t, err)
+	assert.Equal(t, "missing Azure permissions on /subscriptions/sub/resourceGroups/rg: "+
+		"Microsoft.Network/networkInterfaces/write, Microsoft.Network/publicIPAddresses/join/action", err.Error())
+
+	// permissions that cannot be listed are skipped
+	lister = &fakePermissionLister{err: errors.New("connection reset")}
+	assert.NoError(t, CheckAzurePermissions(context.Background(), logger, lister, azureTestInstance, AzureAssignerPermissions))
+
+	err = CheckAzurePermissions(context.Background(), logger, lister, "vm-1", AzureAssignerPermissions)
+	assert.Error(t, err)
+}
+
+func TestAzurePermissionLister_ListPermissions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Authorization/permissions":
+			assert.Equal(t, azurePermissionsAPIVersion, r.URL.Query().Get("api-version"))
+			fmt.Fprintf(w, `{"value":[{"actions":["*/read"],"notActions":[]}],"nextLink":"http://%s/next"}`, r.Host)
+		case "/next":
+			fmt.Fprint(w, `{"value":[{"actions":["Microsoft.Network/*"],"notActions":["Microsoft.Network/*/delete"]}]}`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	lister := &azurePermissionLister{credential: fakeAzureCredential{}, client: server.Client(), configuration: azcloud.Configuration{
+		Services: map[azcloud.ServiceName]azcloud.ServiceConfiguration{azcloud.ResourceManager: {Endpoint: server.URL}},
+	}}
+	permissions, err := lister.ListPermissions(context.Background(), "/subscriptions/sub/resourceGroups/rg")
+	require.NoError(t, err)
+	assert.Equal(t, []AzurePermission{
+		{Actions: []string{"*/read"}, NotActions: []string{}},
+		{Actions: []string{"Microsoft.Network/*"}, NotActions: []string{"Microsoft.Network/*/delete"}},
+	}, permissions)
+
+	_, err = lister.ListPermissions(context.Background(), "/subscriptions/sub/resourceGroups/other")
+	assert.EqualError(t, err, "failed to list Azure permissions on /subscriptions/sub/resourceGroups/other: 403 Forbidden")
+}
+
+func Test_azureActionMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		action  string
+		want    bool
+	}{
+		{pattern: "*", action: "Microsoft.Network/publicIPAddresses/read", want: true},
+		{pattern: "Microsoft.Network/publicIPAddresses/read", action: "microsoft.network/publicipaddresses/read", want: true},
+		{pattern: "Microsoft.Network/*/read", action: "Microsoft.Network/networkInterfaces/read", want: true},
+		{pattern: "Microsoft.Network/*/read", action: "Microsoft.Network/networkInterfaces/write", want: false},
+		{pattern: "*/read", action: "Microsoft.Compute/virtualMachines/read", want: true},
+		{pattern: "Microsoft.Compute/*", action: "Microsoft.Network/networkInterfaces/read", want: false},
+		{pattern: "Microsoft.Network/publicIPAddresses/read", action: "Microsoft.Network/publicIPAddresses/join/action", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.action, func(t *testing.T) {
+			assert.Equal(t, tt.want, azureActionMatches(tt.pattern, tt.action))
+		})
+	}
+}
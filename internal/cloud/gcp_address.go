@@ -13,6 +13,8 @@ type AddressManager interface {
 	AddAccessConfig(project string, zone string, instance string, networkInterface string, fingerprint string, accessconfig *compute.AccessConfig) (*compute.Operation, error)
 	DeleteAccessConfig(project string, zone string, instance string, accessConfig string, networkInterface string, fingerprint string) (*compute.Operation, error)
 	GetAddress(project, region, name string) (*compute.Address, error)
+	// SetLabels replaces the labels of the address, rejected (412 Precondition Failed) if the fingerprint is outdated
+	SetLabels(project, region, name, fingerprint string, labels map[string]string) (*compute.Operation, error)
 }
 
 type addressManager struct {
@@ -52,3 +54,35 @@ func (m *addressManager) DeleteAccessConfig(project, zone, instance, accessConfi
 func (m *addressManager) GetAddress(project, region, name string) (*compute.Address, error) {
 	return m.client.Addresses.Get(project, region, name).Do() //nolint:wrapcheck
 }
+
+func (m *addressManager) SetLabels(project, region, name, fingerprint string, labels map[string]string) (*compute.Operation, error) {
+	return m.client.Addresses.SetLabels(project, region, name, &compute.RegionSetLabelsRequest{ //nolint:wrapcheck
+		LabelFingerprint: fingerprint,
+		Labels:           labels,
+		// send an empty map to remove the last label
+		ForceSendFields: []string{"Labels"},
+	}).Do()
+}
+
+// AliasIPRangeUpdater updates the alias IP ranges of the network interfaces of instances
+type AliasIPRangeUpdater interface {
+	// UpdateAliasIPRanges replaces the alias IP ranges of the network interface with the ranges
+	UpdateAliasIPRanges(project, zone, instance, networkInterface, fingerprint string, ranges []*compute.AliasIpRange) (*compute.Operation, error)
+}
+
+type aliasIPRangeUpdater struct {
+	client *compute.Service
+}
+
+func NewAliasIPRangeUpdater(client *compute.Service) AliasIPRangeUpdater {
+	return &aliasIPRangeUpdater{client: client}
+}
+
+func (u *aliasIPRangeUpdater) UpdateAliasIPRanges(project, zone, instance, networkInterface, fingerprint string, ranges []*compute.AliasIpRange) (*compute.Operation, error) {
+	return u.client.Instances.UpdateNetworkInterface(project, zone, instance, networkInterface, &compute.NetworkInterface{ //nolint:wrapcheck
+		Fingerprint:   fingerprint, // Required to update network interface
+		AliasIpRanges: ranges,
+		// send an empty list to remove the last alias IP range
+		ForceSendFields: []string{"AliasIpRanges"},
+	}).Do()
+}
@@ -0,0 +1,172 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+const gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// gcpCredentialTypes are the supported types of credentials files: service account key and workload identity federation
+// (external account) configuration
+var gcpCredentialTypes = map[string]bool{
+	"service_account":  true,
+	"external_account": true,
+}
+
+// GCPAssignerPermissions are the permissions of the GCP assigner on the project
+var GCPAssignerPermissions = []string{
+	"compute.addresses.get",
+	"compute.addresses.list",
+	"compute.addresses.use",
+	"compute.instances.addAccessConfig",
+	"compute.instances.deleteAccessConfig",
+	"compute.instances.get",
+	"compute.projects.get",
+	"compute.subnetworks.useExternalIp",
+	"compute.zoneOperations.get",
+}
+
+// GCPClientOptions returns the options of the Google Cloud clients: the credentials of the credentials file (service
+// account key or workload identity federation configuration) else the Application Default Credentials; with a service
+// account to impersonate, the credentials impersonate it
+func GCPClientOptions(ctx context.Context, cfg *config.Config) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	if cfg.GCPCredentialsFile != "" {
+		if err := validateGCPCredentialsFile(cfg.GCPCredentialsFile); err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithCredentialsFile(cfg.GCPCredentialsFile))
+	}
+	if cfg.GCPImpersonateServiceAccount != "" {
+		clientOpts, err := gcpHTTPClientOptions(ctx, cfg, opts)
+		if err != nil {
+			return nil, err
+		}
+		tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: cfg.GCPImpersonateServiceAccount,
+			Scopes:          []string{gcpCloudPlatformScope},
+		}, clientOpts...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to impersonate service account %s", cfg.GCPImpersonateServiceAccount)
+		}
+		opts = []option.ClientOption{option.WithTokenSource(tokenSource)}
+	}
+	return gcpHTTPClientOptions(ctx, cfg, opts)
+}
+
+// gcpHTTPClientOptions returns the options with an authenticated HTTP client over the proxy and the CA bundle if set:
+// the HTTP client replaces the transport the clients would build from the options
+func gcpHTTPClientOptions(ctx context.Context, cfg *config.Config, opts []option.ClientOption) ([]option.ClientOption, error) {
+	if cfg.ProxyURL == "" && cfg.CABundleFile == "" {
+		return opts, nil
+	}
+	base, err := NewHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := htransport.NewTransport(ctx, base, append(opts[:len(opts):len(opts)], option.WithScopes(gcpCloudPlatformScope))...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Google Cloud HTTP transport")
+	}
+	return append(opts[:len(opts):len(opts)], option.WithHTTPClient(&http.Client{Transport: transport})), nil
+}
+
+// GCPComputeOptions returns the options of the Compute Engine clients: the client options and the endpoint override
+// (Private Service Connect endpoint, emulator) if set
+func GCPComputeOptions(opts []option.ClientOption, cfg *config.Config) []option.ClientOption {
+	if cfg.GCPEndpoint == "" {
+		return opts
+	}
+	return append(opts[:len(opts):len(opts)], option.WithEndpoint(cfg.GCPEndpoint))
+}
+
+// validateGCPCredentialsFile checks the credentials file is a service account key or a workload identity federation
+// configuration, failing at startup instead of on the first call
+func validateGCPCredentialsFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read Google Cloud credentials file")
+	}
+	var credentials struct {
+		Type string `json:"type"`
+	}
+	if err = json.Unmarshal(data, &credentials); err != nil {
+		return errors.Wrapf(err, "invalid Google Cloud credentials file %s", path)
+	}
+	if !gcpCredentialTypes[credentials.Type] {
+		return errors.Errorf("unsupported Google Cloud credentials type %q in %s, expected service_account or external_account", credentials.Type, path)
+	}
+	return nil
+}
+
+type GCPPermissionTester interface {
+	// TestIamPermissions returns the permissions granted on the project among the tested permissions
+	TestIamPermissions(ctx context.Context, project string, permissions []string) ([]string, error)
+}
+
+type gcpProjectService struct {
+	service *cloudresourcemanager.Service
+}
+
+func (s *gcpProjectService) TestIamPermissions(ctx context.Context, project string, permissions []string) ([]string, error) {
+	resp, err := s.service.Projects.TestIamPermissions(project, &cloudresourcemanager.TestIamPermissionsRequest{
+		Permissions: permissions,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return resp.Permissions, nil
+}
+
+// NewGCPPermissionTester returns a tester of the project permissions with the Cloud Resource Manager API
+func NewGCPPermissionTester(ctx context.Context, opts ...option.ClientOption) (GCPPermissionTester, error) {
+	service, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Cloud Resource Manager client")
+	}
+	return &gcpProjectService{service: service}, nil
+}
+
+// CheckGCPPermissions checks the credentials are granted the permissions on the project; missing permissions are
+// reported by name instead of failing later with opaque 403 errors; a failure to test the permissions (e.g. the Cloud
+// Resource Manager API disabled) is logged and skips the check
+func CheckGCPPermissions(ctx context.Context, logger *logrus.Entry, tester GCPPermissionTester, project string, permissions []string) error {
+	granted, err := tester.TestIamPermissions(ctx, project, permissions)
+	if err != nil {
+		logger.WithError(err).WithField("project", project).Warn("failed to test Google Cloud permissions, skipping permission check")
+		return nil
+	}
+	if missing := missingPermissions(permissions, granted); len(missing) > 0 {
+		return errors.Errorf("missing Google Cloud permissions on project %s: %s", project, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// missingPermissions returns the sorted required permissions not granted
+func missingPermissions(required, granted []string) []string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, permission := range granted {
+		grantedSet[permission] = true
+	}
+	var missing []string
+	for _, permission := range required {
+		if !grantedSet[permission] {
+			missing = append(missing, permission)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
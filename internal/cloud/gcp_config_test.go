@@ -0,0 +1,108 @@
+package cloud
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func Test_validateGCPCredentialsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "service account key",
+			content: `{"type":"service_account","client_email":"kubeip@p.iam.gserviceaccount.com"}`,
+		},
+		{
+			name:    "workload identity federation",
+			content: `{"type":"external_account","audience":"//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/p/providers/eks"}`,
+		},
+		{
+			name:    "user credentials",
+			content: `{"type":"authorized_user"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			content: `not json`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			err := validateGCPCredentialsFile(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+	assert.Error(t, validateGCPCredentialsFile(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+func TestGCPClientOptions(t *testing.T) {
+	opts, err := GCPClientOptions(context.Background(), &config.Config{})
+	require.NoError(t, err)
+	assert.Empty(t, opts)
+
+	_, err = GCPClientOptions(context.Background(), &config.Config{GCPCredentialsFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
+
+type fakePermissionTester struct {
+	granted []string
+	err     error
+}
+
+func (f *fakePermissionTester) TestIamPermissions(_ context.Context, _ string, permissions []string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var granted []string
+	for _, permission := range permissions {
+		for _, g := range f.granted {
+			if g == permission {
+				granted = append(granted, permission)
+			}
+		}
+	}
+	return granted, nil
+}
+
+func Test_CheckGCPPermissions(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	required := []string{"compute.instances.get", "compute.addresses.use", "compute.addresses.list"}
+
+	err := CheckGCPPermissions(context.Background(), logger, &fakePermissionTester{granted: required}, "p", required)
+	assert.NoError(t, err)
+
+	err = CheckGCPPermissions(context.Background(), logger, &fakePermissionTester{granted: []string{"compute.instances.get"}}, "p", required)
+	require.Error(t, err)
+	assert.Equal(t, "missing Google Cloud permissions on project p: compute.addresses.list, compute.addresses.use", err.Error())
+
+	// the check is skipped when the permissions cannot be tested
+	err = CheckGCPPermissions(context.Background(), logger, &fakePermissionTester{err: errors.New("API disabled")}, "p", required)
+	assert.NoError(t, err)
+}
+
+func TestGCPComputeOptions(t *testing.T) {
+	opts := []option.ClientOption{option.WithUserAgent("kubeip")}
+	assert.Len(t, GCPComputeOptions(opts, &config.Config{}), 1)
+
+	computeOpts := GCPComputeOptions(opts, &config.Config{GCPEndpoint: "https://compute-kubeip.p.googleapis.com/compute/v1/"})
+	assert.Len(t, computeOpts, 2)
+}
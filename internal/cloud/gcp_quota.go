@@ -0,0 +1,37 @@
+package cloud
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+)
+
+// StaticAddressesQuota is the region quota of the static external addresses
+const StaticAddressesQuota = "STATIC_ADDRESSES"
+
+// RegionQuotaGetter gets a quota of the region
+type RegionQuotaGetter interface {
+	Quota(ctx context.Context, project, region, metric string) (limit, usage float64, err error)
+}
+
+type regionQuotaGetter struct {
+	client *compute.Service
+}
+
+func NewRegionQuotaGetter(client *compute.Service) RegionQuotaGetter {
+	return &regionQuotaGetter{client: client}
+}
+
+func (g *regionQuotaGetter) Quota(ctx context.Context, project, region, metric string) (float64, float64, error) {
+	r, err := g.client.Regions.Get(project, region).Context(ctx).Do()
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to get region %s", region)
+	}
+	for _, quota := range r.Quotas {
+		if quota.Metric == metric {
+			return quota.Limit, quota.Usage, nil
+		}
+	}
+	return 0, 0, errors.Errorf("quota %s of region %s not reported", metric, region)
+}
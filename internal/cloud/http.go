@@ -0,0 +1,80 @@
+package cloud
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyFunc returns the proxy of the requests: the proxy URL if set, still bypassed for the NO_PROXY hosts, else the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func ProxyFunc(cfg *config.Config) func(*http.Request) (*url.URL, error) {
+	if cfg.ProxyURL == "" {
+		return http.ProxyFromEnvironment
+	}
+	env := httpproxy.FromEnvironment()
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  cfg.ProxyURL,
+		HTTPSProxy: cfg.ProxyURL,
+		NoProxy:    env.NoProxy,
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// TransportOptions returns the options of the transports of the cloud API clients: the proxy and the CA bundle
+// (e.g. of a TLS-inspecting proxy) trusted with the system roots; nil if neither is set
+func TransportOptions(cfg *config.Config) (func(*http.Transport), error) {
+	if cfg.ProxyURL == "" && cfg.CABundleFile == "" {
+		return nil, nil //nolint:nilnil
+	}
+	if cfg.ProxyURL != "" {
+		if u, err := url.Parse(cfg.ProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("invalid proxy URL %s, expected <scheme>://<host>[:<port>]", cfg.ProxyURL)
+		}
+	}
+	var roots *x509.CertPool
+	if cfg.CABundleFile != "" {
+		pem, err := os.ReadFile(cfg.CABundleFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read CA bundle file %s", cfg.CABundleFile)
+		}
+		roots, err = x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA bundle file %s", cfg.CABundleFile)
+		}
+	}
+	proxy := ProxyFunc(cfg)
+	return func(transport *http.Transport) {
+		transport.Proxy = proxy
+		if roots != nil {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			transport.TLSClientConfig.RootCAs = roots
+		}
+	}, nil
+}
+
+// NewHTTPTransport returns a clone of the default transport with the transport options of the cloud API clients
+func NewHTTPTransport(cfg *config.Config) (*http.Transport, error) {
+	options, err := TransportOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	if options != nil {
+		options(transport)
+	}
+	return transport, nil
+}
@@ -0,0 +1,123 @@
+package cloud
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCABundle(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "proxy CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path
+}
+
+func TestTransportOptions(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o600))
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		wantNil   bool
+		wantProxy string
+		wantRoots bool
+		wantErr   bool
+	}{
+		{
+			name:    "no proxy and no CA bundle",
+			cfg:     &config.Config{},
+			wantNil: true,
+		},
+		{
+			name:      "proxy URL",
+			cfg:       &config.Config{ProxyURL: "http://proxy.internal:3128"},
+			wantProxy: "http://proxy.internal:3128",
+		},
+		{
+			name:      "CA bundle",
+			cfg:       &config.Config{CABundleFile: writeCABundle(t)},
+			wantRoots: true,
+		},
+		{
+			name:    "invalid proxy URL",
+			cfg:     &config.Config{ProxyURL: "proxy.internal"},
+			wantErr: true,
+		},
+		{
+			name:    "missing CA bundle",
+			cfg:     &config.Config{CABundleFile: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "CA bundle without certificates",
+			cfg:     &config.Config{CABundleFile: empty},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := TransportOptions(tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, options)
+				return
+			}
+			transport := &http.Transport{}
+			options(transport)
+			if tt.wantProxy != "" {
+				req, _ := http.NewRequest(http.MethodGet, "https://ec2.us-east-1.amazonaws.com", http.NoBody)
+				proxy, err := transport.Proxy(req)
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantProxy, proxy.String())
+			}
+			if tt.wantRoots {
+				require.NotNil(t, transport.TLSClientConfig)
+				assert.NotNil(t, transport.TLSClientConfig.RootCAs)
+			}
+		})
+	}
+}
+
+func TestProxyFunc_NoProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "169.254.169.254,.internal")
+	proxy := ProxyFunc(&config.Config{ProxyURL: "http://proxy.example.com:3128"})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://compute.googleapis.com", http.NoBody)
+	got, err := proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", got.String())
+
+	req, _ = http.NewRequest(http.MethodGet, "https://api.cluster.internal", http.NoBody)
+	got, err = proxy(req)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,5 @@
+package cloud
+
+// MaxListPages is the hard cap of the pages fetched by a list of addresses: past it the list fails rather than
+// picking an address from a partial inventory
+const MaxListPages = 100
@@ -3,6 +3,7 @@ package cloud
 import (
 	"context"
 
+	"github.com/doitintl/kubeip/internal/metrics"
 	"github.com/doitintl/kubeip/internal/types"
 	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/oracle/oci-go-sdk/v65/core"
@@ -34,29 +35,37 @@ func NewOCINetworkService() (OCINetworkService, error) {
 	return &ociNetworkService{client: client}, nil
 }
 
-// ListPublicIps lists all public IPs for the given request and applies the given filters.
+// ListPublicIps lists all public IPs for the given request, following the pages up to MaxListPages, and applies the
+// given filters page by page.
 func (svc *ociNetworkService) ListPublicIps(ctx context.Context, request *core.ListPublicIpsRequest, filters *types.OCIFilters) ([]core.PublicIp, error) {
-	response, err := svc.client.ListPublicIps(ctx, *request)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to list public IPs")
-	}
+	pageRequest := *request
+	list := []core.PublicIp{}
+	for page := 1; ; page++ {
+		response, err := svc.client.ListPublicIps(ctx, pageRequest)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list public IPs")
+		}
+		metrics.ObserveListPage(string(types.CloudProviderOCI))
 
-	if response.Items == nil {
-		return nil, errors.New("no public IPs found")
-	}
+		if response.Items == nil && page == 1 {
+			return nil, errors.New("no public IPs found")
+		}
 
-	// Apply filters
-	if filters != nil {
-		list := []core.PublicIp{}
+		// Apply filters
 		for _, ip := range response.Items {
-			if filters.CheckFreeformTagFilter(ip.FreeformTags) && filters.CheckDefinedTagFilter(ip.DefinedTags) {
+			if filters == nil || (filters.CheckFreeformTagFilter(ip.FreeformTags) && filters.CheckDefinedTagFilter(ip.DefinedTags)) {
 				list = append(list, ip)
 			}
 		}
-		return list, nil
-	}
 
-	return response.Items, nil
+		if response.OpcNextPage == nil || *response.OpcNextPage == "" {
+			return list, nil
+		}
+		if page == MaxListPages {
+			return nil, errors.Errorf("more than %d pages of public IPs", MaxListPages)
+		}
+		pageRequest.Page = response.OpcNextPage
+	}
 }
 
 // GetPublicIP returns the public IP with the given OCID.
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Cluster is a cluster managed by the controller
+type Cluster struct {
+	// Name names the cluster in the logs, the status and its scoping filters
+	Name string
+	// KubeConfig is the kubeconfig file of the cluster and Context its context, the current context if empty
+	KubeConfig string
+	Context    string
+	// Filters scope the addresses of the pool the nodes of the cluster get, on top of the filters of the controller
+	Filters []string
+}
+
+// ManagedClusters returns the clusters managed by the controller with their scoping filters, in the order of the
+// configuration
+func (c *Config) ManagedClusters() ([]Cluster, error) {
+	clusters := make([]Cluster, 0, len(c.Clusters))
+	index := make(map[string]int, len(c.Clusters))
+	for _, entry := range c.Clusters {
+		name, kubeconfig, ok := strings.Cut(entry, "=")
+		kubeconfig, context, _ := strings.Cut(kubeconfig, "#")
+		if !ok || name == "" || kubeconfig == "" {
+			return nil, errors.Errorf("invalid cluster %q, want <name>=<kubeconfig>[#<context>]", entry)
+		}
+		if _, ok = index[name]; ok {
+			return nil, errors.Errorf("duplicate cluster %q", name)
+		}
+		index[name] = len(clusters)
+		clusters = append(clusters, Cluster{Name: name, KubeConfig: kubeconfig, Context: context})
+	}
+	for _, entry := range c.ClusterFilters {
+		name, filter, ok := strings.Cut(entry, "=")
+		if !ok || filter == "" {
+			return nil, errors.Errorf("invalid cluster filter %q, want <name>=<filter>", entry)
+		}
+		i, ok := index[name]
+		if !ok {
+			return nil, errors.Errorf("cluster filter %q of an unknown cluster", entry)
+		}
+		clusters[i].Filters = append(clusters[i].Filters, filter)
+	}
+	return clusters, nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ManagedClusters(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		want    []Cluster
+		wantErr string
+	}{
+		{
+			name: "no cluster",
+			cfg:  &Config{},
+			want: []Cluster{},
+		},
+		{
+			name: "clusters with contexts and filters",
+			cfg: &Config{
+				Clusters:       []string{"gcp-eu=/etc/kubeip/clusters#gke-eu", "gcp-us=/etc/kubeip/gcp-us.yaml"},
+				ClusterFilters: []string{"gcp-eu=labels.cluster=gcp-eu", "gcp-us=labels.cluster=gcp-us", "gcp-eu=labels.env=prod"},
+			},
+			want: []Cluster{
+				{Name: "gcp-eu", KubeConfig: "/etc/kubeip/clusters", Context: "gke-eu", Filters: []string{"labels.cluster=gcp-eu", "labels.env=prod"}},
+				{Name: "gcp-us", KubeConfig: "/etc/kubeip/gcp-us.yaml", Filters: []string{"labels.cluster=gcp-us"}},
+			},
+		},
+		{
+			name:    "missing kubeconfig",
+			cfg:     &Config{Clusters: []string{"gcp-eu"}},
+			wantErr: `invalid cluster "gcp-eu", want <name>=<kubeconfig>[#<context>]`,
+		},
+		{
+			name:    "duplicate cluster",
+			cfg:     &Config{Clusters: []string{"gcp-eu=a.yaml", "gcp-eu=b.yaml"}},
+			wantErr: `duplicate cluster "gcp-eu"`,
+		},
+		{
+			name:    "filter of an unknown cluster",
+			cfg:     &Config{Clusters: []string{"gcp-eu=a.yaml"}, ClusterFilters: []string{"gcp-us=labels.cluster=gcp-us"}},
+			wantErr: `cluster filter "gcp-us=labels.cluster=gcp-us" of an unknown cluster`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.ManagedClusters()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
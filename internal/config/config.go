@@ -9,49 +9,453 @@ import (
 type Config struct {
 	// KubeConfigPath is the path to the kubeconfig file
 	KubeConfigPath string `json:"kubeconfig"`
+	// KubeContext is the kubeconfig context to use (current context if empty)
+	KubeContext string `json:"kube-context"`
+	// KubeAPIServer overrides the Kubernetes API server address
+	KubeAPIServer string `json:"kube-api-server"`
+	// KubeTokenFile is the path to a bearer token file used to authenticate to the Kubernetes API server
+	KubeTokenFile string `json:"kube-token-file"`
+	// KubeAs is the user impersonated by the Kubernetes client
+	KubeAs string `json:"as"`
+	// KubeAsGroups are the groups impersonated by the Kubernetes client
+	KubeAsGroups []string `json:"as-group"`
 	// NodeName is the name of the Kubernetes node
 	NodeName string `json:"node-name"`
+	// InstanceID maps the node without provider ID to its cloud instance, unless annotated with kubeip.com/instance-id
+	InstanceID string `json:"instance-id"`
+	// NodeSelector is the label selector the node must match to get a static public IP address
+	NodeSelector string `json:"node-selector"`
+	// CanaryPercent is the percentage of nodes acting on assignments, other nodes run in dry-run
+	CanaryPercent int `json:"canary-percent"`
+	// CanarySelector is the label selector of canary nodes acting on assignments
+	CanarySelector string `json:"canary-selector"`
+	// ClusterName is the name of the Kubernetes cluster (informational: logs and status)
+	ClusterName string `json:"cluster-name"`
+	// Clusters are the <name>=<kubeconfig>[#<context>] clusters managed by the controller
+	Clusters []string `json:"clusters"`
+	// ClusterFilters are the <name>=<filter> filters scoping the addresses of a cluster managed by the controller
+	ClusterFilters []string `json:"cluster-filters"`
 	// Project is the name of the GCP project or the AWS account ID or the OCI compartment OCID
 	Project string `json:"project"`
 	// Region is the name of the GCP region or the AWS region or the OCI region
 	Region string `json:"region"`
+	// AWSRegion is the AWS region, overriding Region for AWS
+	AWSRegion string `json:"aws-region"`
+	// AWSEndpoint overrides the EC2 API endpoint (VPC endpoint, LocalStack)
+	AWSEndpoint string `json:"aws-endpoint"`
+	// AWSCredentialsFile is the shared credentials file (mounted Secret) of the AWS clients, re-read on rotation
+	AWSCredentialsFile string `json:"aws-credentials-file"`
+	// AWSRoleARN is the ARN of the dedicated IAM role assumed by the AWS clients
+	AWSRoleARN string `json:"aws-role-arn"`
+	// AWSExternalID is the external ID required by the trust policy of the assumed role
+	AWSExternalID string `json:"-"`
+	// AWSAutoScalingEndpoint overrides the EC2 Auto Scaling API endpoint (VPC endpoint, LocalStack)
+	AWSAutoScalingEndpoint string `json:"aws-autoscaling-endpoint"`
+	// AWSPoolRoleARNs are the roles of the accounts of an address pool spanning accounts: their available elastic IPs
+	// are transferred to the account of the instances once it has none left
+	AWSPoolRoleARNs []string `json:"aws-pool-role-arns"`
+	// AWSIPv6Prefixes are the /80 IPv6 prefixes delegated to the primary network interface of the nodes, one per node
+	AWSIPv6Prefixes []string `json:"aws-ipv6-prefixes"`
+	// AWSSecondaryPrivateIPs are the secondary private IPs assigned to the primary network interface of the nodes, one per node
+	AWSSecondaryPrivateIPs []string `json:"aws-secondary-private-ips"`
+	// AWSLifecycleHook is the launch lifecycle hook of the Auto Scaling groups completed once the elastic IP is attached
+	AWSLifecycleHook string `json:"aws-lifecycle-hook"`
+	// AWSIMDSHopLimit is the minimum instance metadata response hop limit expected on the node instances
+	AWSIMDSHopLimit int `json:"aws-imds-hop-limit"`
+	// AWSWebIdentityTokenFile is the web identity token file (IRSA) used to assume the role
+	AWSWebIdentityTokenFile string `json:"aws-web-identity-token-file"`
+	// AzureCloud is the Azure cloud of the clients: AzurePublicCloud (default), AzureUSGovernment or AzureChinaCloud
+	AzureCloud string `json:"azure-cloud"`
+	// AzureEndpoint overrides the Azure Resource Manager endpoint of the cloud (private endpoint, sovereign cloud)
+	AzureEndpoint string `json:"azure-endpoint"`
+	// AzureAuth is the authentication of the Azure clients: managed-identity, workload-identity or client-secret (the
+	// environment, workload identity and managed identity credentials in turn if empty)
+	AzureAuth string `json:"azure-auth"`
+	// AzureTenantID and AzureClientID are the Microsoft Entra tenant and the application (client) ID of the service
+	// principal, or the client ID of the user-assigned managed identity
+	AzureTenantID string `json:"azure-tenant-id"`
+	AzureClientID string `json:"azure-client-id"`
+	// AzureClientSecretFile is the client secret file (mounted Secret) of the service principal
+	AzureClientSecretFile string `json:"azure-client-secret-file"`
+	// AzureFederatedTokenFile is the projected service account token file exchanged by the workload identity
+	AzureFederatedTokenFile string `json:"azure-federated-token-file"`
+	// GCPCredentialsFile is the Google Cloud credentials file: service account key or workload identity federation
+	// configuration (Application Default Credentials if empty)
+	GCPCredentialsFile string `json:"gcp-credentials-file"`
+	// GCPEndpoint overrides the Compute Engine API endpoint (Private Service Connect endpoint, emulator)
+	GCPEndpoint string `json:"gcp-endpoint"`
+	// GCPImpersonateServiceAccount is the email of the service account impersonated by the Google Cloud clients
+	GCPImpersonateServiceAccount string `json:"gcp-impersonate-service-account"`
+	// GCPPrivateNodes is the policy of the nodes without external access config: add an access config or refuse
+	GCPPrivateNodes string `json:"gcp-private-nodes"`
+	// GCPAliasIPRanges are the alias IP ranges attached to the network interface of the nodes, one per node
+	GCPAliasIPRanges []string `json:"gcp-alias-ip-ranges"`
+	// GCPAddressLabels marks the static addresses with the node holding them and the time of the assignment
+	GCPAddressLabels bool `json:"gcp-address-labels"`
+	// ProxyURL is the proxy of the cloud API and Kubernetes API requests (HTTP_PROXY and HTTPS_PROXY if empty)
+	ProxyURL string `json:"proxy-url"`
+	// CABundleFile is the CA bundle trusted by the cloud API clients in addition to the system roots
+	CABundleFile string `json:"ca-bundle-file"`
 	// IPv6 support
 	IPv6 bool `json:"ipv6"`
 	// DevelopMode mode
 	DevelopMode bool `json:"develop-mode"`
+	// DevelopAddresses are the simulated static public IP addresses (IPs or CIDRs) of the develop mode
+	DevelopAddresses []string `json:"develop-addresses"`
+	// DevelopLatency is the simulated latency of the cloud provider calls in develop mode
+	DevelopLatency time.Duration `json:"develop-latency"`
+	// DevelopFailureRate is the simulated failure rate (0 to 1) of the cloud provider calls in develop mode
+	DevelopFailureRate float64 `json:"develop-failure-rate"`
+	// ChaosErrorRate is the rate (0 to 1) of the cloud provider calls failing with an injected error (chaos mode)
+	ChaosErrorRate float64 `json:"chaos-error-rate"`
+	// ChaosMaxDelay is the maximum random delay injected into the cloud provider calls (chaos mode)
+	ChaosMaxDelay time.Duration `json:"chaos-max-delay"`
+	// ChaosStaleRate is the rate (0 to 1) of the candidate reads returning the previous candidate (chaos mode)
+	ChaosStaleRate float64 `json:"chaos-stale-rate"`
 	// Filter is the filter for the IP addresses
 	Filter []string `json:"filter"`
+	// FilterLogic is the combination of the filters: and (all the filters match) or or (any filter matches)
+	FilterLogic string `json:"filter-logic"`
+	// NameRegex is the pattern of the names of the candidate addresses, in addition to the filters
+	NameRegex string `json:"name-regex"`
+	// ProviderFilters are the filters of the nodes of a cloud provider, <provider>=<filter>, used instead of Filter
+	ProviderFilters []string `json:"provider-filters"`
 	// OrderBy is the order by for the IP addresses
 	OrderBy string `json:"order-by"`
+	// NonPoolAddress is the policy of a static public IP address held by the node outside the pool: keep, replace or fail
+	NonPoolAddress string `json:"non-pool-address"`
+	// PermissionCheck checks the cloud permissions of the credentials at startup
+	PermissionCheck bool `json:"permission-check"`
+	// QuotaCheck checks the cloud provider quota of the static public IP addresses at startup
+	QuotaCheck bool `json:"quota-check"`
 	// Retry interval
 	RetryInterval time.Duration `json:"retry-interval"`
 	// Retry attempts
 	RetryAttempts int `json:"retry-attempts"`
+	// DrainTimeout is the time an in-flight cloud mutation may take to complete once the agent is shutting down
+	DrainTimeout time.Duration `json:"drain-timeout"`
 	// ReleaseOnExit releases the IP address on exit
 	ReleaseOnExit bool `json:"release-on-exit"`
+	// VerifyURL is the echo endpoint verifying the traffic of the node egresses from the assigned address
+	VerifyURL string `json:"verify-url"`
+	// VerifyTimeout is the time the verification waits for the traffic to egress from the assigned address
+	VerifyTimeout time.Duration `json:"verify-timeout"`
+	// WatchdogInterval is the interval of the connectivity watchdog checks (disabled if 0)
+	WatchdogInterval time.Duration `json:"watchdog-interval"`
+	// WatchdogURL is the echo endpoint of the connectivity watchdog, the verification endpoint by default
+	WatchdogURL string `json:"watchdog-url"`
+	// WatchdogReconcile reconciles the assignment when the watchdog detects the egress drift
+	WatchdogReconcile bool `json:"watchdog-reconcile"`
+	// ReleaseBeforeDrain releases the IP address once a termination handler taints the node before draining it
+	ReleaseBeforeDrain bool `json:"release-before-drain"`
+	// DrainTaints are the taint keys announcing the drain of the node in addition to those of the common termination handlers
+	DrainTaints []string `json:"drain-taints"`
+	// ReleaseIgnored releases the IP address held by a node with the ignore annotation
+	ReleaseIgnored bool `json:"release-ignored"`
 	// LeaseDuration is the duration of the kubernetes lease
 	LeaseDuration int `json:"lease-duration"`
 	// LeaseNamespace is the namespace of the kubernetes lease
 	LeaseNamespace string `json:"lease-namespace"`
+	// PoolTenants associate node pools with tenants: <pool>=<tenant>
+	PoolTenants []string `json:"pool-tenants"`
+	// AddressPool is the name of the address pool of the filters, labeling the metrics; a template over the node
+	AddressPool string `json:"address-pool"`
+	// Claims honors the KubeIPClaim resources reserving addresses for nodes before the pool selection
+	Claims bool `json:"claims"`
+	// KarpenterNodePools are the Karpenter NodePools whose launched instances get an address before their node registers
+	KarpenterNodePools []string `json:"karpenter-nodepools"`
+	// HandoffLabel is the label of the node role: a replacement node claims the address of the cordoned or deleted node
+	// of the same role (hand-off disabled if empty)
+	HandoffLabel string `json:"handoff-label"`
+	// HandoffTimeout is the time a replacement node waits for the replaced node to release its address
+	HandoffTimeout time.Duration `json:"handoff-timeout"`
+	// AddressAffinity gives a node the address last assigned to its slot (pool and slot annotation or instance group and
+	// index of the node name) if free
+	AddressAffinity bool `json:"address-affinity"`
+	// UnsupportedProvider is the policy of the nodes of an unsupported cloud provider: fail (exit) or ignore (idle)
+	UnsupportedProvider string `json:"unsupported-provider"`
+	// WaitForConditions are the node conditions, <type>[=<status>], the node reports before the assignment
+	WaitForConditions []string `json:"wait-for-conditions"`
+	// WaitForLabels are the node labels, <key>[=<value>], the node carries before the assignment
+	WaitForLabels []string `json:"wait-for-labels"`
+	// WaitForNodeTimeout is the time the assignment waits for the node conditions and labels before going ahead
+	WaitForNodeTimeout time.Duration `json:"wait-for-node-timeout"`
+	// NodeResyncInterval is the interval of the checks of the node besides the changes it is watched for
+	NodeResyncInterval time.Duration `json:"node-resync-interval"`
+	// StartupJitter is the maximum random delay before the agent starts calling the cloud provider
+	StartupJitter time.Duration `json:"startup-jitter"`
+	// RolloutPacing is the minimum time an agent assigning a new address holds the lock
+	RolloutPacing time.Duration `json:"rollout-pacing"`
+	// MaintenanceWindows restrict reassignments (address swaps) to cron-like time windows
+	MaintenanceWindows []string `json:"maintenance-windows"`
+	// DNSProvider is the DNS provider keeping node records in sync with assigned addresses (disabled if empty)
+	DNSProvider string `json:"dns-provider"`
+	// DNSZone is the DNS zone managing the node records
+	DNSZone string `json:"dns-zone"`
+	// DNSDomain is the domain of the node records: <node>.<domain>
+	DNSDomain string `json:"dns-domain"`
+	// DNSNamespace is the namespace of the external-dns DNSEndpoint resources
+	DNSNamespace string `json:"dns-namespace"`
+	// DNSAPIToken is the API token of the DNS provider (Cloudflare)
+	DNSAPIToken string `json:"-"`
+	// DNSTTL is the TTL of the node records in seconds
+	DNSTTL int `json:"dns-ttl"`
+	// IPAMProvider is the IPAM system recording the assigned addresses (disabled if empty)
+	IPAMProvider string `json:"ipam-provider"`
+	// IPAMURL is the base URL of the IPAM API
+	IPAMURL string `json:"ipam-url"`
+	// IPAMToken is the API token of the IPAM system
+	IPAMToken string `json:"-"`
+	// IPAMUsername is the username of the IPAM API (basic authentication)
+	IPAMUsername string `json:"ipam-username"`
+	// IPAMPassword is the password of the IPAM API (basic authentication)
+	IPAMPassword string `json:"-"`
+	// IPAMCAFile is the CA certificate file verifying the IPAM API server certificate
+	IPAMCAFile string `json:"ipam-ca-file"`
+	// IPAMInsecureSkipVerify disables the IPAM API server certificate verification
+	IPAMInsecureSkipVerify bool `json:"ipam-insecure-skip-verify"`
+	// IPAMDomain is the domain of the node host records in the IPAM system: <node>.<domain>
+	IPAMDomain string `json:"ipam-domain"`
+	// IPAMInfobloxRecord is the Infoblox object recording the address: fixedaddress or host
+	IPAMInfobloxRecord string `json:"ipam-infoblox-record"`
+	// IPAMExtAttrs are the extensible attributes (name=template) of the Infoblox objects
+	IPAMExtAttrs []string `json:"ipam-extattrs"`
+	// IPAMSubnetID is the phpIPAM subnet ID where missing addresses are created
+	IPAMSubnetID string `json:"ipam-subnet-id"`
+	// IPAMInterface is the name of the node interface the address is assigned to in the IPAM system
+	IPAMInterface string `json:"ipam-interface"`
+	// FirewallProvider is the firewall resource trusting the assigned addresses: gcp-firewall, aws-security-group or aws-prefix-list (empty disables)
+	FirewallProvider string `json:"firewall-provider"`
+	// FirewallName is the GCP firewall rule name, the AWS security group ID or the AWS managed prefix list ID
+	FirewallName string `json:"firewall-name"`
+	// SNATBackend programs the SNAT rules of the pod egress traffic on the node: iptables or nftables (empty disables)
+	SNATBackend string `json:"snat-backend"`
+	// SNATSourceCIDRs are the CIDRs of the pod egress traffic translated to the assigned address
+	SNATSourceCIDRs []string `json:"snat-source-cidrs"`
+	// SNATExcludeCIDRs are the destinations not translated, e.g. the cluster and VPC CIDRs
+	SNATExcludeCIDRs []string `json:"snat-exclude-cidrs"`
+	// SNATInterface is the output interface of the translated traffic (any if empty)
+	SNATInterface string `json:"snat-interface"`
+	// GARPInterface is the interface sending gratuitous ARP / unsolicited neighbor advertisements of the address bound to the node (empty disables)
+	GARPInterface string `json:"garp-interface"`
+	// GARPCount is the number of gratuitous announcements sent after the assignment
+	GARPCount int `json:"garp-count"`
+	// PolicyRoutes are the policy routing entries (route or rule followed by the ip arguments) added with the assigned address
+	PolicyRoutes []string `json:"policy-routes"`
+	// EgressGatewayLabels labels the node holding the address as egress gateway (Cilium or Calico egress gateway)
+	EgressGatewayLabels bool `json:"egress-gateway-labels"`
+	// ReadinessGate sets the kubeip.com/static-ip readiness gate condition of the pods of the node declaring it
+	ReadinessGate bool `json:"readiness-gate"`
+	// NodeCondition sets the kubeip.com/StaticIPAssigned condition of the node
+	NodeCondition bool `json:"node-condition"`
+	// MetalLBAddresses are the addresses (IPs or CIDRs) claimed for bare metal nodes and announced with MetalLB;
+	// enables the MetalLB mode instead of cloud provider calls
+	MetalLBAddresses []string `json:"metallb-addresses"`
+	// MetalLBNamespace is the namespace of the MetalLB resources
+	MetalLBNamespace string `json:"metallb-namespace"`
+	// BGPAddresses are the addresses (IPs or CIDRs) claimed for bare metal nodes and announced over BGP by the gobgpd
+	// daemon of the node; enables the BGP mode instead of cloud provider calls
+	BGPAddresses []string `json:"bgp-addresses"`
+	// BGPAPIAddress is the gRPC API address (host:port) of the gobgpd daemon of the node
+	BGPAPIAddress string `json:"bgp-api-address"`
+	// BGPNextHop is the next hop of the announced routes; the gobgpd default if empty
+	BGPNextHop string `json:"bgp-next-hop"`
+	// EventsProvider is the source of cloud change notifications triggering an immediate reassignment when an external
+	// actor changes the address: aws-eventbridge or gcp-pubsub (empty disables)
+	EventsProvider string `json:"events-provider"`
+	// EventsSource is the SQS queue URL (EventBridge rule target) or the Pub/Sub subscription (audit log sink topic)
+	EventsSource string `json:"events-source"`
+	// SinkProvider is the event sink streaming assignment lifecycle events: kafka-rest-proxy, pubsub or webhook (empty disables)
+	SinkProvider string `json:"sink-provider"`
+	// SinkURL is the URL of the event sink (Kafka REST Proxy or webhook)
+	SinkURL string `json:"sink-url"`
+	// SinkTopic is the topic of the events (Kafka topic or Pub/Sub topic projects/<project>/topics/<name>)
+	SinkTopic string `json:"sink-topic"`
+	// SinkTemplate is the Go template of the webhook payload rendered over the event (default: JSON event)
+	SinkTemplate string `json:"sink-template"`
+	// SinkTemplateFile is the file of the Go template of the webhook payload
+	SinkTemplateFile string `json:"sink-template-file"`
+	// SinkHeaders are the "Name: value" headers of the webhook requests (may contain credentials)
+	SinkHeaders []string `json:"-"`
 	// TaintKey is the taint key to remove from the node once the IP address is assigned
 	TaintKey string `json:"taint-key"`
+	// MetricsAddress is the listen address of the Prometheus metrics endpoint /metrics (empty disables)
+	MetricsAddress string `json:"metrics-address"`
+	// MetricsTLSCertFile and MetricsTLSKeyFile are the certificate and key serving the metrics over TLS
+	MetricsTLSCertFile string `json:"metrics-tls-cert-file"`
+	MetricsTLSKeyFile  string `json:"metrics-tls-key-file"`
+	// MetricsTLSClientCAFile is the CA verifying the client certificates required to scrape the metrics (mutual TLS)
+	MetricsTLSClientCAFile string `json:"metrics-tls-client-ca-file"`
+	// HealthAddress is the listen address of the liveness /healthz and readiness /readyz probes (empty disables)
+	HealthAddress string `json:"health-address"`
+	// HealthTLSCertFile and HealthTLSKeyFile are the certificate and key serving the health probes over TLS
+	HealthTLSCertFile string `json:"health-tls-cert-file"`
+	HealthTLSKeyFile  string `json:"health-tls-key-file"`
+	// HealthTLSClientCAFile is the CA verifying the client certificates required to query the health probes (mutual TLS)
+	HealthTLSClientCAFile string `json:"health-tls-client-ca-file"`
+	// AdminAddress is the listen address of the admin API (empty disables)
+	AdminAddress string `json:"admin-address"`
+	// AdminTokenFile is the file of the bearer token authenticating the admin API requests
+	AdminTokenFile string `json:"admin-token-file"`
+	// AdminDashboard serves the web dashboard of the assignments at /ui/ of the admin API
+	AdminDashboard bool `json:"admin-dashboard"`
+	// AdminTLSCertFile and AdminTLSKeyFile are the certificate and key serving the admin API over TLS
+	AdminTLSCertFile string `json:"admin-tls-cert-file"`
+	AdminTLSKeyFile  string `json:"admin-tls-key-file"`
+	// AdminTLSClientCAFile is the CA verifying the client certificates required by the admin API (mutual TLS)
+	AdminTLSClientCAFile string `json:"admin-tls-client-ca-file"`
 }
 
 func NewConfig(c *cli.Context) *Config {
 	var cfg Config
 	cfg.KubeConfigPath = c.String("kubeconfig")
+	cfg.KubeContext = c.String("kube-context")
+	cfg.KubeAPIServer = c.String("kube-api-server")
+	cfg.KubeTokenFile = c.String("kube-token-file")
+	cfg.KubeAs = c.String("as")
+	cfg.KubeAsGroups = c.StringSlice("as-group")
 	cfg.NodeName = c.String("node-name")
+	cfg.InstanceID = c.String("instance-id")
+	cfg.NodeSelector = c.String("node-selector")
+	cfg.CanaryPercent = c.Int("canary-percent")
+	cfg.CanarySelector = c.String("canary-selector")
+	cfg.ClusterName = c.String("cluster-name")
+	cfg.Clusters = c.StringSlice("cluster")
+	cfg.ClusterFilters = c.StringSlice("cluster-filter")
 	cfg.DevelopMode = c.Bool("develop-mode")
+	cfg.DevelopAddresses = c.StringSlice("develop-addresses")
+	cfg.DevelopLatency = c.Duration("develop-latency")
+	cfg.DevelopFailureRate = c.Float64("develop-failure-rate")
 	cfg.RetryInterval = c.Duration("retry-interval")
+	cfg.PoolTenants = c.StringSlice("pool-tenant")
+	cfg.AddressPool = c.String("address-pool")
+	cfg.Claims = c.Bool("claims")
+	cfg.KarpenterNodePools = c.StringSlice("karpenter-nodepool")
+	cfg.HandoffLabel = c.String("handoff-label")
+	cfg.HandoffTimeout = c.Duration("handoff-timeout")
+	cfg.AddressAffinity = c.Bool("address-affinity")
 	cfg.RetryAttempts = c.Int("retry-attempts")
+	cfg.DrainTimeout = c.Duration("drain-timeout")
+	cfg.PermissionCheck = c.Bool("permission-check")
+	cfg.QuotaCheck = c.Bool("quota-check")
+	cfg.ChaosErrorRate = c.Float64("chaos-error-rate")
+	cfg.ChaosMaxDelay = c.Duration("chaos-max-delay")
+	cfg.ChaosStaleRate = c.Float64("chaos-stale-rate")
 	cfg.Filter = c.StringSlice("filter")
+	cfg.FilterLogic = c.String("filter-logic")
+	cfg.NameRegex = c.String("name-regex")
+	cfg.ProviderFilters = c.StringSlice("provider-filter")
 	cfg.OrderBy = c.String("order-by")
+	cfg.NonPoolAddress = c.String("non-pool-address")
 	cfg.Project = c.String("project")
 	cfg.Region = c.String("region")
+	cfg.AWSRegion = c.String("aws-region")
+	cfg.AWSEndpoint = c.String("aws-endpoint")
+	cfg.AWSCredentialsFile = c.String("aws-credentials-file")
+	cfg.AzureCloud = c.String("azure-cloud")
+	cfg.AzureEndpoint = c.String("azure-endpoint")
+	cfg.AzureAuth = c.String("azure-auth")
+	cfg.AzureTenantID = c.String("azure-tenant-id")
+	cfg.AzureClientID = c.String("azure-client-id")
+	cfg.AzureClientSecretFile = c.String("azure-client-secret-file")
+	cfg.AzureFederatedTokenFile = c.String("azure-federated-token-file")
+	cfg.AWSRoleARN = c.String("aws-role-arn")
+	cfg.AWSExternalID = c.String("aws-external-id")
+	cfg.AWSWebIdentityTokenFile = c.String("aws-web-identity-token-file")
+	cfg.AWSIMDSHopLimit = c.Int("aws-imds-hop-limit")
+	cfg.AWSLifecycleHook = c.String("aws-lifecycle-hook")
+	cfg.AWSPoolRoleARNs = c.StringSlice("aws-pool-role-arn")
+	cfg.AWSIPv6Prefixes = c.StringSlice("aws-ipv6-prefix")
+	cfg.AWSSecondaryPrivateIPs = c.StringSlice("aws-secondary-private-ip")
+	cfg.AWSAutoScalingEndpoint = c.String("aws-autoscaling-endpoint")
+	cfg.GCPCredentialsFile = c.String("gcp-credentials-file")
+	cfg.GCPEndpoint = c.String("gcp-endpoint")
+	cfg.GCPImpersonateServiceAccount = c.String("gcp-impersonate-service-account")
+	cfg.GCPAliasIPRanges = c.StringSlice("gcp-alias-ip-range")
+	cfg.GCPPrivateNodes = c.String("gcp-private-nodes")
+	cfg.GCPAddressLabels = c.Bool("gcp-address-labels")
+	cfg.ProxyURL = c.String("proxy-url")
+	cfg.CABundleFile = c.String("ca-bundle-file")
 	cfg.IPv6 = c.Bool("ipv6")
 	cfg.ReleaseOnExit = c.Bool("release-on-exit")
+	cfg.ReleaseBeforeDrain = c.Bool("release-before-drain")
+	cfg.VerifyURL = c.String("verify-url")
+	cfg.VerifyTimeout = c.Duration("verify-timeout")
+	cfg.WatchdogInterval = c.Duration("watchdog-interval")
+	cfg.WatchdogURL = c.String("watchdog-url")
+	if cfg.WatchdogURL == "" {
+		cfg.WatchdogURL = cfg.VerifyURL
+	}
+	cfg.WatchdogReconcile = c.Bool("watchdog-reconcile")
+	cfg.DrainTaints = c.StringSlice("drain-taint")
+	cfg.ReleaseIgnored = c.Bool("release-ignored")
 	cfg.LeaseDuration = c.Int("lease-duration")
 	cfg.LeaseNamespace = c.String("lease-namespace")
+	cfg.MaintenanceWindows = c.StringSlice("maintenance-window")
+	cfg.DNSProvider = c.String("dns-provider")
+	cfg.DNSZone = c.String("dns-zone")
+	cfg.DNSDomain = c.String("dns-domain")
+	cfg.DNSNamespace = c.String("dns-namespace")
+	cfg.DNSAPIToken = c.String("dns-api-token")
+	cfg.DNSTTL = c.Int("dns-ttl")
+	cfg.IPAMProvider = c.String("ipam-provider")
+	cfg.IPAMURL = c.String("ipam-url")
+	cfg.IPAMToken = c.String("ipam-token")
+	cfg.IPAMUsername = c.String("ipam-username")
+	cfg.IPAMPassword = c.String("ipam-password")
+	cfg.IPAMCAFile = c.String("ipam-ca-file")
+	cfg.IPAMInsecureSkipVerify = c.Bool("ipam-insecure-skip-verify")
+	cfg.IPAMDomain = c.String("ipam-domain")
+	cfg.IPAMInfobloxRecord = c.String("ipam-infoblox-record")
+	cfg.IPAMExtAttrs = c.StringSlice("ipam-extattr")
+	cfg.IPAMSubnetID = c.String("ipam-subnet-id")
+	cfg.IPAMInterface = c.String("ipam-interface")
+	cfg.FirewallProvider = c.String("firewall-provider")
+	cfg.FirewallName = c.String("firewall-name")
+	cfg.SNATBackend = c.String("snat-backend")
+	cfg.SNATSourceCIDRs = c.StringSlice("snat-source-cidr")
+	cfg.SNATExcludeCIDRs = c.StringSlice("snat-exclude-cidr")
+	cfg.SNATInterface = c.String("snat-interface")
+	cfg.GARPInterface = c.String("garp-interface")
+	cfg.GARPCount = c.Int("garp-count")
+	cfg.PolicyRoutes = c.StringSlice("policy-route")
+	cfg.EgressGatewayLabels = c.Bool("egress-gateway-labels")
+	cfg.ReadinessGate = c.Bool("readiness-gate")
+	cfg.NodeCondition = c.Bool("node-condition")
+	cfg.MetalLBAddresses = c.StringSlice("metallb-addresses")
+	cfg.MetalLBNamespace = c.String("metallb-namespace")
+	cfg.BGPAddresses = c.StringSlice("bgp-addresses")
+	cfg.BGPAPIAddress = c.String("bgp-api-address")
+	cfg.BGPNextHop = c.String("bgp-next-hop")
+	cfg.EventsProvider = c.String("events-provider")
+	cfg.EventsSource = c.String("events-source")
+	cfg.SinkProvider = c.String("sink-provider")
+	cfg.SinkURL = c.String("sink-url")
+	cfg.SinkTopic = c.String("sink-topic")
+	cfg.SinkTemplate = c.String("sink-template")
+	cfg.SinkTemplateFile = c.String("sink-template-file")
+	cfg.SinkHeaders = c.StringSlice("sink-header")
 	cfg.TaintKey = c.String("taint-key")
+	cfg.UnsupportedProvider = c.String("unsupported-provider")
+	cfg.WaitForConditions = c.StringSlice("wait-for-condition")
+	cfg.WaitForLabels = c.StringSlice("wait-for-label")
+	cfg.WaitForNodeTimeout = c.Duration("wait-for-node-timeout")
+	cfg.NodeResyncInterval = c.Duration("node-resync-interval")
+	cfg.StartupJitter = c.Duration("startup-jitter")
+	cfg.RolloutPacing = c.Duration("rollout-pacing")
+	cfg.MetricsAddress = c.String("metrics-address")
+	cfg.MetricsTLSCertFile = c.String("metrics-tls-cert-file")
+	cfg.MetricsTLSKeyFile = c.String("metrics-tls-key-file")
+	cfg.MetricsTLSClientCAFile = c.String("metrics-tls-client-ca-file")
+	cfg.HealthAddress = c.String("health-address")
+	cfg.HealthTLSCertFile = c.String("health-tls-cert-file")
+	cfg.HealthTLSKeyFile = c.String("health-tls-key-file")
+	cfg.HealthTLSClientCAFile = c.String("health-tls-client-ca-file")
+	cfg.AdminAddress = c.String("admin-address")
+	cfg.AdminTokenFile = c.String("admin-token-file")
+	cfg.AdminDashboard = c.Bool("admin-dashboard")
+	cfg.AdminTLSCertFile = c.String("admin-tls-cert-file")
+	cfg.AdminTLSKeyFile = c.String("admin-tls-key-file")
+	cfg.AdminTLSClientCAFile = c.String("admin-tls-client-ca-file")
 	return &cfg
 }
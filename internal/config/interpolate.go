@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// filePrefix marks a value read from a file, e.g. a token of a mounted Secret: file:/var/run/secrets/kubeip/token
+const filePrefix = "file:"
+
+// Interpolate resolves the string values of the configuration generated at deploy time: ${NAME} is replaced by the
+// environment variable NAME ($$ is a literal $) and a value file:<path> by the content of the file, without its
+// trailing newline; all the unresolved values are reported, by field name only, as values may hold credentials
+func (c *Config) Interpolate() error {
+	v := &validator{}
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		name := fieldName(value.Type().Field(i))
+		switch field.Kind() { //nolint:exhaustive
+		case reflect.String:
+			resolved, err := interpolate(field.String())
+			if err != nil {
+				v.check(false, "%s: %v", name, err)
+				continue
+			}
+			field.SetString(resolved)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			for j := 0; j < field.Len(); j++ {
+				resolved, err := interpolate(field.Index(j).String())
+				if err != nil {
+					v.check(false, "%s: %v", name, err)
+					continue
+				}
+				field.Index(j).SetString(resolved)
+			}
+		}
+	}
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// fieldName returns the name of the field in the problems: its JSON name, or its Go name for the unexported credentials
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+func interpolate(value string) (string, error) {
+	if path, ok := strings.CutPrefix(value, filePrefix); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read %s", path)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case strings.HasPrefix(value[i:], "$$"):
+			b.WriteByte('$')
+			i++
+		case strings.HasPrefix(value[i:], "${"):
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				return "", errors.Errorf("unterminated ${ at offset %d", i)
+			}
+			name := value[i+2 : i+end]
+			env, ok := os.LookupEnv(name)
+			if !ok || name == "" {
+				return "", errors.Errorf("environment variable %q is not set", name)
+			}
+			b.WriteString(env)
+			i += end
+		default:
+			// $NAME is kept as is: AWS filters and payload templates may hold a $
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String(), nil
+}
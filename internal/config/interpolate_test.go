@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	token := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(token, []byte("s3cr3t\n"), 0o600))
+	t.Setenv("KUBEIP_TEST_CLUSTER", "prod-eu")
+
+	cfg := &Config{
+		ClusterName:  "${KUBEIP_TEST_CLUSTER}",
+		Filter:       []string{"labels.cluster=${KUBEIP_TEST_CLUSTER}", "labels.env=prod"},
+		DNSAPIToken:  "file:" + token,
+		SinkTemplate: `{"cost": "$${{.Address}}", "node": "$NODE"}`,
+	}
+	require.NoError(t, cfg.Interpolate())
+	assert.Equal(t, "prod-eu", cfg.ClusterName)
+	assert.Equal(t, []string{"labels.cluster=prod-eu", "labels.env=prod"}, cfg.Filter)
+	assert.Equal(t, "s3cr3t", cfg.DNSAPIToken)
+	assert.Equal(t, `{"cost": "${{.Address}}", "node": "$NODE"}`, cfg.SinkTemplate)
+}
+
+func TestInterpolate_problems(t *testing.T) {
+	cfg := &Config{
+		ClusterName: "${KUBEIP_TEST_UNSET}",
+		IPAMToken:   "file:" + filepath.Join(t.TempDir(), "missing"),
+		Filter:      []string{"labels.cluster=${KUBEIP_TEST_CLUSTER"},
+	}
+	err := cfg.Interpolate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid), "validation error, got %v", err)
+	require.Len(t, invalid.Problems, 3)
+	assert.Equal(t, `cluster-name: environment variable "KUBEIP_TEST_UNSET" is not set`, invalid.Problems[0])
+	assert.Contains(t, invalid.Problems[1], "filter: unterminated ${")
+	assert.Contains(t, invalid.Problems[2], "IPAMToken: failed to read")
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// ErrUnknownProfile is returned for a profile that is not built in
+var ErrUnknownProfile = errors.New("unknown configuration profile")
+
+// Profiles are the built-in configuration profiles of the managed Kubernetes services: flag values pre-set for the
+// service, overridden by the flags and environment variables set explicitly; the pool is the addresses labeled (GCP) or
+// tagged (AWS) kubeip=reserved
+var Profiles = map[string]map[string]string{
+	"gke-default": {
+		"filter":           "labels.kubeip=reserved",
+		"order-by":         "name",
+		"retry-interval":   "30s",
+		"retry-attempts":   "120",
+		"drain-timeout":    "20s",
+		"release-on-exit":  "true",
+		"non-pool-address": "keep",
+	},
+	"eks-default": {
+		"filter":           "Name=tag:kubeip,Values=reserved",
+		"order-by":         "PublicIp",
+		"retry-interval":   "30s",
+		"retry-attempts":   "120",
+		"drain-timeout":    "20s",
+		"release-on-exit":  "true",
+		"non-pool-address": "keep",
+	},
+	// the Azure assigner has no pool check: a static public IP address outside the pool is replaced
+	"aks-default": {
+		"filter":          "tags.kubeip=reserved",
+		"order-by":        "name",
+		"retry-interval":  "30s",
+		"retry-attempts":  "120",
+		"drain-timeout":   "20s",
+		"release-on-exit": "true",
+	},
+}
+
+// ProfileNames returns the names of the built-in profiles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile sets the flags of the profile selected with --profile that are set neither on the command line nor in
+// the environment; flags the command does not define are skipped
+func ApplyProfile(c *cli.Context) error {
+	name := c.String("profile")
+	if name == "" {
+		return nil
+	}
+	profile, ok := Profiles[name]
+	if !ok {
+		return errors.Wrapf(ErrUnknownProfile, "%s, built-in profiles: %s", name, strings.Join(ProfileNames(), ", "))
+	}
+	defined := make(map[string]bool)
+	for _, flag := range c.Command.Flags {
+		for _, flagName := range flag.Names() {
+			defined[flagName] = true
+		}
+	}
+	for flagName, value := range profile {
+		if !defined[flagName] || c.IsSet(flagName) {
+			continue
+		}
+		if err := c.Set(flagName, value); err != nil {
+			return errors.Wrapf(err, "failed to set %s of profile %s", flagName, name)
+		}
+	}
+	return nil
+}
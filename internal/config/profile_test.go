@@ -0,0 +1,68 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// runProfile runs a command with the flags of the profiles and returns the configuration read after the profile applies
+func runProfile(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	var cfg *Config
+	app := &cli.App{
+		SliceFlagSeparator: ";",
+		Commands: []*cli.Command{{
+			Name: "run",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "profile"},
+				&cli.StringSliceFlag{Name: "filter", EnvVars: []string{"TEST_PROFILE_FILTER"}},
+				&cli.StringFlag{Name: "order-by"},
+				&cli.DurationFlag{Name: "retry-interval", Value: time.Minute},
+				&cli.IntFlag{Name: "retry-attempts", Value: 60},
+				&cli.BoolFlag{Name: "release-on-exit"},
+			},
+			Action: func(c *cli.Context) error {
+				if err := ApplyProfile(c); err != nil {
+					return err
+				}
+				cfg = NewConfig(c)
+				return nil
+			},
+		}},
+	}
+	err := app.Run(append([]string{"kubeip-agent", "run"}, args...))
+	return cfg, err
+}
+
+func TestApplyProfile(t *testing.T) {
+	cfg, err := runProfile(t, "--profile", "eks-default")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Name=tag:kubeip,Values=reserved"}, cfg.Filter)
+	assert.Equal(t, "PublicIp", cfg.OrderBy)
+	assert.Equal(t, 30*time.Second, cfg.RetryInterval)
+	assert.Equal(t, 120, cfg.RetryAttempts)
+	assert.True(t, cfg.ReleaseOnExit)
+
+	// explicit flags and environment variables take precedence
+	t.Setenv("TEST_PROFILE_FILTER", "Name=tag:cluster,Values=prod")
+	cfg, err = runProfile(t, "--profile", "eks-default", "--retry-attempts", "5")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Name=tag:cluster,Values=prod"}, cfg.Filter)
+	assert.Equal(t, 5, cfg.RetryAttempts)
+	assert.Equal(t, 30*time.Second, cfg.RetryInterval)
+}
+
+func TestApplyProfile_none(t *testing.T) {
+	cfg, err := runProfile(t)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Filter)
+	assert.Equal(t, time.Minute, cfg.RetryInterval)
+
+	_, err = runProfile(t, "--profile", "doks-default")
+	assert.True(t, errors.Is(err, ErrUnknownProfile), "unknown profile, got %v", err)
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError is an invalid configuration with all its problems, reported at once at startup rather than failing on
+// the first bad field deep in the run
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration, %d problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// validator collects the problems of the configuration
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// oneOf checks the value of the flag is one of the values; an empty value is accepted (disabled or defaulted)
+func (v *validator) oneOf(flag, value string, values ...string) {
+	if value == "" {
+		return
+	}
+	for _, allowed := range values {
+		if value == allowed {
+			return
+		}
+	}
+	v.problems = append(v.problems, fmt.Sprintf("--%s %q is not one of %s", flag, value, strings.Join(values, ", ")))
+}
+
+// required checks the flags required by the option are set: name=value pairs, value empty when unset
+func (v *validator) required(option string, flags ...string) {
+	for i := 0; i+1 < len(flags); i += 2 {
+		v.check(flags[i+1] != "", "--%s is required with %s", flags[i], option)
+	}
+}
+
+// exclusive checks at most one of the named options is set
+func (v *validator) exclusive(options map[string]bool) {
+	var set []string
+	for name, enabled := range options {
+		if enabled {
+			set = append(set, name)
+		}
+	}
+	if len(set) > 1 {
+		// sorted: the same problem on every run
+		sort.Strings(set)
+		v.problems = append(v.problems, fmt.Sprintf("%s are mutually exclusive", strings.Join(set, ", ")))
+	}
+}
+
+func (v *validator) rate(flag string, value float64) {
+	v.check(value >= 0 && value <= 1, "--%s %v is not a rate between 0 and 1", flag, value)
+}
+
+func (v *validator) nonNegative(flag string, value time.Duration) {
+	v.check(value >= 0, "--%s %v is negative", flag, value)
+}
+
+// Validate checks the configuration of the agent as a whole: value ranges, enumerations, mutually exclusive options and
+// the fields required by the selected providers; it returns a ValidationError listing all the problems
+func (c *Config) Validate() error {
+	v := &validator{}
+	v.exclusive(map[string]bool{
+		"--develop-mode":      c.DevelopMode,
+		"--metallb-addresses": len(c.MetalLBAddresses) > 0,
+		"--bgp-addresses":     len(c.BGPAddresses) > 0,
+	})
+	v.check(len(c.KubeAsGroups) == 0 || c.KubeAs != "", "--as-group requires --as")
+	v.check(c.CanaryPercent >= 0 && c.CanaryPercent <= 100, "--canary-percent %d is not a percentage between 0 and 100", c.CanaryPercent)
+
+	v.rate("develop-failure-rate", c.DevelopFailureRate)
+	v.rate("chaos-error-rate", c.ChaosErrorRate)
+	v.rate("chaos-stale-rate", c.ChaosStaleRate)
+	v.check(c.RetryInterval > 0, "--retry-interval %v must be positive", c.RetryInterval)
+	v.check(c.RetryAttempts >= 0, "--retry-attempts %d is negative", c.RetryAttempts)
+	v.check(c.LeaseDuration > 0, "--lease-duration %d must be positive", c.LeaseDuration)
+	v.check(c.GARPCount >= 0, "--garp-count %d is negative", c.GARPCount)
+	v.check(c.DNSTTL >= 0, "--dns-ttl %d is negative", c.DNSTTL)
+	v.nonNegative("develop-latency", c.DevelopLatency)
+	v.nonNegative("chaos-max-delay", c.ChaosMaxDelay)
+	v.nonNegative("drain-timeout", c.DrainTimeout)
+	v.nonNegative("verify-timeout", c.VerifyTimeout)
+	v.nonNegative("watchdog-interval", c.WatchdogInterval)
+	v.nonNegative("handoff-timeout", c.HandoffTimeout)
+	v.nonNegative("wait-for-node-timeout", c.WaitForNodeTimeout)
+	v.check(c.NodeResyncInterval > 0, "--node-resync-interval %v must be positive", c.NodeResyncInterval)
+	v.nonNegative("startup-jitter", c.StartupJitter)
+	v.nonNegative("rollout-pacing", c.RolloutPacing)
+
+	v.oneOf("filter-logic", c.FilterLogic, "and", "or")
+	if c.NameRegex != "" {
+		_, err := regexp.Compile(c.NameRegex)
+		v.check(err == nil, "--name-regex %q: %v", c.NameRegex, err)
+	}
+	v.oneOf("non-pool-address", c.NonPoolAddress, "keep", "replace", "fail")
+	v.oneOf("unsupported-provider", c.UnsupportedProvider, "fail", "ignore")
+	v.oneOf("gcp-private-nodes", c.GCPPrivateNodes, "add", "refuse")
+	v.oneOf("azure-cloud", c.AzureCloud, "AzurePublicCloud", "AzureUSGovernment", "AzureChinaCloud")
+	// the Azure SDK sends the bearer tokens over TLS only
+	v.check(c.AzureEndpoint == "" || strings.HasPrefix(c.AzureEndpoint, "https://"), "--azure-endpoint %q is not an https URL", c.AzureEndpoint)
+	v.oneOf("azure-auth", c.AzureAuth, "managed-identity", "workload-identity", "client-secret")
+	switch c.AzureAuth {
+	case "workload-identity":
+		v.required("--azure-auth workload-identity", "azure-tenant-id", c.AzureTenantID, "azure-client-id", c.AzureClientID,
+			"azure-federated-token-file", c.AzureFederatedTokenFile)
+	case "client-secret":
+		v.required("--azure-auth client-secret", "azure-tenant-id", c.AzureTenantID, "azure-client-id", c.AzureClientID,
+			"azure-client-secret-file", c.AzureClientSecretFile)
+	}
+	v.check(c.AWSRoleARN != "" || (c.AWSExternalID == "" && c.AWSWebIdentityTokenFile == ""),
+		"--aws-external-id and --aws-web-identity-token-file require --aws-role-arn")
+
+	c.validateIntegrations(v)
+
+	v.check(!c.WatchdogReconcile || c.WatchdogInterval > 0, "--watchdog-reconcile requires --watchdog-interval")
+	v.check(c.WatchdogInterval == 0 || c.WatchdogURL != "", "--watchdog-interval requires --watchdog-url or --verify-url")
+	v.check(!c.AdminDashboard || c.AdminAddress != "", "--admin-dashboard requires --admin-address")
+	v.check(c.AdminAddress == "" || c.AdminTokenFile != "", "--admin-address requires --admin-token-file")
+	c.validateClusters(v)
+	for _, listener := range []struct{ name, cert, key, clientCA string }{
+		{"metrics", c.MetricsTLSCertFile, c.MetricsTLSKeyFile, c.MetricsTLSClientCAFile},
+		{"health", c.HealthTLSCertFile, c.HealthTLSKeyFile, c.HealthTLSClientCAFile},
+		{"admin", c.AdminTLSCertFile, c.AdminTLSKeyFile, c.AdminTLSClientCAFile},
+	} {
+		v.check((listener.cert == "") == (listener.key == ""), "--%[1]s-tls-cert-file and --%[1]s-tls-key-file are set together", listener.name)
+		v.check(listener.clientCA == "" || listener.cert != "", "--%[1]s-tls-client-ca-file requires --%[1]s-tls-cert-file", listener.name)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validateIntegrations checks the fields required by the providers of the integrations
+func (c *Config) validateIntegrations(v *validator) {
+	v.oneOf("dns-provider", c.DNSProvider, "clouddns", "cloudflare", "external-dns")
+	switch c.DNSProvider {
+	case "clouddns":
+		v.required("--dns-provider clouddns", "dns-zone", c.DNSZone, "dns-domain", c.DNSDomain)
+	case "cloudflare":
+		v.required("--dns-provider cloudflare", "dns-zone", c.DNSZone, "dns-domain", c.DNSDomain, "dns-api-token", c.DNSAPIToken)
+	case "external-dns":
+		v.required("--dns-provider external-dns", "dns-domain", c.DNSDomain)
+	}
+
+	v.oneOf("ipam-provider", c.IPAMProvider, "netbox", "infoblox", "phpipam")
+	switch c.IPAMProvider {
+	case "netbox", "phpipam":
+		v.required("--ipam-provider "+c.IPAMProvider, "ipam-url", c.IPAMURL, "ipam-token", c.IPAMToken)
+	case "infoblox":
+		v.required("--ipam-provider infoblox", "ipam-url", c.IPAMURL, "ipam-username", c.IPAMUsername)
+		v.oneOf("ipam-infoblox-record", c.IPAMInfobloxRecord, "fixedaddress", "host")
+		if c.IPAMInfobloxRecord == "host" {
+			v.required("--ipam-infoblox-record host", "ipam-domain", c.IPAMDomain)
+		}
+	}
+
+	v.oneOf("firewall-provider", c.FirewallProvider, "gcp-firewall", "aws-security-group", "aws-prefix-list")
+	if c.FirewallProvider != "" {
+		v.required("--firewall-provider "+c.FirewallProvider, "firewall-name", c.FirewallName)
+	}
+
+	v.oneOf("snat-backend", c.SNATBackend, "iptables", "nftables")
+	if c.SNATBackend != "" {
+		v.check(len(c.SNATSourceCIDRs) > 0, "--snat-source-cidr is required with --snat-backend %s", c.SNATBackend)
+	}
+
+	v.oneOf("events-provider", c.EventsProvider, "aws-eventbridge", "gcp-pubsub")
+	if c.EventsProvider != "" {
+		v.required("--events-provider "+c.EventsProvider, "events-source", c.EventsSource)
+	}
+
+	v.oneOf("sink-provider", c.SinkProvider, "kafka-rest-proxy", "pubsub", "webhook")
+	switch c.SinkProvider {
+	case "kafka-rest-proxy":
+		v.required("--sink-provider kafka-rest-proxy", "sink-url", c.SinkURL, "sink-topic", c.SinkTopic)
+	case "pubsub":
+		v.required("--sink-provider pubsub", "sink-topic", c.SinkTopic)
+	case "webhook":
+		v.required("--sink-provider webhook", "sink-url", c.SinkURL)
+	}
+	v.check(c.SinkTemplate == "" || c.SinkTemplateFile == "", "--sink-template and --sink-template-file are mutually exclusive")
+}
+
+// validateClusters checks the clusters managed by the controller: each cluster sharing the pool with others has its own
+// scoping filters, added to --filter
+func (c *Config) validateClusters(v *validator) {
+	clusters, err := c.ManagedClusters()
+	if err != nil {
+		v.check(false, "%v", err)
+		return
+	}
+	if len(clusters) == 0 {
+		return
+	}
+	v.check(len(c.ProviderFilters) == 0 && c.FilterLogic != "or",
+		"--cluster scopes the addresses with --filter: --provider-filter and --filter-logic or are not supported")
+	for _, cluster := range clusters {
+		v.check(len(clusters) == 1 || len(cluster.Filters) > 0, "--cluster-filter is required for cluster %s sharing the pool", cluster.Name)
+	}
+}
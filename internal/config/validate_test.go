@@ -0,0 +1,164 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig() *Config {
+	return &Config{
+		CanaryPercent: 100,
+		RetryInterval: 5 * time.Minute,
+		LeaseDuration: 5,
+		GARPCount:     3,
+
+		NodeResyncInterval: 30 * time.Second,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, validConfig().Validate())
+
+	cfg := validConfig()
+	cfg.DNSProvider = "cloudflare"
+	cfg.DNSZone = "example.com"
+	cfg.DNSDomain = "nodes.example.com"
+	cfg.DNSAPIToken = "token"
+	cfg.AdminAddress = ":8443"
+	cfg.AdminTokenFile = "/etc/kubeip/token"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestValidate_problems(t *testing.T) {
+	cfg := validConfig()
+	cfg.MetalLBAddresses = []string{"192.0.2.0/28"}
+	cfg.BGPAddresses = []string{"198.51.100.0/28"}
+	cfg.CanaryPercent = 150
+	cfg.ChaosErrorRate = 2
+	cfg.NameRegex = "egress-("
+	cfg.NonPoolAddress = "drop"
+	cfg.DNSProvider = "clouddns"
+	cfg.SinkProvider = "kafka-rest-proxy"
+	cfg.SinkURL = "http://kafka-rest:8082"
+	cfg.WatchdogReconcile = true
+	cfg.AdminTLSCertFile = "/etc/kubeip/tls.crt"
+
+	err := cfg.Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid), "validation error, got %v", err)
+	assert.Equal(t, []string{
+		"--bgp-addresses, --metallb-addresses are mutually exclusive",
+		"--canary-percent 150 is not a percentage between 0 and 100",
+		"--chaos-error-rate 2 is not a rate between 0 and 1",
+		"--name-regex \"egress-(\": error parsing regexp: missing closing ): `egress-(`",
+		`--non-pool-address "drop" is not one of keep, replace, fail`,
+		"--dns-zone is required with --dns-provider clouddns",
+		"--dns-domain is required with --dns-provider clouddns",
+		"--sink-topic is required with --sink-provider kafka-rest-proxy",
+		"--watchdog-reconcile requires --watchdog-interval",
+		"--admin-tls-cert-file and --admin-tls-key-file are set together",
+	}, invalid.Problems)
+	assert.Contains(t, err.Error(), "invalid configuration, 10 problem(s):\n  - --bgp-addresses")
+	assert.Contains(t, err.Error(), "\n  - --name-regex \"egress-(\": error parsing regexp")
+}
+
+func TestValidate_providers(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   func(cfg *Config)
+		wants string
+	}{
+		{
+			name:  "unknown IPAM provider",
+			set:   func(cfg *Config) { cfg.IPAMProvider = "bluecat" },
+			wants: `--ipam-provider "bluecat" is not one of netbox, infoblox, phpipam`,
+		},
+		{
+			name: "Infoblox host records",
+			set: func(cfg *Config) {
+				cfg.IPAMProvider, cfg.IPAMURL, cfg.IPAMUsername, cfg.IPAMInfobloxRecord = "infoblox", "https://infoblox", "kubeip", "host"
+			},
+			wants: "--ipam-domain is required with --ipam-infoblox-record host",
+		},
+		{
+			name:  "firewall",
+			set:   func(cfg *Config) { cfg.FirewallProvider = "aws-prefix-list" },
+			wants: "--firewall-name is required with --firewall-provider aws-prefix-list",
+		},
+		{
+			name:  "SNAT",
+			set:   func(cfg *Config) { cfg.SNATBackend = "nftables" },
+			wants: "--snat-source-cidr is required with --snat-backend nftables",
+		},
+		{
+			name:  "events",
+			set:   func(cfg *Config) { cfg.EventsProvider = "gcp-pubsub" },
+			wants: "--events-source is required with --events-provider gcp-pubsub",
+		},
+		{
+			name:  "AWS external ID",
+			set:   func(cfg *Config) { cfg.AWSExternalID = "external" },
+			wants: "--aws-external-id and --aws-web-identity-token-file require --aws-role-arn",
+		},
+		{
+			name: "Azure workload identity",
+			set: func(cfg *Config) {
+				cfg.AzureAuth, cfg.AzureTenantID, cfg.AzureClientID = "workload-identity", "tenant", "client"
+			},
+			wants: "--azure-federated-token-file is required with --azure-auth workload-identity",
+		},
+		{
+			name:  "unknown Azure cloud",
+			set:   func(cfg *Config) { cfg.AzureCloud = "AzureGermanCloud" },
+			wants: `--azure-cloud "AzureGermanCloud" is not one of AzurePublicCloud, AzureUSGovernment, AzureChinaCloud`,
+		},
+		{
+			name:  "Azure endpoint without TLS",
+			set:   func(cfg *Config) { cfg.AzureEndpoint = "http://localhost:8080" },
+			wants: `--azure-endpoint "http://localhost:8080" is not an https URL`,
+		},
+		{
+			name:  "unknown Azure authentication",
+			set:   func(cfg *Config) { cfg.AzureAuth = "certificate" },
+			wants: `--azure-auth "certificate" is not one of managed-identity, workload-identity, client-secret`,
+		},
+		{
+			name:  "impersonated groups",
+			set:   func(cfg *Config) { cfg.KubeAsGroups = []string{"system:masters"} },
+			wants: "--as-group requires --as",
+		},
+		{
+			name: "clusters sharing the pool",
+			set: func(cfg *Config) {
+				cfg.Clusters = []string{"gcp-eu=a.yaml", "gcp-us=b.yaml"}
+				cfg.ClusterFilters = []string{"gcp-eu=labels.cluster=gcp-eu"}
+			},
+			wants: "--cluster-filter is required for cluster gcp-us sharing the pool",
+		},
+		{
+			name: "metrics client CA",
+			set: func(cfg *Config) {
+				cfg.MetricsAddress, cfg.MetricsTLSClientCAFile = ":9100", "/etc/kubeip/ca.crt"
+			},
+			wants: "--metrics-tls-client-ca-file requires --metrics-tls-cert-file",
+		},
+		{
+			name:  "health certificate",
+			set:   func(cfg *Config) { cfg.HealthTLSKeyFile = "/etc/kubeip/tls.key" },
+			wants: "--health-tls-cert-file and --health-tls-key-file are set together",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.set(cfg)
+			var invalid *ValidationError
+			require.True(t, errors.As(cfg.Validate(), &invalid))
+			assert.Equal(t, []string{tt.wants}, invalid.Problems)
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package dns
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	clouddns "google.golang.org/api/dns/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	defaultTTL = 300
+	// maxChangeAttempts bounds the retries of changes rejected because the record sets were changed concurrently
+	maxChangeAttempts = 5
+	// changeDone is the status of an applied change
+	changeDone = "done"
+)
+
+// changePollInterval is the delay between polls of a pending change
+var changePollInterval = time.Second
+
+type cloudDNSClient interface {
+	ListRecordSets(ctx context.Context, project, zone, name string) ([]*clouddns.ResourceRecordSet, error)
+	// Change submits the change and returns it, pending until applied to the authoritative servers
+	Change(ctx context.Context, project, zone string, change *clouddns.Change) (*clouddns.Change, error)
+	GetChange(ctx context.Context, project, zone, id string) (*clouddns.Change, error)
+}
+
+type cloudDNSService struct {
+	service *clouddns.Service
+}
+
+func (s *cloudDNSService) ListRecordSets(ctx context.Context, project, zone, name string) ([]*clouddns.ResourceRecordSet, error) {
+	resp, err := s.service.ResourceRecordSets.List(project, zone).Name(name).Context(ctx).Do()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return resp.Rrsets, nil
+}
+
+func (s *cloudDNSService) Change(ctx context.Context, project, zone string, change *clouddns.Change) (*clouddns.Change, error) {
+	return s.service.Changes.Create(project, zone, change).Context(ctx).Do() //nolint:wrapcheck
+}
+
+func (s *cloudDNSService) GetChange(ctx context.Context, project, zone, id string) (*clouddns.Change, error) {
+	return s.service.Changes.Get(project, zone, id).Context(ctx).Do() //nolint:wrapcheck
+}
+
+type cloudDNSUpdater struct {
+	client  cloudDNSClient
+	project string
+	zone    string
+	domain  string
+	ttl     int64
+	owner   string
+	logger  *logrus.Entry
+}
+
+// NewCloudDNSUpdater returns an updater managing node records in a Google Cloud DNS managed zone
+func NewCloudDNSUpdater(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Updater, error) {
+	if cfg.DNSZone == "" || cfg.DNSDomain == "" {
+		return nil, errors.New("DNS zone and domain are required for Cloud DNS")
+	}
+	opts, err := cloud.GCPClientOptions(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	service, err := clouddns.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Cloud DNS client")
+	}
+
+	// get project ID from metadata server
+	project := cfg.Project
+	if project == "" {
+		project, err = metadata.ProjectID()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get project ID from metadata server")
+		}
+	}
+
+	return newCloudDNSUpdater(&cloudDNSService{service: service}, logger, project, cfg), nil
+}
+
+func newCloudDNSUpdater(client cloudDNSClient, logger *logrus.Entry, project string, cfg *config.Config) *cloudDNSUpdater {
+	ttl := int64(cfg.DNSTTL)
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &cloudDNSUpdater{
+		client:  client,
+		project: project,
+		zone:    cfg.DNSZone,
+		domain:  cfg.DNSDomain,
+		ttl:     ttl,
+		owner:   OwnerValue(cfg.ClusterName),
+		logger:  logger,
+	}
+}
+
+// recordSets returns the address and ownership TXT record sets of the name (nil if missing)
+func (u *cloudDNSUpdater) recordSets(ctx context.Context, name, recordType string) (*clouddns.ResourceRecordSet, *clouddns.ResourceRecordSet, error) {
+	rrsets, err := u.client.ListRecordSets(ctx, u.project, u.zone, name)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to list record sets of %s", name)
+	}
+	var record, txt *clouddns.ResourceRecordSet
+	for _, rrset := range rrsets {
+		switch rrset.Type {
+		case recordType:
+			record = rrset
+		case recordTypeTXT:
+			txt = rrset
+		}
+	}
+	return record, txt, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *cloudDNSUpdater) owned(txt *clouddns.ResourceRecordSet) bool {
+	return txt != nil && contains(txt.Rrdatas, u.owner)
+}
+
+// isChangeConflict reports if the change was rejected because the record sets changed since they were listed: a
+// deletion not matching the current record set (412) or an addition of an existing record set (409)
+func isChangeConflict(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusPreconditionFailed || apiErr.Code == http.StatusConflict)
+}
+
+// apply submits the change built from the current record sets and waits until it is done; a change conflicting with a
+// concurrent change is built again and retried; reports false if build returned no change
+func (u *cloudDNSUpdater) apply(ctx context.Context, name string, build func() (*clouddns.Change, error)) (bool, error) {
+	for attempt := 1; attempt <= maxChangeAttempts; attempt++ {
+		change, err := build()
+		if err != nil || change == nil {
+			return false, err
+		}
+		change, err = u.client.Change(ctx, u.project, u.zone, change)
+		if isChangeConflict(err) {
+			u.logger.WithError(err).WithFields(logrus.Fields{
+				"record":  name,
+				"attempt": attempt,
+			}).Debug("DNS record changed concurrently, retrying")
+			continue
+		}
+		if err != nil {
+			return false, err //nolint:wrapcheck
+		}
+		return true, u.wait(ctx, change)
+	}
+	return false, errors.Errorf("DNS record %s still changed concurrently after %d attempts", name, maxChangeAttempts)
+}
+
+// wait polls the change until it is done
+func (u *cloudDNSUpdater) wait(ctx context.Context, change *clouddns.Change) error {
+	var err error
+	id := change.Id
+	for change.Status != changeDone {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for change %s", id)
+		case <-time.After(changePollInterval):
+		}
+		if change, err = u.client.GetChange(ctx, u.project, u.zone, id); err != nil {
+			return errors.Wrapf(err, "failed to get change %s", id)
+		}
+	}
+	return nil
+}
+
+// Upsert creates or updates the node record and its ownership TXT record in a single change;
+// records not created by kubeip (no ownership TXT record) are never modified
+func (u *cloudDNSUpdater) Upsert(ctx context.Context, nodeName, address string) error {
+	name := RecordName(nodeName, u.domain)
+	recordType := RecordType(address)
+	changed, err := u.apply(ctx, name, func() (*clouddns.Change, error) {
+		return u.upsertChange(ctx, name, recordType, address)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update DNS record %s", name)
+	}
+	if !changed {
+		u.logger.WithField("record", name).Debug("DNS record up to date")
+		return nil
+	}
+	u.logger.WithFields(logrus.Fields{
+		"record":  name,
+		"address": address,
+	}).Info("DNS record updated")
+	return nil
+}
+
+// upsertChange returns the change pointing the record to the address, nil if up to date
+func (u *cloudDNSUpdater) upsertChange(ctx context.Context, name, recordType, address string) (*clouddns.Change, error) {
+	record, txt, err := u.recordSets(ctx, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	owned := u.owned(txt)
+	if record != nil && !owned {
+		return nil, errors.Wrapf(ErrRecordNotOwned, "%s %s", recordType, name)
+	}
+	if record != nil && len(record.Rrdatas) == 1 && record.Rrdatas[0] == address {
+		return nil, nil //nolint:nilnil
+	}
+
+	change := &clouddns.Change{
+		Additions: []*clouddns.ResourceRecordSet{{Name: name, Type: recordType, Ttl: u.ttl, Rrdatas: []string{address}}},
+	}
+	if record != nil {
+		change.Deletions = append(change.Deletions, record)
+	}
+	if !owned {
+		rrdatas := []string{u.owner}
+		if txt != nil {
+			// keep unrelated TXT values of the name
+			change.Deletions = append(change.Deletions, txt)
+			rrdatas = append(txt.Rrdatas, u.owner) //nolint:gocritic
+		}
+		change.Additions = append(change.Additions, &clouddns.ResourceRecordSet{Name: name, Type: recordTypeTXT, Ttl: u.ttl, Rrdatas: rrdatas})
+	}
+	return change, nil
+}
+
+// Delete removes the node record and its ownership TXT value if the record still points to the address
+func (u *cloudDNSUpdater) Delete(ctx context.Context, nodeName, address string) error {
+	name := RecordName(nodeName, u.domain)
+	recordType := RecordType(address)
+	changed, err := u.apply(ctx, name, func() (*clouddns.Change, error) {
+		return u.deleteChange(ctx, name, recordType, address)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete DNS record %s", name)
+	}
+	if changed {
+		u.logger.WithField("record", name).Info("DNS record deleted")
+	}
+	return nil
+}
+
+// deleteChange returns the change deleting the record and its ownership TXT value, nil if there is nothing to delete
+func (u *cloudDNSUpdater) deleteChange(ctx context.Context, name, recordType, address string) (*clouddns.Change, error) {
+	record, txt, err := u.recordSets(ctx, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, nil //nolint:nilnil
+	}
+	if !u.owned(txt) {
+		return nil, errors.Wrapf(ErrRecordNotOwned, "%s %s", recordType, name)
+	}
+	if !contains(record.Rrdatas, address) {
+		u.logger.WithField("record", name).Debug("DNS record points to another address, skipping deletion")
+		return nil, nil //nolint:nilnil
+	}
+
+	change := &clouddns.Change{Deletions: []*clouddns.ResourceRecordSet{record, txt}}
+	rrdatas := make([]string, 0, len(txt.Rrdatas))
+	for _, v := range txt.Rrdatas {
+		if v != u.owner {
+			rrdatas = append(rrdatas, v)
+		}
+	}
+	if len(rrdatas) > 0 {
+		change.Additions = []*clouddns.ResourceRecordSet{{Name: name, Type: recordTypeTXT, Ttl: txt.Ttl, Rrdatas: rrdatas}}
+	}
+	return change, nil
+}
@@ -0,0 +1,238 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clouddns "google.golang.org/api/dns/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func init() {
+	changePollInterval = 0
+}
+
+// fakeCloudDNS models the Cloud DNS changes API: a change is rejected if a deletion does not match the current record
+// set (412) or an addition already exists (409), is applied atomically and stays pending for a number of polls
+type fakeCloudDNS struct {
+	rrsets  []*clouddns.ResourceRecordSet
+	changes []*clouddns.Change
+	// pendingPolls is the number of polls a change stays pending
+	pendingPolls int
+	polls        map[string]int
+	// concurrent, if set, changes the record sets before the next change is applied, as another writer would
+	concurrent func(f *fakeCloudDNS)
+	conflicts  int
+	denied     bool
+}
+
+func (f *fakeCloudDNS) ListRecordSets(_ context.Context, _, _, name string) ([]*clouddns.ResourceRecordSet, error) {
+	if f.denied {
+		return nil, &googleapi.Error{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+	var rrsets []*clouddns.ResourceRecordSet
+	for _, rrset := range f.rrsets {
+		if rrset.Name == name {
+			rrsets = append(rrsets, rrset)
+		}
+	}
+	return rrsets, nil
+}
+
+func (f *fakeCloudDNS) find(name, recordType string) int {
+	for i, rrset := range f.rrsets {
+		if rrset.Name == name && rrset.Type == recordType {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeCloudDNS) Change(_ context.Context, _, _ string, change *clouddns.Change) (*clouddns.Change, error) {
+	if f.denied {
+		return nil, &googleapi.Error{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+	if f.concurrent != nil {
+		f.concurrent(f)
+		f.concurrent = nil
+	}
+	rrsets := append([]*clouddns.ResourceRecordSet(nil), f.rrsets...)
+	for _, deletion := range change.Deletions {
+		i := f.find(deletion.Name, deletion.Type)
+		if i < 0 || f.rrsets[i].Ttl != deletion.Ttl || !reflect.DeepEqual(f.rrsets[i].Rrdatas, deletion.Rrdatas) {
+			f.rrsets, f.conflicts = rrsets, f.conflicts+1
+			return nil, &googleapi.Error{Code: http.StatusPreconditionFailed, Message: "conditionNotMet"}
+		}
+		f.rrsets = append(f.rrsets[:i], f.rrsets[i+1:]...)
+	}
+	for _, addition := range change.Additions {
+		if f.find(addition.Name, addition.Type) >= 0 {
+			f.rrsets, f.conflicts = rrsets, f.conflicts+1
+			return nil, &googleapi.Error{Code: http.StatusConflict, Message: "alreadyExists"}
+		}
+		f.rrsets = append(f.rrsets, addition)
+	}
+	f.changes = append(f.changes, change)
+	return &clouddns.Change{Id: fmt.Sprint(len(f.changes)), Status: "pending"}, nil
+}
+
+func (f *fakeCloudDNS) GetChange(_ context.Context, _, _, id string) (*clouddns.Change, error) {
+	if f.polls == nil {
+		f.polls = make(map[string]int)
+	}
+	f.polls[id]++
+	if f.polls[id] < f.pendingPolls {
+		return &clouddns.Change{Id: id, Status: "pending"}, nil
+	}
+	return &clouddns.Change{Id: id, Status: changeDone}, nil
+}
+
+const testRecord = "node-1.egress.example.com."
+
+var testOwner = OwnerValue("test-cluster")
+
+func newTestUpdater(rrsets ...*clouddns.ResourceRecordSet) (*cloudDNSUpdater, *fakeCloudDNS) {
+	client := &fakeCloudDNS{rrsets: rrsets}
+	cfg := &config.Config{DNSZone: "egress", DNSDomain: "egress.example.com", ClusterName: "test-cluster"}
+	return newCloudDNSUpdater(client, logrus.NewEntry(logrus.New()), "test-project", cfg), client
+}
+
+func TestCloudDNSUpdater_Upsert(t *testing.T) {
+	t.Run("create record", func(t *testing.T) {
+		u, client := newTestUpdater()
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, client.changes, 1)
+		assert.Empty(t, client.changes[0].Deletions)
+		require.Len(t, client.changes[0].Additions, 2)
+		assert.Equal(t, &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Ttl: defaultTTL, Rrdatas: []string{"1.1.1.1"}}, client.changes[0].Additions[0])
+		assert.Equal(t, []string{testOwner}, client.changes[0].Additions[1].Rrdatas)
+	})
+	t.Run("update owned record", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Ttl: defaultTTL, Rrdatas: []string{"2.2.2.2"}}
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Ttl: defaultTTL, Rrdatas: []string{testOwner}}
+		u, client := newTestUpdater(record, txt)
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, client.changes, 1)
+		assert.Equal(t, []*clouddns.ResourceRecordSet{record}, client.changes[0].Deletions)
+		assert.Len(t, client.changes[0].Additions, 1)
+	})
+	t.Run("record up to date", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Rrdatas: []string{"1.1.1.1"}}
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Rrdatas: []string{testOwner}}
+		u, client := newTestUpdater(record, txt)
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, client.changes)
+	})
+	t.Run("record not owned", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Rrdatas: []string{"2.2.2.2"}}
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Rrdatas: []string{OwnerValue("other-cluster")}}
+		u, client := newTestUpdater(record, txt)
+		err := u.Upsert(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, errors.Is(err, ErrRecordNotOwned))
+		assert.Empty(t, client.changes)
+	})
+	t.Run("keep unrelated TXT values", func(t *testing.T) {
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Rrdatas: []string{`"v=spf1 -all"`}}
+		u, client := newTestUpdater(txt)
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "2001:db8::1"))
+		require.Len(t, client.changes, 1)
+		assert.Equal(t, "AAAA", client.changes[0].Additions[0].Type)
+		assert.Equal(t, []string{`"v=spf1 -all"`, testOwner}, client.changes[0].Additions[1].Rrdatas)
+	})
+}
+
+func TestCloudDNSUpdater_Delete(t *testing.T) {
+	t.Run("delete owned record", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Rrdatas: []string{"1.1.1.1"}}
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Rrdatas: []string{testOwner}}
+		u, client := newTestUpdater(record, txt)
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, client.changes, 1)
+		assert.Equal(t, []*clouddns.ResourceRecordSet{record, txt}, client.changes[0].Deletions)
+		assert.Empty(t, client.changes[0].Additions)
+	})
+	t.Run("record points to another address", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Rrdatas: []string{"2.2.2.2"}}
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Rrdatas: []string{testOwner}}
+		u, client := newTestUpdater(record, txt)
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, client.changes)
+	})
+	t.Run("record not owned", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Rrdatas: []string{"1.1.1.1"}}
+		u, client := newTestUpdater(record)
+		err := u.Delete(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, errors.Is(err, ErrRecordNotOwned))
+		assert.Empty(t, client.changes)
+	})
+	t.Run("no record", func(t *testing.T) {
+		u, client := newTestUpdater()
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, client.changes)
+	})
+}
+
+func TestCloudDNSUpdater_Change(t *testing.T) {
+	t.Run("wait for pending change", func(t *testing.T) {
+		u, client := newTestUpdater()
+		client.pendingPolls = 3
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 3, client.polls["1"])
+	})
+	t.Run("retry record changed concurrently", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Ttl: defaultTTL, Rrdatas: []string{"2.2.2.2"}}
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Ttl: defaultTTL, Rrdatas: []string{testOwner}}
+		u, client := newTestUpdater(record, txt)
+		client.concurrent = func(f *fakeCloudDNS) {
+			f.rrsets[0] = &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Ttl: defaultTTL, Rrdatas: []string{"3.3.3.3"}}
+		}
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 1, client.conflicts)
+		require.Len(t, client.changes, 1)
+		assert.Equal(t, []string{"3.3.3.3"}, client.changes[0].Deletions[0].Rrdatas)
+		assert.Equal(t, []string{"1.1.1.1"}, client.rrsets[client.find(testRecord, "A")].Rrdatas)
+	})
+	t.Run("retry record created concurrently", func(t *testing.T) {
+		u, client := newTestUpdater()
+		client.concurrent = func(f *fakeCloudDNS) {
+			f.rrsets = append(f.rrsets,
+				&clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Ttl: defaultTTL, Rrdatas: []string{"1.1.1.1"}},
+				&clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Ttl: defaultTTL, Rrdatas: []string{testOwner}})
+		}
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 1, client.conflicts)
+		assert.Empty(t, client.changes)
+	})
+	t.Run("record deleted concurrently", func(t *testing.T) {
+		record := &clouddns.ResourceRecordSet{Name: testRecord, Type: "A", Rrdatas: []string{"1.1.1.1"}}
+		txt := &clouddns.ResourceRecordSet{Name: testRecord, Type: "TXT", Rrdatas: []string{testOwner}}
+		u, client := newTestUpdater(record, txt)
+		client.concurrent = func(f *fakeCloudDNS) {
+			f.rrsets = nil
+		}
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 1, client.conflicts)
+		assert.Empty(t, client.changes)
+	})
+	t.Run("permission denied", func(t *testing.T) {
+		u, client := newTestUpdater()
+		client.denied = true
+		err := u.Upsert(context.Background(), "node-1", "1.1.1.1")
+		var apiErr *googleapi.Error
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusForbidden, apiErr.Code)
+	})
+}
+
+func TestRecordName(t *testing.T) {
+	assert.Equal(t, "node-1.egress.example.com.", RecordName("node-1", "egress.example.com"))
+	assert.Equal(t, "node-1.egress.example.com.", RecordName("node-1", ".egress.example.com."))
+}
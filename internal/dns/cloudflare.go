@@ -0,0 +1,268 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cloudflareAPI     = "https://api.cloudflare.com/client/v4"
+	cloudflareTimeout = 30 * time.Second
+	// cloudflareRecordNotFound is the error code of a request for a record ID that does not exist
+	cloudflareRecordNotFound = 81044
+	// cloudflareRecordExists is the error code of the creation of a record identical to an existing one
+	cloudflareRecordExists = 81058
+)
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// cloudflareError is an unsuccessful Cloudflare API response
+type cloudflareError struct {
+	method   string
+	path     string
+	status   int
+	codes    []int
+	messages []string
+}
+
+func (e *cloudflareError) Error() string {
+	return fmt.Sprintf("Cloudflare API %s %s failed with status %d: %s", e.method, e.path, e.status, strings.Join(e.messages, "; "))
+}
+
+// isCloudflareError reports if the error is a Cloudflare API response with the error code
+func isCloudflareError(err error, code int) bool {
+	var cfErr *cloudflareError
+	if !errors.As(err, &cfErr) {
+		return false
+	}
+	for _, c := range cfErr.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+type cloudflareUpdater struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	zoneID  string
+	domain  string
+	ttl     int
+	owner   string
+	logger  *logrus.Entry
+}
+
+// NewCloudflareUpdater returns an updater managing node records in a Cloudflare zone, authenticated with an API token
+func NewCloudflareUpdater(logger *logrus.Entry, cfg *config.Config) (Updater, error) {
+	if cfg.DNSZone == "" || cfg.DNSDomain == "" {
+		return nil, errors.New("DNS zone and domain are required for Cloudflare")
+	}
+	if cfg.DNSAPIToken == "" {
+		return nil, errors.New("DNS API token is required for Cloudflare")
+	}
+	return newCloudflareUpdater(&http.Client{Timeout: cloudflareTimeout}, cloudflareAPI, logger, cfg), nil
+}
+
+func newCloudflareUpdater(client *http.Client, baseURL string, logger *logrus.Entry, cfg *config.Config) *cloudflareUpdater {
+	ttl := cfg.DNSTTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &cloudflareUpdater{
+		client:  client,
+		baseURL: baseURL,
+		token:   cfg.DNSAPIToken,
+		zoneID:  cfg.DNSZone,
+		domain:  cfg.DNSDomain,
+		ttl:     ttl,
+		// Cloudflare stores TXT content without the surrounding quotes
+		owner:  strings.Trim(OwnerValue(cfg.ClusterName), `"`),
+		logger: logger,
+	}
+}
+
+func (u *cloudflareUpdater) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal Cloudflare request")
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.baseURL+path, reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to create Cloudflare request")
+	}
+	req.Header.Set("Authorization", "Bearer "+u.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to call Cloudflare API")
+	}
+	defer resp.Body.Close()
+
+	var response cloudflareResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.Wrapf(err, "failed to decode Cloudflare response (status %d)", resp.StatusCode)
+	}
+	if !response.Success {
+		cfErr := &cloudflareError{method: method, path: path, status: resp.StatusCode}
+		for _, e := range response.Errors {
+			cfErr.codes = append(cfErr.codes, e.Code)
+			cfErr.messages = append(cfErr.messages, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return cfErr
+	}
+	if result != nil {
+		return errors.Wrap(json.Unmarshal(response.Result, result), "failed to decode Cloudflare result")
+	}
+	return nil
+}
+
+func (u *cloudflareUpdater) records(ctx context.Context, name, recordType string) ([]cloudflareRecord, error) {
+	var records []cloudflareRecord
+	query := url.Values{"name": {name}, "type": {recordType}}
+	path := fmt.Sprintf("/zones/%s/dns_records?%s", u.zoneID, query.Encode())
+	if err := u.do(ctx, http.MethodGet, path, nil, &records); err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s records of %s", recordType, name)
+	}
+	return records, nil
+}
+
+// ownerRecord returns the ownership TXT record of the name or nil if the name is not owned
+func (u *cloudflareUpdater) ownerRecord(ctx context.Context, name string) (*cloudflareRecord, error) {
+	txts, err := u.records(ctx, name, recordTypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	for i := range txts {
+		if strings.Trim(txts[i].Content, `"`) == u.owner {
+			return &txts[i], nil
+		}
+	}
+	return nil, nil //nolint:nilnil
+}
+
+// Upsert creates or updates the node record and its ownership TXT record;
+// records not created by kubeip (no ownership TXT record) are never modified
+func (u *cloudflareUpdater) Upsert(ctx context.Context, nodeName, address string) error {
+	name := strings.TrimSuffix(RecordName(nodeName, u.domain), ".")
+	recordType := RecordType(address)
+	records, err := u.records(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+	owner, err := u.ownerRecord(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 && owner == nil {
+		return errors.Wrapf(ErrRecordNotOwned, "%s %s", recordType, name)
+	}
+
+	// claim the name before creating the record, so an interrupted update never leaves an unowned record behind;
+	// an identical record already exists if another agent of the cluster claimed it concurrently
+	if owner == nil {
+		txt := cloudflareRecord{Type: recordTypeTXT, Name: name, Content: u.owner, TTL: u.ttl}
+		err = u.do(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", u.zoneID), txt, nil)
+		if err != nil && !isCloudflareError(err, cloudflareRecordExists) {
+			return errors.Wrapf(err, "failed to create ownership record %s", name)
+		}
+	}
+
+	record := cloudflareRecord{Type: recordType, Name: name, Content: address, TTL: u.ttl}
+	switch {
+	case len(records) == 0:
+		err = u.create(ctx, record)
+	case records[0].Content == address:
+		u.logger.WithField("record", name).Debug("DNS record up to date")
+		return nil
+	default:
+		err = u.do(ctx, http.MethodPut, fmt.Sprintf("/zones/%s/dns_records/%s", u.zoneID, records[0].ID), record, nil)
+		if isCloudflareError(err, cloudflareRecordNotFound) {
+			// deleted concurrently since listed
+			err = u.create(ctx, record)
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to update DNS record %s", name)
+	}
+	u.logger.WithFields(logrus.Fields{
+		"record":  name,
+		"address": address,
+	}).Info("DNS record updated")
+	return nil
+}
+
+// create creates the record; an identical record created concurrently is not an error
+func (u *cloudflareUpdater) create(ctx context.Context, record cloudflareRecord) error {
+	err := u.do(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", u.zoneID), record, nil)
+	if isCloudflareError(err, cloudflareRecordExists) {
+		return nil
+	}
+	return err
+}
+
+// Delete removes the node record and its ownership TXT record if the record still points to the address
+func (u *cloudflareUpdater) Delete(ctx context.Context, nodeName, address string) error {
+	name := strings.TrimSuffix(RecordName(nodeName, u.domain), ".")
+	recordType := RecordType(address)
+	records, err := u.records(ctx, name, recordType)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	owner, err := u.ownerRecord(ctx, name)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return errors.Wrapf(ErrRecordNotOwned, "%s %s", recordType, name)
+	}
+	if records[0].Content != address {
+		u.logger.WithField("record", name).Debug("DNS record points to another address, skipping deletion")
+		return nil
+	}
+
+	for _, id := range []string{records[0].ID, owner.ID} {
+		err = u.do(ctx, http.MethodDelete, fmt.Sprintf("/zones/%s/dns_records/%s", u.zoneID, id), nil, nil)
+		if err != nil && !isCloudflareError(err, cloudflareRecordNotFound) {
+			return errors.Wrapf(err, "failed to delete DNS record %s", name)
+		}
+	}
+	u.logger.WithField("record", name).Info("DNS record deleted")
+	return nil
+}
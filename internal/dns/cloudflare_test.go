@@ -0,0 +1,172 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCloudflare is an in-memory Cloudflare DNS records API: identical records cannot be created twice and unknown
+// record IDs are not found
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	records map[string]cloudflareRecord
+	nextID  int
+	// concurrent, if set, changes the records before the next write request, as another writer would
+	concurrent func(f *fakeCloudflare)
+}
+
+func (f *fakeCloudflare) fail(w http.ResponseWriter, status, code int, message string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"success":false,"errors":[{"code":%d,"message":%q}],"result":null}`, code, message)
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		f.fail(w, http.StatusForbidden, 10000, "Authentication error")
+		return
+	}
+	if r.Method != http.MethodGet && f.concurrent != nil {
+		f.concurrent(f)
+		f.concurrent = nil
+	}
+	var result interface{}
+	id := strings.TrimPrefix(r.URL.Path, "/zones/test-zone/dns_records/")
+	if _, ok := f.records[id]; !ok && (r.Method == http.MethodPut || r.Method == http.MethodDelete) {
+		f.fail(w, http.StatusNotFound, cloudflareRecordNotFound, "Record does not exist.")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		found := []cloudflareRecord{}
+		for _, record := range f.records {
+			if record.Name == r.URL.Query().Get("name") && record.Type == r.URL.Query().Get("type") {
+				found = append(found, record)
+			}
+		}
+		result = found
+	case http.MethodPost, http.MethodPut:
+		var record cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&record) //nolint:errcheck
+		for _, existing := range f.records {
+			if existing.ID != id && existing.Type == record.Type && existing.Name == record.Name && existing.Content == record.Content {
+				f.fail(w, http.StatusBadRequest, cloudflareRecordExists, "An identical record already exists.")
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			f.nextID++
+			id = fmt.Sprintf("id-%d", f.nextID)
+		}
+		record.ID = id
+		f.records[id] = record
+		result = record
+	case http.MethodDelete:
+		delete(f.records, id)
+		result = map[string]string{"id": id}
+	}
+	data, _ := json.Marshal(result) //nolint:errchkjson
+	fmt.Fprintf(w, `{"success":true,"errors":[],"result":%s}`, data)
+}
+
+func newTestCloudflare(t *testing.T, token string, records ...cloudflareRecord) (*cloudflareUpdater, *fakeCloudflare) {
+	fake := &fakeCloudflare{records: map[string]cloudflareRecord{}}
+	for _, record := range records {
+		fake.records[record.ID] = record
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	cfg := &config.Config{DNSZone: "test-zone", DNSDomain: "egress.example.com", DNSAPIToken: token, ClusterName: "test-cluster"}
+	return newCloudflareUpdater(server.Client(), server.URL, logrus.NewEntry(logrus.New()), cfg), fake
+}
+
+const cloudflareName = "node-1.egress.example.com"
+
+var cloudflareOwner = cloudflareRecord{ID: "owner", Type: "TXT", Name: cloudflareName, Content: "heritage=kubeip,kubeip/owner=test-cluster"}
+
+func TestCloudflareUpdater_Upsert(t *testing.T) {
+	t.Run("create record", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token")
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Len(t, fake.records, 2)
+		assert.Equal(t, cloudflareRecord{ID: "id-2", Type: "A", Name: cloudflareName, Content: "1.1.1.1", TTL: defaultTTL}, fake.records["id-2"])
+	})
+	t.Run("update owned record", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token", cloudflareOwner, cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "2.2.2.2"})
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Len(t, fake.records, 2)
+		assert.Equal(t, "1.1.1.1", fake.records["a"].Content)
+	})
+	t.Run("record not owned", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token", cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "2.2.2.2"})
+		err := u.Upsert(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, errors.Is(err, ErrRecordNotOwned))
+		assert.Equal(t, "2.2.2.2", fake.records["a"].Content)
+	})
+	t.Run("records created concurrently", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token")
+		fake.concurrent = func(f *fakeCloudflare) {
+			f.records["owner"] = cloudflareOwner
+			f.records["a"] = cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "1.1.1.1"}
+		}
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Len(t, fake.records, 2)
+	})
+	t.Run("record deleted concurrently", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token", cloudflareOwner, cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "2.2.2.2"})
+		fake.concurrent = func(f *fakeCloudflare) {
+			delete(f.records, "a")
+		}
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		assert.Len(t, fake.records, 2)
+		assert.Equal(t, "1.1.1.1", fake.records["id-1"].Content)
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		u, _ := newTestCloudflare(t, "invalid-token")
+		err := u.Upsert(context.Background(), "node-1", "1.1.1.1")
+		var cfErr *cloudflareError
+		require.True(t, errors.As(err, &cfErr))
+		assert.Equal(t, http.StatusForbidden, cfErr.status)
+		assert.ErrorContains(t, err, "Authentication error")
+	})
+}
+
+func TestCloudflareUpdater_Delete(t *testing.T) {
+	t.Run("delete owned record", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token", cloudflareOwner, cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "1.1.1.1"})
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, fake.records)
+	})
+	t.Run("record points to another address", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token", cloudflareOwner, cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "2.2.2.2"})
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		assert.Len(t, fake.records, 2)
+	})
+	t.Run("record deleted concurrently", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token", cloudflareOwner, cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "1.1.1.1"})
+		fake.concurrent = func(f *fakeCloudflare) {
+			delete(f.records, "a")
+		}
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, fake.records)
+	})
+	t.Run("record not owned", func(t *testing.T) {
+		u, fake := newTestCloudflare(t, "test-token", cloudflareRecord{ID: "a", Type: "A", Name: cloudflareName, Content: "1.1.1.1"})
+		err := u.Delete(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, errors.Is(err, ErrRecordNotOwned))
+		assert.Len(t, fake.records, 1)
+	})
+}
@@ -0,0 +1,198 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	managedByLabel   = "app.kubernetes.io/managed-by"
+	managedByValue   = "kubeip"
+	nodeLabel        = "kubeip.com/node"
+	endpointPrefix   = "kubeip-"
+	defaultNamespace = "default"
+)
+
+// DNSEndpointResource is the external-dns DNSEndpoint custom resource
+var DNSEndpointResource = schema.GroupVersionResource{Group: "externaldns.k8s.io", Version: "v1alpha1", Resource: "dnsendpoints"}
+
+type externalDNSUpdater struct {
+	client    dynamic.Interface
+	namespace string
+	domain    string
+	ttl       int64
+	logger    *logrus.Entry
+}
+
+// NewExternalDNSUpdater returns an updater publishing node records as external-dns DNSEndpoint resources;
+// an existing external-dns deployment (with --source=crd) manages the actual DNS records
+func NewExternalDNSUpdater(client dynamic.Interface, logger *logrus.Entry, cfg *config.Config) (Updater, error) {
+	if client == nil {
+		return nil, errors.New("kubernetes dynamic client is required for external-dns")
+	}
+	if cfg.DNSDomain == "" {
+		return nil, errors.New("DNS domain is required for external-dns")
+	}
+	namespace := cfg.DNSNamespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	ttl := int64(cfg.DNSTTL)
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &externalDNSUpdater{
+		client:    client,
+		namespace: namespace,
+		domain:    cfg.DNSDomain,
+		ttl:       ttl,
+		logger:    logger,
+	}, nil
+}
+
+// EndpointName returns the DNSEndpoint resource name of the node
+func EndpointName(nodeName string) string {
+	return endpointPrefix + nodeName
+}
+
+func (u *externalDNSUpdater) endpoint(nodeName, address string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": DNSEndpointResource.GroupVersion().String(),
+		"kind":       "DNSEndpoint",
+		"metadata": map[string]interface{}{
+			"name":      EndpointName(nodeName),
+			"namespace": u.namespace,
+			"labels": map[string]interface{}{
+				managedByLabel: managedByValue,
+				nodeLabel:      nodeName,
+			},
+		},
+		"spec": map[string]interface{}{
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"dnsName":    strings.TrimSuffix(RecordName(nodeName, u.domain), "."),
+					"recordType": RecordType(address),
+					"recordTTL":  u.ttl,
+					"targets":    []interface{}{address},
+				},
+			},
+		},
+	}}
+}
+
+// targets returns the targets of the first endpoint of the DNSEndpoint resource
+func targets(obj *unstructured.Unstructured) []interface{} {
+	endpoints, _, _ := unstructured.NestedSlice(obj.Object, "spec", "endpoints") //nolint:errcheck
+	if len(endpoints) == 0 {
+		return nil
+	}
+	endpoint, ok := endpoints[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	t, _, _ := unstructured.NestedSlice(endpoint, "targets") //nolint:errcheck
+	return t
+}
+
+func owned(obj *unstructured.Unstructured) bool {
+	return obj.GetLabels()[managedByLabel] == managedByValue
+}
+
+// isWriteConflict reports if the write lost a race with another writer of the DNSEndpoint resource
+func isWriteConflict(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
+
+// Upsert creates or updates the DNSEndpoint resource of the node; resources not created by kubeip are never modified;
+// writes conflicting with another writer are retried from the current resource
+func (u *externalDNSUpdater) Upsert(ctx context.Context, nodeName, address string) error {
+	resources := u.client.Resource(DNSEndpointResource).Namespace(u.namespace)
+	desired := u.endpoint(nodeName, address)
+
+	changed := false
+	err := retry.OnError(retry.DefaultRetry, isWriteConflict, func() error {
+		current, err := resources.Get(ctx, desired.GetName(), metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			if _, err = resources.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+				return errors.Wrapf(err, "failed to create DNSEndpoint %s/%s", u.namespace, desired.GetName())
+			}
+		case err != nil:
+			return errors.Wrapf(err, "failed to get DNSEndpoint %s/%s", u.namespace, desired.GetName())
+		case !owned(current):
+			return errors.Wrapf(ErrRecordNotOwned, "DNSEndpoint %s/%s", u.namespace, desired.GetName())
+		case reflect.DeepEqual(current.Object["spec"], desired.Object["spec"]):
+			return nil
+		default:
+			current.Object["spec"] = desired.Object["spec"]
+			if _, err = resources.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+				return errors.Wrapf(err, "failed to update DNSEndpoint %s/%s", u.namespace, desired.GetName())
+			}
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if !changed {
+		u.logger.WithField("endpoint", desired.GetName()).Debug("DNSEndpoint up to date")
+		return nil
+	}
+	u.logger.WithFields(logrus.Fields{
+		"endpoint": fmt.Sprintf("%s/%s", u.namespace, desired.GetName()),
+		"address":  address,
+	}).Info("DNSEndpoint updated")
+	return nil
+}
+
+// Delete removes the DNSEndpoint resource of the node if it still points to the address; the deletion is conditional
+// on the resource version, so a resource updated concurrently is checked again
+func (u *externalDNSUpdater) Delete(ctx context.Context, nodeName, address string) error {
+	resources := u.client.Resource(DNSEndpointResource).Namespace(u.namespace)
+	name := EndpointName(nodeName)
+
+	deleted := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := resources.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get DNSEndpoint %s/%s", u.namespace, name)
+		}
+		if !owned(current) {
+			return errors.Wrapf(ErrRecordNotOwned, "DNSEndpoint %s/%s", u.namespace, name)
+		}
+		if t := targets(current); len(t) != 1 || t[0] != address {
+			u.logger.WithField("endpoint", name).Debug("DNSEndpoint points to another address, skipping deletion")
+			return nil
+		}
+		version := current.GetResourceVersion()
+		err = resources.Delete(ctx, name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &version}})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete DNSEndpoint %s/%s", u.namespace, name)
+		}
+		deleted = err == nil
+		return nil
+	})
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	if deleted {
+		u.logger.WithField("endpoint", name).Info("DNSEndpoint deleted")
+	}
+	return nil
+}
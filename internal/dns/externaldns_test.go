@@ -0,0 +1,139 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestExternalDNS(t *testing.T, objects ...runtime.Object) (Updater, *dynamicfake.FakeDynamicClient) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{DNSEndpointResource: "DNSEndpointList"}, objects...)
+	u, err := NewExternalDNSUpdater(client, logrus.NewEntry(logrus.New()), &config.Config{DNSDomain: "egress.example.com", DNSNamespace: "kubeip"})
+	require.NoError(t, err)
+	return u, client
+}
+
+func getEndpoint(t *testing.T, client *dynamicfake.FakeDynamicClient) (*unstructured.Unstructured, error) {
+	t.Helper()
+	return client.Resource(DNSEndpointResource).Namespace("kubeip").Get(context.Background(), "kubeip-node-1", metav1.GetOptions{}) //nolint:wrapcheck
+}
+
+func TestExternalDNSUpdater(t *testing.T) {
+	u, client := newTestExternalDNS(t)
+
+	// create
+	require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+	obj, err := getEndpoint(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"1.1.1.1"}, targets(obj))
+	assert.Equal(t, "kubeip", obj.GetLabels()[managedByLabel])
+	endpoints, _, _ := unstructured.NestedSlice(obj.Object, "spec", "endpoints") //nolint:errcheck
+	assert.Equal(t, "node-1.egress.example.com", endpoints[0].(map[string]interface{})["dnsName"])
+
+	// update
+	require.NoError(t, u.Upsert(context.Background(), "node-1", "2.2.2.2"))
+	obj, err = getEndpoint(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"2.2.2.2"}, targets(obj))
+
+	// delete with stale address is skipped
+	require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+	_, err = getEndpoint(t, client)
+	require.NoError(t, err)
+
+	// delete
+	require.NoError(t, u.Delete(context.Background(), "node-1", "2.2.2.2"))
+	_, err = getEndpoint(t, client)
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestExternalDNSUpdater_notOwned(t *testing.T) {
+	foreign := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "externaldns.k8s.io/v1alpha1",
+		"kind":       "DNSEndpoint",
+		"metadata":   map[string]interface{}{"name": "kubeip-node-1", "namespace": "kubeip"},
+		"spec": map[string]interface{}{
+			"endpoints": []interface{}{map[string]interface{}{"dnsName": "node-1.egress.example.com", "targets": []interface{}{"1.1.1.1"}}},
+		},
+	}}
+	u, client := newTestExternalDNS(t, foreign)
+
+	err := u.Upsert(context.Background(), "node-1", "2.2.2.2")
+	assert.True(t, errors.Is(err, ErrRecordNotOwned))
+	err = u.Delete(context.Background(), "node-1", "1.1.1.1")
+	assert.True(t, errors.Is(err, ErrRecordNotOwned))
+	obj, err := getEndpoint(t, client)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"1.1.1.1"}, targets(obj))
+}
+
+func TestExternalDNSUpdater_concurrent(t *testing.T) {
+	conflict := apierrors.NewConflict(DNSEndpointResource.GroupResource(), "kubeip-node-1", errors.New("the object has been modified"))
+
+	t.Run("update conflict is retried", func(t *testing.T) {
+		u, client := newTestExternalDNS(t)
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		updates := 0
+		client.PrependReactor("update", "dnsendpoints", func(k8stesting.Action) (bool, runtime.Object, error) {
+			updates++
+			return updates == 1, nil, conflict
+		})
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "2.2.2.2"))
+		assert.Equal(t, 2, updates)
+		obj, err := getEndpoint(t, client)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"2.2.2.2"}, targets(obj))
+	})
+	t.Run("created concurrently", func(t *testing.T) {
+		u, client := newTestExternalDNS(t)
+		other, ok := u.(*externalDNSUpdater)
+		require.True(t, ok)
+		client.PrependReactor("create", "dnsendpoints", func(k8stesting.Action) (bool, runtime.Object, error) {
+			// another writer created the resource since it was read
+			require.NoError(t, client.Tracker().Create(DNSEndpointResource, other.endpoint("node-1", "1.1.1.1"), "kubeip"))
+			return true, nil, apierrors.NewAlreadyExists(DNSEndpointResource.GroupResource(), "kubeip-node-1")
+		})
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "2.2.2.2"))
+		obj, err := getEndpoint(t, client)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"2.2.2.2"}, targets(obj))
+	})
+	t.Run("conditional delete of endpoint updated concurrently", func(t *testing.T) {
+		u, client := newTestExternalDNS(t)
+		other, ok := u.(*externalDNSUpdater)
+		require.True(t, ok)
+		require.NoError(t, u.Upsert(context.Background(), "node-1", "1.1.1.1"))
+		client.PrependReactor("delete", "dnsendpoints", func(k8stesting.Action) (bool, runtime.Object, error) {
+			// the resource was updated to another address since it was read: the resource version does not match
+			require.NoError(t, client.Tracker().Update(DNSEndpointResource, other.endpoint("node-1", "2.2.2.2"), "kubeip"))
+			return true, nil, conflict
+		})
+		require.NoError(t, u.Delete(context.Background(), "node-1", "1.1.1.1"))
+		obj, err := getEndpoint(t, client)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"2.2.2.2"}, targets(obj))
+	})
+	t.Run("forbidden", func(t *testing.T) {
+		u, client := newTestExternalDNS(t)
+		client.PrependReactor("*", "dnsendpoints", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(DNSEndpointResource.GroupResource(), "kubeip-node-1", errors.New("RBAC: access denied"))
+		})
+		err := u.Upsert(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, apierrors.IsForbidden(err))
+		err = u.Delete(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, apierrors.IsForbidden(err))
+	})
+}
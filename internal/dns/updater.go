@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	ProviderCloudDNS    = "clouddns"
+	ProviderCloudflare  = "cloudflare"
+	ProviderExternalDNS = "external-dns"
+
+	recordTypeA    = "A"
+	recordTypeAAAA = "AAAA"
+	recordTypeTXT  = "TXT"
+	defaultOwner   = "default"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown DNS provider")
+	ErrRecordNotOwned  = errors.New("DNS record exists and is not owned by kubeip")
+)
+
+// Updater keeps DNS records in sync with the static public IP addresses assigned to nodes
+type Updater interface {
+	// Upsert creates or updates the node record pointing to the address
+	Upsert(ctx context.Context, nodeName, address string) error
+	// Delete removes the node record if it still points to the address
+	Delete(ctx context.Context, nodeName, address string) error
+}
+
+// NewUpdater returns the DNS updater of the configured provider or nil if DNS updates are disabled;
+// the dynamic client is only used by the external-dns provider
+func NewUpdater(ctx context.Context, logger *logrus.Entry, cfg *config.Config, dynamicClient dynamic.Interface) (Updater, error) {
+	switch cfg.DNSProvider {
+	case "":
+		return nil, nil //nolint:nilnil
+	case ProviderCloudDNS:
+		return NewCloudDNSUpdater(ctx, logger, cfg)
+	case ProviderCloudflare:
+		return NewCloudflareUpdater(logger, cfg)
+	case ProviderExternalDNS:
+		return NewExternalDNSUpdater(dynamicClient, logger, cfg)
+	}
+	return nil, errors.Wrapf(ErrUnknownProvider, "%s, supported providers: %s, %s, %s", cfg.DNSProvider, ProviderCloudDNS, ProviderCloudflare, ProviderExternalDNS)
+}
+
+// RecordName returns the fully qualified record name of the node: <node>.<domain>.
+func RecordName(nodeName, domain string) string {
+	return fmt.Sprintf("%s.%s.", nodeName, strings.Trim(domain, "."))
+}
+
+// RecordType returns the record type of the address: A for IPv4, AAAA for IPv6
+func RecordType(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return recordTypeAAAA
+	}
+	return recordTypeA
+}
+
+// OwnerValue returns the TXT record value marking records created by kubeip for the owner (cluster)
+func OwnerValue(owner string) string {
+	if owner == "" {
+		owner = defaultOwner
+	}
+	return fmt.Sprintf(`"heritage=kubeip,kubeip/owner=%s"`, owner)
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sqsMaxMessages     = 10
+	sqsWaitTimeSeconds = 20
+)
+
+// eventBridgeActions are the EC2 API calls changing the association of elastic IP addresses
+var eventBridgeActions = map[string]bool{
+	"AssociateAddress":    true,
+	"DisassociateAddress": true,
+	"ReleaseAddress":      true,
+}
+
+// eventBridgeEvent is the EventBridge event of an EC2 API call recorded by CloudTrail
+type eventBridgeEvent struct {
+	Source string `json:"source"`
+	Detail struct {
+		EventName         string `json:"eventName"`
+		RequestParameters struct {
+			InstanceID    string `json:"instanceId"`
+			PublicIP      string `json:"publicIp"`
+			AllocationID  string `json:"allocationId"`
+			AssociationID string `json:"associationId"`
+		} `json:"requestParameters"`
+	} `json:"detail"`
+}
+
+type sqsClient interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+}
+
+type eventBridgeReceiver struct {
+	client   sqsClient
+	queueURL string
+	logger   *logrus.Entry
+}
+
+// NewEventBridgeWatcher returns a watcher receiving EC2 elastic IP events from the SQS queue targeted by an EventBridge rule
+func NewEventBridgeWatcher(ctx context.Context, logger *logrus.Entry, cfg *config.Config, relay Relay, elector lease.Elector) (Watcher, error) {
+	if cfg.EventsSource == "" {
+		return nil, errors.New("SQS queue URL is required for EventBridge change detection")
+	}
+	endpoint, err := sqsEndpoint(cfg.EventsSource)
+	if err != nil {
+		return nil, err
+	}
+	awsCfg, err := cloud.LoadAWSConfig(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	// the queue URL names the SQS endpoint: regional, VPC endpoint or LocalStack
+	client := sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+	r := &eventBridgeReceiver{client: client, queueURL: cfg.EventsSource, logger: logger}
+	return &watcher{receive: r.receive, relay: relay, elector: elector, logger: logger}, nil
+}
+
+// sqsEndpoint returns the endpoint of the SQS queue URL
+func sqsEndpoint(queueURL string) (string, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Host == "" {
+		return "", errors.Errorf("invalid SQS queue URL: %s", queueURL)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// parseEventBridgeEvent returns the change of the event or false if the event is not an elastic IP change
+func parseEventBridgeEvent(body string) (Change, bool) {
+	var event eventBridgeEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return Change{}, false
+	}
+	if event.Source != "aws.ec2" || !eventBridgeActions[event.Detail.EventName] {
+		return Change{}, false
+	}
+	params := event.Detail.RequestParameters
+	return Change{
+		Instance:      params.InstanceID,
+		Address:       params.PublicIP,
+		AllocationID:  params.AllocationID,
+		AssociationID: params.AssociationID,
+		Action:        event.Detail.EventName,
+	}, true
+}
+
+// receive returns the changes of the received messages and deletes all messages; a VPC disassociation names the
+// association only, resolved by the relay to the node recording it
+func (r *eventBridgeReceiver) receive(ctx context.Context) ([]Change, error) {
+	output, err := r.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(r.queueURL),
+		MaxNumberOfMessages: sqsMaxMessages,
+		WaitTimeSeconds:     sqsWaitTimeSeconds,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to receive messages from %s", r.queueURL)
+	}
+	if len(output.Messages) == 0 {
+		return nil, nil
+	}
+	changes := make([]Change, 0, len(output.Messages))
+	entries := make([]types.DeleteMessageBatchRequestEntry, 0, len(output.Messages))
+	for _, message := range output.Messages {
+		entries = append(entries, types.DeleteMessageBatchRequestEntry{Id: message.MessageId, ReceiptHandle: message.ReceiptHandle})
+		if change, ok := parseEventBridgeEvent(aws.ToString(message.Body)); ok {
+			changes = append(changes, change)
+		}
+	}
+	deleted, err := r.client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: aws.String(r.queueURL), Entries: entries})
+	switch {
+	case err != nil:
+		r.logger.WithError(err).Warn("failed to delete SQS messages")
+	case len(deleted.Failed) > 0:
+		r.logger.WithField("failed", len(deleted.Failed)).Warn("failed to delete some SQS messages")
+	}
+	return changes, nil
+}
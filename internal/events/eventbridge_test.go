@@ -0,0 +1,121 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/kubeip-events"
+
+const testDisassociateEvent = `{"source":"aws.ec2","detail-type":"AWS API Call via CloudTrail",` +
+	`"detail":{"eventName":"DisassociateAddress","requestParameters":{"associationId":"eipassoc-1"}}}`
+
+func Test_parseEventBridgeEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		want   Change
+		wantOk bool
+	}{
+		{
+			name:   "disassociate by association ID",
+			body:   testDisassociateEvent,
+			want:   Change{AssociationID: "eipassoc-1", Action: "DisassociateAddress"},
+			wantOk: true,
+		},
+		{
+			name:   "associate to instance",
+			body:   `{"source":"aws.ec2","detail":{"eventName":"AssociateAddress","requestParameters":{"instanceId":"i-1","publicIp":"1.1.1.1"}}}`,
+			want:   Change{Instance: "i-1", Address: "1.1.1.1", Action: "AssociateAddress"},
+			wantOk: true,
+		},
+		{
+			name: "other API call",
+			body: `{"source":"aws.ec2","detail":{"eventName":"RunInstances"}}`,
+		},
+		{
+			name: "other source",
+			body: `{"source":"aws.s3","detail":{"eventName":"ReleaseAddress"}}`,
+		},
+		{
+			name: "invalid JSON",
+			body: `not json`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseEventBridgeEvent(tt.body)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// fakeSQS returns the queued messages once and records the deleted receipt handles
+type fakeSQS struct {
+	messages []types.Message
+	deleted  []string
+}
+
+func (f *fakeSQS) ReceiveMessage(_ context.Context, params *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if aws.ToString(params.QueueUrl) != testQueueURL {
+		return nil, errors.New("unknown queue")
+	}
+	messages := f.messages
+	f.messages = nil
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (f *fakeSQS) DeleteMessageBatch(_ context.Context, params *sqs.DeleteMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	for _, entry := range params.Entries {
+		f.deleted = append(f.deleted, aws.ToString(entry.ReceiptHandle))
+	}
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+func testMessage(id, body string) types.Message {
+	return types.Message{MessageId: aws.String("m-" + id), ReceiptHandle: aws.String("r-" + id), Body: aws.String(body)}
+}
+
+func TestEventBridgeReceiver_receive(t *testing.T) {
+	client := &fakeSQS{messages: []types.Message{
+		testMessage("1", testDisassociateEvent),
+		testMessage("2", `{"source":"aws.ec2","detail":{"eventName":"AssociateAddress","requestParameters":{"instanceId":"i-1"}}}`),
+		testMessage("3", `{"source":"aws.ec2","detail":{"eventName":"AssociateAddress","requestParameters":{"instanceId":"i-2"}}}`),
+		testMessage("4", `{"source":"aws.ec2","detail":{"eventName":"RunInstances"}}`),
+	}}
+	r := &eventBridgeReceiver{client: client, queueURL: testQueueURL, logger: logrus.NewEntry(logrus.New())}
+
+	// all messages are deleted, the disassociation keeps its association ID for the relay to resolve
+	changes, err := r.receive(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{AssociationID: "eipassoc-1", Action: "DisassociateAddress"},
+		{Instance: "i-1", Action: "AssociateAddress"},
+		{Instance: "i-2", Action: "AssociateAddress"},
+	}, changes)
+	assert.Equal(t, []string{"r-1", "r-2", "r-3", "r-4"}, client.deleted)
+
+	// nothing is deleted once the queue is empty
+	changes, err = r.receive(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Len(t, client.deleted, 4)
+}
+
+func Test_sqsEndpoint(t *testing.T) {
+	endpoint, err := sqsEndpoint(testQueueURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://sqs.us-east-1.amazonaws.com", endpoint)
+
+	_, err = sqsEndpoint("kubeip-events")
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+const pubSubMaxMessages = 10
+
+// pubSubMethods are the Compute Engine API methods (audit log method name suffixes) changing the external IP address of instances
+var pubSubMethods = []string{
+	"compute.instances.addAccessConfig",
+	"compute.instances.deleteAccessConfig",
+	"compute.instances.updateAccessConfig",
+	"compute.addresses.delete",
+}
+
+// auditLogEntry is the Cloud Audit Logs entry exported to Pub/Sub by a log sink
+type auditLogEntry struct {
+	ProtoPayload struct {
+		MethodName   string `json:"methodName"`
+		ResourceName string `json:"resourceName"`
+	} `json:"protoPayload"`
+}
+
+type pubSubClient interface {
+	Pull(ctx context.Context, subscription string, maxMessages int64) ([]*pubsub.ReceivedMessage, error)
+	Acknowledge(ctx context.Context, subscription string, ackIDs []string) error
+}
+
+type pubSubService struct {
+	service *pubsub.Service
+}
+
+func (s *pubSubService) Pull(ctx context.Context, subscription string, maxMessages int64) ([]*pubsub.ReceivedMessage, error) {
+	resp, err := s.service.Projects.Subscriptions.Pull(subscription, &pubsub.PullRequest{MaxMessages: maxMessages}).Context(ctx).Do()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return resp.ReceivedMessages, nil
+}
+
+func (s *pubSubService) Acknowledge(ctx context.Context, subscription string, ackIDs []string) error {
+	_, err := s.service.Projects.Subscriptions.Acknowledge(subscription, &pubsub.AcknowledgeRequest{AckIds: ackIDs}).Context(ctx).Do()
+	return err //nolint:wrapcheck
+}
+
+type pubSubReceiver struct {
+	client       pubSubClient
+	subscription string
+	logger       *logrus.Entry
+}
+
+// NewPubSubWatcher returns a watcher receiving Compute Engine audit log entries from a Pub/Sub subscription of a log sink topic
+func NewPubSubWatcher(ctx context.Context, logger *logrus.Entry, cfg *config.Config, relay Relay, elector lease.Elector) (Watcher, error) {
+	if cfg.EventsSource == "" {
+		return nil, errors.New("Pub/Sub subscription is required for Pub/Sub change detection")
+	}
+	opts, err := cloud.GCPClientOptions(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	service, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Google Cloud Pub/Sub client")
+	}
+	r := &pubSubReceiver{
+		client:       &pubSubService{service: service},
+		subscription: cfg.EventsSource,
+		logger:       logger,
+	}
+	return &watcher{receive: r.receive, relay: relay, elector: elector, logger: logger}, nil
+}
+
+// parseAuditLogEntry returns the change of the base64 encoded audit log entry or false if the entry is not an external IP change
+func parseAuditLogEntry(data string) (Change, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return Change{}, false
+	}
+	var entry auditLogEntry
+	if err = json.Unmarshal(decoded, &entry); err != nil {
+		return Change{}, false
+	}
+	for _, method := range pubSubMethods {
+		if !strings.HasSuffix(entry.ProtoPayload.MethodName, method) {
+			continue
+		}
+		change := Change{Action: entry.ProtoPayload.MethodName}
+		// projects/<project>/zones/<zone>/instances/<instance>
+		parts := strings.Split(entry.ProtoPayload.ResourceName, "/")
+		if len(parts) > 1 && parts[len(parts)-2] == "instances" {
+			change.Instance = parts[len(parts)-1]
+		}
+		return change, true
+	}
+	return Change{}, false
+}
+
+// receive returns the changes of the pulled messages and acknowledges all messages; changes naming no instance (e.g.
+// the deletion of an address) are dropped
+func (w *pubSubReceiver) receive(ctx context.Context) ([]Change, error) {
+	messages, err := w.client.Pull(ctx, w.subscription, pubSubMaxMessages)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to pull messages from %s", w.subscription)
+	}
+	changes := make([]Change, 0, len(messages))
+	ack := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ack = append(ack, message.AckId)
+		if message.Message == nil {
+			continue
+		}
+		change, ok := parseAuditLogEntry(message.Message.Data)
+		switch {
+		case ok && change.Instance != "":
+			changes = append(changes, change)
+		case ok:
+			w.logger.WithField("action", change.Action).Debug("dropping audit log entry naming no instance")
+		}
+	}
+	if len(ack) > 0 {
+		if err = w.client.Acknowledge(ctx, w.subscription, ack); err != nil {
+			w.logger.WithError(err).Warn("failed to acknowledge Pub/Sub messages")
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+func auditLogData(methodName, resourceName string) string {
+	entry := `{"protoPayload":{"methodName":"` + methodName + `","resourceName":"` + resourceName + `"}}`
+	return base64.StdEncoding.EncodeToString([]byte(entry))
+}
+
+func Test_parseAuditLogEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		want   Change
+		wantOk bool
+	}{
+		{
+			name:   "delete access config",
+			data:   auditLogData("v1.compute.instances.deleteAccessConfig", "projects/p/zones/us-central1-a/instances/node-1"),
+			want:   Change{Instance: "node-1", Action: "v1.compute.instances.deleteAccessConfig"},
+			wantOk: true,
+		},
+		{
+			name:   "delete address",
+			data:   auditLogData("v1.compute.addresses.delete", "projects/p/regions/us-central1/addresses/static-1"),
+			want:   Change{Action: "v1.compute.addresses.delete"},
+			wantOk: true,
+		},
+		{
+			name: "other method",
+			data: auditLogData("v1.compute.instances.insert", "projects/p/zones/us-central1-a/instances/node-1"),
+		},
+		{
+			name: "invalid base64",
+			data: "!",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseAuditLogEntry(tt.data)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type fakePubSub struct {
+	messages []*pubsub.ReceivedMessage
+	acked    []string
+}
+
+func (f *fakePubSub) Pull(_ context.Context, _ string, _ int64) ([]*pubsub.ReceivedMessage, error) {
+	messages := f.messages
+	f.messages = nil
+	return messages, nil
+}
+
+func (f *fakePubSub) Acknowledge(_ context.Context, _ string, ackIDs []string) error {
+	f.acked = append(f.acked, ackIDs...)
+	return nil
+}
+
+func TestPubSubWatcher_receive(t *testing.T) {
+	client := &fakePubSub{messages: []*pubsub.ReceivedMessage{
+		{AckId: "a-1", Message: &pubsub.PubsubMessage{MessageId: "m-1", Data: auditLogData("v1.compute.instances.deleteAccessConfig", "projects/p/zones/z/instances/node-1")}},
+		{AckId: "a-2", Message: &pubsub.PubsubMessage{MessageId: "m-2", Data: auditLogData("v1.compute.instances.deleteAccessConfig", "projects/p/zones/z/instances/node-2")}},
+		{AckId: "a-3", Message: &pubsub.PubsubMessage{MessageId: "m-3", Data: auditLogData("v1.compute.addresses.delete", "projects/p/regions/r/addresses/static-1")}},
+		{AckId: "a-4", Message: &pubsub.PubsubMessage{MessageId: "m-4", Data: auditLogData("v1.compute.instances.insert", "projects/p/zones/z/instances/node-3")}},
+	}}
+	w := &pubSubReceiver{client: client, subscription: "projects/p/subscriptions/kubeip", logger: logrus.NewEntry(logrus.New())}
+
+	// all messages are acknowledged, the deletion of an address is dropped
+	changes, err := w.receive(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Instance: "node-1", Action: "v1.compute.instances.deleteAccessConfig"},
+		{Instance: "node-2", Action: "v1.compute.instances.deleteAccessConfig"},
+	}, changes)
+	assert.Equal(t, []string{"a-1", "a-2", "a-3", "a-4"}, client.acked)
+}
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	typesv1 "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ChangeAnnotation is the node annotation handing a change received by the elected agent over to the agent of the node
+const ChangeAnnotation = "kubeip.com/address-change"
+
+// Relay hands the changes received by the elected agent, the single consumer of the queue (or subscription), over to
+// the agents of the nodes they concern
+type Relay interface {
+	// Forward hands the change over to the agents of the nodes it concerns; changes concerning no node are dropped
+	Forward(ctx context.Context, change Change) error
+	// Watch delivers the changes handed over to the agent, clearing them, until the context is done
+	Watch(ctx context.Context, changes chan<- Change) error
+}
+
+type nodeRelay struct {
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewNodeRelay returns a relay annotating the nodes a change concerns; the agent runs on the named node
+func NewNodeRelay(client kubernetes.Interface, nodeName string) Relay {
+	return &nodeRelay{client: client, nodeName: nodeName}
+}
+
+func (r *nodeRelay) patch(ctx context.Context, nodeName string, patch map[string]interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"metadata": patch})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal change patch")
+	}
+	_, err = r.client.CoreV1().Nodes().Patch(ctx, nodeName, typesv1.MergePatchType, data, metav1.PatchOptions{})
+	return errors.Wrapf(err, "failed to patch node %s", nodeName)
+}
+
+// concerns reports if the change concerns the node: its instance, or the address, allocation or association recorded
+// in its assignment status (a disassociation names the association only)
+func concerns(n *v1.Node, change Change) bool {
+	if change.Instance != "" {
+		// aws:///<zone>/<instance>, gce://<project>/<zone>/<instance>, or the instance mapping of a node without provider ID
+		providerID := n.Spec.ProviderID
+		if providerID == "" {
+			providerID = "/" + n.Annotations[nd.InstanceIDAnnotation]
+		}
+		if strings.HasSuffix(providerID, "/"+change.Instance) {
+			return true
+		}
+	}
+	status := map[string]string{
+		nd.AddressAnnotation:       change.Address,
+		nd.AllocationIDAnnotation:  change.AllocationID,
+		nd.AssociationIDAnnotation: change.AssociationID,
+	}
+	for annotation, value := range status {
+		if value != "" && n.Annotations[annotation] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *nodeRelay) Forward(ctx context.Context, change Change) error {
+	nodes, err := r.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list kubernetes nodes")
+	}
+	// the address may move between nodes: both the previous and the new holder reconcile
+	for i := range nodes.Items {
+		if !concerns(&nodes.Items[i], change) {
+			continue
+		}
+		err = r.patch(ctx, nodes.Items[i].Name, map[string]interface{}{
+			"annotations": map[string]string{ChangeAnnotation: change.Action},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *nodeRelay) Watch(ctx context.Context, changes chan<- Change) error {
+	for ctx.Err() == nil {
+		// the watch is opened before the node is checked, so no change is missed in between
+		w, err := r.client.CoreV1().Nodes().Watch(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", r.nodeName).String(),
+		})
+		if err != nil {
+			if !wait(ctx, retryDelay) {
+				break
+			}
+			continue
+		}
+		if n, err := r.client.CoreV1().Nodes().Get(ctx, r.nodeName, metav1.GetOptions{}); err == nil {
+			r.receive(ctx, n, changes)
+		}
+		r.watch(ctx, w, changes)
+		w.Stop()
+	}
+	return nil
+}
+
+// watch delivers the changes handed over on the events of the node until the API server closes the watch or the
+// context is done
+func (r *nodeRelay) watch(ctx context.Context, w watch.Interface, changes chan<- Change) {
+	for {
+		select {
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			if n, isNode := event.Object.(*v1.Node); isNode && n.Name == r.nodeName && event.Type != watch.Deleted {
+				r.receive(ctx, n, changes)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// receive delivers the change handed over to the agent, if any, once cleared
+func (r *nodeRelay) receive(ctx context.Context, n *v1.Node, changes chan<- Change) {
+	action, ok := n.Annotations[ChangeAnnotation]
+	if !ok {
+		return
+	}
+	// the resource version fails the patch when the elected agent hands a change over meanwhile, received on its event
+	err := r.patch(ctx, r.nodeName, map[string]interface{}{
+		"resourceVersion": n.ResourceVersion,
+		"annotations":     map[string]interface{}{ChangeAnnotation: nil},
+	})
+	if err != nil {
+		return
+	}
+	select {
+	case changes <- Change{Action: action}:
+	case <-ctx.Done():
+	}
+}
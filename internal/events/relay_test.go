@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	nd "github.com/doitintl/kubeip/internal/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeRelay_Forward(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2", Annotations: map[string]string{
+			nd.AddressAnnotation:       "2.2.2.2",
+			nd.AllocationIDAnnotation:  "eipalloc-2",
+			nd.AssociationIDAnnotation: "eipassoc-2",
+		}}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-2"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3", Annotations: map[string]string{nd.InstanceIDAnnotation: "i-3"}}},
+	)
+	relay := NewNodeRelay(client, "node-1")
+
+	tests := []struct {
+		name   string
+		change Change
+		want   []string
+	}{
+		{"instance outside the cluster", Change{Instance: "i-4", Action: "AssociateAddress"}, nil},
+		{"instance by provider ID", Change{Instance: "i-1", Action: "AssociateAddress"}, []string{"node-1"}},
+		{"instance by annotation", Change{Instance: "i-3", Action: "AssociateAddress"}, []string{"node-3"}},
+		{"association only", Change{AssociationID: "eipassoc-2", Action: "DisassociateAddress"}, []string{"node-2"}},
+		{"allocation only", Change{AllocationID: "eipalloc-2", Action: "ReleaseAddress"}, []string{"node-2"}},
+		{"address moved to another instance", Change{Instance: "i-1", Address: "2.2.2.2", Action: "AssociateAddress"}, []string{"node-1", "node-2"}},
+		{"unknown association", Change{AssociationID: "eipassoc-4", Action: "DisassociateAddress"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, relay.Forward(ctx, tt.change))
+			nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			var got []string
+			for i := range nodes.Items {
+				if action, ok := nodes.Items[i].Annotations[ChangeAnnotation]; ok {
+					assert.Equal(t, tt.change.Action, action)
+					got = append(got, nodes.Items[i].Name)
+					require.NoError(t, relay.(*nodeRelay).patch(ctx, nodes.Items[i].Name, map[string]interface{}{
+						"annotations": map[string]interface{}{ChangeAnnotation: nil},
+					}))
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNodeRelay_Watch(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-1"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2", Annotations: map[string]string{ChangeAnnotation: "AssociateAddress"}},
+			Spec: v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-2"}},
+	)
+	sender := NewNodeRelay(client, "node-1")
+	relay := NewNodeRelay(client, "node-2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan Change)
+	done := make(chan error)
+	go func() {
+		done <- relay.Watch(ctx, changes)
+	}()
+
+	// the change handed over before the watch is delivered and cleared
+	assert.Equal(t, Change{Action: "AssociateAddress"}, <-changes)
+	n, err := client.CoreV1().Nodes().Get(ctx, "node-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, n.Annotations, ChangeAnnotation)
+
+	// the change handed over during the watch is delivered to the agent of the node only
+	require.NoError(t, sender.Forward(ctx, Change{Instance: "i-1", Action: "DisassociateAddress"}))
+	require.NoError(t, sender.Forward(ctx, Change{Instance: "i-2", Action: "ReleaseAddress"}))
+	assert.Equal(t, Change{Action: "ReleaseAddress"}, <-changes)
+
+	cancel()
+	require.NoError(t, <-done)
+}
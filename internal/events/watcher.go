@@ -0,0 +1,136 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProviderEventBridge = "aws-eventbridge"
+	ProviderPubSub      = "gcp-pubsub"
+)
+
+var ErrUnknownProvider = errors.New("unknown events provider")
+
+var (
+	// retryDelay is the delay before receiving notifications again after a failure
+	retryDelay = 10 * time.Second
+	// pollInterval is the delay before receiving notifications again once none was received
+	pollInterval = 5 * time.Second
+)
+
+// Change is a change of a static public IP address reported by a cloud change notification
+type Change struct {
+	// Instance is the changed instance
+	Instance string
+	// Address is the changed address; empty if the notification does not name it
+	Address string
+	// AllocationID is the allocation of the changed elastic IP address (AWS); empty if the notification does not name it
+	AllocationID string
+	// AssociationID is the changed association of an elastic IP address (AWS); empty if the notification does not name it
+	AssociationID string
+	// Action is the cloud API call of the change
+	Action string
+}
+
+// Affects reports if the change concerns the instance or the address assigned to it
+func (c Change) Affects(instance, address string) bool {
+	if c.Instance != "" {
+		return c.Instance == instance
+	}
+	return c.Address != "" && c.Address == address
+}
+
+// Watcher receives cloud change notifications of static public IP addresses
+type Watcher interface {
+	// Watch delivers the changes concerning the instance to the channel until the context is done
+	Watch(ctx context.Context, instance string, changes chan<- Change) error
+}
+
+// NewWatcher returns the watcher of the configured events provider or nil if change detection is disabled; the
+// notifications are consumed by the agent elected by the elector only, the relay hands the changes over to the agents
+// of the nodes they concern
+func NewWatcher(ctx context.Context, logger *logrus.Entry, cfg *config.Config, relay Relay, elector lease.Elector) (Watcher, error) {
+	switch cfg.EventsProvider {
+	case "":
+		return nil, nil //nolint:nilnil
+	case ProviderEventBridge:
+		return NewEventBridgeWatcher(ctx, logger, cfg, relay, elector)
+	case ProviderPubSub:
+		return NewPubSubWatcher(ctx, logger, cfg, relay, elector)
+	}
+	return nil, errors.Wrapf(ErrUnknownProvider, "%s, supported providers: %s, %s", cfg.EventsProvider, ProviderEventBridge, ProviderPubSub)
+}
+
+// wait waits for the delay, returns false if the context is done
+func wait(ctx context.Context, delay time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// watcher consumes the notifications of a cloud provider on the elected agent
+type watcher struct {
+	// receive returns the changes of the next notifications, removed from the queue (or subscription)
+	receive func(ctx context.Context) ([]Change, error)
+	relay   Relay
+	elector lease.Elector
+	logger  *logrus.Entry
+}
+
+// Watch consumes the notifications while the agent is elected and delivers the changes handed over to the agent of the
+// node, by itself or by the elected agent of another node, until the context is done
+func (w *watcher) Watch(ctx context.Context, instance string, changes chan<- Change) error {
+	go w.elector.Run(ctx, w.consume)
+	handed := make(chan Change)
+	go func() {
+		for {
+			select {
+			case change := <-handed:
+				change.Instance = instance
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return w.relay.Watch(ctx, handed)
+}
+
+// consume receives the notifications until the context is done (or the lease lost) and hands every change over to the
+// agent of the node it concerns; a change concerning no node of the cluster is dropped
+func (w *watcher) consume(ctx context.Context) {
+	for ctx.Err() == nil {
+		received, err := w.receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.logger.WithError(err).Warn("failed to receive change notifications, retrying")
+			if !wait(ctx, retryDelay) {
+				return
+			}
+			continue
+		}
+		for _, change := range received {
+			if err = w.relay.Forward(ctx, change); err != nil {
+				w.logger.WithError(err).WithField("action", change.Action).Warn("failed to hand change over to the agent of the node")
+			}
+		}
+		if len(received) == 0 && !wait(ctx, pollInterval) {
+			return
+		}
+	}
+}
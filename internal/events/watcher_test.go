@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChange_Affects(t *testing.T) {
+	tests := []struct {
+		name   string
+		change Change
+		want   bool
+	}{
+		{"same instance", Change{Instance: "i-1", Address: "2.2.2.2"}, true},
+		{"other instance", Change{Instance: "i-2", Address: "1.1.1.1"}, false},
+		{"same address", Change{Address: "1.1.1.1"}, true},
+		{"other address", Change{Address: "2.2.2.2"}, false},
+		{"unknown instance and address", Change{Action: "DisassociateAddress"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.change.Affects("i-1", "1.1.1.1"))
+		})
+	}
+}
+
+type fakeRelay struct {
+	mu        sync.Mutex
+	forwarded []Change
+	handed    []Change
+}
+
+func (f *fakeRelay) Forward(_ context.Context, change Change) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.forwarded = append(f.forwarded, change)
+	return nil
+}
+
+func (f *fakeRelay) Watch(ctx context.Context, changes chan<- Change) error {
+	for _, change := range f.handed {
+		changes <- change
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// fakeElector runs the task right away, as the agent holding the lease
+type fakeElector struct{}
+
+func (fakeElector) Run(ctx context.Context, task func(ctx context.Context)) {
+	task(ctx)
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	pollInterval = 0
+	relay := &fakeRelay{handed: []Change{{Action: "DisassociateAddress"}}}
+	received := [][]Change{
+		{{Instance: "i-1", Action: "AssociateAddress"}, {AssociationID: "eipassoc-2", Action: "DisassociateAddress"}},
+	}
+	consumed := make(chan struct{})
+	var once sync.Once
+	receive := func(context.Context) ([]Change, error) {
+		if len(received) == 0 {
+			once.Do(func() { close(consumed) })
+			return nil, nil
+		}
+		changes := received[0]
+		received = received[1:]
+		return changes, nil
+	}
+	w := &watcher{receive: receive, relay: relay, elector: fakeElector{}, logger: logrus.NewEntry(logrus.New())}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan Change)
+	done := make(chan error)
+	go func() {
+		done <- w.Watch(ctx, "i-1", changes)
+	}()
+
+	// the change handed over to the agent is delivered for its instance
+	assert.Equal(t, Change{Instance: "i-1", Action: "DisassociateAddress"}, <-changes)
+	<-consumed
+	cancel()
+	require.NoError(t, <-done)
+	// every received change is handed over by the relay, the own instance included
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+	assert.Equal(t, []Change{
+		{Instance: "i-1", Action: "AssociateAddress"},
+		{AssociationID: "eipassoc-2", Action: "DisassociateAddress"},
+	}, relay.forwarded)
+}
@@ -0,0 +1,106 @@
+package firewall
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	allProtocols         = "-1"
+	errCodeDuplicateRule = "InvalidPermission.Duplicate"
+	errCodeRuleNotFound  = "InvalidPermission.NotFound"
+)
+
+type securityGroupClient interface {
+	AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error)
+	RevokeSecurityGroupIngress(ctx context.Context, params *ec2.RevokeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error)
+}
+
+type securityGroupSyncer struct {
+	client      securityGroupClient
+	groupID     string
+	clusterName string
+	logger      *logrus.Entry
+}
+
+// NewSecurityGroupSyncer returns a syncer adding an ingress rule (all traffic) per assigned address to an AWS security group
+func NewSecurityGroupSyncer(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Syncer, error) {
+	if cfg.FirewallName == "" {
+		return nil, errors.New("security group ID is required for AWS security group sync")
+	}
+	awsCfg, err := cloud.LoadAWSConfig(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return &securityGroupSyncer{
+		client:      cloud.NewEC2Client(awsCfg, cfg),
+		groupID:     cfg.FirewallName,
+		clusterName: cfg.ClusterName,
+		logger:      logger,
+	}, nil
+}
+
+func isAPIError(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// permission returns the ingress permission of the address: all protocols from the single host CIDR
+func (s *securityGroupSyncer) permission(nodeName, address string) []types.IpPermission {
+	permission := types.IpPermission{IpProtocol: aws.String(allProtocols)}
+	cidr := hostCIDR(address)
+	if cidr == address+"/128" {
+		permission.Ipv6Ranges = []types.Ipv6Range{{CidrIpv6: aws.String(cidr), Description: aws.String(description(s.clusterName, nodeName))}}
+	} else {
+		permission.IpRanges = []types.IpRange{{CidrIp: aws.String(cidr), Description: aws.String(description(s.clusterName, nodeName))}}
+	}
+	return []types.IpPermission{permission}
+}
+
+func (s *securityGroupSyncer) Allow(ctx context.Context, nodeName, address string) error {
+	_, err := s.client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(s.groupID),
+		IpPermissions: s.permission(nodeName, address),
+	})
+	if err != nil && !isAPIError(err, errCodeDuplicateRule) {
+		return errors.Wrapf(err, "failed to authorize %s in security group %s", address, s.groupID)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"node":           nodeName,
+		"address":        address,
+		"security-group": s.groupID,
+	}).Info("address allowed in security group")
+	return nil
+}
+
+func (s *securityGroupSyncer) Revoke(ctx context.Context, nodeName, address string) error {
+	_, err := s.client.RevokeSecurityGroupIngress(ctx, &ec2.RevokeSecurityGroupIngressInput{
+		GroupId:       aws.String(s.groupID),
+		IpPermissions: s.permission(nodeName, address),
+	})
+	if err != nil && !isAPIError(err, errCodeRuleNotFound) {
+		return errors.Wrapf(err, "failed to revoke %s from security group %s", address, s.groupID)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"node":           nodeName,
+		"address":        address,
+		"security-group": s.groupID,
+	}).Info("address revoked from security group")
+	return nil
+}
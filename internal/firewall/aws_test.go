@@ -0,0 +1,146 @@
+package firewall
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecurityGroupClient models the EC2 security group ingress API: authorizing an existing rule fails as a duplicate,
+// revoking a missing rule fails as not found and rules beyond the limit of the group are rejected
+type fakeSecurityGroupClient struct {
+	authorized []*ec2.AuthorizeSecurityGroupIngressInput
+	revoked    []*ec2.RevokeSecurityGroupIngressInput
+	// rules are the CIDRs of the ingress rules
+	rules  map[string]bool
+	limit  int
+	denied bool
+}
+
+func ruleCIDRs(permissions []types.IpPermission) []string {
+	var cidrs []string
+	for _, permission := range permissions {
+		for _, r := range permission.IpRanges {
+			cidrs = append(cidrs, aws.ToString(r.CidrIp))
+		}
+		for _, r := range permission.Ipv6Ranges {
+			cidrs = append(cidrs, aws.ToString(r.CidrIpv6))
+		}
+	}
+	return cidrs
+}
+
+func (f *fakeSecurityGroupClient) AuthorizeSecurityGroupIngress(_ context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, _ ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	if f.denied {
+		return nil, &smithy.GenericAPIError{Code: errCodeUnauthorized, Message: "You are not authorized to perform this operation."}
+	}
+	f.authorized = append(f.authorized, params)
+	if f.rules == nil {
+		f.rules = make(map[string]bool)
+	}
+	cidrs := ruleCIDRs(params.IpPermissions)
+	for _, cidr := range cidrs {
+		if f.rules[cidr] {
+			return nil, &smithy.GenericAPIError{Code: errCodeDuplicateRule, Message: "the specified rule already exists"}
+		}
+	}
+	if f.limit > 0 && len(f.rules)+len(cidrs) > f.limit {
+		return nil, &smithy.GenericAPIError{Code: "RulesPerSecurityGroupLimitExceeded", Message: "The maximum number of rules per security group has been reached."}
+	}
+	for _, cidr := range cidrs {
+		f.rules[cidr] = true
+	}
+	return &ec2.AuthorizeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (f *fakeSecurityGroupClient) RevokeSecurityGroupIngress(_ context.Context, params *ec2.RevokeSecurityGroupIngressInput, _ ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	if f.denied {
+		return nil, &smithy.GenericAPIError{Code: errCodeUnauthorized, Message: "You are not authorized to perform this operation."}
+	}
+	f.revoked = append(f.revoked, params)
+	cidrs := ruleCIDRs(params.IpPermissions)
+	for _, cidr := range cidrs {
+		if !f.rules[cidr] {
+			return nil, &smithy.GenericAPIError{Code: errCodeRuleNotFound, Message: "The specified rule does not exist in this security group."}
+		}
+	}
+	for _, cidr := range cidrs {
+		delete(f.rules, cidr)
+	}
+	return &ec2.RevokeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func newTestSecurityGroupSyncer(client securityGroupClient) *securityGroupSyncer {
+	return &securityGroupSyncer{client: client, groupID: "sg-1", clusterName: "test-cluster", logger: logrus.NewEntry(logrus.New())}
+}
+
+func TestSecurityGroupSyncer_Allow(t *testing.T) {
+	t.Run("IPv4 address", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{}
+		s := newTestSecurityGroupSyncer(client)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, client.authorized, 1)
+		assert.Equal(t, "sg-1", aws.ToString(client.authorized[0].GroupId))
+		permission := client.authorized[0].IpPermissions[0]
+		assert.Equal(t, allProtocols, aws.ToString(permission.IpProtocol))
+		require.Len(t, permission.IpRanges, 1)
+		assert.Equal(t, "1.1.1.1/32", aws.ToString(permission.IpRanges[0].CidrIp))
+		assert.Equal(t, "kubeip: test-cluster/node-1", aws.ToString(permission.IpRanges[0].Description))
+	})
+	t.Run("IPv6 address", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{}
+		s := newTestSecurityGroupSyncer(client)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "2600:1f18::1"))
+		permission := client.authorized[0].IpPermissions[0]
+		assert.Empty(t, permission.IpRanges)
+		require.Len(t, permission.Ipv6Ranges, 1)
+		assert.Equal(t, "2600:1f18::1/128", aws.ToString(permission.Ipv6Ranges[0].CidrIpv6))
+	})
+	t.Run("duplicate rule", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{rules: map[string]bool{"1.1.1.1/32": true}}
+		s := newTestSecurityGroupSyncer(client)
+		assert.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Len(t, client.rules, 1)
+	})
+	t.Run("rule limit exceeded", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{rules: map[string]bool{"2.2.2.2/32": true}, limit: 1}
+		s := newTestSecurityGroupSyncer(client)
+		err := s.Allow(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, isAPIError(err, "RulesPerSecurityGroupLimitExceeded"))
+		assert.NotContains(t, client.rules, "1.1.1.1/32")
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{denied: true}
+		s := newTestSecurityGroupSyncer(client)
+		err := s.Allow(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, isAPIError(err, errCodeUnauthorized))
+	})
+}
+
+func TestSecurityGroupSyncer_Revoke(t *testing.T) {
+	t.Run("revoke rule", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{rules: map[string]bool{"1.1.1.1/32": true, "2.2.2.2/32": true}}
+		s := newTestSecurityGroupSyncer(client)
+		require.NoError(t, s.Revoke(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, client.revoked, 1)
+		assert.Equal(t, map[string]bool{"2.2.2.2/32": true}, client.rules)
+		assert.Equal(t, "1.1.1.1/32", aws.ToString(client.revoked[0].IpPermissions[0].IpRanges[0].CidrIp))
+	})
+	t.Run("missing rule", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{}
+		s := newTestSecurityGroupSyncer(client)
+		assert.NoError(t, s.Revoke(context.Background(), "node-1", "1.1.1.1"))
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		client := &fakeSecurityGroupClient{denied: true}
+		s := newTestSecurityGroupSyncer(client)
+		assert.Error(t, s.Revoke(context.Background(), "node-1", "1.1.1.1"))
+	})
+}
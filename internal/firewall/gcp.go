@@ -0,0 +1,181 @@
+package firewall
+
+import (
+	"context"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	// maxUpdateAttempts bounds the read-modify-write retries on concurrent updates of the firewall rule
+	maxUpdateAttempts = 5
+	// operationDone is the status of a completed operation
+	operationDone = "DONE"
+)
+
+type gcpFirewalls interface {
+	Get(ctx context.Context, project, name string) (*compute.Firewall, error)
+	// SetSourceRanges patches the source ranges of the rule and returns the pending global operation
+	SetSourceRanges(ctx context.Context, project, name string, sourceRanges []string) (*compute.Operation, error)
+	// Wait waits for the global operation to complete, up to a server-side deadline (the operation may still be pending)
+	Wait(ctx context.Context, project, operation string) (*compute.Operation, error)
+}
+
+type gcpFirewallService struct {
+	service *compute.Service
+}
+
+func (s *gcpFirewallService) Get(ctx context.Context, project, name string) (*compute.Firewall, error) {
+	return s.service.Firewalls.Get(project, name).Context(ctx).Do() //nolint:wrapcheck
+}
+
+func (s *gcpFirewallService) SetSourceRanges(ctx context.Context, project, name string, sourceRanges []string) (*compute.Operation, error) {
+	// ForceSendFields allows removing the last source range
+	return s.service.Firewalls.Patch(project, name, &compute.Firewall{ //nolint:wrapcheck
+		SourceRanges:    sourceRanges,
+		ForceSendFields: []string{"SourceRanges"},
+	}).Context(ctx).Do()
+}
+
+func (s *gcpFirewallService) Wait(ctx context.Context, project, operation string) (*compute.Operation, error) {
+	return s.service.GlobalOperations.Wait(project, operation).Context(ctx).Do() //nolint:wrapcheck
+}
+
+type gcpSyncer struct {
+	firewalls gcpFirewalls
+	lock      lease.KubeLock
+	project   string
+	rule      string
+	logger    *logrus.Entry
+}
+
+// NewGCPSyncer returns a syncer maintaining the source ranges of a GCP VPC firewall rule; VPC firewall rules have no
+// fingerprint to make updates conditional, so the lock (if not nil) serializes the read-modify-write updates of the agents
+func NewGCPSyncer(ctx context.Context, logger *logrus.Entry, cfg *config.Config, lock lease.KubeLock) (Syncer, error) {
+	if cfg.FirewallName == "" {
+		return nil, errors.New("firewall rule name is required for GCP firewall sync")
+	}
+	opts, err := cloud.GCPClientOptions(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	service, err := compute.NewService(ctx, cloud.GCPComputeOptions(opts, cfg)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Google Cloud client")
+	}
+
+	// get project ID from metadata server
+	project := cfg.Project
+	if project == "" {
+		project, err = metadata.ProjectID()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get project ID from metadata server")
+		}
+	}
+	return &gcpSyncer{
+		firewalls: &gcpFirewallService{service: service},
+		lock:      lock,
+		project:   project,
+		rule:      cfg.FirewallName,
+		logger:    logger,
+	}, nil
+}
+
+// update applies the change to the source ranges and verifies it once the operation is done, retrying when the rule was
+// updated concurrently by another writer
+func (s *gcpSyncer) update(ctx context.Context, cidr string, allow bool) error {
+	if s.lock != nil {
+		if err := s.lock.Lock(ctx); err != nil {
+			return errors.Wrapf(err, "failed to acquire lock of firewall rule %s", s.rule)
+		}
+		defer s.lock.Unlock(ctx) //nolint:errcheck
+	}
+	for attempt := 1; attempt <= maxUpdateAttempts; attempt++ {
+		fw, err := s.firewalls.Get(ctx, s.project, s.rule)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get firewall rule %s", s.rule)
+		}
+		ranges, changed := updateRanges(fw.SourceRanges, cidr, allow)
+		if !changed {
+			return nil
+		}
+		op, err := s.firewalls.SetSourceRanges(ctx, s.project, s.rule, ranges)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update firewall rule %s", s.rule)
+		}
+		if err = s.wait(ctx, op); err != nil {
+			return errors.Wrapf(err, "failed to update firewall rule %s", s.rule)
+		}
+		s.logger.WithFields(logrus.Fields{
+			"firewall": s.rule,
+			"cidr":     cidr,
+			"allow":    allow,
+			"attempt":  attempt,
+		}).Debug("firewall rule source ranges updated")
+	}
+	return errors.Errorf("firewall rule %s still not updated after %d attempts", s.rule, maxUpdateAttempts)
+}
+
+// wait blocks until the global operation is done and returns its error, if any
+func (s *gcpSyncer) wait(ctx context.Context, op *compute.Operation) error {
+	var err error
+	name := op.Name
+	for op.Status != operationDone {
+		if op, err = s.firewalls.Wait(ctx, s.project, name); err != nil {
+			return errors.Wrapf(err, "failed to wait for operation %s", name)
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return errors.Errorf("operation %s failed: %s: %s", name, op.Error.Errors[0].Code, op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+// updateRanges adds (allow) or removes the CIDR and reports if the ranges changed
+func updateRanges(ranges []string, cidr string, allow bool) ([]string, bool) {
+	updated := make([]string, 0, len(ranges)+1)
+	found := false
+	for _, r := range ranges {
+		if r == cidr {
+			found = true
+			if !allow {
+				continue
+			}
+		}
+		updated = append(updated, r)
+	}
+	if allow && !found {
+		updated = append(updated, cidr)
+	}
+	return updated, found != allow
+}
+
+func (s *gcpSyncer) Allow(ctx context.Context, nodeName, address string) error {
+	if err := s.update(ctx, hostCIDR(address), true); err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"node":     nodeName,
+		"address":  address,
+		"firewall": s.rule,
+	}).Info("address allowed in firewall rule")
+	return nil
+}
+
+func (s *gcpSyncer) Revoke(ctx context.Context, nodeName, address string) error {
+	if err := s.update(ctx, hostCIDR(address), false); err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"node":     nodeName,
+		"address":  address,
+		"firewall": s.rule,
+	}).Info("address revoked from firewall rule")
+	return nil
+}
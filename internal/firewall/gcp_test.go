@@ -0,0 +1,142 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeFirewalls is a firewall rule patched by asynchronous global operations: a patch returns a pending operation applied
+// when it completes, after one wait
+type fakeFirewalls struct {
+	sourceRanges []string
+	updates      int
+	waits        int
+	// lost simulates concurrent updates overwriting the first n updates
+	lost int
+	// failed fails the operations with a quota error
+	failed  bool
+	pending map[string][]string
+}
+
+func (f *fakeFirewalls) Get(_ context.Context, _, name string) (*compute.Firewall, error) {
+	return &compute.Firewall{Name: name, SourceRanges: append([]string{}, f.sourceRanges...)}, nil
+}
+
+func (f *fakeFirewalls) SetSourceRanges(_ context.Context, _, _ string, sourceRanges []string) (*compute.Operation, error) {
+	f.updates++
+	if f.pending == nil {
+		f.pending = map[string][]string{}
+	}
+	name := fmt.Sprintf("operation-%d", f.updates)
+	f.pending[name] = sourceRanges
+	return &compute.Operation{Name: name, Status: "PENDING"}, nil
+}
+
+func (f *fakeFirewalls) Wait(_ context.Context, _, operation string) (*compute.Operation, error) {
+	f.waits++
+	sourceRanges, ok := f.pending[operation]
+	if !ok {
+		return nil, errors.Errorf("operation %s not found", operation)
+	}
+	delete(f.pending, operation)
+	if f.failed {
+		return &compute.Operation{Name: operation, Status: operationDone, Error: &compute.OperationError{
+			Errors: []*compute.OperationErrorErrors{{Code: "QUOTA_EXCEEDED", Message: "Quota 'FIREWALL_SOURCE_RANGES' exceeded"}},
+		}}, nil
+	}
+	if f.lost > 0 {
+		f.lost--
+	} else {
+		f.sourceRanges = sourceRanges
+	}
+	return &compute.Operation{Name: operation, Status: operationDone}, nil
+}
+
+func newTestGCPSyncer(firewalls gcpFirewalls) *gcpSyncer {
+	return &gcpSyncer{firewalls: firewalls, project: "test-project", rule: "allow-egress", logger: logrus.NewEntry(logrus.New())}
+}
+
+func Test_updateRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		ranges      []string
+		cidr        string
+		allow       bool
+		want        []string
+		wantChanged bool
+	}{
+		{"allow new", []string{"10.0.0.0/8"}, "1.1.1.1/32", true, []string{"10.0.0.0/8", "1.1.1.1/32"}, true},
+		{"allow existing", []string{"1.1.1.1/32"}, "1.1.1.1/32", true, []string{"1.1.1.1/32"}, false},
+		{"revoke existing", []string{"10.0.0.0/8", "1.1.1.1/32"}, "1.1.1.1/32", false, []string{"10.0.0.0/8"}, true},
+		{"revoke last", []string{"1.1.1.1/32"}, "1.1.1.1/32", false, []string{}, true},
+		{"revoke missing", []string{"10.0.0.0/8"}, "1.1.1.1/32", false, []string{"10.0.0.0/8"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := updateRanges(tt.ranges, tt.cidr, tt.allow)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantChanged, changed)
+		})
+	}
+}
+
+func TestGCPSyncer(t *testing.T) {
+	t.Run("allow and revoke", func(t *testing.T) {
+		firewalls := &fakeFirewalls{sourceRanges: []string{"10.0.0.0/8"}}
+		s := newTestGCPSyncer(firewalls)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, []string{"10.0.0.0/8", "1.1.1.1/32"}, firewalls.sourceRanges)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 1, firewalls.updates)
+		require.NoError(t, s.Revoke(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, []string{"10.0.0.0/8"}, firewalls.sourceRanges)
+		assert.Equal(t, 2, firewalls.waits)
+	})
+	t.Run("operation failed", func(t *testing.T) {
+		firewalls := &fakeFirewalls{failed: true}
+		s := newTestGCPSyncer(firewalls)
+		assert.ErrorContains(t, s.Allow(context.Background(), "node-1", "1.1.1.1"), "QUOTA_EXCEEDED")
+		assert.Equal(t, 1, firewalls.updates)
+		assert.Empty(t, firewalls.sourceRanges)
+	})
+	t.Run("serialized by the lease", func(t *testing.T) {
+		client := fake.NewSimpleClientset()
+		firewalls := &fakeFirewalls{}
+		s := newTestGCPSyncer(firewalls)
+		s.lock = lease.NewKubeLeaseLock(client, "kubeip-firewall-lock", "default", "node-1", 5)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, []string{"1.1.1.1/32"}, firewalls.sourceRanges)
+		// released after the update
+		_, err := client.CoordinationV1().Leases("default").Get(context.Background(), "kubeip-firewall-lock", metav1.GetOptions{})
+		assert.Error(t, err)
+	})
+	t.Run("IPv6 address", func(t *testing.T) {
+		firewalls := &fakeFirewalls{}
+		s := newTestGCPSyncer(firewalls)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "2600:1900::1"))
+		assert.Equal(t, []string{"2600:1900::1/128"}, firewalls.sourceRanges)
+	})
+	t.Run("retry on concurrent update", func(t *testing.T) {
+		firewalls := &fakeFirewalls{lost: 2}
+		s := newTestGCPSyncer(firewalls)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, []string{"1.1.1.1/32"}, firewalls.sourceRanges)
+		assert.Equal(t, 3, firewalls.updates)
+	})
+	t.Run("give up after max attempts", func(t *testing.T) {
+		firewalls := &fakeFirewalls{lost: maxUpdateAttempts}
+		s := newTestGCPSyncer(firewalls)
+		assert.Error(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, maxUpdateAttempts, firewalls.updates)
+	})
+}
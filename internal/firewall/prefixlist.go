@@ -0,0 +1,185 @@
+package firewall
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// errCodeIncorrectState is returned while another modification of the prefix list is in progress
+	errCodeIncorrectState = "IncorrectState"
+	// errCodeVersionMismatch is returned when the prefix list was modified since its version was read
+	errCodeVersionMismatch = "PrefixListVersionMismatch"
+)
+
+var (
+	// prefixListRetryDelay is the delay before retrying a prefix list modification conflicting with another agent
+	prefixListRetryDelay = 2 * time.Second
+	// prefixListPollInterval is the delay between polls of a prefix list modification in progress
+	prefixListPollInterval = time.Second
+)
+
+type prefixListClient interface {
+	DescribeManagedPrefixLists(ctx context.Context, params *ec2.DescribeManagedPrefixListsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeManagedPrefixListsOutput, error)
+	GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput, optFns ...func(*ec2.Options)) (*ec2.GetManagedPrefixListEntriesOutput, error)
+	ModifyManagedPrefixList(ctx context.Context, params *ec2.ModifyManagedPrefixListInput, optFns ...func(*ec2.Options)) (*ec2.ModifyManagedPrefixListOutput, error)
+}
+
+type prefixListSyncer struct {
+	client       prefixListClient
+	prefixListID string
+	clusterName  string
+	logger       *logrus.Entry
+}
+
+// NewPrefixListSyncer returns a syncer maintaining an entry per assigned address in an AWS managed prefix list
+func NewPrefixListSyncer(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Syncer, error) {
+	if cfg.FirewallName == "" {
+		return nil, errors.New("prefix list ID is required for AWS managed prefix list sync")
+	}
+	awsCfg, err := cloud.LoadAWSConfig(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return &prefixListSyncer{
+		client:       cloud.NewEC2Client(awsCfg, cfg),
+		prefixListID: cfg.FirewallName,
+		clusterName:  cfg.ClusterName,
+		logger:       logger,
+	}, nil
+}
+
+func (s *prefixListSyncer) describe(ctx context.Context) (*types.ManagedPrefixList, error) {
+	lists, err := s.client.DescribeManagedPrefixLists(ctx, &ec2.DescribeManagedPrefixListsInput{
+		PrefixListIds: []string{s.prefixListID},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe prefix list %s", s.prefixListID)
+	}
+	if len(lists.PrefixLists) == 0 {
+		return nil, errors.Errorf("prefix list %s not found", s.prefixListID)
+	}
+	return &lists.PrefixLists[0], nil
+}
+
+// entries returns the current version and CIDR entries of the prefix list
+func (s *prefixListSyncer) entries(ctx context.Context) (int64, map[string]bool, error) {
+	list, err := s.describe(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	version := aws.ToInt64(list.Version)
+
+	cidrs := make(map[string]bool)
+	input := &ec2.GetManagedPrefixListEntriesInput{PrefixListId: aws.String(s.prefixListID), TargetVersion: aws.Int64(version)}
+	for {
+		out, err := s.client.GetManagedPrefixListEntries(ctx, input)
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "failed to get entries of prefix list %s", s.prefixListID)
+		}
+		for _, entry := range out.Entries {
+			cidrs[aws.ToString(entry.Cidr)] = true
+		}
+		if aws.ToString(out.NextToken) == "" {
+			return version, cidrs, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
+
+// update adds (allow) or removes the entry of the address; concurrent modifications by other agents are retried
+func (s *prefixListSyncer) update(ctx context.Context, nodeName, address string, allow bool) error {
+	cidr := hostCIDR(address)
+	for attempt := 1; attempt <= maxUpdateAttempts; attempt++ {
+		version, cidrs, err := s.entries(ctx)
+		if err != nil {
+			return err
+		}
+		if cidrs[cidr] == allow {
+			return nil
+		}
+		input := &ec2.ModifyManagedPrefixListInput{
+			PrefixListId:   aws.String(s.prefixListID),
+			CurrentVersion: aws.Int64(version),
+		}
+		if allow {
+			input.AddEntries = []types.AddPrefixListEntry{{Cidr: aws.String(cidr), Description: aws.String(description(s.clusterName, nodeName))}}
+		} else {
+			input.RemoveEntries = []types.RemovePrefixListEntry{{Cidr: aws.String(cidr)}}
+		}
+		out, err := s.client.ModifyManagedPrefixList(ctx, input)
+		if err == nil && out.PrefixList != nil {
+			return s.wait(ctx, aws.ToInt64(out.PrefixList.Version))
+		}
+		if err == nil {
+			return nil
+		}
+		if !isAPIError(err, errCodeIncorrectState, errCodeVersionMismatch) {
+			return errors.Wrapf(err, "failed to modify prefix list %s", s.prefixListID)
+		}
+		s.logger.WithError(err).WithField("attempt", attempt).Debug("prefix list modified concurrently, retrying")
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		case <-time.After(prefixListRetryDelay):
+		}
+	}
+	return errors.Errorf("prefix list %s still not modified after %d attempts", s.prefixListID, maxUpdateAttempts)
+}
+
+// wait polls the prefix list until the modification creating the version is complete; a later version means the
+// modification completed and another one followed
+func (s *prefixListSyncer) wait(ctx context.Context, version int64) error {
+	for {
+		list, err := s.describe(ctx)
+		if err != nil {
+			return err
+		}
+		current := aws.ToInt64(list.Version)
+		switch {
+		case current > version:
+			return nil
+		case current == version && list.State == types.PrefixListStateModifyComplete:
+			return nil
+		case current == version && list.State == types.PrefixListStateModifyFailed:
+			return errors.Errorf("modification of prefix list %s failed: %s", s.prefixListID, aws.ToString(list.StateMessage))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck
+		case <-time.After(prefixListPollInterval):
+		}
+	}
+}
+
+func (s *prefixListSyncer) Allow(ctx context.Context, nodeName, address string) error {
+	if err := s.update(ctx, nodeName, address, true); err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"node":        nodeName,
+		"address":     address,
+		"prefix-list": s.prefixListID,
+	}).Info("address added to prefix list")
+	return nil
+}
+
+func (s *prefixListSyncer) Revoke(ctx context.Context, nodeName, address string) error {
+	if err := s.update(ctx, nodeName, address, false); err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"node":        nodeName,
+		"address":     address,
+		"prefix-list": s.prefixListID,
+	}).Info("address removed from prefix list")
+	return nil
+}
@@ -0,0 +1,160 @@
+package firewall
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const errCodeUnauthorized = "UnauthorizedOperation"
+
+// fakePrefixListClient models the EC2 managed prefix list API: a modification is conditional on the current version,
+// stays in progress (rejecting other modifications) for a number of describes, then completes or fails
+type fakePrefixListClient struct {
+	version int64
+	entries map[string]string
+	// conflicts is the number of modifications failing with a version mismatch
+	conflicts int
+	modified  int
+	// pendingDescribes is the number of describes a modification stays in progress
+	pendingDescribes int
+	inProgress       int
+	pending          *ec2.ModifyManagedPrefixListInput
+	state            types.PrefixListState
+	// failure, if set, is the state message of failing modifications
+	failure string
+	denied  bool
+}
+
+func (f *fakePrefixListClient) complete() {
+	if f.failure != "" {
+		f.state = types.PrefixListStateModifyFailed
+		return
+	}
+	if f.pending != nil {
+		for _, entry := range f.pending.AddEntries {
+			f.entries[aws.ToString(entry.Cidr)] = aws.ToString(entry.Description)
+		}
+		for _, entry := range f.pending.RemoveEntries {
+			delete(f.entries, aws.ToString(entry.Cidr))
+		}
+		f.modified++
+	}
+	f.pending = nil
+	f.state = types.PrefixListStateModifyComplete
+}
+
+func (f *fakePrefixListClient) DescribeManagedPrefixLists(_ context.Context, params *ec2.DescribeManagedPrefixListsInput, _ ...func(*ec2.Options)) (*ec2.DescribeManagedPrefixListsOutput, error) {
+	if f.denied {
+		return nil, &smithy.GenericAPIError{Code: errCodeUnauthorized, Message: "You are not authorized to perform this operation."}
+	}
+	if f.state == types.PrefixListStateModifyInProgress {
+		if f.inProgress == 0 {
+			f.complete()
+		} else {
+			f.inProgress--
+		}
+	}
+	return &ec2.DescribeManagedPrefixListsOutput{PrefixLists: []types.ManagedPrefixList{
+		{PrefixListId: aws.String(params.PrefixListIds[0]), Version: aws.Int64(f.version), State: f.state, StateMessage: aws.String(f.failure)},
+	}}, nil
+}
+
+func (f *fakePrefixListClient) GetManagedPrefixListEntries(_ context.Context, _ *ec2.GetManagedPrefixListEntriesInput, _ ...func(*ec2.Options)) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	out := &ec2.GetManagedPrefixListEntriesOutput{}
+	for cidr, desc := range f.entries {
+		out.Entries = append(out.Entries, types.PrefixListEntry{Cidr: aws.String(cidr), Description: aws.String(desc)})
+	}
+	return out, nil
+}
+
+func (f *fakePrefixListClient) ModifyManagedPrefixList(_ context.Context, params *ec2.ModifyManagedPrefixListInput, _ ...func(*ec2.Options)) (*ec2.ModifyManagedPrefixListOutput, error) {
+	if f.denied {
+		return nil, &smithy.GenericAPIError{Code: errCodeUnauthorized, Message: "You are not authorized to perform this operation."}
+	}
+	if f.state == types.PrefixListStateModifyInProgress {
+		return nil, &smithy.GenericAPIError{Code: errCodeIncorrectState}
+	}
+	if f.conflicts > 0 {
+		f.conflicts--
+		f.version++
+		return nil, &smithy.GenericAPIError{Code: errCodeVersionMismatch}
+	}
+	if aws.ToInt64(params.CurrentVersion) != f.version {
+		return nil, &smithy.GenericAPIError{Code: errCodeVersionMismatch}
+	}
+	f.version++
+	f.pending, f.state, f.inProgress = params, types.PrefixListStateModifyInProgress, f.pendingDescribes
+	if f.inProgress == 0 {
+		f.complete()
+	}
+	return &ec2.ModifyManagedPrefixListOutput{PrefixList: &types.ManagedPrefixList{
+		PrefixListId: params.PrefixListId, Version: aws.Int64(f.version), State: f.state,
+	}}, nil
+}
+
+func newTestPrefixListSyncer(client prefixListClient) *prefixListSyncer {
+	prefixListRetryDelay, prefixListPollInterval = 0, 0
+	return &prefixListSyncer{client: client, prefixListID: "pl-1", clusterName: "test-cluster", logger: logrus.NewEntry(logrus.New())}
+}
+
+func TestPrefixListSyncer(t *testing.T) {
+	t.Run("allow and revoke", func(t *testing.T) {
+		client := &fakePrefixListClient{version: 1, entries: map[string]string{"2.2.2.2/32": "kubeip: test-cluster/node-2"}}
+		s := newTestPrefixListSyncer(client)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, "kubeip: test-cluster/node-1", client.entries["1.1.1.1/32"])
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 1, client.modified)
+		require.NoError(t, s.Revoke(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, map[string]string{"2.2.2.2/32": "kubeip: test-cluster/node-2"}, client.entries)
+		require.NoError(t, s.Revoke(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 2, client.modified)
+	})
+	t.Run("retry on version mismatch", func(t *testing.T) {
+		client := &fakePrefixListClient{version: 1, entries: map[string]string{}, conflicts: 2}
+		s := newTestPrefixListSyncer(client)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Contains(t, client.entries, "1.1.1.1/32")
+	})
+	t.Run("give up after max attempts", func(t *testing.T) {
+		client := &fakePrefixListClient{version: 1, entries: map[string]string{}, conflicts: maxUpdateAttempts}
+		s := newTestPrefixListSyncer(client)
+		assert.Error(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, client.entries)
+	})
+	t.Run("wait for modification in progress", func(t *testing.T) {
+		client := &fakePrefixListClient{version: 1, entries: map[string]string{}, pendingDescribes: 2}
+		s := newTestPrefixListSyncer(client)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, types.PrefixListStateModifyComplete, client.state)
+		assert.Contains(t, client.entries, "1.1.1.1/32")
+	})
+	t.Run("retry while another modification is in progress", func(t *testing.T) {
+		client := &fakePrefixListClient{version: 1, entries: map[string]string{}, state: types.PrefixListStateModifyInProgress, inProgress: 1}
+		s := newTestPrefixListSyncer(client)
+		require.NoError(t, s.Allow(context.Background(), "node-1", "1.1.1.1"))
+		assert.Equal(t, 1, client.modified)
+		assert.Contains(t, client.entries, "1.1.1.1/32")
+	})
+	t.Run("modification failed", func(t *testing.T) {
+		client := &fakePrefixListClient{version: 1, entries: map[string]string{}, pendingDescribes: 1, failure: "The prefix list has reached its maximum number of entries."}
+		s := newTestPrefixListSyncer(client)
+		err := s.Allow(context.Background(), "node-1", "1.1.1.1")
+		assert.ErrorContains(t, err, "maximum number of entries")
+		assert.Empty(t, client.entries)
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		client := &fakePrefixListClient{version: 1, entries: map[string]string{}, denied: true}
+		s := newTestPrefixListSyncer(client)
+		err := s.Allow(context.Background(), "node-1", "1.1.1.1")
+		assert.True(t, isAPIError(err, errCodeUnauthorized))
+	})
+}
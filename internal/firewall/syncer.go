@@ -0,0 +1,60 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/doitintl/kubeip/internal/lease"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProviderGCP              = "gcp-firewall"
+	ProviderAWSSecurityGroup = "aws-security-group"
+	ProviderAWSPrefixList    = "aws-prefix-list"
+)
+
+var ErrUnknownProvider = errors.New("unknown firewall provider")
+
+// Syncer keeps a cloud firewall resource trusting the static public IP addresses assigned to nodes
+type Syncer interface {
+	// Allow adds the address of the node to the firewall resource
+	Allow(ctx context.Context, nodeName, address string) error
+	// Revoke removes the address of the node from the firewall resource
+	Revoke(ctx context.Context, nodeName, address string) error
+}
+
+// NewSyncer returns the syncer of the configured firewall provider or nil if firewall sync is disabled; the lock serializes
+// the updates of firewall resources without conditional updates (GCP firewall rules)
+func NewSyncer(ctx context.Context, logger *logrus.Entry, cfg *config.Config, lock lease.KubeLock) (Syncer, error) {
+	switch cfg.FirewallProvider {
+	case "":
+		return nil, nil //nolint:nilnil
+	case ProviderGCP:
+		return NewGCPSyncer(ctx, logger, cfg, lock)
+	case ProviderAWSSecurityGroup:
+		return NewSecurityGroupSyncer(ctx, logger, cfg)
+	case ProviderAWSPrefixList:
+		return NewPrefixListSyncer(ctx, logger, cfg)
+	}
+	return nil, errors.Wrapf(ErrUnknownProvider, "%s, supported providers: %s, %s, %s", cfg.FirewallProvider, ProviderGCP, ProviderAWSSecurityGroup, ProviderAWSPrefixList)
+}
+
+// hostCIDR returns the address as single host CIDR: /32 for IPv4, /128 for IPv6
+func hostCIDR(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return address + "/128"
+	}
+	return address + "/32"
+}
+
+// description returns the description of a firewall entry for the node address
+func description(clusterName, nodeName string) string {
+	if clusterName == "" {
+		return fmt.Sprintf("kubeip: %s", nodeName)
+	}
+	return fmt.Sprintf("kubeip: %s/%s", clusterName, nodeName)
+}
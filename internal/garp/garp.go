@@ -0,0 +1,85 @@
+package garp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultCount is the default number of announcements: switches may miss the first one while the address comes up
+const defaultCount = 3
+
+// Sender announces the address bound to the node to its L2 neighbors, so switches and neighbors update their ARP and
+// neighbor caches immediately instead of waiting for the entries to expire
+type Sender interface {
+	// Announce sends gratuitous ARP replies for an IPv4 address or unsolicited neighbor advertisements for an IPv6 address
+	Announce(ctx context.Context, address string) error
+}
+
+// runner runs the command and returns its combined output
+type runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.Bytes(), err //nolint:wrapcheck
+}
+
+type sender struct {
+	logger *logrus.Entry
+	run    runner
+	iface  string
+	count  int
+}
+
+// NewSender returns a sender announcing from the configured interface with arping (iputils) and ndsend (ndisc6), or nil
+// if the announcements are disabled
+func NewSender(logger *logrus.Entry, cfg *config.Config) (Sender, error) {
+	if cfg.GARPInterface == "" {
+		return nil, nil //nolint:nilnil
+	}
+	count := cfg.GARPCount
+	if count == 0 {
+		count = defaultCount
+	}
+	if count < 0 {
+		return nil, errors.Errorf("invalid gratuitous announcement count %d", count)
+	}
+	return &sender{logger: logger, run: run, iface: cfg.GARPInterface, count: count}, nil
+}
+
+func (s *sender) Announce(ctx context.Context, address string) error {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return errors.Errorf("invalid address %q", address)
+	}
+	for i := 0; i < s.count; i++ {
+		var name string
+		var args []string
+		if ip.To4() != nil {
+			// -U: unsolicited ARP, updating the neighbor caches; -c 1: one per round
+			name, args = "arping", []string{"-U", "-c", "1", "-I", s.iface, ip.String()}
+		} else {
+			name, args = "ndsend", []string{ip.String(), s.iface}
+		}
+		if out, err := s.run(ctx, name, args...); err != nil {
+			return errors.Wrapf(err, "%s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(string(out)))
+		}
+	}
+	s.logger.WithFields(logrus.Fields{
+		"address":   ip.String(),
+		"interface": s.iface,
+		"count":     strconv.Itoa(s.count),
+	}).Debug("gratuitous announcements sent")
+	return nil
+}
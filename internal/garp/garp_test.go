@@ -0,0 +1,48 @@
+package garp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSender(t *testing.T) {
+	log := logrus.NewEntry(logrus.New())
+	s, err := NewSender(log, &config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, s, "disabled without interface")
+
+	_, err = NewSender(log, &config.Config{GARPInterface: "eth0", GARPCount: -1})
+	assert.Error(t, err)
+}
+
+func TestSender_Announce(t *testing.T) {
+	var commands []string
+	s, err := NewSender(logrus.NewEntry(logrus.New()), &config.Config{GARPInterface: "eth0", GARPCount: 2})
+	require.NoError(t, err)
+	s.(*sender).run = func(_ context.Context, name string, args ...string) ([]byte, error) { //nolint:forcetypeassert
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		if args[0] == "2001:db8::99" {
+			return []byte("ndsend: cannot send"), errors.New("exit status 1")
+		}
+		return nil, nil
+	}
+
+	require.NoError(t, s.Announce(context.Background(), "192.0.2.10"))
+	require.NoError(t, s.Announce(context.Background(), "2001:db8::10"))
+	assert.Equal(t, []string{
+		"arping -U -c 1 -I eth0 192.0.2.10",
+		"arping -U -c 1 -I eth0 192.0.2.10",
+		"ndsend 2001:db8::10 eth0",
+		"ndsend 2001:db8::10 eth0",
+	}, commands)
+
+	assert.Error(t, s.Announce(context.Background(), "2001:db8::99"))
+	assert.Error(t, s.Announce(context.Background(), "not-an-address"))
+}
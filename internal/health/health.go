@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/httpserver"
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ReasonProviderUnavailable is the reason of the readiness failing while the cloud provider is unavailable
+	ReasonProviderUnavailable = "provider-unavailable"
+	// ReasonBlocked is the reason of the readiness failing once a permanent error blocked the assignment
+	ReasonBlocked = "blocked"
+)
+
+// Status is the health of the agent: alive as long as it serves, ready unless the cloud provider is unavailable or the
+// assignment is blocked; an agent backing off through a cloud outage is healthy, restarting it would only reset its
+// backoff
+type Status struct {
+	mutex    sync.Mutex
+	provider string
+	// since is the time of the first request of the outage the cloud provider failed, zero when available
+	since time.Time
+	// blocked is the permanent error blocking the assignment, since the time, empty if not blocked
+	blocked      string
+	blockedSince time.Time
+}
+
+// Default is the health of the agent
+var Default = &Status{}
+
+// ProviderUnavailable records a request the cloud provider failed at the time: the outage starts with the first failed
+// request and its duration is updated with every failed retry
+func (s *Status) ProviderUnavailable(provider string, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.since.IsZero() {
+		s.since = now
+	}
+	s.provider = provider
+	metrics.ObserveProviderOutage(provider, now.Sub(s.since))
+}
+
+// ProviderAvailable records a request the cloud provider served, ending the outage
+func (s *Status) ProviderAvailable(provider string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.since = time.Time{}
+	s.provider = provider
+	metrics.ObserveProviderOutage(provider, 0)
+}
+
+// Outage returns the provider unavailable since the time, zero if available
+func (s *Status) Outage() (string, time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.provider, s.since
+}
+
+// Blocked records the permanent error blocking the assignment at the time, until the agent restarts
+func (s *Status) Blocked(err error, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.blocked, s.blockedSince = err.Error(), now
+}
+
+// Block returns the permanent error blocking the assignment since the time, empty if not blocked
+func (s *Status) Block() (string, time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.blocked, s.blockedSince
+}
+
+// readiness is the body of the readiness endpoint
+type readiness struct {
+	Ready    bool       `json:"ready"`
+	Reason   string     `json:"reason,omitempty"`
+	Provider string     `json:"provider,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// Handler serves the liveness (/healthz, always ok) and the readiness (/readyz, 503 Service Unavailable with the blocked
+// reason and the error once the assignment is blocked, with the provider-unavailable reason during a cloud outage) of
+// the status
+func Handler(s *Status) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n")) //nolint:errcheck
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		body, code := readiness{Ready: true}, http.StatusOK
+		if blocked, since := s.Block(); blocked != "" {
+			body, code = readiness{Reason: ReasonBlocked, Error: blocked, Since: &since}, http.StatusServiceUnavailable
+		} else if provider, since := s.Outage(); !since.IsZero() {
+			body, code = readiness{Reason: ReasonProviderUnavailable, Provider: provider, Since: &since}, http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body) //nolint:errcheck,errchkjson
+	})
+	return mux
+}
+
+// Serve serves the liveness and the readiness of the status at /healthz and /readyz of the address until the context is
+// done, over TLS if configured
+func Serve(ctx context.Context, log *logrus.Entry, address string, s *Status, t httpserver.TLS) error {
+	return httpserver.Serve(ctx, log, "health probes", address, Handler(s), t) //nolint:wrapcheck
+}
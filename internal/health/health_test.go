@@ -0,0 +1,62 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	s := &Status{}
+	handler := Handler(s)
+	probe := func(path string) (int, readiness) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body readiness
+		if path == "/readyz" {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		}
+		return rec.Code, body
+	}
+
+	code, body := probe("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, body.Ready)
+
+	// the outage starts with the first failed request and lasts until a request is served
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.ProviderUnavailable("gcp", start)
+	s.ProviderUnavailable("gcp", start.Add(90*time.Second))
+	assert.Equal(t, 90.0, metrics.ProviderOutage.Value("gcp"))
+	code, _ = probe("/healthz")
+	assert.Equal(t, http.StatusOK, code, "liveness passes during the outage")
+	code, body = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, ReasonProviderUnavailable, body.Reason)
+	assert.Equal(t, "gcp", body.Provider)
+	require.NotNil(t, body.Since)
+	assert.True(t, start.Equal(*body.Since))
+
+	s.ProviderAvailable("gcp")
+	assert.Equal(t, 0.0, metrics.ProviderOutage.Value("gcp"))
+	code, _ = probe("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// a blocked assignment fails the readiness until the agent restarts
+	s.Blocked(errors.New("invalid filter"), start)
+	code, _ = probe("/healthz")
+	assert.Equal(t, http.StatusOK, code, "liveness passes while blocked")
+	code, body = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, ReasonBlocked, body.Reason)
+	assert.Equal(t, "invalid filter", body.Error)
+	require.NotNil(t, body.Since)
+	assert.True(t, start.Equal(*body.Since))
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// TLS is the TLS configuration of a listener of the agent: served over TLS if a certificate is configured, and only to the
+// clients presenting a certificate signed by the client CA if one is configured (mutual TLS)
+type TLS struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Enabled reports whether the listener is served over TLS
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Config returns the server TLS configuration requiring and verifying the client certificates against the client CA if
+// configured; the certificate and key are loaded by ListenAndServeTLS
+func (t TLS) Config() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.ClientCAFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(t.ClientCAFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read client CA file %s", t.ClientCAFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no certificates found in client CA file %s", t.ClientCAFile)
+	}
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	cfg.ClientCAs = pool
+	return cfg, nil
+}
+
+// Serve serves the handler on the address until the context is done, over TLS if configured; name names the endpoint in
+// the logs and the errors
+func Serve(ctx context.Context, log *logrus.Entry, name, address string, handler http.Handler, t TLS) error {
+	server := &http.Server{Addr: address, Handler: handler, ReadHeaderTimeout: readHeaderTimeout}
+	if t.Enabled() {
+		cfg, err := t.Config()
+		if err != nil {
+			return errors.Wrapf(err, "failed to configure TLS of %s", name)
+		}
+		server.TLSConfig = cfg
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx) //nolint:errcheck
+	}()
+	log.WithFields(logrus.Fields{"address": address, "tls": t.Enabled(), "client-ca": t.ClientCAFile != ""}).Info("serving " + name)
+	var err error
+	if t.Enabled() {
+		err = server.ListenAndServeTLS(t.CertFile, t.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrapf(err, "failed to serve %s", name)
+	}
+	return nil
+}
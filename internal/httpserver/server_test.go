@@ -0,0 +1,180 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issuer signs the test certificates
+type issuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newIssuer(t *testing.T) *issuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubeip CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	ca := &issuer{cert: cert, key: key, dir: t.TempDir()}
+	ca.write(t, "ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+func (ca *issuer) write(t *testing.T, name, kind string, der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600))
+	return path
+}
+
+// issue returns the certificate and key files of a certificate signed by the CA for the usage
+func (ca *issuer) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return ca.write(t, name+".pem", "CERTIFICATE", der), ca.write(t, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestTLS_Config(t *testing.T) {
+	ca := newIssuer(t)
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o600))
+	tests := []struct {
+		name           string
+		tls            TLS
+		wantClientAuth tls.ClientAuthType
+		wantErr        bool
+	}{
+		{
+			name: "server TLS only",
+			tls:  TLS{CertFile: "tls.crt", KeyFile: "tls.key"},
+		},
+		{
+			name:           "mutual TLS",
+			tls:            TLS{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: filepath.Join(ca.dir, "ca.pem")},
+			wantClientAuth: tls.RequireAndVerifyClientCert,
+		},
+		{
+			name:    "missing client CA file",
+			tls:     TLS{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: filepath.Join(ca.dir, "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "no certificate in client CA file",
+			tls:     TLS{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: empty},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.tls.Config()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantClientAuth, got.ClientAuth)
+			assert.Equal(t, tt.wantClientAuth == tls.RequireAndVerifyClientCert, got.ClientCAs != nil)
+		})
+	}
+}
+
+func TestServe_MutualTLS(t *testing.T) {
+	ca := newIssuer(t)
+	serverCert, serverKey := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+	address := freeAddress(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, logrus.NewEntry(logrus.New()), "test", address, handler,
+			TLS{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: filepath.Join(ca.dir, "ca.pem")})
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certificates ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Timeout: time.Second, Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots, Certificates: certificates},
+		}}
+		return client.Get("https://" + address + "/") //nolint:noctx
+	}
+	keyPair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		resp, err := get(keyPair)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusNoContent
+	}, 5*time.Second, 50*time.Millisecond, "the client presenting a certificate signed by the client CA is served")
+
+	_, err = get()
+	assert.Error(t, err, "the client presenting no certificate is refused")
+
+	other := newIssuer(t)
+	otherCert, otherKey := other.issue(t, "client", x509.ExtKeyUsageClientAuth)
+	otherPair, err := tls.LoadX509KeyPair(otherCert, otherKey)
+	require.NoError(t, err)
+	_, err = get(otherPair)
+	assert.Error(t, err, "the client presenting a certificate signed by another CA is refused")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestServe_InvalidClientCA(t *testing.T) {
+	err := Serve(context.Background(), logrus.NewEntry(logrus.New()), "test", freeAddress(t), http.NotFoundHandler(),
+		TLS{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to configure TLS of test")
+}
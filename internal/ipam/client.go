@@ -0,0 +1,120 @@
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	maxErrorBody   = 512
+)
+
+var errNotFound = errors.New("not found")
+
+// httpError is an unsuccessful response of the IPAM API
+type httpError struct {
+	method string
+	path   string
+	status int
+	body   []byte
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("%s %s failed with status %d: %s", e.method, e.path, e.status, e.body)
+}
+
+// isHTTPError reports if the error is an unsuccessful response with the status and a body containing the text
+func isHTTPError(err error, status int, text string) bool {
+	var httpErr *httpError
+	return errors.As(err, &httpErr) && httpErr.status == status && bytes.Contains(httpErr.body, []byte(text))
+}
+
+// retryConcurrent runs register again if it failed because another agent created (conflict) or deleted the object
+// concurrently; the second run starts from the current object
+func retryConcurrent(register func() error, conflict func(error) bool) error {
+	err := register()
+	if err != nil && (conflict(err) || errors.Is(err, errNotFound)) {
+		return register()
+	}
+	return err
+}
+
+// newHTTPClient returns the HTTP client of the IPAM API with the configured TLS options
+func newHTTPClient(cfg *config.Config) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.IPAMInsecureSkipVerify, //nolint:gosec
+	}
+	if cfg.IPAMCAFile != "" {
+		pem, err := os.ReadFile(cfg.IPAMCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read IPAM CA file %s", cfg.IPAMCAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in IPAM CA file %s", cfg.IPAMCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: defaultTimeout, Transport: transport}, nil
+}
+
+// jsonClient calls a JSON REST API with fixed headers (authentication)
+type jsonClient struct {
+	client  *http.Client
+	baseURL string
+	headers map[string]string
+}
+
+// do sends the request with the JSON encoded body and decodes the JSON response into result (if not nil)
+func (c *jsonClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal request")
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s %s failed", method, path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.Wrapf(errNotFound, "%s %s", method, path)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody)) //nolint:errcheck
+		return &httpError{method: method, path: path, status: resp.StatusCode, body: bytes.TrimSpace(data)}
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(result), "failed to decode %s %s response", method, path)
+}
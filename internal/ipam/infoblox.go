@@ -0,0 +1,226 @@
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	InfobloxFixedAddress = "fixedaddress"
+	InfobloxHost         = "host"
+
+	infobloxHostObject = "record:host"
+	// reserved fixed addresses do not need a MAC address
+	infobloxMatchReserved = "RESERVED"
+	// infobloxConflict is the error code of the creation of an object holding an address already held
+	infobloxConflict = "Client.Ibap.Data.Conflict"
+)
+
+// ExtAttrData are the fields available in the extensible attribute templates
+type ExtAttrData struct {
+	Node    string
+	Cluster string
+	Address string
+}
+
+type infobloxObject struct {
+	Ref     string `json:"_ref"`
+	Comment string `json:"comment"`
+}
+
+type infobloxRegistrar struct {
+	client      *jsonClient
+	record      string
+	domain      string
+	clusterName string
+	extattrs    map[string]*template.Template
+	logger      *logrus.Entry
+}
+
+// NewInfobloxRegistrar returns a registrar recording assignments in Infoblox WAPI as reserved fixed addresses (IPv4)
+// or host records (IPv4 and IPv6); the objects registered for the node are deleted on release, objects not registered by
+// kubeip are never changed
+func NewInfobloxRegistrar(logger *logrus.Entry, cfg *config.Config) (Registrar, error) {
+	if cfg.IPAMURL == "" || cfg.IPAMUsername == "" {
+		return nil, errors.New("IPAM URL and username are required for Infoblox")
+	}
+	record := cfg.IPAMInfobloxRecord
+	switch record {
+	case "":
+		record = InfobloxFixedAddress
+	case InfobloxFixedAddress:
+	case InfobloxHost:
+		if cfg.IPAMDomain == "" {
+			return nil, errors.New("IPAM domain is required for Infoblox host records")
+		}
+	default:
+		return nil, errors.Errorf("unsupported Infoblox record type %s, supported types: %s, %s", record, InfobloxFixedAddress, InfobloxHost)
+	}
+	extattrs, err := parseExtAttrs(cfg.IPAMExtAttrs)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte(cfg.IPAMUsername + ":" + cfg.IPAMPassword))
+	return &infobloxRegistrar{
+		client: &jsonClient{
+			client:  client,
+			baseURL: strings.TrimSuffix(cfg.IPAMURL, "/"),
+			headers: map[string]string{"Authorization": "Basic " + credentials},
+		},
+		record:      record,
+		domain:      strings.Trim(cfg.IPAMDomain, "."),
+		clusterName: cfg.ClusterName,
+		extattrs:    extattrs,
+		logger:      logger,
+	}, nil
+}
+
+// parseExtAttrs parses extensible attributes in the name=template format, e.g. "Cluster={{.Cluster}}"
+func parseExtAttrs(values []string) (map[string]*template.Template, error) {
+	extattrs := make(map[string]*template.Template, len(values))
+	for _, value := range values {
+		name, text, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return nil, errors.Errorf("invalid extensible attribute %q, expected name=value", value)
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid extensible attribute template %q", value)
+		}
+		extattrs[name] = tmpl
+	}
+	return extattrs, nil
+}
+
+func (r *infobloxRegistrar) renderExtAttrs(data *ExtAttrData) (map[string]interface{}, error) {
+	extattrs := make(map[string]interface{}, len(r.extattrs))
+	for name, tmpl := range r.extattrs {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, errors.Wrapf(err, "failed to render extensible attribute %s", name)
+		}
+		extattrs[name] = map[string]string{"value": buf.String()}
+	}
+	return extattrs, nil
+}
+
+// search returns the references and comments of the objects holding the address
+func (r *infobloxRegistrar) search(ctx context.Context, address string) (string, []infobloxObject, error) {
+	object, field := r.record, "ipv4addr"
+	if r.record == InfobloxHost {
+		object = infobloxHostObject
+	}
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		if r.record == InfobloxFixedAddress {
+			return "", nil, errors.Errorf("Infoblox fixed addresses support IPv4 only, use host records for %s", address)
+		}
+		field = "ipv6addr"
+	}
+	var objects []infobloxObject
+	if err := r.client.do(ctx, http.MethodGet, "/"+object+"?"+url.Values{field: {address}, "_return_fields+": {"comment"}}.Encode(), nil, &objects); err != nil {
+		return "", nil, errors.Wrapf(err, "failed to search Infoblox %s %s", object, address)
+	}
+	return object, objects, nil
+}
+
+func isInfobloxConflict(err error) bool {
+	return isHTTPError(err, http.StatusBadRequest, infobloxConflict)
+}
+
+func (r *infobloxRegistrar) Register(ctx context.Context, nodeName, address string) error {
+	return retryConcurrent(func() error {
+		return r.register(ctx, nodeName, address)
+	}, isInfobloxConflict)
+}
+
+func (r *infobloxRegistrar) register(ctx context.Context, nodeName, address string) error {
+	object, existing, err := r.search(ctx, address)
+	if err != nil {
+		return err
+	}
+	// take over objects registered by kubeip only (released from another node)
+	for _, o := range existing {
+		if !strings.HasPrefix(o.Comment, descriptionPrefix) {
+			return errors.Wrapf(ErrNotRegisteredByKubeIP, "Infoblox %s %s (%q)", object, address, o.Comment)
+		}
+	}
+	extattrs, err := r.renderExtAttrs(&ExtAttrData{Node: nodeName, Cluster: r.clusterName, Address: address})
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{
+		"comment":  description(r.clusterName, nodeName),
+		"extattrs": extattrs,
+	}
+	switch r.record {
+	case InfobloxHost:
+		fields["name"] = fmt.Sprintf("%s.%s", nodeName, r.domain)
+		if len(existing) == 0 {
+			key := "ipv4addrs"
+			if net.ParseIP(address).To4() == nil {
+				key = "ipv6addrs"
+			}
+			fields[key] = []map[string]string{{strings.TrimSuffix(key, "s"): address}}
+		}
+	default:
+		fields["name"] = nodeName
+		if len(existing) == 0 {
+			fields["ipv4addr"] = address
+			fields["match_client"] = infobloxMatchReserved
+		}
+	}
+
+	if len(existing) == 0 {
+		err = r.client.do(ctx, http.MethodPost, "/"+object, fields, nil)
+	} else {
+		err = r.client.do(ctx, http.MethodPut, "/"+existing[0].Ref, fields, nil)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to save Infoblox %s %s", object, address)
+	}
+	r.logger.WithFields(logrus.Fields{
+		"node":    nodeName,
+		"address": address,
+		"object":  object,
+	}).Info("IP address registered in Infoblox")
+	return nil
+}
+
+func (r *infobloxRegistrar) Unregister(ctx context.Context, nodeName, address string) error {
+	object, existing, err := r.search(ctx, address)
+	if err != nil {
+		return err
+	}
+	for _, o := range existing {
+		// keep objects not registered by kubeip for the node
+		if o.Comment != description(r.clusterName, nodeName) {
+			continue
+		}
+		// not found if deleted concurrently
+		err = r.client.do(ctx, http.MethodDelete, "/"+o.Ref, nil, nil)
+		if err != nil && !errors.Is(err, errNotFound) {
+			return errors.Wrapf(err, "failed to delete Infoblox %s %s", object, address)
+		}
+	}
+	r.logger.WithFields(logrus.Fields{
+		"node":    nodeName,
+		"address": address,
+		"object":  object,
+	}).Info("IP address released in Infoblox")
+	return nil
+}
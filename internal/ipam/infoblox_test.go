@@ -0,0 +1,233 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInfoblox is an in-memory Infoblox WAPI with fixed addresses and host records; an address is held by a single object
+// of each type
+type fakeInfoblox struct {
+	mu      sync.Mutex
+	objects map[string]map[string]interface{}
+	nextID  int
+	// concurrent, if set, changes the objects before the next write request, as another agent would
+	concurrent func(f *fakeInfoblox)
+}
+
+// infobloxAddresses returns the addresses held by the object
+func infobloxAddresses(o map[string]interface{}) []interface{} {
+	addresses := []interface{}{o["ipv4addr"]}
+	for _, key := range []string{"ipv4addrs", "ipv6addrs"} {
+		entries, _ := o[key].([]interface{})
+		for _, entry := range entries {
+			if e, ok := entry.(map[string]interface{}); ok {
+				addresses = append(addresses, e["ipv4addr"], e["ipv6addr"])
+			}
+		}
+	}
+	return addresses
+}
+
+func (f *fakeInfoblox) conflicts(path string, o map[string]interface{}) bool {
+	for ref, existing := range f.objects {
+		if !strings.HasPrefix(ref, path+"/") {
+			continue
+		}
+		for _, a := range infobloxAddresses(o) {
+			for _, b := range infobloxAddresses(existing) {
+				if a != nil && a == b {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (f *fakeInfoblox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/wapi/v2.12/")
+	if r.Method != http.MethodGet && f.concurrent != nil {
+		f.concurrent(f)
+		f.concurrent = nil
+	}
+	if _, ok := f.objects[path]; !ok && (r.Method == http.MethodPut || r.Method == http.MethodDelete) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"Error":"AdmConDataNotFoundError: Reference not found","code":"Client.Ibap.Data.NotFound"}`)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		results := []map[string]interface{}{}
+		for ref, o := range f.objects {
+			if !strings.HasPrefix(ref, path+"/") {
+				continue
+			}
+			data, _ := json.Marshal(o) //nolint:errchkjson
+			if strings.Contains(string(data), `"`+r.URL.Query().Get("ipv4addr")+r.URL.Query().Get("ipv6addr")+`"`) {
+				results = append(results, map[string]interface{}{"_ref": ref, "comment": o["comment"]})
+			}
+		}
+		json.NewEncoder(w).Encode(results) //nolint:errcheck,errchkjson
+	case http.MethodPost:
+		var o map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&o) //nolint:errcheck
+		if f.conflicts(path, o) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"Error":"AdmConDataError: None (IBDataConflictError: IB.Data.Conflict:The %s already exists.)","code":%q}`, path, infobloxConflict)
+			return
+		}
+		f.nextID++
+		ref := fmt.Sprintf("%s/ref%d", path, f.nextID)
+		f.objects[ref] = o
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ref) //nolint:errcheck,errchkjson
+	case http.MethodPut:
+		var o map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&o) //nolint:errcheck
+		for k, v := range o {
+			f.objects[path][k] = v
+		}
+		json.NewEncoder(w).Encode(path) //nolint:errcheck,errchkjson
+	case http.MethodDelete:
+		delete(f.objects, path)
+		json.NewEncoder(w).Encode(path) //nolint:errcheck,errchkjson
+	}
+}
+
+func newTestInfoblox(t *testing.T, record, password string, objects map[string]map[string]interface{}) (Registrar, *fakeInfoblox) {
+	if objects == nil {
+		objects = map[string]map[string]interface{}{}
+	}
+	fake := &fakeInfoblox{objects: objects}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	cfg := &config.Config{
+		IPAMURL:            server.URL + "/wapi/v2.12",
+		IPAMUsername:       "admin",
+		IPAMPassword:       password,
+		IPAMInfobloxRecord: record,
+		IPAMDomain:         "egress.example.com",
+		IPAMExtAttrs:       []string{"Cluster={{.Cluster}}", "Owner=kubeip-{{.Node}}"},
+		ClusterName:        "test-cluster",
+	}
+	r, err := NewInfobloxRegistrar(logrus.NewEntry(logrus.New()), cfg)
+	require.NoError(t, err)
+	return r, fake
+}
+
+func TestInfobloxRegistrar_fixedAddress(t *testing.T) {
+	r, fake := newTestInfoblox(t, "", "secret", nil)
+
+	require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+	require.Len(t, fake.objects, 1)
+	o := fake.objects["fixedaddress/ref1"]
+	assert.Equal(t, "1.1.1.1", o["ipv4addr"])
+	assert.Equal(t, "RESERVED", o["match_client"])
+	assert.Equal(t, "node-1", o["name"])
+	assert.Equal(t, map[string]interface{}{
+		"Cluster": map[string]interface{}{"value": "test-cluster"},
+		"Owner":   map[string]interface{}{"value": "kubeip-node-1"},
+	}, o["extattrs"])
+
+	// register again updates the existing fixed address
+	require.NoError(t, r.Register(context.Background(), "node-2", "1.1.1.1"))
+	require.Len(t, fake.objects, 1)
+	assert.Equal(t, "node-2", fake.objects["fixedaddress/ref1"]["name"])
+
+	require.NoError(t, r.Unregister(context.Background(), "node-2", "1.1.1.1"))
+	assert.Empty(t, fake.objects)
+
+	// IPv6 requires host records
+	assert.Error(t, r.Register(context.Background(), "node-1", "2001:db8::1"))
+}
+
+func TestInfobloxRegistrar_host(t *testing.T) {
+	r, fake := newTestInfoblox(t, InfobloxHost, "secret", nil)
+
+	require.NoError(t, r.Register(context.Background(), "node-1", "2001:db8::1"))
+	require.Len(t, fake.objects, 1)
+	o := fake.objects["record:host/ref1"]
+	assert.Equal(t, "node-1.egress.example.com", o["name"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"ipv6addr": "2001:db8::1"}}, o["ipv6addrs"])
+}
+
+func TestInfobloxRegistrar_keepForeignObjects(t *testing.T) {
+	foreign := map[string]map[string]interface{}{
+		"fixedaddress/foreign": {"ipv4addr": "1.1.1.1", "comment": "managed by network team"},
+	}
+	r, fake := newTestInfoblox(t, InfobloxFixedAddress, "secret", foreign)
+	assert.ErrorIs(t, r.Register(context.Background(), "node-1", "1.1.1.1"), ErrNotRegisteredByKubeIP)
+	assert.Equal(t, "managed by network team", fake.objects["fixedaddress/foreign"]["comment"])
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+	assert.Len(t, fake.objects, 1)
+
+	// objects registered for another node are kept on release
+	fake.objects["fixedaddress/other"] = map[string]interface{}{"ipv4addr": "2.2.2.2", "comment": "kubeip: test-cluster/node-2"}
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "2.2.2.2"))
+	assert.Len(t, fake.objects, 2)
+}
+
+func TestInfobloxRegistrar_concurrent(t *testing.T) {
+	t.Run("fixed address created by another node", func(t *testing.T) {
+		r, fake := newTestInfoblox(t, InfobloxFixedAddress, "secret", nil)
+		fake.concurrent = func(f *fakeInfoblox) {
+			f.objects["fixedaddress/other"] = map[string]interface{}{"ipv4addr": "1.1.1.1", "name": "node-2", "comment": "kubeip: test-cluster/node-2"}
+		}
+		require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, fake.objects, 1)
+		assert.Equal(t, "node-1", fake.objects["fixedaddress/other"]["name"])
+	})
+	t.Run("host record created by someone else", func(t *testing.T) {
+		r, fake := newTestInfoblox(t, InfobloxHost, "secret", nil)
+		fake.concurrent = func(f *fakeInfoblox) {
+			f.objects["record:host/foreign"] = map[string]interface{}{
+				"ipv4addrs": []interface{}{map[string]interface{}{"ipv4addr": "1.1.1.1"}},
+				"comment":   "managed by network team",
+			}
+		}
+		assert.ErrorIs(t, r.Register(context.Background(), "node-1", "1.1.1.1"), ErrNotRegisteredByKubeIP)
+		assert.Len(t, fake.objects, 1)
+	})
+	t.Run("fixed address deleted concurrently", func(t *testing.T) {
+		r, fake := newTestInfoblox(t, InfobloxFixedAddress, "secret", nil)
+		require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+		fake.concurrent = func(f *fakeInfoblox) {
+			delete(f.objects, "fixedaddress/ref1")
+		}
+		require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, fake.objects)
+	})
+}
+
+func TestInfobloxRegistrar_unauthorized(t *testing.T) {
+	r, _ := newTestInfoblox(t, InfobloxFixedAddress, "wrong", nil)
+	assert.ErrorContains(t, r.Register(context.Background(), "node-1", "1.1.1.1"), "status 401")
+}
+
+func TestParseExtAttrs(t *testing.T) {
+	_, err := parseExtAttrs([]string{"missing-separator"})
+	assert.Error(t, err)
+	_, err = parseExtAttrs([]string{"Owner={{.Node"})
+	assert.Error(t, err)
+	extattrs, err := parseExtAttrs([]string{"Site=eu-west={{.Cluster}}"})
+	require.NoError(t, err)
+	assert.Contains(t, extattrs, "Site")
+}
@@ -0,0 +1,213 @@
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	netBoxStatusActive = "active"
+	// netBoxDuplicate is the validation error of the creation of an address already in NetBox
+	netBoxDuplicate = "Duplicate IP address"
+)
+
+type netBoxObject struct {
+	ID int `json:"id"`
+}
+
+type netBoxList struct {
+	Results []netBoxObject `json:"results"`
+}
+
+// netBoxAddress is a NetBox IP address with the fields telling the node it is registered for
+type netBoxAddress struct {
+	ID                 int    `json:"id"`
+	Description        string `json:"description"`
+	AssignedObjectType string `json:"assigned_object_type"`
+	AssignedObjectID   int    `json:"assigned_object_id"`
+}
+
+type netBoxAddressList struct {
+	Results []netBoxAddress `json:"results"`
+}
+
+type netBoxRegistrar struct {
+	client      *jsonClient
+	iface       string
+	clusterName string
+	logger      *logrus.Entry
+}
+
+// NewNetBoxRegistrar returns a registrar recording assignments in NetBox IP addresses: on assign, the address is set active,
+// assigned to the node interface (device or virtual machine named as the node) and described; on release, the address
+// registered for the node is deleted, freeing it; addresses not registered by kubeip are left untouched
+func NewNetBoxRegistrar(logger *logrus.Entry, cfg *config.Config) (Registrar, error) {
+	if cfg.IPAMURL == "" || cfg.IPAMToken == "" {
+		return nil, errors.New("IPAM URL and token are required for NetBox")
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &netBoxRegistrar{
+		client: &jsonClient{
+			client:  client,
+			baseURL: strings.TrimSuffix(cfg.IPAMURL, "/"),
+			headers: map[string]string{"Authorization": "Token " + cfg.IPAMToken},
+		},
+		iface:       cfg.IPAMInterface,
+		clusterName: cfg.ClusterName,
+		logger:      logger,
+	}, nil
+}
+
+// cidr returns the address with the host prefix length, as stored by NetBox
+func cidr(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return address + "/128"
+	}
+	return address + "/32"
+}
+
+// find returns the ID of the first object matching the query or 0 if none
+func (r *netBoxRegistrar) find(ctx context.Context, path string, query url.Values) (int, error) {
+	var list netBoxList
+	if err := r.client.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &list); err != nil {
+		return 0, err
+	}
+	if len(list.Results) == 0 {
+		return 0, nil
+	}
+	return list.Results[0].ID, nil
+}
+
+// nodeInterface returns the object type and ID of the node interface: a device interface or a virtual machine interface
+func (r *netBoxRegistrar) nodeInterface(ctx context.Context, nodeName string) (string, int, error) {
+	if r.iface == "" {
+		return "", 0, nil
+	}
+	id, err := r.find(ctx, "/api/dcim/interfaces/", url.Values{"device": {nodeName}, "name": {r.iface}})
+	if err != nil || id != 0 {
+		return "dcim.interface", id, err
+	}
+	id, err = r.find(ctx, "/api/virtualization/interfaces/", url.Values{"virtual_machine": {nodeName}, "name": {r.iface}})
+	return "virtualization.vminterface", id, err
+}
+
+// findAddress returns the NetBox IP address or nil if the address is not in NetBox
+func (r *netBoxRegistrar) findAddress(ctx context.Context, address string) (*netBoxAddress, error) {
+	var list netBoxAddressList
+	if err := r.client.do(ctx, http.MethodGet, "/api/ipam/ip-addresses/?"+url.Values{"address": {address}}.Encode(), nil, &list); err != nil {
+		return nil, errors.Wrapf(err, "failed to find NetBox IP address %s", address)
+	}
+	if len(list.Results) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+	return &list.Results[0], nil
+}
+
+// registeredFor checks if the NetBox IP address is registered for the node: described by kubeip for the node or assigned
+// to the node interface
+func (r *netBoxRegistrar) registeredFor(ctx context.Context, existing *netBoxAddress, nodeName string) (bool, error) {
+	if existing.Description == description(r.clusterName, nodeName) {
+		return true, nil
+	}
+	if existing.AssignedObjectID == 0 {
+		return false, nil
+	}
+	objectType, objectID, err := r.nodeInterface(ctx, nodeName)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find NetBox interface of %s", nodeName)
+	}
+	return objectID != 0 && objectType == existing.AssignedObjectType && objectID == existing.AssignedObjectID, nil
+}
+
+func isNetBoxConflict(err error) bool {
+	return isHTTPError(err, http.StatusBadRequest, netBoxDuplicate)
+}
+
+func (r *netBoxRegistrar) Register(ctx context.Context, nodeName, address string) error {
+	return retryConcurrent(func() error {
+		return r.register(ctx, nodeName, address)
+	}, isNetBoxConflict)
+}
+
+func (r *netBoxRegistrar) register(ctx context.Context, nodeName, address string) error {
+	existing, err := r.findAddress(ctx, address)
+	if err != nil {
+		return err
+	}
+	// take over addresses registered by kubeip only (released from another node)
+	if existing != nil && !strings.HasPrefix(existing.Description, descriptionPrefix) {
+		return errors.Wrapf(ErrNotRegisteredByKubeIP, "NetBox IP address %s (%q)", address, existing.Description)
+	}
+	fields := map[string]interface{}{
+		"status":      netBoxStatusActive,
+		"description": description(r.clusterName, nodeName),
+	}
+	objectType, objectID, err := r.nodeInterface(ctx, nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find NetBox interface of %s", nodeName)
+	}
+	if objectID != 0 {
+		fields["assigned_object_type"] = objectType
+		fields["assigned_object_id"] = objectID
+	} else if r.iface != "" {
+		r.logger.WithFields(logrus.Fields{
+			"node":      nodeName,
+			"interface": r.iface,
+		}).Warn("NetBox interface not found, registering IP address without interface")
+	}
+	if existing == nil {
+		fields["address"] = cidr(address)
+		err = r.client.do(ctx, http.MethodPost, "/api/ipam/ip-addresses/", fields, nil)
+	} else {
+		err = r.client.do(ctx, http.MethodPatch, fmt.Sprintf("/api/ipam/ip-addresses/%d/", existing.ID), fields, nil)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to save NetBox IP address %s", address)
+	}
+	r.logger.WithFields(logrus.Fields{
+		"node":    nodeName,
+		"address": address,
+	}).Info("IP address registered in NetBox")
+	return nil
+}
+
+func (r *netBoxRegistrar) Unregister(ctx context.Context, nodeName, address string) error {
+	logger := r.logger.WithFields(logrus.Fields{
+		"node":    nodeName,
+		"address": address,
+	})
+	existing, err := r.findAddress(ctx, address)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		logger.Debug("IP address not in NetBox, nothing to release")
+		return nil
+	}
+	registered, err := r.registeredFor(ctx, existing, nodeName)
+	if err != nil {
+		return err
+	}
+	if !registered {
+		logger.WithField("description", existing.Description).Warn("NetBox IP address not registered for the node, skipping release")
+		return nil
+	}
+	// not found if deleted concurrently
+	err = r.client.do(ctx, http.MethodDelete, fmt.Sprintf("/api/ipam/ip-addresses/%d/", existing.ID), nil, nil)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return errors.Wrapf(err, "failed to delete NetBox IP address %s", address)
+	}
+	logger.Info("IP address released in NetBox")
+	return nil
+}
@@ -0,0 +1,203 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNetBox is an in-memory NetBox API with IP addresses and a device interface; IP addresses are globally unique
+type fakeNetBox struct {
+	mu        sync.Mutex
+	addresses map[int]map[string]interface{}
+	nextID    int
+	// concurrent, if set, changes the addresses before the next write request, as another agent would
+	concurrent func(f *fakeNetBox)
+}
+
+func (f *fakeNetBox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Token test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"detail":"Invalid token"}`)
+		return
+	}
+	if r.Method != http.MethodGet && f.concurrent != nil {
+		f.concurrent(f)
+		f.concurrent = nil
+	}
+	id, _ := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/ipam/ip-addresses/"), "/")) //nolint:errcheck
+	if _, ok := f.addresses[id]; !ok && (r.Method == http.MethodPatch || r.Method == http.MethodDelete) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"detail":"Not found."}`)
+		return
+	}
+	switch {
+	case r.URL.Path == "/api/dcim/interfaces/":
+		if r.URL.Query().Get("device") == "node-1" && r.URL.Query().Get("name") == "eth0" {
+			fmt.Fprint(w, `{"results":[{"id":7}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"results":[]}`)
+	case r.URL.Path == "/api/virtualization/interfaces/":
+		fmt.Fprint(w, `{"results":[]}`)
+	case r.URL.Path == "/api/ipam/ip-addresses/" && r.Method == http.MethodGet:
+		results := []map[string]interface{}{}
+		for id, a := range f.addresses {
+			if strings.HasPrefix(a["address"].(string), r.URL.Query().Get("address")+"/") {
+				result := map[string]interface{}{"id": id}
+				for k, v := range a {
+					result[k] = v
+				}
+				results = append(results, result)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results}) //nolint:errcheck,errchkjson
+	case r.URL.Path == "/api/ipam/ip-addresses/" && r.Method == http.MethodPost:
+		var a map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&a) //nolint:errcheck
+		for _, existing := range f.addresses {
+			if existing["address"] == a["address"] {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"address":["Duplicate IP address found in global table: %s"]}`, a["address"])
+				return
+			}
+		}
+		f.nextID++
+		f.addresses[f.nextID] = a
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, f.nextID)
+	case strings.HasPrefix(r.URL.Path, "/api/ipam/ip-addresses/") && r.Method == http.MethodPatch:
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields) //nolint:errcheck
+		for k, v := range fields {
+			f.addresses[id][k] = v
+		}
+		fmt.Fprintf(w, `{"id":%d}`, id)
+	case strings.HasPrefix(r.URL.Path, "/api/ipam/ip-addresses/") && r.Method == http.MethodDelete:
+		delete(f.addresses, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestNetBox(t *testing.T, token string) (Registrar, *fakeNetBox) {
+	fake := &fakeNetBox{addresses: map[int]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	cfg := &config.Config{IPAMURL: server.URL + "/", IPAMToken: token, IPAMInterface: "eth0", ClusterName: "test-cluster"}
+	r, err := NewNetBoxRegistrar(logrus.NewEntry(logrus.New()), cfg)
+	require.NoError(t, err)
+	return r, fake
+}
+
+func TestNetBoxRegistrar(t *testing.T) {
+	r, fake := newTestNetBox(t, "test-token")
+
+	// register creates the address assigned to the node interface
+	require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+	require.Len(t, fake.addresses, 1)
+	assert.Equal(t, map[string]interface{}{
+		"address":              "1.1.1.1/32",
+		"status":               "active",
+		"description":          "kubeip: test-cluster/node-1",
+		"assigned_object_type": "dcim.interface",
+		"assigned_object_id":   float64(7),
+	}, fake.addresses[1])
+
+	// unregister from another node keeps the address
+	require.NoError(t, r.Unregister(context.Background(), "node-3", "1.1.1.1"))
+	require.Len(t, fake.addresses, 1)
+
+	// unregister deletes the address of the node, then has nothing to release
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+	assert.Empty(t, fake.addresses)
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+
+	// register on a node without interface in NetBox
+	require.NoError(t, r.Register(context.Background(), "node-2", "2001:db8::1"))
+	require.Len(t, fake.addresses, 1)
+	assert.Equal(t, "2001:db8::1/128", fake.addresses[2]["address"])
+	assert.NotContains(t, fake.addresses[2], "assigned_object_id")
+}
+
+func TestNetBoxRegistrar_ownership(t *testing.T) {
+	r, fake := newTestNetBox(t, "test-token")
+	fake.addresses[1] = map[string]interface{}{"address": "1.1.1.1/32", "status": "active", "description": "office gateway"}
+	fake.addresses[2] = map[string]interface{}{
+		"address":              "2.2.2.2/32",
+		"status":               "active",
+		"description":          "",
+		"assigned_object_type": "dcim.interface",
+		"assigned_object_id":   7,
+	}
+	fake.nextID = 2
+
+	// addresses not registered by kubeip are not taken over
+	assert.ErrorIs(t, r.Register(context.Background(), "node-1", "1.1.1.1"), ErrNotRegisteredByKubeIP)
+	assert.Equal(t, "office gateway", fake.addresses[1]["description"])
+
+	// addresses assigned to the node interface are released
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+	assert.Contains(t, fake.addresses, 1)
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "2.2.2.2"))
+	assert.NotContains(t, fake.addresses, 2)
+}
+
+func TestNetBoxRegistrar_concurrent(t *testing.T) {
+	t.Run("address created by another node", func(t *testing.T) {
+		r, fake := newTestNetBox(t, "test-token")
+		fake.concurrent = func(f *fakeNetBox) {
+			f.nextID++
+			f.addresses[f.nextID] = map[string]interface{}{"address": "1.1.1.1/32", "status": "active", "description": "kubeip: test-cluster/node-2"}
+		}
+		require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, fake.addresses, 1)
+		assert.Equal(t, "kubeip: test-cluster/node-1", fake.addresses[1]["description"])
+	})
+	t.Run("address created by someone else", func(t *testing.T) {
+		r, fake := newTestNetBox(t, "test-token")
+		fake.concurrent = func(f *fakeNetBox) {
+			f.nextID++
+			f.addresses[f.nextID] = map[string]interface{}{"address": "1.1.1.1/32", "status": "active", "description": "office gateway"}
+		}
+		assert.ErrorIs(t, r.Register(context.Background(), "node-1", "1.1.1.1"), ErrNotRegisteredByKubeIP)
+		assert.Equal(t, "office gateway", fake.addresses[1]["description"])
+	})
+	t.Run("address deleted concurrently", func(t *testing.T) {
+		r, fake := newTestNetBox(t, "test-token")
+		require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+		fake.concurrent = func(f *fakeNetBox) {
+			delete(f.addresses, 1)
+		}
+		require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, fake.addresses)
+	})
+}
+
+func TestNetBoxRegistrar_invalidToken(t *testing.T) {
+	r, _ := newTestNetBox(t, "invalid-token")
+	err := r.Register(context.Background(), "node-1", "1.1.1.1")
+	assert.ErrorContains(t, err, "status 403")
+}
+
+func TestNewRegistrar(t *testing.T) {
+	r, err := NewRegistrar(logrus.NewEntry(logrus.New()), &config.Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+	_, err = NewRegistrar(logrus.NewEntry(logrus.New()), &config.Config{IPAMProvider: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
@@ -0,0 +1,161 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// phpIPAMTagUsed is the phpIPAM address tag of assigned addresses
+const phpIPAMTagUsed = 2
+
+type phpIPAMResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type phpIPAMAddress struct {
+	ID          string `json:"id"`
+	Hostname    string `json:"hostname"`
+	Description string `json:"description"`
+}
+
+type phpIPAMRegistrar struct {
+	client      *jsonClient
+	subnetID    string
+	clusterName string
+	logger      *logrus.Entry
+}
+
+// NewPhpIPAMRegistrar returns a registrar recording assignments in phpIPAM addresses (app code token authentication):
+// on assign, the address is tagged used with the node hostname, addresses missing in phpIPAM are created in the configured
+// subnet; on release, the address registered for the node is deleted, freeing it; addresses not registered by kubeip are
+// left untouched
+func NewPhpIPAMRegistrar(logger *logrus.Entry, cfg *config.Config) (Registrar, error) {
+	if cfg.IPAMURL == "" || cfg.IPAMToken == "" {
+		return nil, errors.New("IPAM URL (including the API app ID) and token are required for phpIPAM")
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &phpIPAMRegistrar{
+		client: &jsonClient{
+			client:  client,
+			baseURL: strings.TrimSuffix(cfg.IPAMURL, "/"),
+			headers: map[string]string{"token": cfg.IPAMToken},
+		},
+		subnetID:    cfg.IPAMSubnetID,
+		clusterName: cfg.ClusterName,
+		logger:      logger,
+	}, nil
+}
+
+func (r *phpIPAMRegistrar) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var response phpIPAMResponse
+	if err := r.client.do(ctx, method, path, body, &response); err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.Errorf("phpIPAM %s %s failed: %s", method, path, response.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(response.Data, result), "failed to decode phpIPAM response data")
+}
+
+// find returns the phpIPAM address or nil if the address is not in phpIPAM
+func (r *phpIPAMRegistrar) find(ctx context.Context, address string) (*phpIPAMAddress, error) {
+	var addresses []phpIPAMAddress
+	err := r.do(ctx, http.MethodGet, fmt.Sprintf("/addresses/search/%s/", url.PathEscape(address)), nil, &addresses)
+	if errors.Is(err, errNotFound) || (err == nil && len(addresses) == 0) {
+		return nil, nil //nolint:nilnil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to search phpIPAM address %s", address)
+	}
+	return &addresses[0], nil
+}
+
+// isPhpIPAMConflict reports if the address was created concurrently since searched
+func isPhpIPAMConflict(err error) bool {
+	return isHTTPError(err, http.StatusConflict, "")
+}
+
+func (r *phpIPAMRegistrar) Register(ctx context.Context, nodeName, address string) error {
+	return retryConcurrent(func() error {
+		return r.register(ctx, nodeName, address)
+	}, isPhpIPAMConflict)
+}
+
+func (r *phpIPAMRegistrar) register(ctx context.Context, nodeName, address string) error {
+	existing, err := r.find(ctx, address)
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{
+		"hostname":    nodeName,
+		"description": description(r.clusterName, nodeName),
+		"tag":         phpIPAMTagUsed,
+	}
+	switch {
+	// take over addresses registered by kubeip only (released from another node)
+	case existing != nil && !strings.HasPrefix(existing.Description, descriptionPrefix):
+		return errors.Wrapf(ErrNotRegisteredByKubeIP, "phpIPAM address %s (%q)", address, existing.Description)
+	case existing != nil:
+		err = r.do(ctx, http.MethodPatch, fmt.Sprintf("/addresses/%s/", existing.ID), fields, nil)
+	case r.subnetID != "":
+		fields["ip"] = address
+		fields["subnetId"] = r.subnetID
+		err = r.do(ctx, http.MethodPost, "/addresses/", fields, nil)
+	default:
+		return errors.Errorf("phpIPAM address %s not found and no subnet ID configured to create it", address)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to save phpIPAM address %s", address)
+	}
+	r.logger.WithFields(logrus.Fields{
+		"node":    nodeName,
+		"address": address,
+	}).Info("IP address registered in phpIPAM")
+	return nil
+}
+
+func (r *phpIPAMRegistrar) Unregister(ctx context.Context, nodeName, address string) error {
+	logger := r.logger.WithFields(logrus.Fields{
+		"node":    nodeName,
+		"address": address,
+	})
+	existing, err := r.find(ctx, address)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		logger.Debug("IP address not in phpIPAM, nothing to release")
+		return nil
+	}
+	// release addresses registered for the node only
+	if existing.Hostname != nodeName && existing.Description != description(r.clusterName, nodeName) {
+		logger.WithFields(logrus.Fields{
+			"hostname":    existing.Hostname,
+			"description": existing.Description,
+		}).Warn("phpIPAM address not registered for the node, skipping release")
+		return nil
+	}
+	// not found if deleted concurrently
+	err = r.do(ctx, http.MethodDelete, fmt.Sprintf("/addresses/%s/", existing.ID), nil, nil)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return errors.Wrapf(err, "failed to delete phpIPAM address %s", address)
+	}
+	logger.Info("IP address released in phpIPAM")
+	return nil
+}
@@ -0,0 +1,168 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePhpIPAM is an in-memory phpIPAM API (app ID "kubeip"); an address cannot be created twice
+type fakePhpIPAM struct {
+	mu        sync.Mutex
+	addresses map[string]map[string]interface{}
+	nextID    int
+	// concurrent, if set, changes the addresses before the next write request, as another agent would
+	concurrent func(f *fakePhpIPAM)
+}
+
+func (f *fakePhpIPAM) reply(w http.ResponseWriter, code int, data interface{}) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "success": code < 300, "data": data, "message": http.StatusText(code)}) //nolint:errcheck,errchkjson
+}
+
+func (f *fakePhpIPAM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("token") != "test-token" {
+		f.reply(w, http.StatusUnauthorized, nil)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/kubeip")
+	if r.Method != http.MethodGet && f.concurrent != nil {
+		f.concurrent(f)
+		f.concurrent = nil
+	}
+	id := strings.Trim(strings.TrimPrefix(path, "/addresses/"), "/")
+	if _, ok := f.addresses[id]; !ok && (r.Method == http.MethodPatch || r.Method == http.MethodDelete) {
+		f.reply(w, http.StatusNotFound, nil)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/addresses/search/"):
+		ip := strings.Trim(strings.TrimPrefix(path, "/addresses/search/"), "/")
+		for id, a := range f.addresses {
+			if a["ip"] == ip {
+				result := map[string]interface{}{"id": id}
+				for k, v := range a {
+					result[k] = v
+				}
+				f.reply(w, http.StatusOK, []map[string]interface{}{result})
+				return
+			}
+		}
+		f.reply(w, http.StatusNotFound, nil)
+	case r.Method == http.MethodPost && path == "/addresses/":
+		var a map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&a) //nolint:errcheck
+		for _, existing := range f.addresses {
+			if existing["ip"] == a["ip"] {
+				f.reply(w, http.StatusConflict, nil)
+				return
+			}
+		}
+		f.nextID++
+		f.addresses[fmt.Sprint(f.nextID)] = a
+		f.reply(w, http.StatusCreated, nil)
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "/addresses/"):
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields) //nolint:errcheck
+		for k, v := range fields {
+			f.addresses[id][k] = v
+		}
+		f.reply(w, http.StatusOK, nil)
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/addresses/"):
+		delete(f.addresses, id)
+		f.reply(w, http.StatusOK, nil)
+	default:
+		f.reply(w, http.StatusNotFound, nil)
+	}
+}
+
+func newTestPhpIPAM(t *testing.T, subnetID string) (Registrar, *fakePhpIPAM) {
+	fake := &fakePhpIPAM{addresses: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	cfg := &config.Config{IPAMURL: server.URL + "/api/kubeip/", IPAMToken: "test-token", IPAMSubnetID: subnetID}
+	r, err := NewPhpIPAMRegistrar(logrus.NewEntry(logrus.New()), cfg)
+	require.NoError(t, err)
+	return r, fake
+}
+
+func TestPhpIPAMRegistrar(t *testing.T) {
+	r, fake := newTestPhpIPAM(t, "12")
+
+	// register creates the missing address in the subnet
+	require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+	require.Len(t, fake.addresses, 1)
+	assert.Equal(t, map[string]interface{}{
+		"ip":          "1.1.1.1",
+		"subnetId":    "12",
+		"hostname":    "node-1",
+		"description": "kubeip: node-1",
+		"tag":         float64(phpIPAMTagUsed),
+	}, fake.addresses["1"])
+
+	// unregister from another node keeps the address
+	require.NoError(t, r.Unregister(context.Background(), "node-2", "1.1.1.1"))
+	require.Len(t, fake.addresses, 1)
+
+	// unregister deletes the address of the node, then has nothing to release
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+	assert.Empty(t, fake.addresses)
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+}
+
+func TestPhpIPAMRegistrar_keepForeignAddresses(t *testing.T) {
+	r, fake := newTestPhpIPAM(t, "12")
+	fake.addresses["7"] = map[string]interface{}{"ip": "1.1.1.1", "hostname": "gw-1", "description": "office gateway"}
+
+	assert.ErrorIs(t, r.Register(context.Background(), "node-1", "1.1.1.1"), ErrNotRegisteredByKubeIP)
+	assert.Equal(t, "gw-1", fake.addresses["7"]["hostname"])
+	require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+	assert.Len(t, fake.addresses, 1)
+}
+
+func TestPhpIPAMRegistrar_concurrent(t *testing.T) {
+	t.Run("address created by another node", func(t *testing.T) {
+		r, fake := newTestPhpIPAM(t, "12")
+		fake.concurrent = func(f *fakePhpIPAM) {
+			f.addresses["7"] = map[string]interface{}{"ip": "1.1.1.1", "hostname": "node-2", "description": "kubeip: node-2"}
+		}
+		require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+		require.Len(t, fake.addresses, 1)
+		assert.Equal(t, "node-1", fake.addresses["7"]["hostname"])
+	})
+	t.Run("address deleted concurrently", func(t *testing.T) {
+		r, fake := newTestPhpIPAM(t, "12")
+		require.NoError(t, r.Register(context.Background(), "node-1", "1.1.1.1"))
+		fake.concurrent = func(f *fakePhpIPAM) {
+			delete(f.addresses, "1")
+		}
+		require.NoError(t, r.Unregister(context.Background(), "node-1", "1.1.1.1"))
+		assert.Empty(t, fake.addresses)
+	})
+}
+
+func TestPhpIPAMRegistrar_unauthorized(t *testing.T) {
+	r, _ := newTestPhpIPAM(t, "12")
+	pr, ok := r.(*phpIPAMRegistrar)
+	require.True(t, ok)
+	pr.client.headers["token"] = "invalid-token"
+	assert.ErrorContains(t, r.Register(context.Background(), "node-1", "1.1.1.1"), "status 401")
+}
+
+func TestPhpIPAMRegistrar_noSubnet(t *testing.T) {
+	r, fake := newTestPhpIPAM(t, "")
+	assert.ErrorContains(t, r.Register(context.Background(), "node-1", "1.1.1.1"), "no subnet ID")
+	assert.Empty(t, fake.addresses)
+}
@@ -0,0 +1,58 @@
+package ipam
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProviderNetBox   = "netbox"
+	ProviderInfoblox = "infoblox"
+	ProviderPhpIPAM  = "phpipam"
+)
+
+// descriptionPrefix marks IPAM objects registered by kubeip
+const descriptionPrefix = "kubeip:"
+
+var (
+	ErrUnknownProvider = errors.New("unknown IPAM provider")
+	// ErrNotRegisteredByKubeIP is returned when registering an address held by an IPAM object kubeip did not create
+	ErrNotRegisteredByKubeIP = errors.New("IPAM address not registered by kubeip")
+)
+
+// Registrar keeps the IP address management (IPAM) system in sync with the static public IP addresses assigned to nodes
+type Registrar interface {
+	// Register records the address as assigned to the node; addresses held by IPAM objects not registered by kubeip are
+	// refused with ErrNotRegisteredByKubeIP
+	Register(ctx context.Context, nodeName, address string) error
+	// Unregister records the address as no longer assigned to the node; addresses missing in the IPAM or registered for
+	// another node are left untouched
+	Unregister(ctx context.Context, nodeName, address string) error
+}
+
+// NewRegistrar returns the registrar of the configured IPAM provider or nil if IPAM sync is disabled
+func NewRegistrar(logger *logrus.Entry, cfg *config.Config) (Registrar, error) {
+	switch cfg.IPAMProvider {
+	case "":
+		return nil, nil //nolint:nilnil
+	case ProviderNetBox:
+		return NewNetBoxRegistrar(logger, cfg)
+	case ProviderInfoblox:
+		return NewInfobloxRegistrar(logger, cfg)
+	case ProviderPhpIPAM:
+		return NewPhpIPAMRegistrar(logger, cfg)
+	}
+	return nil, errors.Wrapf(ErrUnknownProvider, "%s, supported providers: %s, %s, %s", cfg.IPAMProvider, ProviderNetBox, ProviderInfoblox, ProviderPhpIPAM)
+}
+
+// description returns the IPAM description of an address assigned to the node
+func description(clusterName, nodeName string) string {
+	if clusterName == "" {
+		return fmt.Sprintf("%s %s", descriptionPrefix, nodeName)
+	}
+	return fmt.Sprintf("%s %s/%s", descriptionPrefix, clusterName, nodeName)
+}
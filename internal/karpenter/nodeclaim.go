@@ -0,0 +1,160 @@
+package karpenter
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	typesv1 "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// NodePoolLabel is the label of the Karpenter NodePool of a NodeClaim
+	NodePoolLabel = "karpenter.sh/nodepool"
+	// StagedAddressAnnotation is the static public IP address assigned to the instance of a NodeClaim before its node
+	// registers
+	StagedAddressAnnotation = "kubeip.com/staged-address"
+	zoneLabel               = "topology.kubernetes.io/zone"
+)
+
+// Resource is the cluster scoped Karpenter NodeClaim resource: the instance Karpenter launches for a node
+var Resource = schema.GroupVersionResource{Group: "karpenter.sh", Version: "v1", Resource: "nodeclaims"}
+
+// NodeClaim is a launched Karpenter instance whose node did not register yet
+type NodeClaim struct {
+	Name       string
+	NodePool   string
+	ProviderID string
+	Zone       string
+	// Labels are the labels of the NodeClaim, those of its NodePool template included: the labels of its future node
+	Labels map[string]string
+}
+
+// Stager pre-stages the static public IP address of the instances Karpenter launches
+type Stager interface {
+	// Watch calls launched with the NodeClaims of the node pools with a launched instance (provider ID known), no
+	// registered node and no staged address, by name, on every change of the NodeClaims and every resync interval, until
+	// the context is done
+	Watch(ctx context.Context, nodePools []string, resync time.Duration, launched func(ctx context.Context, claims []NodeClaim)) error
+	// Stage records the static public IP address assigned to the instance of the NodeClaim
+	Stage(ctx context.Context, name, address string) error
+}
+
+type stager struct {
+	client dynamic.Interface
+}
+
+func NewStager(client dynamic.Interface) Stager {
+	return &stager{client: client}
+}
+
+// Watch runs an informer of the NodeClaims of the node pools: one watch instead of listing the NodeClaims on every check;
+// the NodeClaims are listed once the informer synced
+func (s *stager) Watch(ctx context.Context, nodePools []string, resync time.Duration, launched func(ctx context.Context, claims []NodeClaim)) error {
+	requirement, err := labels.NewRequirement(NodePoolLabel, selection.In, nodePools)
+	if err != nil {
+		return errors.Wrap(err, "invalid Karpenter node pools")
+	}
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(s.client, resync, metav1.NamespaceAll, func(o *metav1.ListOptions) {
+		o.LabelSelector = labels.NewSelector().Add(*requirement).String()
+	})
+	informer := factory.ForResource(Resource)
+	// the changes are coalesced: the handler only signals, the NodeClaims are read from the cache
+	changed := make(chan struct{}, 1)
+	signal := func(interface{}) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	_, err = informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    signal,
+		UpdateFunc: func(_, obj interface{}) { signal(obj) },
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to watch Karpenter NodeClaims")
+	}
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	// the initial NodeClaims are listed at once: the signals of their additions may come before the cache holds them all
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		return nil
+	}
+	for {
+		select {
+		case <-changed:
+			items, err := informer.Lister().List(labels.Everything())
+			if err != nil {
+				return errors.Wrap(err, "failed to list Karpenter NodeClaims")
+			}
+			if claims := launchedClaims(items); len(claims) > 0 {
+				launched(ctx, claims)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// launchedClaims returns the launched NodeClaims of the objects, by name
+func launchedClaims(objects []runtime.Object) []NodeClaim {
+	var claims []NodeClaim
+	for _, object := range objects {
+		item, ok := object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		if claim, ok := launched(item); ok {
+			claims = append(claims, claim)
+		}
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].Name < claims[j].Name })
+	return claims
+}
+
+// launched returns the NodeClaim of the resource if its instance is launched, its node not registered and no address
+// staged; NodeClaims being deleted are skipped
+func launched(item *unstructured.Unstructured) (NodeClaim, bool) {
+	if item.GetDeletionTimestamp() != nil || item.GetAnnotations()[StagedAddressAnnotation] != "" {
+		return NodeClaim{}, false
+	}
+	providerID, _, _ := unstructured.NestedString(item.Object, "status", "providerID")
+	nodeName, _, _ := unstructured.NestedString(item.Object, "status", "nodeName")
+	if providerID == "" || nodeName != "" {
+		return NodeClaim{}, false
+	}
+	return NodeClaim{
+		Name:       item.GetName(),
+		NodePool:   item.GetLabels()[NodePoolLabel],
+		ProviderID: providerID,
+		Zone:       item.GetLabels()[zoneLabel],
+		Labels:     item.GetLabels(),
+	}, true
+}
+
+func (s *stager) Stage(ctx context.Context, name, address string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{StagedAddressAnnotation: address},
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal NodeClaim patch")
+	}
+	if _, err = s.client.Resource(Resource).Patch(ctx, name, typesv1.MergePatchType, data, metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to annotate Karpenter NodeClaim %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package karpenter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newNodeClaim(name, nodePool, providerID, nodeName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "karpenter.sh/v1",
+		"kind":       "NodeClaim",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": map[string]interface{}{NodePoolLabel: nodePool, zoneLabel: "us-west-2a"},
+		},
+		"status": map[string]interface{}{"providerID": providerID, "nodeName": nodeName},
+	}}
+}
+
+func TestStager(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{Resource: "NodeClaimList"},
+		newNodeClaim("public-b", "public", "aws:///us-west-2a/i-0b", ""),
+		newNodeClaim("public-a", "public", "aws:///us-west-2a/i-0a", ""),
+		newNodeClaim("public-launching", "public", "", ""),
+		newNodeClaim("public-registered", "public", "aws:///us-west-2a/i-0c", "ip-10-0-0-1"),
+		newNodeClaim("private", "private", "aws:///us-west-2a/i-0d", ""),
+	)
+	s := NewStager(client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	launched := make(chan []NodeClaim, 10)
+	done := make(chan error)
+	go func() {
+		done <- s.Watch(ctx, []string{"public"}, time.Hour, func(_ context.Context, claims []NodeClaim) {
+			launched <- claims
+		})
+	}()
+
+	labels := map[string]string{NodePoolLabel: "public", zoneLabel: "us-west-2a"}
+	assert.Equal(t, []NodeClaim{
+		{Name: "public-a", NodePool: "public", ProviderID: "aws:///us-west-2a/i-0a", Zone: "us-west-2a", Labels: labels},
+		{Name: "public-b", NodePool: "public", ProviderID: "aws:///us-west-2a/i-0b", Zone: "us-west-2a", Labels: labels},
+	}, <-launched)
+
+	// a staged NodeClaim is not staged again
+	require.NoError(t, s.Stage(ctx, "public-a", "203.0.113.1"))
+	item, err := client.Resource(Resource).Get(ctx, "public-a", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.1", item.GetAnnotations()[StagedAddressAnnotation])
+	// the signals preceding the staging may still deliver both NodeClaims
+	assert.Eventually(t, func() bool {
+		select {
+		case claims := <-launched:
+			return len(claims) == 1 && claims[0].Name == "public-b"
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,78 @@
+package lease
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Elector runs a task on a single agent of the cluster at a time: the agent holding the named lease
+type Elector interface {
+	// Run runs the task while the agent holds the lease, competing for it again once lost, until the context is done
+	Run(ctx context.Context, task func(ctx context.Context))
+}
+
+type elector struct {
+	client        kubernetes.Interface
+	leaseName     string
+	namespace     string
+	identity      string
+	leaseDuration time.Duration
+	logger        *logrus.Entry
+}
+
+// NewElector returns an elector competing for the lease with the identity, unique per agent (e.g. the node name); the
+// lease is distinct from the lock of the assignments, so the elected agent still competes for the lock as the others do
+func NewElector(client kubernetes.Interface, logger *logrus.Entry, leaseName, namespace, identity string, leaseDuration time.Duration) Elector {
+	return &elector{
+		client:        client,
+		leaseName:     leaseName,
+		namespace:     namespace,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		logger:        logger.WithFields(logrus.Fields{"lease": leaseName, "identity": identity}),
+	}
+}
+
+func (e *elector) newLeaderElector(task func(ctx context.Context)) (*leaderelection.LeaderElector, error) {
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: e.leaseName, Namespace: e.namespace},
+			Client:     e.client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: e.identity},
+		},
+		LeaseDuration:   e.leaseDuration,
+		RenewDeadline:   e.leaseDuration * 2 / 3, //nolint:gomnd
+		RetryPeriod:     e.leaseDuration / 5,     //nolint:gomnd
+		ReleaseOnCancel: true,
+		Name:            e.leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				e.logger.Info("lease acquired, running the elected task")
+				task(ctx)
+			},
+			OnStoppedLeading: func() {
+				e.logger.Debug("lease released")
+			},
+		},
+	})
+	return le, errors.Wrap(err, "failed to configure leader election")
+}
+
+func (e *elector) Run(ctx context.Context, task func(ctx context.Context)) {
+	le, err := e.newLeaderElector(task)
+	if err != nil {
+		e.logger.WithError(err).Error("leader election disabled")
+		return
+	}
+	// Run returns once the lease is lost: compete again until the context is done
+	for ctx.Err() == nil {
+		le.Run(ctx)
+	}
+}
@@ -0,0 +1,59 @@
+package lease
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestElector_Run(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	logger := logrus.NewEntry(logrus.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var running, maxRunning int32
+	started := make(chan string, 2)
+	task := func(identity string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			n := atomic.AddInt32(&running, 1)
+			if n > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, n)
+			}
+			started <- identity
+			<-ctx.Done()
+			atomic.AddInt32(&running, -1)
+		}
+	}
+	done := make(chan struct{}, 2)
+	for _, identity := range []string{"node-1", "node-2"} {
+		e := NewElector(client, logger, "kubeip-test", "default", identity, time.Second)
+		go func(identity string) {
+			e.Run(ctx, task(identity))
+			done <- struct{}{}
+		}(identity)
+	}
+
+	// a single agent runs the task
+	leader := <-started
+	select {
+	case other := <-started:
+		t.Fatalf("Run() started the task on %s while %s holds the lease", other, leader)
+	case <-time.After(2 * time.Second):
+	}
+	lease, err := client.CoordinationV1().Leases("default").Get(context.Background(), "kubeip-test", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, leader, *lease.Spec.HolderIdentity)
+
+	cancel()
+	<-done
+	<-done
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	dashboardUID   = "kubeip"
+	dashboardTitle = "KubeIP"
+	// panel grid: two panels per row of the 24 columns grid
+	panelWidth  = 12
+	panelHeight = 8
+	// rateWindow is the window of the rates of the panels
+	rateWindow = "$__rate_interval"
+)
+
+// filterLabels are the labels of the dashboard variables filtering every panel
+var filterLabels = []string{LabelProvider, LabelAddressPool, LabelPool, LabelNode}
+
+type dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []panel    `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Query      string      `json:"query,omitempty"`
+	Datasource *datasource `json:"datasource,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	AllValue   string      `json:"allValue,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type panel struct {
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Type        string      `json:"type"`
+	Datasource  datasource  `json:"datasource"`
+	GridPos     gridPos     `json:"gridPos"`
+	FieldConfig fieldConfig `json:"fieldConfig"`
+	Targets     []target    `json:"targets"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	// Exemplar shows the exemplars of the series, linking the latency percentiles to the trace IDs of the assignments
+	Exemplar bool `json:"exemplar,omitempty"`
+}
+
+// Dashboard returns the Grafana dashboard of the metric families of the registry: a rate panel by result per counter
+// and a latency percentiles panel per histogram, filtered by the provider, address pool, pool and node variables
+func Dashboard(registry *Registry) ([]byte, error) {
+	promDatasource := datasource{Type: "prometheus", UID: "${datasource}"}
+	d := dashboard{
+		UID:           dashboardUID,
+		Title:         dashboardTitle,
+		Tags:          []string{"kubeip"},
+		SchemaVersion: 39, //nolint:gomnd
+		Refresh:       "1m",
+		Time:          timeRange{From: "now-6h", To: "now"},
+		Templating:    templating{List: []variable{{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"}}},
+	}
+	// the variables list the values of the first metric family
+	if metrics := registry.Metrics(); len(metrics) > 0 {
+		for _, label := range filterLabels {
+			d.Templating.List = append(d.Templating.List, variable{
+				Name:       label,
+				Label:      label,
+				Type:       "query",
+				Query:      fmt.Sprintf("label_values(%s, %s)", seriesName(metrics[0].Desc()), label),
+				Datasource: &promDatasource,
+				IncludeAll: true,
+				Multi:      true,
+				AllValue:   ".*",
+				Refresh:    2, //nolint:gomnd
+			})
+		}
+	}
+	for i, m := range registry.Metrics() {
+		desc := m.Desc()
+		p := panel{
+			ID:          i + 1,
+			Title:       desc.Name,
+			Description: desc.Help,
+			Type:        "timeseries",
+			Datasource:  promDatasource,
+			GridPos:     gridPos{H: panelHeight, W: panelWidth, X: (i % 2) * panelWidth, Y: (i / 2) * panelHeight}, //nolint:gomnd
+		}
+		selector := filterSelector(desc)
+		switch desc.Type {
+		case typeCounter:
+			p.FieldConfig.Defaults.Unit = "ops"
+			by := groupBy(desc, LabelResult)
+			p.Targets = []target{{
+				RefID:        "A",
+				Expr:         fmt.Sprintf("sum by (%s) (rate(%s%s[%s]))", by, desc.Name, selector, rateWindow),
+				LegendFormat: legend(by),
+			}}
+		case typeGauge:
+			p.FieldConfig.Defaults.Unit = "short"
+			// info gauges of the addresses are counted, other gauges are values
+			expr, by := "min", groupBy(desc, LabelQuota)
+			if hasLabel(desc, LabelAddress) {
+				expr, by = "count", groupBy(desc, LabelTenant)
+			}
+			p.Targets = []target{{
+				RefID:        "A",
+				Expr:         fmt.Sprintf("%s by (%s) (%s%s)", expr, by, desc.Name, selector),
+				LegendFormat: legend(by),
+			}}
+		case typeHistogram:
+			p.FieldConfig.Defaults.Unit = "s"
+			// the phase durations are split by phase
+			by, prefix := "le", ""
+			if hasLabel(desc, LabelPhase) {
+				by, prefix = "le, "+LabelPhase, legend(LabelPhase)+" "
+			}
+			for j, quantile := range []string{"0.5", "0.9", "0.99"} {
+				p.Targets = append(p.Targets, target{
+					RefID:        string(rune('A' + j)),
+					Expr:         fmt.Sprintf("histogram_quantile(%s, sum by (%s) (rate(%s_bucket%s[%s])))", quantile, by, desc.Name, selector, rateWindow),
+					LegendFormat: prefix + "p" + strings.TrimPrefix(quantile, "0."),
+					Exemplar:     true,
+				})
+			}
+		default:
+			return nil, errors.Errorf("metric %s: unsupported type %s", desc.Name, desc.Type)
+		}
+		d.Panels = append(d.Panels, p)
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	return data, errors.Wrap(err, "failed to marshal dashboard")
+}
+
+// seriesName returns the name of a series of the metric family listing all its label values
+func seriesName(desc Desc) string {
+	if desc.Type == typeHistogram {
+		return desc.Name + "_count"
+	}
+	return desc.Name
+}
+
+// filterSelector returns the selector of the dashboard variables the metric family has labels for
+func filterSelector(desc Desc) string {
+	var matchers []string
+	for _, label := range filterLabels {
+		if hasLabel(desc, label) {
+			matchers = append(matchers, fmt.Sprintf(`%s=~"$%s"`, label, label))
+		}
+	}
+	if len(matchers) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+// groupBy returns the label if the metric family has it, to split its panel; empty otherwise
+func groupBy(desc Desc, label string) string {
+	if hasLabel(desc, label) {
+		return label
+	}
+	return ""
+}
+
+func legend(label string) string {
+	if label == "" {
+		return "__auto"
+	}
+	return "{{" + label + "}}"
+}
+
+func hasLabel(desc Desc, label string) bool {
+	for _, l := range desc.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	data, err := Dashboard(Default)
+	if err != nil {
+		t.Fatalf("Dashboard() error = %v", err)
+	}
+	var d dashboard
+	if err = json.Unmarshal(data, &d); err != nil {
+		t.Fatalf("Dashboard() is not valid JSON: %v", err)
+	}
+	if len(d.Panels) != len(Default.Metrics()) {
+		t.Fatalf("Dashboard() panels = %d, want one per metric (%d)", len(d.Panels), len(Default.Metrics()))
+	}
+	// datasource, provider, address pool, pool and node
+	if len(d.Templating.List) != 5 {
+		t.Errorf("Dashboard() variables = %+v", d.Templating.List)
+	}
+	wantExpr := map[string]string{
+		"kubeip_assignments_total":                 `sum by (result) (rate(kubeip_assignments_total{provider=~"$provider",address_pool=~"$address_pool",pool=~"$pool",node=~"$node"}[$__rate_interval]))`,
+		"kubeip_assigned_address_info":             `count by (tenant) (kubeip_assigned_address_info{provider=~"$provider",address_pool=~"$address_pool",pool=~"$pool",node=~"$node"})`,
+		"kubeip_address_quota_remaining":           `min by (quota) (kubeip_address_quota_remaining{provider=~"$provider"})`,
+		"kubeip_assignment_duration_seconds":       `histogram_quantile(0.5, sum by (le) (rate(kubeip_assignment_duration_seconds_bucket{provider=~"$provider",address_pool=~"$address_pool",pool=~"$pool",node=~"$node"}[$__rate_interval])))`,
+		"kubeip_assignment_phase_duration_seconds": `histogram_quantile(0.5, sum by (le, phase) (rate(kubeip_assignment_phase_duration_seconds_bucket{provider=~"$provider",address_pool=~"$address_pool",pool=~"$pool",node=~"$node"}[$__rate_interval])))`,
+	}
+	for _, p := range d.Panels {
+		want, ok := wantExpr[p.Title]
+		if !ok {
+			continue
+		}
+		if len(p.Targets) == 0 || p.Targets[0].Expr != want {
+			t.Errorf("Dashboard() panel %s targets = %+v, want %s", p.Title, p.Targets, want)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package metrics
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// labels of the metrics; every metric of an operation on a node carries them all, so the panels and alerts group
+// and filter the metrics the same way
+const (
+	LabelProvider = "provider"
+	// LabelAddressPool is the name of the address pool the node draws its address from
+	LabelAddressPool = "address_pool"
+	// LabelPool is the node pool of the node
+	LabelPool   = "pool"
+	LabelNode   = "node"
+	LabelResult = "result"
+	// labels of the assigned addresses
+	LabelTenant  = "tenant"
+	LabelAddress = "address"
+	// LabelQuota is the cloud provider quota of the static public IP addresses
+	LabelQuota = "quota"
+	// LabelPhase is the phase of an assignment
+	LabelPhase = "phase"
+)
+
+// phases of the assignments
+const (
+	// PhaseDiscovery is the discovery of the node: Kubernetes node, instance and cloud provider
+	PhaseDiscovery = "discovery"
+	// PhaseList is the listing of the candidate addresses
+	PhaseList = "list"
+	// PhaseLock is the acquisition of the cluster wide lock
+	PhaseLock = "lock"
+	// PhaseDetach is the removal of the current (ephemeral) address of the instance
+	PhaseDetach = "detach"
+	// PhaseAttach is an attempt to attach a candidate address to the instance
+	PhaseAttach = "attach"
+	// PhaseVerify is the verification of the egress of the assigned address
+	PhaseVerify = "verify"
+	// PhaseReport is the wait for the node to report the assigned address
+	PhaseReport = "report"
+)
+
+// results of the operations
+const (
+	ResultSuccess         = "success"
+	ResultAlreadyAssigned = "already_assigned"
+	ResultFailure         = "failure"
+	// ResultBlocked is a failure retrying cannot fix (invalid filter, permission denied, region mismatch)
+	ResultBlocked = "blocked"
+	// results of the policy of the static public IP addresses held outside the pool
+	ResultKept     = "kept"
+	ResultReplaced = "replaced"
+	ResultRefused  = "refused"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// operationLabels are the labels of the metrics of an operation on a node
+var operationLabels = []string{LabelProvider, LabelAddressPool, LabelPool, LabelNode, LabelResult}
+
+// DurationBuckets are the buckets (seconds) of the operation durations, retries included
+var DurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900}
+
+// exemplarLabel is the label of the trace ID of the exemplars
+const exemplarLabel = "trace_id"
+
+// Metric is a Prometheus metric family with its description, from which the dashboard is generated
+type Metric interface {
+	prometheus.Collector
+	// Desc returns the description of the metric family
+	Desc() Desc
+}
+
+// Desc describes a metric family
+type Desc struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
+}
+
+// exemplarLabels returns the labels of the exemplar of the trace ID; nil without trace ID
+func exemplarLabels(traceID string) prometheus.Labels {
+	if traceID == "" {
+		return nil
+	}
+	return prometheus.Labels{exemplarLabel: traceID}
+}
+
+// value returns the sample of the series of the label values of the metric family, nil if the series does not exist;
+// the series is not created, unlike with WithLabelValues
+func value(m Metric, values []string) *dto.Metric {
+	desc := m.Desc()
+	want := make(map[string]string, len(desc.Labels))
+	for i, label := range desc.Labels {
+		want[label] = values[i]
+	}
+	samples := make(chan prometheus.Metric)
+	go func() {
+		m.Collect(samples)
+		close(samples)
+	}()
+	var found *dto.Metric
+	for sample := range samples {
+		var metric dto.Metric
+		if found != nil || sample.Write(&metric) != nil {
+			continue
+		}
+		matches := len(metric.GetLabel()) == len(want)
+		for _, pair := range metric.GetLabel() {
+			matches = matches && want[pair.GetName()] == pair.GetValue()
+		}
+		if matches {
+			found = &metric
+		}
+	}
+	return found
+}
+
+// Counter is a counter metric family
+type Counter struct {
+	*prometheus.CounterVec
+	desc Desc
+}
+
+// NewCounter returns a counter metric family with the labels
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{
+		CounterVec: prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels),
+		desc:       Desc{Name: name, Help: help, Type: typeCounter, Labels: labels},
+	}
+}
+
+func (c *Counter) Desc() Desc {
+	return c.desc
+}
+
+// Inc increments the counter of the label values
+func (c *Counter) Inc(values ...string) {
+	c.IncExemplar("", values...)
+}
+
+// IncExemplar increments the counter of the label values, recording the increment as the exemplar of the trace ID
+// unless empty
+func (c *Counter) IncExemplar(traceID string, values ...string) {
+	c.WithLabelValues(values...).(prometheus.ExemplarAdder).AddWithExemplar(1, exemplarLabels(traceID))
+}
+
+// Value returns the counter of the label values
+func (c *Counter) Value(values ...string) float64 {
+	return value(c, values).GetCounter().GetValue()
+}
+
+// Gauge is a gauge metric family; its series can be deleted, e.g. the info series of a released address
+type Gauge struct {
+	*prometheus.GaugeVec
+	desc Desc
+}
+
+// NewGauge returns a gauge metric family with the labels
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{
+		GaugeVec: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels),
+		desc:     Desc{Name: name, Help: help, Type: typeGauge, Labels: labels},
+	}
+}
+
+func (g *Gauge) Desc() Desc {
+	return g.desc
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(value float64, values ...string) {
+	g.WithLabelValues(values...).Set(value)
+}
+
+// Delete removes the series of the label values
+func (g *Gauge) Delete(values ...string) {
+	g.DeleteLabelValues(values...)
+}
+
+// Value returns the gauge of the label values
+func (g *Gauge) Value(values ...string) float64 {
+	return value(g, values).GetGauge().GetValue()
+}
+
+// Histogram is a histogram metric family
+type Histogram struct {
+	*prometheus.HistogramVec
+	desc Desc
+}
+
+// NewHistogram returns a histogram metric family with the upper bounds of the buckets (sorted) and the labels
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{
+		HistogramVec: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels),
+		desc:         Desc{Name: name, Help: help, Type: typeHistogram, Labels: labels},
+	}
+}
+
+func (h *Histogram) Desc() Desc {
+	return h.desc
+}
+
+// Observe adds the value to the histogram of the label values
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.ObserveExemplar(value, "", values...)
+}
+
+// ObserveExemplar adds the value to the histogram of the label values, recording it as the exemplar of the trace ID
+// in the bucket of the value unless the trace ID is empty
+func (h *Histogram) ObserveExemplar(value float64, traceID string, values ...string) {
+	h.WithLabelValues(values...).(prometheus.ExemplarObserver).ObserveWithExemplar(value, exemplarLabels(traceID))
+}
+
+// Registry is a set of metric families exposed together
+type Registry struct {
+	registry *prometheus.Registry
+	metrics  []Metric
+}
+
+// NewRegistry returns a registry of the metric families, panicking on a metric family registered twice (a programming
+// error)
+func NewRegistry(metrics ...Metric) *Registry {
+	registry := prometheus.NewRegistry()
+	for _, m := range metrics {
+		registry.MustRegister(m)
+	}
+	return &Registry{registry: registry, metrics: metrics}
+}
+
+// Metrics returns the metric families of the registry
+func (r *Registry) Metrics() []Metric {
+	return r.metrics
+}
+
+// metrics of the agent
+var (
+	Assignments = NewCounter("kubeip_assignments_total",
+		"Static public IP address assignments of a node, retries included, by result (success, already_assigned, failure).",
+		operationLabels...)
+	AssignmentDuration = NewHistogram("kubeip_assignment_duration_seconds",
+		"Duration of the static public IP address assignments of a node, retries included.",
+		DurationBuckets, operationLabels...)
+	Releases = NewCounter("kubeip_releases_total",
+		"Static public IP address releases of a node by result (success, failure).",
+		operationLabels...)
+
+	NonPoolAddresses = NewCounter("kubeip_non_pool_addresses_total",
+		"Static public IP addresses held by a node outside the pool, by result of the non-pool address policy (kept, replaced, refused).",
+		operationLabels...)
+
+	AssignedAddresses = NewGauge("kubeip_assigned_address_info",
+		"Static public IP address assigned to a node, attributed to the tenant of the node pool; always 1.",
+		LabelProvider, LabelAddressPool, LabelPool, LabelNode, LabelTenant, LabelAddress)
+	QuotaRemaining = NewGauge("kubeip_address_quota_remaining",
+		"Static public IP addresses left in the cloud provider quota of the region, checked at startup.",
+		LabelProvider, LabelQuota)
+
+	ListPages = NewCounter("kubeip_list_pages_total",
+		"Pages of static public IP addresses fetched from the cloud provider list APIs.",
+		LabelProvider)
+
+	EgressDrift = NewGauge("kubeip_egress_drift",
+		"Traffic of a node egressing from another address than its static public IP address, checked by the connectivity watchdog: 1 on drift, 0 otherwise.",
+		LabelProvider, LabelAddressPool, LabelPool, LabelNode)
+
+	AssignmentPhaseDuration = NewHistogram("kubeip_assignment_phase_duration_seconds",
+		"Duration of the phases of the static public IP address assignments of a node (discovery, list, lock, detach, attach, verify, report), each attempt observed.",
+		DurationBuckets, LabelProvider, LabelAddressPool, LabelPool, LabelNode, LabelPhase)
+
+	ProviderOutage = NewGauge("kubeip_provider_outage_seconds",
+		"Duration of the ongoing cloud provider outage, from the first failed request, updated on every failed retry; 0 once a request is served.",
+		LabelProvider)
+
+	// Default is the registry of the agent metrics
+	Default = NewRegistry(Assignments, AssignmentDuration, Releases, NonPoolAddresses, AssignedAddresses, QuotaRemaining, ListPages, EgressDrift,
+		ProviderOutage, AssignmentPhaseDuration)
+)
+
+// ObserveAssignment records an assignment of the node and its duration, with the trace ID of the context as exemplar
+func ObserveAssignment(ctx context.Context, provider, addressPool, pool, node, result string, duration time.Duration) {
+	traceID := TraceID(ctx)
+	Assignments.IncExemplar(traceID, provider, addressPool, pool, node, result)
+	AssignmentDuration.ObserveExemplar(duration.Seconds(), traceID, provider, addressPool, pool, node, result)
+}
+
+// ObserveRelease records a release of the node
+func ObserveRelease(provider, addressPool, pool, node, result string) {
+	Releases.Inc(provider, addressPool, pool, node, result)
+}
+
+// ObserveNonPoolAddress records a static public IP address held by the node outside the pool
+func ObserveNonPoolAddress(provider, addressPool, pool, node, result string) {
+	NonPoolAddresses.Inc(provider, addressPool, pool, node, result)
+}
+
+// ObserveAssignedAddress records the address assigned to the node, attributed to the tenant
+func ObserveAssignedAddress(provider, addressPool, pool, node, tenant, address string) {
+	AssignedAddresses.Set(1, provider, addressPool, pool, node, tenant, address)
+}
+
+// ObserveReleasedAddress removes the address released from the node
+func ObserveReleasedAddress(provider, addressPool, pool, node, tenant, address string) {
+	AssignedAddresses.Delete(provider, addressPool, pool, node, tenant, address)
+}
+
+// ObserveQuota records the static public IP addresses left in the quota
+func ObserveQuota(provider, quota string, remaining float64) {
+	QuotaRemaining.Set(remaining, provider, quota)
+}
+
+// ObserveListPage records a page of addresses fetched from the cloud provider
+func ObserveListPage(provider string) {
+	ListPages.Inc(provider)
+}
+
+// ObserveEgressDrift records whether the traffic of the node egresses from another address than its static public IP address
+func ObserveEgressDrift(provider, addressPool, pool, node string, drifted bool) {
+	value := 0.0
+	if drifted {
+		value = 1
+	}
+	EgressDrift.Set(value, provider, addressPool, pool, node)
+}
+
+// ObserveProviderOutage records the duration of the ongoing cloud provider outage, 0 once the cloud provider is available
+func ObserveProviderOutage(provider string, outage time.Duration) {
+	ProviderOutage.Set(outage.Seconds(), provider)
+}
+
+// phaseLabelsKey is the context key of the labels of the phase durations
+type phaseLabelsKey struct{}
+
+// WithPhaseLabels returns the context of the operations on the node, labelling the phase durations observed with it
+func WithPhaseLabels(ctx context.Context, provider, addressPool, pool, node string) context.Context {
+	return context.WithValue(ctx, phaseLabelsKey{}, []string{provider, addressPool, pool, node})
+}
+
+// ObservePhase records the duration of the phase started at the time, labelled by the context, with the trace ID of
+// the context as exemplar; the phases of a context without labels (e.g. the one-shot commands) are not recorded
+func ObservePhase(ctx context.Context, phase string, start time.Time) {
+	labels, ok := ctx.Value(phaseLabelsKey{}).([]string)
+	if !ok {
+		return
+	}
+	AssignmentPhaseDuration.ObserveExemplar(time.Since(start).Seconds(), TraceID(ctx), append(append([]string(nil), labels...), phase)...)
+}
+
+// traceIDKey is the context key of the trace ID of the exemplars
+type traceIDKey struct{}
+
+// WithTraceID returns the context of an operation with the trace ID, recorded as exemplar of the metrics observed with it
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceID returns the trace ID of the context; empty if none
+func TraceID(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// NewTraceID returns a random trace ID in the W3C trace context format (32 hex digits)
+func NewTraceID() string {
+	id := make([]byte, 16) //nolint:gomnd
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
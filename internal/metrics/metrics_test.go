@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the metric families of the registry exposed by its handler, in the format accepted
+func scrape(t *testing.T, registry *Registry, accept string) (string, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	Handler(registry).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Handler() = %d %s", rec.Code, rec.Body.String())
+	}
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec.Header().Get("Content-Type"), string(body)
+}
+
+func TestHandler(t *testing.T) {
+	counter := NewCounter("test_total", "Test counter.", LabelNode, LabelResult)
+	counter.Inc("node-1", ResultSuccess)
+	counter.Inc("node-1", ResultSuccess)
+	counter.Inc(`node-"2"`, ResultFailure)
+	histogram := NewHistogram("test_seconds", "Test histogram.", []float64{1, 5}, LabelNode)
+	histogram.Observe(0.5, "node-1")
+	histogram.Observe(3, "node-1")
+	histogram.Observe(10, "node-1")
+
+	contentType, body := scrape(t, NewRegistry(counter, histogram), "")
+	if !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Handler() content type = %s", contentType)
+	}
+	want := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{node="node-1",le="1"} 1
+test_seconds_bucket{node="node-1",le="5"} 2
+test_seconds_bucket{node="node-1",le="+Inf"} 3
+test_seconds_sum{node="node-1"} 13.5
+test_seconds_count{node="node-1"} 3
+# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{node="node-\"2\"",result="failure"} 1
+test_total{node="node-1",result="success"} 2
+`
+	if body != want {
+		t.Errorf("Handler() = %s, want %s", body, want)
+	}
+	if got := counter.Value("node-1", ResultSuccess); got != 2 {
+		t.Errorf("Value() = %v, want 2", got)
+	}
+}
+
+func TestHandler_OpenMetrics(t *testing.T) {
+	counter := NewCounter("test_total", "Test counter.", LabelNode)
+	counter.Inc("node-1")
+	counter.IncExemplar("4bf92f3577b34da6a3ce929d0e0e4736", "node-1")
+	histogram := NewHistogram("test_seconds", "Test histogram.", []float64{1, 5}, LabelNode)
+	histogram.ObserveExemplar(3, "00f067aa0ba902b700f067aa0ba902b7", "node-1")
+	histogram.Observe(10, "node-1")
+
+	// the OpenMetrics format is negotiated
+	contentType, body := scrape(t, NewRegistry(counter, histogram), "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	if !strings.HasPrefix(contentType, "application/openmetrics-text; version=1.0.0") || !strings.HasSuffix(body, "# EOF\n") {
+		t.Fatalf("Handler() OpenMetrics = %s %s", contentType, body)
+	}
+	// the exemplar is in the bucket of its value only
+	for _, want := range []string{
+		`test_total{node="node-1"} 2.0 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 1.0 `,
+		`test_seconds_bucket{node="node-1",le="1.0"} 0` + "\n",
+		`test_seconds_bucket{node="node-1",le="5.0"} 1 # {trace_id="00f067aa0ba902b700f067aa0ba902b7"} 3.0 `,
+		`test_seconds_bucket{node="node-1",le="+Inf"} 2` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Handler() OpenMetrics = %s, want %s", body, want)
+		}
+	}
+
+	// the Prometheus text format has no exemplars
+	if _, body = scrape(t, NewRegistry(counter, histogram), ""); strings.Contains(body, "trace_id") {
+		t.Errorf("Handler() = %s, want no exemplars", body)
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	if got := TraceID(context.Background()); got != "" {
+		t.Errorf("TraceID() without trace ID = %q, want empty", got)
+	}
+	traceID := NewTraceID()
+	if len(traceID) != 32 || traceID == NewTraceID() {
+		t.Errorf("NewTraceID() = %q, want 32 random hex digits", traceID)
+	}
+	if got := TraceID(WithTraceID(context.Background(), traceID)); got != traceID {
+		t.Errorf("TraceID() = %q, want %q", got, traceID)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	gauge := NewGauge("test_info", "Test gauge.", LabelNode, LabelAddress)
+	gauge.Set(1, "node-1", "1.1.1.1")
+	gauge.Set(1, "node-2", "2.2.2.2")
+	gauge.Delete("node-1", "1.1.1.1")
+
+	_, body := scrape(t, NewRegistry(gauge), "")
+	want := `# HELP test_info Test gauge.
+# TYPE test_info gauge
+test_info{address="2.2.2.2",node="node-2"} 1
+`
+	if body != want {
+		t.Errorf("Handler() = %s, want %s", body, want)
+	}
+	if got := gauge.Value("node-1", "1.1.1.1"); got != 0 {
+		t.Errorf("Value() of a deleted series = %v, want 0", got)
+	}
+	// reading a value does not create its series
+	if _, body = scrape(t, NewRegistry(gauge), ""); strings.Contains(body, "node-1") {
+		t.Errorf("Handler() = %s, want the deleted series removed", body)
+	}
+}
+
+func TestObservePhase(t *testing.T) {
+	// the phases are observed into a histogram of the test, not the package one other tests observe into
+	global := AssignmentPhaseDuration
+	AssignmentPhaseDuration = NewHistogram(global.desc.Name, global.desc.Help, DurationBuckets, global.desc.Labels...)
+	t.Cleanup(func() { AssignmentPhaseDuration = global })
+
+	// the one-shot commands do not label their phases
+	ObservePhase(context.Background(), PhaseList, time.Now())
+	if _, body := scrape(t, NewRegistry(AssignmentPhaseDuration), ""); body != "" {
+		t.Fatalf("ObservePhase() without labels recorded %s", body)
+	}
+	ctx := WithPhaseLabels(context.Background(), "gcp", "default", "pool-1", "node-1")
+	ObservePhase(ctx, PhaseList, time.Now().Add(-2*time.Second))
+	observed := value(AssignmentPhaseDuration, []string{"gcp", "default", "pool-1", "node-1", PhaseList}).GetHistogram()
+	if observed.GetSampleCount() != 1 || observed.GetSampleSum() < 2 || observed.GetSampleSum() > 2.5 {
+		t.Fatalf("ObservePhase() count = %v, sum = %v, want one observation of 2s", observed.GetSampleCount(), observed.GetSampleSum())
+	}
+	// one observation of 2s: the buckets from 2.5s on count it
+	for _, bucket := range observed.GetBucket() {
+		want := uint64(0)
+		if bucket.GetUpperBound() >= 2.5 {
+			want = 1
+		}
+		if bucket.GetCumulativeCount() != want {
+			t.Errorf("ObservePhase() bucket %v = %v, want %v", bucket.GetUpperBound(), bucket.GetCumulativeCount(), want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/doitintl/kubeip/internal/httpserver"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// Handler returns the handler exposing the metric families of the registry in the Prometheus text format, or in the
+// OpenMetrics text format with the exemplars if the scraper accepts it
+func Handler(registry *Registry) http.Handler {
+	return promhttp.HandlerFor(registry.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// Serve serves the metric families of the registry at /metrics of the address until the context is done, over TLS if
+// configured
+func Serve(ctx context.Context, log *logrus.Entry, address string, registry *Registry, t httpserver.TLS) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(registry))
+	return httpserver.Serve(ctx, log, "metrics", address, mux, t) //nolint:wrapcheck
+}
@@ -0,0 +1,163 @@
+package netlink
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Runner runs the command and returns its combined output
+type Runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// Run runs the command on the node
+func Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.Bytes(), err //nolint:wrapcheck
+}
+
+// Address is an address of a local interface
+type Address struct {
+	// IP is the address, without the prefix length
+	IP string
+	// Prefix is the address with its prefix length, as bound to the interface
+	Prefix string
+	// Secondary is set for the additional addresses of a subnet already bound to the interface
+	Secondary bool
+}
+
+// Link programs the addresses of a local interface of the node for the providers binding the assigned address on the
+// node itself (bare metal, BGP): the address is bound as a host address (/32, /128) next to the addresses of the
+// interface, which are never replaced
+type Link interface {
+	// Add binds the address to the interface; binding a bound address succeeds
+	Add(ctx context.Context, address string) error
+	// Remove unbinds the address from the interface; unbinding an unbound address succeeds
+	Remove(ctx context.Context, address string) error
+	// Addresses lists the addresses of the interface, primary and secondary
+	Addresses(ctx context.Context) ([]Address, error)
+	// Cleanup unbinds the addresses bound by the link and not removed since, e.g. on exit
+	Cleanup(ctx context.Context) error
+}
+
+type link struct {
+	name  string
+	run   Runner
+	mutex sync.Mutex
+	// bound are the addresses bound by the link
+	bound map[string]bool
+}
+
+// NewLink returns the link programming the addresses of the interface with the ip command (iproute2); requires
+// hostNetwork and the NET_ADMIN capability
+func NewLink(name string, run Runner) Link {
+	return &link{name: name, run: run, bound: make(map[string]bool)}
+}
+
+// hostPrefix returns the host prefix of the address: /32 for IPv4, /128 for IPv6
+func hostPrefix(address string) (string, string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", "", errors.Errorf("invalid address %q", address)
+	}
+	if ip.To4() != nil {
+		return ip.String(), ip.String() + "/32", nil
+	}
+	return ip.String(), ip.String() + "/128", nil
+}
+
+func (l *link) ip(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := l.run(ctx, "ip", args...)
+	if err != nil {
+		return out, errors.Wrapf(err, "ip %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+func (l *link) Add(ctx context.Context, address string) error {
+	ip, prefix, err := hostPrefix(address)
+	if err != nil {
+		return err
+	}
+	// replace: add fails on a bound address
+	if _, err = l.ip(ctx, "addr", "replace", prefix, "dev", l.name); err != nil {
+		return errors.Wrapf(err, "failed to bind %s to %s", ip, l.name)
+	}
+	l.mutex.Lock()
+	l.bound[ip] = true
+	l.mutex.Unlock()
+	return nil
+}
+
+func (l *link) Remove(ctx context.Context, address string) error {
+	ip, prefix, err := hostPrefix(address)
+	if err != nil {
+		return err
+	}
+	out, err := l.ip(ctx, "addr", "del", prefix, "dev", l.name)
+	// already unbound
+	if err != nil && !strings.Contains(string(out), "Cannot assign requested address") {
+		return errors.Wrapf(err, "failed to unbind %s from %s", ip, l.name)
+	}
+	l.mutex.Lock()
+	delete(l.bound, ip)
+	l.mutex.Unlock()
+	return nil
+}
+
+func (l *link) Addresses(ctx context.Context) ([]Address, error) {
+	out, err := l.ip(ctx, "-o", "addr", "show", "dev", l.name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the addresses of %s", l.name)
+	}
+	return parseAddresses(out), nil
+}
+
+// parseAddresses parses the one line per address output of ip -o addr show, e.g.
+// 2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global secondary eth0\       valid_lft forever preferred_lft forever
+func parseAddresses(out []byte) []Address {
+	var addresses []Address
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			continue
+		}
+		a := Address{IP: ip.String(), Prefix: fields[3]}
+		for _, flag := range fields[4:] {
+			if flag == "secondary" {
+				a.Secondary = true
+			}
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses
+}
+
+func (l *link) Cleanup(ctx context.Context) error {
+	l.mutex.Lock()
+	bound := make([]string, 0, len(l.bound))
+	for ip := range l.bound {
+		bound = append(bound, ip)
+	}
+	l.mutex.Unlock()
+	// remove all the addresses and report the first failure
+	var first error
+	for _, ip := range bound {
+		if err := l.Remove(ctx, ip); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
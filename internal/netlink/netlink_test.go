@@ -0,0 +1,75 @@
+package netlink
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLink(t *testing.T) {
+	ctx := context.Background()
+	var commands []string
+	l := NewLink("eth0", func(_ context.Context, name string, args ...string) ([]byte, error) {
+		command := name + " " + strings.Join(args, " ")
+		commands = append(commands, command)
+		if command == "ip addr del 192.0.2.11/32 dev eth0" {
+			return []byte("RTNETLINK answers: Cannot assign requested address"), errors.New("exit status 2")
+		}
+		return nil, nil
+	})
+
+	require.NoError(t, l.Add(ctx, "192.0.2.10"))
+	require.NoError(t, l.Add(ctx, "2001:db8::10"))
+	require.NoError(t, l.Remove(ctx, "192.0.2.11"), "already unbound")
+	assert.Error(t, l.Add(ctx, "eth0"))
+	assert.Equal(t, []string{
+		"ip addr replace 192.0.2.10/32 dev eth0",
+		"ip addr replace 2001:db8::10/128 dev eth0",
+		"ip addr del 192.0.2.11/32 dev eth0",
+	}, commands)
+
+	// the cleanup removes the addresses still bound by the link
+	require.NoError(t, l.Remove(ctx, "2001:db8::10"))
+	commands = nil
+	require.NoError(t, l.Cleanup(ctx))
+	assert.Equal(t, []string{"ip addr del 192.0.2.10/32 dev eth0"}, commands)
+	commands = nil
+	require.NoError(t, l.Cleanup(ctx))
+	assert.Empty(t, commands)
+}
+
+func TestLink_failure(t *testing.T) {
+	l := NewLink("eth0", func(context.Context, string, ...string) ([]byte, error) {
+		return []byte("RTNETLINK answers: Operation not permitted"), errors.New("exit status 2")
+	})
+	err := l.Add(context.Background(), "192.0.2.10")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Operation not permitted")
+	assert.Error(t, l.Remove(context.Background(), "192.0.2.10"))
+}
+
+func TestLink_Addresses(t *testing.T) {
+	out := `2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet 10.0.0.6/24 scope global secondary eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet 192.0.2.10/32 scope global eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet6 2001:db8::10/128 scope global \       valid_lft forever preferred_lft forever
+2: eth0    inet6 fe80::1/64 scope link \       valid_lft forever preferred_lft forever
+`
+	l := NewLink("eth0", func(_ context.Context, _ string, args ...string) ([]byte, error) {
+		assert.Equal(t, []string{"-o", "addr", "show", "dev", "eth0"}, args)
+		return []byte(out), nil
+	})
+	addresses, err := l.Addresses(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Address{
+		{IP: "10.0.0.5", Prefix: "10.0.0.5/24"},
+		{IP: "10.0.0.6", Prefix: "10.0.0.6/24", Secondary: true},
+		{IP: "192.0.2.10", Prefix: "192.0.2.10/32"},
+		{IP: "2001:db8::10", Prefix: "2001:db8::10/128"},
+		{IP: "fe80::1", Prefix: "fe80::1/64"},
+	}, addresses)
+}
@@ -0,0 +1,176 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Requirement is a state of the node the assignment waits for: a condition with its status, or a label with its value
+type Requirement struct {
+	Condition v1.NodeConditionType
+	Status    v1.ConditionStatus
+	Label     string
+	// Value is the value of the label; empty matches any value
+	Value string
+}
+
+func (r Requirement) String() string {
+	if r.Label != "" {
+		if r.Value == "" {
+			return "label " + r.Label
+		}
+		return "label " + r.Label + "=" + r.Value
+	}
+	return "condition " + string(r.Condition) + "=" + string(r.Status)
+}
+
+// Met reports whether the node meets the requirement
+func (r Requirement) Met(n *v1.Node) bool {
+	if r.Label != "" {
+		value, ok := n.Labels[r.Label]
+		return ok && (r.Value == "" || value == r.Value)
+	}
+	for _, condition := range n.Status.Conditions {
+		if condition.Type == r.Condition {
+			return condition.Status == r.Status
+		}
+	}
+	return false
+}
+
+// ParseRequirements parses the conditions, <type>[=<status>] with True as default status (e.g. Ready,
+// NetworkUnavailable=False), and the labels, <key>[=<value>], the node must meet
+func ParseRequirements(conditions, labels []string) ([]Requirement, error) {
+	requirements := make([]Requirement, 0, len(conditions)+len(labels))
+	for _, condition := range conditions {
+		conditionType, status, found := strings.Cut(condition, "=")
+		if !found {
+			status = string(v1.ConditionTrue)
+		}
+		switch v1.ConditionStatus(status) {
+		case v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown:
+		default:
+			return nil, errors.Errorf("invalid status of node condition %q, want True, False or Unknown", condition)
+		}
+		if conditionType == "" {
+			return nil, errors.Errorf("invalid node condition %q, want <type>[=<status>]", condition)
+		}
+		requirements = append(requirements, Requirement{Condition: v1.NodeConditionType(conditionType), Status: v1.ConditionStatus(status)})
+	}
+	for _, label := range labels {
+		key, value, _ := strings.Cut(label, "=")
+		if key == "" {
+			return nil, errors.Errorf("invalid node label %q, want <key>[=<value>]", label)
+		}
+		requirements = append(requirements, Requirement{Label: key, Value: value})
+	}
+	return requirements, nil
+}
+
+// BootstrapWaiter waits for the node to meet the requirements before the assignment: swapping the public IP address
+// while the kubelet or the CNI bootstraps breaks their registration on some platforms
+type BootstrapWaiter interface {
+	Wait(ctx context.Context, nodeName string, requirements []Requirement) error
+}
+
+type bootstrapWaiter struct {
+	client kubernetes.Interface
+	// resync is the interval of the checks of the node besides its changes, in case the watch misses one
+	resync time.Duration
+	// unmet is called with the requirements the node does not meet yet on every check
+	unmet func([]Requirement)
+}
+
+// NewBootstrapWaiter returns a waiter checking the node on every change (added, Ready transition, label, provider ID)
+// and every resync interval; unmet, if not nil, is called with the requirements not met yet
+func NewBootstrapWaiter(client kubernetes.Interface, resync time.Duration, unmet func([]Requirement)) BootstrapWaiter {
+	return &bootstrapWaiter{
+		client: client,
+		resync: resync,
+		unmet:  unmet,
+	}
+}
+
+// Wait blocks until the node meets all the requirements or the context is done
+func (w *bootstrapWaiter) Wait(ctx context.Context, nodeName string, requirements []Requirement) error {
+	if len(requirements) == 0 {
+		return nil
+	}
+	ticker := time.NewTicker(w.resync)
+	defer ticker.Stop()
+	// the watch is opened before the first check, so no change is missed in between
+	changes := w.watch(ctx, nodeName)
+	defer func() {
+		if changes != nil {
+			changes.Stop()
+		}
+	}()
+	check := func(n *v1.Node) bool {
+		unmet := unmetRequirements(n, requirements)
+		if len(unmet) > 0 && w.unmet != nil {
+			w.unmet(unmet)
+		}
+		return len(unmet) == 0
+	}
+	// the API server may not answer during the bootstrap: keep checking until the context is done
+	if n, err := w.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{}); err == nil && check(n) {
+		return nil
+	}
+	for {
+		var events <-chan watch.Event
+		if changes != nil {
+			events = changes.ResultChan()
+		}
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// the API server closed the watch: reopened on the next resync
+				changes.Stop()
+				changes = nil
+				continue
+			}
+			if n, isNode := event.Object.(*v1.Node); isNode && n.Name == nodeName && event.Type != watch.Deleted && check(n) {
+				return nil
+			}
+		case <-ticker.C:
+			if changes == nil {
+				changes = w.watch(ctx, nodeName)
+			}
+			if n, err := w.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{}); err == nil && check(n) {
+				return nil
+			}
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "context cancelled while waiting for node bootstrap")
+		}
+	}
+}
+
+// watch opens a watch of the changes of the node; nil if the API server refuses it, the resync then checks the node
+func (w *bootstrapWaiter) watch(ctx context.Context, nodeName string) watch.Interface {
+	changes, err := w.client.CoreV1().Nodes().Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", nodeName).String(),
+	})
+	if err != nil {
+		return nil
+	}
+	return changes
+}
+
+// unmetRequirements returns the requirements the node does not meet
+func unmetRequirements(n *v1.Node, requirements []Requirement) []Requirement {
+	var unmet []Requirement
+	for _, r := range requirements {
+		if !r.Met(n) {
+			unmet = append(unmet, r)
+		}
+	}
+	return unmet
+}
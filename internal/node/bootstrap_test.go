@@ -0,0 +1,116 @@
+package node
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseRequirements(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []string
+		labels     []string
+		want       []Requirement
+		wantErr    bool
+	}{
+		{
+			name:       "conditions and labels",
+			conditions: []string{"Ready", "NetworkUnavailable=False"},
+			labels:     []string{"cni.example.com/ready", "network=calico"},
+			want: []Requirement{
+				{Condition: v1.NodeReady, Status: v1.ConditionTrue},
+				{Condition: v1.NodeNetworkUnavailable, Status: v1.ConditionFalse},
+				{Label: "cni.example.com/ready"},
+				{Label: "network", Value: "calico"},
+			},
+		},
+		{name: "nothing to wait for", want: []Requirement{}},
+		{name: "invalid status", conditions: []string{"Ready=yes"}, wantErr: true},
+		{name: "empty condition type", conditions: []string{"=True"}, wantErr: true},
+		{name: "empty label key", labels: []string{"=value"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRequirements(tt.conditions, tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRequirements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRequirements() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_bootstrapWaiter_Wait(t *testing.T) {
+	n := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+		Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}}},
+	}
+	client := fake.NewSimpleClientset(n)
+	requirements := []Requirement{{Condition: v1.NodeReady, Status: v1.ConditionTrue}, {Label: "cni.example.com/ready"}}
+
+	checks := 0
+	waiter := NewBootstrapWaiter(client, time.Millisecond, func(unmet []Requirement) {
+		checks++
+		if checks == 1 && len(unmet) != 2 {
+			t.Errorf("Wait() unmet = %v, want both requirements", unmet)
+		}
+		// the node becomes ready and labeled by the CNI
+		ready := n.DeepCopy()
+		ready.Labels = map[string]string{"cni.example.com/ready": "true"}
+		ready.Status.Conditions[0].Status = v1.ConditionTrue
+		if _, err := client.CoreV1().Nodes().Update(context.Background(), ready, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := waiter.Wait(ctx, "test-node", requirements); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if checks != 1 {
+		t.Errorf("Wait() checks with unmet requirements = %d, want 1", checks)
+	}
+
+	// the context ends the wait for a node never meeting the requirement
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := waiter.Wait(ctx, "test-node", []Requirement{{Label: "never"}}); err == nil {
+		t.Error("Wait() expected error once the context is done")
+	}
+}
+
+func Test_bootstrapWaiter_Wait_watch(t *testing.T) {
+	n := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}}
+	client := fake.NewSimpleClientset(n)
+	checked := make(chan struct{}, 1)
+	// a resync too long for the test: the change of the node ends the wait
+	waiter := NewBootstrapWaiter(client, time.Hour, func([]Requirement) {
+		select {
+		case checked <- struct{}{}:
+		default:
+		}
+	})
+	go func() {
+		<-checked
+		// the provider ID is set and the node becomes ready
+		ready := n.DeepCopy()
+		ready.Spec.ProviderID = "gce://project/zone/instance"
+		ready.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+		if _, err := client.CoreV1().Nodes().Update(context.Background(), ready, metav1.UpdateOptions{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := waiter.Wait(ctx, "test-node", []Requirement{{Condition: v1.NodeReady, Status: v1.ConditionTrue}}); err != nil {
+		t.Fatalf("Wait() error = %v, want the watched change to end the wait", err)
+	}
+}
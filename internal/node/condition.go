@@ -0,0 +1,95 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// NodeConditionType is the node condition true once the node holds its static public IP address
+	NodeConditionType     v1.NodeConditionType = "kubeip.com/StaticIPAssigned"
+	nodeConditionBlocked                       = "AssignmentBlocked"
+	nodeConditionAssigned                      = readinessGateAssigned
+	nodeConditionPending                       = readinessGateNotAssigned
+)
+
+type NodeCondition interface {
+	// Sync sets the condition of the node: true if the node holds the address, false if the address is empty
+	Sync(ctx context.Context, nodeName, address string) error
+	// Block sets the condition of the node false with the permanent error blocking the assignment
+	Block(ctx context.Context, nodeName string, err error) error
+}
+
+type nodeCondition struct {
+	client kubernetes.Interface
+}
+
+func NewNodeCondition(client kubernetes.Interface) NodeCondition {
+	return &nodeCondition{
+		client: client,
+	}
+}
+
+func (c *nodeCondition) Sync(ctx context.Context, nodeName, address string) error {
+	condition := v1.NodeCondition{
+		Type:    NodeConditionType,
+		Status:  v1.ConditionTrue,
+		Reason:  nodeConditionAssigned,
+		Message: fmt.Sprintf("node holds static public IP address %s", address),
+	}
+	if address == "" {
+		condition.Status, condition.Reason = v1.ConditionFalse, nodeConditionPending
+		condition.Message = "node holds no static public IP address"
+	}
+	return c.set(ctx, nodeName, condition)
+}
+
+func (c *nodeCondition) Block(ctx context.Context, nodeName string, err error) error {
+	return c.set(ctx, nodeName, v1.NodeCondition{
+		Type:    NodeConditionType,
+		Status:  v1.ConditionFalse,
+		Reason:  nodeConditionBlocked,
+		Message: fmt.Sprintf("static public IP address assignment blocked: %v", err),
+	})
+}
+
+func (c *nodeCondition) set(ctx context.Context, nodeName string, condition v1.NodeCondition) error {
+	n, err := c.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get node")
+	}
+	if !setNodeCondition(n, condition) {
+		return nil
+	}
+	if _, err = c.client.CoreV1().Nodes().UpdateStatus(ctx, n, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update node condition")
+	}
+	return nil
+}
+
+// setNodeCondition sets the condition of the node; it reports false if the node already has the condition status and
+// message; the transition time only changes with the status
+func setNodeCondition(n *v1.Node, condition v1.NodeCondition) bool {
+	now := metav1.Now()
+	condition.LastHeartbeatTime, condition.LastTransitionTime = now, now
+	for i, existing := range n.Status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Message == condition.Message {
+			return false
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		n.Status.Conditions[i] = condition
+		return true
+	}
+	n.Status.Conditions = append(n.Status.Conditions, condition)
+	return true
+}
@@ -0,0 +1,53 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_nodeCondition(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}},
+	})
+	c := NewNodeCondition(client)
+	condition := func() *v1.NodeCondition {
+		n, err := client.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Len(t, n.Status.Conditions, 2, "the other conditions are kept")
+		return &n.Status.Conditions[1]
+	}
+
+	require.NoError(t, c.Sync(context.Background(), "node-1", ""))
+	assert.Equal(t, v1.ConditionFalse, condition().Status)
+	assert.Equal(t, nodeConditionPending, condition().Reason)
+
+	require.NoError(t, c.Sync(context.Background(), "node-1", "203.0.113.1"))
+	assert.Equal(t, v1.ConditionTrue, condition().Status)
+	assert.Equal(t, "node holds static public IP address 203.0.113.1", condition().Message)
+
+	require.NoError(t, c.Block(context.Background(), "node-1", errors.New("permission denied")))
+	assert.Equal(t, v1.ConditionFalse, condition().Status)
+	assert.Equal(t, nodeConditionBlocked, condition().Reason)
+
+	assert.Error(t, c.Sync(context.Background(), "missing-node", ""))
+}
+
+func Test_setNodeCondition(t *testing.T) {
+	n := &v1.Node{}
+	condition := v1.NodeCondition{Type: NodeConditionType, Status: v1.ConditionFalse, Message: "pending"}
+	assert.True(t, setNodeCondition(n, condition))
+	assert.False(t, setNodeCondition(n, condition), "unchanged condition")
+	transition := n.Status.Conditions[0].LastTransitionTime
+	condition.Message = "blocked"
+	assert.True(t, setNodeCondition(n, condition))
+	assert.Equal(t, transition, n.Status.Conditions[0].LastTransitionTime, "same status keeps the transition time")
+	assert.Len(t, n.Status.Conditions, 1)
+}
@@ -0,0 +1,57 @@
+package node
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DrainTaints are the taints the common termination handlers put on a node before draining it: AWS Node Termination
+// Handler, Karpenter disruption, cluster-autoscaler scale down and GKE node termination handler
+var DrainTaints = []string{
+	"aws-node-termination-handler/spot-itn",
+	"aws-node-termination-handler/scheduled-maintenance",
+	"aws-node-termination-handler/asg-lifecycle-termination",
+	"aws-node-termination-handler/rebalance-recommendation",
+	"karpenter.sh/disrupted",
+	"ToBeDeletedByClusterAutoscaler",
+	"cloud.google.com/impending-node-termination",
+}
+
+// DrainDetector detects the pre-drain phase of the node
+type DrainDetector interface {
+	// Draining returns the taint key announcing the drain of the node; empty if the node is not about to be drained
+	Draining(ctx context.Context, nodeName string) (string, error)
+}
+
+type drainDetector struct {
+	client kubernetes.Interface
+	taints map[string]bool
+}
+
+// NewDrainDetector returns a detector of the drain taints and of the additional taint keys
+func NewDrainDetector(client kubernetes.Interface, taintKeys []string) DrainDetector {
+	taints := make(map[string]bool, len(DrainTaints)+len(taintKeys))
+	for _, key := range append(append([]string{}, DrainTaints...), taintKeys...) {
+		taints[key] = true
+	}
+	return &drainDetector{
+		client: client,
+		taints: taints,
+	}
+}
+
+func (d *drainDetector) Draining(ctx context.Context, nodeName string) (string, error) {
+	n, err := d.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get kubernetes node")
+	}
+	for _, taint := range n.Spec.Taints {
+		if d.taints[taint.Key] {
+			return taint.Key, nil
+		}
+	}
+	return "", nil
+}
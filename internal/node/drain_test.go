@@ -0,0 +1,40 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_drainDetector_Draining(t *testing.T) {
+	node := func(name string, taints ...string) *v1.Node {
+		n := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		for _, key := range taints {
+			n.Spec.Taints = append(n.Spec.Taints, v1.Taint{Key: key, Effect: v1.TaintEffectNoSchedule})
+		}
+		return n
+	}
+	client := fake.NewSimpleClientset(
+		node("spot", "kubeip.com/not-ready", "aws-node-termination-handler/spot-itn"),
+		node("custom", "example.com/draining"),
+		node("running", "kubeip.com/not-ready"),
+	)
+	d := NewDrainDetector(client, []string{"example.com/draining"})
+
+	for name, want := range map[string]string{
+		"spot":    "aws-node-termination-handler/spot-itn",
+		"custom":  "example.com/draining",
+		"running": "",
+	} {
+		got, err := d.Draining(context.Background(), name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+	_, err := d.Draining(context.Background(), "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typesv1 "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// EgressGatewayLabel marks the node holding a static public IP address as egress gateway
+	// (Cilium egress gateway policy or Calico egress gateway node selector)
+	EgressGatewayLabel = "kubeip.com/egress-gateway"
+	// EgressIPLabel is the static public IP address of the egress gateway node
+	EgressIPLabel = "kubeip.com/egress-ip"
+)
+
+type EgressLabeler interface {
+	// Label marks the node as egress gateway holding the address
+	Label(ctx context.Context, nodeName, address string) error
+	// Unlabel removes the egress gateway labels from the node
+	Unlabel(ctx context.Context, nodeName string) error
+}
+
+type egressLabeler struct {
+	client kubernetes.Interface
+}
+
+func NewEgressLabeler(client kubernetes.Interface) EgressLabeler {
+	return &egressLabeler{
+		client: client,
+	}
+}
+
+// EgressIPLabelValue returns the address as label value: IPv6 colons are not allowed and replaced with dashes
+func EgressIPLabelValue(address string) string {
+	return strings.ReplaceAll(address, ":", "-")
+}
+
+func (l *egressLabeler) patchLabels(ctx context.Context, nodeName string, labels map[string]*string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": labels,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal labels patch")
+	}
+	_, err = l.client.CoreV1().Nodes().Patch(ctx, nodeName, typesv1.MergePatchType, data, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to patch node labels")
+	}
+	return nil
+}
+
+func (l *egressLabeler) Label(ctx context.Context, nodeName, address string) error {
+	gateway := "true"
+	ip := EgressIPLabelValue(address)
+	return l.patchLabels(ctx, nodeName, map[string]*string{
+		EgressGatewayLabel: &gateway,
+		EgressIPLabel:      &ip,
+	})
+}
+
+func (l *egressLabeler) Unlabel(ctx context.Context, nodeName string) error {
+	// null values remove the labels in merge patch
+	return l.patchLabels(ctx, nodeName, map[string]*string{
+		EgressGatewayLabel: nil,
+		EgressIPLabel:      nil,
+	})
+}
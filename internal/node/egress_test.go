@@ -0,0 +1,34 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEgressIPLabelValue(t *testing.T) {
+	assert.Equal(t, "1.1.1.1", EgressIPLabelValue("1.1.1.1"))
+	assert.Equal(t, "2600-1900--1", EgressIPLabelValue("2600:1900::1"))
+}
+
+func Test_egressLabeler(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node", Labels: map[string]string{"other": "value"}},
+	})
+	l := NewEgressLabeler(client)
+
+	require.NoError(t, l.Label(context.Background(), "test-node", "1.1.1.1"))
+	n, err := client.CoreV1().Nodes().Get(context.Background(), "test-node", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"other": "value", EgressGatewayLabel: "true", EgressIPLabel: "1.1.1.1"}, n.Labels)
+
+	require.NoError(t, l.Unlabel(context.Background(), "test-node"))
+	n, err = client.CoreV1().Nodes().Get(context.Background(), "test-node", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"other": "value"}, n.Labels)
+}
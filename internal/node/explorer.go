@@ -2,10 +2,12 @@ package node
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"os"
 	"strings"
 
+	"github.com/doitintl/kubeip/internal/providerid"
 	"github.com/doitintl/kubeip/internal/types"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
@@ -14,70 +16,124 @@ import (
 )
 
 const (
-	minProviderIDTokens = 2
-	podInfoDir          = "/etc/podinfo/"
-	awsPoolLabel        = "eks.amazonaws.com/nodegroup"
-	azurePoolLabel      = "node.kubernetes.io/instancegroup"
-	gcpPoolLabel        = "cloud.google.com/gke-nodepool"
-	ociPoolAnnotation   = "oci.oraclecloud.com/node-pool-id"
-	regionLabel         = "topology.kubernetes.io/region"
-	zoneLabel           = "topology.kubernetes.io/zone"
+	podInfoDir        = "/etc/podinfo/"
+	awsPoolLabel      = "eks.amazonaws.com/nodegroup"
+	azurePoolLabel    = "node.kubernetes.io/instancegroup"
+	gcpPoolLabel      = "cloud.google.com/gke-nodepool"
+	ociPoolAnnotation = "oci.oraclecloud.com/node-pool-id"
+	regionLabel       = "topology.kubernetes.io/region"
+	zoneLabel         = "topology.kubernetes.io/zone"
+	// bareMetalPoolLabel is the optional node pool label of bare metal nodes
+	bareMetalPoolLabel = "kubeip.com/pool"
+	// InstanceIDAnnotation maps a node without provider ID (custom kubelet bootstrap) to its cloud instance
+	InstanceIDAnnotation = "kubeip.com/instance-id"
+	awsInstancePrefix    = "i-"
 )
 
+// UnsupportedProviderError is returned for a node whose provider ID is not one of a supported cloud provider (e.g. kind,
+// k3s on bare VMs): the agent cannot assign it a static public IP address outside the MetalLB mode
+type UnsupportedProviderError struct {
+	Node       string
+	ProviderID string
+}
+
+func (e *UnsupportedProviderError) Error() string {
+	return fmt.Sprintf("unsupported provider ID %q of node %s: supported cloud providers are aws, azure, gce and oci", e.ProviderID, e.Node)
+}
+
 type Explorer interface {
 	GetNode(ctx context.Context, nodeName string) (*types.Node, error)
 }
 
 type explorer struct {
-	client kubernetes.Interface
+	client    kubernetes.Interface
+	bareMetal types.CloudProvider
+	// instanceID is the instance of the nodes without provider ID, unless annotated
+	instanceID string
 }
 
 func getNodeName(file string) (string, error) {
 	// get node name from file
-	nodeName, err := os.ReadFile(file)
+	data, err := os.ReadFile(file)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to read %s", file)
 	}
-	return string(nodeName), nil
+	// downward API volume files may end with a newline
+	nodeName := strings.TrimSpace(string(data))
+	if nodeName == "" {
+		return "", errors.Errorf("empty node name in %s", file)
+	}
+	return nodeName, nil
 }
 
-func NewExplorer(client kubernetes.Interface) Explorer {
+// NewExplorer returns a node explorer; if bareMetal is not empty, nodes without a cloud provider ID are bare metal nodes
+// of this provider (MetalLB mode): the node name is the instance and the region, zone and pool labels are optional;
+// nodes with a cloud provider ID keep their cloud provider, except in develop mode (fake provider) where all nodes are
+// simulated; instanceID maps the nodes without provider ID to their cloud instance, see InstanceProviderID
+func NewExplorer(client kubernetes.Interface, bareMetal types.CloudProvider, instanceID string) Explorer {
 	return &explorer{
-		client: client,
+		client:     client,
+		bareMetal:  bareMetal,
+		instanceID: instanceID,
 	}
 }
 
-func getCloudProvider(providerID string) (types.CloudProvider, error) {
-	if strings.HasPrefix(providerID, "aws://") {
-		return types.CloudProviderAWS, nil
+// InstanceProviderID returns the provider ID of an explicit instance mapping: a provider ID in the kubelet format
+// (aws:///<zone>/<instance>, gce://<project>/<zone>/<instance>, azure://<resource ID>, ocid1.instance...), or a bare AWS
+// instance ID (i-...); the region, zone and pool of the node still come from its labels
+func InstanceProviderID(instanceID string) (string, error) {
+	instanceID = strings.TrimSpace(instanceID)
+	if strings.HasPrefix(instanceID, awsInstancePrefix) {
+		return "aws:///" + instanceID, nil
+	}
+	if _, _, _, err := ParseProviderID(instanceID); err != nil {
+		return "", errors.Wrapf(err, "invalid instance ID %q", instanceID)
 	}
-	if strings.HasPrefix(providerID, "azure://") {
-		return types.CloudProviderAzure, nil
+	return instanceID, nil
+}
+
+// providerID returns the provider ID of the node: set by the kubelet, or mapped by the kubeip.com/instance-id annotation
+// of the node or the instance ID of the explorer, in this order
+func (d *explorer) providerID(n *v1.Node) (string, error) {
+	if n.Spec.ProviderID != "" {
+		return n.Spec.ProviderID, nil
 	}
-	if strings.HasPrefix(providerID, "gce://") {
-		return types.CloudProviderGCP, nil
+	instanceID := n.Annotations[InstanceIDAnnotation]
+	if instanceID == "" {
+		instanceID = d.instanceID
 	}
-	if strings.HasPrefix(providerID, "oci") {
-		return types.CloudProviderOCI, nil
+	if instanceID == "" {
+		return "", nil
 	}
-	return "", errors.Errorf("unsupported provider ID: %s", providerID)
+	return InstanceProviderID(instanceID)
 }
 
-func getInstance(providerID string) (string, error) {
-	if providerID == "" {
-		return "", errors.Errorf("failed to get instance ID, provider ID is empty")
+// ParseProviderID returns the cloud provider, the instance and, for GCE, the zone of a provider ID, e.g. of an instance
+// launched before its node registers
+func ParseProviderID(providerID string) (types.CloudProvider, string, string, error) {
+	p, err := parseSupported(providerID)
+	if err != nil {
+		return "", "", "", err
 	}
-
-	// In case of OCI, the provider ID is the instance ID
-	if strings.HasPrefix(providerID, "oci") {
-		return providerID, nil
+	var zone string
+	if p.Cloud == types.CloudProviderGCP {
+		zone = p.Zone
 	}
+	return p.Cloud, p.Instance, zone, nil
+}
 
-	s := strings.Split(providerID, "/")
-	if len(s) < minProviderIDTokens {
-		return "", errors.Errorf("failed to get instance ID")
+// parseSupported parses the provider ID of an instance of a cloud provider with an assigner
+func parseSupported(providerID string) (*providerid.ProviderID, error) {
+	p, err := providerid.Parse(providerID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse provider ID")
+	}
+	switch p.Cloud {
+	case types.CloudProviderAWS, types.CloudProviderAzure, types.CloudProviderGCP, types.CloudProviderOCI:
+		return p, nil
+	default:
+		return nil, errors.Errorf("unsupported cloud provider %s of provider ID %s", p.Cloud, providerID)
 	}
-	return s[len(s)-1], nil
 }
 
 func getNodePool(providerID types.CloudProvider, node *v1.Node) (string, error) {
@@ -146,50 +202,65 @@ func (d *explorer) GetNode(ctx context.Context, nodeName string) (*types.Node, e
 		return nil, errors.Wrap(err, "failed to get kubernetes node")
 	}
 
-	// get cloud provider from node spec
-	cloudProvider, err := getCloudProvider(n.Spec.ProviderID)
+	node := &types.Node{
+		Name:        nodeName,
+		Labels:      n.Labels,
+		Annotations: n.Annotations,
+	}
+	providerID, err := d.providerID(n)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get cloud provider")
+		return nil, errors.Wrapf(err, "failed to map node %s to its instance", nodeName)
+	}
+	if d.bareMetal != "" && (providerID == "" || d.bareMetal == types.CloudProviderFake) {
+		node.Cloud, node.Instance = d.bareMetal, nodeName
+		node.Region, node.Zone, node.Pool = n.Labels[regionLabel], n.Labels[zoneLabel], n.Labels[bareMetalPoolLabel]
+	} else if err = setCloudNode(node, n, providerID); err != nil {
+		return nil, err
 	}
 
-	// get instance ID from provider ID
-	instance, err := getInstance(n.Spec.ProviderID)
+	// get node addresses
+	node.ExternalIPs, node.InternalIPs, err = getAddresses(n.Status.Addresses)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get instance ID")
+		return nil, errors.Wrap(err, "failed to get node addresses")
 	}
+	return node, nil
+}
 
-	// get node region from node labels
-	region, ok := n.Labels[regionLabel]
-	if !ok {
-		return nil, errors.Errorf("failed to get node region")
+// setCloudNode sets the cloud provider, instance, region, zone and pool of a cloud node
+func setCloudNode(node *types.Node, n *v1.Node, providerID string) error {
+	// get cloud provider and instance from provider ID; the GCE provider ID also has the project and the zone of the
+	// instance, the Azure instance is the virtual machine resource ID
+	p, err := parseSupported(providerID)
+	if err != nil {
+		var invalid *providerid.InvalidFormatError
+		if errors.As(err, &invalid) {
+			return errors.Wrap(err, "failed to get instance ID")
+		}
+		return &UnsupportedProviderError{Node: n.Name, ProviderID: providerID}
+	}
+	node.Cloud, node.Instance = p.Cloud, p.Instance
+	if p.Cloud == types.CloudProviderGCP {
+		node.Project, node.Zone = p.Project, p.Zone
 	}
 
-	// get node zone from node labels
-	zone, ok := n.Labels[zoneLabel]
+	// get node region from node labels
+	var ok bool
+	node.Region, ok = n.Labels[regionLabel]
 	if !ok {
-		return nil, errors.Errorf("failed to get node zone")
+		return errors.Errorf("failed to get node region")
 	}
 
-	// get node pool from node
-	pool, err := getNodePool(cloudProvider, n)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get node pool")
+	// get node zone from node labels, unless known from the provider ID
+	if node.Zone == "" {
+		if node.Zone, ok = n.Labels[zoneLabel]; !ok {
+			return errors.Errorf("failed to get node zone")
+		}
 	}
 
-	// get node addresses
-	externalIPs, internalIPs, err := getAddresses(n.Status.Addresses)
+	// get node pool from node
+	node.Pool, err = getNodePool(node.Cloud, n)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get node addresses")
+		return errors.Wrap(err, "failed to get node pool")
 	}
-
-	return &types.Node{
-		Name:        nodeName,
-		Instance:    instance,
-		Cloud:       cloudProvider,
-		Region:      region,
-		Zone:        zone,
-		Pool:        pool,
-		ExternalIPs: externalIPs,
-		InternalIPs: internalIPs,
-	}, nil
+	return nil
 }
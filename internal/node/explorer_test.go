@@ -58,6 +58,48 @@ func Test_getNodeName(t *testing.T) {
 			},
 			want: "test-node",
 		},
+		{
+			name:     "trim trailing newline from node name file",
+			nodeName: "test-node\n",
+			tearUp: func(name string) (*os.File, error) {
+				tmpfile, err := os.CreateTemp("", "nodeName")
+				if err != nil {
+					return nil, err
+				}
+				if _, err = tmpfile.Write([]byte(name)); err != nil {
+					return nil, err
+				}
+				if err = tmpfile.Close(); err != nil {
+					return nil, err
+				}
+				return tmpfile, nil
+			},
+			tearDown: func(file string) error {
+				return os.Remove(file)
+			},
+			want: "test-node",
+		},
+		{
+			name:     "empty node name file",
+			nodeName: "",
+			tearUp: func(name string) (*os.File, error) {
+				tmpfile, err := os.CreateTemp("", "nodeName")
+				if err != nil {
+					return nil, err
+				}
+				if _, err = tmpfile.Write([]byte(name)); err != nil {
+					return nil, err
+				}
+				if err = tmpfile.Close(); err != nil {
+					return nil, err
+				}
+				return tmpfile, nil
+			},
+			tearDown: func(file string) error {
+				return os.Remove(file)
+			},
+			wantErr: true,
+		},
 		{
 			name:    "no such file or directory",
 			wantErr: true,
@@ -93,66 +135,6 @@ func Test_getNodeName(t *testing.T) {
 	}
 }
 
-func Test_getCloudProvider(t *testing.T) {
-	type args struct {
-		providerID string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    types.CloudProvider
-		wantErr error
-	}{
-		{
-			name: "aws",
-			args: args{
-				providerID: "aws:///us-west-2b/i-06d71a5ffc05cc325",
-			},
-			want: types.CloudProviderAWS,
-		},
-		{
-			name: "azure",
-			args: args{
-				providerID: "azure:///subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/aks-agentpool-12345678-vmss_0",
-			},
-			want: types.CloudProviderAzure,
-		},
-		{
-			name: "gcp",
-			args: args{
-				providerID: "gce:///projects/123456789012/zones/us-west1-b/instances/gke-cluster-1-default-pool-12345678-0v0v",
-			},
-			want: types.CloudProviderGCP,
-		},
-		{
-			name: "oci",
-			args: args{
-				providerID: "ocid1.instance.oc1.ap-mumbai-1.anrg6ljrdgsxvfacnncnwaxaasbdnjdgwuejhkbdfejkenoernoered",
-			},
-			want: types.CloudProviderOCI,
-		},
-		{
-			name: "unsupported",
-			args: args{
-				providerID: "unsupported",
-			},
-			wantErr: errors.New("unsupported provider ID: unsupported"),
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := getCloudProvider(tt.args.providerID)
-			if !matchErr(err, tt.wantErr) {
-				t.Errorf("getCloudProvider() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("getCloudProvider() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func Test_getNodePool(t *testing.T) {
 	type args struct {
 		providerID types.CloudProvider
@@ -443,6 +425,12 @@ func Test_explorer_GetNode(t *testing.T) {
 				InternalIPs: []net.IP{
 					net.ParseIP("10.10.0.1"),
 				},
+				Labels: map[string]string{
+					"eks.amazonaws.com/nodegroup":   "test-node-pool",
+					"beta.kubernetes.io/os":         "linux",
+					"topology.kubernetes.io/region": "us-west-2",
+					"topology.kubernetes.io/zone":   "us-west-2b",
+				},
 			},
 		},
 		{
@@ -486,6 +474,46 @@ func Test_explorer_GetNode(t *testing.T) {
 				InternalIPs: []net.IP{
 					net.ParseIP("10.10.0.1"),
 				},
+				Labels: map[string]string{
+					"topology.kubernetes.io/region": "us-west-2",
+					"topology.kubernetes.io/zone":   "us-west-2b",
+				},
+				Annotations: map[string]string{
+					"oci.oraclecloud.com/node-pool-id": "ocid1.nodepool.oc1.ap-mumbai-1.test",
+				},
+			},
+		},
+		{
+			name: "get node of a managed instance group with a custom hostname",
+			fields: fields{
+				client: fake.NewSimpleClientset(&v1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "web-1.internal.example.com",
+						Labels: map[string]string{
+							"cloud.google.com/gke-nodepool": "web",
+							"topology.kubernetes.io/region": "us-central1",
+						},
+					},
+					Spec: v1.NodeSpec{
+						ProviderID: "gce://test-project/us-central1-b/web-mig-7x2k",
+					},
+				}),
+			},
+			args: args{
+				nodeName: "web-1.internal.example.com",
+			},
+			want: &types.Node{
+				Name:     "web-1.internal.example.com",
+				Instance: "web-mig-7x2k",
+				Project:  "test-project",
+				Cloud:    types.CloudProviderGCP,
+				Pool:     "web",
+				Region:   "us-central1",
+				Zone:     "us-central1-b",
+				Labels: map[string]string{
+					"cloud.google.com/gke-nodepool": "web",
+					"topology.kubernetes.io/region": "us-central1",
+				},
 			},
 		},
 		{
@@ -500,7 +528,7 @@ func Test_explorer_GetNode(t *testing.T) {
 			args: args{
 				nodeName: "test-node",
 			},
-			wantErr: errors.New("failed to get cloud provider: unsupported provider ID: "),
+			wantErr: errors.New(`unsupported provider ID "" of node test-node: supported cloud providers are aws, azure, gce and oci`),
 		},
 		{
 			name: "failed to get region",
@@ -620,68 +648,163 @@ func Test_explorer_GetNode(t *testing.T) {
 	}
 }
 
-func Test_getInstance(t *testing.T) {
-	type args struct {
-		providerID string
+func Test_explorer_GetNode_bareMetal(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cloud-1",
+			Labels: map[string]string{
+				"topology.kubernetes.io/region": "us-central1",
+				"topology.kubernetes.io/zone":   "us-central1-a",
+				"cloud.google.com/gke-nodepool": "default-pool",
+			},
+		},
+		Spec: v1.NodeSpec{ProviderID: "gce://project/us-central1-a/gke-1"},
+	}, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "metal-1",
+			Labels: map[string]string{
+				"kubernetes.io/hostname": "metal-1",
+				"kubeip.com/pool":        "edge",
+			},
+		},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "192.168.1.10"},
+			},
+		},
+	})
+	want := &types.Node{
+		Name:        "metal-1",
+		Instance:    "metal-1",
+		Cloud:       types.CloudProviderMetalLB,
+		Pool:        "edge",
+		InternalIPs: []net.IP{net.ParseIP("192.168.1.10")},
+		Labels: map[string]string{
+			"kubernetes.io/hostname": "metal-1",
+			"kubeip.com/pool":        "edge",
+		},
+	}
+	explorer := NewExplorer(client, types.CloudProviderMetalLB, "")
+	got, err := explorer.GetNode(context.Background(), "metal-1")
+	if err != nil {
+		t.Fatalf("GetNode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetNode() got = %v, want %v", got, want)
+	}
+
+	// cloud nodes keep their cloud provider in MetalLB mode
+	got, err = explorer.GetNode(context.Background(), "cloud-1")
+	if err != nil {
+		t.Fatalf("GetNode() error = %v", err)
+	}
+	if got.Cloud != types.CloudProviderGCP || got.Instance != "gke-1" || got.Pool != "default-pool" {
+		t.Errorf("GetNode() got = %v, want GCP instance gke-1 in default-pool", got)
+	}
+
+	// nodes without cloud provider ID are not bare metal nodes out of MetalLB mode
+	if _, err = NewExplorer(client, "", "").GetNode(context.Background(), "metal-1"); err == nil {
+		t.Errorf("GetNode() of node without provider ID out of MetalLB mode, want error")
+	}
+
+	// all nodes are simulated in develop mode
+	got, err = NewExplorer(client, types.CloudProviderFake, "").GetNode(context.Background(), "cloud-1")
+	if err != nil {
+		t.Fatalf("GetNode() error = %v", err)
+	}
+	if got.Cloud != types.CloudProviderFake || got.Instance != "cloud-1" || got.Region != "us-central1" {
+		t.Errorf("GetNode() got = %v, want simulated instance cloud-1 in us-central1", got)
+	}
+}
+
+func Test_explorer_GetNode_instanceID(t *testing.T) {
+	labels := map[string]string{
+		"topology.kubernetes.io/region": "us-east-1",
+		"topology.kubernetes.io/zone":   "us-east-1a",
+		"eks.amazonaws.com/nodegroup":   "workers",
+	}
+	client := fake.NewSimpleClientset(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-1", Labels: labels},
+	}, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "custom-2",
+			Labels:      labels,
+			Annotations: map[string]string{"kubeip.com/instance-id": "aws:///us-east-1a/i-annotated"},
+		},
+	}, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-3", Labels: labels, Annotations: map[string]string{"kubeip.com/instance-id": "vm-1"}},
+	})
+	explorer := NewExplorer(client, "", "i-flag")
+
+	got, err := explorer.GetNode(context.Background(), "custom-1")
+	if err != nil {
+		t.Fatalf("GetNode() error = %v", err)
+	}
+	if got.Cloud != types.CloudProviderAWS || got.Instance != "i-flag" || got.Pool != "workers" {
+		t.Errorf("GetNode() got = %v, want AWS instance i-flag in workers", got)
+	}
+
+	// the annotation takes precedence over the flag
+	got, err = explorer.GetNode(context.Background(), "custom-2")
+	if err != nil {
+		t.Fatalf("GetNode() error = %v", err)
+	}
+	if got.Instance != "i-annotated" {
+		t.Errorf("GetNode() instance = %v, want i-annotated", got.Instance)
+	}
+
+	if _, err = explorer.GetNode(context.Background(), "custom-3"); err == nil {
+		t.Errorf("GetNode() with invalid instance ID annotation, want error")
 	}
+}
+
+func TestInstanceProviderID(t *testing.T) {
 	tests := []struct {
-		name    string
-		args    args
-		want    string
-		wantErr error
+		instanceID string
+		want       string
+		wantErr    bool
 	}{
-		{
-			name: "empty provider ID",
-			args: args{
-				providerID: "",
-			},
-			wantErr: errors.New("failed to get instance ID, provider ID is empty"),
-		},
-		{
-			name: "oci",
-			args: args{
-				providerID: "ocid1.instance.oc1.ap-mumbai-1.anrg6ljrdgsxvfacnncnwaxaasbdnjdgwuejhkbdfejkenoernoered",
-			},
-			want: "ocid1.instance.oc1.ap-mumbai-1.anrg6ljrdgsxvfacnncnwaxaasbdnjdgwuejhkbdfejkenoernoered",
-		},
-		{
-			name: "aws",
-			args: args{
-				providerID: "aws:///us-west-2b/i-06d71a5ffc05cc325",
-			},
-			want: "i-06d71a5ffc05cc325",
-		},
-		{
-			name: "azure",
-			args: args{
-				providerID: "azure:///subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/aks-agentpool-12345678-vmss_0",
-			},
-			want: "aks-agentpool-12345678-vmss_0",
-		},
-		{
-			name: "gcp",
-			args: args{
-				providerID: "gce:///projects/123456789012/zones/us-west1-b/instances/gke-cluster-1-default-pool-12345678-0v0v",
-			},
-			want: "gke-cluster-1-default-pool-12345678-0v0v",
-		},
-		{
-			name: "unsupported",
-			args: args{
-				providerID: "unsupported",
-			},
-			wantErr: errors.New("failed to get instance ID"),
-		},
+		{instanceID: "i-0123456789abcdef0", want: "aws:///i-0123456789abcdef0"},
+		{instanceID: "aws:///us-east-1a/i-0123456789abcdef0", want: "aws:///us-east-1a/i-0123456789abcdef0"},
+		{instanceID: "gce://project/us-central1-a/vm-1", want: "gce://project/us-central1-a/vm-1"},
+		{instanceID: "ocid1.instance.oc1.iad.abc", want: "ocid1.instance.oc1.iad.abc"},
+		{instanceID: "gce://project/vm-1", wantErr: true},
+		{instanceID: "vm-1", wantErr: true},
 	}
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := getInstance(tt.args.providerID)
-			if !matchErr(err, tt.wantErr) {
-				t.Errorf("getInstance() error = %v, wantErr %v", err, tt.wantErr)
-				return
+		t.Run(tt.instanceID, func(t *testing.T) {
+			got, err := InstanceProviderID(tt.instanceID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InstanceProviderID() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if got != tt.want {
-				t.Errorf("getInstance() got = %v, want %v", got, tt.want)
+				t.Errorf("InstanceProviderID() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProviderID(t *testing.T) {
+	tests := []struct {
+		providerID   string
+		wantCloud    types.CloudProvider
+		wantInstance string
+		wantZone     string
+		wantErr      bool
+	}{
+		{providerID: "aws:///us-west-2a/i-0123456789abcdef0", wantCloud: types.CloudProviderAWS, wantInstance: "i-0123456789abcdef0"},
+		{providerID: "gce://test-project/us-central1-a/test-instance", wantCloud: types.CloudProviderGCP, wantInstance: "test-instance", wantZone: "us-central1-a"},
+		{providerID: "gce://test-project/test-instance", wantErr: true},
+		{providerID: "kind://docker/kind/kind-worker", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.providerID, func(t *testing.T) {
+			cloud, instance, zone, err := ParseProviderID(tt.providerID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProviderID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cloud != tt.wantCloud || instance != tt.wantInstance || zone != tt.wantZone {
+				t.Errorf("ParseProviderID() = %v, %v, %v, want %v, %v, %v", cloud, instance, zone, tt.wantCloud, tt.wantInstance, tt.wantZone)
 			}
 		})
 	}
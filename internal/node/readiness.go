@@ -0,0 +1,154 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// ReadinessGateConditionType is the pod readiness gate true once the node of the pod holds its static public IP address
+	ReadinessGateConditionType v1.PodConditionType = "kubeip.com/static-ip"
+	readinessGateAssigned                          = "StaticIPAssigned"
+	readinessGateNotAssigned                       = "StaticIPNotAssigned"
+	// ReadinessGateLabel opts a pod in the readiness gate: the pods labeled true have to declare it, KubeIP does not
+	// inject it
+	ReadinessGateLabel = "kubeip.com/static-ip"
+)
+
+type ReadinessGate interface {
+	// Sync sets the readiness gate condition of the pods of the node declaring it: true if the node holds the address,
+	// false if the address is empty
+	Sync(ctx context.Context, nodeName, address string) error
+	// Watch sets the readiness gate condition of the pods of the node declaring it as they are scheduled and updated,
+	// with the address the node holds, until the context is done; the failed updates and the pods labeled with
+	// ReadinessGateLabel not declaring the gate are reported to onError
+	Watch(ctx context.Context, nodeName string, address func() string, onError func(error)) error
+}
+
+type readinessGate struct {
+	client kubernetes.Interface
+}
+
+func NewReadinessGate(client kubernetes.Interface) ReadinessGate {
+	return &readinessGate{
+		client: client,
+	}
+}
+
+// hasReadinessGate reports if the pod declares the readiness gate
+func hasReadinessGate(pod *v1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == ReadinessGateConditionType {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *readinessGate) Sync(ctx context.Context, nodeName, address string) error {
+	pods, err := g.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list pods of the node")
+	}
+	condition := readinessCondition(nodeName, address)
+	var failed []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != nodeName || !hasReadinessGate(pod) || !setPodCondition(pod, condition) {
+			continue
+		}
+		if _, err = g.client.CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+			failed = append(failed, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to update the readiness gate of pods %v", failed)
+	}
+	return nil
+}
+
+// Watch runs an informer of the pods filtered on spec.nodeName: one watch of the pods of the node instead of listing them
+// periodically
+func (g *readinessGate) Watch(ctx context.Context, nodeName string, address func() string, onError func(error)) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(g.client, 0, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+		o.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+	}))
+	informer := factory.Core().V1().Pods().Informer()
+	sync := func(obj interface{}) {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || pod.Spec.NodeName != nodeName || !hasReadinessGate(pod) {
+			return
+		}
+		// the pods of the cache are shared: the condition is set on a copy
+		pod = pod.DeepCopy()
+		if !setPodCondition(pod, readinessCondition(nodeName, address())) {
+			return
+		}
+		if _, err := g.client.CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+			onError(errors.Wrapf(err, "failed to update the readiness gate of pod %s/%s", pod.Namespace, pod.Name))
+		}
+	}
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*v1.Pod); ok && pod.Spec.NodeName == nodeName && pod.Labels[ReadinessGateLabel] == "true" && !hasReadinessGate(pod) {
+				onError(errors.Errorf("pod %s/%s labeled %s=true does not declare the %s readiness gate",
+					pod.Namespace, pod.Name, ReadinessGateLabel, ReadinessGateConditionType))
+			}
+			sync(obj)
+		},
+		UpdateFunc: func(_, obj interface{}) { sync(obj) },
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to watch the pods of the node")
+	}
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	<-ctx.Done()
+	return nil
+}
+
+// readinessCondition returns the readiness gate condition of the pods of the node: true if the node holds the address,
+// false if the address is empty
+func readinessCondition(nodeName, address string) v1.PodCondition {
+	if address == "" {
+		return v1.PodCondition{
+			Type:    ReadinessGateConditionType,
+			Status:  v1.ConditionFalse,
+			Reason:  readinessGateNotAssigned,
+			Message: fmt.Sprintf("node %s holds no static public IP address", nodeName),
+		}
+	}
+	return v1.PodCondition{
+		Type:    ReadinessGateConditionType,
+		Status:  v1.ConditionTrue,
+		Reason:  readinessGateAssigned,
+		Message: fmt.Sprintf("node %s holds static public IP address %s", nodeName, address),
+	}
+}
+
+// setPodCondition sets the condition of the pod; it reports false if the pod already has the condition status and message
+func setPodCondition(pod *v1.Pod, condition v1.PodCondition) bool {
+	condition.LastTransitionTime = metav1.Now()
+	for i, existing := range pod.Status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Message == condition.Message {
+			return false
+		}
+		pod.Status.Conditions[i] = condition
+		return true
+	}
+	pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	return true
+}
@@ -0,0 +1,108 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testPod(name, nodeName string, gated bool) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+	if gated {
+		pod.Spec.ReadinessGates = []v1.PodReadinessGate{{ConditionType: ReadinessGateConditionType}}
+	}
+	return pod
+}
+
+func Test_readinessGate_Sync(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		testPod("gated", "node-1", true),
+		testPod("not-gated", "node-1", false),
+		testPod("other-node", "node-2", true),
+	)
+	g := NewReadinessGate(client)
+	condition := func(name string) *v1.PodCondition {
+		pod, err := client.CoreV1().Pods("default").Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		for i := range pod.Status.Conditions {
+			if pod.Status.Conditions[i].Type == ReadinessGateConditionType {
+				return &pod.Status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	require.NoError(t, g.Sync(context.Background(), "node-1", "203.0.113.1"))
+	require.NotNil(t, condition("gated"))
+	assert.Equal(t, v1.ConditionTrue, condition("gated").Status)
+	assert.Equal(t, "node node-1 holds static public IP address 203.0.113.1", condition("gated").Message)
+	assert.Nil(t, condition("not-gated"))
+	assert.Nil(t, condition("other-node"))
+
+	require.NoError(t, g.Sync(context.Background(), "node-1", ""))
+	assert.Equal(t, v1.ConditionFalse, condition("gated").Status)
+}
+
+func Test_readinessGate_Watch(t *testing.T) {
+	labeled := testPod("labeled", "node-1", false)
+	labeled.Labels = map[string]string{ReadinessGateLabel: "true"}
+	client := fake.NewSimpleClientset(testPod("gated", "node-1", true), labeled, testPod("other-node", "node-2", true))
+	g := NewReadinessGate(client)
+	status := func(name string) v1.ConditionStatus {
+		pod, err := client.CoreV1().Pods("default").Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == ReadinessGateConditionType {
+				return condition.Status
+			}
+		}
+		return v1.ConditionUnknown
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, g.Watch(ctx, "node-1", func() string { return "203.0.113.1" }, func(err error) { errs <- err }))
+	}()
+
+	// the pods of the node are set as they are listed, then as they are scheduled
+	assert.Eventually(t, func() bool { return status("gated") == v1.ConditionTrue }, 5*time.Second, 10*time.Millisecond)
+	_, err := client.CoreV1().Pods("default").Create(ctx, testPod("scheduled", "node-1", true), metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return status("scheduled") == v1.ConditionTrue }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, v1.ConditionUnknown, status("other-node"))
+	assert.Equal(t, v1.ConditionUnknown, status("labeled"))
+
+	// the pod opting in without declaring the gate is reported once
+	select {
+	case err := <-errs:
+		assert.Equal(t, "pod default/labeled labeled kubeip.com/static-ip=true does not declare the kubeip.com/static-ip readiness gate", err.Error())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error reported for the labeled pod")
+	}
+	cancel()
+	<-done
+	assert.Empty(t, errs)
+}
+
+func Test_setPodCondition(t *testing.T) {
+	pod := testPod("gated", "node-1", true)
+	condition := v1.PodCondition{Type: ReadinessGateConditionType, Status: v1.ConditionTrue, Message: "assigned"}
+	assert.True(t, setPodCondition(pod, condition))
+	assert.False(t, setPodCondition(pod, condition), "unchanged condition")
+	condition.Status = v1.ConditionFalse
+	assert.True(t, setPodCondition(pod, condition))
+	assert.Len(t, pod.Status.Conditions, 1)
+}
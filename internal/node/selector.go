@@ -0,0 +1,54 @@
+package node
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const maxCanaryPercent = 100
+
+// IgnoreAnnotation opts the node out of static public IP address assignment when set to "true"
+const IgnoreAnnotation = "kubeip.com/ignore"
+
+// MatchesSelector checks if the node labels match the label selector (e.g. "kubeip=enabled,pool in (a,b)");
+// an empty selector matches every node
+func MatchesSelector(n *types.Node, selector string) (bool, error) {
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse node selector %q", selector)
+	}
+	return s.Matches(labels.Set(n.Labels)), nil
+}
+
+// IsIgnored checks if the node opted out of static public IP address assignment with the ignore annotation
+func IsIgnored(n *types.Node) bool {
+	ignore, err := strconv.ParseBool(n.Annotations[IgnoreAnnotation])
+	return err == nil && ignore
+}
+
+// InCanary checks if the node belongs to the canary: the node must match the canary selector (if any) and fall
+// into the canary percentage; the percentage is stable per node name, so the same nodes are picked on every restart
+func InCanary(n *types.Node, selector string, percent int) (bool, error) {
+	if selector != "" {
+		matches, err := MatchesSelector(n, selector)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to match canary selector")
+		}
+		if !matches {
+			return false, nil
+		}
+	}
+	if percent >= maxCanaryPercent {
+		return true, nil
+	}
+	if percent <= 0 {
+		return false, nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(n.Name)) //nolint:errcheck
+	return int(h.Sum32()%maxCanaryPercent) < percent, nil
+}
@@ -0,0 +1,114 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/types"
+)
+
+func TestMatchesSelector(t *testing.T) {
+	n := &types.Node{
+		Name: "test-node",
+		Labels: map[string]string{
+			"kubeip":                        "enabled",
+			"cloud.google.com/gke-nodepool": "public",
+		},
+	}
+	tests := []struct {
+		name     string
+		selector string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "empty selector", selector: "", want: true},
+		{name: "equality match", selector: "kubeip=enabled", want: true},
+		{name: "equality mismatch", selector: "kubeip=disabled", want: false},
+		{name: "set match", selector: "cloud.google.com/gke-nodepool in (public,edge)", want: true},
+		{name: "exists mismatch", selector: "kubeip,missing", want: false},
+		{name: "not exists", selector: "!missing", want: true},
+		{name: "invalid selector", selector: "kubeip in (", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchesSelector(n, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchesSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MatchesSelector() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{name: "no annotations", want: false},
+		{name: "annotation true", annotations: map[string]string{IgnoreAnnotation: "true"}, want: true},
+		{name: "annotation false", annotations: map[string]string{IgnoreAnnotation: "false"}, want: false},
+		{name: "annotation invalid", annotations: map[string]string{IgnoreAnnotation: "yes please"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsIgnored(&types.Node{Name: "test-node", Annotations: tt.annotations}); got != tt.want {
+				t.Errorf("IsIgnored() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInCanary(t *testing.T) {
+	canary := &types.Node{Name: "test-node", Labels: map[string]string{"kubeip/canary": "true"}}
+	stable := &types.Node{Name: "test-node", Labels: map[string]string{}}
+	tests := []struct {
+		name     string
+		node     *types.Node
+		selector string
+		percent  int
+		want     bool
+		wantErr  bool
+	}{
+		{name: "all nodes", node: stable, percent: 100, want: true},
+		{name: "no nodes", node: stable, percent: 0, want: false},
+		{name: "selector match", node: canary, selector: "kubeip/canary=true", percent: 100, want: true},
+		{name: "selector mismatch", node: stable, selector: "kubeip/canary=true", percent: 100, want: false},
+		{name: "selector match no nodes", node: canary, selector: "kubeip/canary=true", percent: 0, want: false},
+		{name: "invalid selector", node: canary, selector: "kubeip in (", percent: 100, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InCanary(tt.node, tt.selector, tt.percent)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InCanary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("InCanary() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInCanary_percent(t *testing.T) {
+	const nodes = 1000
+	selected := 0
+	for i := 0; i < nodes; i++ {
+		n := &types.Node{Name: fmt.Sprintf("node-%d", i)}
+		first, _ := InCanary(n, "", 10)  //nolint:errcheck
+		second, _ := InCanary(n, "", 10) //nolint:errcheck
+		if first != second {
+			t.Fatalf("InCanary() not stable for node %s", n.Name)
+		}
+		if first {
+			selected++
+		}
+	}
+	// roughly 10% of the nodes, with a generous margin for the hash distribution
+	if selected < 50 || selected > 150 {
+		t.Errorf("InCanary() selected %d of %d nodes, want about 10%%", selected, nodes)
+	}
+}
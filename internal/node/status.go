@@ -0,0 +1,239 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typesv1 "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	AddressAnnotation            = "kubeip.com/address"
+	PoolAnnotation               = "kubeip.com/pool"
+	LastTransitionTimeAnnotation = "kubeip.com/last-transition-time"
+	LastErrorAnnotation          = "kubeip.com/last-error"
+	HistoryAnnotation            = "kubeip.com/history"
+	RetryAttemptsAnnotation      = "kubeip.com/retry-attempts"
+	LastRetryTimeAnnotation      = "kubeip.com/last-retry-time"
+	// cloud identifiers of the most recent operation of the node
+	OperationIDAnnotation   = "kubeip.com/operation-id"
+	AllocationIDAnnotation  = "kubeip.com/allocation-id"
+	AssociationIDAnnotation = "kubeip.com/association-id"
+	// HistoryLimit is the number of transitions kept in the history of a node: the annotation weighs on every watcher
+	// of the nodes
+	HistoryLimit = 10
+	// historyErrorLength is the length the errors of the transitions are truncated to in the history of a node
+	historyErrorLength = 200
+)
+
+type StatusRecorder interface {
+	SetStatus(ctx context.Context, status *types.AssignmentStatus) error
+	SetRetry(ctx context.Context, nodeName string, attempts int, lastError string) error
+	GetStatus(ctx context.Context, nodeName string) (*types.AssignmentStatus, error)
+	ListStatus(ctx context.Context) ([]types.AssignmentStatus, error)
+}
+
+type statusRecorder struct {
+	client kubernetes.Interface
+}
+
+func NewStatusRecorder(client kubernetes.Interface) StatusRecorder {
+	return &statusRecorder{
+		client: client,
+	}
+}
+
+// annotationValue returns a pointer to the value, or nil for an empty value (removes annotation in merge patch)
+func annotationValue(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// SetStatus records the assignment status in the node annotations and appends the transition to the node history,
+// dropping the oldest transitions beyond HistoryLimit and truncating their errors; an assignment or release clears the
+// retry state, a failure keeps it. The cloud operation replaces the recorded one if set, otherwise the most recent
+// operation is kept. The node is patched at the version its history was read from: a concurrent write (e.g. the admin
+// API) makes the patch conflict and the history is read again rather than losing its transition
+func (r *statusRecorder) SetStatus(ctx context.Context, status *types.AssignmentStatus) error {
+	transitionTime := status.LastTransitionTime
+	if transitionTime.IsZero() {
+		transitionTime = time.Now()
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error { //nolint:wrapcheck
+		n, err := r.client.CoreV1().Nodes().Get(ctx, status.Node, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to get kubernetes node")
+		}
+		historyData, err := json.Marshal(appendHistory(historyFromNode(n), types.AssignmentTransition{
+			Time:    transitionTime.UTC().Truncate(time.Second),
+			Address: status.Address,
+			Pool:    status.Pool,
+			Error:   status.LastError,
+		}))
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal status history")
+		}
+		annotations := map[string]*string{
+			AddressAnnotation:            annotationValue(status.Address),
+			PoolAnnotation:               annotationValue(status.Pool),
+			LastTransitionTimeAnnotation: annotationValue(transitionTime.UTC().Format(time.RFC3339)),
+			LastErrorAnnotation:          annotationValue(status.LastError),
+			HistoryAnnotation:            annotationValue(string(historyData)),
+		}
+		if status.LastError == "" {
+			annotations[RetryAttemptsAnnotation] = nil
+			annotations[LastRetryTimeAnnotation] = nil
+		}
+		if op := status.Operation; op != nil {
+			annotations[OperationIDAnnotation] = annotationValue(op.OperationID)
+			annotations[AllocationIDAnnotation] = annotationValue(op.AllocationID)
+			annotations[AssociationIDAnnotation] = annotationValue(op.AssociationID)
+		}
+		return r.patchAnnotations(ctx, status.Node, n.ResourceVersion, annotations)
+	})
+}
+
+// appendHistory appends the transition to the history, keeping the HistoryLimit most recent transitions with their
+// errors truncated
+func appendHistory(history []types.AssignmentTransition, transition types.AssignmentTransition) []types.AssignmentTransition {
+	history = append(history, transition)
+	if len(history) > HistoryLimit {
+		history = history[len(history)-HistoryLimit:]
+	}
+	for i := range history {
+		history[i].Error = truncateError(history[i].Error)
+	}
+	return history
+}
+
+// truncateError truncates the error to historyErrorLength bytes, on a character boundary
+func truncateError(message string) string {
+	if len(message) <= historyErrorLength {
+		return message
+	}
+	end := historyErrorLength
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end] + "..."
+}
+
+// SetRetry records the failed attempts of the pending assignment and the last failure in the node annotations, without
+// a transition in the node history: a restarted agent resumes the retries instead of starting over from the first one
+func (r *statusRecorder) SetRetry(ctx context.Context, nodeName string, attempts int, lastError string) error {
+	return r.patchAnnotations(ctx, nodeName, "", map[string]*string{
+		RetryAttemptsAnnotation: annotationValue(strconv.Itoa(attempts)),
+		LastRetryTimeAnnotation: annotationValue(time.Now().UTC().Format(time.RFC3339)),
+		LastErrorAnnotation:     annotationValue(lastError),
+	})
+}
+
+// patchAnnotations merges the annotations into the node annotations; nil values remove the annotation; with a resource
+// version, the patch conflicts if the node changed since
+func (r *statusRecorder) patchAnnotations(ctx context.Context, nodeName, resourceVersion string, annotations map[string]*string) error {
+	metadata := map[string]interface{}{
+		"annotations": annotations,
+	}
+	if resourceVersion != "" {
+		metadata["resourceVersion"] = resourceVersion
+	}
+	patch := map[string]interface{}{
+		"metadata": metadata,
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal status patch")
+	}
+	_, err = r.client.CoreV1().Nodes().Patch(ctx, nodeName, typesv1.MergePatchType, data, metav1.PatchOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to patch node annotations")
+	}
+	return nil
+}
+
+// statusFromNode returns the assignment status recorded in the node annotations or nil if not recorded; a node without
+// transition yet has a status while its first assignment is retried
+func statusFromNode(n *v1.Node) *types.AssignmentStatus {
+	annotations := n.Annotations
+	transition, ok := annotations[LastTransitionTimeAnnotation]
+	if _, retrying := annotations[RetryAttemptsAnnotation]; !ok && !retrying {
+		return nil
+	}
+	status := &types.AssignmentStatus{
+		Node:      n.Name,
+		Address:   annotations[AddressAnnotation],
+		Pool:      annotations[PoolAnnotation],
+		LastError: annotations[LastErrorAnnotation],
+	}
+	// ignore malformed timestamps and retry counts, keep the rest of the status
+	if t, err := time.Parse(time.RFC3339, transition); err == nil {
+		status.LastTransitionTime = t
+	}
+	if attempts, err := strconv.Atoi(annotations[RetryAttemptsAnnotation]); err == nil {
+		status.RetryAttempts = attempts
+	}
+	if t, err := time.Parse(time.RFC3339, annotations[LastRetryTimeAnnotation]); err == nil {
+		status.LastRetryTime = t
+	}
+	status.History = historyFromNode(n)
+	op := &types.CloudOperation{
+		OperationID:   annotations[OperationIDAnnotation],
+		AllocationID:  annotations[AllocationIDAnnotation],
+		AssociationID: annotations[AssociationIDAnnotation],
+	}
+	if *op != (types.CloudOperation{}) {
+		status.Operation = op
+	}
+	return status
+}
+
+// historyFromNode returns the transitions recorded in the node history annotation; a malformed history is dropped
+func historyFromNode(n *v1.Node) []types.AssignmentTransition {
+	value, ok := n.Annotations[HistoryAnnotation]
+	if !ok {
+		return nil
+	}
+	var history []types.AssignmentTransition
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil
+	}
+	return history
+}
+
+// GetStatus returns the assignment status of the node; the status is empty if nothing was recorded yet
+func (r *statusRecorder) GetStatus(ctx context.Context, nodeName string) (*types.AssignmentStatus, error) {
+	n, err := r.client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get kubernetes node")
+	}
+	if status := statusFromNode(n); status != nil {
+		return status, nil
+	}
+	return &types.AssignmentStatus{Node: nodeName}, nil
+}
+
+// ListStatus returns the assignment status of all nodes with a recorded status
+func (r *statusRecorder) ListStatus(ctx context.Context) ([]types.AssignmentStatus, error) {
+	nodes, err := r.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list kubernetes nodes")
+	}
+	statuses := make([]types.AssignmentStatus, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		if status := statusFromNode(&nodes.Items[i]); status != nil {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses, nil
+}
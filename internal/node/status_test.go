@@ -0,0 +1,291 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_statusRecorder_SetStatus(t *testing.T) {
+	transition := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		status          *types.AssignmentStatus
+		wantAnnotations map[string]string
+	}{
+		{
+			name: "record assigned address",
+			status: &types.AssignmentStatus{
+				Node:               "test-node",
+				Address:            "1.1.1.1",
+				Pool:               "test-pool",
+				LastTransitionTime: transition,
+			},
+			wantAnnotations: map[string]string{
+				AddressAnnotation:            "1.1.1.1",
+				PoolAnnotation:               "test-pool",
+				LastTransitionTimeAnnotation: "2024-03-01T10:00:00Z",
+				HistoryAnnotation:            `[{"time":"2024-03-01T10:00:00Z","address":"1.1.1.1","pool":"test-pool"}]`,
+			},
+		},
+		{
+			name: "record error and clear previous address",
+			annotations: map[string]string{
+				"other":                      "value",
+				AddressAnnotation:            "1.1.1.1",
+				PoolAnnotation:               "test-pool",
+				LastTransitionTimeAnnotation: "2024-02-01T10:00:00Z",
+			},
+			status: &types.AssignmentStatus{
+				Node:               "test-node",
+				Pool:               "test-pool",
+				LastTransitionTime: transition,
+				LastError:          "no available addresses",
+			},
+			wantAnnotations: map[string]string{
+				"other":                      "value",
+				PoolAnnotation:               "test-pool",
+				LastTransitionTimeAnnotation: "2024-03-01T10:00:00Z",
+				LastErrorAnnotation:          "no available addresses",
+				HistoryAnnotation:            `[{"time":"2024-03-01T10:00:00Z","pool":"test-pool","error":"no available addresses"}]`,
+			},
+		},
+		{
+			name: "record cloud operation",
+			annotations: map[string]string{
+				OperationIDAnnotation: "previous-request",
+			},
+			status: &types.AssignmentStatus{
+				Node:               "test-node",
+				Address:            "1.1.1.1",
+				LastTransitionTime: transition,
+				Operation:          &types.CloudOperation{AllocationID: "eipalloc-1", AssociationID: "eipassoc-1"},
+			},
+			wantAnnotations: map[string]string{
+				AddressAnnotation:            "1.1.1.1",
+				LastTransitionTimeAnnotation: "2024-03-01T10:00:00Z",
+				HistoryAnnotation:            `[{"time":"2024-03-01T10:00:00Z","address":"1.1.1.1"}]`,
+				AllocationIDAnnotation:       "eipalloc-1",
+				AssociationIDAnnotation:      "eipassoc-1",
+			},
+		},
+		{
+			name: "keep the most recent cloud operation",
+			annotations: map[string]string{
+				OperationIDAnnotation: "operation-1",
+			},
+			status: &types.AssignmentStatus{
+				Node:               "test-node",
+				LastTransitionTime: transition,
+				LastError:          "quota exceeded",
+			},
+			wantAnnotations: map[string]string{
+				LastTransitionTimeAnnotation: "2024-03-01T10:00:00Z",
+				LastErrorAnnotation:          "quota exceeded",
+				HistoryAnnotation:            `[{"time":"2024-03-01T10:00:00Z","error":"quota exceeded"}]`,
+				OperationIDAnnotation:        "operation-1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-node", Annotations: tt.annotations},
+			})
+			r := NewStatusRecorder(client)
+			if err := r.SetStatus(context.Background(), tt.status); err != nil {
+				t.Fatalf("SetStatus() error = %v", err)
+			}
+			n, err := client.CoreV1().Nodes().Get(context.Background(), "test-node", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(n.Annotations) != len(tt.wantAnnotations) {
+				t.Errorf("SetStatus() annotations = %v, want %v", n.Annotations, tt.wantAnnotations)
+			}
+			for k, v := range tt.wantAnnotations {
+				if n.Annotations[k] != v {
+					t.Errorf("SetStatus() annotation %s = %v, want %v", k, n.Annotations[k], v)
+				}
+			}
+		})
+	}
+}
+
+func Test_statusRecorder_History(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+	r := NewStatusRecorder(client)
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < HistoryLimit+2; i++ {
+		status := &types.AssignmentStatus{Node: "test-node", Address: "1.1.1.1", LastTransitionTime: start.Add(time.Duration(i) * time.Minute)}
+		if i%2 == 1 {
+			status.Address = ""
+		}
+		if err := r.SetStatus(context.Background(), status); err != nil {
+			t.Fatalf("SetStatus() error = %v", err)
+		}
+	}
+
+	status, err := r.GetStatus(context.Background(), "test-node")
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if len(status.History) != HistoryLimit {
+		t.Fatalf("GetStatus() history length = %d, want %d", len(status.History), HistoryLimit)
+	}
+	// the two oldest transitions are dropped
+	if first := status.History[0]; !first.Time.Equal(start.Add(2*time.Minute)) || first.Address != "1.1.1.1" {
+		t.Errorf("GetStatus() oldest transition = %+v", first)
+	}
+	if last := status.History[HistoryLimit-1]; !last.Time.Equal(start.Add((HistoryLimit+1)*time.Minute)) || last.Address != "" {
+		t.Errorf("GetStatus() latest transition = %+v", last)
+	}
+}
+
+func Test_statusRecorder_HistoryTruncatesErrors(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+	r := NewStatusRecorder(client)
+	lastError := "x" + strings.Repeat("é", historyErrorLength)
+	if err := r.SetStatus(context.Background(), &types.AssignmentStatus{Node: "test-node", LastError: lastError}); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+	status, err := r.GetStatus(context.Background(), "test-node")
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	// the last error is kept whole, the error of the transition is truncated on a character boundary
+	if status.LastError != lastError {
+		t.Errorf("GetStatus() last error truncated")
+	}
+	if got := status.History[0].Error; got != "x"+strings.Repeat("é", historyErrorLength/2-1)+"..." {
+		t.Errorf("GetStatus() history error = %q", got)
+	}
+}
+
+func Test_statusRecorder_SetStatusConflict(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", ResourceVersion: "1"}})
+	var patches []string
+	client.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patches = append(patches, string(action.(k8stesting.PatchAction).GetPatch()))
+		if len(patches) > 1 {
+			return false, nil, nil
+		}
+		// the admin API records a transition meanwhile
+		concurrent := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node", ResourceVersion: "2", Annotations: map[string]string{
+			HistoryAnnotation: `[{"time":"2024-03-01T09:00:00Z","address":"1.1.1.1"}]`,
+		}}}
+		if err := client.Tracker().Update(v1.SchemeGroupVersion.WithResource("nodes"), concurrent, ""); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(v1.Resource("nodes"), "test-node", errors.New("the object has been modified"))
+	})
+
+	r := NewStatusRecorder(client)
+	transition := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	if err := r.SetStatus(context.Background(), &types.AssignmentStatus{Node: "test-node", LastTransitionTime: transition}); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+	if len(patches) != 2 || !strings.Contains(patches[0], `"resourceVersion":"1"`) || !strings.Contains(patches[1], `"resourceVersion":"2"`) {
+		t.Fatalf("SetStatus() patches = %v, want the conflicting patch retried at the current version", patches)
+	}
+	status, err := r.GetStatus(context.Background(), "test-node")
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	// the concurrent transition is kept
+	if len(status.History) != 2 || status.History[0].Address != "1.1.1.1" || !status.History[1].Time.Equal(transition) {
+		t.Errorf("GetStatus() history = %+v", status.History)
+	}
+}
+
+func Test_statusRecorder_GetAndListStatus(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: map[string]string{
+				AddressAnnotation:            "1.1.1.1",
+				PoolAnnotation:               "pool-1",
+				LastTransitionTimeAnnotation: "2024-03-01T10:00:00Z",
+				OperationIDAnnotation:        "operation-1",
+			}},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+		},
+	)
+	r := NewStatusRecorder(client)
+
+	status, err := r.GetStatus(context.Background(), "node-1")
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Address != "1.1.1.1" || status.Pool != "pool-1" || status.LastTransitionTime.IsZero() {
+		t.Errorf("GetStatus() = %+v", status)
+	}
+	if status.Operation == nil || status.Operation.OperationID != "operation-1" {
+		t.Errorf("GetStatus() operation = %+v, want operation-1", status.Operation)
+	}
+
+	status, err = r.GetStatus(context.Background(), "node-2")
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.Node != "node-2" || status.Address != "" {
+		t.Errorf("GetStatus() for node without status = %+v", status)
+	}
+
+	if _, err = r.GetStatus(context.Background(), "missing"); err == nil {
+		t.Errorf("GetStatus() for missing node expected error")
+	}
+
+	statuses, err := r.ListStatus(context.Background())
+	if err != nil {
+		t.Fatalf("ListStatus() error = %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Node != "node-1" {
+		t.Errorf("ListStatus() = %+v, want only node-1", statuses)
+	}
+}
+
+func Test_statusRecorder_Retry(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+	r := NewStatusRecorder(client)
+	ctx := context.Background()
+
+	if err := r.SetRetry(ctx, "test-node", 2, "no available addresses"); err != nil {
+		t.Fatalf("SetRetry() error = %v", err)
+	}
+	status, err := r.GetStatus(ctx, "test-node")
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if status.RetryAttempts != 2 || status.LastError != "no available addresses" || status.LastRetryTime.IsZero() || len(status.History) != 0 {
+		t.Errorf("GetStatus() after SetRetry() = %+v", status)
+	}
+
+	// a failed assignment keeps the retry state
+	if err = r.SetStatus(ctx, &types.AssignmentStatus{Node: "test-node", LastError: "no available addresses"}); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+	if status, _ = r.GetStatus(ctx, "test-node"); status.RetryAttempts != 2 {
+		t.Errorf("GetStatus() retry attempts after failure = %d, want 2", status.RetryAttempts)
+	}
+
+	// an assignment clears it
+	if err = r.SetStatus(ctx, &types.AssignmentStatus{Node: "test-node", Address: "1.1.1.1"}); err != nil {
+		t.Fatalf("SetStatus() error = %v", err)
+	}
+	if status, _ = r.GetStatus(ctx, "test-node"); status.RetryAttempts != 0 || !status.LastRetryTime.IsZero() {
+		t.Errorf("GetStatus() retry state after assignment = %+v", status)
+	}
+}
@@ -0,0 +1,106 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxResponseSize bounds the response of the echo endpoint: an address, possibly in a small JSON object
+const maxResponseSize = 4096
+
+// ErrUnexpectedEgress is returned when the traffic of the node egresses from another address than expected
+var ErrUnexpectedEgress = errors.New("traffic egresses from unexpected address")
+
+// Prober reports the public IP address the traffic of the node egresses from
+type Prober interface {
+	EgressIP(ctx context.Context) (string, error)
+}
+
+type prober struct {
+	url    string
+	client *http.Client
+}
+
+// NewProber returns a prober calling the echo endpoint ("what's my IP" service) at the URL; the endpoint answers the
+// caller address as plain text or as a JSON object with an ip field; the requests bypass any proxy: the proxy would
+// report its own address
+func NewProber(url string, timeout time.Duration) Prober {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	transport.Proxy = nil
+	// a new connection per probe: a kept alive connection still egresses from the previous address
+	transport.DisableKeepAlives = true
+	return &prober{
+		url:    url,
+		client: &http.Client{Transport: transport, Timeout: timeout},
+	}
+}
+
+func (p *prober) EgressIP(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, http.NoBody)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid echo endpoint %s", p.url)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to call echo endpoint %s", p.url)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read echo endpoint %s response", p.url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("echo endpoint %s answered %s", p.url, resp.Status)
+	}
+	return parseAddress(body)
+}
+
+// parseAddress returns the address of the echo endpoint response: plain text or a JSON object with an ip field
+func parseAddress(body []byte) (string, error) {
+	text := strings.TrimSpace(string(body))
+	var object struct {
+		IP string `json:"ip"`
+	}
+	if strings.HasPrefix(text, "{") && json.Unmarshal(body, &object) == nil {
+		text = object.IP
+	}
+	ip := net.ParseIP(text)
+	if ip == nil {
+		return "", errors.Errorf("invalid address %q in echo endpoint response", text)
+	}
+	return ip.String(), nil
+}
+
+// Verify probes the egress every interval until the traffic egresses from the expected address; once the context is
+// done, it returns ErrUnexpectedEgress with the last address seen, or the last probe error
+func Verify(ctx context.Context, p Prober, expected string, interval time.Duration) error {
+	if ip := net.ParseIP(expected); ip != nil {
+		expected = ip.String()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last error
+	for {
+		egress, err := p.EgressIP(ctx)
+		switch {
+		case err != nil:
+			last = err
+		case egress == expected:
+			return nil
+		default:
+			last = errors.Wrapf(ErrUnexpectedEgress, "%s instead of %s", egress, expected)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return last
+		}
+	}
+}
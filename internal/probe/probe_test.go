@@ -0,0 +1,68 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProber_EgressIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain text", status: http.StatusOK, body: "203.0.113.1\n", want: "203.0.113.1"},
+		{name: "json", status: http.StatusOK, body: `{"ip": "2001:db8::1"}`, want: "2001:db8::1"},
+		{name: "not an address", status: http.StatusOK, body: "<html></html>", wantErr: true},
+		{name: "error status", status: http.StatusBadGateway, body: "203.0.113.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			got, err := NewProber(server.URL, time.Second).EgressIP(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// fakeProber reports the addresses in order, then the last one
+type fakeProber struct {
+	addresses []string
+	calls     int
+}
+
+func (f *fakeProber) EgressIP(context.Context) (string, error) {
+	address := f.addresses[min(f.calls, len(f.addresses)-1)]
+	f.calls++
+	return address, nil
+}
+
+func TestVerify(t *testing.T) {
+	// the routing catches up after the assignment
+	p := &fakeProber{addresses: []string{"198.51.100.1", "203.0.113.1"}}
+	require.NoError(t, Verify(context.Background(), p, "203.0.113.1", time.Millisecond))
+	assert.Equal(t, 2, p.calls)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := Verify(ctx, &fakeProber{addresses: []string{"198.51.100.1"}}, "203.0.113.1", time.Millisecond)
+	assert.True(t, errors.Is(err, ErrUnexpectedEgress))
+}
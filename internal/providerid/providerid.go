@@ -0,0 +1,180 @@
+package providerid
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	schemeAWS       = "aws://"
+	schemeAzure     = "azure://"
+	schemeGCE       = "gce://"
+	schemeOCI       = "oci://"
+	schemeOpenStack = "openstack://"
+	// ociInstancePrefix is the prefix of the OCID of an OCI compute instance
+	ociInstancePrefix = "ocid1.instance."
+)
+
+// ErrEmpty is returned for an empty provider ID: the kubelet runs without cloud provider integration
+var ErrEmpty = errors.New("provider ID is empty")
+
+// UnknownFormatError is returned for a provider ID of an unknown cloud provider, e.g. kind:// or k3s://
+type UnknownFormatError struct {
+	ProviderID string
+}
+
+func (e *UnknownFormatError) Error() string {
+	return fmt.Sprintf("unsupported provider ID: %s", e.ProviderID)
+}
+
+// InvalidFormatError is returned for a malformed provider ID of a known cloud provider, with the expected formats
+type InvalidFormatError struct {
+	ProviderID string
+	Cloud      types.CloudProvider
+	Expected   string
+}
+
+func (e *InvalidFormatError) Error() string {
+	return fmt.Sprintf("invalid %s provider ID %q, expected %s", e.Cloud, e.ProviderID, e.Expected)
+}
+
+// ProviderID is the cloud instance backing a Kubernetes node, as recorded in spec.providerID by the kubelet or the cloud
+// controller manager
+type ProviderID struct {
+	Cloud types.CloudProvider
+	// Project is the project of a GCE instance
+	Project string
+	// Region is the region of an OpenStack instance, if recorded
+	Region string
+	// Zone is the zone of a GCE instance or the availability zone of an AWS instance, if recorded
+	Zone string
+	// Instance is the instance name (GCE), ID (AWS, OpenStack), OCID (OCI) or virtual machine resource ID (Azure)
+	Instance string
+}
+
+// Parse parses the provider ID of a node:
+//   - gce://<project>/<zone>/<instance> or gce:///projects/<project>/zones/<zone>/instances/<instance>
+//   - aws:///<availability zone>/<instance ID>, aws://<availability zone>/<instance ID> or aws:///<instance ID>
+//   - azure:///subscriptions/<subscription>/resourceGroups/<group>/providers/Microsoft.Compute/virtualMachines/<name>,
+//     or .../virtualMachineScaleSets/<scale set>/virtualMachines/<instance ID>
+//   - ocid1.instance.<realm>.<region>.<id> or oci://ocid1.instance...
+//   - openstack:///<instance ID> or openstack://<region>/<instance ID>
+func Parse(providerID string) (*ProviderID, error) {
+	switch {
+	case providerID == "":
+		return nil, ErrEmpty
+	case strings.HasPrefix(providerID, schemeGCE):
+		return parseGCE(providerID)
+	case strings.HasPrefix(providerID, schemeAWS):
+		return parseAWS(providerID)
+	case strings.HasPrefix(providerID, schemeAzure):
+		return parseAzure(providerID)
+	case strings.HasPrefix(providerID, schemeOCI), strings.HasPrefix(providerID, ociInstancePrefix):
+		return parseOCI(providerID)
+	case strings.HasPrefix(providerID, schemeOpenStack):
+		return parseOpenStack(providerID)
+	}
+	return nil, &UnknownFormatError{ProviderID: providerID}
+}
+
+// nonEmpty checks that none of the tokens is empty
+func nonEmpty(tokens ...string) bool {
+	for _, token := range tokens {
+		if token == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGCE parses a GCE provider ID; the instance is the name of the GCE instance backing the node, which differs from
+// the node name with custom hostnames (e.g. managed instance groups)
+func parseGCE(providerID string) (*ProviderID, error) {
+	invalid := &InvalidFormatError{
+		ProviderID: providerID,
+		Cloud:      types.CloudProviderGCP,
+		Expected:   "gce://<project>/<zone>/<instance> or gce:///projects/<project>/zones/<zone>/instances/<instance>",
+	}
+	path := strings.TrimPrefix(providerID, schemeGCE)
+	if strings.HasPrefix(path, "/projects/") {
+		s := strings.Split(strings.TrimPrefix(path, "/"), "/")
+		if len(s) == 6 && s[2] == "zones" && s[4] == "instances" && nonEmpty(s[1], s[3], s[5]) { //nolint:gomnd
+			return &ProviderID{Cloud: types.CloudProviderGCP, Project: s[1], Zone: s[3], Instance: s[5]}, nil
+		}
+		return nil, invalid
+	}
+	s := strings.Split(path, "/")
+	if len(s) != 3 || !nonEmpty(s...) { //nolint:gomnd
+		return nil, invalid
+	}
+	return &ProviderID{Cloud: types.CloudProviderGCP, Project: s[0], Zone: s[1], Instance: s[2]}, nil
+}
+
+// parseAWS parses an AWS provider ID; the availability zone is the host of the legacy form
+func parseAWS(providerID string) (*ProviderID, error) {
+	invalid := &InvalidFormatError{
+		ProviderID: providerID,
+		Cloud:      types.CloudProviderAWS,
+		Expected:   "aws:///<availability zone>/<instance ID>",
+	}
+	s := strings.Split(strings.TrimPrefix(strings.TrimPrefix(providerID, schemeAWS), "/"), "/")
+	switch {
+	case len(s) == 1 && nonEmpty(s...):
+		return &ProviderID{Cloud: types.CloudProviderAWS, Instance: s[0]}, nil
+	case len(s) == 2 && nonEmpty(s...): //nolint:gomnd
+		return &ProviderID{Cloud: types.CloudProviderAWS, Zone: s[0], Instance: s[1]}, nil
+	}
+	// EKS Fargate nodes: aws:///<zone>/<id>/fargate-ip-<address>.<region>.compute.internal
+	return nil, invalid
+}
+
+// parseAzure parses an Azure provider ID; the instance is the virtual machine resource ID, unique across the scale sets:
+// the instance of a uniform orchestration scale set (.../virtualMachineScaleSets/<scale set>/virtualMachines/<instance
+// ID>) is only identified by its scale set, the instance of a flexible orchestration scale set is a standalone virtual
+// machine (.../virtualMachines/<name>); the resource types are case insensitive
+func parseAzure(providerID string) (*ProviderID, error) {
+	resourceID := strings.TrimPrefix(providerID, schemeAzure)
+	s := strings.Split(strings.TrimPrefix(resourceID, "/"), "/")
+	valid := len(s) >= 8 && strings.EqualFold(s[0], "subscriptions") && strings.EqualFold(s[2], "resourceGroups") && //nolint:gomnd
+		strings.EqualFold(s[4], "providers") && strings.EqualFold(s[5], "Microsoft.Compute") && nonEmpty(s...)
+	switch {
+	case valid && len(s) == 8 && strings.EqualFold(s[6], "virtualMachines"): //nolint:gomnd
+	case valid && len(s) == 10 && strings.EqualFold(s[6], "virtualMachineScaleSets") && strings.EqualFold(s[8], "virtualMachines"): //nolint:gomnd
+	default:
+		return nil, &InvalidFormatError{
+			ProviderID: providerID,
+			Cloud:      types.CloudProviderAzure,
+			Expected:   "azure:///subscriptions/<subscription>/resourceGroups/<group>/providers/Microsoft.Compute/virtualMachines/<name> or .../virtualMachineScaleSets/<scale set>/virtualMachines/<instance ID>",
+		}
+	}
+	return &ProviderID{Cloud: types.CloudProviderAzure, Instance: "/" + strings.Join(s, "/")}, nil
+}
+
+// parseOCI parses an OCI provider ID: the OCID of the instance, with or without the oci:// scheme
+func parseOCI(providerID string) (*ProviderID, error) {
+	ocid := strings.TrimPrefix(providerID, schemeOCI)
+	if !strings.HasPrefix(ocid, ociInstancePrefix) || strings.Contains(ocid, "/") {
+		return nil, &InvalidFormatError{
+			ProviderID: providerID,
+			Cloud:      types.CloudProviderOCI,
+			Expected:   "ocid1.instance.<realm>.<region>.<id>",
+		}
+	}
+	return &ProviderID{Cloud: types.CloudProviderOCI, Instance: ocid}, nil
+}
+
+// parseOpenStack parses an OpenStack provider ID; the region is the host of the provider ID, empty with a single region
+func parseOpenStack(providerID string) (*ProviderID, error) {
+	s := strings.Split(strings.TrimPrefix(providerID, schemeOpenStack), "/")
+	if len(s) != 2 || s[1] == "" { //nolint:gomnd
+		return nil, &InvalidFormatError{
+			ProviderID: providerID,
+			Cloud:      types.CloudProviderOpenStack,
+			Expected:   "openstack:///<instance ID> or openstack://<region>/<instance ID>",
+		}
+	}
+	return &ProviderID{Cloud: types.CloudProviderOpenStack, Region: s[0], Instance: s[1]}, nil
+}
@@ -0,0 +1,147 @@
+package providerid
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		providerID string
+		want       *ProviderID
+	}{
+		{
+			name:       "gce",
+			providerID: "gce://test-project/us-central1-a/gke-cluster-default-pool-1234",
+			want:       &ProviderID{Cloud: types.CloudProviderGCP, Project: "test-project", Zone: "us-central1-a", Instance: "gke-cluster-default-pool-1234"},
+		},
+		{
+			name:       "gce resource path",
+			providerID: "gce:///projects/123456789012/zones/us-west1-b/instances/gke-cluster-1-default-pool-12345678-0v0v",
+			want:       &ProviderID{Cloud: types.CloudProviderGCP, Project: "123456789012", Zone: "us-west1-b", Instance: "gke-cluster-1-default-pool-12345678-0v0v"},
+		},
+		{
+			name:       "aws",
+			providerID: "aws:///us-west-2b/i-06d71a5ffc05cc325",
+			want:       &ProviderID{Cloud: types.CloudProviderAWS, Zone: "us-west-2b", Instance: "i-06d71a5ffc05cc325"},
+		},
+		{
+			name:       "aws legacy",
+			providerID: "aws://us-west-2b/i-06d71a5ffc05cc325",
+			want:       &ProviderID{Cloud: types.CloudProviderAWS, Zone: "us-west-2b", Instance: "i-06d71a5ffc05cc325"},
+		},
+		{
+			name:       "aws without zone",
+			providerID: "aws:///i-06d71a5ffc05cc325",
+			want:       &ProviderID{Cloud: types.CloudProviderAWS, Instance: "i-06d71a5ffc05cc325"},
+		},
+		{
+			name:       "azure uniform orchestration scale set",
+			providerID: "azure:///subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/mc_rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-nodepool1-12345678-vmss/virtualMachines/3",
+			want: &ProviderID{
+				Cloud:    types.CloudProviderAzure,
+				Instance: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/mc_rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-nodepool1-12345678-vmss/virtualMachines/3",
+			},
+		},
+		{
+			name:       "azure flexible orchestration scale set",
+			providerID: "azure:///subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/mc_rg/providers/Microsoft.Compute/virtualMachines/aks-nodepool2-12345678-vmss_0",
+			want: &ProviderID{
+				Cloud:    types.CloudProviderAzure,
+				Instance: "/subscriptions/12345678-1234-1234-1234-123456789012/resourceGroups/mc_rg/providers/Microsoft.Compute/virtualMachines/aks-nodepool2-12345678-vmss_0",
+			},
+		},
+		{
+			name:       "azure lowercase resource types",
+			providerID: "azure:///subscriptions/sub/resourcegroups/rg/providers/microsoft.compute/virtualmachinescalesets/vmss/virtualmachines/0",
+			want:       &ProviderID{Cloud: types.CloudProviderAzure, Instance: "/subscriptions/sub/resourcegroups/rg/providers/microsoft.compute/virtualmachinescalesets/vmss/virtualmachines/0"},
+		},
+		{
+			name:       "oci",
+			providerID: "ocid1.instance.oc1.ap-mumbai-1.anrg6ljrdgsxvfacnncnwaxaasbdnjdgwuejhkbdfejkenoernoered",
+			want:       &ProviderID{Cloud: types.CloudProviderOCI, Instance: "ocid1.instance.oc1.ap-mumbai-1.anrg6ljrdgsxvfacnncnwaxaasbdnjdgwuejhkbdfejkenoernoered"},
+		},
+		{
+			name:       "oci with scheme",
+			providerID: "oci://ocid1.instance.oc1.iad.abc",
+			want:       &ProviderID{Cloud: types.CloudProviderOCI, Instance: "ocid1.instance.oc1.iad.abc"},
+		},
+		{
+			name:       "openstack",
+			providerID: "openstack:///8e4d3b27-5f7c-4d4f-9a4e-2b1c0f6e1a11",
+			want:       &ProviderID{Cloud: types.CloudProviderOpenStack, Instance: "8e4d3b27-5f7c-4d4f-9a4e-2b1c0f6e1a11"},
+		},
+		{
+			name:       "openstack with region",
+			providerID: "openstack://RegionOne/8e4d3b27-5f7c-4d4f-9a4e-2b1c0f6e1a11",
+			want:       &ProviderID{Cloud: types.CloudProviderOpenStack, Region: "RegionOne", Instance: "8e4d3b27-5f7c-4d4f-9a4e-2b1c0f6e1a11"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.providerID)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		providerID  string
+		wantInvalid types.CloudProvider
+	}{
+		{name: "gce without zone", providerID: "gce://test-project/gke-cluster-default-pool-1234", wantInvalid: types.CloudProviderGCP},
+		{name: "gce empty zone", providerID: "gce://test-project//gke-cluster-default-pool-1234", wantInvalid: types.CloudProviderGCP},
+		{name: "gce resource path without zone", providerID: "gce:///projects/123456789012/instances/gke-1", wantInvalid: types.CloudProviderGCP},
+		{name: "gce resource path empty instance", providerID: "gce:///projects/123456789012/zones/us-west1-b/instances/", wantInvalid: types.CloudProviderGCP},
+		{name: "aws empty", providerID: "aws:///", wantInvalid: types.CloudProviderAWS},
+		{name: "aws empty instance", providerID: "aws:///us-west-2b/", wantInvalid: types.CloudProviderAWS},
+		{name: "aws fargate", providerID: "aws:///us-east-1a/0123abcd/fargate-ip-192-168-1-10.us-east-1.compute.internal", wantInvalid: types.CloudProviderAWS},
+		{name: "azure scale set without instance", providerID: "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/vmss", wantInvalid: types.CloudProviderAzure},
+		{name: "azure empty instance", providerID: "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/", wantInvalid: types.CloudProviderAzure},
+		{name: "azure other resource", providerID: "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/nic", wantInvalid: types.CloudProviderAzure},
+		{name: "oci other resource", providerID: "oci://ocid1.nodepool.oc1.iad.abc", wantInvalid: types.CloudProviderOCI},
+		{name: "openstack without instance", providerID: "openstack://RegionOne/", wantInvalid: types.CloudProviderOpenStack},
+		{name: "openstack extra token", providerID: "openstack://RegionOne/project/instance", wantInvalid: types.CloudProviderOpenStack},
+		{name: "kind", providerID: "kind://docker/kind/kind-worker"},
+		{name: "k3s", providerID: "k3s://node-1"},
+		{name: "no scheme", providerID: "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.providerID)
+			var invalid *InvalidFormatError
+			var unknown *UnknownFormatError
+			switch {
+			case tt.wantInvalid != "":
+				if !errors.As(err, &invalid) || invalid.Cloud != tt.wantInvalid {
+					t.Errorf("Parse() error = %v, want invalid %s provider ID", err, tt.wantInvalid)
+				}
+			case !errors.As(err, &unknown):
+				t.Errorf("Parse() error = %v, want unknown format", err)
+			}
+		})
+	}
+
+	if _, err := Parse(""); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Parse() error = %v, want %v", err, ErrEmpty)
+	}
+}
+
+func TestInvalidFormatError(t *testing.T) {
+	_, err := Parse("gce://test-project/gke-1")
+	want := `invalid gcp provider ID "gce://test-project/gke-1", expected gce://<project>/<zone>/<instance> or gce:///projects/<project>/zones/<zone>/instances/<instance>`
+	if err == nil || err.Error() != want {
+		t.Errorf("Parse() error = %v, want %v", err, want)
+	}
+}
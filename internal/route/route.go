@@ -0,0 +1,158 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	objectRoute = "route"
+	objectRule  = "rule"
+)
+
+// ErrUnknownObject is returned for entries neither adding a route nor a rule
+var ErrUnknownObject = errors.New("policy route entry must start with route or rule")
+
+// Hook adds the policy routing entries (ip route, ip rule) of the address assigned to the node, so the return traffic of
+// an additional address or interface flows through it, and deletes them on release
+type Hook interface {
+	// Add adds the entries in order, replacing the existing ones
+	Add(ctx context.Context, nodeName, address string) error
+	// Delete deletes the entries in reverse order
+	Delete(ctx context.Context, nodeName, address string) error
+}
+
+// runner runs the command and returns its combined output
+type runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.Bytes(), err //nolint:wrapcheck
+}
+
+// entry is a policy routing entry: the object (route or rule) and the template of its ip arguments
+type entry struct {
+	object string
+	args   *template.Template
+}
+
+// data are the fields of the entry templates
+type data struct {
+	Node    string
+	Address string
+}
+
+type hook struct {
+	logger  *logrus.Entry
+	run     runner
+	entries []entry
+}
+
+// NewHook returns the hook of the configured policy routing entries or nil if none is configured
+func NewHook(logger *logrus.Entry, cfg *config.Config) (Hook, error) {
+	if len(cfg.PolicyRoutes) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+	entries, err := parseEntries(cfg.PolicyRoutes)
+	if err != nil {
+		return nil, err
+	}
+	return &hook{logger: logger, run: run, entries: entries}, nil
+}
+
+// parseEntries parses the entries: route or rule followed by the ip arguments, e.g. "rule from {{.Address}} lookup 100"
+func parseEntries(values []string) ([]entry, error) {
+	entries := make([]entry, 0, len(values))
+	for _, value := range values {
+		object, args, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if object != objectRoute && object != objectRule {
+			return nil, errors.Wrapf(ErrUnknownObject, "%q", value)
+		}
+		tmpl, err := template.New(object).Option("missingkey=error").Parse(args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse policy route %q", value)
+		}
+		entries = append(entries, entry{object: object, args: tmpl})
+	}
+	return entries, nil
+}
+
+// command returns the ip command of the entry with the action (add, replace, del)
+func (e *entry) command(action string, d data) ([]string, error) {
+	var args strings.Builder
+	if err := e.args.Execute(&args, d); err != nil {
+		return nil, errors.Wrapf(err, "failed to render policy %s", e.object)
+	}
+	command := []string{e.object, action}
+	if ip := net.ParseIP(d.Address); ip != nil && ip.To4() == nil {
+		command = append([]string{"-6"}, command...)
+	}
+	return append(command, strings.Fields(args.String())...), nil
+}
+
+func (h *hook) ip(ctx context.Context, args []string) error {
+	if out, err := h.run(ctx, "ip", args...); err != nil {
+		return errors.Wrapf(err, "ip %s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (h *hook) Add(ctx context.Context, nodeName, address string) error {
+	d := data{Node: nodeName, Address: address}
+	for i := range h.entries {
+		e := &h.entries[i]
+		if e.object == objectRule {
+			// ip rule add duplicates rules: delete the rule first, missing or not
+			del, err := e.command("del", d)
+			if err != nil {
+				return err
+			}
+			_ = h.ip(ctx, del)
+		}
+		action := "add"
+		if e.object == objectRoute {
+			action = "replace"
+		}
+		add, err := e.command(action, d)
+		if err != nil {
+			return err
+		}
+		if err = h.ip(ctx, add); err != nil {
+			return err
+		}
+	}
+	h.logger.WithFields(logrus.Fields{"node": nodeName, "address": address, "entries": len(h.entries)}).Info("policy routes added")
+	return nil
+}
+
+func (h *hook) Delete(ctx context.Context, nodeName, address string) error {
+	d := data{Node: nodeName, Address: address}
+	// delete every entry, missing or not: a failure leaves the next entries in place otherwise
+	var first error
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		del, err := h.entries[i].command("del", d)
+		if err == nil {
+			err = h.ip(ctx, del)
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	if first != nil {
+		return first
+	}
+	h.logger.WithFields(logrus.Fields{"node": nodeName, "address": address, "entries": len(h.entries)}).Info("policy routes deleted")
+	return nil
+}
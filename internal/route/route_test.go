@@ -0,0 +1,78 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner records the commands; the commands with a failing prefix fail
+type fakeRunner struct {
+	commands []string
+	failing  []string
+}
+
+func (f *fakeRunner) run(_ context.Context, name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.commands = append(f.commands, command)
+	for _, prefix := range f.failing {
+		if strings.HasPrefix(command, prefix) {
+			return []byte("RTNETLINK answers: No such file or directory"), errors.New("exit status 2")
+		}
+	}
+	return nil, nil
+}
+
+func newTestHook(t *testing.T, f *fakeRunner) *hook {
+	entries, err := parseEntries([]string{
+		"route default via 10.0.1.1 dev eth1 table 100",
+		"rule from {{.Address}} lookup 100",
+	})
+	require.NoError(t, err)
+	return &hook{logger: logrus.NewEntry(logrus.New()), run: f.run, entries: entries}
+}
+
+func TestNewHook(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	h, err := NewHook(logger, &config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, h)
+
+	_, err = NewHook(logger, &config.Config{PolicyRoutes: []string{"link set eth1 up"}})
+	assert.ErrorIs(t, err, ErrUnknownObject)
+	_, err = NewHook(logger, &config.Config{PolicyRoutes: []string{"rule from {{.Address lookup 100"}})
+	assert.Error(t, err)
+}
+
+func TestHook(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeRunner{failing: []string{"ip rule del"}}
+	h := newTestHook(t, f)
+	require.NoError(t, h.Add(ctx, "node-1", "10.0.1.20"))
+	assert.Equal(t, []string{
+		"ip route replace default via 10.0.1.1 dev eth1 table 100",
+		"ip rule del from 10.0.1.20 lookup 100",
+		"ip rule add from 10.0.1.20 lookup 100",
+	}, f.commands)
+
+	// deleted in reverse order, a missing entry does not keep the others
+	f = &fakeRunner{failing: []string{"ip -6 rule del"}}
+	h = newTestHook(t, f)
+	assert.Error(t, h.Delete(ctx, "node-1", "2001:db8::20"))
+	assert.Equal(t, []string{
+		"ip -6 rule del from 2001:db8::20 lookup 100",
+		"ip -6 route del default via 10.0.1.1 dev eth1 table 100",
+	}, f.commands)
+
+	// unknown template field
+	entries, err := parseEntries([]string{"rule from {{.Pool}} lookup 100"})
+	require.NoError(t, err)
+	h = &hook{logger: logrus.NewEntry(logrus.New()), run: f.run, entries: entries}
+	assert.Error(t, h.Add(ctx, "node-1", "10.0.1.20"))
+}
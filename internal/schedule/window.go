@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	cronFields   = 5
+	windowFields = cronFields + 1
+)
+
+var ErrInvalidWindow = errors.New("invalid maintenance window")
+
+// field bounds: minute, hour, day of month, month, day of week (0 = Sunday, 7 is accepted as Sunday)
+var fieldBounds = [cronFields][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}} //nolint:gomnd
+
+// Window is a maintenance window: a cron expression opening the window and the window duration,
+// e.g. "0 2 * * 6 4h" opens every Saturday at 02:00 for 4 hours
+type Window struct {
+	expr     string
+	fields   [cronFields]map[int]bool
+	wildcard [cronFields]bool
+	duration time.Duration
+}
+
+// Windows is a list of maintenance windows; an empty list is always open
+type Windows []*Window
+
+// ParseWindow parses a maintenance window: "<minute> <hour> <day of month> <month> <day of week> <duration>"
+func ParseWindow(expr string) (*Window, error) {
+	tokens := strings.Fields(expr)
+	if len(tokens) != windowFields {
+		return nil, errors.Wrapf(ErrInvalidWindow, "%q: expected %d cron fields and a duration", expr, cronFields)
+	}
+	w := &Window{expr: expr}
+	for i := 0; i < cronFields; i++ {
+		values, wildcard, err := parseField(tokens[i], fieldBounds[i][0], fieldBounds[i][1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q: field %d", expr, i+1)
+		}
+		w.fields[i], w.wildcard[i] = values, wildcard
+	}
+	// day of week 7 is Sunday
+	if w.fields[4][7] {
+		w.fields[4][0] = true
+	}
+	duration, err := time.ParseDuration(tokens[cronFields])
+	if err != nil || duration < time.Minute {
+		return nil, errors.Wrapf(ErrInvalidWindow, "%q: duration must be at least 1m", expr)
+	}
+	w.duration = duration
+	return w, nil
+}
+
+// ParseWindows parses a list of maintenance windows
+func ParseWindows(exprs []string) (Windows, error) {
+	windows := make(Windows, 0, len(exprs))
+	for _, expr := range exprs {
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+		w, err := ParseWindow(expr)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseField parses a cron field: "*", "5", "1-5", "*/15", "1-30/5" and comma separated lists of those
+func parseField(field string, minValue, maxValue int) (map[int]bool, bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, false, errors.Wrapf(ErrInvalidWindow, "invalid step in %q", part)
+			}
+			rng = part[:i]
+		}
+		low, high := minValue, maxValue
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2) //nolint:gomnd
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, false, errors.Wrapf(ErrInvalidWindow, "invalid value in %q", part)
+			}
+			high = low
+			if len(bounds) == 2 { //nolint:gomnd
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, false, errors.Wrapf(ErrInvalidWindow, "invalid value in %q", part)
+				}
+			} else if step > 1 {
+				high = maxValue
+			}
+		}
+		if low < minValue || high > maxValue || low > high {
+			return nil, false, errors.Wrapf(ErrInvalidWindow, "%q out of range %d-%d", part, minValue, maxValue)
+		}
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+	return values, field == "*", nil
+}
+
+// opens checks if the cron expression fires at the given minute
+func (w *Window) opens(t time.Time) bool {
+	if !w.fields[0][t.Minute()] || !w.fields[1][t.Hour()] || !w.fields[3][int(t.Month())] {
+		return false
+	}
+	dom, dow := w.fields[2][t.Day()], w.fields[4][int(t.Weekday())]
+	// cron semantics: when both day of month and day of week are restricted, either may match
+	if !w.wildcard[2] && !w.wildcard[4] {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Contains checks if the window is open at the given time
+func (w *Window) Contains(t time.Time) bool {
+	start := t.Truncate(time.Minute)
+	for s := start; t.Sub(s) < w.duration; s = s.Add(-time.Minute) {
+		if w.opens(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Window) String() string {
+	return w.expr
+}
+
+// Contains checks if any window is open at the given time; no windows means always open
+func (ws Windows) Contains(t time.Time) bool {
+	if len(ws) == 0 {
+		return true
+	}
+	for _, w := range ws {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
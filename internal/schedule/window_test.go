@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "every saturday", expr: "0 2 * * 6 4h"},
+		{name: "lists ranges and steps", expr: "*/15 1-5,22 1,15 * 1-5 30m"},
+		{name: "sunday as 7", expr: "0 0 * * 7 1h"},
+		{name: "missing duration", expr: "0 2 * * 6", wantErr: true},
+		{name: "invalid duration", expr: "0 2 * * 6 forever", wantErr: true},
+		{name: "duration too short", expr: "0 2 * * 6 30s", wantErr: true},
+		{name: "minute out of range", expr: "60 2 * * 6 1h", wantErr: true},
+		{name: "reversed range", expr: "0 5-2 * * * 1h", wantErr: true},
+		{name: "invalid step", expr: "*/0 2 * * * 1h", wantErr: true},
+		{name: "not a number", expr: "0 two * * * 1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWindow(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWindows_Contains(t *testing.T) {
+	// 2024-03-16 is a Saturday
+	saturday := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 16, hour, minute, 30, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		exprs []string
+		t     time.Time
+		want  bool
+	}{
+		{name: "no windows", t: saturday(12, 0), want: true},
+		{name: "window start", exprs: []string{"0 2 * * 6 4h"}, t: saturday(2, 0), want: true},
+		{name: "inside window", exprs: []string{"0 2 * * 6 4h"}, t: saturday(5, 59), want: true},
+		{name: "window end", exprs: []string{"0 2 * * 6 4h"}, t: saturday(6, 0), want: false},
+		{name: "before window", exprs: []string{"0 2 * * 6 4h"}, t: saturday(1, 59), want: false},
+		{name: "other day", exprs: []string{"0 2 * * 0 4h"}, t: saturday(3, 0), want: false},
+		{name: "window across midnight", exprs: []string{"0 22 * * 5 4h"}, t: saturday(1, 0), want: true},
+		{name: "second window", exprs: []string{"0 2 * * 0 1h", "0 12 * * * 1h"}, t: saturday(12, 30), want: true},
+		{name: "day of month or day of week", exprs: []string{"0 12 1 * 6 1h"}, t: saturday(12, 30), want: true},
+		{name: "day of month and any day of week", exprs: []string{"0 12 1 * * 1h"}, t: saturday(12, 30), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := ParseWindows(tt.exprs)
+			if err != nil {
+				t.Fatalf("ParseWindows() error = %v", err)
+			}
+			if got := windows.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	kafkaContentType = "application/vnd.kafka.json.v2+json"
+	kafkaTimeout     = 30 * time.Second
+	maxErrorBody     = 512
+)
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value *Event `json:"value"`
+}
+
+type kafkaSink struct {
+	client *http.Client
+	url    string
+	logger *logrus.Entry
+}
+
+// NewKafkaRESTProxySink returns a sink producing events to a Kafka topic through the Confluent REST Proxy (v2 API); the
+// brokers are not reached directly, the REST Proxy is required; the record key is the node name, keeping the events of a
+// node ordered in a partition
+func NewKafkaRESTProxySink(logger *logrus.Entry, cfg *config.Config) (Sink, error) {
+	if cfg.SinkURL == "" || cfg.SinkTopic == "" {
+		return nil, errors.New("REST Proxy URL and topic are required for the Kafka REST Proxy event sink")
+	}
+	return &kafkaSink{
+		client: &http.Client{Timeout: kafkaTimeout},
+		url:    strings.TrimSuffix(cfg.SinkURL, "/") + "/topics/" + url.PathEscape(cfg.SinkTopic),
+		logger: logger,
+	}, nil
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"records": []kafkaRecord{{Key: event.Node, Value: event}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal Kafka records")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to create Kafka REST Proxy request")
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to produce event to Kafka")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return errors.Errorf("failed to produce event to Kafka: %s: %s", resp.Status, string(body))
+	}
+
+	// the REST Proxy reports per record errors with status 200
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return errors.Wrap(err, "failed to decode Kafka REST Proxy response")
+	}
+	for _, offset := range result.Offsets {
+		if offset.Error != "" {
+			return errors.Errorf("failed to produce event to Kafka: %s", offset.Error)
+		}
+	}
+	s.logger.WithFields(logrus.Fields{
+		"event": event.Type,
+		"node":  event.Node,
+	}).Debug("event produced to Kafka")
+	return nil
+}
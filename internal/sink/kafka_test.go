@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvent = &Event{
+	Type:    EventAssigned,
+	Time:    time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	Cluster: "test-cluster",
+	Node:    "node-1",
+	Address: "1.1.1.1",
+}
+
+func TestKafkaSink_Publish(t *testing.T) {
+	var records []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/kafka/topics/kubeip-events", r.URL.Path)
+		assert.Equal(t, kafkaContentType, r.Header.Get("Content-Type"))
+		var body struct {
+			Records []map[string]interface{} `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		records = body.Records
+		if body.Records[0]["key"] == "fail" {
+			_, _ = w.Write([]byte(`{"offsets":[{"error_code":40403,"error":"topic not authorized"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"offsets":[{"partition":0,"offset":42}]}`))
+	}))
+	defer server.Close()
+
+	s, err := NewKafkaRESTProxySink(logrus.NewEntry(logrus.New()), &config.Config{SinkURL: server.URL + "/kafka/", SinkTopic: "kubeip-events"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Publish(context.Background(), testEvent))
+	require.Len(t, records, 1)
+	assert.Equal(t, "node-1", records[0]["key"])
+	assert.Equal(t, map[string]interface{}{
+		"type":    "assigned",
+		"time":    "2024-03-01T10:00:00Z",
+		"cluster": "test-cluster",
+		"node":    "node-1",
+		"address": "1.1.1.1",
+	}, records[0]["value"])
+
+	assert.ErrorContains(t, s.Publish(context.Background(), &Event{Type: EventFailed, Node: "fail"}), "topic not authorized")
+}
+
+func TestNewKafkaRESTProxySink(t *testing.T) {
+	_, err := NewKafkaRESTProxySink(logrus.NewEntry(logrus.New()), &config.Config{SinkURL: "http://rest-proxy:8082"})
+	assert.Error(t, err)
+}
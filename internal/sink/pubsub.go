@@ -0,0 +1,78 @@
+package sink
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/doitintl/kubeip/internal/cloud"
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+type pubSubPublisher interface {
+	Publish(ctx context.Context, topic string, message *pubsub.PubsubMessage) error
+}
+
+type pubSubService struct {
+	service *pubsub.Service
+}
+
+func (s *pubSubService) Publish(ctx context.Context, topic string, message *pubsub.PubsubMessage) error {
+	_, err := s.service.Projects.Topics.Publish(topic, &pubsub.PublishRequest{
+		Messages: []*pubsub.PubsubMessage{message},
+	}).Context(ctx).Do()
+	return err //nolint:wrapcheck
+}
+
+type pubSubSink struct {
+	publisher pubSubPublisher
+	topic     string
+	logger    *logrus.Entry
+}
+
+// NewPubSubSink returns a sink publishing events to a Google Cloud Pub/Sub topic (projects/<project>/topics/<name>)
+func NewPubSubSink(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Sink, error) {
+	if cfg.SinkTopic == "" {
+		return nil, errors.New("topic is required for the Pub/Sub event sink")
+	}
+	opts, err := cloud.GCPClientOptions(ctx, cfg)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	service, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize Google Cloud Pub/Sub client")
+	}
+	return &pubSubSink{
+		publisher: &pubSubService{service: service},
+		topic:     cfg.SinkTopic,
+		logger:    logger,
+	}, nil
+}
+
+func (s *pubSubSink) Publish(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+	// attributes allow subscription filters without decoding the data
+	err = s.publisher.Publish(ctx, s.topic, &pubsub.PubsubMessage{
+		Data: base64.StdEncoding.EncodeToString(data),
+		Attributes: map[string]string{
+			"type":    event.Type,
+			"node":    event.Node,
+			"cluster": event.Cluster,
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to publish event to %s", s.topic)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"event": event.Type,
+		"node":  event.Node,
+	}).Debug("event published to Pub/Sub")
+	return nil
+}
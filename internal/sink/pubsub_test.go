@@ -0,0 +1,40 @@
+package sink
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+type fakePublisher struct {
+	topic    string
+	messages []*pubsub.PubsubMessage
+}
+
+func (f *fakePublisher) Publish(_ context.Context, topic string, message *pubsub.PubsubMessage) error {
+	f.topic = topic
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+func TestPubSubSink_Publish(t *testing.T) {
+	publisher := &fakePublisher{}
+	s := &pubSubSink{publisher: publisher, topic: "projects/p/topics/kubeip", logger: logrus.NewEntry(logrus.New())}
+
+	require.NoError(t, s.Publish(context.Background(), testEvent))
+	assert.Equal(t, "projects/p/topics/kubeip", publisher.topic)
+	require.Len(t, publisher.messages, 1)
+	assert.Equal(t, map[string]string{"type": "assigned", "node": "node-1", "cluster": "test-cluster"}, publisher.messages[0].Attributes)
+
+	data, err := base64.StdEncoding.DecodeString(publisher.messages[0].Data)
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(data, &event))
+	assert.Equal(t, *testEvent, event)
+}
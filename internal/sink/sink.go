@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProviderKafkaRESTProxy = "kafka-rest-proxy"
+	ProviderPubSub         = "pubsub"
+	ProviderWebhook        = "webhook"
+)
+
+// event types of the assignment lifecycle
+const (
+	EventAssigned = "assigned"
+	EventReleased = "released"
+	EventFailed   = "failed"
+	// EventBlocked reports an assignment failed with an error retrying cannot fix, left blocked until the agent restarts
+	EventBlocked = "blocked"
+	// EventNonPool reports a static public IP address held by the node outside the pool, with the result of the policy
+	EventNonPool = "non_pool"
+	// EventQuotaExhausted reports a cloud provider quota of the static public IP addresses left without room
+	EventQuotaExhausted = "quota_exhausted"
+)
+
+var ErrUnknownProvider = errors.New("unknown event sink provider")
+
+// Event is an assignment lifecycle event
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Cluster  string    `json:"cluster,omitempty"`
+	Node     string    `json:"node"`
+	Instance string    `json:"instance,omitempty"`
+	Cloud    string    `json:"cloud,omitempty"`
+	Pool     string    `json:"pool,omitempty"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Address  string    `json:"address,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Result is the result of the non-pool address policy (kept, replaced, refused) of non_pool events
+	Result string `json:"result,omitempty"`
+	// Quota is the exhausted quota of quota_exhausted events, with its limit
+	Quota      string  `json:"quota,omitempty"`
+	QuotaLimit float64 `json:"quotaLimit,omitempty"`
+}
+
+// Sink streams assignment lifecycle events to a data platform
+type Sink interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// NewSink returns the sink of the configured provider or nil if the event sink is disabled
+func NewSink(ctx context.Context, logger *logrus.Entry, cfg *config.Config) (Sink, error) {
+	switch cfg.SinkProvider {
+	case "":
+		return nil, nil //nolint:nilnil
+	case ProviderKafkaRESTProxy:
+		return NewKafkaRESTProxySink(logger, cfg)
+	case ProviderPubSub:
+		return NewPubSubSink(ctx, logger, cfg)
+	case ProviderWebhook:
+		return NewWebhookSink(logger, cfg)
+	}
+	return nil, errors.Wrapf(ErrUnknownProvider, "%s, supported providers: %s, %s, %s", cfg.SinkProvider, ProviderKafkaRESTProxy, ProviderPubSub, ProviderWebhook)
+}
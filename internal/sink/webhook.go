@@ -0,0 +1,137 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const webhookTimeout = 30 * time.Second
+
+// templateFuncs are the functions available in payload templates
+var templateFuncs = template.FuncMap{
+	// json encodes the value as JSON, e.g. a quoted and escaped string
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err //nolint:wrapcheck
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+type webhookSink struct {
+	client   *http.Client
+	url      string
+	headers  map[string]string
+	template *template.Template
+	logger   *logrus.Entry
+}
+
+// NewWebhookSink returns a sink posting events to a webhook; the payload is the JSON event or rendered from a Go template
+// over the event, so events can be posted directly into ServiceNow or other CMDB APIs
+func NewWebhookSink(logger *logrus.Entry, cfg *config.Config) (Sink, error) {
+	if cfg.SinkURL == "" {
+		return nil, errors.New("URL is required for the webhook event sink")
+	}
+	tmpl, err := parseTemplate(cfg.SinkTemplate, cfg.SinkTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := parseHeaders(cfg.SinkHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookSink{
+		client:   &http.Client{Timeout: webhookTimeout},
+		url:      cfg.SinkURL,
+		headers:  headers,
+		template: tmpl,
+		logger:   logger,
+	}, nil
+}
+
+// parseTemplate returns the payload template (inline or from file) or nil for the JSON event payload
+func parseTemplate(text, file string) (*template.Template, error) {
+	if text != "" && file != "" {
+		return nil, errors.New("payload template and template file are mutually exclusive")
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read payload template file %s", file)
+		}
+		text = string(data)
+	}
+	if text == "" {
+		return nil, nil //nolint:nilnil
+	}
+	tmpl, err := template.New("payload").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse payload template")
+	}
+	return tmpl, nil
+}
+
+// parseHeaders parses "Name: value" headers
+func parseHeaders(headers []string) (map[string]string, error) {
+	parsed := map[string]string{"Content-Type": "application/json"}
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, errors.Errorf("invalid header %q, expected Name: value", header)
+		}
+		parsed[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return parsed, nil
+}
+
+// payload returns the rendered template or the JSON event
+func (s *webhookSink) payload(event *Event) ([]byte, error) {
+	if s.template == nil {
+		data, err := json.Marshal(event)
+		return data, errors.Wrap(err, "failed to marshal event")
+	}
+	var buf bytes.Buffer
+	if err := s.template.Execute(&buf, event); err != nil {
+		return nil, errors.Wrap(err, "failed to render payload template")
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *webhookSink) Publish(ctx context.Context, event *Event) error {
+	data, err := s.payload(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to create webhook request")
+	}
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to post event to webhook")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return errors.Errorf("failed to post event to webhook: %s: %s", resp.Status, string(body))
+	}
+	s.logger.WithFields(logrus.Fields{
+		"event": event.Type,
+		"node":  event.Node,
+	}).Debug("event posted to webhook")
+	return nil
+}
@@ -0,0 +1,91 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serviceNowTemplate renders a ServiceNow Table API record
+const serviceNowTemplate = `{"u_node":{{json .Node}},"u_ip_address":{{json .Address}},` +
+	`"u_state":{{json (upper .Type)}},"u_cluster":{{json .Cluster}},"u_time":{{json .Time}}}`
+
+func newTestWebhook(t *testing.T, cfg *config.Config) (Sink, *string, *http.Header) {
+	t.Helper()
+	var body string
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		headers = r.Header
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+	cfg.SinkURL = server.URL + "/api/now/table/u_kubeip"
+	s, err := NewWebhookSink(logrus.NewEntry(logrus.New()), cfg)
+	require.NoError(t, err)
+	return s, &body, &headers
+}
+
+func TestWebhookSink_Publish(t *testing.T) {
+	t.Run("JSON event", func(t *testing.T) {
+		s, body, headers := newTestWebhook(t, &config.Config{})
+		require.NoError(t, s.Publish(context.Background(), testEvent))
+		assert.JSONEq(t, `{"type":"assigned","time":"2024-03-01T10:00:00Z","cluster":"test-cluster","node":"node-1","address":"1.1.1.1"}`, *body)
+		assert.Equal(t, "application/json", headers.Get("Content-Type"))
+	})
+	t.Run("template", func(t *testing.T) {
+		s, body, headers := newTestWebhook(t, &config.Config{
+			SinkTemplate: serviceNowTemplate,
+			SinkHeaders:  []string{"authorization: Bearer token", "Accept: application/json"},
+		})
+		require.NoError(t, s.Publish(context.Background(), testEvent))
+		assert.JSONEq(t, `{"u_node":"node-1","u_ip_address":"1.1.1.1","u_state":"ASSIGNED","u_cluster":"test-cluster","u_time":"2024-03-01T10:00:00Z"}`, *body)
+		assert.Equal(t, "Bearer token", headers.Get("Authorization"))
+	})
+	t.Run("template file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "payload.tmpl")
+		require.NoError(t, os.WriteFile(file, []byte(`node={{.Node}} error={{.Error}}`), 0o600))
+		s, body, _ := newTestWebhook(t, &config.Config{SinkTemplateFile: file, SinkHeaders: []string{"Content-Type: text/plain"}})
+		require.NoError(t, s.Publish(context.Background(), &Event{Type: EventFailed, Node: "node-1", Error: "no available addresses"}))
+		assert.Equal(t, "node=node-1 error=no available addresses", *body)
+	})
+}
+
+func TestWebhookSink_PublishError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "invalid table", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	s, err := NewWebhookSink(logrus.NewEntry(logrus.New()), &config.Config{SinkURL: server.URL})
+	require.NoError(t, err)
+	assert.ErrorContains(t, s.Publish(context.Background(), testEvent), "invalid table")
+}
+
+func TestNewWebhookSink(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{"missing URL", &config.Config{}},
+		{"invalid template", &config.Config{SinkURL: "http://cmdb", SinkTemplate: "{{.Node"}},
+		{"template and file", &config.Config{SinkURL: "http://cmdb", SinkTemplate: "{{.Node}}", SinkTemplateFile: "payload.tmpl"}},
+		{"missing template file", &config.Config{SinkURL: "http://cmdb", SinkTemplateFile: "/nonexistent/payload.tmpl"}},
+		{"invalid header", &config.Config{SinkURL: "http://cmdb", SinkHeaders: []string{"Authorization"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWebhookSink(logrus.NewEntry(logrus.New()), tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package snat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	BackendIPTables = "iptables"
+	BackendNFTables = "nftables"
+	// chain is the iptables chain of the SNAT rules, jumped to first from POSTROUTING
+	chain = "KUBEIP-SNAT"
+	// table is the nftables table of the SNAT rules
+	table = "kubeip"
+)
+
+var (
+	ErrUnknownBackend = errors.New("unknown SNAT backend")
+	// ErrNoSourceCIDRs is returned when SNAT is enabled without the CIDRs of the pod egress traffic
+	ErrNoSourceCIDRs = errors.New("SNAT requires the source CIDRs of the pod egress traffic")
+)
+
+// Programmer programs the SNAT rules of the node translating the pod egress traffic to the address assigned to the node,
+// for clusters without a CNI egress gateway
+type Programmer interface {
+	// Apply replaces the SNAT rules with rules translating to the address
+	Apply(ctx context.Context, address string) error
+	// Remove deletes the SNAT rules of the address
+	Remove(ctx context.Context, address string) error
+}
+
+// runner runs the command with the input and returns its combined output
+type runner func(ctx context.Context, input string, name string, args ...string) ([]byte, error)
+
+func run(ctx context.Context, input, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.Bytes(), err //nolint:wrapcheck
+}
+
+type rules struct {
+	logger   *logrus.Entry
+	run      runner
+	sources  []string
+	excludes []string
+	oif      string
+}
+
+// NewProgrammer returns the programmer of the configured SNAT backend or nil if SNAT is disabled
+func NewProgrammer(logger *logrus.Entry, cfg *config.Config) (Programmer, error) {
+	if cfg.SNATBackend == "" {
+		return nil, nil //nolint:nilnil
+	}
+	if len(cfg.SNATSourceCIDRs) == 0 {
+		return nil, ErrNoSourceCIDRs
+	}
+	for _, cidr := range append(append([]string{}, cfg.SNATSourceCIDRs...), cfg.SNATExcludeCIDRs...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, errors.Wrapf(err, "invalid SNAT CIDR %s", cidr)
+		}
+	}
+	r := rules{logger: logger, run: run, sources: cfg.SNATSourceCIDRs, excludes: cfg.SNATExcludeCIDRs, oif: cfg.SNATInterface}
+	switch cfg.SNATBackend {
+	case BackendIPTables:
+		return &iptables{rules: r}, nil
+	case BackendNFTables:
+		return &nftables{rules: r}, nil
+	}
+	return nil, errors.Wrapf(ErrUnknownBackend, "%s, supported backends: %s, %s", cfg.SNATBackend, BackendIPTables, BackendNFTables)
+}
+
+// family returns the CIDRs of the address family of the address
+func family(cidrs []string, ipv6 bool) []string {
+	var matching []string
+	for _, cidr := range cidrs {
+		ip, _, _ := net.ParseCIDR(cidr)
+		if (ip.To4() == nil) == ipv6 {
+			matching = append(matching, cidr)
+		}
+	}
+	return matching
+}
+
+func isIPv6(address string) (bool, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false, errors.Errorf("invalid address %s", address)
+	}
+	return ip.To4() == nil, nil
+}
+
+// iptables programs the rules in the KUBEIP-SNAT chain of the nat table (ip6tables for IPv6 addresses)
+type iptables struct {
+	rules
+}
+
+func (t *iptables) command(ipv6 bool) string {
+	if ipv6 {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+func (t *iptables) exec(ctx context.Context, ipv6 bool, args ...string) error {
+	command := t.command(ipv6)
+	if out, err := t.run(ctx, "", command, append([]string{"-t", "nat"}, args...)...); err != nil {
+		return errors.Wrapf(err, "%s %s: %s", command, strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// specs returns the rules of the chain in the order of the -S listing: excluded destinations first
+func (t *iptables) specs(address string, ipv6 bool) [][]string {
+	var specs [][]string
+	for _, cidr := range family(t.excludes, ipv6) {
+		specs = append(specs, []string{"-A", chain, "-d", cidr, "-j", "RETURN"})
+	}
+	for _, cidr := range family(t.sources, ipv6) {
+		spec := []string{"-A", chain, "-s", cidr}
+		if t.oif != "" {
+			spec = append(spec, "-o", t.oif)
+		}
+		specs = append(specs, append(spec, "-j", "SNAT", "--to-source", address))
+	}
+	return specs
+}
+
+// applied checks if the chain holds exactly the rules and POSTROUTING jumps to it: flushing the chain of an agent
+// restarted on a node already translating to the address would drop the pod egress traffic until the rules are back
+func (t *iptables) applied(ctx context.Context, ipv6 bool, specs [][]string) bool {
+	out, err := t.run(ctx, "", t.command(ipv6), "-t", "nat", "-S", chain)
+	if err != nil {
+		return false
+	}
+	want := []string{"-N " + chain}
+	for _, spec := range specs {
+		want = append(want, strings.Join(spec, " "))
+	}
+	got := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		return false
+	}
+	return t.exec(ctx, ipv6, "-C", "POSTROUTING", "-j", chain) == nil
+}
+
+func (t *iptables) Apply(ctx context.Context, address string) error {
+	ipv6, err := isIPv6(address)
+	if err != nil {
+		return err
+	}
+	specs := t.specs(address, ipv6)
+	if t.applied(ctx, ipv6, specs) {
+		t.logger.WithFields(logrus.Fields{"address": address, "chain": chain}).Debug("SNAT rules already applied")
+		return nil
+	}
+	// create or flush the chain, then add the rules
+	if t.exec(ctx, ipv6, "-N", chain) != nil {
+		if err = t.exec(ctx, ipv6, "-F", chain); err != nil {
+			return err
+		}
+	}
+	for _, spec := range specs {
+		if err = t.exec(ctx, ipv6, spec...); err != nil {
+			return err
+		}
+	}
+	// jump to the chain before the masquerading rules of the CNI
+	if t.exec(ctx, ipv6, "-C", "POSTROUTING", "-j", chain) != nil {
+		if err = t.exec(ctx, ipv6, "-I", "POSTROUTING", "1", "-j", chain); err != nil {
+			return err
+		}
+	}
+	t.logger.WithFields(logrus.Fields{"address": address, "chain": chain}).Info("SNAT rules applied")
+	return nil
+}
+
+func (t *iptables) Remove(ctx context.Context, address string) error {
+	ipv6, err := isIPv6(address)
+	if err != nil {
+		return err
+	}
+	// nothing to remove without the chain
+	if t.exec(ctx, ipv6, "-C", "POSTROUTING", "-j", chain) == nil {
+		if err = t.exec(ctx, ipv6, "-D", "POSTROUTING", "-j", chain); err != nil {
+			return err
+		}
+	}
+	if t.exec(ctx, ipv6, "-F", chain) != nil {
+		return nil
+	}
+	if err = t.exec(ctx, ipv6, "-X", chain); err != nil {
+		return err
+	}
+	t.logger.WithFields(logrus.Fields{"address": address, "chain": chain}).Info("SNAT rules removed")
+	return nil
+}
+
+// nftables programs the rules in the kubeip table (ip or ip6 family), replaced atomically by nft -f
+type nftables struct {
+	rules
+}
+
+// family returns the nftables family of the table, also the protocol of the address matches
+func (t *nftables) family(ipv6 bool) string {
+	if ipv6 {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// script returns the nft script replacing the kubeip table with the SNAT rules to the address
+func (t *nftables) script(address string, ipv6 bool) string {
+	fam := t.family(ipv6)
+	var b strings.Builder
+	// declare the table before deleting it: deleting a missing table fails
+	fmt.Fprintf(&b, "table %s %s\ndelete table %s %s\n", fam, table, fam, table)
+	fmt.Fprintf(&b, "table %s %s {\n\tchain postrouting {\n\t\ttype nat hook postrouting priority srcnat - 10; policy accept;\n", fam, table)
+	for _, cidr := range family(t.excludes, ipv6) {
+		fmt.Fprintf(&b, "\t\t%s daddr %s return\n", fam, cidr)
+	}
+	oif := ""
+	if t.oif != "" {
+		oif = fmt.Sprintf(" oifname %q", t.oif)
+	}
+	for _, cidr := range family(t.sources, ipv6) {
+		fmt.Fprintf(&b, "\t\t%s saddr %s%s snat to %s\n", fam, cidr, oif, address)
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+func (t *nftables) Apply(ctx context.Context, address string) error {
+	ipv6, err := isIPv6(address)
+	if err != nil {
+		return err
+	}
+	if out, err := t.run(ctx, t.script(address, ipv6), "nft", "-f", "-"); err != nil {
+		return errors.Wrapf(err, "nft -f: %s", strings.TrimSpace(string(out)))
+	}
+	t.logger.WithFields(logrus.Fields{"address": address, "table": table}).Info("SNAT rules applied")
+	return nil
+}
+
+func (t *nftables) Remove(ctx context.Context, address string) error {
+	ipv6, err := isIPv6(address)
+	if err != nil {
+		return err
+	}
+	fam := t.family(ipv6)
+	script := fmt.Sprintf("table %s %s\ndelete table %s %s\n", fam, table, fam, table)
+	if out, err := t.run(ctx, script, "nft", "-f", "-"); err != nil {
+		return errors.Wrapf(err, "nft -f: %s", strings.TrimSpace(string(out)))
+	}
+	t.logger.WithFields(logrus.Fields{"address": address, "table": table}).Info("SNAT rules removed")
+	return nil
+}
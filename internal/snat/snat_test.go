@@ -0,0 +1,152 @@
+package snat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/doitintl/kubeip/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner records the commands; the commands with a failing prefix fail, the commands with an output prefix return
+// the output
+type fakeRunner struct {
+	commands []string
+	inputs   []string
+	failing  []string
+	outputs  map[string]string
+}
+
+func (f *fakeRunner) run(_ context.Context, input, name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.commands = append(f.commands, command)
+	f.inputs = append(f.inputs, input)
+	for _, prefix := range f.failing {
+		if strings.HasPrefix(command, prefix) {
+			return []byte("iptables: No chain/target/match by that name."), errors.New("exit status 1")
+		}
+	}
+	for prefix, out := range f.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return []byte(out), nil
+		}
+	}
+	return nil, nil
+}
+
+func newRules(f *fakeRunner) rules {
+	return rules{
+		logger:   logrus.NewEntry(logrus.New()),
+		run:      f.run,
+		sources:  []string{"10.8.0.0/24", "fd00:8::/64"},
+		excludes: []string{"10.0.0.0/8"},
+		oif:      "eth0",
+	}
+}
+
+func TestNewProgrammer(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	programmer, err := NewProgrammer(logger, &config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, programmer)
+
+	_, err = NewProgrammer(logger, &config.Config{SNATBackend: BackendIPTables})
+	assert.ErrorIs(t, err, ErrNoSourceCIDRs)
+	_, err = NewProgrammer(logger, &config.Config{SNATBackend: BackendIPTables, SNATSourceCIDRs: []string{"10.8.0.0"}})
+	assert.Error(t, err)
+	_, err = NewProgrammer(logger, &config.Config{SNATBackend: "pf", SNATSourceCIDRs: []string{"10.8.0.0/24"}})
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+
+	programmer, err = NewProgrammer(logger, &config.Config{SNATBackend: BackendNFTables, SNATSourceCIDRs: []string{"10.8.0.0/24"}})
+	require.NoError(t, err)
+	assert.IsType(t, &nftables{}, programmer)
+}
+
+func TestIPTables(t *testing.T) {
+	ctx := context.Background()
+	// the chain and the jump are missing
+	f := &fakeRunner{failing: []string{"iptables -t nat -S", "iptables -t nat -F KUBEIP-SNAT", "iptables -t nat -C"}}
+	programmer := &iptables{rules: newRules(f)}
+	require.NoError(t, programmer.Apply(ctx, "203.0.113.10"))
+	assert.Equal(t, []string{
+		"iptables -t nat -S KUBEIP-SNAT",
+		"iptables -t nat -N KUBEIP-SNAT",
+		"iptables -t nat -A KUBEIP-SNAT -d 10.0.0.0/8 -j RETURN",
+		"iptables -t nat -A KUBEIP-SNAT -s 10.8.0.0/24 -o eth0 -j SNAT --to-source 203.0.113.10",
+		"iptables -t nat -C POSTROUTING -j KUBEIP-SNAT",
+		"iptables -t nat -I POSTROUTING 1 -j KUBEIP-SNAT",
+	}, f.commands)
+
+	// the chain exists with other rules: it is flushed, the jump is kept
+	f = &fakeRunner{failing: []string{"ip6tables -t nat -N"}, outputs: map[string]string{
+		"ip6tables -t nat -S": "-N KUBEIP-SNAT\n-A KUBEIP-SNAT -s fd00:8::/64 -o eth0 -j SNAT --to-source 2001:db8::9\n",
+	}}
+	programmer = &iptables{rules: newRules(f)}
+	require.NoError(t, programmer.Apply(ctx, "2001:db8::10"))
+	assert.Equal(t, []string{
+		"ip6tables -t nat -S KUBEIP-SNAT",
+		"ip6tables -t nat -N KUBEIP-SNAT",
+		"ip6tables -t nat -F KUBEIP-SNAT",
+		"ip6tables -t nat -A KUBEIP-SNAT -s fd00:8::/64 -o eth0 -j SNAT --to-source 2001:db8::10",
+		"ip6tables -t nat -C POSTROUTING -j KUBEIP-SNAT",
+	}, f.commands)
+
+	// the rules are already applied, e.g. after a restart of the agent: nothing is flushed
+	f = &fakeRunner{outputs: map[string]string{
+		"iptables -t nat -S": "-N KUBEIP-SNAT\n" +
+			"-A KUBEIP-SNAT -d 10.0.0.0/8 -j RETURN\n" +
+			"-A KUBEIP-SNAT -s 10.8.0.0/24 -o eth0 -j SNAT --to-source 203.0.113.10\n",
+	}}
+	programmer = &iptables{rules: newRules(f)}
+	require.NoError(t, programmer.Apply(ctx, "203.0.113.10"))
+	assert.Equal(t, []string{
+		"iptables -t nat -S KUBEIP-SNAT",
+		"iptables -t nat -C POSTROUTING -j KUBEIP-SNAT",
+	}, f.commands)
+
+	f = &fakeRunner{}
+	programmer = &iptables{rules: newRules(f)}
+	require.NoError(t, programmer.Remove(ctx, "203.0.113.10"))
+	assert.Equal(t, []string{
+		"iptables -t nat -C POSTROUTING -j KUBEIP-SNAT",
+		"iptables -t nat -D POSTROUTING -j KUBEIP-SNAT",
+		"iptables -t nat -F KUBEIP-SNAT",
+		"iptables -t nat -X KUBEIP-SNAT",
+	}, f.commands)
+
+	// nothing to remove
+	f = &fakeRunner{failing: []string{"iptables -t nat -C", "iptables -t nat -F"}}
+	programmer = &iptables{rules: newRules(f)}
+	require.NoError(t, programmer.Remove(ctx, "203.0.113.10"))
+	assert.Len(t, f.commands, 2)
+
+	assert.Error(t, programmer.Apply(ctx, "not-an-address"))
+}
+
+func TestNFTables(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeRunner{}
+	programmer := &nftables{rules: newRules(f)}
+	require.NoError(t, programmer.Apply(ctx, "203.0.113.10"))
+	assert.Equal(t, []string{"nft -f -"}, f.commands)
+	assert.Equal(t, `table ip kubeip
+delete table ip kubeip
+table ip kubeip {
+	chain postrouting {
+		type nat hook postrouting priority srcnat - 10; policy accept;
+		ip daddr 10.0.0.0/8 return
+		ip saddr 10.8.0.0/24 oifname "eth0" snat to 203.0.113.10
+	}
+}
+`, f.inputs[0])
+
+	require.NoError(t, programmer.Remove(ctx, "2001:db8::10"))
+	assert.Equal(t, "table ip6 kubeip\ndelete table ip6 kubeip\n", f.inputs[1])
+
+	f.failing = []string{"nft"}
+	assert.Error(t, programmer.Apply(ctx, "203.0.113.10"))
+}
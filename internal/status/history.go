@@ -0,0 +1,82 @@
+package status
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+)
+
+// Holder is a node holding an address from Since until Until (zero while still held)
+type Holder struct {
+	Node  string    `json:"node"`
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until,omitempty"`
+}
+
+// HoldersAt returns the nodes whose recorded history shows the address held at the given time; the history of a node
+// being bounded, a time before its oldest transition is unknown and the node is not reported
+func HoldersAt(statuses []types.AssignmentStatus, address string, at time.Time) []Holder {
+	var holders []Holder
+	for i := range statuses {
+		history := statuses[i].History
+		// the last transition at or before the time gives the address held at the time
+		last := -1
+		for j := range history {
+			if !history[j].Time.After(at) && (last < 0 || !history[j].Time.Before(history[last].Time)) {
+				last = j
+			}
+		}
+		if last < 0 || history[last].Address != address {
+			continue
+		}
+		holder := Holder{Node: statuses[i].Node, Since: history[last].Time}
+		for j := range history {
+			if history[j].Time.After(at) && history[j].Address != address && (holder.Until.IsZero() || history[j].Time.Before(holder.Until)) {
+				holder.Until = history[j].Time
+			}
+		}
+		holders = append(holders, holder)
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i].Node < holders[j].Node })
+	return holders
+}
+
+// PrintHistory writes the transitions of the nodes to the writer in the given format: table, json or yaml
+func PrintHistory(w io.Writer, statuses []types.AssignmentStatus, format string) error {
+	if format != FormatTable && format != "" {
+		return Print(w, statuses, format)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:gomnd
+	fmt.Fprintln(tw, "NODE\tTIME\tADDRESS\tPOOL\tERROR")
+	for i := range statuses {
+		for _, t := range statuses[i].History {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", statuses[i].Node, t.Time.Format(time.RFC3339), orNone(t.Address), orNone(t.Pool), orNone(t.Error))
+		}
+	}
+	return errors.Wrap(tw.Flush(), "failed to write history table")
+}
+
+// PrintHolders writes the holders of an address to the writer in the given format: table, json or yaml
+func PrintHolders(w io.Writer, holders []Holder, format string) error {
+	switch format {
+	case FormatTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:gomnd
+		fmt.Fprintln(tw, "NODE\tSINCE\tUNTIL")
+		for _, h := range holders {
+			until := ""
+			if !h.Until.IsZero() {
+				until = h.Until.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", h.Node, h.Since.Format(time.RFC3339), orNone(until))
+		}
+		return errors.Wrap(tw.Flush(), "failed to write holders table")
+	case FormatJSON, FormatYAML:
+		return marshal(w, holders, format)
+	}
+	return errors.Wrapf(ErrUnknownFormat, "%s, supported formats: table, json, yaml", format)
+}
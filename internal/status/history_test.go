@@ -0,0 +1,62 @@
+package status
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/types"
+)
+
+func TestHoldersAt(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 3, 1, hour, 0, 0, 0, time.UTC) }
+	statuses := []types.AssignmentStatus{
+		{Node: "node-1", History: []types.AssignmentTransition{
+			{Time: at(10), Address: "1.1.1.1"},
+			{Time: at(12)},
+		}},
+		{Node: "node-2", History: []types.AssignmentTransition{
+			{Time: at(11), Error: "no available addresses"},
+			{Time: at(13), Address: "1.1.1.1"},
+		}},
+	}
+	tests := []struct {
+		name string
+		at   time.Time
+		want []Holder
+	}{
+		{name: "before history", at: at(9)},
+		{name: "first holder", at: at(11), want: []Holder{{Node: "node-1", Since: at(10), Until: at(12)}}},
+		{name: "released", at: at(12)},
+		{name: "second holder", at: at(14), want: []Holder{{Node: "node-2", Since: at(13)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HoldersAt(statuses, "1.1.1.1", tt.at)
+			if len(got) != len(tt.want) {
+				t.Fatalf("HoldersAt() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Node != tt.want[i].Node || !got[i].Since.Equal(tt.want[i].Since) || !got[i].Until.Equal(tt.want[i].Until) {
+					t.Errorf("HoldersAt() = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	statuses := []types.AssignmentStatus{{Node: "node-1", History: []types.AssignmentTransition{
+		{Time: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), Address: "1.1.1.1", Pool: "pool-1"},
+		{Time: time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC), Pool: "pool-1"},
+	}}}
+	var buf bytes.Buffer
+	if err := PrintHistory(&buf, statuses, FormatTable); err != nil {
+		t.Fatalf("PrintHistory() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "1.1.1.1") || !strings.Contains(lines[2], "<none>") {
+		t.Errorf("PrintHistory() = %q", buf.String())
+	}
+}
@@ -0,0 +1,71 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// Print writes the assignment statuses to the writer in the given format: table, json or yaml
+func Print(w io.Writer, statuses []types.AssignmentStatus, format string) error {
+	switch format {
+	case FormatTable, "":
+		return printTable(w, statuses)
+	case FormatJSON, FormatYAML:
+		return marshal(w, statuses, format)
+	}
+	return errors.Wrapf(ErrUnknownFormat, "%s, supported formats: table, json, yaml", format)
+}
+
+// marshal writes the value to the writer as JSON or YAML
+func marshal(w io.Writer, v interface{}, format string) error {
+	if format == FormatYAML {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal status to YAML")
+		}
+		_, err = w.Write(data)
+		return errors.Wrap(err, "failed to write status")
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal status to JSON")
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return errors.Wrap(err, "failed to write status")
+}
+
+func printTable(w io.Writer, statuses []types.AssignmentStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:gomnd
+	fmt.Fprintln(tw, "NODE\tADDRESS\tPOOL\tLAST TRANSITION\tLAST ERROR")
+	for i := range statuses {
+		s := &statuses[i]
+		transition := ""
+		if !s.LastTransitionTime.IsZero() {
+			transition = s.LastTransitionTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Node, orNone(s.Address), orNone(s.Pool), orNone(transition), orNone(s.LastError))
+	}
+	return errors.Wrap(tw.Flush(), "failed to write status table")
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package status
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+)
+
+func TestPrint(t *testing.T) {
+	statuses := []types.AssignmentStatus{
+		{
+			Node:               "node-1",
+			Address:            "1.1.1.1",
+			Pool:               "pool-1",
+			LastTransitionTime: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			Node:      "node-2",
+			LastError: "no available addresses",
+		},
+	}
+	tests := []struct {
+		name     string
+		format   string
+		contains []string
+		wantErr  error
+	}{
+		{
+			name:     "table",
+			format:   FormatTable,
+			contains: []string{"NODE", "node-1", "1.1.1.1", "2024-03-01T10:00:00Z", "node-2", "<none>", "no available addresses"},
+		},
+		{
+			name:     "json",
+			format:   FormatJSON,
+			contains: []string{`"node": "node-1"`, `"address": "1.1.1.1"`, `"lastError": "no available addresses"`},
+		},
+		{
+			name:     "yaml",
+			format:   FormatYAML,
+			contains: []string{"- address: 1.1.1.1", "node: node-2", "lastError: no available addresses"},
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: ErrUnknownFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Print(&buf, statuses, tt.format)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Print() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, c := range tt.contains {
+				if !strings.Contains(buf.String(), c) {
+					t.Errorf("Print() output does not contain %q:\n%s", c, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package status
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/types"
+	"github.com/pkg/errors"
+)
+
+// maxRecentErrors is the number of most recent errors shown by PrintTop
+const maxRecentErrors = 10
+
+// Sort orders of the nodes table shown by PrintTop
+const (
+	SortByNode    = "node"
+	SortByPool    = "pool"
+	SortByAddress = "address"
+	SortByTime    = "time"
+)
+
+// SortOrders are the sort orders of the nodes table, in the order the top command cycles through them
+var SortOrders = []string{SortByNode, SortByPool, SortByAddress, SortByTime}
+
+// View is the sort order and filter of the nodes table shown by PrintTop
+type View struct {
+	SortBy string
+	// Filter keeps the nodes whose name, pool or address contains it
+	Filter string
+}
+
+// NextSort returns the sort order following the current one
+func (v View) NextSort() string {
+	for i, order := range SortOrders {
+		if order == v.SortBy {
+			return SortOrders[(i+1)%len(SortOrders)]
+		}
+	}
+	return SortOrders[0]
+}
+
+// Apply returns the statuses matching the filter, sorted by the sort order (node name by default, most recent first
+// by time)
+func (v View) Apply(statuses []types.AssignmentStatus) []types.AssignmentStatus {
+	shown := make([]types.AssignmentStatus, 0, len(statuses))
+	for i := range statuses {
+		if v.Filter == "" || strings.Contains(statuses[i].Node, v.Filter) || strings.Contains(statuses[i].Pool, v.Filter) ||
+			strings.Contains(statuses[i].Address, v.Filter) {
+			shown = append(shown, statuses[i])
+		}
+	}
+	sort.SliceStable(shown, func(i, j int) bool {
+		switch v.SortBy {
+		case SortByPool:
+			if shown[i].Pool != shown[j].Pool {
+				return shown[i].Pool < shown[j].Pool
+			}
+		case SortByAddress:
+			if shown[i].Address != shown[j].Address {
+				return shown[i].Address < shown[j].Address
+			}
+		case SortByTime:
+			if !shown[i].LastTransitionTime.Equal(shown[j].LastTransitionTime) {
+				return shown[i].LastTransitionTime.After(shown[j].LastTransitionTime)
+			}
+		}
+		return shown[i].Node < shown[j].Node
+	})
+	return shown
+}
+
+// PoolUtilization is the number of tracked nodes and nodes with an assigned static public IP address in a node pool
+type PoolUtilization struct {
+	Pool     string `json:"pool"`
+	Nodes    int    `json:"nodes"`
+	Assigned int    `json:"assigned"`
+}
+
+// Utilization returns the pool utilization, sorted by pool name
+func Utilization(statuses []types.AssignmentStatus) []PoolUtilization {
+	pools := make(map[string]*PoolUtilization)
+	for i := range statuses {
+		p, ok := pools[statuses[i].Pool]
+		if !ok {
+			p = &PoolUtilization{Pool: statuses[i].Pool}
+			pools[statuses[i].Pool] = p
+		}
+		p.Nodes++
+		if statuses[i].Address != "" {
+			p.Assigned++
+		}
+	}
+	utilization := make([]PoolUtilization, 0, len(pools))
+	for _, p := range pools {
+		utilization = append(utilization, *p)
+	}
+	sort.Slice(utilization, func(i, j int) bool { return utilization[i].Pool < utilization[j].Pool })
+	return utilization
+}
+
+// RecentErrors returns up to limit statuses with an error, most recent first
+func RecentErrors(statuses []types.AssignmentStatus, limit int) []types.AssignmentStatus {
+	var failed []types.AssignmentStatus
+	for i := range statuses {
+		if statuses[i].LastError != "" {
+			failed = append(failed, statuses[i])
+		}
+	}
+	sort.SliceStable(failed, func(i, j int) bool { return failed[i].LastTransitionTime.After(failed[j].LastTransitionTime) })
+	if len(failed) > limit {
+		failed = failed[:limit]
+	}
+	return failed
+}
+
+// PrintTop writes the nodes, pool utilization and recent errors overview shown by the top command; the view applies to
+// the nodes table only
+func PrintTop(w io.Writer, statuses []types.AssignmentStatus, now time.Time, view View) error {
+	shown := view.Apply(statuses)
+	sortBy := view.SortBy
+	if sortBy == "" {
+		sortBy = SortByNode
+	}
+	fmt.Fprintf(w, "kubeip top - %s - %d nodes", now.UTC().Format(time.RFC3339), len(statuses))
+	if view.Filter != "" {
+		fmt.Fprintf(w, " - %d matching %q", len(shown), view.Filter)
+	}
+	fmt.Fprintf(w, "\n\nNODES (by %s)\n", sortBy)
+	if err := printTable(w, shown); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nPOOLS")
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:gomnd
+	fmt.Fprintln(tw, "POOL\tNODES\tASSIGNED\tUTILIZATION")
+	for _, p := range Utilization(statuses) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d%%\n", orNone(p.Pool), p.Nodes, p.Assigned, p.Assigned*100/p.Nodes) //nolint:gomnd
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.Wrap(err, "failed to write pool utilization")
+	}
+
+	fmt.Fprintln(w, "\nRECENT ERRORS")
+	tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:gomnd
+	fmt.Fprintln(tw, "TIME\tNODE\tERROR")
+	for _, s := range RecentErrors(statuses, maxRecentErrors) {
+		transition := ""
+		if !s.LastTransitionTime.IsZero() {
+			transition = s.LastTransitionTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", orNone(transition), s.Node, s.LastError)
+	}
+	return errors.Wrap(tw.Flush(), "failed to write recent errors")
+}
@@ -0,0 +1,95 @@
+package status
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/types"
+)
+
+var topStatuses = []types.AssignmentStatus{
+	{Node: "node-1", Address: "1.1.1.1", Pool: "pool-a", LastTransitionTime: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+	{Node: "node-2", Pool: "pool-a", LastError: "no available addresses", LastTransitionTime: time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+	{Node: "node-3", Address: "2.2.2.2", Pool: "pool-b", LastTransitionTime: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)},
+	{Node: "node-4", Pool: "pool-b", LastError: "quota exceeded", LastTransitionTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+}
+
+func TestUtilization(t *testing.T) {
+	want := []PoolUtilization{
+		{Pool: "pool-a", Nodes: 2, Assigned: 1},
+		{Pool: "pool-b", Nodes: 2, Assigned: 1},
+	}
+	if got := Utilization(topStatuses); !reflect.DeepEqual(got, want) {
+		t.Errorf("Utilization() got = %v, want %v", got, want)
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	got := RecentErrors(topStatuses, 1)
+	if len(got) != 1 || got[0].Node != "node-4" {
+		t.Errorf("RecentErrors() got = %v, want only node-4", got)
+	}
+	if got = RecentErrors(topStatuses, 10); len(got) != 2 || got[1].Node != "node-2" {
+		t.Errorf("RecentErrors() got = %v, want node-4 and node-2", got)
+	}
+}
+
+func TestView(t *testing.T) {
+	nodes := func(statuses []types.AssignmentStatus) []string {
+		names := make([]string, 0, len(statuses))
+		for i := range statuses {
+			names = append(names, statuses[i].Node)
+		}
+		return names
+	}
+	tests := []struct {
+		name string
+		view View
+		want []string
+	}{
+		{name: "default", want: []string{"node-1", "node-2", "node-3", "node-4"}},
+		{name: "by address", view: View{SortBy: SortByAddress}, want: []string{"node-2", "node-4", "node-1", "node-3"}},
+		{name: "by time", view: View{SortBy: SortByTime}, want: []string{"node-4", "node-2", "node-1", "node-3"}},
+		{name: "filter pool", view: View{SortBy: SortByPool, Filter: "pool-b"}, want: []string{"node-3", "node-4"}},
+		{name: "filter address", view: View{Filter: "2.2"}, want: []string{"node-3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nodes(tt.view.Apply(topStatuses)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("View.Apply() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	view := View{SortBy: SortByTime}
+	if got := view.NextSort(); got != SortByNode {
+		t.Errorf("View.NextSort() got = %v, want %v", got, SortByNode)
+	}
+}
+
+func TestPrintTop(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintTop(&buf, topStatuses, time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC), View{}); err != nil {
+		t.Fatalf("PrintTop() error = %v", err)
+	}
+	for _, s := range []string{"2024-03-01T13:00:00Z - 4 nodes", "NODES", "POOLS", "pool-a", "50%", "RECENT ERRORS", "quota exceeded"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("PrintTop() output does not contain %q:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestPrintTopFiltered(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintTop(&buf, topStatuses, time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC), View{SortBy: SortByPool, Filter: "pool-b"}); err != nil {
+		t.Fatalf("PrintTop() error = %v", err)
+	}
+	for _, s := range []string{"4 nodes - 2 matching \"pool-b\"", "NODES (by pool)", "pool-a"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("PrintTop() output does not contain %q:\n%s", s, buf.String())
+		}
+	}
+}
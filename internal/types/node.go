@@ -12,17 +12,36 @@ const (
 	CloudProviderAWS   CloudProvider = "aws"
 	CloudProviderOCI   CloudProvider = "oci"
 	CloudProviderAzure CloudProvider = "azure"
+	// CloudProviderOpenStack is an OpenStack instance: recognized in provider IDs, without assigner
+	CloudProviderOpenStack CloudProvider = "openstack"
+	// CloudProviderMetalLB is a bare metal node announcing the address with MetalLB
+	CloudProviderMetalLB CloudProvider = "metallb"
+	// CloudProviderBGP is a bare metal node announcing the address over BGP
+	CloudProviderBGP CloudProvider = "bgp"
+	// CloudProviderFake is a node of the develop mode, with simulated static public IP addresses
+	CloudProviderFake CloudProvider = "fake"
 )
 
 type Node struct {
-	Name        string
-	Instance    string
-	Cloud       CloudProvider
-	Pool        string
+	Name     string
+	Instance string
+	// Project is the GCP project of the instance, from the provider ID; empty on other cloud providers
+	Project string
+	Cloud   CloudProvider
+	Pool    string
+	// AddressPool is the name of the address pool the node draws its address from, labeling its metrics
+	AddressPool string
+	// Tenant is the tenant of the node pool, attributing the assigned address in the metrics and events
+	Tenant      string
 	Region      string
 	Zone        string
 	ExternalIPs []net.IP
 	InternalIPs []net.IP
+	Labels      map[string]string
+	Annotations map[string]string
+	// ClaimedAddress is the static public IP address reserved for the node with a KubeIPClaim, assigned instead of an
+	// address of the pool
+	ClaimedAddress string
 }
 
 // Stringer interface: all fields with name and value
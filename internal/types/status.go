@@ -0,0 +1,36 @@
+package types
+
+import "time"
+
+// AssignmentStatus is the static public IP assignment state of a node, as recorded by the kubeip agent.
+type AssignmentStatus struct {
+	Node               string    `json:"node"`
+	Address            string    `json:"address,omitempty"`
+	Pool               string    `json:"pool,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty"`
+	LastError          string    `json:"lastError,omitempty"`
+	// RetryAttempts is the number of failed attempts of the pending assignment, cleared once the node is assigned
+	RetryAttempts int `json:"retryAttempts,omitempty"`
+	// LastRetryTime is the time of the last failed attempt of the pending assignment
+	LastRetryTime time.Time `json:"lastRetryTime,omitempty"`
+	// History holds the most recent transitions of the node, oldest first
+	History []AssignmentTransition `json:"history,omitempty"`
+	// Operation identifies the most recent cloud provider mutation of the node, nil if the cloud provider reports none
+	Operation *CloudOperation `json:"operation,omitempty"`
+}
+
+// CloudOperation identifies a cloud provider mutation, to find its entry in the cloud audit log: the operation or request
+// ID, the allocation ID of the address and the ID of its association with the instance, each set if the provider has one
+type CloudOperation struct {
+	OperationID   string `json:"operationID,omitempty"`
+	AllocationID  string `json:"allocationID,omitempty"`
+	AssociationID string `json:"associationID,omitempty"`
+}
+
+// AssignmentTransition is a recorded assignment, release or failed assignment of a node
+type AssignmentTransition struct {
+	Time    time.Time `json:"time"`
+	Address string    `json:"address,omitempty"`
+	Pool    string    `json:"pool,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
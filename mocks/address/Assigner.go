@@ -77,6 +77,62 @@ func (_c *Assigner_Assign_Call) RunAndReturn(run func(context.Context, string, s
 	return _c
 }
 
+// Candidate provides a mock function with given fields: ctx, instanceID, zone, filter, orderBy
+func (_m *Assigner) Candidate(ctx context.Context, instanceID string, zone string, filter []string, orderBy string) (string, error) {
+	ret := _m.Called(ctx, instanceID, zone, filter, orderBy)
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string, string) (string, error)); ok {
+		return rf(ctx, instanceID, zone, filter, orderBy)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string, string) string); ok {
+		r0 = rf(ctx, instanceID, zone, filter, orderBy)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []string, string) error); ok {
+		r1 = rf(ctx, instanceID, zone, filter, orderBy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Assigner_Candidate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Candidate'
+type Assigner_Candidate_Call struct {
+	*mock.Call
+}
+
+// Candidate is a helper method to define mock.On call
+//   - ctx context.Context
+//   - instanceID string
+//   - zone string
+//   - filter []string
+//   - orderBy string
+func (_e *Assigner_Expecter) Candidate(ctx interface{}, instanceID interface{}, zone interface{}, filter interface{}, orderBy interface{}) *Assigner_Candidate_Call {
+	return &Assigner_Candidate_Call{Call: _e.mock.On("Candidate", ctx, instanceID, zone, filter, orderBy)}
+}
+
+func (_c *Assigner_Candidate_Call) Run(run func(ctx context.Context, instanceID string, zone string, filter []string, orderBy string)) *Assigner_Candidate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].([]string), args[4].(string))
+	})
+	return _c
+}
+
+func (_c *Assigner_Candidate_Call) Return(_a0 string, _a1 error) *Assigner_Candidate_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Assigner_Candidate_Call) RunAndReturn(run func(context.Context, string, string, []string, string) (string, error)) *Assigner_Candidate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Unassign provides a mock function with given fields: ctx, instanceID, zone
 func (_m *Assigner) Unassign(ctx context.Context, instanceID string, zone string) error {
 	ret := _m.Called(ctx, instanceID, zone)
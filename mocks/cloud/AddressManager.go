@@ -194,6 +194,64 @@ func (_c *AddressManager_GetAddress_Call) RunAndReturn(run func(string, string,
 	return _c
 }
 
+// SetLabels provides a mock function with given fields: project, region, name, fingerprint, labels
+func (_m *AddressManager) SetLabels(project string, region string, name string, fingerprint string, labels map[string]string) (*compute.Operation, error) {
+	ret := _m.Called(project, region, name, fingerprint, labels)
+
+	var r0 *compute.Operation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, string, string, map[string]string) (*compute.Operation, error)); ok {
+		return rf(project, region, name, fingerprint, labels)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, string, string, map[string]string) *compute.Operation); ok {
+		r0 = rf(project, region, name, fingerprint, labels)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*compute.Operation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, string, string, map[string]string) error); ok {
+		r1 = rf(project, region, name, fingerprint, labels)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddressManager_SetLabels_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetLabels'
+type AddressManager_SetLabels_Call struct {
+	*mock.Call
+}
+
+// SetLabels is a helper method to define mock.On call
+//   - project string
+//   - region string
+//   - name string
+//   - fingerprint string
+//   - labels map[string]string
+func (_e *AddressManager_Expecter) SetLabels(project interface{}, region interface{}, name interface{}, fingerprint interface{}, labels interface{}) *AddressManager_SetLabels_Call {
+	return &AddressManager_SetLabels_Call{Call: _e.mock.On("SetLabels", project, region, name, fingerprint, labels)}
+}
+
+func (_c *AddressManager_SetLabels_Call) Run(run func(project string, region string, name string, fingerprint string, labels map[string]string)) *AddressManager_SetLabels_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string), args[2].(string), args[3].(string), args[4].(map[string]string))
+	})
+	return _c
+}
+
+func (_c *AddressManager_SetLabels_Call) Return(_a0 *compute.Operation, _a1 error) *AddressManager_SetLabels_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *AddressManager_SetLabels_Call) RunAndReturn(run func(string, string, string, string, map[string]string) (*compute.Operation, error)) *AddressManager_SetLabels_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewAddressManager creates a new instance of AddressManager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewAddressManager(t interface {
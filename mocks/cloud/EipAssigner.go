@@ -6,6 +6,8 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	types "github.com/doitintl/kubeip/internal/types"
 )
 
 // EipAssigner is an autogenerated mock type for the EipAssigner type
@@ -22,17 +24,29 @@ func (_m *EipAssigner) EXPECT() *EipAssigner_Expecter {
 }
 
 // Assign provides a mock function with given fields: ctx, networkInterfaceID, allocationID
-func (_m *EipAssigner) Assign(ctx context.Context, networkInterfaceID string, allocationID string) error {
+func (_m *EipAssigner) Assign(ctx context.Context, networkInterfaceID string, allocationID string) (*types.CloudOperation, error) {
 	ret := _m.Called(ctx, networkInterfaceID, allocationID)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+	var r0 *types.CloudOperation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*types.CloudOperation, error)); ok {
+		return rf(ctx, networkInterfaceID, allocationID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *types.CloudOperation); ok {
 		r0 = rf(ctx, networkInterfaceID, allocationID)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.CloudOperation)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, networkInterfaceID, allocationID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // EipAssigner_Assign_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Assign'
@@ -55,12 +69,12 @@ func (_c *EipAssigner_Assign_Call) Run(run func(ctx context.Context, networkInte
 	return _c
 }
 
-func (_c *EipAssigner_Assign_Call) Return(_a0 error) *EipAssigner_Assign_Call {
-	_c.Call.Return(_a0)
+func (_c *EipAssigner_Assign_Call) Return(_a0 *types.CloudOperation, _a1 error) *EipAssigner_Assign_Call {
+	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *EipAssigner_Assign_Call) RunAndReturn(run func(context.Context, string, string) error) *EipAssigner_Assign_Call {
+func (_c *EipAssigner_Assign_Call) RunAndReturn(run func(context.Context, string, string) (*types.CloudOperation, error)) *EipAssigner_Assign_Call {
 	_c.Call.Return(run)
 	return _c
 }
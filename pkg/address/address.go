@@ -0,0 +1,50 @@
+// Package address exposes the KubeIP assigners: the cloud provider implementations assigning a static public IP address
+// of the pool to an instance, as the agent does, for operators embedding the assignment.
+//
+// An assigner may implement the optional interfaces (PoolChecker, Claimer, QuotaChecker, PermissionChecker), checked by
+// type assertion. The in-memory provider of the fake subpackage implements Assigner for tests.
+package address
+
+import (
+	"context"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/doitintl/kubeip/pkg/config"
+	"github.com/doitintl/kubeip/pkg/node"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrUnknownCloudProvider is returned by NewAssigner for a cloud provider without assigner
+	ErrUnknownCloudProvider = address.ErrUnknownCloudProvider
+	// ErrStaticIPAlreadyAssigned is returned by Assign, with the address, when the instance already holds an address
+	ErrStaticIPAlreadyAssigned = address.ErrStaticIPAlreadyAssigned
+	// ErrNoStaticIPAssigned is returned by Unassign when the instance holds no address
+	ErrNoStaticIPAssigned = address.ErrNoStaticIPAssigned
+	// ErrNoAvailableAddress is returned when all the addresses of the pool are assigned
+	ErrNoAvailableAddress = address.ErrNoAvailableAddress
+)
+
+// Assigner assigns and releases the static public IP addresses of the instances
+type Assigner = address.Assigner
+
+// optional interfaces of the assigners
+type (
+	// PoolChecker tells the addresses of the pool from the other static public IP addresses
+	PoolChecker = address.PoolChecker
+	// Claimer assigns a given address of the pool
+	Claimer = address.Claimer
+	// QuotaChecker reports the quota of the static public IP addresses of the region
+	QuotaChecker = address.QuotaChecker
+	// PermissionChecker checks the cloud permissions of the credentials
+	PermissionChecker = address.PermissionChecker
+)
+
+// Quota is a cloud provider quota of the static public IP addresses
+type Quota = address.Quota
+
+// NewAssigner returns the assigner of the cloud provider (AWS, Google Cloud or OCI) configured by cfg: the project and
+// region, the IPv6 mode and the provider settings; the credentials come from the environment, like the agent
+func NewAssigner(ctx context.Context, logger *logrus.Entry, provider node.CloudProvider, cfg *config.Config) (Assigner, error) {
+	return address.NewAssigner(ctx, logger, provider, cfg) //nolint:wrapcheck
+}
@@ -0,0 +1,42 @@
+package address_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/doitintl/kubeip/pkg/address"
+	"github.com/doitintl/kubeip/pkg/config"
+	"github.com/doitintl/kubeip/pkg/ipam"
+	"github.com/doitintl/kubeip/pkg/node"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Assigns a static public IP address of the pool to a node, the way the agent does
+func ExampleNewAssigner() {
+	ctx := context.Background()
+	var client kubernetes.Interface // the clientset of the operator
+	n, err := node.NewExplorer(client, "").GetNode(ctx, "node-1")
+	if err != nil || node.IsIgnored(n) {
+		return
+	}
+	cfg := &config.Config{Project: "my-project", Region: n.Region}
+	assigner, err := address.NewAssigner(ctx, logrus.NewEntry(logrus.New()), n.Cloud, cfg)
+	if err != nil {
+		return
+	}
+	decision, err := ipam.Decide(ipam.Request{Filter: []string{"labels.kubeip=reserved"}})
+	if err != nil {
+		return
+	}
+	assigned, err := assigner.Assign(ctx, n.Instance, n.Zone, decision.Filter, decision.OrderBy)
+	if errors.Is(err, address.ErrStaticIPAlreadyAssigned) {
+		fmt.Println("node already holds", assigned)
+		return
+	}
+	if err != nil {
+		return
+	}
+	fmt.Println("node assigned", assigned)
+}
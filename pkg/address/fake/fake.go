@@ -0,0 +1,222 @@
+// Package fake provides an in-memory static public IP address provider behaving like the KubeIP assigners, a harness
+// running concurrent agents against it, to test KubeIP behavior (pool exhaustion, races, failover) without a cloud
+// provider, and a load test of virtual nodes joining and leaving. The develop mode of the agent runs on it.
+package fake
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/doitintl/kubeip/internal/address"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoAvailableAddress is returned by Assign when all the addresses of the pool are assigned
+	ErrNoAvailableAddress = address.ErrNoAvailableAddress
+	// ErrStaticIPAlreadyAssigned is returned by Assign, with the address, when the instance already holds an address
+	ErrStaticIPAlreadyAssigned = address.ErrStaticIPAlreadyAssigned
+	// ErrNoStaticIPAssigned is returned by Unassign when the instance holds no address
+	ErrNoStaticIPAssigned = address.ErrNoStaticIPAssigned
+	// ErrSimulatedFailure is the failure of the calls failing at the failure rate
+	ErrSimulatedFailure = errors.New("simulated cloud provider failure")
+)
+
+// Option configures the provider
+type Option func(*Provider)
+
+// WithLatency makes every call take the latency
+func WithLatency(latency time.Duration) Option {
+	return func(p *Provider) {
+		p.latency = latency
+	}
+}
+
+// WithFailureRate makes the calls fail with ErrSimulatedFailure at the rate (0 to 1)
+func WithFailureRate(rate float64) Option {
+	return func(p *Provider) {
+		p.failureRate = rate
+	}
+}
+
+// WithRandom replaces the random source of the failures, returning numbers in [0, 1)
+func WithRandom(random func() float64) Option {
+	return func(p *Provider) {
+		p.random = random
+	}
+}
+
+// Provider is an in-memory pool of static public IP addresses, safe for concurrent agents; it implements the KubeIP
+// assigner interface
+type Provider struct {
+	latency     time.Duration
+	failureRate float64
+	random      func() float64
+	mutex       sync.Mutex
+	addresses   []string
+	// assigned maps the instances to their addresses
+	assigned map[string]string
+	// failures are the errors of the next calls
+	failures []error
+	// beforeClaim runs between the selection and the claim of an address
+	beforeClaim func(instanceID, address string)
+}
+
+// New returns a provider over the addresses (IPs or CIDRs), assigned in order
+func New(addresses []string, opts ...Option) (*Provider, error) {
+	ips, err := address.ExpandAddresses(addresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse addresses")
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("addresses are required")
+	}
+	p := &Provider{
+		random:   rand.Float64, //nolint:gosec
+		assigned: make(map[string]string),
+	}
+	for _, ip := range ips {
+		p.addresses = append(p.addresses, ip.String())
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.failureRate < 0 || p.failureRate > 1 {
+		return nil, errors.Errorf("failure rate %v is not between 0 and 1", p.failureRate)
+	}
+	return p, nil
+}
+
+// FailNext makes the next call fail with the error, after the failures already queued
+func (p *Provider) FailNext(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.failures = append(p.failures, err)
+}
+
+// BeforeClaim sets the hook run between the selection and the claim of an address by Assign, e.g. assigning the
+// address to another instance to simulate a concurrent agent; the assignment then moves on to the next address
+func (p *Provider) BeforeClaim(hook func(instanceID, address string)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.beforeClaim = hook
+}
+
+// Assignments returns a copy of the addresses by instance
+func (p *Provider) Assignments() map[string]string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	assignments := make(map[string]string, len(p.assigned))
+	for instance, address := range p.assigned {
+		assignments[instance] = address
+	}
+	return assignments
+}
+
+// call simulates the latency and the failures of a cloud provider call
+func (p *Provider) call(ctx context.Context, operation string) error {
+	select {
+	case <-time.After(p.latency):
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "simulated %s cancelled", operation)
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.failures) > 0 {
+		err := p.failures[0]
+		p.failures = p.failures[1:]
+		return err
+	}
+	if p.random() < p.failureRate {
+		return errors.Wrapf(ErrSimulatedFailure, "simulated %s", operation)
+	}
+	return nil
+}
+
+// available returns the first address not assigned to an instance
+func (p *Provider) available() (string, error) {
+	held := make(map[string]bool, len(p.assigned))
+	for _, address := range p.assigned {
+		held[address] = true
+	}
+	for _, address := range p.addresses {
+		if !held[address] {
+			return address, nil
+		}
+	}
+	return "", ErrNoAvailableAddress
+}
+
+// Assign assigns the first available address to the instance; the filter and the order are ignored
+func (p *Provider) Assign(ctx context.Context, instanceID, _ string, _ []string, _ string) (string, error) {
+	if err := p.call(ctx, "assign"); err != nil {
+		return "", err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for {
+		if address, ok := p.assigned[instanceID]; ok {
+			return address, ErrStaticIPAlreadyAssigned
+		}
+		address, err := p.available()
+		if err != nil {
+			return "", err
+		}
+		if hook := p.beforeClaim; hook != nil {
+			p.mutex.Unlock()
+			hook(instanceID, address)
+			p.mutex.Lock()
+			if p.claimed(address) {
+				continue
+			}
+		}
+		p.assigned[instanceID] = address
+		return address, nil
+	}
+}
+
+// claimed reports if an instance holds the address
+func (p *Provider) claimed(address string) bool {
+	for _, held := range p.assigned {
+		if held == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidate returns the address held by the instance or the first available address
+func (p *Provider) Candidate(ctx context.Context, instanceID, _ string, _ []string, _ string) (string, error) {
+	if err := p.call(ctx, "candidate"); err != nil {
+		return "", err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if address, ok := p.assigned[instanceID]; ok {
+		return address, nil
+	}
+	return p.available()
+}
+
+// Unassign returns the address of the instance to the pool
+func (p *Provider) Unassign(ctx context.Context, instanceID, _ string) error {
+	if err := p.call(ctx, "unassign"); err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.assigned[instanceID]; !ok {
+		return ErrNoStaticIPAssigned
+	}
+	delete(p.assigned, instanceID)
+	return nil
+}
+
+// Announced confirms the assignment: the node never reports the simulated address as external IP
+func (p *Provider) Announced(_ context.Context, instanceID, address string) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.assigned[instanceID] == address, nil
+}
@@ -0,0 +1,102 @@
+package fake
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	p, err := New([]string{"203.0.113.1", "203.0.113.2"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	candidate, err := p.Candidate(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.1", candidate)
+
+	assigned, err := p.Assign(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.1", assigned)
+
+	assigned, err = p.Assign(ctx, "node-1", "", nil, "")
+	assert.ErrorIs(t, err, ErrStaticIPAlreadyAssigned)
+	assert.Equal(t, "203.0.113.1", assigned)
+
+	assigned, err = p.Assign(ctx, "node-2", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.2", assigned)
+
+	_, err = p.Assign(ctx, "node-3", "", nil, "")
+	assert.ErrorIs(t, err, ErrNoAvailableAddress)
+
+	announced, err := p.Announced(ctx, "node-2", "203.0.113.2")
+	require.NoError(t, err)
+	assert.True(t, announced)
+
+	require.NoError(t, p.Unassign(ctx, "node-1", ""))
+	assert.ErrorIs(t, p.Unassign(ctx, "node-1", ""), ErrNoStaticIPAssigned)
+
+	assigned, err = p.Assign(ctx, "node-3", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.1", assigned)
+	assert.Equal(t, map[string]string{"node-2": "203.0.113.2", "node-3": "203.0.113.1"}, p.Assignments())
+}
+
+func TestProvider_Failures(t *testing.T) {
+	random := 0.4
+	p, err := New([]string{"203.0.113.0/30"}, WithFailureRate(0.5), WithRandom(func() float64 { return random }))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = p.Assign(ctx, "node-1", "", nil, "")
+	assert.ErrorIs(t, err, ErrSimulatedFailure)
+
+	random = 0.6
+	injected := errors.New("quota exceeded")
+	p.FailNext(injected)
+	_, err = p.Assign(ctx, "node-1", "", nil, "")
+	assert.ErrorIs(t, err, injected)
+
+	assigned, err := p.Assign(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.0", assigned)
+}
+
+func TestProvider_BeforeClaim(t *testing.T) {
+	p, err := New([]string{"203.0.113.1", "203.0.113.2"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// another agent claims the selected address first
+	p.BeforeClaim(func(string, string) {
+		p.BeforeClaim(nil)
+		_, err := p.Assign(ctx, "node-2", "", nil, "")
+		require.NoError(t, err)
+	})
+	assigned, err := p.Assign(ctx, "node-1", "", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.2", assigned)
+	assert.Equal(t, map[string]string{"node-1": "203.0.113.2", "node-2": "203.0.113.1"}, p.Assignments())
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		addresses []string
+		opts      []Option
+	}{
+		"no addresses":          {},
+		"invalid address":       {addresses: []string{"not-an-ip"}},
+		"negative failure rate": {addresses: []string{"203.0.113.1"}, opts: []Option{WithFailureRate(-0.1)}},
+		"failure rate above 1":  {addresses: []string{"203.0.113.1"}, opts: []Option{WithFailureRate(1.5)}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tt.addresses, tt.opts...)
+			assert.Error(t, err)
+		})
+	}
+}
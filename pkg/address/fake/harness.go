@@ -0,0 +1,82 @@
+package fake
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Assigner is the assignment interface of the KubeIP assigners, implemented by Provider
+type Assigner interface {
+	Assign(ctx context.Context, instanceID, zone string, filter []string, orderBy string) (string, error)
+	Unassign(ctx context.Context, instanceID, zone string) error
+}
+
+// Result is the outcome of the agent of an instance
+type Result struct {
+	Instance string
+	Address  string
+	// Attempts is the number of assignment attempts
+	Attempts int
+	Err      error
+}
+
+// Harness runs agents against an assigner the way the KubeIP agents do: one agent per node, all starting together,
+// retrying failed assignments
+type Harness struct {
+	Assigner Assigner
+	// Attempts is the maximum number of assignment attempts of an agent (1 if not set)
+	Attempts int
+	// RetryInterval is the interval between the attempts of an agent
+	RetryInterval time.Duration
+}
+
+// AssignAll runs the agents of the instances concurrently and returns their results in the order of the instances;
+// an instance already holding an address keeps it
+func (h *Harness) AssignAll(ctx context.Context, instances ...string) []Result {
+	results := make([]Result, len(instances))
+	var wg sync.WaitGroup
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance string) {
+			defer wg.Done()
+			results[i] = h.assign(ctx, instance)
+		}(i, instance)
+	}
+	wg.Wait()
+	return results
+}
+
+func (h *Harness) assign(ctx context.Context, instance string) Result {
+	attempts := h.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	result := Result{Instance: instance}
+	for result.Attempts < attempts {
+		result.Attempts++
+		result.Address, result.Err = h.Assigner.Assign(ctx, instance, "", nil, "")
+		if result.Err == nil || errors.Is(result.Err, ErrStaticIPAlreadyAssigned) {
+			result.Err = nil
+			return result
+		}
+		select {
+		case <-time.After(h.RetryInterval):
+		case <-ctx.Done():
+			result.Err = errors.Wrap(ctx.Err(), "assignment cancelled")
+			return result
+		}
+	}
+	return result
+}
+
+// Failover releases the address of the failed instance, as the agent does on node removal, and runs the agents of the
+// replacement instances
+func (h *Harness) Failover(ctx context.Context, failed string, replacements ...string) ([]Result, error) {
+	if err := h.Assigner.Unassign(ctx, failed, ""); err != nil {
+		return nil, errors.Wrapf(err, "failed to release the address of instance %s", failed)
+	}
+	return h.AssignAll(ctx, replacements...), nil
+}
@@ -0,0 +1,58 @@
+package fake
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarness_AssignAll(t *testing.T) {
+	p, err := New([]string{"203.0.113.0/29"})
+	require.NoError(t, err)
+	h := &Harness{Assigner: p}
+
+	// concurrent agents never share an address
+	instances := []string{"node-1", "node-2", "node-3", "node-4", "node-5", "node-6", "node-7", "node-8"}
+	held := map[string]bool{}
+	for _, result := range h.AssignAll(context.Background(), instances...) {
+		require.NoError(t, result.Err)
+		assert.False(t, held[result.Address], "address %s assigned twice", result.Address)
+		held[result.Address] = true
+	}
+
+	// the pool is exhausted
+	results := h.AssignAll(context.Background(), "node-9")
+	assert.ErrorIs(t, results[0].Err, ErrNoAvailableAddress)
+}
+
+func TestHarness_Retry(t *testing.T) {
+	p, err := New([]string{"203.0.113.1"})
+	require.NoError(t, err)
+	p.FailNext(ErrSimulatedFailure)
+	p.FailNext(ErrSimulatedFailure)
+	h := &Harness{Assigner: p, Attempts: 3}
+
+	results := h.AssignAll(context.Background(), "node-1")
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, 3, results[0].Attempts)
+	assert.Equal(t, "203.0.113.1", results[0].Address)
+}
+
+func TestHarness_Failover(t *testing.T) {
+	p, err := New([]string{"203.0.113.1"})
+	require.NoError(t, err)
+	h := &Harness{Assigner: p}
+	results := h.AssignAll(context.Background(), "node-1")
+	require.NoError(t, results[0].Err)
+
+	results, err = h.Failover(context.Background(), "node-1", "node-2")
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	assert.Equal(t, "203.0.113.1", results[0].Address)
+	assert.Equal(t, map[string]string{"node-2": "203.0.113.1"}, p.Assignments())
+
+	_, err = h.Failover(context.Background(), "node-1", "node-3")
+	assert.ErrorIs(t, err, ErrNoStaticIPAssigned)
+}
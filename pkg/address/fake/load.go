@@ -0,0 +1,232 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// node states of the load test
+const (
+	nodeAbsent = iota
+	nodeJoining
+	nodePresent
+	nodeLeaving
+)
+
+// LoadTest simulates virtual nodes joining and leaving the cluster at constant rates: every join queues the assignment
+// of an address to the node, every leave the release of its address; workers process the queue as the agents (or a
+// controller) would, to size KubeIP for large clusters
+type LoadTest struct {
+	Assigner Assigner
+	// Nodes is the number of virtual nodes, all absent at start
+	Nodes int
+	// JoinRate is the number of absent nodes joining per second
+	JoinRate float64
+	// LeaveRate is the number of nodes holding an address leaving per second; no node leaves if not set
+	LeaveRate float64
+	// Workers is the number of concurrent assignments and releases (1 if not set)
+	Workers int
+	// Attempts is the maximum number of assignment attempts of a join (1 if not set)
+	Attempts int
+	// RetryInterval is the interval between the attempts of a join
+	RetryInterval time.Duration
+	// Duration is the duration of the load test, draining the queue excepted
+	Duration time.Duration
+	// Random replaces the random source of the leaving nodes, returning numbers in [0, n)
+	Random func(n int) int
+}
+
+// LoadReport is the outcome of a load test
+type LoadReport struct {
+	// Elapsed is the duration of the load test, queue drained
+	Elapsed time.Duration
+	// Joined and Left are the numbers of completed assignments and releases
+	Joined int
+	Left   int
+	// Failed is the number of failed assignments and releases
+	Failed int
+	// Throughput is the number of completed assignments per second
+	Throughput float64
+	// QueueLatency are the percentiles of the time between the join or leave of a node and the start of its processing
+	QueueLatency Percentiles
+	// AssignLatency are the percentiles of the time to assign an address, retries included
+	AssignLatency Percentiles
+}
+
+// Percentiles are the percentiles of a set of durations
+type Percentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+func (p Percentiles) String() string {
+	return fmt.Sprintf("p50=%v p90=%v p99=%v max=%v", p.P50, p.P90, p.P99, p.Max)
+}
+
+// loadEvent is the join or the leave of a virtual node, queued at
+type loadEvent struct {
+	node   int
+	join   bool
+	queued time.Time
+}
+
+// loadState is the state of the virtual nodes and the measures of a running load test
+type loadState struct {
+	mutex   sync.Mutex
+	nodes   []int
+	queue   []time.Duration
+	assign  []time.Duration
+	joined  int
+	left    int
+	failed  int
+	pending sync.WaitGroup
+}
+
+// Run runs the load test until its duration elapses or the context is done, waits for the queued events and reports
+func (l *LoadTest) Run(ctx context.Context) (*LoadReport, error) {
+	if l.Nodes < 1 {
+		return nil, errors.New("the number of nodes must be positive")
+	}
+	if l.JoinRate <= 0 || l.LeaveRate < 0 {
+		return nil, errors.Errorf("invalid join rate %v or leave rate %v", l.JoinRate, l.LeaveRate)
+	}
+	workers := l.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	random := l.Random
+	if random == nil {
+		random = rand.Intn //nolint:gosec
+	}
+
+	state := &loadState{nodes: make([]int, l.Nodes)}
+	// a node has one event queued at most
+	events := make(chan loadEvent, l.Nodes)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range events {
+				l.process(ctx, state, event)
+				state.pending.Done()
+			}
+		}()
+	}
+
+	start := time.Now()
+	l.generate(ctx, state, events, random)
+	state.pending.Wait()
+	close(events)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	return &LoadReport{
+		Elapsed:       elapsed,
+		Joined:        state.joined,
+		Left:          state.left,
+		Failed:        state.failed,
+		Throughput:    float64(state.joined) / elapsed.Seconds(),
+		QueueLatency:  percentiles(state.queue),
+		AssignLatency: percentiles(state.assign),
+	}, nil
+}
+
+// generate queues the joins and the leaves at their rates until the duration elapses or the context is done
+func (l *LoadTest) generate(ctx context.Context, state *loadState, events chan<- loadEvent, random func(n int) int) {
+	deadline := time.NewTimer(l.Duration)
+	defer deadline.Stop()
+	joins := time.NewTicker(time.Duration(float64(time.Second) / l.JoinRate))
+	defer joins.Stop()
+	var leaves <-chan time.Time
+	if l.LeaveRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / l.LeaveRate))
+		defer ticker.Stop()
+		leaves = ticker.C
+	}
+	for {
+		select {
+		case <-joins.C:
+			state.queueEvent(events, nodeAbsent, nodeJoining, true, random)
+		case <-leaves:
+			state.queueEvent(events, nodePresent, nodeLeaving, false, random)
+		case <-deadline.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// queueEvent queues the event of a random node in the state from, moving it to the state to; nothing if no node is in
+// the state from
+func (s *loadState) queueEvent(events chan<- loadEvent, from, to int, join bool, random func(n int) int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var candidates []int
+	for node, state := range s.nodes {
+		if state == from {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	node := candidates[random(len(candidates))]
+	s.nodes[node] = to
+	s.pending.Add(1)
+	events <- loadEvent{node: node, join: join, queued: time.Now()}
+}
+
+// process assigns the address of a joining node or releases the address of a leaving node
+func (l *LoadTest) process(ctx context.Context, state *loadState, event loadEvent) {
+	started := time.Now()
+	instance := fmt.Sprintf("node-%d", event.node)
+	var err error
+	next := nodeAbsent
+	if event.join {
+		result := (&Harness{Assigner: l.Assigner, Attempts: l.Attempts, RetryInterval: l.RetryInterval}).assign(ctx, instance)
+		if err = result.Err; err == nil {
+			next = nodePresent
+		}
+	} else if err = l.Assigner.Unassign(ctx, instance, ""); err != nil {
+		// the node keeps its address: it may leave again
+		next = nodePresent
+	}
+	finished := time.Now()
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.nodes[event.node] = next
+	state.queue = append(state.queue, started.Sub(event.queued))
+	switch {
+	case err != nil:
+		state.failed++
+	case event.join:
+		state.joined++
+		state.assign = append(state.assign, finished.Sub(started))
+	default:
+		state.left++
+	}
+}
+
+// percentiles returns the percentiles of the durations, zero if none
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	return Percentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: sorted[len(sorted)-1]} //nolint:gomnd
+}
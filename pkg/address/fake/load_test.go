@@ -0,0 +1,61 @@
+package fake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTest_Run(t *testing.T) {
+	p, err := New([]string{"203.0.113.0/29"})
+	require.NoError(t, err)
+	l := &LoadTest{
+		Assigner:  p,
+		Nodes:     16,
+		JoinRate:  1000,
+		LeaveRate: 200,
+		Workers:   4,
+		Duration:  100 * time.Millisecond,
+	}
+
+	report, err := l.Run(context.Background())
+	require.NoError(t, err)
+	assert.Positive(t, report.Joined)
+	assert.Positive(t, report.Throughput)
+	assert.LessOrEqual(t, report.QueueLatency.P50, report.QueueLatency.Max)
+	// the queue is drained: the nodes holding an address are the nodes joined and not left
+	assignments := p.Assignments()
+	assert.Equal(t, report.Joined-report.Left, len(assignments))
+	held := map[string]bool{}
+	for _, address := range assignments {
+		assert.False(t, held[address], "address %s assigned twice", address)
+		held[address] = true
+	}
+}
+
+func TestLoadTest_Invalid(t *testing.T) {
+	p, err := New([]string{"203.0.113.1"})
+	require.NoError(t, err)
+	for name, l := range map[string]*LoadTest{
+		"no nodes":            {Assigner: p, JoinRate: 1},
+		"no join rate":        {Assigner: p, Nodes: 1},
+		"negative leave rate": {Assigner: p, Nodes: 1, JoinRate: 1, LeaveRate: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := l.Run(context.Background())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, Percentiles{P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}, percentiles(durations))
+	assert.Equal(t, Percentiles{}, percentiles(nil))
+}
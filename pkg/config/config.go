@@ -0,0 +1,10 @@
+// Package config is the public configuration of the KubeIP assigners and node explorer, the one the agent builds from
+// its flags. Embedders fill the fields they need; the zero value of a field leaves its feature disabled.
+package config
+
+import (
+	"github.com/doitintl/kubeip/internal/config"
+)
+
+// Config is the configuration of the agent, see the fields for their flags
+type Config = config.Config
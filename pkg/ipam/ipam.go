@@ -0,0 +1,102 @@
+// Package ipam decides which static public IP address a node gets, with the semantics of the KubeIP agent, so tools
+// outside the agent (capacity planners, admission checks, migration scripts) reach the same decision for a node.
+//
+// The decision is made from what is known about the node, in order of precedence:
+//
+//  1. a node opted out of KubeIP is skipped
+//  2. an address claimed for the node (KubeIPClaim or hand-off from the node it replaces) is claimed
+//  3. an address already held by the node is kept if it belongs to the pool; outside the pool, the non-pool address
+//     policy keeps it, releases it to assign an address of the pool, or refuses the node
+//  4. otherwise the first available address of the pool is assigned: the addresses matching the filter, in the order
+//     of the order-by expression, both evaluated by the cloud provider list APIs
+//
+// The package makes no cloud provider call: the caller gathers the facts of the Request and carries out the Decision.
+package ipam
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Action is what to do for the node
+type Action string
+
+const (
+	// ActionSkip leaves the node alone: it is opted out of KubeIP
+	ActionSkip Action = "skip"
+	// ActionClaim assigns the claimed address of the decision
+	ActionClaim Action = "claim"
+	// ActionKeep keeps the address held by the node
+	ActionKeep Action = "keep"
+	// ActionReplace releases the address held by the node outside the pool, then assigns an address of the pool
+	ActionReplace Action = "replace"
+	// ActionRefuse refuses the node holding an address outside the pool
+	ActionRefuse Action = "refuse"
+	// ActionAssign assigns the first available address of the pool matching the filter, in the order of the decision
+	ActionAssign Action = "assign"
+)
+
+// NonPoolPolicy is the policy of an address held by the node outside the pool
+type NonPoolPolicy string
+
+const (
+	// NonPoolKeep keeps the address, the default
+	NonPoolKeep NonPoolPolicy = "keep"
+	// NonPoolReplace releases the address and assigns an address of the pool
+	NonPoolReplace NonPoolPolicy = "replace"
+	// NonPoolFail refuses the node
+	NonPoolFail NonPoolPolicy = "fail"
+)
+
+// ErrUnknownPolicy is returned by Decide for a non-pool address policy other than keep, replace and fail
+var ErrUnknownPolicy = errors.New("unknown non-pool address policy")
+
+// Request is what is known about the node
+type Request struct {
+	// Ignored is set for a node opted out of KubeIP
+	Ignored bool
+	// Claimed is the address claimed for the node; empty if none
+	Claimed string
+	// Held is the static public IP address held by the node; empty if none
+	Held string
+	// HeldInPool is set if the held address matches the pool filter; set it as well if the membership is unknown,
+	// the agent then keeps the address
+	HeldInPool bool
+	// NonPoolPolicy is the policy of a held address outside the pool; empty keeps it
+	NonPoolPolicy NonPoolPolicy
+	// Filter selects the addresses of the pool, in the syntax of the cloud provider
+	Filter []string
+	// OrderBy orders the addresses of the pool, in the syntax of the cloud provider; empty for the provider order
+	OrderBy string
+}
+
+// Decision is the action for the node with its address (claimed or held) or the pool selection (filter and order)
+type Decision struct {
+	Action  Action
+	Address string
+	Filter  []string
+	OrderBy string
+}
+
+// Decide returns the decision for the node
+func Decide(r Request) (Decision, error) {
+	switch {
+	case r.Ignored:
+		return Decision{Action: ActionSkip}, nil
+	case r.Claimed != "":
+		// the claim narrows the pool to the claimed address: the address must still match the filter
+		return Decision{Action: ActionClaim, Address: r.Claimed, Filter: r.Filter}, nil
+	case r.Held == "":
+		return Decision{Action: ActionAssign, Filter: r.Filter, OrderBy: r.OrderBy}, nil
+	case r.HeldInPool:
+		return Decision{Action: ActionKeep, Address: r.Held}, nil
+	}
+	switch r.NonPoolPolicy {
+	case "", NonPoolKeep:
+		return Decision{Action: ActionKeep, Address: r.Held}, nil
+	case NonPoolReplace:
+		return Decision{Action: ActionReplace, Address: r.Held, Filter: r.Filter, OrderBy: r.OrderBy}, nil
+	case NonPoolFail:
+		return Decision{Action: ActionRefuse, Address: r.Held}, nil
+	}
+	return Decision{}, errors.Wrapf(ErrUnknownPolicy, "%s, supported policies: %s, %s, %s", r.NonPoolPolicy, NonPoolKeep, NonPoolReplace, NonPoolFail)
+}
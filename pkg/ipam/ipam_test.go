@@ -0,0 +1,70 @@
+package ipam
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecide(t *testing.T) {
+	filter := []string{"labels.kubeip=reserved"}
+	tests := []struct {
+		name    string
+		request Request
+		want    Decision
+		wantErr error
+	}{
+		{
+			name:    "ignored node, even with a claim",
+			request: Request{Ignored: true, Claimed: "203.0.113.10", Held: "203.0.113.20"},
+			want:    Decision{Action: ActionSkip},
+		},
+		{
+			name:    "claim before the held address",
+			request: Request{Claimed: "203.0.113.10", Held: "203.0.113.20", HeldInPool: true, Filter: filter, OrderBy: "name"},
+			want:    Decision{Action: ActionClaim, Address: "203.0.113.10", Filter: filter},
+		},
+		{
+			name:    "pool",
+			request: Request{Filter: filter, OrderBy: "name"},
+			want:    Decision{Action: ActionAssign, Filter: filter, OrderBy: "name"},
+		},
+		{
+			name:    "held address of the pool",
+			request: Request{Held: "203.0.113.20", HeldInPool: true, NonPoolPolicy: NonPoolFail},
+			want:    Decision{Action: ActionKeep, Address: "203.0.113.20"},
+		},
+		{
+			name:    "held address outside the pool kept by default",
+			request: Request{Held: "203.0.113.20"},
+			want:    Decision{Action: ActionKeep, Address: "203.0.113.20"},
+		},
+		{
+			name:    "held address outside the pool replaced",
+			request: Request{Held: "203.0.113.20", NonPoolPolicy: NonPoolReplace, Filter: filter, OrderBy: "name"},
+			want:    Decision{Action: ActionReplace, Address: "203.0.113.20", Filter: filter, OrderBy: "name"},
+		},
+		{
+			name:    "held address outside the pool refused",
+			request: Request{Held: "203.0.113.20", NonPoolPolicy: NonPoolFail},
+			want:    Decision{Action: ActionRefuse, Address: "203.0.113.20"},
+		},
+		{
+			name:    "unknown policy",
+			request: Request{Held: "203.0.113.20", NonPoolPolicy: "swap"},
+			wantErr: ErrUnknownPolicy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decide(tt.request)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Package node exposes the KubeIP view of a Kubernetes node: its cloud provider, instance, region, zone and pool as
+// discovered by the agent, and the annotations recording its static public IP address.
+package node
+
+import (
+	"github.com/doitintl/kubeip/internal/node"
+	"github.com/doitintl/kubeip/internal/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// annotations of the nodes read and written by the agent
+const (
+	// AddressAnnotation is the static public IP address assigned to the node
+	AddressAnnotation = node.AddressAnnotation
+	// PoolAnnotation is the pool of the assigned address
+	PoolAnnotation = node.PoolAnnotation
+	// LastErrorAnnotation is the error of the last failed assignment
+	LastErrorAnnotation = node.LastErrorAnnotation
+	// IgnoreAnnotation opts the node out of KubeIP
+	IgnoreAnnotation = node.IgnoreAnnotation
+	// InstanceIDAnnotation maps a node without provider ID to its cloud instance
+	InstanceIDAnnotation = node.InstanceIDAnnotation
+)
+
+// Node is a Kubernetes node with its cloud provider instance
+type Node = types.Node
+
+// CloudProvider is the cloud provider of a node
+type CloudProvider = types.CloudProvider
+
+// cloud providers of the nodes
+const (
+	CloudProviderAWS     = types.CloudProviderAWS
+	CloudProviderGCP     = types.CloudProviderGCP
+	CloudProviderOCI     = types.CloudProviderOCI
+	CloudProviderMetalLB = types.CloudProviderMetalLB
+)
+
+// Explorer returns the KubeIP view of a node by name
+type Explorer = node.Explorer
+
+// NewExplorer returns a node explorer; nodes without a cloud provider ID are bare metal nodes of the bareMetal provider
+// (CloudProviderMetalLB), empty if all the nodes run on a cloud provider
+func NewExplorer(client kubernetes.Interface, bareMetal CloudProvider) Explorer {
+	return node.NewExplorer(client, bareMetal, "")
+}
+
+// IsIgnored checks if the node is opted out of KubeIP
+func IsIgnored(n *Node) bool {
+	return node.IsIgnored(n)
+}